@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// initDataHeader carries the mini app's Telegram.WebApp.initData on API requests
+const initDataHeader = "X-Telegram-Init-Data"
+
+// initDataMaxAge is how long signed initData is accepted after Telegram issued it
+const initDataMaxAge = 24 * time.Hour
+
+// initDataAuth verifies that API requests come from the mini app opened in Telegram by
+// an authorized user, see https://core.telegram.org/bots/webapps#validating-data-received-via-the-mini-app
+type initDataAuth struct {
+	secretKey []byte      // HMAC-SHA256 of the bot token keyed with "WebAppData"
+	users     *auth.Users // Nil or empty allows every Telegram user, like the bot itself
+	now       func() time.Time
+
+	// allowUser accepts other users too, those with their own workspace. Nil accepts nobody else.
+	allowUser func(userID int64) bool
+}
+
+func newInitDataAuth(botToken string, users *auth.Users) *initDataAuth {
+	mac := hmac.New(sha256.New, []byte("WebAppData"))
+	mac.Write([]byte(botToken))
+	return &initDataAuth{secretKey: mac.Sum(nil), users: users, now: time.Now}
+}
+
+// verify checks the hash, age and user of initData, returning the user ID
+func (a *initDataAuth) verify(initData string) (int64, error) {
+	if initData == "" {
+		return 0, errors.New("missing initData")
+	}
+	values, err := url.ParseQuery(initData)
+	if err != nil {
+		return 0, fmt.Errorf("malformed initData: %w", err)
+	}
+
+	hash := values.Get("hash")
+	if hash == "" {
+		return 0, errors.New("initData has no hash")
+	}
+	// The signed string is every other field as key=value, sorted by key
+	pairs := make([]string, 0, len(values))
+	for key := range values {
+		if key != "hash" {
+			pairs = append(pairs, key+"="+values.Get(key))
+		}
+	}
+	sort.Strings(pairs)
+	mac := hmac.New(sha256.New, a.secretKey)
+	mac.Write([]byte(strings.Join(pairs, "\n")))
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(hash)) {
+		return 0, errors.New("initData hash mismatch")
+	}
+
+	authDate, err := strconv.ParseInt(values.Get("auth_date"), 10, 64)
+	if err != nil {
+		return 0, errors.New("initData has no valid auth_date")
+	}
+	if age := a.now().Sub(time.Unix(authDate, 0)); age > initDataMaxAge {
+		return 0, fmt.Errorf("initData expired %v ago", (age - initDataMaxAge).Round(time.Second))
+	}
+
+	var user struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal([]byte(values.Get("user")), &user); err != nil || user.ID == 0 {
+		return 0, errors.New("initData has no user")
+	}
+	if !a.users.Allows(user.ID) && (a.allowUser == nil || !a.allowUser(user.ID)) {
+		return 0, fmt.Errorf("user %d is not authorized", user.ID)
+	}
+	return user.ID, nil
+}
+
+// apiUserKey is the request context key of the user verified by requireInitData
+type apiUserKey struct{}
+
+// apiUserID returns the user verified by requireInitData, 0 for unchecked requests
+func apiUserID(r *http.Request) int64 {
+	userID, _ := r.Context().Value(apiUserKey{}).(int64)
+	return userID
+}
+
+// requireInitData rejects API requests without valid initData with 401. The initData is
+// read from the X-Telegram-Init-Data header, or the initData query parameter for links.
+// The verified user is kept in the request's context. Without a verifier, as with
+// ALLOW_INSECURE_API, requests pass unchecked.
+func (s *apiServer) requireInitData(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflights carry no headers to check
+		if s.auth == nil || r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+
+		initData := r.Header.Get(initDataHeader)
+		if initData == "" {
+			initData = r.URL.Query().Get("initData")
+		}
+		userID, err := s.auth.verify(initData)
+		if err != nil {
+			log.Printf("Rejected %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), apiUserKey{}, userID)))
+	}
+}
+
+// notionFor returns the Notion client of the request's user: their own workspace's, or the
+// bot's for everyone else
+func (s *apiServer) notionFor(r *http.Request) *notion.Client {
+	if client := s.tenants.Client(apiUserID(r)); client != nil {
+		return client
+	}
+	return s.notion
+}
+
+// ownerOnly rejects requests from users with their own workspace with 403, for endpoints
+// working on the bot's workspace or the whole bot
+func (s *apiServer) ownerOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.tenants.IsConnected(apiUserID(r)) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]string{"error": "only available to the bot's owner"})
+			return
+		}
+		next(w, r)
+	}
+}
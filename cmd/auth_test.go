@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+)
+
+const (
+	testBotToken = "123456:TEST-TOKEN"
+
+	// validInitData is signed with testBotToken for user 42 at auth_date 1700000000
+	validInitData = "auth_date=1700000000&query_id=AAHdF6IQAAAAAN0XohDhrOrc&user=%7B%22id%22%3A42%2C%22first_name%22%3A%22Ada%22%2C%22username%22%3A%22ada%22%7D&hash=8d12ebca289dcf66905cf5135b7cd6cffeacdb9a74301e3ab0c30a2694655a37"
+)
+
+// newTestAuth creates a verifier for testBotToken at an hour after validInitData was issued
+func newTestAuth(authorizedUserIDs ...int64) *initDataAuth {
+	a := newInitDataAuth(testBotToken, auth.NewUsers(authorizedUserIDs...))
+	a.now = func() time.Time { return time.Unix(1700000000, 0).Add(time.Hour) }
+	return a
+}
+
+func TestVerifyInitData(t *testing.T) {
+	userID, err := newTestAuth(42).verify(validInitData)
+	if err != nil || userID != 42 {
+		t.Fatalf("Expected valid initData for user 42, got %d, %v", userID, err)
+	}
+
+	tests := []struct {
+		name     string
+		auth     *initDataAuth
+		initData string
+	}{
+		{"missing", newTestAuth(42), ""},
+		{"tampered user", newTestAuth(42), strings.Replace(validInitData, "%22id%22%3A42", "%22id%22%3A43", 1)},
+		{"wrong hash", newTestAuth(42), validInitData[:len(validInitData)-1] + "0"},
+		{"no hash", newTestAuth(42), validInitData[:strings.Index(validInitData, "&hash=")]},
+		{"other bot", &initDataAuth{secretKey: newInitDataAuth("654321:OTHER", nil).secretKey, now: newTestAuth().now}, validInitData},
+		{"other user", newTestAuth(7), validInitData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.auth.verify(tt.initData); err == nil {
+				t.Error("Expected the initData to be rejected")
+			}
+		})
+	}
+
+	// Users with their own workspace are accepted besides the authorized one
+	connected := newTestAuth(7)
+	connected.allowUser = func(userID int64) bool { return userID == 42 }
+	if userID, err := connected.verify(validInitData); err != nil || userID != 42 {
+		t.Errorf("Expected a connected user to be accepted, got %d, %v", userID, err)
+	}
+
+	expired := newTestAuth(42)
+	expired.now = func() time.Time { return time.Unix(1700000000, 0).Add(25 * time.Hour) }
+	if _, err := expired.verify(validInitData); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("Expected day-old initData to be expired, got %v", err)
+	}
+}
+
+func TestAPIRequiresInitData(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.auth = newTestAuth(42)
+	mux := server.routes()
+
+	request := func(path, initData string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if initData != "" {
+			req.Header.Set(initDataHeader, initData)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := request("/notion/mini-app/api/config", ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without initData, got %d", code)
+	}
+	if code := request("/notion/mini-app/api/config", "auth_date=1700000000&hash=00"); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for invalid initData, got %d", code)
+	}
+	if code := request("/notion/mini-app/api/config", validInitData); code != http.StatusOK {
+		t.Errorf("Expected 200 with valid initData, got %d", code)
+	}
+	if code := request("/notion/mini-app/api/config?initData="+strings.ReplaceAll(validInitData, "&", "%26"), ""); code != http.StatusOK {
+		t.Errorf("Expected initData to be accepted as a query parameter, got %d", code)
+	}
+
+	// Health probes stay open for the reverse proxy
+	if code := request("/notion/mini-app/api/healthz", ""); code != http.StatusOK {
+		t.Errorf("Expected healthz without initData, got %d", code)
+	}
+}
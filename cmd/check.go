@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/selfcheck"
+)
+
+// checkedDatabases are the Notion databases validated at startup. Only tasks is required,
+// the others are optional features.
+var checkedDatabases = []struct {
+	dbType   string
+	optional bool
+}{
+	{"tasks", false},
+	{"notes", true},
+	{"journal", true},
+	{"projects", true},
+}
+
+// selfChecks lists the checks of the configured services. telegramEndpoint is the Bot API
+// endpoint format, tgbotapi.APIEndpoint outside tests.
+func selfChecks(token, telegramEndpoint string, notionClient *notion.Client, provider llm.Provider) []selfcheck.Check {
+	checks := []selfcheck.Check{{
+		Component: "telegram",
+		Run: func(ctx context.Context) (string, error) {
+			if token == "" {
+				return "", fmt.Errorf("TELEGRAM_BOT_TOKEN not set")
+			}
+			// Creating the API client calls getMe, which fails for an invalid token
+			client := &http.Client{Transport: contextTransport{ctx: ctx}}
+			botAPI, err := tgbotapi.NewBotAPIWithClient(token, telegramEndpoint, client)
+			if err != nil {
+				return "", fmt.Errorf("getMe failed: %w", err)
+			}
+			return "@" + botAPI.Self.UserName, nil
+		},
+	}}
+
+	for _, db := range checkedDatabases {
+		dbType := db.dbType
+		checks = append(checks, selfcheck.Check{
+			Component: "notion " + dbType + " database",
+			Optional:  db.optional,
+			Run: func(ctx context.Context) (string, error) {
+				if !notionClient.HasDatabase(dbType) {
+					return "", selfcheck.ErrNotConfigured
+				}
+				title, err := notionClient.CheckDatabase(ctx, dbType)
+				if err != nil {
+					return "", err
+				}
+				return fmt.Sprintf("%q", title), nil
+			},
+		})
+	}
+
+	checks = append(checks, selfcheck.Check{
+		Component: "llm",
+		Optional:  true,
+		Run: func(ctx context.Context) (string, error) {
+			if provider == nil {
+				return "", selfcheck.ErrNotConfigured
+			}
+			checker, ok := provider.(llm.Checker)
+			if !ok {
+				return "not checked", nil
+			}
+			return "", checker.Check(ctx)
+		},
+	})
+	return checks
+}
+
+// runSelfCheck runs the checks and logs the results as a table, reporting whether every
+// required component passed
+func runSelfCheck(ctx context.Context, checks []selfcheck.Check) bool {
+	results := selfcheck.Run(ctx, checks)
+
+	var table strings.Builder
+	selfcheck.WriteTable(&table, results)
+	log.Printf("Configuration check:\n%s", table.String())
+
+	passed := selfcheck.Passed(results)
+	for _, result := range results {
+		if result.Status() == "WARN" {
+			log.Printf("Warning: %s check failed, continuing without it: %v", result.Component, result.Err)
+		}
+	}
+	return passed
+}
+
+// contextTransport attaches a context to requests made by clients that don't take one,
+// like the Telegram library
+type contextTransport struct {
+	ctx context.Context
+}
+
+func (t contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req.WithContext(t.ctx))
+}
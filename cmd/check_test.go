@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/selfcheck"
+)
+
+// newCheckServer fakes the Telegram and Gemini APIs, accepting only the given token and key
+func newCheckServer(t *testing.T, token, key string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/bot"+token+"/getMe":
+			w.Write([]byte(`{"ok": true, "result": {"id": 1, "is_bot": true, "username": "tasks_bot"}}`))
+		case strings.HasPrefix(r.URL.Path, "/bot"):
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"ok": false, "error_code": 401, "description": "Unauthorized"}`))
+		case strings.HasPrefix(r.URL.Path, "/v1beta/models/") && r.URL.Query().Get("key") == key:
+			w.Write([]byte(`{"name": "models/gemini-2.0-flash-lite"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"code": 400, "message": "API key not valid"}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func checkResults(t *testing.T, token, key string) []selfcheck.Result {
+	t.Helper()
+	server := newCheckServer(t, "good-token", "good-key")
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	t.Setenv("NOTION_NOTES_DATABASE_ID", "")
+	t.Setenv("NOTION_JOURNAL_DATABASE_ID", "")
+	t.Setenv("NOTION_PROJECTS_DATABASE_ID", "")
+	t.Setenv("GEMINI_API_KEY", key)
+	t.Setenv("GEMINI_API_URL", server.URL)
+
+	notionClient := notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: &fakeNotionTransport{}}))
+	checks := selfChecks(token, server.URL+"/bot%s/%s", notionClient, gemini.NewClient())
+	return selfcheck.Run(context.Background(), checks)
+}
+
+// statuses maps each component to its status
+func statuses(results []selfcheck.Result) map[string]string {
+	byComponent := make(map[string]string)
+	for _, result := range results {
+		byComponent[result.Component] = result.Status()
+	}
+	return byComponent
+}
+
+func TestSelfCheckPasses(t *testing.T) {
+	results := checkResults(t, "good-token", "good-key")
+
+	if !selfcheck.Passed(results) {
+		t.Fatalf("Expected the check to pass, got %v", statuses(results))
+	}
+	got := statuses(results)
+	for component, want := range map[string]string{
+		"telegram":              "OK",
+		"notion tasks database": "OK",
+		"notion notes database": "SKIP",
+		"llm":                   "OK",
+	} {
+		if got[component] != want {
+			t.Errorf("Expected %s for %s, got %s", want, component, got[component])
+		}
+	}
+}
+
+func TestSelfCheckFailsOnInvalidToken(t *testing.T) {
+	results := checkResults(t, "typo-token", "good-key")
+
+	if selfcheck.Passed(results) {
+		t.Error("Expected an invalid Telegram token to fail the check")
+	}
+	if got := statuses(results)["telegram"]; got != "FAIL" {
+		t.Errorf("Expected FAIL for telegram, got %s", got)
+	}
+}
+
+func TestSelfCheckWarnsOnInvalidGeminiKey(t *testing.T) {
+	results := checkResults(t, "good-token", "bad-key")
+
+	if !selfcheck.Passed(results) {
+		t.Error("Expected an invalid Gemini key not to fail the check")
+	}
+	if got := statuses(results)["llm"]; got != "WARN" {
+		t.Errorf("Expected WARN for llm, got %s", got)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// exportTimeout bounds an export, well past the server's WriteTimeout since large
+	// databases take many Notion queries
+	exportTimeout = 5 * time.Minute
+	// exportFlushEvery is how many rows are written between flushes to the client
+	exportFlushEvery = 100
+)
+
+// exportWriter writes tasks in one export format
+type exportWriter interface {
+	// begin writes what comes before the first task
+	begin() error
+	write(task notion.Task) error
+	// flush passes buffered rows on to the response
+	flush() error
+	// end writes what comes after the last task and flushes
+	end() error
+}
+
+// csvExport writes a header row, then a row per task with properties flattened
+type csvExport struct {
+	w       *csv.Writer
+	columns []string
+}
+
+func (e *csvExport) begin() error {
+	return e.w.Write(append([]string{"id", "title", "url", "created_at"}, e.columns...))
+}
+
+func (e *csvExport) write(task notion.Task) error {
+	row := []string{task.ID, task.Title, task.URL, task.CreatedAt.UTC().Format(time.RFC3339)}
+	for _, column := range e.columns {
+		row = append(row, csvValue(task.Properties[column]))
+	}
+	return e.w.Write(row)
+}
+
+func (e *csvExport) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) end() error {
+	return e.flush()
+}
+
+// csvValue flattens a property value into a cell. Multi-selects and people are joined
+// with ";", values without a plain form are written as JSON.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []string:
+		return strings.Join(v, ";")
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(encoded)
+}
+
+// jsonExport writes a JSON array of tasks, one element at a time
+type jsonExport struct {
+	w       io.Writer
+	written int
+}
+
+func (e *jsonExport) begin() error {
+	_, err := io.WriteString(e.w, "[")
+	return err
+}
+
+func (e *jsonExport) write(task notion.Task) error {
+	encoded, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	separator := "\n"
+	if e.written > 0 {
+		separator = ",\n"
+	}
+	e.written++
+	_, err = e.w.Write(append([]byte(separator), encoded...))
+	return err
+}
+
+func (e *jsonExport) flush() error {
+	return nil
+}
+
+func (e *jsonExport) end() error {
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+// handleExport streams every page of a database as CSV or JSON:
+// GET /api/export?db_type=tasks&format=csv|json. Rows are written as Notion returns
+// them, an export failing halfway ends with a truncated file and a logged error.
+func (s *apiServer) handleExport(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Export API called from: %s", r.RemoteAddr)
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	sendJSONError := func(statusCode int, message string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": message,
+		})
+	}
+
+	if r.Method != http.MethodGet {
+		sendJSONError(http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	dbType, err := dbTypeParam(r)
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
+	}
+	client := s.notionFor(r)
+	if !client.HasDatabase(dbType) {
+		sendJSONError(http.StatusBadRequest, fmt.Sprintf("database %s is not configured", dbType))
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		sendJSONError(http.StatusBadRequest, fmt.Sprintf("format must be csv or json, got %q", format))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), exportTimeout)
+	defer cancel()
+
+	// The server's WriteTimeout is meant for API calls, not for exports
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Now().Add(exportTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Warning: Could not extend the export's write deadline: %v", err)
+	}
+
+	var export exportWriter
+	contentType := "application/json"
+	if format == "csv" {
+		columns, err := client.ExportColumns(ctx, dbType)
+		if err != nil {
+			log.Printf("Error preparing export: %v", err)
+			sendJSONError(http.StatusBadGateway, fmt.Sprintf("Failed to export %s: %s", dbType, notion.Explain(err)))
+			return
+		}
+		export = &csvExport{w: csv.NewWriter(w), columns: columns}
+		contentType = "text/csv; charset=utf-8"
+	} else {
+		export = &jsonExport{w: w}
+	}
+
+	// Headers go out with the first row, so a failing first query still gets an error status
+	started := false
+	start := func() error {
+		if started {
+			return nil
+		}
+		started = true
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, dbType, time.Now().Format("2006-01-02"), format))
+		w.WriteHeader(http.StatusOK)
+		return export.begin()
+	}
+
+	rows := 0
+	err = client.ExportTasks(ctx, dbType, func(task notion.Task) error {
+		if err := start(); err != nil {
+			return err
+		}
+		if err := export.write(task); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportFlushEvery == 0 {
+			if err := export.flush(); err != nil {
+				return err
+			}
+			if err := controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && !started {
+		log.Printf("Error exporting %s: %v", dbType, err)
+		sendJSONError(http.StatusBadGateway, fmt.Sprintf("Failed to export %s: %s", dbType, notion.Explain(err)))
+		return
+	}
+	if err != nil {
+		log.Printf("Error exporting %s after %d rows, the file is truncated: %v", dbType, rows, err)
+		return
+	}
+
+	if err := start(); err != nil {
+		log.Printf("Error writing export: %v", err)
+		return
+	}
+	if err := export.end(); err != nil {
+		log.Printf("Error writing export: %v", err)
+		return
+	}
+	log.Printf("Exported %d %s as %s", rows, dbType, format)
+}
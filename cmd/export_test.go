@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// exportNotion answers the export's schema fetch and two pages of task queries
+type exportNotion struct{}
+
+func (exportNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	page := func(id, title, tags, estimate string) string {
+		return `{"object": "page", "id": "` + id + `", "url": "https://www.notion.so/` + id + `", "created_time": "2024-03-01T10:00:00.000Z", "properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": ` + title + `}, "plain_text": ` + title + `}]},
+			"Tags": {"id": "tags", "type": "multi_select", "multi_select": ` + tags + `},
+			"Estimate": {"id": "est", "type": "number", "number": ` + estimate + `}
+		}}`
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	var response string
+	switch {
+	case req.Method == http.MethodGet:
+		response = `{"object": "database", "id": "tasks-db", "properties": {
+			"Name": {"id": "title", "type": "title", "title": {}},
+			"Tags": {"id": "tags", "type": "multi_select", "multi_select": {"options": []}},
+			"Estimate": {"id": "est", "type": "number", "number": {}}
+		}}`
+	case strings.Contains(string(body), `"start_cursor":"page-2"`):
+		response = `{"object": "list", "has_more": false, "results": [` +
+			page("task-3", `"Plain"`, `[]`, `0.5`) + `]}`
+	default:
+		response = `{"object": "list", "has_more": true, "next_cursor": "page-2", "results": [` +
+			page("task-1", `"Call \"Bob\", maybe"`, `[{"name": "work"}, {"name": "phone"}]`, `2`) + `,` +
+			page("task-2", `"Line one\nline two"`, `[{"name": "home"}]`, `1`) + `]}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+func exportRequest(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	t.Setenv("NOTION_NOTES_DATABASE_ID", "")
+	server := &apiServer{
+		notion: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: exportNotion{}})),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/export?"+query, nil)
+	rec := httptest.NewRecorder()
+	server.routes().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestExportCSV(t *testing.T) {
+	rec := exportRequest(t, "db_type=tasks&format=csv")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	want := `attachment; filename="tasks-` + time.Now().Format("2006-01-02") + `.csv"`
+	if got := rec.Header().Get("Content-Disposition"); got != want {
+		t.Errorf("Expected Content-Disposition %s, got %s", want, got)
+	}
+
+	raw := rec.Body.String()
+	for _, escaped := range []string{`"Call ""Bob"", maybe"`, "\"Line one\nline two\""} {
+		if !strings.Contains(raw, escaped) {
+			t.Errorf("Expected %q in the CSV:\n%s", escaped, raw)
+		}
+	}
+
+	rows, err := csv.NewReader(strings.NewReader(raw)).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV: %v\n%s", err, raw)
+	}
+	wantRows := [][]string{
+		{"id", "title", "url", "created_at", "Estimate", "Tags"},
+		{"task-1", `Call "Bob", maybe`, "https://www.notion.so/task-1", "2024-03-01T10:00:00Z", "2", "work;phone"},
+		{"task-2", "Line one\nline two", "https://www.notion.so/task-2", "2024-03-01T10:00:00Z", "1", "home"},
+		{"task-3", "Plain", "https://www.notion.so/task-3", "2024-03-01T10:00:00Z", "0.5", ""},
+	}
+	if len(rows) != len(wantRows) {
+		t.Fatalf("Expected %d rows, got %d: %q", len(wantRows), len(rows), rows)
+	}
+	for i := range wantRows {
+		if strings.Join(rows[i], "|") != strings.Join(wantRows[i], "|") {
+			t.Errorf("Row %d: expected %q, got %q", i, wantRows[i], rows[i])
+		}
+	}
+}
+
+func TestExportJSON(t *testing.T) {
+	rec := exportRequest(t, "format=json")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	if !strings.HasSuffix(rec.Header().Get("Content-Disposition"), `.json"`) {
+		t.Errorf("Expected a .json filename, got %s", rec.Header().Get("Content-Disposition"))
+	}
+
+	// One element per line, so the array can be written as pages arrive
+	raw := rec.Body.String()
+	if !strings.HasPrefix(raw, "[\n{") || strings.Count(raw, "},\n{") != 2 || !strings.HasSuffix(raw, "}\n]\n") {
+		t.Errorf("Expected a streamed array of 3 tasks, got:\n%s", raw)
+	}
+	var tasks []notion.Task
+	if err := json.Unmarshal(rec.Body.Bytes(), &tasks); err != nil {
+		t.Fatalf("Invalid JSON: %v\n%s", err, raw)
+	}
+	if len(tasks) != 3 || tasks[0].Title != `Call "Bob", maybe` || tasks[2].ID != "task-3" {
+		t.Errorf("Expected the tasks of both pages in order, got %+v", tasks)
+	}
+}
+
+func TestExportRejectsUnknownFormatsAndMissingDatabases(t *testing.T) {
+	for _, query := range []string{"format=xml", "db_type=notes"} {
+		rec := exportRequest(t, query)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", query, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/selfcheck"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// readinessInterval is how long readiness results are reused before Notion is asked again
+const readinessInterval = 30 * time.Second
+
+// newReadinessProber checks the Notion API, through the tasks database, and the local
+// database for /readyz
+func newReadinessProber(notionClient *notion.Client, db *database.DB) *selfcheck.Prober {
+	return selfcheck.NewProber([]selfcheck.Check{
+		{
+			Component: "notion",
+			Run: func(ctx context.Context) (string, error) {
+				_, err := notionClient.CheckDatabase(ctx, "tasks")
+				return "", err
+			},
+		},
+		{
+			Component: "database",
+			Run: func(ctx context.Context) (string, error) {
+				if db == nil {
+					return "", errors.New("local database unavailable")
+				}
+				return "", db.Check(ctx)
+			},
+		},
+	}, readinessInterval)
+}
+
+// buildInfo describes the running binary
+func buildInfo() map[string]string {
+	info := map[string]string{
+		"version":    version,
+		"go_version": runtime.Version(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" {
+				info["revision"] = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+// handleHealthz reports that the server is up, without checking any dependency
+func (s *apiServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"build":          buildInfo(),
+		"started_at":     s.startedAt.UTC().Format(time.RFC3339),
+		"uptime_seconds": int64(time.Since(s.startedAt).Seconds()),
+	})
+}
+
+// readinessCheck is one dependency in the /readyz response
+type readinessCheck struct {
+	Component string `json:"component"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// handleReadyz reports whether Notion and the local database work, 503 naming the failing
+// ones otherwise. Results are cached for readinessInterval.
+func (s *apiServer) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	results, checkedAt := s.readiness.Results(ctx)
+	checks := make([]readinessCheck, 0, len(results))
+	for _, result := range results {
+		check := readinessCheck{Component: result.Component, Status: result.Status()}
+		if result.Err != nil {
+			check.Error = result.Err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	status, code := "ok", http.StatusOK
+	if !selfcheck.Passed(results) {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     status,
+		"checks":     checks,
+		"checked_at": checkedAt.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+func TestHealthzReportsUptime(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.startedAt = time.Now().Add(-time.Minute)
+
+	rec := httptest.NewRecorder()
+	server.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/healthz", nil))
+
+	var body struct {
+		Status        string            `json:"status"`
+		Build         map[string]string `json:"build"`
+		UptimeSeconds int64             `json:"uptime_seconds"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body, err)
+	}
+	if rec.Code != http.StatusOK || body.Status != "ok" || body.Build["version"] == "" {
+		t.Errorf("Expected ok with build info, got %d %s", rec.Code, rec.Body)
+	}
+	if body.UptimeSeconds < 60 {
+		t.Errorf("Expected at least a minute of uptime, got %d", body.UptimeSeconds)
+	}
+}
+
+// readyz requests readiness and decodes the checks by component
+func readyz(t *testing.T, server *apiServer) (int, map[string]readinessCheck) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	server.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/readyz", nil))
+
+	var body struct {
+		Checks []readinessCheck `json:"checks"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Invalid response %s: %v", rec.Body, err)
+	}
+	checks := make(map[string]readinessCheck)
+	for _, check := range body.Checks {
+		checks[check.Component] = check
+	}
+	return rec.Code, checks
+}
+
+func TestReadyzPasses(t *testing.T) {
+	server, _ := newTestServer(t)
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	server.readiness = newReadinessProber(server.notion, db)
+
+	code, checks := readyz(t, server)
+	if code != http.StatusOK || checks["notion"].Status != "OK" || checks["database"].Status != "OK" {
+		t.Errorf("Expected ready, got %d %+v", code, checks)
+	}
+}
+
+func TestReadyzNamesFailingDependency(t *testing.T) {
+	server, fake := newTestServer(t)
+	server.readiness = newReadinessProber(server.notion, nil)
+
+	code, checks := readyz(t, server)
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", code)
+	}
+	if checks["database"].Status != "FAIL" || checks["database"].Error == "" {
+		t.Errorf("Expected the database to be reported failing, got %+v", checks["database"])
+	}
+	if checks["notion"].Status != "OK" {
+		t.Errorf("Expected Notion to pass, got %+v", checks["notion"])
+	}
+
+	// A second probe is served from the cache
+	readyz(t, server)
+	if fake.schemaFetches != 1 {
+		t.Errorf("Expected Notion to be checked once, got %d", fake.schemaFetches)
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// maxImportBytes bounds the uploaded CSV
+	maxImportBytes = 5 << 20
+	// maxImportRows bounds how many tasks one import creates, at the Notion rate limit a
+	// thousand rows take a few minutes
+	maxImportRows = 1000
+	// importTimeout bounds an import, well past the server's WriteTimeout
+	importTimeout = 10 * time.Minute
+)
+
+// importColumns maps the accepted CSV columns, matched ignoring case, to the tasks
+// database properties they fill. Title is required.
+var importColumns = map[string]string{
+	"title":   "Title",
+	"tags":    "Tags",
+	"date":    "Date",
+	"project": "project",
+	"status":  "status",
+}
+
+// importRowError is a row that wasn't imported
+type importRowError struct {
+	Row   int    `json:"row"` // Line of the row in the file, the header is line 1
+	Error string `json:"error"`
+}
+
+// importSummary is the response of an import. In a dry run created counts the rows that
+// would be created.
+type importSummary struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Errors  []importRowError `json:"errors"`
+	DryRun  bool             `json:"dry_run,omitempty"`
+}
+
+// parseImportHeader returns the property each column fills, rejecting unknown or repeated
+// columns and a missing Title
+func parseImportHeader(header []string) ([]string, error) {
+	properties := make([]string, len(header))
+	seen := make(map[string]bool)
+	for i, column := range header {
+		name := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		property, ok := importColumns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q, expected Title, Tags, Date, project or status", column)
+		}
+		if seen[property] {
+			return nil, fmt.Errorf("column %q appears twice", column)
+		}
+		seen[property] = true
+		properties[i] = property
+	}
+	if !seen["Title"] {
+		return nil, errors.New("the Title column is required")
+	}
+	return properties, nil
+}
+
+// importRow converts a row into a title and properties for CreateTask. Tags are separated
+// with ";" like in exports, empty cells are left out.
+func importRow(properties, record []string) (string, map[string]interface{}) {
+	var title string
+	values := make(map[string]interface{})
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		switch properties[i] {
+		case "Title":
+			title = cell
+		case "Tags":
+			var tags []interface{}
+			for _, tag := range strings.Split(cell, ";") {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			values["Tags"] = tags
+		default:
+			values[properties[i]] = cell
+		}
+	}
+	return title, values
+}
+
+// handleImport creates a task per row of an uploaded CSV: POST /api/import with the file
+// in the "file" field of a multipart form. Rows are converted by CreateTask's property
+// handlers; a row that can't be converted or created is reported and the rest go on.
+// ?dry_run=1 validates every row without creating anything.
+func (s *apiServer) handleImport(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Import API called from: %s", r.RemoteAddr)
+
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	sendJSONError := func(statusCode int, message string) {
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": message,
+		})
+	}
+
+	if r.Method != http.MethodPost {
+		sendJSONError(http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, fmt.Sprintf("Expected a CSV file of at most %d MB in the \"file\" field: %v", maxImportBytes>>20, err))
+		return
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err == io.EOF {
+		sendJSONError(http.StatusBadRequest, "The CSV file is empty")
+		return
+	}
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, fmt.Sprintf("Invalid CSV header: %v", err))
+		return
+	}
+	properties, err := parseImportHeader(header)
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), importTimeout)
+	defer cancel()
+	ctx = optionsContext(ctx, r)
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(importTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Printf("Warning: Could not extend the import's write deadline: %v", err)
+	}
+
+	client := s.notionFor(r)
+	summary := importSummary{Errors: []importRowError{}, DryRun: queryFlag(r, "dry_run")}
+	fail := func(row int, message string) {
+		summary.Failed++
+		summary.Errors = append(summary.Errors, importRowError{Row: row, Error: message})
+	}
+
+	for rows := 0; ; rows++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) && errors.Is(err, csv.ErrFieldCount) {
+			fail(parseErr.StartLine, fmt.Sprintf("expected %d cells, got %d", len(header), len(record)))
+			continue
+		}
+		if errors.As(err, &parseErr) {
+			// The rest of the file can't be read reliably
+			fail(parseErr.StartLine, fmt.Sprintf("invalid CSV, stopped here: %v", parseErr.Err))
+			break
+		}
+		if err != nil {
+			fail(0, fmt.Sprintf("could not read the file: %v", err))
+			break
+		}
+		row, _ := reader.FieldPos(0)
+		if rows == maxImportRows {
+			fail(row, fmt.Sprintf("only %d rows are imported at once, stopped here", maxImportRows))
+			break
+		}
+
+		title, values := importRow(properties, record)
+		if title == "" {
+			fail(row, "the title is empty")
+			continue
+		}
+
+		plan, err := client.PlanCreateTask(ctx, title, values, "tasks")
+		if err != nil {
+			fail(row, err.Error())
+			continue
+		}
+		// Defaults the database lacks are skipped on every row, only the row's own count
+		var problems []string
+		for _, warning := range plan.Warnings {
+			for key := range values {
+				if strings.HasPrefix(warning, key+": ") {
+					problems = append(problems, warning)
+				}
+			}
+		}
+		if len(problems) > 0 {
+			fail(row, strings.Join(problems, "; "))
+			continue
+		}
+
+		if summary.DryRun {
+			summary.Created++
+			continue
+		}
+		if _, err := client.ExecuteCreatePlan(ctx, plan); err != nil {
+			log.Printf("Error importing row %d: %v", row, err)
+			fail(row, err.Error())
+			continue
+		}
+		summary.Created++
+	}
+
+	if summary.Created > 0 && !summary.DryRun {
+		s.recentTasks.invalidate()
+	}
+	log.Printf("Import finished: %d created, %d failed (dry run: %v)", summary.Created, summary.Failed, summary.DryRun)
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		log.Printf("Error encoding import summary: %v", err)
+	}
+}
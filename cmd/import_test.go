@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// importNotion answers the tasks schema and records the pages created
+type importNotion struct {
+	created []string
+}
+
+func (n *importNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+	response := `{"object": "database", "id": "tasks-db", "properties": {
+		"Name": {"id": "title", "type": "title", "title": {}},
+		"Tags": {"id": "tags", "type": "multi_select", "multi_select": {"options": []}},
+		"Date": {"id": "date", "type": "date", "date": {}},
+		"project": {"id": "proj", "type": "select", "select": {"options": []}},
+		"status": {"id": "stat", "type": "select", "select": {"options": []}}
+	}}`
+	if req.Method == http.MethodPost && req.URL.Path == "/v1/pages" {
+		n.created = append(n.created, string(body))
+		response = `{"object": "page", "id": "page-42"}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+func importRequest(t *testing.T, query, csvFile string) (*httptest.ResponseRecorder, *importNotion) {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	fake := &importNotion{}
+	server := &apiServer{
+		notion: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", "tasks.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, csvFile)
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/notion/mini-app/api/import?"+query, &form)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	server.routes().ServeHTTP(rec, req)
+	return rec, fake
+}
+
+const importCSV = "Title,Tags,Date,project\n" +
+	"Buy milk,home;errands,2024-03-01,\n" +
+	"Plan trip,,next blue moon,travel\n" +
+	"\"Call Bob, maybe\",work,,\n"
+
+func TestImportReportsBadRowsAndCreatesTheRest(t *testing.T) {
+	rec, fake := importRequest(t, "", importCSV)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var summary importSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if summary.Created != 2 || summary.Failed != 1 {
+		t.Fatalf("Expected 2 created and 1 failed, got %+v", summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Row != 3 || !strings.HasPrefix(summary.Errors[0].Error, "Date: ") {
+		t.Errorf("Expected the bad date reported on line 3, got %+v", summary.Errors)
+	}
+
+	if len(fake.created) != 2 {
+		t.Fatalf("Expected 2 pages created, got %d", len(fake.created))
+	}
+	if !strings.Contains(fake.created[0], `"multi_select":[{"name":"home"},{"name":"errands"}]`) {
+		t.Errorf("Expected the tags as a multi-select, got %s", fake.created[0])
+	}
+	if !strings.Contains(fake.created[1], `Call Bob, maybe`) {
+		t.Errorf("Expected the quoted title, got %s", fake.created[1])
+	}
+}
+
+func TestImportDryRunCreatesNothing(t *testing.T) {
+	rec, fake := importRequest(t, "dry_run=1", importCSV)
+	var summary importSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if !summary.DryRun || summary.Created != 2 || summary.Failed != 1 {
+		t.Errorf("Expected a dry run with 2 valid rows and 1 bad one, got %+v", summary)
+	}
+	if len(fake.created) != 0 {
+		t.Errorf("Expected no pages created in a dry run, got %d", len(fake.created))
+	}
+}
+
+func TestImportRejectsUnknownColumns(t *testing.T) {
+	for name, csvFile := range map[string]string{
+		"unknown column": "Title,Priority\nBuy milk,high\n",
+		"no title":       "Tags\nhome\n",
+	} {
+		rec, fake := importRequest(t, "", csvFile)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, rec.Code, rec.Body)
+		}
+		if len(fake.created) != 0 {
+			t.Errorf("%s: expected no pages created, got %d", name, len(fake.created))
+		}
+	}
+}
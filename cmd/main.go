@@ -2,42 +2,101 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/joho/godotenv"
 	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/alerts"
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
 	"github.com/numero_quadro/notion-mini-app/internal/bot"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
 	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
 	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/ollama"
+	"github.com/numero_quadro/notion-mini-app/internal/redact"
 	"github.com/numero_quadro/notion-mini-app/internal/scheduler"
+	"github.com/numero_quadro/notion-mini-app/internal/schemawatch"
+	"github.com/numero_quadro/notion-mini-app/internal/selfcheck"
+	"github.com/numero_quadro/notion-mini-app/internal/share"
+	"github.com/numero_quadro/notion-mini-app/internal/tenant"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+	"github.com/numero_quadro/notion-mini-app/internal/uploads"
+	"github.com/numero_quadro/notion-mini-app/internal/usage"
 )
 
+// saveWorkers is how many reacted messages are saved to Notion at the same time
+const saveWorkers = 2
+
 func main() {
+	checkOnly := flag.Bool("check", false, "validate the configuration against Telegram, Notion and the LLM provider, then exit")
+	flag.Parse()
+
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found, using environment variables")
 	}
 
+	// Keep the credentials out of the logs, the Bot API library's included
+	sanitizer := redact.New(logSecrets()...)
+	log.SetOutput(sanitizer.Writer(os.Stderr))
+	tgbotapi.SetLogger(log.New(sanitizer.Writer(os.Stderr), "", log.LstdFlags))
+
+	// Deployment health gates run only the configuration check
+	if *checkOnly {
+		checks := selfChecks(os.Getenv("TELEGRAM_BOT_TOKEN"), tgbotapi.APIEndpoint, notion.NewClient(), newLLMProvider())
+		if !runSelfCheck(context.Background(), checks) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// SIGINT/SIGTERM (docker stop) cancel ctx, which shuts everything down in order
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Set when the HTTP server fails, applied once the deferred cleanup has run
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Get bot token from environment
 	token := os.Getenv("TELEGRAM_BOT_TOKEN")
 	if token == "" {
 		log.Fatal("TELEGRAM_BOT_TOKEN environment variable is not set")
 	}
 
-	// Get authorized user ID
-	authorizedUserID := os.Getenv("AUTHORIZED_USER_ID")
-	if authorizedUserID == "" {
+	// Get the authorized users and who gets the scheduled messages. A typo must not open
+	// the bot to everyone.
+	users, err := auth.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !users.Restricted() {
 		log.Printf("Warning: AUTHORIZED_USER_ID not set, bot will be accessible to anyone")
 	} else {
-		log.Printf("Bot will be allowed only to user with IDs: %s", authorizedUserID)
+		log.Printf("Bot will be allowed only to users with IDs: %s", users)
 	}
 
 	// Get mini app URL
@@ -48,50 +107,179 @@ func main() {
 	}
 	log.Printf("Mini App URL: %s", miniAppURL)
 
+	// Export traces when OTEL_EXPORTER_OTLP_ENDPOINT is set
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Printf("Warning: Tracing disabled: %v", err)
+	} else {
+		defer shutdownTracing(context.Background())
+	}
+
 	// Initialize Notion client
 	notionClient := notion.NewClient()
 
-	// Initialize Gemini client
-	geminiClient := gemini.NewClient()
+	// Initialize the LLM provider (nil when AI features are disabled)
+	llmProvider := newLLMProvider()
+
+	// Fail fast on a wrong token or database ID, optional components only warn
+	if !runSelfCheck(ctx, selfChecks(token, tgbotapi.APIEndpoint, notionClient, llmProvider)) {
+		log.Fatal("Configuration check failed, see the table above")
+	}
 
-	// Initialize Telegram bot
-	botAPI, err := tgbotapi.NewBotAPI(token)
+	// Open the local database (optional - features that need it are skipped without it)
+	db := openDatabase()
+	if db != nil {
+		defer db.Close()
+	}
+
+	// Initialize Telegram bot, counting sent messages for the usage summary
+	telegramClient := &http.Client{Transport: usage.TelegramTransport(nil)}
+	botAPI, err := tgbotapi.NewBotAPIWithClient(token, tgbotapi.APIEndpoint, telegramClient)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	botAPI.Debug = true
+	// Debug logging prints every request and update, only when asked for
+	botAPI.Debug = os.Getenv("BOT_DEBUG") == "true"
 	log.Printf("Authorized on account %s", botAPI.Self.UserName)
 
 	// Initialize bot handler
-	handler := bot.NewHandler(botAPI, notionClient, geminiClient)
+	handler := bot.NewHandler(botAPI, notionClient, llmProvider, db, users)
 
-	// Set global variables for webhook handler (BEFORE scheduler setup)
-	globalHandler = handler
-	globalBot = botAPI
+	// Let TENANT_USER_IDS connect their own workspace with TENANT_ENCRYPTION_KEY set
+	tenants, err := tenant.NewRegistryFromEnv(db, notionClient)
+	if err != nil {
+		log.Fatalf("Invalid workspace connection settings: %v", err)
+	}
+	if tenants != nil {
+		handler.SetTenants(tenants)
+		log.Printf("Users in TENANT_USER_IDS can connect their own workspace with /connect")
+	}
 
-	// Get authorized user ID for scheduler
-	authorizedUserIDInt, err := strconv.ParseInt(authorizedUserID, 10, 64)
+	// Task sets for /template, a broken file fails startup rather than the first /template
+	templates, err := bot.TemplatesFromEnv()
 	if err != nil {
-		log.Printf("Warning: Invalid authorized user ID, scheduler will be disabled")
-		authorizedUserIDInt = 0
+		log.Fatal(err)
+	}
+	if len(templates) > 0 {
+		handler.SetTemplates(templates)
+		log.Printf("Loaded %d task templates", len(templates))
+	}
+
+	// Open the mini app from the chat's menu button
+	if err := handler.SetMenuButton(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Background work using the local database, waited for before it's closed
+	var background sync.WaitGroup
+	defer background.Wait()
+
+	// Finish saves interrupted by a previous shutdown
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.ReconcileSaveAttempts(ctx)
+	}()
+
+	// Save reacted messages in the background, finishing queued saves on shutdown
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.RunSaveWorkers(ctx, saveWorkers)
+	}()
+
+	// Problems that need attention are sent to the notified user
+	notifyUserID := users.NotifyUserID()
+	var alerter alerts.Alerter
+	if notifyUserID != 0 {
+		alerter = alerts.NewTelegramAlerter(botAPI, notifyUserID)
+		handler.SetAlerter(alerter)
+	}
+
+	// Record schema changes and alert about ones that break the bot (needs the local database)
+	if db != nil {
+		notionClient.SetSchemaHook(schemawatch.NewWatcher(db, alerter).Observe)
+	}
+
+	// Transcribe voice notes and videos in the background, cancelling them on shutdown
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.RunTranscriptions(ctx)
+	}()
+
+	// Drop messages that waited too long for their 👍
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.RunPendingSweeper(ctx)
+	}()
+
+	// Remove photos of saves that stopped with the bot
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.RunUploadCleanup(ctx)
+	}()
+
+	// Retry Notion writes queued while Notion was down (needs the local database)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		handler.RunOutbox(ctx)
+	}()
+
+	// Roll usage counters up into daily totals for /usage and the weekly summary
+	if db != nil {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			usage.RunRollup(ctx, db)
+		}()
 	}
 
 	// Start scheduler if user ID is configured
-	if authorizedUserIDInt != 0 {
-		schedulerCtx, schedulerCancel := context.WithCancel(context.Background())
-		defer schedulerCancel()
-
-        schedulerInstance := scheduler.NewScheduler(notionClient, botAPI, authorizedUserIDInt, "23:00", geminiClient)
-        globalScheduler = schedulerInstance
+	var schedulerInstance *scheduler.Scheduler
+	if notifyUserID != 0 {
+		schedulerInstance = scheduler.NewScheduler(notionClient, botAPI, users, os.Getenv("SCHEDULER_TIMES"), llmProvider, db)
 
 		// Link scheduler to handler for /cron command
 		handler.SetScheduler(schedulerInstance)
+		schedulerInstance.SetTenants(tenants)
 
-		go schedulerInstance.Start(schedulerCtx)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			schedulerInstance.Start(ctx)
+		}()
 		log.Printf("Scheduler started")
 	} else {
-		log.Printf("Scheduler disabled (authorized user not configured)")
+		log.Printf("Scheduler disabled (neither AUTHORIZED_USER_ID nor NOTIFY_USER_ID is set)")
+	}
+
+	// The HTTP handlers share the bot's Notion client and its schema cache
+	server := &apiServer{
+		notion:        notionClient,
+		db:            db,
+		scheduler:     schedulerInstance,
+		handler:       handler,
+		webhookSecret: os.Getenv("TELEGRAM_WEBHOOK_SECRET"),
+		updates:       newUpdateLog(),
+		recentTasks:   recentTasksCacheFromEnv(),
+		auth:          newInitDataAuth(token, users),
+		tenants:       tenants,
+		metricsToken:  os.Getenv("METRICS_TOKEN"),
+		startedAt:     time.Now(),
+		readiness:     newReadinessProber(notionClient, db),
+	}
+
+	// Connected users open the mini app on their own workspace
+	server.auth.allowUser = tenants.IsConnected
+
+	if os.Getenv("ALLOW_INSECURE_API") == "true" {
+		log.Printf("Warning: ALLOW_INSECURE_API is set, mini app API requests are not verified")
+		server.auth = nil
 	}
 
 	// Check if we should use webhook or polling
@@ -101,114 +289,294 @@ func main() {
 	if useWebhook {
 		log.Printf("Running in WEBHOOK mode: %s", webhookURL)
 		log.Printf("Bot will receive updates via webhook at /telegram/webhook")
-		log.Printf("Make sure webhook is configured with: ./setup-webhook.sh")
+		if server.webhookSecret != "" {
+			// Register the webhook ourselves so Telegram always sends the current secret
+			if err := registerWebhook(botAPI, webhookURL, server.webhookSecret); err != nil {
+				log.Printf("Warning: Could not register webhook: %v", err)
+			} else {
+				log.Printf("Webhook registered with secret token")
+			}
+		} else {
+			log.Printf("Warning: TELEGRAM_WEBHOOK_SECRET not set, webhook requests are not verified")
+			log.Printf("Make sure webhook is configured with: ./setup-webhook.sh")
+		}
 
 		// Serve static files and start webhook server
-		serveStaticFiles()
+		if err := server.serveStaticFiles(ctx); err != nil {
+			log.Printf("Error: HTTP server failed: %v", err)
+			exitCode = 1
+		}
 	} else {
 		log.Printf("Running in POLLING mode (webhook URL not set)")
 		log.Printf("WARNING: Reactions will NOT work in polling mode!")
 		log.Printf("To enable reactions, set WEBHOOK_URL and run ./setup-webhook.sh")
 
 		// Serve static files for mini app in background
-		go serveStaticFiles()
+		serverDone := make(chan error, 1)
+		go func() {
+			serverDone <- server.serveStaticFiles(ctx)
+		}()
 
 		// Use polling for development
 		updateConfig := tgbotapi.NewUpdate(0)
 		updateConfig.Timeout = 60
-		updateConfig.AllowedUpdates = []string{"message", "callback_query"}
+		updateConfig.AllowedUpdates = []string{"message", "edited_message", "callback_query", "inline_query", "chosen_inline_result"}
 
 		updates := botAPI.GetUpdatesChan(updateConfig)
 
-		// Handle updates
-		for update := range updates {
-			if update.Message != nil {
-				// Handle incoming messages
-				if err := handler.HandleMessage(update.Message); err != nil {
-					log.Printf("Error handling message: %v", err)
-				}
-			} else if update.CallbackQuery != nil {
-				// Handle callback queries (button clicks)
-				log.Printf("Received callback query: %s", update.CallbackQuery.Data)
-			}
+		// Handle updates until shutdown, or until the server fails
+		serverErr := pollUpdates(ctx, updates, serverDone, handler)
+		botAPI.StopReceivingUpdates()
+		if serverErr == nil {
+			serverErr = <-serverDone
+		}
+		if serverErr != nil {
+			log.Printf("Error: HTTP server failed: %v", serverErr)
+			exitCode = 1
 		}
 	}
+
+	// Stop the background work if the server failed rather than a signal arrived
+	stop()
+	log.Printf("Shutting down")
 }
 
-// handleMessageReactionFromUpdate extracts and handles message_reaction from update
-func handleMessageReactionFromUpdate(update tgbotapi.Update, handler *bot.Handler) error {
-	// Try to parse message_reaction from raw JSON
-	// The tgbotapi library v5.5.1 doesn't natively support message_reaction,
-	// but we can access it through reflection or custom unmarshaling
+// pollUpdates handles updates from long polling until ctx is done or the HTTP server
+// stops, returning the server's error in that case
+func pollUpdates(ctx context.Context, updates tgbotapi.UpdatesChannel, serverDone <-chan error, handler updateHandler) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-serverDone:
+			if err == nil {
+				err = errors.New("server stopped")
+			}
+			return err
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			dispatchUpdate(ctx, handler, &telegramUpdate{Update: update})
+		}
+	}
+}
 
-	// For debugging: log the update ID to see if we're receiving reactions
-	if update.Message == nil && update.CallbackQuery == nil {
-		log.Printf("Received non-standard update: UpdateID=%d", update.UpdateID)
+// logSecrets returns the credentials the logs must not show: the bot token, the Notion
+// and Gemini keys, which Gemini request errors include in their URL, and the password of
+// an OLLAMA_URL with one
+func logSecrets() []string {
+	secrets := []string{os.Getenv("TELEGRAM_BOT_TOKEN"), os.Getenv("NOTION_API_KEY"), os.Getenv("GEMINI_API_KEY")}
+	if ollamaURL, err := url.Parse(os.Getenv("OLLAMA_URL")); err == nil && ollamaURL.User != nil {
+		if password, ok := ollamaURL.User.Password(); ok {
+			secrets = append(secrets, password)
+		}
 	}
+	return secrets
+}
 
-	return nil
+// newLLMProvider creates the LLM provider selected by LLM_PROVIDER (gemini, ollama or none).
+// It returns an untyped nil for "none" so nil checks in the handler and scheduler hold.
+func newLLMProvider() llm.Provider {
+	provider := strings.ToLower(os.Getenv("LLM_PROVIDER"))
+	switch provider {
+	case "", "gemini":
+		return gemini.NewClient()
+	case "ollama":
+		return ollama.NewClient()
+	case "none":
+		log.Printf("LLM provider disabled, tagging and transcription are off")
+		return nil
+	default:
+		log.Printf("Warning: Unknown LLM_PROVIDER %q, AI features disabled", provider)
+		return nil
+	}
 }
 
-// Serve static files for the mini app
-func serveStaticFiles() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// openDatabase opens the SQLite database at DATABASE_PATH, returning nil if it is unavailable
+func openDatabase() *database.DB {
+	dbPath := os.Getenv("DATABASE_PATH")
+	if dbPath == "" {
+		dbPath = "./data/tasks.db"
 	}
 
-	host := os.Getenv("HOST")
-	if host == "" {
-		host = "0.0.0.0"
+	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
+		log.Printf("Warning: Could not create database directory: %v", err)
+		return nil
 	}
 
-	// Create a file server handler for the root
-	fs := http.FileServer(http.Dir("./web"))
+	db, err := database.NewDB(dbPath)
+	if err != nil {
+		log.Printf("Warning: Local database unavailable, continuing without it: %v", err)
+		return nil
+	}
+
+	return db
+}
+
+// apiServer holds the dependencies of the HTTP handlers. It's created once in main, so every
+// request shares the same Notion client and its schema cache.
+type apiServer struct {
+	notion    *notion.Client
+	db        *database.DB
+	scheduler *scheduler.Scheduler // nil when the scheduler is disabled
+	handler   updateHandler        // Receives webhook updates, nil in tests
+
+	// webhookSecret must match the secret token header of webhook requests, unchecked if empty
+	webhookSecret string
+	// updates are the webhook update IDs already handled, nil handles every delivery
+	updates *updateLog
+	// recentTasks caches /api/recent-tasks responses, nil queries Notion every time
+	recentTasks *responseCache
+
+	// auth verifies the mini app's initData on API requests, unchecked if nil (ALLOW_INSECURE_API)
+	auth *initDataAuth
+	// tenants resolves the Notion client of users with their own workspace, nil when single-user
+	tenants *tenant.Registry
+	// metricsToken is the bearer token scrapers send for /metrics, initData is required if empty
+	metricsToken string
+
+	startedAt time.Time         // Reported as uptime by /healthz
+	readiness *selfcheck.Prober // Dependency checks behind /readyz
+}
+
+// routes registers the mini app, API and webhook endpoints
+func (s *apiServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	// The mini app's files, with cache headers, versioned asset paths and gzip
+	fs := newStaticHandler("./web")
 
 	// For the mini app path
-	http.Handle("/notion/mini-app/", http.StripPrefix("/notion/mini-app/", fs))
+	mux.Handle("/notion/mini-app/", http.StripPrefix("/notion/mini-app/", fs))
+
+	// API endpoints, only for the mini app opened in Telegram by the authorized user, with
+	// bounded request bodies
+	maxBodyBytes := maxBodyBytesFromEnv()
+	api := func(path string, handler http.HandlerFunc) {
+		mux.HandleFunc(path, s.requireInitData(limitBody(maxBodyBytes, handler)))
+	}
+	api("/notion/mini-app/api/tasks", s.handleTasks)
+	api("/notion/mini-app/api/properties", s.handleProperties)
+	api("/notion/mini-app/api/log", handleLogs)
+	api("/notion/mini-app/api/recent-tasks", s.handleRecentTasks)
+	api("/notion/mini-app/api/search", s.handleSearch)
+	api("/notion/mini-app/api/task", s.handleGetTask)
+	api("/notion/mini-app/api/export", s.handleExport)
+	// Imports bound their uploads to maxImportBytes themselves
+	mux.HandleFunc("/notion/mini-app/api/import", s.requireInitData(s.handleImport))
+	api("/notion/mini-app/api/projects", s.handleProjects)
+	api("/notion/mini-app/api/update-task-status", s.handleUpdateTaskStatus)
+	api("/notion/mini-app/api/update-task", s.handleUpdateTask)
+	api("/notion/mini-app/api/task/update", s.handleUpdateTask)
+	api("/notion/mini-app/api/trigger-check", s.ownerOnly(s.handleTriggerCheck))
+
+	api("/notion/mini-app/api/counts", func(w http.ResponseWriter, r *http.Request) {
+		createCountsHandler(s.notionFor(r))(w, r)
+	})
+	api("/notion/mini-app/api/schema-changes", s.ownerOnly(createSchemaChangesHandler(s.db)))
+
+	// Probes for the reverse proxy, without auth
+	mux.HandleFunc("/notion/mini-app/api/healthz", s.handleHealthz)
+	mux.HandleFunc("/notion/mini-app/api/readyz", s.handleReadyz)
+
+	// Prometheus metrics, for scrapers with METRICS_TOKEN
+	mux.HandleFunc("/notion/mini-app/api/metrics", s.handleMetrics())
+
+	// Public read-only task lists created with /share, no auth by design
+	mux.Handle(share.PathPrefix, share.NewServer(s.db, s.notion))
 
-	// API endpoints
-	http.HandleFunc("/notion/mini-app/api/tasks", handleTasks)
-	http.HandleFunc("/notion/mini-app/api/properties", handleProperties)
-	http.HandleFunc("/notion/mini-app/api/log", handleLogs)
-	http.HandleFunc("/notion/mini-app/api/recent-tasks", handleRecentTasks)
-	http.HandleFunc("/notion/mini-app/api/projects", handleProjects)
-	http.HandleFunc("/notion/mini-app/api/update-task-status", handleUpdateTaskStatus)
-	http.HandleFunc("/notion/mini-app/api/trigger-check", handleTriggerCheck)
+	// Photos attached to tasks, fetched by Notion without auth
+	mux.Handle(uploads.PathPrefix, uploads.FromEnv(os.Getenv("MINI_APP_URL")).Handler())
 
 	// Telegram webhook endpoint for receiving reaction updates
-	http.HandleFunc("/telegram/webhook", createWebhookHandler())
+	mux.HandleFunc("/telegram/webhook", limitBody(maxBodyBytes, s.handleWebhook))
 
 	// Simple config endpoint that returns environment variables as JSON
-	http.HandleFunc("/notion/mini-app/api/config", handleConfig)
+	api("/notion/mini-app/api/config", s.handleConfig)
 
-	// Debug endpoints - disable in production
-	http.HandleFunc("/notion/mini-app/api/debug/task", handleDebugTask)
+	// Debug endpoints, off in production unless DEBUG_ENDPOINTS=true
+	if debugEndpointsEnabled() {
+		api("/notion/mini-app/api/debug/task", s.handleDebugTask)
+		api("/notion/mini-app/api/debug/cache", s.ownerOnly(s.handleDebugCache))
+		api("/notion/mini-app/api/debug/cache/clear", s.ownerOnly(s.handleDebugCacheClear))
+	}
 
 	// Also serve files at the root for local development
-	http.Handle("/", fs)
+	mux.Handle("/", fs)
+
+	return mux
+}
+
+// Serve static files for the mini app until ctx is done
+func (s *apiServer) serveStaticFiles(ctx context.Context) error {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	host := os.Getenv("HOST")
+	if host == "" {
+		host = "0.0.0.0"
+	}
 
 	// Start the server
 	server := &http.Server{
 		Addr:         host + ":" + port,
+		Handler:      s.routes(),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
 
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
 	log.Printf("Starting mini app server on %s", server.Addr)
 	log.Printf("Mini app available at: http://%s:%s/notion/mini-app/", host, port)
-	log.Fatal(server.ListenAndServe())
+	return serve(ctx, server, listener)
+}
+
+// shutdownTimeout bounds how long in-flight requests may take to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// serve runs the server on the listener until ctx is done, then stops accepting connections
+// and waits up to shutdownTimeout for in-flight requests
+func serve(ctx context.Context, server *http.Server, listener net.Listener) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down mini app server")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down server: %w", err)
+	}
+
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 // Handler for providing configuration to the frontend
-func handleConfig(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleConfig(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Config endpoint called from: %s", r.RemoteAddr)
 
 	// Set headers
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -223,22 +591,20 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check environment (limit what's exposed in production)
-	isProd := os.Getenv("ENVIRONMENT") == "production"
-
-	// Initialize Notion client to get database IDs
-	notionClient := notion.NewClient()
-
-	// Create config object
+	// Only feature flags and public URLs, the browser reaches Notion through the API
+	// endpoints so credentials and database IDs never leave the server
 	config := map[string]string{
 		"MINI_APP_URL": os.Getenv("MINI_APP_URL"),
+		// Deep links from confirmation cards carry "<prefix><page ID>" as start_param
+		"TASK_START_PARAM_PREFIX": bot.TaskStartParamPrefix,
 	}
 
 	// Add boolean flags for available databases
-	hasTasksDb := notionClient.GetTasksDatabaseID() != ""
-	hasNotesDb := notionClient.GetNotesDatabaseID() != ""
-	hasJournalDb := notionClient.GetJournalDatabaseID() != ""
-	hasProjectsDb := notionClient.GetProjectsDatabaseID() != ""
+	client := s.notionFor(r)
+	hasTasksDb := client.GetTasksDatabaseID() != ""
+	hasNotesDb := client.GetNotesDatabaseID() != ""
+	hasJournalDb := client.GetJournalDatabaseID() != ""
+	hasProjectsDb := client.GetProjectsDatabaseID() != ""
 
 	if hasTasksDb {
 		config["HAS_TASKS_DB"] = "true"
@@ -264,28 +630,6 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 		config["HAS_PROJECTS_DB"] = "false"
 	}
 
-	// Add sensitive info only in non-production environments
-	if !isProd {
-		notionKey := os.Getenv("NOTION_API_KEY")
-		tasksDbID := notionClient.GetTasksDatabaseID()
-		notesDbID := notionClient.GetNotesDatabaseID()
-		journalDbID := notionClient.GetJournalDatabaseID()
-		projectsDbID := notionClient.GetProjectsDatabaseID()
-
-		// Log available keys (without exposing their values)
-		log.Printf("Config: NOTION_API_KEY available: %v", notionKey != "")
-		log.Printf("Config: NOTION_TASKS_DATABASE_ID available: %v", tasksDbID != "")
-		log.Printf("Config: NOTION_NOTES_DATABASE_ID available: %v", notesDbID != "")
-		log.Printf("Config: NOTION_JOURNAL_DATABASE_ID available: %v", journalDbID != "")
-		log.Printf("Config: NOTION_PROJECTS_DATABASE_ID available: %v", projectsDbID != "")
-
-		config["NOTION_API_KEY"] = notionKey
-		config["NOTION_TASKS_DATABASE_ID"] = tasksDbID
-		config["NOTION_NOTES_DATABASE_ID"] = notesDbID
-		config["NOTION_JOURNAL_DATABASE_ID"] = journalDbID
-		config["NOTION_PROJECTS_DATABASE_ID"] = projectsDbID
-	}
-
 	// Return configuration as JSON
 	jsonData, err := json.Marshal(config)
 	if err != nil {
@@ -300,17 +644,18 @@ func handleConfig(w http.ResponseWriter, r *http.Request) {
 
 type TaskRequest struct {
 	Title      string                 `json:"title"`
+	Content    string                 `json:"content,omitempty"` // Paragraphs of the page body
 	Properties map[string]interface{} `json:"properties"`
 }
 
 // API handler for tasks
-func handleTasks(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleTasks(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Handling task request: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
 
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -342,9 +687,9 @@ func handleTasks(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the request body
 	var taskReq TaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&taskReq); err != nil {
+	if err := decodeJSON(r, &taskReq); err != nil {
 		log.Printf("Error decoding task request: %v", err)
-		sendJSONError(http.StatusBadRequest, "Invalid request body: "+err.Error())
+		sendJSONError(decodeStatus(err), decodeMessage(err))
 		return
 	}
 
@@ -355,52 +700,113 @@ func handleTasks(w http.ResponseWriter, r *http.Request) {
 		sendJSONError(http.StatusBadRequest, "Task title is required")
 		return
 	}
+	if err := validateTitle(taskReq.Title); err != nil {
+		sendJSONError(http.StatusBadRequest, "Invalid title: "+err.Error())
+		return
+	}
 
 	// Get database type from query param or default to "tasks"
-	dbType := r.URL.Query().Get("db_type")
-	if dbType == "" {
-		dbType = "tasks"
+	dbType, err := dbTypeParam(r)
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Initialize Notion client
-	notionClient := notion.NewClient()
-
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
+	ctx = optionsContext(ctx, r)
+
+	// Build the Notion request first so dry runs and verbose calls can echo it
+	client := s.notionFor(r)
+	plan, err := client.PlanCreateTask(ctx, taskReq.Title, taskReq.Properties, dbType)
+	if err != nil {
+		log.Printf("Error preparing task for Notion: %v", err)
+		// Unknown options are answered with the nearest ones, for the mini app to offer
+		var optionErr *notion.OptionError
+		if errors.As(err, &optionErr) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":      "error",
+				"message":     "Failed to prepare task: " + err.Error(),
+				"property":    optionErr.Property,
+				"value":       optionErr.Value,
+				"suggestions": optionErr.Suggestions,
+			})
+			return
+		}
+		sendJSONError(http.StatusBadRequest, "Failed to prepare task: "+err.Error())
+		return
+	}
+	plan.SetContent(taskReq.Content)
+
+	if queryFlag(r, "dry_run") {
+		log.Printf("Dry run for task in %s database: %s", dbType, taskReq.Title)
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "dry_run",
+			"message": "Task was not created",
+			"plan":    plan,
+		}); err != nil {
+			log.Printf("Error encoding dry run response: %v", err)
+		}
+		return
+	}
 
 	start := time.Now()
 	log.Printf("Creating task in %s database: %s", dbType, taskReq.Title)
 
 	// Create the task in Notion
-	taskID, err := notionClient.CreateTask(ctx, taskReq.Title, taskReq.Properties, dbType)
+	taskID, err := client.ExecuteCreatePlan(ctx, plan)
 	if err != nil {
 		log.Printf("Error creating task in Notion: %v", err)
-		sendJSONError(http.StatusInternalServerError, "Failed to create task: "+err.Error())
+		sendJSONError(http.StatusInternalServerError, "Failed to create task: "+notion.Explain(err))
 		return
 	}
 
 	elapsed := time.Since(start)
 	log.Printf("Task created successfully in %v with ID: %s", elapsed, taskID)
+	s.recentTasks.invalidate()
 
-	// Return success response
-	w.WriteHeader(http.StatusCreated)
-	if err := json.NewEncoder(w).Encode(map[string]string{
+	response := map[string]interface{}{
 		"status":  "success",
 		"message": "Task created successfully",
-	}); err != nil {
+		"id":      taskID,
+	}
+	if queryFlag(r, "verbose") {
+		response["plan"] = plan
+	}
+
+	// Return success response
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Printf("Error encoding success response: %v", err)
 	}
 }
 
+// optionsContext lets a request create select and multi-select options that don't exist
+// yet with ?allow_new_options=1, unknown values are rejected otherwise
+func optionsContext(ctx context.Context, r *http.Request) context.Context {
+	if queryFlag(r, "allow_new_options") {
+		return notion.WithNewOptions(ctx)
+	}
+	return ctx
+}
+
+// queryFlag reports whether a boolean query parameter such as ?dry_run=1 is set
+func queryFlag(r *http.Request, name string) bool {
+	value := strings.ToLower(r.URL.Query().Get(name))
+	return value == "1" || value == "true" || value == "yes"
+}
+
 // Handler for database properties API
-func handleProperties(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleProperties(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Properties API called from: %s %s", r.RemoteAddr, r.URL.Path)
 
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -433,22 +839,21 @@ func handleProperties(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get database type from query param or default to "tasks"
-	dbType := r.URL.Query().Get("db_type")
-	if dbType == "" {
-		dbType = "tasks"
+	dbType, err := dbTypeParam(r)
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error(), nil)
+		return
 	}
 
 	log.Printf("Fetching properties for database type: %s", dbType)
 
-	// Initialize Notion client
-	notionClient := notion.NewClient()
-
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	// Fetch database properties from Notion
-	properties, err := notionClient.GetDatabaseProperties(ctx, dbType)
+	client := s.notionFor(r)
+	properties, err := client.GetDatabaseProperties(ctx, dbType)
 
 	// Transform the properties to a more frontend-friendly format
 	result := make(map[string]map[string]interface{})
@@ -459,7 +864,7 @@ func handleProperties(w http.ResponseWriter, r *http.Request) {
 	var partialSuccess bool = false
 
 	if err != nil {
-		errorMessage = fmt.Sprintf("Failed to get full database properties: %v", err)
+		errorMessage = "Failed to get full database properties: " + notion.Explain(err)
 		log.Printf("Error getting database properties: %v", err)
 
 		// Check if it's a button property error
@@ -476,6 +881,7 @@ func handleProperties(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Process properties if we have them
+	schemaGuessed := client.SchemaGuessed(dbType)
 	if properties != nil {
 		for name, prop := range properties {
 			propType := prop.GetType()
@@ -495,6 +901,10 @@ func handleProperties(w http.ResponseWriter, r *http.Request) {
 			propInfo := map[string]interface{}{
 				"type": propType,
 			}
+			// Guessed from a page by the button workaround, options are unknown
+			if schemaGuessed {
+				propInfo["guessed"] = true
+			}
 
 			// Add options for select and multi_select types
 			switch propType {
@@ -545,7 +955,7 @@ func handleLogs(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -561,9 +971,9 @@ func handleLogs(w http.ResponseWriter, r *http.Request) {
 
 	// Parse the log data
 	var logData map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&logData); err != nil {
+	if err := decodeJSON(r, &logData); err != nil {
 		log.Printf("Error decoding log data: %v", err)
-		http.Error(w, "Invalid log data", http.StatusBadRequest)
+		http.Error(w, "Invalid log data", decodeStatus(err))
 		return
 	}
 
@@ -575,13 +985,13 @@ func handleLogs(w http.ResponseWriter, r *http.Request) {
 }
 
 // Debug endpoint for testing task creation
-func handleDebugTask(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleDebugTask(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Debug task endpoint called from: %s", r.RemoteAddr)
 
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -608,11 +1018,11 @@ func handleDebugTask(w http.ResponseWriter, r *http.Request) {
 		DbType     string                 `json:"db_type"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(r, &req); err != nil {
 		log.Printf("Error decoding debug task request: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(decodeStatus(err))
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid request: " + err.Error(),
+			"error": decodeMessage(err),
 		})
 		return
 	}
@@ -621,23 +1031,27 @@ func handleDebugTask(w http.ResponseWriter, r *http.Request) {
 	if req.DbType == "" {
 		req.DbType = "tasks"
 	}
+	if err := errors.Join(validateDBType(req.DbType), validateTitle(req.Title)); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Invalid request: " + err.Error(),
+		})
+		return
+	}
 
 	// Log the request
 	log.Printf("Debug task: %+v", req)
 
-	// Initialize Notion client
-	notionClient := notion.NewClient()
-
 	// Create task
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	_, err := notionClient.CreateTask(ctx, req.Title, req.Properties, req.DbType)
+	_, err := s.notionFor(r).CreateTask(ctx, req.Title, req.Properties, req.DbType)
 	if err != nil {
 		log.Printf("Error creating debug task: %v", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to create task: " + err.Error(),
+			"error": "Failed to create task: " + notion.Explain(err),
 		})
 		return
 	}
@@ -650,14 +1064,76 @@ func handleDebugTask(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// debugEndpointsEnabled reports whether the debug endpoints are served: always outside
+// production, and in production with DEBUG_ENDPOINTS=true
+func debugEndpointsEnabled() bool {
+	return os.Getenv("ENVIRONMENT") != "production" || os.Getenv("DEBUG_ENDPOINTS") == "true"
+}
+
+// Debug endpoint reporting the sizes of the bounded in-memory caches and the cached
+// database schemas with their expiry
+func (s *apiServer) handleDebugCache(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Method not allowed",
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"caches":  lru.Sizes(),
+		"schemas": s.notionFor(r).CachedSchemas(),
+	})
+}
+
+// Debug endpoint dropping the cached schema of the db_type database, or of all of them
+// without one, so the next request fetches it from Notion
+func (s *apiServer) handleDebugCacheClear(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": "Method not allowed",
+		})
+		return
+	}
+
+	client := s.notionFor(r)
+	dbType := r.URL.Query().Get("db_type")
+	switch {
+	case dbType == "":
+		client.InvalidateAllCaches()
+		log.Printf("Cleared the schema cache of every database")
+	case notion.IsDatabaseType(dbType):
+		client.InvalidateCache(dbType)
+		log.Printf("Cleared the schema cache of the %s database", dbType)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": fmt.Sprintf("Unknown db_type %q, expected tasks, notes, journal or projects", dbType),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "cleared",
+		"schemas": client.CachedSchemas(),
+	})
+}
+
 // Handler for fetching recent tasks with filtering
-func handleRecentTasks(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleRecentTasks(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Recent tasks API called from: %s", r.RemoteAddr)
 
-	// Set CORS headers
+	// Set CORS headers, letting the mini app revalidate its cached listing
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match, "+initDataHeader)
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -683,41 +1159,301 @@ func handleRecentTasks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get database type from query param or default to "tasks"
-	dbType := r.URL.Query().Get("db_type")
-	if dbType == "" {
-		dbType = "tasks"
+	dbType, err := dbTypeParam(r)
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
 	}
 
-	// Initialize Notion client
-	notionClient := notion.NewClient()
+	opts, err := parseTaskQuery(r.URL.Query())
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// ?fresh=1 skips the cache, the result replaces the cached one
+	key := recentTasksKey(apiUserID(r), dbType, r.URL.Query())
+	cached, generation, ok := s.recentTasks.get(key)
+	if ok && !queryFlag(r, "fresh") {
+		log.Printf("Serving cached recent tasks for %s", key)
+		writeCachedResponse(w, r, cached)
+		return
+	}
 
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
 	// Get the recent tasks
-	tasks, err := notionClient.GetRecentTasks(ctx, dbType, 10)
+	page, err := s.notionFor(r).GetTasksFiltered(ctx, dbType, opts)
+	if errors.Is(err, notion.ErrInvalidQuery) {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
 		log.Printf("Error getting recent tasks: %v", err)
-		sendJSONError(http.StatusInternalServerError, fmt.Sprintf("Failed to get recent tasks: %v", err))
+		sendJSONError(http.StatusInternalServerError, "Failed to get recent tasks: "+notion.Explain(err))
 		return
 	}
 
-	// Return tasks as JSON
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(tasks); err != nil {
+	// Return tasks as JSON, flagging results the button workaround may have filtered wrongly
+	response := map[string]interface{}{"tasks": page.Tasks, "next_cursor": page.NextCursor}
+	if page.Info.Degraded {
+		response["degraded"] = page.Info
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
 		log.Printf("Error encoding recent tasks: %v", err)
+		sendJSONError(http.StatusInternalServerError, "Failed to encode recent tasks")
+		return
+	}
+	fresh := newCachedResponse(append(body, '\n'))
+	s.recentTasks.store(key, generation, fresh)
+	writeCachedResponse(w, r, fresh)
+}
+
+// searchResultLimit is how many matches /api/search returns
+const searchResultLimit = 20
+
+// Handler for searching tasks by title: GET /api/search?q=<text>
+func (s *apiServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	sendJSONError := func(statusCode int, message string) {
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": message,
+		})
+	}
+
+	if r.Method != http.MethodGet {
+		sendJSONError(http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	dbType, err := dbTypeParam(r)
+	if err != nil {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	tasks, err := s.notionFor(r).SearchTasks(ctx, dbType, r.URL.Query().Get("q"), searchResultLimit)
+	if errors.Is(err, notion.ErrInvalidQuery) {
+		sendJSONError(http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error searching tasks: %v", err)
+		sendJSONError(http.StatusInternalServerError, "Failed to search tasks: "+notion.Explain(err))
+		return
+	}
+
+	if tasks == nil {
+		tasks = []notion.Task{}
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"tasks": tasks}); err != nil {
+		log.Printf("Error encoding search results: %v", err)
+	}
+}
+
+// Handler for fetching one task with its body: GET /api/task?id=<page ID>
+func (s *apiServer) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	sendJSONError := func(statusCode int, message string) {
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error": message,
+		})
+	}
+
+	if r.Method != http.MethodGet {
+		sendJSONError(http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	pageID, err := notion.ParsePageID(r.URL.Query().Get("id"))
+	if err != nil {
+		sendJSONError(http.StatusNotFound, "Task not found")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	task, err := s.notionFor(r).GetTask(ctx, pageID)
+	if notionErr, ok := notion.AsNotionError(err); ok && isMissingPage(notionErr) {
+		sendJSONError(http.StatusNotFound, "Task not found: "+notion.Explain(err))
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching task %s: %v", pageID, err)
+		sendJSONError(http.StatusInternalServerError, "Failed to fetch task: "+notion.Explain(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(task); err != nil {
+		log.Printf("Error encoding task: %v", err)
+	}
+}
+
+// isMissingPage reports whether Notion refused a page because it doesn't exist, isn't
+// shared with the integration or its ID is malformed
+func isMissingPage(err *notion.NotionError) bool {
+	switch err.Code {
+	case notion.ErrCodeObjectNotFound, notion.ErrCodeRestrictedResource, notion.ErrCodeValidation:
+		return true
+	}
+	return false
+}
+
+// parseTaskQuery reads the filters of /api/recent-tasks: limit, cursor, status, tag,
+// project and has_date
+func parseTaskQuery(query url.Values) (notion.TaskQueryOptions, error) {
+	opts := notion.TaskQueryOptions{
+		Cursor:  query.Get("cursor"),
+		Status:  query.Get("status"),
+		Tag:     query.Get("tag"),
+		Project: query.Get("project"),
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("limit must be a positive number, got %q", value)
+		}
+		opts.Limit = limit
+	}
+
+	if value := query.Get("has_date"); value != "" {
+		hasDate, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, fmt.Errorf("has_date must be true or false, got %q", value)
+		}
+		opts.HasDate = &hasDate
+	}
+	return opts, nil
+}
+
+// createCountsHandler returns the handler serving open-item counts for the tab badges.
+// It's separate from /api/config so loading the config never waits on Notion queries.
+func createCountsHandler(notionClient *notion.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Set CORS headers
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+		// Handle preflight OPTIONS request
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		counts, err := notionClient.GetCounts(ctx, time.Now())
+		if err != nil {
+			log.Printf("Error getting counts: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Failed to get counts: %v", err)})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(counts); err != nil {
+			log.Printf("Error encoding counts: %v", err)
+		}
+	}
+}
+
+// createSchemaChangesHandler returns the handler listing the latest detected schema changes
+func createSchemaChangesHandler(db *database.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Set CORS headers
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+		// Handle preflight OPTIONS request
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+			return
+		}
+
+		if db == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": "Local database not available"})
+			return
+		}
+
+		changes, err := db.GetRecentSchemaChanges(schemawatch.RecentChangesLimit)
+		if err != nil {
+			log.Printf("Error getting schema changes: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Failed to get schema changes: %v", err)})
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(changes); err != nil {
+			log.Printf("Error encoding schema changes: %v", err)
+		}
 	}
 }
 
 // API handler for updating task status
-func handleUpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleUpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Update task status API called from: %s", r.RemoteAddr)
 
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -738,9 +1474,9 @@ func handleUpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 		Properties map[string]interface{} `json:"properties"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeJSON(r, &req); err != nil {
 		log.Printf("Error decoding update task status request: %v", err)
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		http.Error(w, decodeMessage(err), decodeStatus(err))
 		return
 	}
 
@@ -750,33 +1486,158 @@ func handleUpdateTaskStatus(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize Notion client
-	notionClient := notion.NewClient()
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	ctx = optionsContext(ctx, r)
 
-	// Update task status in Notion
-	err := notionClient.UpdateTaskStatus(req.TaskID, req.Status, req.Properties)
+	// The payload depends on the type of the status property, so the schema is needed
+	client := s.notionFor(r)
+	plan, err := client.PlanUpdateTaskStatus(ctx, req.TaskID, req.Status, req.Properties)
 	if err != nil {
+		log.Printf("Error preparing task status update: %v", err)
+		status := http.StatusBadGateway
+		if errors.Is(err, notion.ErrInvalidUpdate) || errors.Is(err, notion.ErrUnknownOption) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, notion.Explain(err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if queryFlag(r, "dry_run") {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "dry_run",
+			"message": "Task status was not updated",
+			"plan":    plan,
+		})
+		return
+	}
+
+	// Update task status in Notion
+	if err := client.UpdateTaskStatus(ctx, req.TaskID, req.Status, req.Properties); err != nil {
 		log.Printf("Error updating task status: %v", err)
-		http.Error(w, "Failed to update task status", http.StatusInternalServerError)
+		http.Error(w, "Failed to update task status: "+notion.Explain(err), http.StatusInternalServerError)
 		return
 	}
+	s.recentTasks.invalidate()
+
+	response := map[string]interface{}{
+		"status":  "success",
+		"message": "Task status updated successfully",
+	}
+	if len(plan.Warnings) > 0 {
+		response["warnings"] = plan.Warnings
+	}
+	if queryFlag(r, "verbose") {
+		response["plan"] = plan
+	}
 
 	// Return success
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleUpdateTask changes the title and properties of a task. A null property value
+// clears the property; clearing the title or a type without an empty value is rejected.
+func (s *apiServer) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Update task API called from: %s", r.RemoteAddr)
+
+	// Set CORS headers
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
+
+	// Handle preflight OPTIONS request
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		TaskID     string                 `json:"task_id"`
+		Title      string                 `json:"title"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		log.Printf("Error decoding update task request: %v", err)
+		http.Error(w, decodeMessage(err), decodeStatus(err))
+		return
+	}
+
+	if req.TaskID == "" {
+		http.Error(w, "Task ID is required", http.StatusBadRequest)
+		return
+	}
+	if err := validateTitle(req.Title); err != nil {
+		http.Error(w, "Invalid title: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	ctx = optionsContext(ctx, r)
+
+	client := s.notionFor(r)
+	plan, err := client.PlanUpdateTask(ctx, req.TaskID, req.Title, req.Properties)
+	if err != nil {
+		log.Printf("Error preparing task update: %v", err)
+		status := http.StatusBadGateway
+		if errors.Is(err, notion.ErrInvalidUpdate) || errors.Is(err, notion.ErrUnknownOption) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, notion.Explain(err), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if queryFlag(r, "dry_run") {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "dry_run",
+			"message": "Task was not updated",
+			"plan":    plan,
+		})
+		return
+	}
+
+	if err := client.UpdateTask(ctx, req.TaskID, req.Title, req.Properties); err != nil {
+		log.Printf("Error updating task: %v", err)
+		http.Error(w, "Failed to update task: "+notion.Explain(err), http.StatusInternalServerError)
+		return
+	}
+	s.recentTasks.invalidate()
+
+	response := map[string]interface{}{
 		"status":  "success",
-		"message": "Task status updated successfully",
-	})
+		"message": "Task updated successfully",
+	}
+	if len(plan.Warnings) > 0 {
+		response["warnings"] = plan.Warnings
+	}
+	if queryFlag(r, "verbose") {
+		response["plan"] = plan
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
 // Handler for fetching projects
-func handleProjects(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleProjects(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Projects API called from: %s", r.RemoteAddr)
 
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -801,17 +1662,14 @@ func handleProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Initialize Notion client
-	notionClient := notion.NewClient()
-
 	// Create a context with timeout
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
 	// Get projects from Notion
-	projects, err := notionClient.GetProjects(ctx)
+	projects, err := s.notionFor(r).GetProjects(ctx)
 	if err != nil {
-		sendJSONError(http.StatusInternalServerError, fmt.Sprintf("Failed to get projects: %v", err))
+		sendJSONError(http.StatusInternalServerError, "Failed to get projects: "+notion.Explain(err))
 		return
 	}
 
@@ -823,13 +1681,13 @@ func handleProjects(w http.ResponseWriter, r *http.Request) {
 }
 
 // Handler for manually triggering the daily task check
-func handleTriggerCheck(w http.ResponseWriter, r *http.Request) {
+func (s *apiServer) handleTriggerCheck(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Manual trigger check API called from: %s", r.RemoteAddr)
 
 	// Set CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, "+initDataHeader)
 
 	// Handle preflight OPTIONS request
 	if r.Method == http.MethodOptions {
@@ -850,7 +1708,7 @@ func handleTriggerCheck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if scheduler is available
-	if globalScheduler == nil {
+	if s.scheduler == nil {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		json.NewEncoder(w).Encode(map[string]string{
 			"error": "Scheduler not available",
@@ -859,7 +1717,7 @@ func handleTriggerCheck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Trigger manual check
-	globalScheduler.RunManualCheck()
+	s.scheduler.RunManualCheck()
 
 	// Return success
 	w.WriteHeader(http.StatusOK)
@@ -869,92 +1727,95 @@ func handleTriggerCheck(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Global variable to store the bot handler for webhook
-var globalHandler *bot.Handler
-var globalBot *tgbotapi.BotAPI
-var globalScheduler *scheduler.Scheduler
+// webhookSecretHeader carries the secret_token given to setWebhook on every webhook request
+const webhookSecretHeader = "X-Telegram-Bot-Api-Secret-Token"
 
-// createWebhookHandler creates a handler for Telegram webhook updates
-func createWebhookHandler() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+// webhookAllowedUpdates are the update types handled in webhook mode
+var webhookAllowedUpdates = []string{"message", "edited_message", "message_reaction", "callback_query", "inline_query", "chosen_inline_result"}
 
-		// Parse the webhook update
-		var updateData map[string]interface{}
-		if err := json.NewDecoder(r.Body).Decode(&updateData); err != nil {
-			log.Printf("Error decoding webhook data: %v", err)
-			http.Error(w, "Bad request", http.StatusBadRequest)
-			return
-		}
+// seenUpdatesCap is how many webhook update IDs are remembered to spot redeliveries
+const seenUpdatesCap = 1000
 
-		log.Printf("Received webhook update: %+v", updateData)
+// updateLog remembers the IDs of recent webhook updates. Telegram redelivers an update
+// when the response is slow or not 200, and handling it again would store it twice.
+type updateLog struct {
+	mu  sync.Mutex
+	ids *lru.Cache[int, bool]
+}
 
-		// Handle different update types
+func newUpdateLog() *updateLog {
+	ids := lru.New[int, bool](seenUpdatesCap, 0)
+	lru.Register("webhook.update_ids", ids)
+	return &updateLog{ids: ids}
+}
 
-		// 1. Handle regular messages
-		if messageData, ok := updateData["message"]; ok {
-			log.Printf("Received message update via webhook")
+// first records an update ID, reporting false when it was recorded before
+func (l *updateLog) first(updateID int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, seen := l.ids.Get(updateID); seen {
+		return false
+	}
+	l.ids.Add(updateID, true)
+	return true
+}
 
-			// Parse the message
-			messageJSON, _ := json.Marshal(messageData)
-			var message tgbotapi.Message
-			if err := json.Unmarshal(messageJSON, &message); err == nil && globalHandler != nil {
-				if err := globalHandler.HandleMessage(&message); err != nil {
-					log.Printf("Error handling message: %v", err)
-				}
-			}
-		}
+// registerWebhook points Telegram at the webhook URL, with the secret it must send back
+func registerWebhook(botAPI *tgbotapi.BotAPI, webhookURL, secret string) error {
+	params := tgbotapi.Params{"url": webhookURL}
+	params.AddNonEmpty("secret_token", secret)
+	if err := params.AddInterface("allowed_updates", webhookAllowedUpdates); err != nil {
+		return err
+	}
 
-		// 1.5. Handle edited messages (update pending task)
-		if editedMessageData, ok := updateData["edited_message"]; ok {
-			log.Printf("Received edited message update via webhook")
-
-			// Parse the edited message
-			messageJSON, _ := json.Marshal(editedMessageData)
-			var message tgbotapi.Message
-			if err := json.Unmarshal(messageJSON, &message); err == nil && globalHandler != nil {
-				// Update the pending task with new text
-				if err := globalHandler.HandleMessage(&message); err != nil {
-					log.Printf("Error handling edited message: %v", err)
-				}
-			}
-		}
+	if _, err := botAPI.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+	return nil
+}
 
-		// 2. Handle callback queries
-		if callbackData, ok := updateData["callback_query"]; ok {
-			log.Printf("Received callback query via webhook: %+v", callbackData)
+// handleWebhook handles Telegram webhook updates
+func (s *apiServer) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Without the secret anyone who finds the URL could inject updates
+	if s.webhookSecret != "" {
+		token := r.Header.Get(webhookSecretHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.webhookSecret)) != 1 {
+			log.Printf("Warning: Rejected webhook request with invalid secret token from %s", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
 		}
+	}
 
-		// 3. Handle message reactions
-		if messageReactionData, ok := updateData["message_reaction"]; ok {
-			log.Printf("Received message_reaction update: %+v", messageReactionData)
+	// Parse the webhook update. Telegram adds fields to updates over time, which must not
+	// fail them like unknown fields of API requests do.
+	var update telegramUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		log.Printf("Error decoding webhook data: %v", err)
+		http.Error(w, "Bad request", decodeStatus(err))
+		return
+	}
 
-			// Parse the reaction update
-			reactionJSON, err := json.Marshal(messageReactionData)
-			if err != nil {
-				log.Printf("Error marshaling reaction data: %v", err)
-				w.WriteHeader(http.StatusOK)
-				return
-			}
+	log.Printf("Received webhook update %d", update.UpdateID)
 
-			var reaction bot.MessageReactionUpdate
-			if err := json.Unmarshal(reactionJSON, &reaction); err != nil {
-				log.Printf("Error unmarshaling reaction: %v", err)
-				w.WriteHeader(http.StatusOK)
-				return
-			}
+	// A redelivered update was handled when it first arrived
+	if update.UpdateID != 0 && s.updates != nil && !s.updates.first(update.UpdateID) {
+		log.Printf("Ignoring redelivered webhook update %d", update.UpdateID)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-			// Handle the reaction
-			if globalHandler != nil {
-				if err := globalHandler.HandleMessageReaction(&reaction); err != nil {
-					log.Printf("Error handling reaction: %v", err)
-				}
-			}
-		}
+	// The update is handled past the response, so the span context must outlive the request
+	ctx, span := tracing.Start(context.WithoutCancel(r.Context()), "telegram.webhook")
+	defer span.End()
 
-		w.WriteHeader(http.StatusOK)
+	if s.handler != nil {
+		dispatchUpdate(ctx, s.handler, &update)
 	}
+
+	w.WriteHeader(http.StatusOK)
 }
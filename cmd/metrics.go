@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
+)
+
+// handleMetrics serves the Prometheus metrics. Scrapers authenticate with METRICS_TOKEN as
+// a bearer token; without a token the endpoint needs initData like the rest of the API.
+func (s *apiServer) handleMetrics() http.HandlerFunc {
+	serve := metrics.Handler().ServeHTTP
+	if s.metricsToken == "" {
+		return s.requireInitData(serve)
+	}
+
+	want := []byte("Bearer " + s.metricsToken)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			log.Printf("Rejected metrics scrape from %s: invalid token", r.RemoteAddr)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "unauthorized"})
+			return
+		}
+		serve(w, r)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRequireToken(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.auth = newTestAuth(42)
+	server.metricsToken = "scrape-secret"
+	mux := server.routes()
+
+	// Creating a task is counted
+	create := httptest.NewRecorder()
+	mux.ServeHTTP(create, withInitData(httptest.NewRequest(http.MethodPost, "/notion/mini-app/api/tasks", strings.NewReader(`{"title": "Buy milk"}`))))
+	if create.Code != http.StatusCreated {
+		t.Fatalf("Expected the task to be created, got %d: %s", create.Code, create.Body)
+	}
+
+	scrape := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, authorization := range []string{"", "Bearer wrong", "scrape-secret"} {
+		if rec := scrape(authorization); rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %q, got %d", authorization, rec.Code)
+		}
+	}
+
+	rec := scrape("Bearer scrape-secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200 with the token, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), `task_create_total{db_type="tasks",result="ok"}`) {
+		t.Errorf("Expected the created task to be counted:\n%s", rec.Body)
+	}
+
+	// Caches report their sizes as they do on /api/debug/cache
+	newResponseCache(time.Minute)
+	want := fmt.Sprintf(`lru_cache_capacity{cache="api.recent_tasks"} %d`, recentTasksCacheCap)
+	if body := scrape("Bearer scrape-secret").Body.String(); !strings.Contains(body, want) {
+		t.Errorf("Expected %q in the metrics:\n%s", want, body)
+	}
+}
+
+func TestMetricsWithoutTokenNeedInitData(t *testing.T) {
+	server, _ := newTestServer(t)
+	server.auth = newTestAuth(42)
+	mux := server.routes()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/metrics", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without initData, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, withInitData(httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/metrics", nil)))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected 200 with initData, got %d", rec.Code)
+	}
+}
+
+// withInitData signs a request as the mini app of user 42
+func withInitData(req *http.Request) *http.Request {
+	req.Header.Set(initDataHeader, validInitData)
+	return req
+}
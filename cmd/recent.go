@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+)
+
+const (
+	// defaultRecentTasksCacheTTL is how long /api/recent-tasks responses are reused without
+	// RECENT_TASKS_CACHE_TTL
+	defaultRecentTasksCacheTTL = 60 * time.Second
+	// recentTasksCacheCap bounds how many filter combinations are cached
+	recentTasksCacheCap = 100
+)
+
+// cachedResponse is a response body with its ETag
+type cachedResponse struct {
+	body []byte
+	etag string
+}
+
+// newCachedResponse computes the ETag of a body from its hash
+func newCachedResponse(body []byte) cachedResponse {
+	sum := sha256.Sum256(body)
+	return cachedResponse{body: body, etag: `"` + hex.EncodeToString(sum[:16]) + `"`}
+}
+
+// responseCache keeps /api/recent-tasks responses by database type and filters, so
+// opening the mini app doesn't wait on a Notion query every time. Tasks created or
+// updated through the API clear it; changes made elsewhere show up after the TTL.
+type responseCache struct {
+	mu         sync.Mutex
+	generation int // Bumped by invalidate, responses of queries started before aren't stored
+	entries    *lru.Cache[string, cachedResponse]
+}
+
+// newResponseCache creates a cache whose entries expire after ttl
+func newResponseCache(ttl time.Duration) *responseCache {
+	entries := lru.New[string, cachedResponse](recentTasksCacheCap, ttl)
+	lru.Register("api.recent_tasks", entries)
+	return &responseCache{entries: entries}
+}
+
+// recentTasksCacheFromEnv creates the cache with RECENT_TASKS_CACHE_TTL, a duration like
+// "60s". Zero disables caching, which returns nil.
+func recentTasksCacheFromEnv() *responseCache {
+	ttl := defaultRecentTasksCacheTTL
+	if value := os.Getenv("RECENT_TASKS_CACHE_TTL"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 {
+			log.Printf("Warning: Invalid RECENT_TASKS_CACHE_TTL %q, using %v", value, ttl)
+		} else {
+			ttl = parsed
+		}
+	}
+	if ttl == 0 {
+		log.Printf("Recent tasks cache disabled")
+		return nil
+	}
+	return newResponseCache(ttl)
+}
+
+// recentTasksKey identifies a listing by its user, database type and filters, ignoring
+// fresh. Users with their own workspace list different tasks.
+func recentTasksKey(userID int64, dbType string, query url.Values) string {
+	params := url.Values{}
+	for name, values := range query {
+		if name != "fresh" {
+			params[name] = values
+		}
+	}
+	params.Set("db_type", dbType)
+	params.Set("user_id", strconv.FormatInt(userID, 10))
+	return params.Encode()
+}
+
+// get returns the cached response for key, along with the generation to store a fresh
+// one under. A nil cache caches nothing.
+func (c *responseCache) get(key string) (cachedResponse, int, bool) {
+	if c == nil {
+		return cachedResponse{}, 0, false
+	}
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	response, ok := c.entries.Get(key)
+	return response, generation, ok
+}
+
+// store caches a response unless the cache was invalidated since generation was read, the
+// query may have run before the change
+func (c *responseCache) store(key string, generation int, response cachedResponse) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if generation == c.generation {
+		c.entries.Add(key, response)
+	}
+}
+
+// invalidate drops every cached response, after the API changed a task
+func (c *responseCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	c.entries.Purge()
+}
+
+// etagMatches reports whether an If-None-Match header lists the ETag, weak or not
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCachedResponse sends a JSON body with its ETag, or 304 when the client has it.
+// no-cache makes browsers revalidate every time instead of showing a stale list.
+func writeCachedResponse(w http.ResponseWriter, r *http.Request, response cachedResponse) {
+	w.Header().Set("ETag", response.etag)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), response.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(response.body); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// listingNotion answers task queries with a page whose title counts the queries, so
+// every live response differs from the last
+type listingNotion struct {
+	mu      sync.Mutex
+	queries int
+}
+
+func (f *listingNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	response := `{"object": "page", "id": "page-42", "properties": {}}`
+	switch {
+	case req.Method == http.MethodGet:
+		response = `{"object": "database", "id": "tasks-db", "properties": {"Name": {"id": "title", "type": "title", "title": {}}}}`
+	case strings.HasSuffix(req.URL.Path, "/query"):
+		f.mu.Lock()
+		f.queries++
+		title := fmt.Sprintf("Query %d", f.queries)
+		f.mu.Unlock()
+		response = `{"object": "list", "has_more": false, "results": [{"object": "page", "id": "task-1", "properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "` + title + `"}, "plain_text": "` + title + `"}]}
+		}}]}`
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+func (f *listingNotion) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries
+}
+
+// newCachingTestServer creates a server caching recent tasks, served by the fake
+func newCachingTestServer(t *testing.T) (http.Handler, *listingNotion) {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+
+	fake := &listingNotion{}
+	server := &apiServer{
+		notion:      notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+		recentTasks: newResponseCache(time.Minute),
+	}
+	return server.routes(), fake
+}
+
+// getRecentTasks requests the listing with the query and If-None-Match header
+func getRecentTasks(routes http.Handler, query, ifNoneMatch string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/notion/mini-app/api/recent-tasks?"+query, nil)
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRecentTasksAreCached(t *testing.T) {
+	routes, fake := newCachingTestServer(t)
+
+	first := getRecentTasks(routes, "limit=5", "")
+	second := getRecentTasks(routes, "limit=5", "")
+	if first.Code != http.StatusOK || second.Code != http.StatusOK {
+		t.Fatalf("Expected 200s, got %d and %d", first.Code, second.Code)
+	}
+	if fake.count() != 1 {
+		t.Errorf("Expected the second request to be served from the cache, got %d queries", fake.count())
+	}
+	if first.Body.String() != second.Body.String() || first.Header().Get("ETag") != second.Header().Get("ETag") {
+		t.Errorf("Expected the cached response, got %s and %s", first.Body, second.Body)
+	}
+
+	// Other filters are another listing
+	getRecentTasks(routes, "limit=6", "")
+	if fake.count() != 2 {
+		t.Errorf("Expected a query for other filters, got %d queries", fake.count())
+	}
+
+	fresh := getRecentTasks(routes, "limit=5&fresh=1", "")
+	if fake.count() != 3 || !strings.Contains(fresh.Body.String(), "Query 3") {
+		t.Errorf("Expected fresh=1 to query Notion, got %d queries: %s", fake.count(), fresh.Body)
+	}
+	if cached := getRecentTasks(routes, "limit=5", ""); cached.Body.String() != fresh.Body.String() {
+		t.Errorf("Expected the fresh response to replace the cached one, got %s", cached.Body)
+	}
+}
+
+func TestCreatingTaskInvalidatesRecentTasks(t *testing.T) {
+	routes, fake := newCachingTestServer(t)
+
+	before := getRecentTasks(routes, "", "")
+
+	req := httptest.NewRequest(http.MethodPost, "/notion/mini-app/api/tasks", strings.NewReader(`{"title": "Buy milk"}`))
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body)
+	}
+
+	after := getRecentTasks(routes, "", before.Header().Get("ETag"))
+	if fake.count() != 2 {
+		t.Errorf("Expected the listing to be queried again after the create, got %d queries", fake.count())
+	}
+	if after.Code != http.StatusOK || after.Header().Get("ETag") == before.Header().Get("ETag") {
+		t.Errorf("Expected a new listing with a new ETag, got %d %s", after.Code, after.Header().Get("ETag"))
+	}
+}
+
+func TestRecentTasksNotModified(t *testing.T) {
+	routes, _ := newCachingTestServer(t)
+
+	first := getRecentTasks(routes, "", "")
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+
+	for _, header := range []string{etag, "W/" + etag, `"other", ` + etag} {
+		rec := getRecentTasks(routes, "", header)
+		if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: expected an empty 304, got %d: %s", header, rec.Code, rec.Body)
+		}
+		if rec.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: expected the ETag on the 304, got %q", header, rec.Header().Get("ETag"))
+		}
+	}
+
+	if rec := getRecentTasks(routes, "", `"stale"`); rec.Code != http.StatusOK || rec.Body.String() != first.Body.String() {
+		t.Errorf("Expected the listing for another ETag, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRecentTasksPreflightAllowsRevalidation(t *testing.T) {
+	routes, _ := newCachingTestServer(t)
+
+	req := httptest.NewRequest(http.MethodOptions, "/notion/mini-app/api/recent-tasks", nil)
+	rec := httptest.NewRecorder()
+	routes.ServeHTTP(rec, req)
+	if !strings.Contains(rec.Header().Get("Access-Control-Allow-Headers"), "If-None-Match") {
+		t.Errorf("Expected If-None-Match to be allowed, got %q", rec.Header().Get("Access-Control-Allow-Headers"))
+	}
+	if rec.Header().Get("Access-Control-Expose-Headers") != "ETag" {
+		t.Errorf("Expected the ETag to be exposed, got %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// assetsPrefix starts the versioned paths index.html links its assets with
+	assetsPrefix = "assets/"
+	// immutableCacheControl is sent for versioned assets, whose content never changes
+	immutableCacheControl = "public, max-age=31536000, immutable"
+	// revalidateCacheControl is sent for index.html and unversioned files, so deploys show up
+	// on the next open
+	revalidateCacheControl = "no-cache"
+)
+
+// staticFile is a file of the mini app, loaded at startup with its compressed form
+type staticFile struct {
+	body        []byte
+	gzipped     []byte // nil for types that don't compress, like images
+	contentType string
+	etag        string
+	modTime     time.Time
+}
+
+// staticHandler serves the mini app's files. index.html links the other files under
+// assets/<version>/, which are cached for good, while index.html itself is revalidated on
+// every open. Text files are gzipped for clients that accept it.
+type staticHandler struct {
+	version string
+	files   map[string]*staticFile // By path relative to the directory, like "app.js"
+}
+
+// newStaticHandler loads the files of dir. The version is BUILD_VERSION, or a hash of the
+// file contents, so a deploy changing any file changes the asset paths. Files changed
+// after startup are only served after a restart.
+func newStaticHandler(dir string) *staticHandler {
+	h := &staticHandler{files: make(map[string]*staticFile)}
+	contents := make(map[string][]byte)
+	modTimes := make(map[string]time.Time)
+	err := filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		contents[name], modTimes[name] = body, info.ModTime()
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: Could not load the mini app from %s: %v", dir, err)
+	}
+
+	h.version = strings.TrimSpace(os.Getenv("BUILD_VERSION"))
+	if h.version == "" {
+		h.version = contentVersion(contents)
+	}
+
+	for name, body := range contents {
+		if name == "index.html" {
+			body = h.versionAssetLinks(body, contents)
+		}
+		h.files[name] = newStaticFile(name, body, modTimes[name])
+	}
+	log.Printf("Serving %d mini app file(s) from %s, assets version %s", len(h.files), dir, h.version)
+	return h
+}
+
+// contentVersion hashes the files in name order, shortened for paths
+func contentVersion(contents map[string][]byte) string {
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(hash, "%s\x00%d\x00", name, len(contents[name]))
+		hash.Write(contents[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:12]
+}
+
+// versionAssetLinks points the src and href attributes of index.html naming one of the
+// files at its versioned path. External URLs are left alone.
+func (h *staticHandler) versionAssetLinks(index []byte, contents map[string][]byte) []byte {
+	for name := range contents {
+		if name == "index.html" {
+			continue
+		}
+		versioned := assetsPrefix + h.version + "/" + name
+		for _, attr := range []string{"src", "href"} {
+			index = bytes.ReplaceAll(index, []byte(attr+`="`+name+`"`), []byte(attr+`="`+versioned+`"`))
+		}
+	}
+	return index
+}
+
+// newStaticFile prepares a file for serving, compressing text types
+func newStaticFile(name string, body []byte, modTime time.Time) *staticFile {
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	sum := sha256.Sum256(body)
+	file := &staticFile{
+		body:        body,
+		contentType: contentType,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		modTime:     modTime,
+	}
+
+	if !compressible(contentType) {
+		return file
+	}
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gz.Write(body)
+	if err := gz.Close(); err != nil {
+		log.Printf("Warning: Could not compress %s: %v", name, err)
+		return file
+	}
+	file.gzipped = buf.Bytes()
+	return file
+}
+
+// compressible reports whether a content type is text that gzip shrinks
+func compressible(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case mediaType == "application/javascript", mediaType == "application/json", mediaType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	cacheControl := revalidateCacheControl
+	if rest, ok := strings.CutPrefix(name, assetsPrefix); ok {
+		version, file, found := strings.Cut(rest, "/")
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		// Pages cached before a deploy ask for the old version, they get the current file
+		// without it being cached for good under the old path
+		if version == h.version {
+			cacheControl = immutableCacheControl
+		}
+		name = file
+	}
+	if name == "" {
+		name = "index.html"
+	}
+
+	file, ok := h.files[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	header := w.Header()
+	header.Set("Cache-Control", cacheControl)
+	header.Set("Content-Type", file.contentType)
+	body, etag := file.body, file.etag
+	if file.gzipped != nil {
+		header.Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			header.Set("Content-Encoding", "gzip")
+			body, etag = file.gzipped, strings.TrimSuffix(file.etag, `"`)+`-gzip"`
+		}
+	}
+	header.Set("ETag", etag)
+	http.ServeContent(w, r, name, file.modTime, bytes.NewReader(body))
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of a request allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		// gzip;q=0 refuses it
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
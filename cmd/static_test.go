@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestWebDir writes a small mini app: an index linking a script and a stylesheet, and an image
+func newTestWebDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html": `<html><head><link rel="stylesheet" href="styles.css"><script src="https://telegram.org/js/telegram-web-app.js"></script></head>` +
+			`<body><script src="app.js"></script></body></html>`,
+		"app.js":     strings.Repeat("console.log('mini app');\n", 200),
+		"styles.css": strings.Repeat("body { margin: 0; }\n", 200),
+		"logo.png":   "\x89PNG\r\n\x1a\nnot really an image",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Writing %s failed: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func serveStatic(h http.Handler, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestStaticCacheHeaders(t *testing.T) {
+	h := newStaticHandler(newTestWebDir(t))
+	versioned := "/" + assetsPrefix + h.version + "/"
+
+	tests := []struct {
+		name         string
+		path         string
+		status       int
+		cacheControl string
+	}{
+		{"index", "/", http.StatusOK, revalidateCacheControl},
+		{"index by name", "/index.html", http.StatusOK, revalidateCacheControl},
+		{"versioned script", versioned + "app.js", http.StatusOK, immutableCacheControl},
+		{"versioned image", versioned + "logo.png", http.StatusOK, immutableCacheControl},
+		{"old version", "/" + assetsPrefix + "0123456789ab/app.js", http.StatusOK, revalidateCacheControl},
+		{"unversioned", "/app.js", http.StatusOK, revalidateCacheControl},
+		{"unknown", versioned + "missing.js", http.StatusNotFound, ""},
+		{"escaping", "/../main.go", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveStatic(h, tt.path, nil)
+			if rec.Code != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); tt.cacheControl != "" && got != tt.cacheControl {
+				t.Errorf("Expected Cache-Control %q, got %q", tt.cacheControl, got)
+			}
+		})
+	}
+
+	index := serveStatic(h, "/", nil).Body.String()
+	for _, link := range []string{`href="` + versioned[1:] + `styles.css"`, `src="` + versioned[1:] + `app.js"`, `src="https://telegram.org/js/telegram-web-app.js"`} {
+		if !strings.Contains(index, link) {
+			t.Errorf("Expected index.html to contain %s, got %s", link, index)
+		}
+	}
+}
+
+func TestStaticGzip(t *testing.T) {
+	h := newStaticHandler(newTestWebDir(t))
+	gzipHeader := http.Header{"Accept-Encoding": {"br, gzip;q=0.8"}}
+
+	for _, name := range []string{"index.html", "app.js", "styles.css"} {
+		plain := serveStatic(h, "/"+name, nil)
+		compressed := serveStatic(h, "/"+name, gzipHeader)
+		if plain.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: Expected no encoding without Accept-Encoding", name)
+		}
+		if compressed.Header().Get("Content-Encoding") != "gzip" || compressed.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: Expected a gzipped response varying on Accept-Encoding, got %v", name, compressed.Header())
+		}
+		if compressed.Body.Len() >= plain.Body.Len() {
+			t.Errorf("%s: Expected the gzipped body to be smaller, %d >= %d bytes", name, compressed.Body.Len(), plain.Body.Len())
+		}
+		if plain.Header().Get("ETag") == compressed.Header().Get("ETag") {
+			t.Errorf("%s: Expected different ETags per encoding", name)
+		}
+
+		gz, err := gzip.NewReader(compressed.Body)
+		if err != nil {
+			t.Fatalf("%s: Reading gzip failed: %v", name, err)
+		}
+		unzipped, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("%s: Reading gzip failed: %v", name, err)
+		}
+		if !bytes.Equal(unzipped, plain.Body.Bytes()) {
+			t.Errorf("%s: Expected the gunzipped body to match the plain one", name)
+		}
+	}
+
+	if rec := serveStatic(h, "/app.js", http.Header{"Accept-Encoding": {"gzip;q=0"}}); rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("Expected gzip;q=0 to refuse gzip")
+	}
+	if rec := serveStatic(h, "/logo.png", gzipHeader); rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("Expected images to be served as they are, got %v", rec.Header())
+	}
+}
+
+func TestStaticVersion(t *testing.T) {
+	dir := newTestWebDir(t)
+	first := newStaticHandler(dir).version
+	if again := newStaticHandler(dir).version; again != first {
+		t.Errorf("Expected the same files to give the same version, got %s and %s", first, again)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log('new release');"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if changed := newStaticHandler(dir).version; changed == first {
+		t.Errorf("Expected a changed file to change the version %s", first)
+	}
+
+	t.Setenv("BUILD_VERSION", "1.4.0")
+	h := newStaticHandler(dir)
+	if h.version != "1.4.0" {
+		t.Errorf("Expected BUILD_VERSION as the version, got %s", h.version)
+	}
+	if rec := serveStatic(h, "/assets/1.4.0/app.js", nil); rec.Header().Get("Cache-Control") != immutableCacheControl {
+		t.Errorf("Expected the BUILD_VERSION path to be immutable, got %v", rec.Header())
+	}
+}
+
+func TestStaticRevalidation(t *testing.T) {
+	h := newStaticHandler(newTestWebDir(t))
+	etag := serveStatic(h, "/", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag for index.html")
+	}
+	if rec := serveStatic(h, "/", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for a matching ETag, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/app.js", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", rec.Code)
+	}
+}
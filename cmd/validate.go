@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// defaultMaxBodyBytes bounds API request bodies without MAX_BODY_BYTES
+	defaultMaxBodyBytes = 64 << 10
+	// maxTitleLength is Notion's limit for one rich text object, longer titles are refused
+	// by Notion with an unhelpful validation error
+	maxTitleLength = 2000
+)
+
+// maxBodyBytesFromEnv returns the body limit set by MAX_BODY_BYTES, or the default
+func maxBodyBytesFromEnv() int64 {
+	value := os.Getenv("MAX_BODY_BYTES")
+	if value == "" {
+		return defaultMaxBodyBytes
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		log.Printf("Warning: Invalid MAX_BODY_BYTES %q, using %d", value, defaultMaxBodyBytes)
+		return defaultMaxBodyBytes
+	}
+	return limit
+}
+
+// limitBody makes reading more than limit bytes of a request body fail, see decodeJSON
+func limitBody(limit int64, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next(w, r)
+	}
+}
+
+// decodeJSON decodes a request body into v, rejecting fields v doesn't have
+func decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// decodeStatus is the status answering a decodeJSON error: 413 for a body over the limit,
+// 400 otherwise
+func decodeStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// decodeMessage describes a decodeJSON error for the response
+func decodeMessage(err error) string {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit)
+	}
+	return "Invalid request body: " + err.Error()
+}
+
+// dbTypeParam returns the db_type query parameter, tasks when it's missing. Unknown types
+// are an error rather than falling back to the tasks database.
+func dbTypeParam(r *http.Request) (string, error) {
+	dbType := r.URL.Query().Get("db_type")
+	if dbType == "" {
+		return "tasks", nil
+	}
+	return dbType, validateDBType(dbType)
+}
+
+// validateDBType rejects database types the client doesn't know
+func validateDBType(dbType string) error {
+	if !notion.IsDatabaseType(dbType) {
+		return fmt.Errorf("unknown db_type %q, expected tasks, notes, journal or projects", dbType)
+	}
+	return nil
+}
+
+// validateTitle rejects titles longer than Notion accepts
+func validateTitle(title string) error {
+	if length := utf8.RuneCountInString(title); length > maxTitleLength {
+		return fmt.Errorf("title is %d characters long, Notion allows at most %d", length, maxTitleLength)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/bot"
+)
+
+// updateHandler handles the Telegram update types the bot receives, implemented by
+// bot.Handler
+type updateHandler interface {
+	HandleMessage(message *tgbotapi.Message) error
+	HandleEditedMessage(message *tgbotapi.Message) error
+	HandleCallbackQuery(query *tgbotapi.CallbackQuery) error
+	HandleInlineQuery(query *tgbotapi.InlineQuery) error
+	HandleChosenInlineResult(result *tgbotapi.ChosenInlineResult) error
+	HandleMessageReaction(ctx context.Context, reaction *bot.MessageReactionUpdate) error
+}
+
+// telegramUpdate is a Telegram update with the reaction updates tgbotapi v5 doesn't decode
+type telegramUpdate struct {
+	tgbotapi.Update
+	MessageReaction      *bot.MessageReactionUpdate `json:"message_reaction,omitempty"`
+	MessageReactionCount *messageReactionCount      `json:"message_reaction_count,omitempty"`
+}
+
+// messageReactionCount is the anonymous reaction count of a channel post, only sent when
+// asked for in allowed_updates
+type messageReactionCount struct {
+	Chat      bot.ChatInfo    `json:"chat"`
+	MessageID int             `json:"message_id"`
+	Date      int             `json:"date"`
+	Reactions []reactionCount `json:"reactions"`
+}
+
+type reactionCount struct {
+	Type       bot.ReactionType `json:"type"`
+	TotalCount int              `json:"total_count"`
+}
+
+// dispatchUpdate passes an update to the handler method of its type. The reaction save is
+// traced as a child of any span in ctx.
+func dispatchUpdate(ctx context.Context, handler updateHandler, update *telegramUpdate) {
+	switch {
+	case update.Message != nil:
+		if err := handler.HandleMessage(update.Message); err != nil {
+			log.Printf("Error handling message: %v", err)
+		}
+	case update.EditedMessage != nil:
+		if err := handler.HandleEditedMessage(update.EditedMessage); err != nil {
+			log.Printf("Error handling edited message: %v", err)
+		}
+	case update.CallbackQuery != nil:
+		log.Printf("Received callback query: %s", update.CallbackQuery.Data)
+		if err := handler.HandleCallbackQuery(update.CallbackQuery); err != nil {
+			log.Printf("Error handling callback query: %v", err)
+		}
+	case update.InlineQuery != nil:
+		if err := handler.HandleInlineQuery(update.InlineQuery); err != nil {
+			log.Printf("Error handling inline query: %v", err)
+		}
+	case update.ChosenInlineResult != nil:
+		if err := handler.HandleChosenInlineResult(update.ChosenInlineResult); err != nil {
+			log.Printf("Error handling chosen inline result: %v", err)
+		}
+	case update.MessageReaction != nil:
+		if err := handler.HandleMessageReaction(ctx, update.MessageReaction); err != nil {
+			log.Printf("Error handling reaction: %v", err)
+		}
+	case update.MessageReactionCount != nil:
+		// Anonymous counts can't be tied to a user, so they never save a task
+		log.Printf("Ignoring reaction count of message %d", update.MessageReactionCount.MessageID)
+	default:
+		log.Printf("Ignoring unhandled update %d", update.UpdateID)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/bot"
+)
+
+// recordingHandler records the handler methods called and their update
+type recordingHandler struct {
+	calls []string
+	last  interface{}
+}
+
+func (h *recordingHandler) record(method string, update interface{}) error {
+	h.calls = append(h.calls, method)
+	h.last = update
+	return nil
+}
+
+func (h *recordingHandler) HandleMessage(message *tgbotapi.Message) error {
+	return h.record("HandleMessage", message)
+}
+
+func (h *recordingHandler) HandleEditedMessage(message *tgbotapi.Message) error {
+	return h.record("HandleEditedMessage", message)
+}
+
+func (h *recordingHandler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
+	return h.record("HandleCallbackQuery", query)
+}
+
+func (h *recordingHandler) HandleInlineQuery(query *tgbotapi.InlineQuery) error {
+	return h.record("HandleInlineQuery", query)
+}
+
+func (h *recordingHandler) HandleChosenInlineResult(result *tgbotapi.ChosenInlineResult) error {
+	return h.record("HandleChosenInlineResult", result)
+}
+
+func (h *recordingHandler) HandleMessageReaction(ctx context.Context, reaction *bot.MessageReactionUpdate) error {
+	return h.record("HandleMessageReaction", reaction)
+}
+
+func TestWebhookDispatchesTypedUpdates(t *testing.T) {
+	const (
+		from = `"from": {"id": 456, "is_bot": false, "first_name": "Ann"}`
+		chat = `"chat": {"id": 789, "type": "private"}`
+	)
+	tests := []struct {
+		name    string
+		payload string
+		method  string
+		check   func(update interface{}) bool
+	}{
+		{
+			"message",
+			`{"update_id": 1, "message": {"message_id": 55, "date": 1700000000, ` + from + `, ` + chat + `, "text": "Buy milk"}}`,
+			"HandleMessage",
+			func(update interface{}) bool {
+				message, ok := update.(*tgbotapi.Message)
+				return ok && message.Text == "Buy milk" && message.From.ID == 456
+			},
+		},
+		{
+			"edited_message",
+			`{"update_id": 2, "edited_message": {"message_id": 55, "date": 1700000000, "edit_date": 1700000060, ` + from + `, ` + chat + `, "text": "Buy oat milk"}}`,
+			"HandleEditedMessage",
+			func(update interface{}) bool {
+				message, ok := update.(*tgbotapi.Message)
+				return ok && message.Text == "Buy oat milk" && message.EditDate == 1700000060
+			},
+		},
+		{
+			"callback_query",
+			`{"update_id": 3, "callback_query": {"id": "cb-1", ` + from + `, "chat_instance": "ci", "data": "confirm:55",
+				"message": {"message_id": 56, "date": 1700000000, ` + chat + `, "text": "Save?"}}}`,
+			"HandleCallbackQuery",
+			func(update interface{}) bool {
+				query, ok := update.(*tgbotapi.CallbackQuery)
+				return ok && query.Data == "confirm:55" && query.Message.MessageID == 56
+			},
+		},
+		{
+			"message_reaction",
+			`{"update_id": 4, "message_reaction": {"chat": {"id": 789, "type": "private"}, "message_id": 55,
+				"user": {"id": 456, "is_bot": false, "first_name": "Ann"}, "date": 1700000000,
+				"old_reaction": [], "new_reaction": [{"type": "emoji", "emoji": "👍"}]}}`,
+			"HandleMessageReaction",
+			func(update interface{}) bool {
+				reaction, ok := update.(*bot.MessageReactionUpdate)
+				return ok && reaction.MessageID == 55 && reaction.User.ID == 456 &&
+					len(reaction.NewReaction) == 1 && reaction.NewReaction[0].Emoji == "👍"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{}
+			server := &apiServer{handler: handler}
+
+			rec := httptest.NewRecorder()
+			server.handleWebhook(rec, httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(tt.payload)))
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected 200, got %d", rec.Code)
+			}
+			if len(handler.calls) != 1 || handler.calls[0] != tt.method {
+				t.Fatalf("Expected a single %s call, got %v", tt.method, handler.calls)
+			}
+			if !tt.check(handler.last) {
+				t.Errorf("Unexpected update passed to %s: %+v", tt.method, handler.last)
+			}
+		})
+	}
+}
+
+func TestWebhookIgnoresReactionCounts(t *testing.T) {
+	handler := &recordingHandler{}
+	server := &apiServer{handler: handler}
+
+	payload := `{"update_id": 5, "message_reaction_count": {"chat": {"id": -1001234567890, "type": "channel"},
+		"message_id": 55, "date": 1700000000, "reactions": [{"type": {"type": "emoji", "emoji": "👍"}, "total_count": 3}]}}`
+	rec := httptest.NewRecorder()
+	server.handleWebhook(rec, httptest.NewRequest(http.MethodPost, "/telegram/webhook", strings.NewReader(payload)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	if len(handler.calls) != 0 {
+		t.Errorf("Expected no handler call for an anonymous count, got %v", handler.calls)
+	}
+}
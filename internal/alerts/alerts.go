@@ -0,0 +1,36 @@
+// Package alerts notifies the bot owner about problems that need attention
+package alerts
+
+import (
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Alerter delivers an alert to the bot owner
+type Alerter interface {
+	Alert(text string) error
+}
+
+// TelegramAlerter sends alerts as Telegram messages to a single chat
+type TelegramAlerter struct {
+	bot    *tgbotapi.BotAPI
+	chatID int64
+}
+
+// NewTelegramAlerter creates an alerter messaging chatID, usually the authorized user
+func NewTelegramAlerter(bot *tgbotapi.BotAPI, chatID int64) *TelegramAlerter {
+	return &TelegramAlerter{bot: bot, chatID: chatID}
+}
+
+// Alert sends the text prefixed with a warning sign
+func (a *TelegramAlerter) Alert(text string) error {
+	msg := tgbotapi.NewMessage(a.chatID, "⚠️ "+text)
+	if _, err := a.bot.Send(msg); err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+
+	log.Printf("Sent alert: %s", text)
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package auth decides who may use the bot, from AUTHORIZED_USER_ID, and who gets its
+// scheduled messages
+package auth
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Users are the Telegram users allowed to use the bot and the one its checks, reviews and
+// alerts go to. Without allowed users everyone may use the bot.
+type Users struct {
+	allowed []int64
+	notify  int64
+}
+
+// NewUsers allows the given users, notifying the first
+func NewUsers(allowed ...int64) *Users {
+	u := &Users{allowed: allowed}
+	if len(allowed) > 0 {
+		u.notify = allowed[0]
+	}
+	return u
+}
+
+// FromEnv parses AUTHORIZED_USER_ID and NOTIFY_USER_ID, see Parse
+func FromEnv() (*Users, error) {
+	return Parse(os.Getenv("AUTHORIZED_USER_ID"), os.Getenv("NOTIFY_USER_ID"))
+}
+
+// Parse reads a comma-separated list of allowed user IDs, like "123, 456", and the user
+// notified, the first allowed one when notify is empty. An empty list allows everyone.
+func Parse(allowed, notify string) (*Users, error) {
+	ids, err := ParseUserIDs(allowed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid AUTHORIZED_USER_ID: %w", err)
+	}
+	u := NewUsers(ids...)
+
+	if notify = strings.TrimSpace(notify); notify != "" {
+		id, err := parseUserID(notify)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NOTIFY_USER_ID: %w", err)
+		}
+		if !u.Allows(id) {
+			return nil, fmt.Errorf("NOTIFY_USER_ID %d isn't one of the AUTHORIZED_USER_ID users", id)
+		}
+		u.notify = id
+	}
+	return u, nil
+}
+
+// ParseUserIDs parses a comma-separated list of Telegram user IDs, skipping empty entries
+func ParseUserIDs(value string) ([]int64, error) {
+	var ids []int64
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := parseUserID(field)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// parseUserID parses a Telegram user ID, which is positive
+func parseUserID(value string) (int64, error) {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid user ID %q", value)
+	}
+	return id, nil
+}
+
+// Allows reports whether a user may use the bot. Nil or empty Users allow everyone.
+func (u *Users) Allows(userID int64) bool {
+	if !u.Restricted() {
+		return true
+	}
+	for _, id := range u.allowed {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// Restricted reports whether only the allowed users may use the bot
+func (u *Users) Restricted() bool {
+	return u != nil && len(u.allowed) > 0
+}
+
+// NotifyUserID returns the user getting the scheduled messages, 0 when nobody is
+func (u *Users) NotifyUserID() int64 {
+	if u == nil {
+		return 0
+	}
+	return u.notify
+}
+
+// String lists the allowed users, "anyone" when the bot is open
+func (u *Users) String() string {
+	if !u.Restricted() {
+		return "anyone"
+	}
+	ids := make([]string, len(u.allowed))
+	for i, id := range u.allowed {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(ids, ", ")
+}
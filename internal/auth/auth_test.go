@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  string
+		notify   string
+		ids      []int64
+		notified int64
+	}{
+		{name: "unset", allowed: "", ids: nil, notified: 0},
+		{name: "single ID", allowed: "123", ids: []int64{123}, notified: 123},
+		{name: "list", allowed: "123,456,789", ids: []int64{123, 456, 789}, notified: 123},
+		{name: "whitespace and empty entries", allowed: " 123 ,, 456 , ", ids: []int64{123, 456}, notified: 123},
+		{name: "notified user", allowed: "123, 456", notify: " 456 ", ids: []int64{123, 456}, notified: 456},
+		{name: "notified user of an open bot", notify: "456", ids: nil, notified: 456},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := Parse(tt.allowed, tt.notify)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if !reflect.DeepEqual(users.allowed, tt.ids) || users.NotifyUserID() != tt.notified {
+				t.Errorf("Expected %v notifying %d, got %v notifying %d", tt.ids, tt.notified, users.allowed, users.NotifyUserID())
+			}
+		})
+	}
+
+	invalid := map[string][2]string{
+		"not a number":         {"123,abc", ""},
+		"negative":             {"-5", ""},
+		"zero":                 {"0", ""},
+		"invalid notified":     {"123", "me"},
+		"notified not allowed": {"123", "456"},
+	}
+	for name, values := range invalid {
+		if _, err := Parse(values[0], values[1]); err == nil {
+			t.Errorf("Expected %s to be rejected", name)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	users := NewUsers(123, 456)
+	for id, want := range map[int64]bool{123: true, 456: true, 789: false, 0: false} {
+		if got := users.Allows(id); got != want {
+			t.Errorf("Allows(%d) = %v, expected %v", id, got, want)
+		}
+	}
+
+	// Without allowed users everyone may use the bot
+	var unset *Users
+	if !unset.Allows(789) || !NewUsers().Allows(789) || unset.Restricted() {
+		t.Error("Expected an open bot to allow everyone")
+	}
+	if users.String() != "123, 456" || unset.String() != "anyone" {
+		t.Errorf("Unexpected descriptions %q and %q", users.String(), unset.String())
+	}
+}
@@ -0,0 +1,45 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// handleAssignCommand assigns the task of a pasted Notion link to a person of the
+// workspace: /assign <notion-url-or-id> <email>
+func (h *Handler) handleAssignCommand(message *tgbotapi.Message) error {
+	reply := func(text string) error {
+		_, err := h.bot.Send(tgbotapi.NewMessage(message.Chat.ID, text))
+		return err
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 || !strings.Contains(args[1], "@") {
+		return reply("Usage: /assign <Notion link or page ID> <email>")
+	}
+	pageID, err := notion.ParsePageID(args[0])
+	if err != nil {
+		return reply("❌ That doesn't look like a Notion link or page ID.")
+	}
+	email := args[1]
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := h.notionFor(message.From.ID).AssignTask(ctx, pageID, email); err != nil {
+		log.Printf("Failed to assign task %s to %s: %v", pageID, email, err)
+		switch {
+		case errors.Is(err, notion.ErrUserNotFound):
+			return reply("❌ No one in the Notion workspace has the email " + email + ".")
+		case errors.Is(err, notion.ErrInvalidUpdate):
+			return reply("❌ The tasks database has no " + notion.AssigneePropertyKey + " people property.")
+		}
+		return reply("❌ Failed to assign the task.")
+	}
+	return reply("👤 Assigned to " + email + ".")
+}
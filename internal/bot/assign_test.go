@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// assigneeSchema is a tasks database with an Assignee people property, as fakeNotionAPI.schema
+const assigneeSchema = `{
+	"Name": {"id": "title", "type": "title", "title": {}},
+	"Assignee": {"id": "ppl", "type": "people", "people": {}}
+}`
+
+func assignCommand(args string) *tgbotapi.Message {
+	message := testMessage("/assign "+args, 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/assign")}}
+	return message
+}
+
+func TestAssignCommandSetsAssignee(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.schema = assigneeSchema
+	fake.users = `[{"object": "user", "id": "user-1", "type": "person", "name": "Anna", "person": {"email": "anna@example.com"}}]`
+
+	link := "https://www.notion.so/workspace/Call-the-bank-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d"
+	if err := handler.HandleMessage(assignCommand(link + " Anna@example.com")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	updates := requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID)
+	if len(updates) != 1 || !strings.Contains(updates[0], `"Assignee":{"people":[{"object":"user","id":"user-1"}]}`) {
+		t.Fatalf("Expected the linked page to be assigned to user-1, got %v", updates)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].Params.Get("text"), "👤 Assigned to Anna@example.com") {
+		t.Errorf("Expected an assigned reply, got %v", sent)
+	}
+}
+
+func TestAssignCommandRejectsUnknownEmails(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.schema = assigneeSchema
+
+	if err := handler.HandleMessage(assignCommand(snoozedPageID + " carla@example.com")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if updates := requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID); len(updates) != 0 {
+		t.Errorf("Expected no update, got %v", updates)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || sent[0].Params.Get("text") != "❌ No one in the Notion workspace has the email carla@example.com." {
+		t.Errorf("Expected an unknown email reply, got %v", sent)
+	}
+}
+
+func TestAssignCommandUsage(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+
+	for _, args := range []string{"", snoozedPageID, "anna@example.com", snoozedPageID + " Anna"} {
+		if err := handler.HandleMessage(assignCommand(args)); err != nil {
+			t.Fatalf("HandleMessage(%q) failed: %v", args, err)
+		}
+	}
+
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no Notion requests, got %d", len(fake.requests))
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 4 {
+		t.Fatalf("Expected 4 replies, got %d", len(sent))
+	}
+	for _, call := range sent {
+		if !strings.HasPrefix(call.Params.Get("text"), "Usage: /assign") {
+			t.Errorf("Expected usage, got %q", call.Params.Get("text"))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// defaultTelegramLinkProperty is the url property getting the link back to the message a
+// task was created from, unless TELEGRAM_LINK_PROPERTY names another
+const defaultTelegramLinkProperty = "telegram_link"
+
+// telegramLinkPropertyFromEnv returns the property of TELEGRAM_LINK_PROPERTY
+func telegramLinkPropertyFromEnv() string {
+	if name := os.Getenv("TELEGRAM_LINK_PROPERTY"); name != "" {
+		return name
+	}
+	return defaultTelegramLinkProperty
+}
+
+// messageLink returns a link opening a message in Telegram, empty for basic groups, which
+// have none. Private chats have no t.me links, the bot's DM is linked with tg://openmessage.
+func messageLink(chat *tgbotapi.Chat, messageID int) string {
+	if chat == nil || messageID == 0 {
+		return ""
+	}
+	if chat.ID > 0 {
+		return fmt.Sprintf("tg://openmessage?user_id=%d&message_id=%d", chat.ID, messageID)
+	}
+	return postLink(chat, messageID)
+}
+
+// telegramLinkProperties returns the property linking a task back to its message, nil
+// when there is no link or the database has no url property for it
+func (h *Handler) telegramLinkProperties(ctx context.Context, client *notion.Client, dbType, link string) map[string]interface{} {
+	if h.telegramLinkProp == "" {
+		return nil
+	}
+	return urlProperties(ctx, client, dbType, h.telegramLinkProp, link)
+}
+
+// addOpenInNotionButton gives the bot's transcription reply of a saved message a button
+// opening the created page. Typed messages have no reply of the bot to edit.
+func (h *Handler) addOpenInNotionButton(chatID int64, transcriptMessageID int, pageID string) {
+	if transcriptMessageID == 0 {
+		return
+	}
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, transcriptMessageID, tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("Open in Notion", notionPageURL(pageID))),
+	))
+	if _, err := h.bot.Request(edit); err != nil {
+		log.Printf("Warning: Could not add the Notion button to message %d: %v", transcriptMessageID, err)
+	}
+}
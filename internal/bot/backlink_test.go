@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMessageLink(t *testing.T) {
+	tests := []struct {
+		name string
+		chat *tgbotapi.Chat
+		want string
+	}{
+		{"bot DM", &tgbotapi.Chat{ID: 456, Type: "private"}, "tg://openmessage?user_id=456&message_id=123"},
+		{"public channel", &tgbotapi.Chat{ID: -1001234567890, Type: "channel", UserName: "golangweekly"}, "https://t.me/golangweekly/123"},
+		{"private supergroup", &tgbotapi.Chat{ID: -1001234567890, Type: "supergroup"}, "https://t.me/c/1234567890/123"},
+		{"basic group", &tgbotapi.Chat{ID: -4567, Type: "group"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageLink(tt.chat, 123); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSaveLinksBackToTheMessage(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.telegramLinkProp = defaultTelegramLinkProperty
+	fake.schema = `{"Name": {"id": "title", "type": "title", "title": {}}, "Telegram_Link": {"id": "tl", "type": "url", "url": {}}}`
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	handler.recordTranscript(789, 456, 123, 1001)
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if link, ok := createdURL(t, fake, "Telegram_Link"); !ok || link != "tg://openmessage?user_id=789&message_id=123" {
+		t.Errorf("Expected the link to the message, got %q", link)
+	}
+
+	edits := telegram.callsTo("editMessageReplyMarkup")
+	if len(edits) != 1 || edits[0].Params.Get("message_id") != "1001" ||
+		!strings.Contains(edits[0].Params.Get("reply_markup"), `"url":"https://notion.so/page1"`) {
+		t.Errorf("Expected an Open in Notion button on the transcription reply, got %+v", edits)
+	}
+}
+
+func TestTelegramLinkNeedsTheProperty(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.telegramLinkProp = defaultTelegramLinkProperty
+	fake.schema = `{"Name": {"id": "title", "type": "title", "title": {}}, "URL": {"id": "u", "type": "url", "url": {}}}`
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if _, ok := createdURL(t, fake, "URL"); ok {
+		t.Error("Expected the url property to be left alone")
+	}
+	if edits := telegram.callsTo("editMessageReplyMarkup"); len(edits) != 0 {
+		t.Errorf("Expected no button without a transcription reply, got %+v", edits)
+	}
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// maxCommentTranscript is how much of a transcript the source comment quotes
+	maxCommentTranscript = 1500
+	// originForward is the origin of forwarded messages, media have their kind instead
+	originForward = "forwarded message"
+)
+
+// messageOrigin names what a message was when it wasn't typed: the kind of transcribed
+// media or a forward. It's empty for typed messages, which get no source comment.
+func messageOrigin(message *tgbotapi.Message) string {
+	if media, ok := transcribableMedia(message); ok {
+		return media.kind
+	}
+	if message.ForwardDate != 0 {
+		return originForward
+	}
+	return ""
+}
+
+// messageTime returns when Telegram received a message, now when it didn't say
+func messageTime(message *tgbotapi.Message) time.Time {
+	if message.Date == 0 {
+		return time.Now()
+	}
+	return time.Unix(int64(message.Date), 0)
+}
+
+// sourceComment describes where a pending task came from, empty when it gets no comment.
+// Transcribed media quote the transcript as saved, forwards link the original post.
+func (h *Handler) sourceComment(task *PendingTask, savedText string) string {
+	if !h.sourceComments || task.Origin == "" {
+		return ""
+	}
+
+	comment := fmt.Sprintf("Created from Telegram %s on %s", task.Origin, task.ReceivedAt.In(h.location()).Format("2006-01-02 15:04"))
+	switch {
+	case task.Origin != originForward:
+		comment += "; original transcript: " + truncateRunes(savedText, maxCommentTranscript)
+	case task.SourceURL != "":
+		comment += "; original post: " + task.SourceURL
+	}
+	return comment
+}
+
+// attachSourceComment posts the source comment on a created page. The task exists either
+// way, so a failure is only logged.
+func (h *Handler) attachSourceComment(ctx context.Context, client *notion.Client, pageID, comment string) {
+	if comment == "" {
+		return
+	}
+	if err := client.AddComment(ctx, pageID, comment); err != nil {
+		log.Printf("Warning: Could not add the source comment to task %s: %v", pageID, err)
+	}
+}
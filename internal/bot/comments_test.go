@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// comments returns the page and text of the comments the fake got
+func comments(t *testing.T, fake *fakeNotionAPI) map[string]string {
+	t.Helper()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	result := make(map[string]string)
+	for _, r := range fake.requests {
+		if r.Method != http.MethodPost || r.Path != "/v1/comments" {
+			continue
+		}
+		var body struct {
+			Parent struct {
+				PageID string `json:"page_id"`
+			} `json:"parent"`
+			RichText []struct {
+				Text struct {
+					Content string `json:"content"`
+				} `json:"text"`
+			} `json:"rich_text"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatalf("Invalid request body %s: %v", r.Body, err)
+		}
+		var text strings.Builder
+		for _, part := range body.RichText {
+			text.WriteString(part.Text.Content)
+		}
+		result[body.Parent.PageID] = text.String()
+	}
+	return result
+}
+
+// forwardedMessage is a post forwarded from a public channel
+func forwardedMessage(text string) *tgbotapi.Message {
+	message := testMessage(text, 0)
+	message.Date = int(time.Date(2024, 5, 1, 14, 32, 0, 0, time.UTC).Unix())
+	message.ForwardDate = message.Date
+	message.ForwardFromChat = &tgbotapi.Chat{ID: -1001234567890, Title: "Go News", UserName: "gonews"}
+	message.ForwardFromMessageID = 42
+	return message
+}
+
+func TestSourceComment(t *testing.T) {
+	handler := &Handler{sourceComments: true}
+	receivedAt := time.Date(2024, 5, 1, 14, 32, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task PendingTask
+		want string
+	}{
+		{
+			name: "voice note",
+			task: PendingTask{Origin: "voice note", ReceivedAt: receivedAt, TranscriptMessageID: 1001},
+			want: "Created from Telegram voice note on 2024-05-01 14:32; original transcript: Buy milk",
+		},
+		{
+			name: "forward",
+			task: PendingTask{Origin: originForward, ReceivedAt: receivedAt, SourceURL: "https://t.me/gonews/42"},
+			want: "Created from Telegram forwarded message on 2024-05-01 14:32; original post: https://t.me/gonews/42",
+		},
+		{
+			name: "forward without a link",
+			task: PendingTask{Origin: originForward, ReceivedAt: receivedAt},
+			want: "Created from Telegram forwarded message on 2024-05-01 14:32",
+		},
+		{
+			name: "typed message",
+			task: PendingTask{ReceivedAt: receivedAt},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := handler.sourceComment(&tt.task, "Buy milk"); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	handler.sourceComments = false
+	if got := handler.sourceComment(&tests[0].task, "Buy milk"); got != "" {
+		t.Errorf("Expected no comment with ATTACH_SOURCE_COMMENT=false, got %q", got)
+	}
+}
+
+func TestForwardGetsSourceComment(t *testing.T) {
+	handler, _, fake := newRecoveryTestHandler(t)
+	handler.sourceComments = true
+
+	handler.storePendingTask(forwardedMessage("Go 1.23 is out"))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	want := "Created from Telegram forwarded message on " + time.Date(2024, 5, 1, 14, 32, 0, 0, time.UTC).In(handler.location()).Format("2006-01-02 15:04") +
+		"; original post: https://t.me/gonews/42"
+	if got := comments(t, fake); len(got) != 1 || got["page-1"] != want {
+		t.Errorf("Expected the source comment on page-1, got %v", got)
+	}
+
+	// Typed messages get no comment
+	message := testMessage("Call the plumber", 0)
+	message.MessageID = 124
+	handler.storePendingTask(message)
+	reaction := thumbsUp()
+	reaction.MessageID = 124
+	if err := handler.HandleMessageReaction(context.Background(), reaction); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	if got := comments(t, fake); len(got) != 1 {
+		t.Errorf("Expected no comment for a typed message, got %v", got)
+	}
+}
+
+// noComments refuses comments like an integration without the comment capability
+type noComments struct {
+	*fakeNotionAPI
+}
+
+func (n *noComments) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := n.fakeNotionAPI.RoundTrip(req)
+	if err != nil || req.URL.Path != "/v1/comments" {
+		return response, err
+	}
+	response.StatusCode = http.StatusForbidden
+	response.Body = io.NopCloser(strings.NewReader(`{"object": "error", "status": 403, "code": "restricted_resource", "message": "Insufficient permissions for this endpoint."}`))
+	return response, nil
+}
+
+func TestFailedSourceCommentKeepsTheTask(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.notion = notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: &noComments{fake}}))
+	handler.sourceComments = true
+
+	handler.storePendingTask(forwardedMessage("Go 1.23 is out"))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("Expected the save to succeed without its comment, got %v", err)
+	}
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 {
+		t.Errorf("Expected the task to be created, got %v", created)
+	}
+	if got := comments(t, fake); len(got) != 1 {
+		t.Errorf("Expected the comment to be tried, got %v", got)
+	}
+	if got := reactions(telegram); len(got) == 0 || !strings.Contains(got[len(got)-1], feedbackSaved) {
+		t.Errorf("Expected the reaction to flip to 👍, got %v", got)
+	}
+	if handler.pendingTasks[456][123] != nil {
+		t.Errorf("Expected the message to no longer be pending")
+	}
+}
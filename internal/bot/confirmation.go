@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// TaskStartParamPrefix prefixes the page ID in mini app deep links (start_param)
+const TaskStartParamPrefix = "task_"
+
+// confirmationCardTTL is how long a confirmation card stays in the chat before it is deleted
+const confirmationCardTTL = 30 * time.Minute
+
+// archiveCallbackPrefix marks callback data of the "Delete" button on confirmation cards
+const archiveCallbackPrefix = "archive:"
+
+// notionPageURL builds a Notion URL from a page ID (Notion expects the ID without hyphens)
+func notionPageURL(pageID string) string {
+	return fmt.Sprintf("https://notion.so/%s", strings.ReplaceAll(pageID, "-", ""))
+}
+
+// buildMiniAppDeepLink builds a t.me link that opens the mini app focused on the given page.
+// When appShortName is empty the bot's main mini app is used.
+func buildMiniAppDeepLink(botUsername, appShortName, pageID string) string {
+	startParam := TaskStartParamPrefix + strings.ReplaceAll(pageID, "-", "")
+	if appShortName == "" {
+		return fmt.Sprintf("https://t.me/%s?startapp=%s", botUsername, startParam)
+	}
+	return fmt.Sprintf("https://t.me/%s/%s?startapp=%s", botUsername, appShortName, startParam)
+}
+
+// renderConfirmationCard renders the HTML text of a confirmation card (at most 4 lines)
+func renderConfirmationCard(title, pageURL, llmTag string, properties map[string]interface{}) string {
+	lines := []string{
+		fmt.Sprintf("✅ <a href=\"%s\">%s</a>", html.EscapeString(pageURL), html.EscapeString(truncateRunes(title, 80))),
+	}
+
+	if llmTag == "" {
+		llmTag = "—"
+	}
+	lines = append(lines, fmt.Sprintf("🏷️ %s", html.EscapeString(llmTag)))
+
+	// Tags and project share a line to keep the card compact
+	var details []string
+	if tags := propertyStrings(properties["Tags"]); len(tags) > 0 {
+		details = append(details, "Tags: "+html.EscapeString(strings.Join(tags, ", ")))
+	}
+	if project := propertyStrings(properties["project"]); len(project) > 0 {
+		details = append(details, "project: "+html.EscapeString(strings.Join(project, ", ")))
+	}
+	if len(details) > 0 {
+		lines = append(lines, "📌 "+strings.Join(details, " · "))
+	}
+
+	if date := propertyStrings(properties["Date"]); len(date) > 0 {
+		lines = append(lines, "📅 "+html.EscapeString(date[0]))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// confirmationCardKeyboard builds the "Edit in mini app" and "Delete" buttons for a card
+func confirmationCardKeyboard(deepLink, pageID string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL("Edit in mini app", deepLink),
+			tgbotapi.NewInlineKeyboardButtonData("Delete", archiveCallbackPrefix+pageID),
+		),
+	)
+}
+
+// sendConfirmationCard sends a card describing the saved task and schedules its deletion
+func (h *Handler) sendConfirmationCard(chatID int64, pageID, title, llmTag string, properties map[string]interface{}) error {
+	deepLink := buildMiniAppDeepLink(h.bot.Self.UserName, h.miniAppShortName, pageID)
+
+	msg := tgbotapi.NewMessage(chatID, renderConfirmationCard(title, notionPageURL(pageID), llmTag, properties))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = confirmationCardKeyboard(deepLink, pageID)
+
+	sent, err := h.bot.Send(msg)
+	if err != nil {
+		return fmt.Errorf("failed to send confirmation card: %w", err)
+	}
+
+	// Remove the card automatically so the chat doesn't fill up with them
+	h.afterFunc(confirmationCardTTL, func() {
+		if _, err := h.bot.Request(tgbotapi.NewDeleteMessage(chatID, sent.MessageID)); err != nil {
+			log.Printf("Warning: Failed to delete confirmation card %d: %v", sent.MessageID, err)
+		}
+	})
+
+	return nil
+}
+
+// HandleCallbackQuery handles inline button presses
+func (h *Handler) HandleCallbackQuery(query *tgbotapi.CallbackQuery) error {
+	if query.From == nil || !h.isAuthorized(query.From.ID) {
+		log.Printf("Ignoring callback query from unauthorized or unknown user")
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(query.Data, archiveCallbackPrefix):
+		return h.handleArchiveCallback(query, strings.TrimPrefix(query.Data, archiveCallbackPrefix))
+	case strings.HasPrefix(query.Data, undoCallbackPrefix):
+		return h.handleUndoCallback(query, strings.TrimPrefix(query.Data, undoCallbackPrefix))
+	case strings.HasPrefix(query.Data, snoozeCallbackPrefix):
+		return h.handleSnoozeCallback(query, strings.TrimPrefix(query.Data, snoozeCallbackPrefix))
+	case strings.HasPrefix(query.Data, templateCallbackPrefix):
+		return h.handleTemplateCallback(query, strings.TrimPrefix(query.Data, templateCallbackPrefix))
+	default:
+		log.Printf("Unknown callback data: %s", query.Data)
+		_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, ""))
+		return err
+	}
+}
+
+// handleArchiveCallback archives the page behind a confirmation card, which then offers
+// to undo it
+func (h *Handler) handleArchiveCallback(query *tgbotapi.CallbackQuery, pageID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := h.notionFor(query.From.ID).ArchiveTask(ctx, pageID); err != nil {
+		log.Printf("Failed to archive task %s: %v", pageID, err)
+		_, _ = h.bot.Request(tgbotapi.NewCallback(query.ID, "❌ Failed to archive task"))
+		return err
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "🗑️ Deleted")); err != nil {
+		log.Printf("Warning: Failed to answer callback query: %v", err)
+	}
+
+	// Replace the card so it no longer offers actions on an archived page
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID,
+			"🗑️ Task deleted. Press Undo within a minute to restore it.", undoKeyboard(pageID))
+		if _, err := h.bot.Request(edit); err != nil {
+			log.Printf("Warning: Failed to update confirmation card: %v", err)
+		}
+		h.offerUndo(query.From.ID, query.Message.Chat.ID, query.Message.MessageID, pageID)
+	}
+
+	return nil
+}
+
+// propertyStrings flattens a property value from a properties map into strings
+func propertyStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				result = append(result, s)
+			}
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// truncateRunes truncates a string to maxLen characters (UTF-8 safe)
+func truncateRunes(s string, maxLen int) string {
+	runes := []rune(s)
+	if len(runes) <= maxLen {
+		return s
+	}
+	return string(runes[:maxLen]) + "..."
+}
@@ -0,0 +1,223 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// telegramCall is a single Bot API call received by fakeTelegram
+type telegramCall struct {
+	Method string
+	Params url.Values
+}
+
+// fakeTelegram is a local Bot API server that records every call
+type fakeTelegram struct {
+	mu            sync.Mutex
+	calls         []telegramCall
+	failMethods   map[string]bool // Methods answered with a Bot API error
+	nextMessageID int
+}
+
+// newFakeTelegram starts a fake Bot API server and returns a BotAPI bound to it
+func newFakeTelegram(t *testing.T) (*fakeTelegram, *tgbotapi.BotAPI) {
+	t.Helper()
+
+	fake := &fakeTelegram{failMethods: make(map[string]bool), nextMessageID: 1000}
+	server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(server.Close)
+
+	botAPI, err := tgbotapi.NewBotAPIWithClient("test-token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("Failed to create bot API: %v", err)
+	}
+	return fake, botAPI
+}
+
+func (f *fakeTelegram) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	f.mu.Lock()
+	f.calls = append(f.calls, telegramCall{Method: method, Params: r.PostForm})
+	f.nextMessageID++
+	messageID := f.nextMessageID
+	fail := f.failMethods[method]
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case fail:
+		fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: REACTION_INVALID"}`)
+	case method == "getMe":
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Test","username":"test_bot"}}`)
+	case method == "sendMessage":
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":%s}}}`, messageID, r.PostForm.Get("chat_id"))
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+// setFailing makes calls to a method fail or succeed
+func (f *fakeTelegram) setFailing(method string, fail bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failMethods[method] = fail
+}
+
+// callsTo returns the recorded calls to the given Bot API method
+func (f *fakeTelegram) callsTo(method string) []telegramCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []telegramCall
+	for _, call := range f.calls {
+		if call.Method == method {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+func TestRenderConfirmationCard(t *testing.T) {
+	card := renderConfirmationCard("Buy <milk> & eggs", "https://notion.so/abc", "date", map[string]interface{}{
+		"Tags":    []interface{}{"from-telegram", "home"},
+		"project": "Errands",
+		"Date":    "2024-06-01",
+	})
+
+	lines := strings.Split(card, "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected 4 lines, got %d: %q", len(lines), card)
+	}
+	if lines[0] != `✅ <a href="https://notion.so/abc">Buy &lt;milk&gt; &amp; eggs</a>` {
+		t.Errorf("Unexpected title line: %s", lines[0])
+	}
+	if lines[1] != "🏷️ date" {
+		t.Errorf("Unexpected tag line: %s", lines[1])
+	}
+	if lines[2] != "📌 Tags: from-telegram, home · project: Errands" {
+		t.Errorf("Unexpected properties line: %s", lines[2])
+	}
+	if lines[3] != "📅 2024-06-01" {
+		t.Errorf("Unexpected date line: %s", lines[3])
+	}
+}
+
+func TestRenderConfirmationCardWithoutProperties(t *testing.T) {
+	card := renderConfirmationCard("Call dentist", "https://notion.so/abc", "", nil)
+
+	lines := strings.Split(card, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d: %q", len(lines), card)
+	}
+	if lines[1] != "🏷️ —" {
+		t.Errorf("Expected placeholder tag, got %s", lines[1])
+	}
+}
+
+func TestBuildMiniAppDeepLink(t *testing.T) {
+	pageID := "1234abcd-0000-1111-2222-333344445555"
+
+	tests := []struct {
+		name      string
+		shortName string
+		expected  string
+	}{
+		{"main mini app", "", "https://t.me/test_bot?startapp=task_1234abcd000011112222333344445555"},
+		{"named mini app", "tasks", "https://t.me/test_bot/tasks?startapp=task_1234abcd000011112222333344445555"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildMiniAppDeepLink("test_bot", tt.shortName, pageID); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSendConfirmationCardSchedulesDeletion(t *testing.T) {
+	fake, botAPI := newFakeTelegram(t)
+
+	var scheduledDelay time.Duration
+	var scheduled func()
+	handler := &Handler{
+		bot: botAPI,
+		afterFunc: func(d time.Duration, f func()) {
+			scheduledDelay = d
+			scheduled = f
+		},
+	}
+
+	if err := handler.sendConfirmationCard(789, "page-id", "Test task", "task", nil); err != nil {
+		t.Fatalf("sendConfirmationCard failed: %v", err)
+	}
+
+	sent := fake.callsTo("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 sendMessage call, got %d", len(sent))
+	}
+	if !strings.Contains(sent[0].Params.Get("reply_markup"), "archive:page-id") {
+		t.Errorf("Expected archive button in reply markup, got %s", sent[0].Params.Get("reply_markup"))
+	}
+	if !strings.Contains(sent[0].Params.Get("reply_markup"), "startapp=task_pageid") {
+		t.Errorf("Expected deep link in reply markup, got %s", sent[0].Params.Get("reply_markup"))
+	}
+
+	if scheduledDelay != confirmationCardTTL {
+		t.Errorf("Expected deletion after %v, got %v", confirmationCardTTL, scheduledDelay)
+	}
+	if len(fake.callsTo("deleteMessage")) != 0 {
+		t.Fatal("Card must not be deleted before the delay elapses")
+	}
+
+	// Run the scheduled deletion
+	scheduled()
+
+	deleted := fake.callsTo("deleteMessage")
+	if len(deleted) != 1 {
+		t.Fatalf("Expected 1 deleteMessage call, got %d", len(deleted))
+	}
+	if deleted[0].Params.Get("message_id") != "1002" {
+		t.Errorf("Expected deletion of the sent card (1002), got %s", deleted[0].Params.Get("message_id"))
+	}
+}
+
+func TestConfirmationCardShowsAppliedProperties(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.confirmationCards = true
+	fake.schema = `{
+		"Name": {"id": "title", "type": "title", "title": {}},
+		"Tags": {"id": "tags", "type": "multi_select", "multi_select": {"options": []}},
+		"Date": {"id": "date", "type": "date", "date": {}}
+	}`
+
+	if err := handler.HandleMessage(testMessage("Buy milk #home !2024-06-01", 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	var card string
+	for _, call := range telegram.callsTo("sendMessage") {
+		if strings.Contains(call.Params.Get("reply_markup"), archiveCallbackPrefix) {
+			card = call.Params.Get("text")
+		}
+	}
+	for _, want := range []string{"📌 Tags: home", "📅 2024-06-01"} {
+		if !strings.Contains(card, want) {
+			t.Errorf("Expected %q on the card, got %q", want, card)
+		}
+	}
+}
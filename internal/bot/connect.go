@@ -0,0 +1,246 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/tenant"
+)
+
+// connectTimeout bounds the Notion calls validating what a user pasted
+const connectTimeout = 30 * time.Second
+
+// Steps of /connect
+const (
+	connectStepToken = iota
+	connectStepTasks
+	connectStepNotes
+)
+
+// connectFlow is a /connect in progress, collecting credentials one message at a time
+type connectFlow struct {
+	step  int
+	creds tenant.Credentials
+}
+
+// SetTenants lets the users of r connect their own workspace. Without it the bot only
+// uses the workspace it was created with.
+func (h *Handler) SetTenants(r *tenant.Registry) {
+	h.tenants = r
+}
+
+// notionFor returns the Notion client of a user's own workspace, or the bot's
+func (h *Handler) notionFor(userID int64) *notion.Client {
+	if client := h.tenants.Client(userID); client != nil {
+		return client
+	}
+	return h.notion
+}
+
+// workspaceOf returns the workspace notionFor creates a user's tasks in, as task records
+// store it: the user's own when they connected one, database.OwnWorkspace otherwise
+func (h *Handler) workspaceOf(userID int64) int64 {
+	if h.tenants.Client(userID) != nil {
+		return userID
+	}
+	return database.OwnWorkspace
+}
+
+// isOwner reports whether a user can use the bot's own workspace and its bot-wide commands
+func (h *Handler) isOwner(userID int64) bool {
+	return h.users.Allows(userID)
+}
+
+// ownerOnly tells a user with their own workspace that a command works on the bot's
+func (h *Handler) ownerOnly(message *tgbotapi.Message) bool {
+	if h.isOwner(message.From.ID) {
+		return false
+	}
+	h.reply(message.Chat.ID, "❌ This command is only available to the bot's owner.")
+	return true
+}
+
+// handleConnect runs /connect, /disconnect and the answers to /connect for the users who
+// may connect a workspace. It reports whether it handled the message.
+func (h *Handler) handleConnect(message *tgbotapi.Message) (bool, error) {
+	userID := message.From.ID
+	if !h.tenants.CanConnect(userID) {
+		return false, nil
+	}
+
+	if message.IsCommand() {
+		switch message.Command() {
+		case "connect":
+			return true, h.startConnect(message)
+		case "disconnect":
+			return true, h.handleDisconnect(message)
+		case "cancel", "skip":
+			// Answers to a /connect in progress, handled below
+		default:
+			// Any other command abandons a /connect in progress
+			h.mu.Lock()
+			delete(h.connecting, userID)
+			h.mu.Unlock()
+			return false, nil
+		}
+	}
+
+	h.mu.Lock()
+	flow, ok := h.connecting[userID]
+	var current connectFlow
+	if ok {
+		current = *flow
+	}
+	h.mu.Unlock()
+	if !ok {
+		return false, nil
+	}
+	if message.IsCommand() && message.Command() == "cancel" {
+		h.mu.Lock()
+		delete(h.connecting, userID)
+		h.mu.Unlock()
+		h.reply(message.Chat.ID, "Cancelled, nothing was saved.")
+		return true, nil
+	}
+	return true, h.continueConnect(message, current)
+}
+
+// startConnect asks for the token of the integration to connect. Only private chats are
+// accepted, the token would be visible to everyone in a group.
+func (h *Handler) startConnect(message *tgbotapi.Message) error {
+	if !message.Chat.IsPrivate() {
+		h.reply(message.Chat.ID, "❌ Send /connect in a private chat with me, the token must stay secret.")
+		return nil
+	}
+
+	h.mu.Lock()
+	if h.connecting == nil {
+		h.connecting = make(map[int64]*connectFlow)
+	}
+	h.connecting[message.From.ID] = &connectFlow{step: connectStepToken, creds: tenant.Credentials{UserID: message.From.ID}}
+	h.mu.Unlock()
+
+	h.reply(message.Chat.ID, "🔌 Let's connect your Notion workspace.\n\n"+
+		"1. Create an internal integration at https://www.notion.so/my-integrations\n"+
+		"2. Share your tasks database (and notes database, if you have one) with it\n"+
+		"3. Paste the integration's secret token here\n\n"+
+		"Send /cancel to stop.")
+	return nil
+}
+
+// continueConnect validates an answer to /connect against Notion and asks for the next
+// one, saving the credentials after the last
+func (h *Handler) continueConnect(message *tgbotapi.Message, flow connectFlow) error {
+	chatID := message.Chat.ID
+	text := strings.TrimSpace(message.Text)
+	ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+	defer cancel()
+
+	switch flow.step {
+	case connectStepToken:
+		// The token shouldn't stay in the chat history
+		if _, err := h.bot.Request(tgbotapi.NewDeleteMessage(chatID, message.MessageID)); err != nil {
+			log.Printf("Warning: Could not delete the message with a Notion token: %v", err)
+		}
+		if text == "" {
+			h.reply(chatID, "Please paste the integration token, or /cancel.")
+			return nil
+		}
+		creds := flow.creds
+		creds.Token = text
+		if err := h.tenants.Validate(ctx, creds); err != nil {
+			log.Printf("Warning: User %d pasted a token Notion rejected: %v", message.From.ID, err)
+			h.reply(chatID, "❌ Notion rejected this token. Check it and paste it again, or /cancel.")
+			return nil
+		}
+		h.advanceConnect(message.From.ID, connectStepTasks, creds)
+		h.reply(chatID, "✅ Token accepted. Now send the link or ID of your tasks database.")
+		return nil
+
+	case connectStepTasks:
+		dbID, err := notion.ParsePageID(text)
+		if err != nil {
+			h.reply(chatID, "❌ That's not a database link or ID. Copy the link of the database and send it, or /cancel.")
+			return nil
+		}
+		creds := flow.creds
+		creds.TasksDbID = dbID
+		if err := h.tenants.Validate(ctx, creds); err != nil {
+			log.Printf("Warning: User %d sent a tasks database that can't be opened: %v", message.From.ID, err)
+			h.reply(chatID, "❌ I can't open this database. Make sure it's shared with your integration and send it again, or /cancel.")
+			return nil
+		}
+		h.advanceConnect(message.From.ID, connectStepNotes, creds)
+		h.reply(chatID, "✅ Tasks database found. Send the link or ID of a notes database, or /skip if you don't use one.")
+		return nil
+
+	default:
+		creds := flow.creds
+		if !(message.IsCommand() && message.Command() == "skip") {
+			dbID, err := notion.ParsePageID(text)
+			if err != nil {
+				h.reply(chatID, "❌ That's not a database link or ID. Send it again, /skip or /cancel.")
+				return nil
+			}
+			creds.NotesDbID = dbID
+			if err := h.tenants.Validate(ctx, creds); err != nil {
+				log.Printf("Warning: User %d sent a notes database that can't be opened: %v", message.From.ID, err)
+				h.reply(chatID, "❌ I can't open this database. Make sure it's shared with your integration and send it again, /skip or /cancel.")
+				return nil
+			}
+		}
+		return h.finishConnect(message, creds)
+	}
+}
+
+// advanceConnect moves a /connect to its next step
+func (h *Handler) advanceConnect(userID int64, step int, creds tenant.Credentials) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if flow := h.connecting[userID]; flow != nil {
+		flow.step = step
+		flow.creds = creds
+	}
+}
+
+// finishConnect saves validated credentials, from then on the user's messages go to their
+// workspace
+func (h *Handler) finishConnect(message *tgbotapi.Message, creds tenant.Credentials) error {
+	h.mu.Lock()
+	delete(h.connecting, message.From.ID)
+	h.mu.Unlock()
+
+	if err := h.tenants.Save(creds, time.Now()); err != nil {
+		log.Printf("Error saving the workspace of user %d: %v", message.From.ID, err)
+		h.reply(message.Chat.ID, "❌ Could not save your workspace, please try /connect again later.")
+		return err
+	}
+	log.Printf("User %d connected their Notion workspace", message.From.ID)
+	h.reply(message.Chat.ID, "🎉 Your workspace is connected! Send me any text and react with 👍 to save it as a task. /disconnect removes it.")
+	return nil
+}
+
+// handleDisconnect deletes the credentials of the user's workspace
+func (h *Handler) handleDisconnect(message *tgbotapi.Message) error {
+	h.mu.Lock()
+	delete(h.connecting, message.From.ID)
+	h.mu.Unlock()
+
+	removed, err := h.tenants.Remove(message.From.ID)
+	if err != nil {
+		log.Printf("Error removing the workspace of user %d: %v", message.From.ID, err)
+		h.reply(message.Chat.ID, "❌ Could not disconnect your workspace, please try again later.")
+		return err
+	}
+	if !removed {
+		h.reply(message.Chat.ID, "No workspace is connected. Use /connect to connect one.")
+		return nil
+	}
+	h.reply(message.Chat.ID, "🔌 Your workspace was disconnected and its token deleted.")
+	return nil
+}
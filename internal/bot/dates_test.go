@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+)
+
+// newFakeGeminiDates tags every entry "date" and answers date prompts from the answers
+// for each entry
+func newFakeGeminiDates(t *testing.T, answers map[string]string) *gemini.Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gemini.GeminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt := req.Contents[0].Parts[0].Text
+
+		answer := "date"
+		if strings.Contains(prompt, "YYYY-MM-DD") {
+			answer = "none"
+			for entry, date := range answers {
+				if strings.Contains(prompt, `"`+entry+`"`) {
+					answer = date
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(gemini.GeminiResponse{Candidates: []gemini.Candidate{{
+			Content: gemini.ContentResponse{Parts: []gemini.PartResponse{{Text: answer}}},
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_URL", server.URL)
+	return gemini.NewClient()
+}
+
+// dateUpdates returns the Date values sent in page updates
+func (f *fakeNotionAPI) dateUpdates(t *testing.T) []string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var dates []string
+	for _, r := range f.requests {
+		if r.Method != http.MethodPatch || !strings.HasPrefix(r.Path, "/v1/pages/") {
+			continue
+		}
+		var body struct {
+			Properties map[string]struct {
+				Date *struct {
+					Start string `json:"start"`
+				} `json:"date"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatalf("Invalid update body %s: %v", r.Body, err)
+		}
+		if date := body.Properties["Date"].Date; date != nil {
+			dates = append(dates, date.Start)
+		}
+	}
+	return dates
+}
+
+// newDateTestHandler creates a handler whose tasks database has a Date and whose
+// Gemini stub resolves the answers
+func newDateTestHandler(t *testing.T, answers map[string]string) (*Handler, *fakeNotionAPI) {
+	t.Helper()
+	handler, _, fake := newRecoveryTestHandler(t)
+	fake.schema = `{
+		"Name": {"id": "title", "type": "title", "title": {}},
+		"Date": {"id": "date", "type": "date", "date": {}},
+		"llm_tag": {"id": "tag", "type": "rich_text", "rich_text": {}}
+	}`
+	client := newFakeGeminiDates(t, answers)
+	handler.tagger = client
+	handler.dates = client
+	handler.scheduler = fixedScheduler{location: time.UTC}
+	return handler, fake
+}
+
+func TestSetExtractedDate(t *testing.T) {
+	answers := map[string]string{
+		"Dentist tomorrow":            "2026-10-15",
+		"Submit the report by friday": "2026-10-16",
+		"Call mom on 23 october":      "2026-10-23",
+		"Exam prep sometime":          "soon-ish",
+	}
+	tests := []struct {
+		text string
+		want string // Empty when the date is left unset
+	}{
+		{"Dentist tomorrow", "2026-10-15"},
+		{"Submit the report by friday", "2026-10-16"},
+		{"Call mom on 23 october", "2026-10-23"},
+		{"Exam prep sometime", ""},
+		{"Homework", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			handler, fake := newDateTestHandler(t, answers)
+
+			handler.setExtractedDate(context.Background(), handler.notion, "page-1", tt.text)
+
+			dates := fake.dateUpdates(t)
+			if tt.want == "" {
+				if len(dates) != 0 {
+					t.Errorf("Expected the date to stay unset, got %v", dates)
+				}
+				return
+			}
+			if len(dates) != 1 || !strings.HasPrefix(dates[0], tt.want) {
+				t.Errorf("Expected the date %s, got %v", tt.want, dates)
+			}
+		})
+	}
+}
+
+func TestDateTaggedTaskGetsDate(t *testing.T) {
+	handler, fake := newDateTestHandler(t, map[string]string{"Dentist tomorrow": "2026-10-15"})
+
+	handler.storePendingTask(testMessage("Dentist tomorrow", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	// Tagging runs in the background after the save, the date is set after the tag
+	var dates []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(dates) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		dates = fake.dateUpdates(t)
+	}
+	if len(dates) != 1 || !strings.HasPrefix(dates[0], "2026-10-15") {
+		t.Errorf("Expected the date 2026-10-15, got %v", dates)
+	}
+}
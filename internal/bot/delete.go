@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// undoCallbackPrefix marks callback data of the "Undo" button sent after a delete
+	undoCallbackPrefix = "undo:"
+	// undoWindow is how long a deleted task can be restored with the "Undo" button
+	undoWindow = 60 * time.Second
+)
+
+// undoKey identifies a deleted task its user can still restore
+type undoKey struct {
+	userID int64
+	pageID string
+}
+
+// undoKeyboard builds the "Undo" button of a deleted task
+func undoKeyboard(pageID string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("Undo", undoCallbackPrefix+pageID),
+		),
+	)
+}
+
+// handleDeleteCommand archives a task: /delete <Notion link or page ID>, or /delete as a
+// reply to the message a task was saved from
+func (h *Handler) handleDeleteCommand(message *tgbotapi.Message) error {
+	pageID, ok := h.deleteTarget(message)
+	if !ok {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := h.notionFor(message.From.ID).ArchiveTask(ctx, pageID); err != nil {
+		log.Printf("Failed to delete task %s: %v", pageID, err)
+		h.reply(message.Chat.ID, "❌ Failed to delete the task.")
+		return nil
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, "🗑️ Task deleted. Press Undo within a minute to restore it.")
+	msg.ReplyMarkup = undoKeyboard(pageID)
+	sent, err := h.bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	h.offerUndo(message.From.ID, message.Chat.ID, sent.MessageID, pageID)
+	return nil
+}
+
+// deleteTarget returns the page /delete names, replying with the usage when there is none
+func (h *Handler) deleteTarget(message *tgbotapi.Message) (string, bool) {
+	if arg := strings.TrimSpace(message.CommandArguments()); arg != "" {
+		pageID, err := notion.ParsePageID(arg)
+		if err != nil {
+			h.reply(message.Chat.ID, "❌ That doesn't look like a Notion link or page ID.")
+			return "", false
+		}
+		return pageID, true
+	}
+
+	if message.ReplyToMessage != nil {
+		h.mu.Lock()
+		saved, ok := h.savedMessageCache().Get(savedKey{message.From.ID, message.ReplyToMessage.MessageID})
+		h.mu.Unlock()
+		if ok {
+			return saved.TaskID, true
+		}
+		h.reply(message.Chat.ID, "❌ I don't know a task saved from that message, send /delete with its Notion link instead.")
+		return "", false
+	}
+
+	h.reply(message.Chat.ID, "Usage: /delete <Notion link or page ID>, or reply /delete to a saved message")
+	return "", false
+}
+
+// offerUndo lets the user restore a deleted task from the message with its "Undo" button
+// until undoWindow passes, then removes the button
+func (h *Handler) offerUndo(userID int64, chatID int64, messageID int, pageID string) {
+	key := undoKey{userID, pageID}
+	expires := time.Now().Add(undoWindow)
+
+	h.mu.Lock()
+	if h.undos == nil {
+		h.undos = make(map[undoKey]time.Time)
+	}
+	h.undos[key] = expires
+	h.mu.Unlock()
+
+	h.afterFunc(undoWindow, func() {
+		h.mu.Lock()
+		current, pending := h.undos[key]
+		// A later delete of the same task has its own window
+		if pending && !current.After(expires) {
+			delete(h.undos, key)
+		}
+		h.mu.Unlock()
+		if !pending {
+			return
+		}
+
+		edit := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+		if _, err := h.bot.Request(edit); err != nil {
+			log.Printf("Warning: Failed to remove the undo button: %v", err)
+		}
+	})
+}
+
+// handleUndoCallback restores a deleted task if its undo window is still open
+func (h *Handler) handleUndoCallback(query *tgbotapi.CallbackQuery, pageID string) error {
+	key := undoKey{query.From.ID, pageID}
+	h.mu.Lock()
+	expires, ok := h.undos[key]
+	if ok {
+		delete(h.undos, key)
+	}
+	h.mu.Unlock()
+
+	if !ok || time.Now().After(expires) {
+		if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "⌛ Too late to undo, restore the task from Notion's trash")); err != nil {
+			log.Printf("Warning: Failed to answer callback query: %v", err)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := h.notionFor(query.From.ID).RestoreTask(ctx, pageID); err != nil {
+		log.Printf("Failed to restore task %s: %v", pageID, err)
+		_, _ = h.bot.Request(tgbotapi.NewCallback(query.ID, "❌ Failed to restore task"))
+		return err
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "↩️ Restored")); err != nil {
+		log.Printf("Warning: Failed to answer callback query: %v", err)
+	}
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, "↩️ Task restored")
+		if _, err := h.bot.Request(edit); err != nil {
+			log.Printf("Warning: Failed to update the deleted task's message: %v", err)
+		}
+	}
+	return nil
+}
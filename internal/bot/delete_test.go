@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func deleteCommand(arg string) *tgbotapi.Message {
+	message := testMessage("/delete "+arg, 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/delete")}}
+	return message
+}
+
+func undoQuery(pageID string) *tgbotapi.CallbackQuery {
+	return &tgbotapi.CallbackQuery{
+		ID:      "cb-1",
+		From:    &tgbotapi.User{ID: 456},
+		Message: &tgbotapi.Message{MessageID: 1001, Chat: &tgbotapi.Chat{ID: 789}},
+		Data:    undoCallbackPrefix + pageID,
+	}
+}
+
+// deleteWithUndo deletes the snooze tests' page with /delete, returning the scheduled
+// expiry of its undo window
+func deleteWithUndo(t *testing.T, handler *Handler) func() {
+	t.Helper()
+	var expire func()
+	handler.afterFunc = func(d time.Duration, f func()) {
+		if d != undoWindow {
+			t.Errorf("Expected the undo button to expire after %v, got %v", undoWindow, d)
+		}
+		expire = f
+	}
+	if err := handler.HandleMessage(deleteCommand("https://www.notion.so/Call-the-bank-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if expire == nil {
+		t.Fatal("Expected the undo window to be scheduled")
+	}
+	return expire
+}
+
+func TestDeleteCommandArchivesAndUndoRestores(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	expire := deleteWithUndo(t, handler)
+
+	updates := requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID)
+	if len(updates) != 1 || !strings.Contains(updates[0], `"archived":true`) {
+		t.Fatalf("Expected the page to be archived, got %v", updates)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.Contains(sent[0].Params.Get("reply_markup"), undoCallbackPrefix+snoozedPageID) {
+		t.Fatalf("Expected a reply with an undo button, got %v", sent)
+	}
+
+	if err := handler.HandleCallbackQuery(undoQuery(snoozedPageID)); err != nil {
+		t.Fatalf("HandleCallbackQuery failed: %v", err)
+	}
+	updates = requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID)
+	if len(updates) != 2 || !strings.Contains(updates[1], `"archived":false`) {
+		t.Fatalf("Expected the page to be restored, got %v", updates)
+	}
+	answers := telegram.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params.Get("text") != "↩️ Restored" {
+		t.Errorf("Expected a restored answer, got %v", answers)
+	}
+	edits := telegram.callsTo("editMessageText")
+	if len(edits) != 1 || edits[0].Params.Get("text") != "↩️ Task restored" {
+		t.Errorf("Expected the reply to say the task was restored, got %v", edits)
+	}
+
+	// The window closing afterwards has nothing left to remove
+	expire()
+	if len(telegram.callsTo("editMessageReplyMarkup")) != 0 {
+		t.Errorf("Expected no edit once the task was restored")
+	}
+}
+
+func TestUndoAfterExpiryIsRefused(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	deleteWithUndo(t, handler)
+
+	// The window has passed, but the button hasn't been removed yet
+	handler.mu.Lock()
+	handler.undos[undoKey{456, snoozedPageID}] = time.Now().Add(-time.Second)
+	handler.mu.Unlock()
+	if err := handler.HandleCallbackQuery(undoQuery(snoozedPageID)); err != nil {
+		t.Fatalf("HandleCallbackQuery failed: %v", err)
+	}
+
+	// Once the button is removed, a press from a stale client is refused too
+	expire := deleteWithUndo(t, handler)
+	expire()
+	if len(telegram.callsTo("editMessageReplyMarkup")) != 1 {
+		t.Errorf("Expected the undo button to be removed when the window closes")
+	}
+	if err := handler.HandleCallbackQuery(undoQuery(snoozedPageID)); err != nil {
+		t.Fatalf("HandleCallbackQuery failed: %v", err)
+	}
+
+	for _, body := range requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID) {
+		if strings.Contains(body, `"archived":false`) {
+			t.Fatalf("Expected the page to stay archived, got %s", body)
+		}
+	}
+	answers := telegram.callsTo("answerCallbackQuery")
+	if len(answers) != 2 {
+		t.Fatalf("Expected 2 callback answers, got %d", len(answers))
+	}
+	for _, answer := range answers {
+		if !strings.HasPrefix(answer.Params.Get("text"), "⌛ Too late to undo") {
+			t.Errorf("Expected the undo to be refused, got %q", answer.Params.Get("text"))
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// maxDoneCandidates is how many open tasks /done matches against
+	maxDoneCandidates = 300
+	// maxDoneChoices is how many matches /done lists when several fit
+	maxDoneChoices = 5
+	// minDoneScore is the share of the words of /done's text a title needs to match
+	minDoneScore = 0.5
+	// doneChoiceTTL is how long the numbered list of /done can be answered
+	doneChoiceTTL = 5 * time.Minute
+)
+
+// doneChoice is a list of matches /done is waiting for the user to pick from
+type doneChoice struct {
+	tasks   []notion.Task
+	expires time.Time
+}
+
+// titleWords splits a title into lower case words, ignoring punctuation
+func titleWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// wordOverlap returns the share of the query words found in the title
+func wordOverlap(query []string, title string) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+	words := make(map[string]bool)
+	for _, word := range titleWords(title) {
+		words[word] = true
+	}
+	found := 0
+	for _, word := range query {
+		if words[word] {
+			found++
+		}
+	}
+	return float64(found) / float64(len(query))
+}
+
+// matchDoneTasks returns the tasks whose title best fits the text, best first. Titles
+// containing the text win; an exact title is a single match. Without any, titles sharing
+// at least half the words of the text are ranked by how many they share.
+func matchDoneTasks(tasks []notion.Task, text string) []notion.Task {
+	query := strings.ToLower(strings.TrimSpace(text))
+
+	var contains []notion.Task
+	for _, task := range tasks {
+		title := strings.ToLower(strings.TrimSpace(task.Title))
+		if title == query {
+			return []notion.Task{task}
+		}
+		if strings.Contains(title, query) {
+			contains = append(contains, task)
+		}
+	}
+	if len(contains) > 0 {
+		// The shortest titles are the closest to the text
+		sort.SliceStable(contains, func(i, j int) bool {
+			return len([]rune(contains[i].Title)) < len([]rune(contains[j].Title))
+		})
+		return contains
+	}
+
+	type scored struct {
+		task  notion.Task
+		score float64
+	}
+	words := titleWords(query)
+	var matches []scored
+	for _, task := range tasks {
+		if score := wordOverlap(words, task.Title); score >= minDoneScore {
+			matches = append(matches, scored{task, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	result := make([]notion.Task, 0, len(matches))
+	for _, match := range matches {
+		result = append(result, match.task)
+	}
+	return result
+}
+
+// handleDoneCommand marks the open task best matching the text done: /done <text>. Several
+// matches are listed for the user to answer with the number of the right one.
+func (h *Handler) handleDoneCommand(message *tgbotapi.Message) error {
+	text := strings.TrimSpace(message.CommandArguments())
+	if len([]rune(text)) < 2 {
+		h.reply(message.Chat.ID, "Usage: /done <task title>, with at least 2 characters")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tasks, err := h.notionFor(message.From.ID).GetUndoneTasksExcludingSometimesLater(ctx, "tasks", maxDoneCandidates)
+	if err != nil {
+		log.Printf("Error fetching open tasks for /done: %v", err)
+		h.reply(message.Chat.ID, "❌ Could not fetch tasks: "+notion.Explain(err))
+		return nil
+	}
+
+	matches := matchDoneTasks(tasks, text)
+	switch {
+	case len(matches) == 0:
+		h.reply(message.Chat.ID, fmt.Sprintf("🔍 No open task matches \"%s\"", text))
+		return nil
+	case len(matches) == 1:
+		return h.completeTask(ctx, message, matches[0])
+	}
+
+	if len(matches) > maxDoneChoices {
+		matches = matches[:maxDoneChoices]
+	}
+	h.mu.Lock()
+	if h.doneChoices == nil {
+		h.doneChoices = make(map[int64]*doneChoice)
+	}
+	h.doneChoices[message.From.ID] = &doneChoice{tasks: matches, expires: time.Now().Add(doneChoiceTTL)}
+	h.mu.Unlock()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Several tasks match \"%s\", reply with the number of the one to mark done:", html.EscapeString(text))
+	for i, task := range matches {
+		fmt.Fprintf(&b, "\n%d. <a href=\"%s\">%s</a>", i+1, html.EscapeString(notionPageURL(task.ID)), html.EscapeString(truncateRunes(task.Title, 80)))
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, b.String())
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	_, err = h.bot.Send(msg)
+	return err
+}
+
+// handleDoneChoice marks the task a number picks from the user's /done list done.
+// answered is false when there is no list to answer, expired lists are dropped so the
+// message is handled like any other.
+func (h *Handler) handleDoneChoice(message *tgbotapi.Message) (answered bool, err error) {
+	n, convErr := strconv.Atoi(strings.TrimSpace(message.Text))
+	if convErr != nil {
+		return false, nil
+	}
+
+	h.mu.Lock()
+	choice := h.doneChoices[message.From.ID]
+	if choice != nil && time.Now().After(choice.expires) {
+		delete(h.doneChoices, message.From.ID)
+		choice = nil
+	}
+	valid := choice != nil && n >= 1 && n <= len(choice.tasks)
+	if valid {
+		delete(h.doneChoices, message.From.ID)
+	}
+	h.mu.Unlock()
+
+	if choice == nil {
+		return false, nil
+	}
+	if !valid {
+		h.reply(message.Chat.ID, fmt.Sprintf("Reply with a number from 1 to %d, or send /done again.", len(choice.tasks)))
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return true, h.completeTask(ctx, message, choice.tasks[n-1])
+}
+
+// completeTask sets a task's status to done and confirms with a link to it
+func (h *Handler) completeTask(ctx context.Context, message *tgbotapi.Message, task notion.Task) error {
+	chatID := message.Chat.ID
+	if err := h.notionFor(message.From.ID).UpdateTaskStatus(ctx, task.ID, "done", nil); err != nil {
+		log.Printf("Failed to mark task %s done: %v", task.ID, err)
+		update := outboxStatus{UserID: message.From.ID, ChatID: chatID, TaskID: task.ID, Title: task.Title, Status: "done"}
+		if notion.IsTransient(err) && h.queueWrite(outboxUpdateStatus, chatID, 0, update, err) {
+			h.reply(chatID, "⏳ Notion can't be reached, the task is marked done once it's back.")
+			return nil
+		}
+		h.reply(chatID, "❌ Failed to mark the task done.")
+		return nil
+	}
+
+	text := fmt.Sprintf("✅ Done: <a href=\"%s\">%s</a>", html.EscapeString(notionPageURL(task.ID)), html.EscapeString(truncateRunes(task.Title, 80)))
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	_, err := h.bot.Send(msg)
+	return err
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// doneSchema is a tasks database with a select status
+const doneSchema = `{
+	"Name": {"id": "title", "type": "title", "title": {}},
+	"status": {"id": "st", "type": "select", "select": {"options": [{"name": "todo"}, {"name": "done"}]}}
+}`
+
+// openTaskJSON returns an undone task page for fakeNotionAPI.results
+func openTaskJSON(id, title string) string {
+	return `{"object": "page", "id": "` + id + `", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "` + title + `"}, "plain_text": "` + title + `"}]},
+		"status": {"id": "st", "type": "select", "select": {"name": "todo"}}
+	}}`
+}
+
+func newDoneTestHandler(t *testing.T, titles ...string) (*Handler, *fakeTelegram, *fakeNotionAPI) {
+	t.Helper()
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.schema = doneSchema
+
+	pages := make([]string, 0, len(titles))
+	for i, title := range titles {
+		pages = append(pages, openTaskJSON("task-"+string(rune('a'+i)), title))
+	}
+	fake.results = "[" + strings.Join(pages, ",") + "]"
+	return handler, telegram, fake
+}
+
+func doneCommand(text string) *tgbotapi.Message {
+	message := testMessage("/done "+text, 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/done")}}
+	return message
+}
+
+// statusUpdates returns the pages whose status was set to done
+func statusUpdates(fake *fakeNotionAPI) []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var pages []string
+	for _, r := range fake.requests {
+		if r.Method == http.MethodPatch && strings.Contains(string(r.Body), `"status":{"select":{"name":"done"}`) {
+			pages = append(pages, strings.TrimPrefix(r.Path, "/v1/pages/"))
+		}
+	}
+	return pages
+}
+
+func TestMatchDoneTasks(t *testing.T) {
+	tasks := []notion.Task{
+		{ID: "1", Title: "Buy oat milk"},
+		{ID: "2", Title: "Buy milk"},
+		{ID: "3", Title: "Call the dentist about Friday"},
+		{ID: "4", Title: "Renew passport"},
+	}
+	ids := func(matches []notion.Task) string {
+		var result []string
+		for _, task := range matches {
+			result = append(result, task.ID)
+		}
+		return strings.Join(result, ",")
+	}
+
+	tests := []struct {
+		text, want string
+	}{
+		{"BUY MILK", "2"},            // Exact title, ignoring case
+		{"milk", "2,1"},              // Substrings, shortest title first
+		{"passport", "4"},            // Single substring
+		{"dentist call", "3"},        // Words in another order
+		{"call dentist monday", "3"}, // Two of three words
+		{"walk the dog", ""},
+	}
+	for _, tt := range tests {
+		if got := ids(matchDoneTasks(tasks, tt.text)); got != tt.want {
+			t.Errorf("matchDoneTasks(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDoneCommandCompletesUniqueMatch(t *testing.T) {
+	handler, telegram, fake := newDoneTestHandler(t, "Renew passport", "Buy milk")
+
+	if err := handler.HandleMessage(doneCommand("passport")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if got := statusUpdates(fake); len(got) != 1 || got[0] != "task-a" {
+		t.Fatalf("Expected task-a to be marked done, got %v", got)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.Contains(sent[0].Params.Get("text"), `✅ Done: <a href="https://notion.so/taska">Renew passport</a>`) {
+		t.Errorf("Expected a confirmation with a link, got %v", sent)
+	}
+}
+
+func TestDoneCommandAsksToChooseBetweenMatches(t *testing.T) {
+	handler, telegram, fake := newDoneTestHandler(t, "Buy oat milk", "Buy milk", "Renew passport")
+
+	if err := handler.HandleMessage(doneCommand("milk")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if got := statusUpdates(fake); len(got) != 0 {
+		t.Fatalf("Expected no task to be marked done before choosing, got %v", got)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected the list of matches, got %d messages", len(sent))
+	}
+	list := sent[0].Params.Get("text")
+	if !strings.Contains(list, "1. <a href=\"https://notion.so/taskb\">Buy milk</a>") || !strings.Contains(list, "2. <a href=\"https://notion.so/taska\">Buy oat milk</a>") {
+		t.Errorf("Expected numbered matches, got %q", list)
+	}
+
+	// Out of range numbers keep the list
+	if err := handler.HandleMessage(testMessage("3", 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if err := handler.HandleMessage(testMessage("2", 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if got := statusUpdates(fake); len(got) != 1 || got[0] != "task-a" {
+		t.Fatalf("Expected the second match to be marked done, got %v", got)
+	}
+
+	// The list is answered, the next number is an ordinary message
+	if err := handler.HandleMessage(testMessage("1", 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if got := statusUpdates(fake); len(got) != 1 {
+		t.Errorf("Expected a single task marked done, got %v", got)
+	}
+	if handler.pendingTasks[456][123] == nil {
+		t.Error("Expected the number to be stored as a pending task")
+	}
+}
+
+func TestDoneChoiceExpires(t *testing.T) {
+	handler, _, fake := newDoneTestHandler(t, "Buy oat milk", "Buy milk")
+
+	if err := handler.HandleMessage(doneCommand("milk")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	handler.mu.Lock()
+	handler.doneChoices[456].expires = time.Now().Add(-time.Second)
+	handler.mu.Unlock()
+
+	if err := handler.HandleMessage(testMessage("1", 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if got := statusUpdates(fake); len(got) != 0 {
+		t.Errorf("Expected no task marked done after the list expired, got %v", got)
+	}
+	if _, ok := handler.doneChoices[456]; ok {
+		t.Error("Expected the expired list to be dropped")
+	}
+	if handler.pendingTasks[456][123] == nil {
+		t.Error("Expected the number to be stored as a pending task")
+	}
+}
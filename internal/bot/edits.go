@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+)
+
+const (
+	// savedMessageTTL is how long a saved message keeps accepting edits
+	savedMessageTTL = 48 * time.Hour
+	// savedMessagesCap bounds how many saved messages are remembered for edits
+	savedMessagesCap = 10000
+)
+
+// savedKey identifies a saved message by user and message ID
+type savedKey struct {
+	userID    int64
+	messageID int
+}
+
+// savedMessage links a Telegram message to the Notion page created from it
+type savedMessage struct {
+	TaskID        string
+	Title         string    // Title currently stored in Notion
+	SaveStartedAt time.Time // When the save read the message text
+	editDate      int       // edit_date of the last applied edit
+}
+
+// HandleEditedMessage applies a message edit to its task.
+//
+// Edits are ordered against saves as follows:
+//   - before the save starts, the pending text is replaced and the save picks it up,
+//     since each save attempt reads the text under the same lock
+//   - while the save is in flight, the pending text is replaced and the save issues a
+//     follow-up title update once the page exists
+//   - after the save, the Notion title is updated directly
+//
+// Edits with an edit_date older than the last applied edit arrived out of order and are dropped.
+func (h *Handler) HandleEditedMessage(message *tgbotapi.Message) error {
+	if message.From == nil || !h.isAuthorized(message.From.ID) {
+		log.Printf("Ignoring edited message from unauthorized or unknown user")
+		return nil
+	}
+
+	// Only text edits affect the title
+	if message.Text == "" {
+		return nil
+	}
+
+	userID := message.From.ID
+	messageID := message.MessageID
+
+	h.mu.Lock()
+	if pending := h.pendingTasks[userID][messageID]; pending != nil {
+		if message.EditDate < pending.editDate {
+			h.mu.Unlock()
+			log.Printf("Ignoring out-of-order edit of message %d", messageID)
+			return nil
+		}
+
+		pending.Text = message.Text
+		pending.editDate = message.EditDate
+		saving := pending.saving
+		h.mu.Unlock()
+
+		if saving {
+			log.Printf("Message %d edited while its save is in progress, the title will be updated afterwards", messageID)
+		} else {
+			h.persistPendingEdit(message.Chat.ID, messageID, message.Text)
+			log.Printf("Updated pending task for message %d: %s", messageID, message.Text)
+		}
+		return nil
+	}
+	h.mu.Unlock()
+
+	return h.applyEditToSavedTask(userID, messageID, message.Text, message.EditDate)
+}
+
+// applyEditToSavedTask updates the Notion title of a task created from an edited message
+func (h *Handler) applyEditToSavedTask(userID int64, messageID int, text string, editDate int) error {
+	h.mu.Lock()
+	saved, _ := h.savedMessageCache().Get(savedKey{userID, messageID})
+	if saved == nil {
+		h.mu.Unlock()
+		log.Printf("No task found for edited message %d", messageID)
+		return nil
+	}
+
+	if editDate < saved.editDate || text == saved.Title {
+		h.mu.Unlock()
+		return nil
+	}
+
+	// An edit dated after the save read the text raced with it
+	if int64(editDate) >= saved.SaveStartedAt.Unix() {
+		log.Printf("Edit of message %d raced with its save, applying follow-up title update", messageID)
+	}
+
+	saved.Title = text
+	saved.editDate = editDate
+	taskID := saved.TaskID
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Only the title follows edits, the page body may have been changed in Notion since.
+	// Markers are stripped as on save, without changing the tags or date again.
+	client := h.notionFor(userID)
+	title, _, _, _ := splitTaskText(client, text)
+	if err := client.UpdateTaskTitle(ctx, taskID, title); err != nil {
+		return fmt.Errorf("failed to apply edit of message %d: %w", messageID, err)
+	}
+	return nil
+}
+
+// recordSavedMessage remembers the page created from a message. Callers must hold h.mu.
+func (h *Handler) recordSavedMessage(userID int64, messageID int, saved *savedMessage) {
+	h.savedMessageCache().Add(savedKey{userID, messageID}, saved)
+}
+
+// savedMessageCache returns the saved messages, creating the cache on first use.
+// Callers must hold h.mu.
+func (h *Handler) savedMessageCache() *lru.Cache[savedKey, *savedMessage] {
+	if h.savedMessages == nil {
+		h.savedMessages = lru.New[savedKey, *savedMessage](savedMessagesCap, savedMessageTTL)
+		lru.Register("bot.saved_messages", h.savedMessages)
+	}
+	return h.savedMessages
+}
@@ -0,0 +1,247 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// notionRequest is a single request received by fakeNotionAPI
+type notionRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// fakeNotionAPI is an http.RoundTripper standing in for the Notion API
+type fakeNotionAPI struct {
+	mu       sync.Mutex
+	requests []notionRequest
+	onCreate func() // Runs while a page creation is in flight
+	results  string // Pages returned by database queries, as a JSON array
+	schema   string // Database properties as a JSON object, only a title by default
+	page     string // Properties of fetched pages as a JSON object, none by default
+	users    string // Users of the workspace as a JSON array, none by default
+}
+
+func (f *fakeNotionAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, notionRequest{Method: req.Method, Path: req.URL.Path, Body: body})
+	onCreate := f.onCreate
+	results := f.results
+	schema := f.schema
+	page := f.page
+	users := f.users
+	f.mu.Unlock()
+
+	response := `{"object": "page", "id": "page-1", "properties": {}}`
+	switch {
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/databases/"):
+		if schema == "" {
+			schema = `{"Name": {"id": "title", "type": "title", "title": {}}}`
+		}
+		response = `{"object": "database", "id": "tasks-db", "properties": ` + schema + `}`
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/query"):
+		if results == "" {
+			results = "[]"
+		}
+		response = `{"object": "list", "results": ` + results + `, "has_more": false, "next_cursor": null}`
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/pages/") && page != "":
+		response = `{"object": "page", "id": "page-1", "properties": ` + page + `}`
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/users":
+		if users == "" {
+			users = "[]"
+		}
+		response = `{"object": "list", "results": ` + users + `, "has_more": false, "next_cursor": null}`
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/pages" && onCreate != nil:
+		onCreate()
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+// titles returns the titles sent in requests matching the method and path
+func (f *fakeNotionAPI) titles(t *testing.T, method, path string) []string {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []string
+	for _, r := range f.requests {
+		if r.Method != method || r.Path != path {
+			continue
+		}
+		var body struct {
+			Properties map[string]struct {
+				Title []struct {
+					Text struct {
+						Content string `json:"content"`
+					} `json:"text"`
+				} `json:"title"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatalf("Invalid request body %s: %v", r.Body, err)
+		}
+		if title := body.Properties["Name"].Title; len(title) > 0 {
+			result = append(result, title[0].Text.Content)
+		}
+	}
+	return result
+}
+
+// newEditTestHandler creates a handler backed by fake Telegram and Notion APIs
+func newEditTestHandler(t *testing.T) (*Handler, *fakeNotionAPI) {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+
+	_, botAPI := newFakeTelegram(t)
+	fake := &fakeNotionAPI{}
+	handler := &Handler{
+		bot:          botAPI,
+		notion:       notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+		pendingTasks: make(map[int64]map[int]*PendingTask),
+	}
+	return handler, fake
+}
+
+func testMessage(text string, editDate int) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: 123,
+		From:      &tgbotapi.User{ID: 456},
+		Chat:      &tgbotapi.Chat{ID: 789},
+		Text:      text,
+		EditDate:  editDate,
+	}
+}
+
+func thumbsUp() *MessageReactionUpdate {
+	return &MessageReactionUpdate{
+		Chat:        ChatInfo{ID: 789},
+		MessageID:   123,
+		User:        UserInfo{ID: 456},
+		NewReaction: []ReactionType{{Type: "emoji", Emoji: "👍"}},
+	}
+}
+
+func TestEditBeforeSaveReplacesPendingText(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleEditedMessage(testMessage("Buy oat milk", int(time.Now().Unix()))); err != nil {
+		t.Fatalf("HandleEditedMessage failed: %v", err)
+	}
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "Buy oat milk" {
+		t.Errorf("Expected page created with the edited text, got %v", created)
+	}
+	if updates := fake.titles(t, http.MethodPatch, "/v1/pages/page-1"); len(updates) != 0 {
+		t.Errorf("No follow-up update expected, got %v", updates)
+	}
+}
+
+func TestEditDuringSaveTriggersFollowUpUpdate(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+
+	// The edit lands after the save read the text but before the page exists
+	fake.onCreate = func() {
+		fake.mu.Lock()
+		fake.onCreate = nil
+		fake.mu.Unlock()
+		if err := handler.HandleEditedMessage(testMessage("Buy oat milk", int(time.Now().Unix()))); err != nil {
+			t.Errorf("HandleEditedMessage failed: %v", err)
+		}
+	}
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "Buy milk" {
+		t.Errorf("Expected page created with the original text, got %v", created)
+	}
+	if updates := fake.titles(t, http.MethodPatch, "/v1/pages/page-1"); len(updates) != 1 || updates[0] != "Buy oat milk" {
+		t.Errorf("Expected follow-up title update, got %v", updates)
+	}
+}
+
+func TestEditAfterSaveUpdatesTitle(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	editDate := int(time.Now().Unix()) + 60
+	if err := handler.HandleEditedMessage(testMessage("Buy oat milk", editDate)); err != nil {
+		t.Fatalf("HandleEditedMessage failed: %v", err)
+	}
+	// An older edit delivered late must not revert the title
+	if err := handler.HandleEditedMessage(testMessage("Buy soy milk", editDate-30)); err != nil {
+		t.Fatalf("HandleEditedMessage failed: %v", err)
+	}
+
+	if updates := fake.titles(t, http.MethodPatch, "/v1/pages/page-1"); len(updates) != 1 || updates[0] != "Buy oat milk" {
+		t.Errorf("Expected a single title update to the latest edit, got %v", updates)
+	}
+	if pending := handler.pendingTasks[456][123]; pending != nil {
+		t.Error("Edits after the save must not create a new pending task")
+	}
+}
+
+func TestMultiLineMessageSavesBody(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+
+	handler.storePendingTask(testMessage("Groceries\nmilk\neggs", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "Groceries" {
+		t.Errorf("Expected the first line as the title, got %v", created)
+	}
+	fake.mu.Lock()
+	body := string(fake.requests[len(fake.requests)-1].Body)
+	fake.mu.Unlock()
+	if !strings.Contains(body, `"paragraph"`) || !strings.Contains(body, "eggs") {
+		t.Errorf("Expected the other lines in the page body, got %s", body)
+	}
+}
+
+func TestSavedMessagesStayBounded(t *testing.T) {
+	handler, _ := newEditTestHandler(t)
+
+	handler.mu.Lock()
+	for i := 0; i < 100000; i++ {
+		handler.recordSavedMessage(456, i, &savedMessage{TaskID: "page-1", SaveStartedAt: time.Now()})
+	}
+	handler.mu.Unlock()
+
+	if n := handler.savedMessages.Len(); n != savedMessagesCap {
+		t.Errorf("Expected %d saved messages, got %d", savedMessagesCap, n)
+	}
+}
@@ -0,0 +1,14 @@
+package bot
+
+import "strings"
+
+// normalizeEmoji strips variation selector 16 and skin-tone modifiers, so "👍🏻" and "👍️"
+// compare equal to "👍". Telegram only accepts the base emoji in setMessageReaction.
+func normalizeEmoji(emoji string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\uFE0F' || (r >= 0x1F3FB && r <= 0x1F3FF) {
+			return -1
+		}
+		return r
+	}, emoji)
+}
@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestNormalizeEmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		emoji   string
+		matches bool
+	}{
+		{"base", "👍", true},
+		{"light skin tone", "👍🏻", true},
+		{"medium-light skin tone", "👍🏼", true},
+		{"medium skin tone", "👍🏽", true},
+		{"medium-dark skin tone", "👍🏾", true},
+		{"dark skin tone", "👍🏿", true},
+		{"variation selector 16", "👍\uFE0F", true},
+		{"thumbs down", "👎", false},
+		{"thumbs down with skin tone", "👎🏻", false},
+		{"heart", "❤\uFE0F", false},
+		{"two thumbs", "👍👍", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeEmoji(tt.emoji) == "👍"; got != tt.matches {
+				t.Errorf("normalizeEmoji(%q) matching 👍 = %v, expected %v", tt.emoji, got, tt.matches)
+			}
+		})
+	}
+
+	// Variation selectors are stripped from other emoji too
+	if got := normalizeEmoji("❤\uFE0F"); got != "❤" {
+		t.Errorf("Expected the bare heart, got %q", got)
+	}
+}
+
+func TestSkinToneThumbsUpSavesTask(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	reaction := thumbsUp()
+	reaction.NewReaction = []ReactionType{{Type: "emoji", Emoji: "👍🏽"}}
+	if err := handler.HandleMessageReaction(context.Background(), reaction); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 {
+		t.Errorf("Expected the task to be saved, got %v", titles)
+	}
+}
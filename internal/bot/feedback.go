@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+)
+
+// Save states shown on a message
+const (
+	feedbackPending = "🤔"
+	feedbackSaving  = "✍️"
+	feedbackSaved   = "👍"
+	feedbackFailed  = "😢"
+	feedbackJournal = "📔"
+	feedbackNoted   = "📎"
+)
+
+const (
+	// reactionFailureThreshold is how many consecutive reaction failures switch a chat to replies
+	reactionFailureThreshold = 3
+	// reactionProbeInterval is how often a chat in replies mode retries reactions
+	reactionProbeInterval = 24 * time.Hour
+	// savedNoteTTL is how long the "saved" reply stays visible in replies mode
+	savedNoteTTL = time.Minute
+	// feedbackChatsCap bounds how many chats keep their feedback state in memory, evicted
+	// chats reload their mode from the database
+	feedbackChatsCap = 1000
+	// feedbackNotesCap bounds the tracked reply notes per chat
+	feedbackNotesCap = 100
+)
+
+// feedbackNoteTexts are the replies sent instead of reactions in replies mode
+var feedbackNoteTexts = map[string]string{
+	feedbackPending: "🤔 pending — react 👍 or reply /save",
+	feedbackSaving:  "✍️ saving…",
+	feedbackSaved:   "👍 saved",
+	feedbackFailed:  "😢 could not save",
+	feedbackJournal: "📔 added to today's journal",
+	feedbackNoted:   "📎 added to the task",
+}
+
+// chatFeedback tracks how save progress is shown in a chat
+type chatFeedback struct {
+	mode     string               // database.FeedbackModeReactions or database.FeedbackModeReplies
+	failures int                  // Consecutive setMessageReaction failures
+	probedAt time.Time            // When reactions were last tried in replies mode
+	notes    *lru.Cache[int, int] // Reply note message ID by task message ID
+}
+
+// showFeedback shows a save state on a message. Reactions are used unless they keep failing
+// in the chat, in which case a small reply note is sent and edited instead. Chats in replies
+// mode retry reactions once a day and switch back when they work again.
+func (h *Handler) showFeedback(chatID int64, messageID int, state string) {
+	h.feedbackMu.Lock()
+	chat := h.chatFeedback(chatID)
+	useReactions := chat.mode == database.FeedbackModeReactions
+	probe := !useReactions && time.Since(chat.probedAt) >= reactionProbeInterval
+	h.feedbackMu.Unlock()
+
+	if useReactions || probe {
+		err := h.setMessageReaction(chatID, messageID, state)
+
+		h.feedbackMu.Lock()
+		if err == nil {
+			chat.failures = 0
+			if probe {
+				log.Printf("Reactions work again in chat %d, switching back from replies", chatID)
+				h.setFeedbackMode(chatID, chat, database.FeedbackModeReactions)
+			}
+			h.feedbackMu.Unlock()
+			return
+		}
+
+		log.Printf("Warning: Failed to set %s reaction: %v", state, err)
+		if probe {
+			// Still failing, try again tomorrow
+			h.setFeedbackMode(chatID, chat, database.FeedbackModeReplies)
+		} else {
+			chat.failures++
+			if chat.failures >= reactionFailureThreshold {
+				log.Printf("Reactions failed %d times in chat %d, switching to reply feedback", chat.failures, chatID)
+				h.setFeedbackMode(chatID, chat, database.FeedbackModeReplies)
+			}
+		}
+		useReplies := chat.mode == database.FeedbackModeReplies
+		h.feedbackMu.Unlock()
+
+		if !useReplies {
+			return
+		}
+	}
+
+	h.sendFeedbackNote(chatID, messageID, state)
+}
+
+// clearFeedback removes the save state shown on a message, the reaction or the reply
+// note, once it no longer waits for a save
+func (h *Handler) clearFeedback(chatID int64, messageID int) {
+	h.feedbackMu.Lock()
+	chat := h.chatFeedback(chatID)
+	noteID, hasNote := chat.notes.Get(messageID)
+	chat.notes.Remove(messageID)
+	useReactions := chat.mode == database.FeedbackModeReactions
+	h.feedbackMu.Unlock()
+
+	if hasNote {
+		if _, err := h.bot.Request(tgbotapi.NewDeleteMessage(chatID, noteID)); err != nil {
+			log.Printf("Warning: Failed to delete feedback note %d: %v", noteID, err)
+		}
+	}
+	if useReactions {
+		if err := h.clearMessageReaction(chatID, messageID); err != nil {
+			log.Printf("Warning: Failed to clear the reaction of message %d: %v", messageID, err)
+		}
+	}
+}
+
+// chatFeedback returns the feedback state of a chat, loading a persisted mode on first use.
+// Callers must hold h.feedbackMu.
+func (h *Handler) chatFeedback(chatID int64) *chatFeedback {
+	if h.feedbackChats == nil {
+		h.feedbackChats = lru.New[int64, *chatFeedback](feedbackChatsCap, 0)
+		lru.Register("bot.feedback_chats", h.feedbackChats)
+	}
+	if chat, ok := h.feedbackChats.Get(chatID); ok {
+		return chat
+	}
+
+	chat := &chatFeedback{mode: database.FeedbackModeReactions, notes: lru.New[int, int](feedbackNotesCap, 0)}
+	if h.db != nil {
+		mode, probedAt, err := h.db.GetChatFeedbackMode(chatID)
+		if err != nil {
+			log.Printf("Warning: Could not load feedback mode for chat %d: %v", chatID, err)
+		} else if mode != "" {
+			chat.mode = mode
+			chat.probedAt = probedAt
+		}
+	}
+
+	h.feedbackChats.Add(chatID, chat)
+	return chat
+}
+
+// setFeedbackMode switches the mode of a chat, resets the probe timer and persists the mode.
+// Callers must hold h.feedbackMu.
+func (h *Handler) setFeedbackMode(chatID int64, chat *chatFeedback, mode string) {
+	chat.mode = mode
+	chat.failures = 0
+	chat.probedAt = time.Now()
+
+	if h.db != nil {
+		if err := h.db.SetChatFeedbackMode(chatID, mode, chat.probedAt); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// sendFeedbackNote shows a save state as a reply to the task message, editing the previous
+// note for the same message. The note is removed shortly after a successful save.
+func (h *Handler) sendFeedbackNote(chatID int64, messageID int, state string) {
+	text, ok := feedbackNoteTexts[state]
+	if !ok {
+		text = state
+	}
+
+	h.feedbackMu.Lock()
+	chat := h.chatFeedback(chatID)
+	noteID, hasNote := chat.notes.Get(messageID)
+	h.feedbackMu.Unlock()
+
+	if hasNote {
+		if _, err := h.bot.Request(tgbotapi.NewEditMessageText(chatID, noteID, text)); err != nil {
+			log.Printf("Warning: Failed to edit feedback note: %v", err)
+		}
+	} else {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ReplyToMessageID = messageID
+		msg.DisableNotification = true
+		sent, err := h.bot.Send(msg)
+		if err != nil {
+			log.Printf("Warning: Failed to send feedback note: %v", err)
+			return
+		}
+		noteID = sent.MessageID
+	}
+
+	h.feedbackMu.Lock()
+	switch state {
+	case feedbackSaved, feedbackFailed:
+		// Final states, the note is no longer tracked
+		chat.notes.Remove(messageID)
+	default:
+		chat.notes.Add(messageID, noteID)
+	}
+	h.feedbackMu.Unlock()
+
+	if state == feedbackSaved {
+		h.afterFunc(savedNoteTTL, func() {
+			if _, err := h.bot.Request(tgbotapi.NewDeleteMessage(chatID, noteID)); err != nil {
+				log.Printf("Warning: Failed to delete feedback note %d: %v", noteID, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// newFeedbackTestHandler creates a handler with a fake Telegram API and a temporary database.
+// Scheduled work is collected instead of run.
+func newFeedbackTestHandler(t *testing.T) (*Handler, *fakeTelegram, *[]func()) {
+	t.Helper()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fake, botAPI := newFakeTelegram(t)
+	scheduled := &[]func(){}
+	handler := &Handler{
+		bot: botAPI,
+		db:  db,
+		afterFunc: func(d time.Duration, f func()) {
+			*scheduled = append(*scheduled, f)
+		},
+	}
+	return handler, fake, scheduled
+}
+
+func TestFeedbackSwitchesToRepliesAfterRepeatedFailures(t *testing.T) {
+	handler, fake, _ := newFeedbackTestHandler(t)
+	fake.setFailing("setMessageReaction", true)
+
+	for messageID := 1; messageID < reactionFailureThreshold; messageID++ {
+		handler.showFeedback(789, messageID, feedbackPending)
+	}
+	if len(fake.callsTo("sendMessage")) != 0 {
+		t.Fatal("No fallback expected before the failure threshold")
+	}
+
+	// The third failure flips the chat and this message gets a note right away
+	handler.showFeedback(789, 3, feedbackPending)
+	notes := fake.callsTo("sendMessage")
+	if len(notes) != 1 {
+		t.Fatalf("Expected 1 fallback note, got %d", len(notes))
+	}
+	if notes[0].Params.Get("reply_to_message_id") != "3" {
+		t.Errorf("Note should reply to the task message, got %s", notes[0].Params.Get("reply_to_message_id"))
+	}
+
+	mode, _, err := handler.db.GetChatFeedbackMode(789)
+	if err != nil {
+		t.Fatalf("Failed to load feedback mode: %v", err)
+	}
+	if mode != database.FeedbackModeReplies {
+		t.Errorf("Expected persisted replies mode, got %q", mode)
+	}
+
+	// Reactions aren't attempted again until the re-probe is due
+	handler.showFeedback(789, 4, feedbackPending)
+	if n := len(fake.callsTo("setMessageReaction")); n != reactionFailureThreshold {
+		t.Errorf("Expected %d reaction attempts, got %d", reactionFailureThreshold, n)
+	}
+
+	// Other chats keep using reactions, and a restarted handler remembers the mode
+	restarted := &Handler{bot: handler.bot, db: handler.db, afterFunc: handler.afterFunc}
+	if chat := restarted.chatFeedback(789); chat.mode != database.FeedbackModeReplies {
+		t.Errorf("Expected mode to survive a restart, got %q", chat.mode)
+	}
+	if chat := restarted.chatFeedback(111); chat.mode != database.FeedbackModeReactions {
+		t.Errorf("Expected other chats to use reactions, got %q", chat.mode)
+	}
+}
+
+func TestFeedbackNotesInRepliesMode(t *testing.T) {
+	handler, fake, scheduled := newFeedbackTestHandler(t)
+	fake.setFailing("setMessageReaction", true)
+	if err := handler.db.SetChatFeedbackMode(789, database.FeedbackModeReplies, time.Now()); err != nil {
+		t.Fatalf("Failed to store feedback mode: %v", err)
+	}
+
+	handler.showFeedback(789, 123, feedbackPending)
+	handler.showFeedback(789, 123, feedbackSaving)
+	handler.showFeedback(789, 123, feedbackSaved)
+
+	sent := fake.callsTo("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected a single note per message, got %d", len(sent))
+	}
+	if sent[0].Params.Get("text") != feedbackNoteTexts[feedbackPending] {
+		t.Errorf("Unexpected pending note: %s", sent[0].Params.Get("text"))
+	}
+
+	edits := fake.callsTo("editMessageText")
+	if len(edits) != 2 {
+		t.Fatalf("Expected the note to be edited twice, got %d", len(edits))
+	}
+	if edits[1].Params.Get("text") != feedbackNoteTexts[feedbackSaved] || edits[1].Params.Get("message_id") != "1002" {
+		t.Errorf("Expected note 1002 edited to saved, got %s: %s", edits[1].Params.Get("message_id"), edits[1].Params.Get("text"))
+	}
+	if len(fake.callsTo("setMessageReaction")) != 0 {
+		t.Error("Reactions must not be attempted in replies mode before the re-probe")
+	}
+
+	// The saved note is removed later
+	if len(*scheduled) != 1 {
+		t.Fatalf("Expected note deletion to be scheduled, got %d", len(*scheduled))
+	}
+	(*scheduled)[0]()
+	deleted := fake.callsTo("deleteMessage")
+	if len(deleted) != 1 || deleted[0].Params.Get("message_id") != "1002" {
+		t.Errorf("Expected note 1002 to be deleted, got %v", deleted)
+	}
+}
+
+func TestFeedbackReprobeRecovers(t *testing.T) {
+	handler, fake, _ := newFeedbackTestHandler(t)
+	stale := time.Now().Add(-reactionProbeInterval - time.Hour)
+	if err := handler.db.SetChatFeedbackMode(789, database.FeedbackModeReplies, stale); err != nil {
+		t.Fatalf("Failed to store feedback mode: %v", err)
+	}
+
+	// A failed probe keeps replies mode and postpones the next probe
+	fake.setFailing("setMessageReaction", true)
+	handler.showFeedback(789, 1, feedbackPending)
+	if len(fake.callsTo("setMessageReaction")) != 1 || len(fake.callsTo("sendMessage")) != 1 {
+		t.Fatal("Expected a probe followed by a fallback note")
+	}
+	handler.showFeedback(789, 2, feedbackPending)
+	if len(fake.callsTo("setMessageReaction")) != 1 {
+		t.Fatal("Probe must not repeat within the interval")
+	}
+
+	// Once reactions work again the chat switches back
+	chat, _ := handler.feedbackChats.Get(789)
+	chat.probedAt = stale
+	fake.setFailing("setMessageReaction", false)
+	handler.showFeedback(789, 3, feedbackPending)
+
+	if len(fake.callsTo("sendMessage")) != 2 {
+		t.Error("No note expected after a successful probe")
+	}
+	mode, _, err := handler.db.GetChatFeedbackMode(789)
+	if err != nil {
+		t.Fatalf("Failed to load feedback mode: %v", err)
+	}
+	if mode != database.FeedbackModeReactions {
+		t.Errorf("Expected persisted reactions mode, got %q", mode)
+	}
+}
+
+func TestSaveCommandSavesRepliedMessage(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+	handler.afterFunc = func(time.Duration, func()) {}
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+
+	save := &tgbotapi.Message{
+		MessageID:      124,
+		From:           &tgbotapi.User{ID: 456},
+		Chat:           &tgbotapi.Chat{ID: 789},
+		Text:           "/save",
+		Entities:       []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
+		ReplyToMessage: testMessage("Buy milk", 0),
+	}
+	if err := handler.HandleMessage(save); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if created := fake.titles(t, "POST", "/v1/pages"); len(created) != 1 || created[0] != "Buy milk" {
+		t.Errorf("Expected the replied-to message to be saved, got %v", created)
+	}
+}
+
+func TestFeedbackChatsStayBounded(t *testing.T) {
+	handler := &Handler{}
+
+	handler.feedbackMu.Lock()
+	for i := 0; i < 100000; i++ {
+		chat := handler.chatFeedback(int64(i))
+		chat.notes.Add(i, i)
+	}
+	chat := handler.chatFeedback(1)
+	for i := 0; i < 100000; i++ {
+		chat.notes.Add(i, i)
+	}
+	handler.feedbackMu.Unlock()
+
+	if n := handler.feedbackChats.Len(); n != feedbackChatsCap {
+		t.Errorf("Expected %d chats, got %d", feedbackChatsCap, n)
+	}
+	if n := chat.notes.Len(); n != feedbackNotesCap {
+		t.Errorf("Expected %d notes, got %d", feedbackNotesCap, n)
+	}
+}
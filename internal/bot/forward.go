@@ -0,0 +1,119 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// sourceURLProperty is the url property of a database that gets the link a task came from
+const sourceURLProperty = "url"
+
+// messageText returns the text of a message, or the caption of a photo or other media
+func messageText(message *tgbotapi.Message) string {
+	if message.Text != "" {
+		return message.Text
+	}
+	return message.Caption
+}
+
+// forwardOrigin returns the title of the channel or group a message was forwarded from and
+// a link to the original post. Both are empty for messages that weren't forwarded from a
+// chat, the link is empty when Telegram didn't say which post it was.
+func forwardOrigin(message *tgbotapi.Message) (title, link string) {
+	chat := message.ForwardFromChat
+	if chat == nil {
+		return "", ""
+	}
+	title = chat.Title
+	if message.ForwardFromMessageID == 0 {
+		return title, ""
+	}
+
+	return title, postLink(chat, message.ForwardFromMessageID)
+}
+
+// postLink returns the t.me link of a message in a channel or supergroup, empty for other
+// chats
+func postLink(chat *tgbotapi.Chat, messageID int) string {
+	if chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, messageID)
+	}
+	// Private channels and supergroups are linked by their ID without the -100 prefix
+	if id := -chat.ID - 1000000000000; id > 0 {
+		return fmt.Sprintf("https://t.me/c/%d/%d", id, messageID)
+	}
+	return ""
+}
+
+// firstLink returns the first URL in a text, either written out or behind a text link.
+// Entity offsets count UTF-16 code units.
+func firstLink(text string, entities []tgbotapi.MessageEntity) string {
+	var units []uint16
+	for _, entity := range entities {
+		switch entity.Type {
+		case "text_link":
+			if entity.URL != "" {
+				return entity.URL
+			}
+		case "url":
+			if units == nil {
+				units = utf16.Encode([]rune(text))
+			}
+			if entity.Offset < 0 || entity.Length <= 0 || entity.Offset+entity.Length > len(units) {
+				continue
+			}
+			return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+		}
+	}
+	return ""
+}
+
+// messageSource returns the text to save for a message, prefixed with the channel it was
+// forwarded from, and the link it came from: the original post of a forward, or else the
+// first link in the message
+func messageSource(message *tgbotapi.Message) (text, link string) {
+	text = messageText(message)
+	title, link := forwardOrigin(message)
+	if title != "" {
+		text = title + ": " + text
+	}
+	if link == "" {
+		entities := message.Entities
+		if message.Text == "" {
+			entities = message.CaptionEntities
+		}
+		link = firstLink(messageText(message), entities)
+	}
+	return text, link
+}
+
+// sourceURLProperties returns the properties recording the link a task came from, nil when
+// there is none or the database has no url property for it
+func (h *Handler) sourceURLProperties(ctx context.Context, client *notion.Client, dbType, link string) map[string]interface{} {
+	return urlProperties(ctx, client, dbType, sourceURLProperty, link)
+}
+
+// urlProperties returns the property setting link in the url property of the given name,
+// ignoring case, nil when there is no link or no such property
+func urlProperties(ctx context.Context, client *notion.Client, dbType, name, link string) map[string]interface{} {
+	if link == "" {
+		return nil
+	}
+	props, err := client.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		log.Printf("Warning: Could not check the %s database for a url property: %v", dbType, err)
+		return nil
+	}
+	for key, prop := range props {
+		if strings.EqualFold(key, name) && prop.GetType() == "url" {
+			return map[string]interface{}{key: link}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func channelForward() *tgbotapi.Message {
+	message := testMessage("Go 1.22 is released", 0)
+	message.ForwardFromChat = &tgbotapi.Chat{ID: -1001234567890, Type: "channel", Title: "Go Weekly", UserName: "golangweekly"}
+	message.ForwardFromMessageID = 42
+	return message
+}
+
+func TestMessageSource(t *testing.T) {
+	privateForward := channelForward()
+	privateForward.ForwardFromChat.UserName = ""
+
+	// The offset counts the emoji as two UTF-16 code units
+	bareURL := testMessage("📖 Read https://go.dev/blog later", 0)
+	bareURL.Entities = []tgbotapi.MessageEntity{{Type: "url", Offset: 8, Length: 19}}
+
+	textLink := testMessage("Read the release notes", 0)
+	textLink.Entities = []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 4}, {Type: "text_link", Offset: 9, Length: 13, URL: "https://go.dev/doc/go1.22"}}
+
+	photo := testMessage("", 0)
+	photo.Photo = []tgbotapi.PhotoSize{{FileID: "photo-1", Width: 800, Height: 600}}
+	photo.Caption = "Whiteboard from https://example.com/retro"
+	photo.CaptionEntities = []tgbotapi.MessageEntity{{Type: "url", Offset: 16, Length: 25}}
+
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		text    string
+		link    string
+	}{
+		{"channel forward", channelForward(), "Go Weekly: Go 1.22 is released", "https://t.me/golangweekly/42"},
+		{"private channel forward", privateForward, "Go Weekly: Go 1.22 is released", "https://t.me/c/1234567890/42"},
+		{"bare url", bareURL, "📖 Read https://go.dev/blog later", "https://go.dev/blog"},
+		{"text link", textLink, "Read the release notes", "https://go.dev/doc/go1.22"},
+		{"photo with caption", photo, "Whiteboard from https://example.com/retro", "https://example.com/retro"},
+		{"plain text", testMessage("Buy milk", 0), "Buy milk", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, link := messageSource(tt.message)
+			if text != tt.text || link != tt.link {
+				t.Errorf("Expected %q with link %q, got %q with %q", tt.text, tt.link, text, link)
+			}
+		})
+	}
+}
+
+// createdURL returns the url property key of the first page created through the fake
+func createdURL(t *testing.T, fake *fakeNotionAPI, key string) (string, bool) {
+	t.Helper()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	for _, r := range fake.requests {
+		if r.Method != http.MethodPost || r.Path != "/v1/pages" {
+			continue
+		}
+		var body struct {
+			Properties map[string]struct {
+				URL string `json:"url"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatalf("Invalid request body %s: %v", r.Body, err)
+		}
+		prop, ok := body.Properties[key]
+		return prop.URL, ok
+	}
+	t.Fatal("No page was created")
+	return "", false
+}
+
+func TestForwardSavesSourceURL(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+	fake.schema = `{"Name": {"id": "title", "type": "title", "title": {}}, "URL": {"id": "u", "type": "url", "url": {}}}`
+
+	handler.storePendingTask(channelForward())
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 || titles[0] != "Go Weekly: Go 1.22 is released" {
+		t.Errorf("Expected the title to name the channel, got %v", titles)
+	}
+	if link, ok := createdURL(t, fake, "URL"); !ok || link != "https://t.me/golangweekly/42" {
+		t.Errorf("Expected the link to the post, got %q", link)
+	}
+}
+
+func TestSourceURLNeedsAURLProperty(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+	// A text property named url isn't filled with the link
+	fake.schema = `{"Name": {"id": "title", "type": "title", "title": {}}, "URL": {"id": "u", "type": "rich_text", "rich_text": {}}}`
+
+	handler.storePendingTask(channelForward())
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if _, ok := createdURL(t, fake, "URL"); ok {
+		t.Error("Expected no url property to be set")
+	}
+}
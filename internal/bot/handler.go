@@ -1,70 +1,158 @@
 package bot
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+	"github.com/numero_quadro/notion-mini-app/internal/alerts"
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
 	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/share"
+	"github.com/numero_quadro/notion-mini-app/internal/tenant"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+	"github.com/numero_quadro/notion-mini-app/internal/uploads"
+	"github.com/numero_quadro/notion-mini-app/internal/usage"
 )
 
 // Store pending tasks waiting for reaction
 type PendingTask struct {
-	MessageID int
-	Text      string
+	ChatID     int64
+	MessageID  int
+	Text       string
+	SourceChat string // notion.SourceChatValue of the chat the message came from
+	SourceURL  string // Link to the forwarded post or the first link in the message
+	// Link opening the message itself in Telegram, see messageLink
+	MessageLink string
+
+	// The bot's "📝 Transcribed" reply of a voice note or video, answering it corrects Text
+	TranscriptMessageID int
+	// What the message was when it wasn't typed, see messageOrigin, and when it arrived
+	Origin     string
+	ReceivedAt time.Time
+	// The Telegram file of the photo attached to the task, see messagePhoto
+	PhotoFileID string
+
+	saving          bool      // A save has claimed the task
+	saveRequestedAt time.Time // When the reaction or /save asked for the save, zero for recovered saves
+	saveStartedAt   time.Time // When the save last read Text
+	editDate        int       // edit_date of the last applied edit
 }
 
 type Handler struct {
-	bot              *tgbotapi.BotAPI
-	notion           *notion.Client
-	gemini           *gemini.Client
-	scheduler        Scheduler
-	authorizedUserID int64                          // Only this user can interact with the bot
-	pendingTasks     map[int64]map[int]*PendingTask // Track pending tasks by user ID and message ID
+	bot           *tgbotapi.BotAPI
+	notion        *notion.Client
+	tagger        llm.Tagger        // nil when AI is disabled (LLM_PROVIDER=none)
+	transcriber   llm.Transcriber   // nil when AI is disabled
+	dates         llm.DateExtractor // nil when the provider can't resolve dates
+	splitter      llm.Splitter      // nil when the provider can't split messages into tasks
+	scheduler     Scheduler
+	users         *auth.Users                         // Who can use the bot's workspace, nil allows anyone
+	pendingTasks  map[int64]map[int]*PendingTask      // Track pending tasks by user ID and message ID
+	savedMessages *lru.Cache[savedKey, *savedMessage] // Tasks created from messages, for applying later edits
+	doneChoices   map[int64]*doneChoice               // Matches of /done waiting for a number, by user ID
+	undos         map[undoKey]time.Time               // Deleted tasks that can be restored until then
+	tagPlans      map[int64]*tagPlan                  // Tags proposed by /tags preview, by user ID
+	tagRuns       map[int64]*tagRun                   // Progress of the last /tags run, by user ID
+	mu            sync.Mutex                          // Guards pendingTasks, savedMessages, doneChoices, undos, tagPlans and tagRuns
+	db            *database.DB                        // Optional local database, nil when unavailable
+
+	feedbackMu    sync.Mutex
+	feedbackChats *lru.Cache[int64, *chatFeedback] // How save progress is shown, per chat
+
+	confirmationCards bool                        // Send a confirmation card after each save (CONFIRMATION_CARDS)
+	miniAppShortName  string                      // Mini app short name used in deep links (MINI_APP_SHORT_NAME)
+	sourceChatProp    string                      // Property recording the chat a task came from (SOURCE_CHAT_PROPERTY)
+	sourceComments    bool                        // Comment where voice notes and forwards came from on their tasks (ATTACH_SOURCE_COMMENT)
+	telegramLinkProp  string                      // Url property linking a task back to its message (TELEGRAM_LINK_PROPERTY)
+	afterFunc         func(time.Duration, func()) // Schedules delayed work such as card deletion
+
+	saves        *saveQueue    // Saves waiting for RunSaveWorkers, nil saves in the update's goroutine
+	retryBackoff time.Duration // Wait before the second save attempt, growing with each attempt
+
+	alerter alerts.Alerter // Reports writes RunOutbox gave up on, nil only logs them
+
+	maxAudioBytes  int64           // Largest file transcribed (MAX_AUDIO_BYTES), 0 uses the default
+	transcriptions *transcriptions // Transcriptions running until RunTranscriptions stops them, nil transcribes in the update's goroutine
+
+	uploads     *uploads.Store                      // Where photos are stored for their pages to link, nil saves captions only
+	fileURLFunc func(fileID string) (string, error) // Download URL of a Telegram file, nil asks the Bot API
+
+	maxPendingPerUser int           // Pending tasks kept per user (MAX_PENDING_PER_USER), 0 uses the default
+	pendingTaskTTL    time.Duration // How long a pending task waits for its 👍 (PENDING_TASK_TTL), 0 uses the default
+	pendingEvicted    int           // Pending tasks dropped over the cap since start, guarded by mu
+	pendingExpired    int           // Pending tasks dropped past their TTL since start, guarded by mu
+
+	// Append messages tagged journal to today's journal page instead (JOURNAL_AUTO_APPEND)
+	journalAutoAppend bool
+
+	templates []TaskTemplate // Task sets /template creates (TASK_TEMPLATES_FILE)
+
+	// Database type by normalized emoji (REACTION_MAP), nil saves 👍 as tasks
+	reactions map[string]string
+
+	tenants    *tenant.Registry       // Users with their own workspace, nil when single-user
+	connecting map[int64]*connectFlow // /connect in progress by user ID, guarded by mu
 }
 
 // Scheduler interface to avoid circular dependency
 type Scheduler interface {
 	RunManualCheck()
+	RunReconcile()
+	Timezone() *time.Location
 }
 
-func NewHandler(bot *tgbotapi.BotAPI, notionClient *notion.Client, geminiClient *gemini.Client) *Handler {
-	// Get authorized user ID from environment variable
-	authorizedUserIDStr := os.Getenv("AUTHORIZED_USER_ID")
-	var authorizedUserID int64 = 0
+// NewHandler creates a handler. provider may be nil, which disables tagging and transcription.
+// users are the users allowed to use the bot, nil allows anyone.
+func NewHandler(bot *tgbotapi.BotAPI, notionClient *notion.Client, provider llm.Provider, db *database.DB, users *auth.Users) *Handler {
+	handler := &Handler{
+		bot:               bot,
+		notion:            notionClient,
+		scheduler:         nil, // Set later via SetScheduler
+		users:             users,
+		pendingTasks:      make(map[int64]map[int]*PendingTask),
+		db:                db,
+		confirmationCards: os.Getenv("CONFIRMATION_CARDS") == "true",
+		miniAppShortName:  os.Getenv("MINI_APP_SHORT_NAME"),
+		sourceChatProp:    os.Getenv("SOURCE_CHAT_PROPERTY"),
+		sourceComments:    os.Getenv("ATTACH_SOURCE_COMMENT") != "false",
+		telegramLinkProp:  telegramLinkPropertyFromEnv(),
+		afterFunc: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+		saves:        newSaveQueue(),
+		retryBackoff: 2 * time.Second,
+		reactions:    reactionMapFromEnv(os.Getenv("REACTION_MAP"), notionClient.HasDatabase),
 
-	if authorizedUserIDStr != "" {
-		id, err := strconv.ParseInt(authorizedUserIDStr, 10, 64)
-		if err != nil {
-			log.Printf("Warning: Invalid AUTHORIZED_USER_ID: %v", err)
-		} else {
-			authorizedUserID = id
-		}
-	}
+		maxAudioBytes:  maxAudioBytesFromEnv(),
+		transcriptions: newTranscriptions(),
+		uploads:        uploads.FromEnv(miniAppURL()),
 
-	if authorizedUserID == 0 {
-		log.Printf("Warning: No authorized user ID set, bot will be accessible to anyone")
-	} else {
-		log.Printf("Bot restricted to user ID: %d", authorizedUserID)
+		maxPendingPerUser: maxPendingPerUserFromEnv(),
+		pendingTaskTTL:    pendingTTLFromEnv(),
+
+		journalAutoAppend: journalAutoAppendFromEnv(notionClient.HasDatabase),
 	}
 
-	return &Handler{
-		bot:              bot,
-		notion:           notionClient,
-		gemini:           geminiClient,
-		scheduler:        nil, // Set later via SetScheduler
-		authorizedUserID: authorizedUserID,
-		pendingTasks:     make(map[int64]map[int]*PendingTask),
+	if provider != nil {
+		handler.tagger = provider
+		handler.transcriber = provider
+		handler.dates, _ = provider.(llm.DateExtractor)
+		handler.splitter, _ = provider.(llm.Splitter)
 	}
+
+	// Messages that were waiting for a 👍 when the bot stopped
+	handler.restorePendingTasks()
+	return handler
 }
 
 // SetScheduler sets the scheduler after initialization
@@ -72,15 +160,18 @@ func (h *Handler) SetScheduler(scheduler Scheduler) {
 	h.scheduler = scheduler
 }
 
+// isAuthorized reports whether a user can use the bot, in the bot's workspace or the one
+// they connected
 func (h *Handler) isAuthorized(userID int64) bool {
-	// If no authorized user is set, allow anyone
-	if h.authorizedUserID == 0 {
-		return true
-	}
-	return userID == h.authorizedUserID
+	return h.isOwner(userID) || h.tenants.IsConnected(userID)
 }
 
 func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
+	// Users who may connect a workspace aren't authorized until they did
+	if handled, err := h.handleConnect(message); handled {
+		return err
+	}
+
 	// Check if user is authorized
 	if !h.isAuthorized(message.From.ID) {
 		// Only respond to /start, silently ignore other messages from unauthorized users
@@ -91,93 +182,140 @@ func (h *Handler) HandleMessage(message *tgbotapi.Message) error {
 		return nil
 	}
 
-	// If it's a voice or audio message, transcribe it first
-	if (message.Voice != nil || message.Audio != nil) && h.gemini != nil {
-		var fileID string
-		var mimeType string
-		if message.Voice != nil {
-			fileID = message.Voice.FileID
-			mimeType = message.Voice.MimeType
-			if mimeType == "" {
-				mimeType = "audio/ogg"
-			}
-		} else if message.Audio != nil {
-			fileID = message.Audio.FileID
-			mimeType = message.Audio.MimeType
-			if mimeType == "" {
-				mimeType = "audio/mpeg"
-			}
-		}
-
-		// Download file from Telegram
-		url, err := h.bot.GetFileDirectURL(fileID)
-		if err != nil {
-			log.Printf("Failed to get file URL: %v", err)
-			// Gracefully continue without storing
-			msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Could not access audio file.")
-			_, _ = h.bot.Send(msg)
-			return nil
-		}
-		resp, err := http.Get(url)
-		if err != nil {
-			log.Printf("Failed to download audio: %v", err)
-			msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Download failed for audio.")
-			_, _ = h.bot.Send(msg)
-			return nil
-		}
-		defer resp.Body.Close()
-		audioBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Printf("Failed to read audio bytes: %v", err)
-			msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Could not read audio data.")
-			_, _ = h.bot.Send(msg)
+	// Voice notes, audio and videos are transcribed into the text to store
+	if media, ok := transcribableMedia(message); ok {
+		if h.transcriber == nil {
+			h.reply(message.Chat.ID, fmt.Sprintf("❌ Transcribing a %s needs an AI provider, none is configured.", media.kind))
 			return nil
 		}
+		return h.handleMedia(message, media)
+	}
 
-		// Transcribe via Gemini
-		transcript, err := h.gemini.TranscribeAudio(audioBytes, mimeType)
-		if err != nil {
-			log.Printf("Gemini transcription failed: %v", err)
-			msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Transcription failed.")
-			_, _ = h.bot.Send(msg)
+	// Photos are saved with their caption as the title
+	if len(message.Photo) > 0 {
+		return h.handlePhoto(message)
+	}
+
+	// A reply to a transcription corrects the text it will be saved with
+	if corrected, err := h.handleTranscriptCorrection(message); corrected {
+		return err
+	}
+
+	// A reply to a saved message adds detail to its task
+	if appended, err := h.handleAppendNote(message); appended {
+		return err
+	}
+
+	// /save as a reply saves the replied-to message, for chats without reactions
+	if message.IsCommand() && message.Command() == "save" && message.ReplyToMessage != nil {
+		return h.enqueueSave(context.Background(), message.Chat.ID, message.From.ID, message.ReplyToMessage.MessageID, "tasks")
+	}
+
+	// /split as a reply saves one task per entry of the replied-to message
+	if message.IsCommand() && message.Command() == "split" && message.ReplyToMessage != nil {
+		return h.enqueueSplit(context.Background(), message.Chat.ID, message.From.ID, message.ReplyToMessage.MessageID)
+	}
+
+	if message.IsCommand() && message.Command() == "share" {
+		// Share pages show the bot's own workspace
+		if h.ownerOnly(message) {
 			return nil
 		}
+		return h.handleShareCommand(message)
+	}
 
-		// Store as pending task with the transcribed text
-		message.Text = transcript
-		h.storePendingTask(message)
+	if message.IsCommand() && message.Command() == "today" {
+		return h.handleTodayCommand(message)
+	}
 
-		// Brief confirmation
-		preview := transcript
-		if len([]rune(preview)) > 200 {
-			previewRunes := []rune(preview)
-			preview = string(previewRunes[:200]) + "..."
-		}
-		confirm := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("📝 Transcribed. Add 👍 to save.\n%s", preview))
-		_, _ = h.bot.Send(confirm)
-		return nil
+	if message.IsCommand() && message.Command() == "overdue" {
+		return h.handleOverdueCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "find" {
+		return h.handleFindCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "snooze" {
+		return h.handleSnoozeCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "assign" {
+		return h.handleAssignCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "done" {
+		return h.handleDoneCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "delete" {
+		return h.handleDeleteCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "pause" {
+		return h.handlePauseCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "resume" {
+		return h.handleResumeCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "use" {
+		return h.handleUseCommand(message)
+	}
+
+	if message.IsCommand() && message.Command() == "tags" {
+		return h.handleTagsCommand(message)
+	}
+
+	// A number answers the list of matches /done sent
+	if answered, err := h.handleDoneChoice(message); answered {
+		return err
 	}
 
 	// Handle regular commands
 	switch message.Text {
 	case "/start":
 		return h.handleStart(message)
-	case "Open Mini App":
+	case miniAppButtonText:
 		return h.handleMiniAppButton(message)
 	case "/cron":
+		if h.ownerOnly(message) {
+			return nil
+		}
 		return h.handleCronCommand(message)
-	case "/tags":
-		return h.handleTagsCommand(message)
+	case "/reconcile":
+		if h.ownerOnly(message) {
+			return nil
+		}
+		return h.handleReconcileCommand(message)
+	case "/template":
+		return h.handleTemplateCommand(message)
+	case "/usage":
+		if h.ownerOnly(message) {
+			return nil
+		}
+		return h.handleUsageCommand(message)
+	case "/stats":
+		// The local statistics cover every workspace's tasks
+		if h.ownerOnly(message) {
+			return nil
+		}
+		return h.handleStatsCommand(message)
 	default:
 		// Any other text is treated as a potential task, stored and waiting for reaction
 		h.storePendingTask(message)
-		log.Printf("Stored message %d as pending task: %s", message.MessageID, message.Text)
+		log.Printf("Stored message %d as pending task: %s", message.MessageID, messageText(message))
 		return nil // Don't send any response, just wait for reaction
 	}
 }
 
 func (h *Handler) handleUnauthorized(message *tgbotapi.Message) error {
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Sorry, you are not authorized to use this bot.")
+	text := "Sorry, you are not authorized to use this bot."
+	if h.tenants.CanConnect(message.From.ID) {
+		text = "Welcome! Use /connect to connect your Notion workspace first."
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	_, err := h.bot.Send(msg)
 	return err
 }
@@ -186,28 +324,50 @@ func (h *Handler) storePendingTask(message *tgbotapi.Message) {
 	userID := message.From.ID
 	messageID := message.MessageID
 
+	h.mu.Lock()
 	// Initialize map for user if it doesn't exist
 	if h.pendingTasks[userID] == nil {
 		h.pendingTasks[userID] = make(map[int]*PendingTask)
 	}
 
-	// Store the pending task
-	h.pendingTasks[userID][messageID] = &PendingTask{
-		MessageID: messageID,
-		Text:      message.Text,
+	// Store the pending task, forwards keep the channel they came from
+	text, link := messageSource(message)
+	photo := h.messagePhoto(message)
+	if strings.TrimSpace(text) == "" && photo != "" {
+		text = photoTitle
 	}
-
-	// Set thinking emoji when message is received
-	if setErr := h.setMessageReaction(message.Chat.ID, messageID, "🤔"); setErr != nil {
-		log.Printf("Warning: Failed to set 🤔 reaction: %v", setErr)
+	task := &PendingTask{
+		ChatID:     message.Chat.ID,
+		MessageID:  messageID,
+		Text:       text,
+		SourceChat: notion.SourceChatValue(message.Chat.Title, message.Chat.ID),
+		SourceURL:  link,
+		Origin:     messageOrigin(message),
+		ReceivedAt: messageTime(message),
+
+		MessageLink: messageLink(message.Chat, messageID),
+		PhotoFileID: photo,
+	}
+	h.pendingTasks[userID][messageID] = task
+	evicted := h.evictOldestPending(userID)
+	h.mu.Unlock()
+
+	h.persistPendingTask(userID, message.Chat.ID, task)
+	if len(evicted) > 0 {
+		log.Printf("Dropped the %d oldest pending task(s) of user %d over the limit of %d", len(evicted), userID, h.pendingLimit())
+		metrics.RecordPendingDropped("evicted", len(evicted))
+		h.dropPendingTasks(evicted)
 	}
+
+	// Show thinking emoji when message is received
+	h.showFeedback(message.Chat.ID, messageID, feedbackPending)
 }
 
 func (h *Handler) handleStart(message *tgbotapi.Message) error {
 	// Create a custom keyboard with a button
 	keyboard := tgbotapi.NewReplyKeyboard(
 		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton("Open Mini App"),
+			tgbotapi.NewKeyboardButton(miniAppButtonText),
 		),
 	)
 	msg := tgbotapi.NewMessage(message.Chat.ID, "Welcome to Notion Task Manager! Use the button below to open the mini app or simply send me any text to create a new task.")
@@ -216,25 +376,6 @@ func (h *Handler) handleStart(message *tgbotapi.Message) error {
 	return err
 }
 
-func (h *Handler) handleMiniAppButton(message *tgbotapi.Message) error {
-	miniAppURL := os.Getenv("MINI_APP_URL")
-	if miniAppURL == "" {
-		miniAppURL = "https://tralalero-tralala.ru/notion/mini-app" // Default fallback
-	}
-
-	// Create a web app button
-	webAppButton := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL("Open Mini App", miniAppURL),
-		),
-	)
-
-	msg := tgbotapi.NewMessage(message.Chat.ID, "Click the button below to open the mini app:")
-	msg.ReplyMarkup = webAppButton
-	_, err := h.bot.Send(msg)
-	return err
-}
-
 // handleCronCommand manually triggers the daily task check
 func (h *Handler) handleCronCommand(message *tgbotapi.Message) error {
 	if h.scheduler == nil {
@@ -252,111 +393,89 @@ func (h *Handler) handleCronCommand(message *tgbotapi.Message) error {
 	return err
 }
 
-// handleTagsCommand tags all existing tasks using Gemini AI
-func (h *Handler) handleTagsCommand(message *tgbotapi.Message) error {
-	if h.gemini == nil {
-		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Gemini AI not configured")
+// handleReconcileCommand starts dropping the local records of tasks deleted in Notion, the
+// scheduler reports the result when it's done
+func (h *Handler) handleReconcileCommand(message *tgbotapi.Message) error {
+	if h.scheduler == nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Scheduler not available")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+	if h.db == nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Reconciliation needs the local database")
 		_, err := h.bot.Send(msg)
 		return err
 	}
 
-	// Send initial message
-	msg := tgbotapi.NewMessage(message.Chat.ID, "🏷️ Starting to tag all tasks... This may take a while.")
-	h.bot.Send(msg)
-
-	// Run tagging in background
-	go func() {
-		ctx := context.Background()
-		log.Printf("/tags command: Starting to tag all tasks")
-
-		// Get all non-done tasks (up to 1000)
-		tasks, err := h.notion.GetRecentTasks(ctx, "tasks", 1000)
-		if err != nil {
-			log.Printf("Error retrieving tasks for tagging: %v", err)
-			errorMsg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("❌ Failed to retrieve tasks: %v", err))
-			h.bot.Send(errorMsg)
-			return
-		}
-
-		log.Printf("/tags command: Found %d tasks to tag", len(tasks))
-
-		taggedCount := 0
-		skippedCount := 0
-		errorCount := 0
+	h.scheduler.RunReconcile()
+	msg := tgbotapi.NewMessage(message.Chat.ID, "🧹 Checking the recorded tasks against Notion, this takes about a second per task.")
+	_, err := h.bot.Send(msg)
+	return err
+}
 
-		for i, task := range tasks {
-			log.Printf("/tags command: Processing task %d/%d: %s", i+1, len(tasks), task.Title)
+// handleUsageCommand sends the usage summary of the current week so far
+func (h *Handler) handleUsageCommand(message *tgbotapi.Message) error {
+	if h.db == nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Usage statistics need the local database")
+		_, err := h.bot.Send(msg)
+		return err
+	}
 
-			// Check if task already has llm_tag
-			if existingTag, ok := task.Properties["llm_tag"].(string); ok && existingTag != "" {
-				log.Printf("/tags command: Task %s already has llm_tag '%s', skipping", task.ID, existingTag)
-				skippedCount++
-				continue
-			}
+	text, err := usage.WeekSummary(h.db, time.Now())
+	if err != nil {
+		log.Printf("Error building usage summary: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("❌ Failed to load usage: %v", err))
+		_, err := h.bot.Send(msg)
+		return err
+	}
 
-			// Double-check: Skip if status is "done" (in case filter didn't catch it)
-			if status, ok := task.Properties["status"].(string); ok && status == "done" {
-				log.Printf("/tags command: Task %s has status 'done', skipping", task.ID)
-				skippedCount++
-				continue
-			}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	_, err = h.bot.Send(msg)
+	return err
+}
 
-			// Double-check: Skip if task has "sometimes-later" tag (in case filter didn't catch it)
-			if tags, ok := task.Properties["Tags"]; ok {
-				if tagList, ok := tags.([]string); ok {
-					hasSometimesLater := false
-					for _, tag := range tagList {
-						if tag == "sometimes-later" {
-							hasSometimesLater = true
-							break
-						}
-					}
-					if hasSometimesLater {
-						log.Printf("/tags command: Task %s has 'sometimes-later' tag, skipping", task.ID)
-						skippedCount++
-						continue
-					}
-				}
-			}
+// handleShareCommand creates or revokes a public read-only link to the open tasks with a
+// tag or project: /share <tag or project>, /share revoke <slug>
+func (h *Handler) handleShareCommand(message *tgbotapi.Message) error {
+	reply := func(text string) error {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		msg.DisableWebPagePreview = true
+		_, err := h.bot.Send(msg)
+		return err
+	}
 
-			// Get tag from Gemini
-			tag, err := h.gemini.TagTask(task.Title)
-			if err != nil {
-				log.Printf("/tags command: Failed to tag task %s: %v", task.ID, err)
-				errorCount++
-				// Use default tag on error
-				tag = "task"
-			}
+	if h.db == nil {
+		return reply("❌ Sharing needs the local database")
+	}
 
-			// Update task in Notion
-			if err := h.notion.UpdateTaskLLMTag(task.ID, tag); err != nil {
-				log.Printf("/tags command: Failed to update task %s in Notion: %v", task.ID, err)
-				errorCount++
-			} else {
-				log.Printf("/tags command: Successfully tagged task %s as '%s'", task.ID, tag)
-				taggedCount++
-			}
+	args := strings.TrimSpace(message.CommandArguments())
+	if args == "" {
+		return reply("Usage: /share <tag or project> to create a read-only link, /share revoke <slug> to delete one")
+	}
 
-			// Small delay to avoid rate limits
-			time.Sleep(500 * time.Millisecond)
+	if slug, ok := strings.CutPrefix(args, "revoke "); ok {
+		deleted, err := h.db.DeleteShare(strings.TrimSpace(slug))
+		if err != nil {
+			log.Printf("Error revoking share: %v", err)
+			return reply(fmt.Sprintf("❌ Failed to revoke link: %v", err))
 		}
+		if !deleted {
+			return reply("No such link")
+		}
+		return reply("🔒 Link revoked")
+	}
 
-		// Send summary
-		summary := fmt.Sprintf(
-			"✅ Tagging complete!\n\n"+
-				"📊 Summary:\n"+
-				"• Tagged: %d tasks\n"+
-				"• Skipped (already tagged): %d\n"+
-				"• Errors: %d\n"+
-				"• Total processed: %d",
-			taggedCount, skippedCount, errorCount, len(tasks))
-
-		summaryMsg := tgbotapi.NewMessage(message.Chat.ID, summary)
-		h.bot.Send(summaryMsg)
-		log.Printf("/tags command: Completed. Tagged=%d, Skipped=%d, Errors=%d", taggedCount, skippedCount, errorCount)
-	}()
+	created, err := share.Create(h.db, args, time.Now())
+	if err != nil {
+		log.Printf("Error creating share: %v", err)
+		return reply(fmt.Sprintf("❌ Failed to create link: %v", err))
+	}
 
-	return nil
+	link := strings.TrimSuffix(miniAppURL(), "/") + "/share/" + created.Slug
+
+	return reply(fmt.Sprintf("🔗 Read-only list of open \"%s\" tasks, valid until %s:\n%s\n\nRevoke with /share revoke %s",
+		args, created.ExpiresAt.Format("02 Jan 2006"), link, created.Slug))
 }
 
 // MessageReactionUpdate represents an update to message reactions
@@ -383,8 +502,9 @@ type ReactionType struct {
 	Emoji string `json:"emoji,omitempty"`
 }
 
-// HandleMessageReaction handles reactions added to messages
-func (h *Handler) HandleMessageReaction(reaction *MessageReactionUpdate) error {
+// HandleMessageReaction handles reactions added to messages. The save is traced as a
+// child of any span in ctx.
+func (h *Handler) HandleMessageReaction(ctx context.Context, reaction *MessageReactionUpdate) error {
 	// Check if user is authorized
 	if reaction.User.ID == 0 || !h.isAuthorized(reaction.User.ID) {
 		log.Printf("Ignoring reaction from unauthorized or unknown user")
@@ -398,7 +518,10 @@ func (h *Handler) HandleMessageReaction(reaction *MessageReactionUpdate) error {
 	log.Printf("Received reaction update for message %d from user %d", messageID, userID)
 
 	// Check if this message has a pending task
-	if h.pendingTasks[userID] == nil || h.pendingTasks[userID][messageID] == nil {
+	h.mu.Lock()
+	pendingTask := h.pendingTasks[userID][messageID]
+	h.mu.Unlock()
+	if pendingTask == nil {
 		log.Printf("No pending task found for message %d", messageID)
 		return nil
 	}
@@ -409,41 +532,198 @@ func (h *Handler) HandleMessageReaction(reaction *MessageReactionUpdate) error {
 		return nil
 	}
 
-	// Only process if reaction is 👍 (thumbs up)
-	isThumbsUp := false
-	for _, r := range reaction.NewReaction {
-		if r.Type == "emoji" && r.Emoji == "👍" {
-			isThumbsUp = true
-			break
-		}
+	// ✂️ saves one task per entry of the message
+	if hasSplitReaction(reaction.NewReaction) {
+		return h.enqueueSplit(ctx, chatID, userID, messageID)
 	}
 
-	if !isThumbsUp {
-		log.Printf("Reaction is not thumbs up, ignoring")
+	// Only mapped reactions save, 👍 as a task unless REACTION_MAP says otherwise
+	dbType, ok := h.reactionTarget(reaction.NewReaction)
+	if !ok {
+		log.Printf("Reaction is not mapped to a database, ignoring")
 		return nil
 	}
 
-	// Get the pending task
+	return h.enqueueSave(ctx, chatID, userID, messageID, dbType)
+}
+
+// tagSavedTask tags a new task with the LLM and stores the tag in its llm_tag property.
+// Tasks mentioning a date get it set, the daily check asks about the others. A known tag
+// skips asking the LLM again. It returns the tag, llm.DefaultTag when tagging failed.
+func (h *Handler) tagSavedTask(ctx context.Context, client *notion.Client, taskID, text, known string) string {
+	tag := known
+	if tag == "" {
+		var err error
+		tag, err = h.tagger.TagTask(ctx, text)
+		if err != nil {
+			log.Printf("Warning: Failed to get LLM tag for task %s: %v", taskID, err)
+			tag = llm.DefaultTag // Default tag on error
+		}
+	}
+
+	// Store tag in Notion's llm_tag property
+	if err := client.UpdateTaskLLMTag(ctx, taskID, tag); err != nil {
+		log.Printf("Warning: Failed to update llm_tag in Notion for %s: %v", taskID, err)
+	}
+	if h.db != nil {
+		if err := h.db.UpdateTaskTag(taskID, tag); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	if tag == llm.TagDate {
+		h.setExtractedDate(ctx, client, taskID, text)
+	}
+	return tag
+}
+
+// recordTaskMetadata stores a task the bot created for a user in the local database, for
+// /stats, with the workspace it was created in. tag is empty until the LLM has tagged it.
+func (h *Handler) recordTaskMetadata(userID int64, taskID, title, tag string) {
+	if h.db == nil {
+		return
+	}
+	if err := h.db.StoreTaskMetadata(h.workspaceOf(userID), taskID, title, tag); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// setExtractedDate sets the Date of a task to the date its text mentions, leaving it
+// unset when none can be resolved
+func (h *Handler) setExtractedDate(ctx context.Context, client *notion.Client, taskID, text string) {
+	if h.dates == nil {
+		return
+	}
+	date, err := h.dates.ExtractDate(ctx, text, time.Now().In(h.location()))
+	if err != nil {
+		log.Printf("Warning: Could not extract a date for task %s, leaving it unset: %v", taskID, err)
+		return
+	}
+	if err := client.UpdateTaskDate(ctx, taskID, date); err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+	log.Printf("Set date %s for task %s", date, taskID)
+}
+
+// claimPendingTask marks a pending task as being saved and drops its stored copy. It
+// returns nil when there is no such task or a save already claimed it, and otherwise when
+// the save was requested.
+func (h *Handler) claimPendingTask(chatID, userID int64, messageID int) (*PendingTask, time.Time) {
+	h.mu.Lock()
 	pendingTask := h.pendingTasks[userID][messageID]
+	if pendingTask == nil {
+		h.mu.Unlock()
+		log.Printf("No pending task found for message %d", messageID)
+		return nil, time.Time{}
+	}
+	if pendingTask.saving {
+		h.mu.Unlock()
+		log.Printf("Task for message %d is already being saved, ignoring", messageID)
+		return nil, time.Time{}
+	}
+	pendingTask.saving = true
+	requestedAt := pendingTask.saveRequestedAt
+	if requestedAt.IsZero() {
+		requestedAt = time.Now()
+	}
+	h.mu.Unlock()
+	h.forgetPendingTask(chatID, messageID)
+	return pendingTask, requestedAt
+}
+
+// savePendingTask creates the page for a pending message in the database of dbType,
+// triggered by a mapped reaction or /save
+func (h *Handler) savePendingTask(ctx context.Context, chatID, userID int64, messageID int, dbType string) (err error) {
+	// Claim the pending task so a repeated 👍 doesn't save it twice
+	pendingTask, requestedAt := h.claimPendingTask(chatID, userID, messageID)
+	if pendingTask == nil {
+		return nil
+	}
+
+	ctx, span := tracing.Start(ctx, "bot.save_task")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+	// Tags and source chats of messages are meant as written, unknown ones become options
+	ctx = notion.WithNewOptions(ctx)
+
+	// Show the writing hand to indicate processing
+	h.showFeedback(chatID, messageID, feedbackSaving)
+	client := h.notionFor(userID)
 
-	// Set writing hand reaction to indicate processing
-	if setErr := h.setMessageReaction(chatID, messageID, "✍️"); setErr != nil {
-		log.Printf("Warning: Failed to set ✍️ reaction: %v", setErr)
+	// Tasks go to the database the chat picked with /use
+	target := dbType
+	if dbType == "tasks" {
+		target = h.activeDatabase(chatID, client)
+	}
+
+	// Journal entries go to today's journal page instead when JOURNAL_AUTO_APPEND is on
+	var knownTag string
+	if dbType == "tasks" && h.journalAutoAppend && h.tagger != nil && client.HasDatabase("journal") {
+		var appended bool
+		knownTag, appended = h.appendToDailyJournal(ctx, userID, messageID, pendingTask)
+		if appended {
+			metrics.ObserveTaskSave(time.Since(requestedAt), nil)
+			h.showFeedback(chatID, messageID, feedbackJournal)
+			return nil
+		}
 	}
 
 	// Try to create task with retries
-	ctx := context.Background()
-	var err error
 	var taskID string
+	var savedText, title, content string
+	var taskProperties map[string]interface{}
 	maxRetries := 3
 
+	properties := h.sourceChatProperties(pendingTask.SourceChat)
+	for key, value := range h.sourceURLProperties(ctx, client, target, pendingTask.SourceURL) {
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[key] = value
+	}
+	for key, value := range h.telegramLinkProperties(ctx, client, target, pendingTask.MessageLink) {
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[key] = value
+	}
+	photo, photoProperties := h.stagePhoto(ctx, client, target, pendingTask.PhotoFileID)
+	for key, value := range photoProperties {
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[key] = value
+	}
+
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		log.Printf("Attempt %d/%d to create task: %s", attempt, maxRetries, pendingTask.Text)
-		taskID, err = h.notion.CreateTask(ctx, pendingTask.Text, nil, "tasks")
+		// Read the text at execution time so edits made before the attempt are included
+		h.mu.Lock()
+		savedText = pendingTask.Text
+		pendingTask.saveStartedAt = time.Now()
+		saveStartedAt := pendingTask.saveStartedAt
+		h.mu.Unlock()
+
+		// Persist the attempt so a restart mid-save can be reconciled. Recovery looks for
+		// the page in the tasks database, so saves to other databases aren't persisted.
+		if target == "tasks" {
+			h.startSaveAttempt(chatID, userID, messageID, savedText, saveStartedAt)
+		}
+
+		log.Printf("Attempt %d/%d to create task: %s", attempt, maxRetries, savedText)
+		// #tags and a !date in the text become properties, the title is what's left. Long
+		// or multi-line messages keep their first line as the title, the rest goes in the body.
+		var tags []string
+		var dateHint string
+		title, content, tags, dateHint = splitTaskText(client, savedText)
+		taskProperties = h.markerProperties(ctx, properties, tags, dateHint)
+		taskID, err = client.CreateTaskWithContent(ctx, title, content, taskProperties, target)
 
 		if err == nil {
 			// Success!
-			log.Printf("Task created successfully on attempt %d: %s", attempt, pendingTask.Text)
+			log.Printf("Task created successfully on attempt %d: %s", attempt, savedText)
 			break
 		}
 
@@ -451,90 +731,149 @@ func (h *Handler) HandleMessageReaction(reaction *MessageReactionUpdate) error {
 
 		if attempt < maxRetries {
 			// Wait before retry (exponential backoff)
-			sleep := time.Duration(attempt) * 2 * time.Second
+			sleep := time.Duration(attempt) * h.retryBackoff
 			log.Printf("Waiting %v before retry...", sleep)
 			time.Sleep(sleep)
 		}
 	}
 
-	// Remove from pending tasks
-	delete(h.pendingTasks[userID], messageID)
+	metrics.ObserveTaskSave(time.Since(requestedAt), err)
+
+	// Remove from pending tasks and remember the page for later edits. A failed save stays
+	// pending so the message isn't lost, another 👍 tries again.
+	h.mu.Lock()
+	editedText, editDate := pendingTask.Text, pendingTask.editDate
+	if err == nil {
+		delete(h.pendingTasks[userID], messageID)
+		h.recordSavedMessage(userID, messageID, &savedMessage{
+			TaskID:        taskID,
+			Title:         savedText,
+			SaveStartedAt: pendingTask.saveStartedAt,
+			editDate:      editDate,
+		})
+	} else {
+		pendingTask.saving = false
+		pendingTask.saveRequestedAt = time.Time{}
+	}
+	h.mu.Unlock()
 
 	if err != nil {
 		// All retries failed - set crying emoji
 		log.Printf("Failed to create task after %d attempts: %v", maxRetries, err)
-		if setErr := h.setMessageReaction(chatID, messageID, "😢"); setErr != nil {
-			log.Printf("Error setting 😢 reaction: %v", setErr)
+		h.finishSaveAttempt(chatID, messageID, database.SaveStateFailed, "")
+		h.persistPendingTask(userID, chatID, pendingTask)
+		h.showFeedback(chatID, messageID, feedbackFailed)
+
+		// While Notion is down the save is queued for RunOutbox
+		if notion.IsTransient(err) {
+			queued := h.queueWrite(outboxCreateTask, chatID, messageID, outboxTask{
+				UserID:     userID,
+				ChatID:     chatID,
+				MessageID:  messageID,
+				DbType:     target,
+				Text:       savedText,
+				Title:      title,
+				Content:    content,
+				Properties: taskProperties,
+				KnownTag:   knownTag,
+				Comment:    h.sourceComment(pendingTask, savedText),
+			}, err)
+			if queued {
+				// The queued task links the photo too
+				h.finishPhoto(photo, true)
+				h.reply(chatID, "⏳ Notion can't be reached, the task is queued and saved once it's back.")
+				return err
+			}
+		}
+		h.finishPhoto(photo, false)
+		// Say what to fix when Notion rejected the task
+		if _, ok := notion.AsNotionError(err); ok {
+			h.reply(chatID, "❌ Could not save the task: "+notion.Explain(err))
 		}
 		return err
 	}
+	h.finishSaveAttempt(chatID, messageID, database.SaveStateDone, taskID)
+	h.finishPhoto(photo, true)
+	h.persistSavedMessage(userID, chatID, messageID, taskID)
+	h.dropQueuedSave(chatID, messageID)
+	if dbType == "tasks" {
+		h.recordTaskMetadata(userID, taskID, savedText, knownTag)
+	}
+	h.attachSourceComment(ctx, client, taskID, h.sourceComment(pendingTask, savedText))
+	h.addOpenInNotionButton(chatID, pendingTask.TranscriptMessageID, taskID)
+
+	// The message was edited while the save was in flight, apply the correction
+	if editedText != savedText {
+		log.Printf("Message %d was edited during its save, updating the title", messageID)
+		if err := h.applyEditToSavedTask(userID, messageID, editedText, editDate); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
 
-	// Task created successfully - now tag it with Gemini and store in Notion
-	if h.gemini != nil {
+	// Task created successfully - now tag it with the LLM and store in Notion. Only tasks
+	// are tagged, the daily check doesn't look at the other databases.
+	if dbType != "tasks" {
+		log.Printf("Saved message %d to the %s database", messageID, dbType)
+	} else if h.tagger != nil {
 		go func() {
-			// Get LLM tag from Gemini
-			tag, err := h.gemini.TagTask(pendingTask.Text)
-			if err != nil {
-				log.Printf("Warning: Failed to get LLM tag for task %s: %v", taskID, err)
-				tag = "task" // Default tag on error
-			}
+			tag := h.tagSavedTask(ctx, client, taskID, savedText, knownTag)
 
-			// Store tag in Notion's llm_tag property
-			if err := h.notion.UpdateTaskLLMTag(taskID, tag); err != nil {
-				log.Printf("Warning: Failed to update llm_tag in Notion for %s: %v", taskID, err)
+			// The card is sent after tagging so it can show the chosen tag
+			if h.confirmationCards {
+				if err := h.sendConfirmationCard(chatID, taskID, savedText, tag, taskProperties); err != nil {
+					log.Printf("Warning: %v", err)
+				}
 			}
 		}()
 	} else {
-		log.Printf("Gemini not configured, skipping task tagging")
-	}
-
-	// Success - set thumbs up (try multiple times to ensure it's visible)
-	for i := 0; i < 2; i++ {
-		if setErr := h.setMessageReaction(chatID, messageID, "👍"); setErr != nil {
-			log.Printf("Attempt %d: Failed to set 👍 reaction: %v", i+1, setErr)
-			time.Sleep(500 * time.Millisecond)
-		} else {
-			log.Printf("Successfully set 👍 reaction")
-			break
+		log.Printf("LLM not configured, skipping task tagging")
+		if h.confirmationCards {
+			if err := h.sendConfirmationCard(chatID, taskID, savedText, "", taskProperties); err != nil {
+				log.Printf("Warning: %v", err)
+			}
 		}
 	}
+
+	// Success - show thumbs up
+	h.showFeedback(chatID, messageID, feedbackSaved)
 	return nil
 }
 
-// setMessageReaction sets a reaction on a message using direct API call
+// setMessageReaction sets a reaction on a message. The library has no helper for
+// setMessageReaction, so the request is built by hand.
 func (h *Handler) setMessageReaction(chatID int64, messageID int, emoji string) error {
-	token := h.bot.Token
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/setMessageReaction", token)
-
-	payload := map[string]interface{}{
-		"chat_id":    chatID,
-		"message_id": messageID,
-		"reaction": []map[string]string{
-			{
-				"type":  "emoji",
-				"emoji": emoji,
-			},
+	params := tgbotapi.Params{}
+	params.AddFirstValid("chat_id", chatID)
+	params.AddNonZero("message_id", messageID)
+	params.AddBool("is_big", false)
+
+	reaction := []map[string]string{
+		{
+			"type":  "emoji",
+			"emoji": normalizeEmoji(emoji),
 		},
-		"is_big": false,
-	}
-
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
 	}
-
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+	if err := params.AddInterface("reaction", reaction); err != nil {
+		return fmt.Errorf("failed to marshal reaction: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		var result map[string]interface{}
-		json.NewDecoder(resp.Body).Decode(&result)
-		return fmt.Errorf("API returned status %d: %v", resp.StatusCode, result)
+	if _, err := h.bot.MakeRequest("setMessageReaction", params); err != nil {
+		return fmt.Errorf("failed to set reaction: %w", err)
 	}
 
 	log.Printf("Successfully set reaction on message %d", messageID)
 	return nil
 }
+
+// clearMessageReaction removes the bot's reaction from a message
+func (h *Handler) clearMessageReaction(chatID int64, messageID int) error {
+	params := tgbotapi.Params{}
+	params.AddFirstValid("chat_id", chatID)
+	params.AddNonZero("message_id", messageID)
+	params["reaction"] = "[]"
+
+	if _, err := h.bot.MakeRequest("setMessageReaction", params); err != nil {
+		return fmt.Errorf("failed to clear reaction: %w", err)
+	}
+	return nil
+}
@@ -1,9 +1,15 @@
 package bot
 
 import (
+	"context"
+	"net/http"
 	"testing"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
 )
 
 // Test storing pending task
@@ -123,7 +129,7 @@ func TestOnlyThumbsUpTriggers(t *testing.T) {
 // Test authorization check
 func TestAuthorizationCheck(t *testing.T) {
 	handler := &Handler{
-		authorizedUserID: 456,
+		users: auth.NewUsers(456, 789),
 	}
 
 	// Test authorized user
@@ -131,15 +137,17 @@ func TestAuthorizationCheck(t *testing.T) {
 		t.Error("User 456 should be authorized")
 	}
 
+	if !handler.isAuthorized(789) {
+		t.Error("User 789 should be authorized")
+	}
+
 	// Test unauthorized user
 	if handler.isAuthorized(999) {
 		t.Error("User 999 should not be authorized")
 	}
 
 	// Test no authorization (allow all)
-	handlerNoAuth := &Handler{
-		authorizedUserID: 0,
-	}
+	handlerNoAuth := &Handler{}
 	if !handlerNoAuth.isAuthorized(999) {
 		t.Error("When no auth is set, all users should be allowed")
 	}
@@ -162,3 +170,48 @@ func TestReactionTypeParsing(t *testing.T) {
 		t.Error("First reaction should be thumbs up")
 	}
 }
+
+func TestSavedTasksAreRecorded(t *testing.T) {
+	handler, _, _ := newRecoveryTestHandler(t)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	tasks, err := handler.db.GetTasksSince(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetTasksSince failed: %v", err)
+	}
+	// Without an LLM the task stays untagged
+	if len(tasks) != 1 || tasks[0].TaskID != "page-1" || tasks[0].TaskTitle != "Buy milk" || tasks[0].LLMTag != "" {
+		t.Errorf("Expected the saved task to be recorded untagged, got %+v", tasks)
+	}
+	if len(tasks) == 1 && tasks[0].Workspace != database.OwnWorkspace {
+		t.Errorf("Expected the task recorded in the bot's workspace, got %d", tasks[0].Workspace)
+	}
+
+	// The tag is filled in once the LLM resolves it
+	handler.tagger = &fakeTagger{tag: llm.TagDate}
+	handler.tagSavedTask(context.Background(), handler.notion, "page-1", "Buy milk", "")
+	tasks, err = handler.db.GetTasksSince(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetTasksSince failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].LLMTag != llm.TagDate {
+		t.Errorf("Expected the recorded tag to be updated, got %+v", tasks)
+	}
+}
+
+func TestSavedTasksWithoutDatabase(t *testing.T) {
+	handler, _, fake := newRecoveryTestHandler(t)
+	handler.db = nil
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 {
+		t.Errorf("Expected the task to be saved without the local database, got %v", titles)
+	}
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+)
+
+const (
+	// inlineCreateResultID identifies the "Create task" result of inline queries
+	inlineCreateResultID = "create_task"
+	// inlineCacheTime is how many seconds Telegram may reuse an answer. The library can't
+	// send 0, which Telegram would take as its 300 second default.
+	inlineCacheTime = 1
+	// maxInlineTitle is how many characters of the query the result shows
+	maxInlineTitle = 60
+)
+
+// inlineQueryAnswer answers an inline query with a single article creating a task from the
+// query text, or with no results for an empty query. Answers are personal so they are
+// never shown to someone else.
+func inlineQueryAnswer(query *tgbotapi.InlineQuery) tgbotapi.InlineConfig {
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       []interface{}{},
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+	}
+	text := strings.TrimSpace(query.Query)
+	if text == "" {
+		return answer
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticleHTML(inlineCreateResultID,
+		"Create task: "+truncateRunes(text, maxInlineTitle),
+		"📝 Saving task: "+html.EscapeString(text))
+	article.Description = "Saved to your Notion tasks when sent"
+	// Telegram only passes the inline_message_id needed to edit in the confirmation when
+	// the message has a keyboard
+	another := ""
+	article.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{
+			{Text: "➕ Another task", SwitchInlineQueryCurrentChat: &another},
+		}},
+	}
+	answer.Results = append(answer.Results, article)
+	return answer
+}
+
+// HandleInlineQuery offers to create a task from what is typed after the bot's username
+// in any chat. Queries from anyone but the authorized user get no results.
+func (h *Handler) HandleInlineQuery(query *tgbotapi.InlineQuery) error {
+	if query.From == nil || !h.isAuthorized(query.From.ID) {
+		log.Printf("Ignoring inline query from unauthorized or unknown user")
+		_, err := h.bot.Request(tgbotapi.InlineConfig{InlineQueryID: query.ID, Results: []interface{}{}, IsPersonal: true})
+		return err
+	}
+
+	if _, err := h.bot.Request(inlineQueryAnswer(query)); err != nil {
+		return fmt.Errorf("failed to answer inline query: %w", err)
+	}
+	return nil
+}
+
+// HandleChosenInlineResult creates the task of a "Create task" result once it was sent,
+// tagged like saved messages, and edits the sent message into a confirmation. Telegram
+// only reports chosen results with inline feedback enabled in BotFather.
+func (h *Handler) HandleChosenInlineResult(result *tgbotapi.ChosenInlineResult) (err error) {
+	if result.From == nil || !h.isAuthorized(result.From.ID) {
+		log.Printf("Ignoring chosen inline result from unauthorized or unknown user")
+		return nil
+	}
+	text := strings.TrimSpace(result.Query)
+	if result.ResultID != inlineCreateResultID || text == "" {
+		return nil
+	}
+
+	ctx, span := tracing.Start(context.Background(), "bot.inline_task")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	startedAt := time.Now()
+	client := h.notionFor(result.From.ID)
+	title, content := client.SplitContent(text)
+	taskID, err := client.CreateTaskWithContent(ctx, title, content, nil, "tasks")
+	metrics.ObserveTaskSave(time.Since(startedAt), err)
+	if err != nil {
+		h.editInlineMessage(result.InlineMessageID, "❌ Could not save the task: "+html.EscapeString(text), nil)
+		return fmt.Errorf("failed to create task from inline query: %w", err)
+	}
+	log.Printf("Created task %s from an inline query", taskID)
+	h.recordTaskMetadata(result.From.ID, taskID, text, "")
+
+	if h.tagger != nil {
+		go h.tagSavedTask(ctx, client, taskID, text, "")
+	}
+
+	pageURL := notionPageURL(taskID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonURL("Open in Notion", pageURL)),
+	)
+	h.editInlineMessage(result.InlineMessageID,
+		fmt.Sprintf("✅ Task saved: <a href=\"%s\">%s</a>", html.EscapeString(pageURL), html.EscapeString(truncateRunes(title, 80))),
+		&keyboard)
+	return nil
+}
+
+// editInlineMessage replaces the text of a message sent through an inline result, which
+// has no chat ID
+func (h *Handler) editInlineMessage(inlineMessageID, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if inlineMessageID == "" {
+		return
+	}
+	edit := tgbotapi.EditMessageTextConfig{
+		BaseEdit:              tgbotapi.BaseEdit{InlineMessageID: inlineMessageID, ReplyMarkup: keyboard},
+		Text:                  text,
+		ParseMode:             tgbotapi.ModeHTML,
+		DisableWebPagePreview: true,
+	}
+	if _, err := h.bot.Request(edit); err != nil {
+		log.Printf("Warning: Could not edit inline message %s: %v", inlineMessageID, err)
+	}
+}
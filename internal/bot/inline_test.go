@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+)
+
+func TestInlineQueryAnswerOffersToCreateTheTask(t *testing.T) {
+	answer := inlineQueryAnswer(&tgbotapi.InlineQuery{ID: "query-1", Query: "  buy <milk> "})
+	if answer.InlineQueryID != "query-1" || !answer.IsPersonal || answer.CacheTime != inlineCacheTime {
+		t.Errorf("Expected a personal, barely cached answer to query-1, got %+v", answer)
+	}
+	if len(answer.Results) != 1 {
+		t.Fatalf("Expected a single result, got %d", len(answer.Results))
+	}
+	article, ok := answer.Results[0].(tgbotapi.InlineQueryResultArticle)
+	if !ok {
+		t.Fatalf("Expected an article, got %T", answer.Results[0])
+	}
+	if article.ID != inlineCreateResultID || article.Title != "Create task: buy <milk>" {
+		t.Errorf("Unexpected article %q: %q", article.ID, article.Title)
+	}
+	content, ok := article.InputMessageContent.(tgbotapi.InputTextMessageContent)
+	if !ok || content.Text != "📝 Saving task: buy &lt;milk&gt;" || content.ParseMode != tgbotapi.ModeHTML {
+		t.Errorf("Expected the escaped task as HTML, got %+v", article.InputMessageContent)
+	}
+	// Without a keyboard Telegram doesn't pass the ID needed to edit in the confirmation
+	if article.ReplyMarkup == nil || len(article.ReplyMarkup.InlineKeyboard) == 0 {
+		t.Errorf("Expected a keyboard on the result")
+	}
+
+	if empty := inlineQueryAnswer(&tgbotapi.InlineQuery{ID: "query-2", Query: " "}); len(empty.Results) != 0 {
+		t.Errorf("Expected no results for an empty query, got %d", len(empty.Results))
+	}
+}
+
+func TestInlineQueriesFromOtherUsersAreRejected(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.users = auth.NewUsers(456)
+	stranger := &tgbotapi.User{ID: 999}
+
+	if err := handler.HandleInlineQuery(&tgbotapi.InlineQuery{ID: "query-1", From: stranger, Query: "buy milk"}); err != nil {
+		t.Fatalf("HandleInlineQuery failed: %v", err)
+	}
+	answers := telegram.callsTo("answerInlineQuery")
+	if len(answers) != 1 || answers[0].Params.Get("results") != "[]" {
+		t.Errorf("Expected an empty answer, got %v", answers)
+	}
+
+	err := handler.HandleChosenInlineResult(&tgbotapi.ChosenInlineResult{ResultID: inlineCreateResultID, From: stranger, Query: "buy milk", InlineMessageID: "inline-1"})
+	if err != nil {
+		t.Fatalf("HandleChosenInlineResult failed: %v", err)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no Notion requests, got %d", len(fake.requests))
+	}
+}
+
+func TestChosenInlineResultCreatesTask(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.users = auth.NewUsers(456)
+	owner := &tgbotapi.User{ID: 456}
+
+	if err := handler.HandleInlineQuery(&tgbotapi.InlineQuery{ID: "query-1", From: owner, Query: "Buy milk"}); err != nil {
+		t.Fatalf("HandleInlineQuery failed: %v", err)
+	}
+	if answers := telegram.callsTo("answerInlineQuery"); len(answers) != 1 || !strings.Contains(answers[0].Params.Get("results"), "Create task: Buy milk") {
+		t.Errorf("Expected the create result, got %v", answers)
+	}
+
+	err := handler.HandleChosenInlineResult(&tgbotapi.ChosenInlineResult{ResultID: inlineCreateResultID, From: owner, Query: "Buy milk", InlineMessageID: "inline-1"})
+	if err != nil {
+		t.Fatalf("HandleChosenInlineResult failed: %v", err)
+	}
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 || titles[0] != "Buy milk" {
+		t.Errorf("Expected the task to be created, got %v", titles)
+	}
+
+	edits := telegram.callsTo("editMessageText")
+	if len(edits) != 1 {
+		t.Fatalf("Expected the inline message to be edited, got %d edits", len(edits))
+	}
+	if edits[0].Params.Get("inline_message_id") != "inline-1" || !strings.Contains(edits[0].Params.Get("text"), "✅ Task saved:") {
+		t.Errorf("Expected a confirmation in inline-1, got %v", edits[0].Params)
+	}
+}
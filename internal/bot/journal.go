@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+// journalAutoAppendFromEnv reports whether JOURNAL_AUTO_APPEND is on. hasDatabase reports
+// whether a database type is configured, the flag is ignored without a journal database.
+func journalAutoAppendFromEnv(hasDatabase func(dbType string) bool) bool {
+	if os.Getenv("JOURNAL_AUTO_APPEND") != "true" {
+		return false
+	}
+	if !hasDatabase("journal") {
+		log.Printf("Warning: Ignoring JOURNAL_AUTO_APPEND, the journal database is not configured")
+		return false
+	}
+	return true
+}
+
+// appendToDailyJournal tags a claimed pending message and, when it is a journal entry,
+// appends it to today's journal page and drops it from the pending tasks. Otherwise the
+// message is left to be saved as a task and the tag is returned so it isn't asked for
+// twice. Entries the journal can't take are saved as tasks too.
+func (h *Handler) appendToDailyJournal(ctx context.Context, userID int64, messageID int, pendingTask *PendingTask) (tag string, appended bool) {
+	h.mu.Lock()
+	text := pendingTask.Text
+	h.mu.Unlock()
+
+	tag, err := h.tagger.TagTask(ctx, text)
+	if err != nil {
+		log.Printf("Warning: Could not tag message %d, saving it as a task: %v", messageID, err)
+		return "", false
+	}
+	if tag != llm.TagJournal {
+		return tag, false
+	}
+
+	if err := h.notionFor(userID).AppendToDailyJournal(ctx, time.Now().In(h.location()), text); err != nil {
+		log.Printf("Warning: Could not append message %d to the daily journal, saving it as a task: %v", messageID, err)
+		return tag, false
+	}
+
+	h.mu.Lock()
+	delete(h.pendingTasks[userID], messageID)
+	h.mu.Unlock()
+	log.Printf("Appended message %d to the daily journal", messageID)
+	return tag, true
+}
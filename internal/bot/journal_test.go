@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+// fakeTagger tags every entry with the same tag
+type fakeTagger struct {
+	tag string
+}
+
+func (f *fakeTagger) TagTask(ctx context.Context, content string) (string, error) {
+	return f.tag, nil
+}
+
+func (f *fakeTagger) TagTasksBatch(ctx context.Context, contents []string) ([]string, error) {
+	tags := make([]string, len(contents))
+	for i, content := range contents {
+		tags[i], _ = f.TagTask(ctx, content)
+	}
+	return tags, nil
+}
+
+// newJournalTestHandler returns a handler with a journal database and JOURNAL_AUTO_APPEND
+// on, tagging every message with tag
+func newJournalTestHandler(t *testing.T, tag string) (*Handler, *fakeTelegram, *fakeNotionAPI) {
+	t.Helper()
+	t.Setenv("NOTION_JOURNAL_DATABASE_ID", "journal-db")
+
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.schema = `{"Name": {"id": "title", "type": "title", "title": {}}, "Date": {"id": "date", "type": "date", "date": {}}}`
+	handler.tagger = &fakeTagger{tag: tag}
+	handler.journalAutoAppend = true
+	return handler, telegram, fake
+}
+
+// requestCount returns how many requests the fake got with the method and path
+func requestCount(fake *fakeNotionAPI, method, path string) int {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	count := 0
+	for _, r := range fake.requests {
+		if r.Method == method && r.Path == path {
+			count++
+		}
+	}
+	return count
+}
+
+func TestJournalAutoAppendRoutesJournalEntries(t *testing.T) {
+	handler, telegram, fake := newJournalTestHandler(t, llm.TagJournal)
+
+	handler.storePendingTask(testMessage("Felt calm after the walk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	// The day's page is created in the journal and the entry appended to it, no task
+	if parents := createdParents(t, fake); !reflect.DeepEqual(parents, []string{"journal-db"}) {
+		t.Errorf("Expected only the daily page in journal-db, got %v", parents)
+	}
+	if n := requestCount(fake, http.MethodPatch, "/v1/blocks/page-1/children"); n != 1 {
+		t.Errorf("Expected 1 append to the daily page, got %d", n)
+	}
+	if got := reactions(telegram); len(got) == 0 || got[len(got)-1] != `[{"emoji":"📔","type":"emoji"}]` {
+		t.Errorf("Expected the message to end with 📔, got %v", got)
+	}
+	if handler.pendingTasks[456][123] != nil {
+		t.Error("Expected the message to be no longer pending")
+	}
+}
+
+func TestJournalAutoAppendSavesOtherTagsAsTasks(t *testing.T) {
+	handler, telegram, fake := newJournalTestHandler(t, llm.TagTask)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if parents := createdParents(t, fake); !reflect.DeepEqual(parents, []string{"tasks-db"}) {
+		t.Errorf("Expected only a task in tasks-db, got %v", parents)
+	}
+	if n := requestCount(fake, http.MethodPatch, "/v1/blocks/page-1/children"); n != 0 {
+		t.Errorf("Expected nothing appended to the journal, got %d", n)
+	}
+	if got := reactions(telegram); len(got) == 0 || got[len(got)-1] != `[{"emoji":"👍","type":"emoji"}]` {
+		t.Errorf("Expected the message to end with 👍, got %v", got)
+	}
+}
+
+func TestJournalAutoAppendOff(t *testing.T) {
+	handler, _, fake := newJournalTestHandler(t, llm.TagJournal)
+	handler.journalAutoAppend = false
+	handler.tagger = nil
+
+	handler.storePendingTask(testMessage("Felt calm after the walk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if parents := createdParents(t, fake); !reflect.DeepEqual(parents, []string{"tasks-db"}) {
+		t.Errorf("Expected only a task in tasks-db without JOURNAL_AUTO_APPEND, got %v", parents)
+	}
+}
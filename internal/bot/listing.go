@@ -0,0 +1,274 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// telegramMessageLimit is the longest text Telegram accepts in one message
+const telegramMessageLimit = 4096
+
+// maxOverdueTasks caps the /overdue listing
+const maxOverdueTasks = 100
+
+// maxFindResults is how many matches /find replies with
+const maxFindResults = 5
+
+// maxScopedFindMatches is how many matches /find looks through for ones from the current chat
+const maxScopedFindMatches = 50
+
+// location returns the timezone for "today", the scheduler's when there is one
+func (h *Handler) location() *time.Location {
+	if h.scheduler != nil {
+		return h.scheduler.Timezone()
+	}
+	return time.Local
+}
+
+// scopeFilters returns the query filters limiting a listing to the current chat, and the
+// command arguments without the widening "all"
+func (h *Handler) scopeFilters(ctx context.Context, message *tgbotapi.Message) ([]notionapi.Filter, string, error) {
+	scope, args := h.chatScope(message)
+	if scope == nil {
+		return nil, args, nil
+	}
+	filter, err := h.notionFor(message.From.ID).ChatScopeFilter(ctx, "tasks", *scope)
+	if err != nil {
+		return nil, args, err
+	}
+	return []notionapi.Filter{filter}, args, nil
+}
+
+// handleTodayCommand lists the tasks due today: /today, or /today all for every chat
+func (h *Handler) handleTodayCommand(message *tgbotapi.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filters, _, err := h.scopeFilters(ctx, message)
+	if err != nil {
+		log.Printf("Error building chat filter for /today: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Failed to load tasks: "+notion.Explain(err))
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	today := time.Now().In(h.location())
+	tasks, err := h.notionFor(message.From.ID).GetTasksDueOn(ctx, "tasks", today, filters...)
+	if err != nil {
+		log.Printf("Error retrieving tasks due today: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Failed to load tasks: "+notion.Explain(err))
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	if len(tasks) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "🌤 Nothing due today, enjoy!")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	header := fmt.Sprintf("📅 *Due today, %s* \\(%d\\)", escapeMarkdown(today.Format("02 Jan")), len(tasks))
+	lines := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		lines = append(lines, formatTaskLine(task))
+	}
+	return h.sendListing(message.Chat.ID, header, lines)
+}
+
+// handleOverdueCommand lists the tasks that aren't done and were due before today:
+// /overdue, or /overdue all for every chat
+func (h *Handler) handleOverdueCommand(message *tgbotapi.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filters, _, err := h.scopeFilters(ctx, message)
+	if err != nil {
+		log.Printf("Error building chat filter for /overdue: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Failed to load tasks: "+notion.Explain(err))
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	location := h.location()
+	tasks, err := h.notionFor(message.From.ID).GetOverdueTasks(ctx, "tasks", time.Now().In(location), maxOverdueTasks, filters...)
+	if err != nil {
+		log.Printf("Error retrieving overdue tasks: %v", err)
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ Failed to load tasks: "+notion.Explain(err))
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	if len(tasks) == 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "✅ Nothing overdue, well done!")
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	header := fmt.Sprintf("⏰ *Overdue* \\(%d\\)", len(tasks))
+	lines := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		line := formatTaskLine(task)
+		if due, ok := notion.DueDay(task, location); ok {
+			line += escapeMarkdown(", due " + due.Format("02 Jan"))
+		}
+		lines = append(lines, line)
+	}
+	return h.sendListing(message.Chat.ID, header, lines)
+}
+
+// handleFindCommand searches the titles of tasks: /find <text>, or /find all <text> for
+// tasks from every chat
+func (h *Handler) handleFindCommand(message *tgbotapi.Message) error {
+	reply := func(text string) error {
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	scope, query := h.chatScope(message)
+	if len([]rune(strings.TrimSpace(query))) < 2 {
+		return reply("Usage: /find <text>, with at least 2 characters")
+	}
+
+	// Search can't be combined with other filters, so the chat scope is applied here to a
+	// wider set of matches
+	limit := maxFindResults
+	if scope != nil {
+		limit = maxScopedFindMatches
+	}
+	tasks, err := h.notionFor(message.From.ID).SearchTasks(ctx, "tasks", query, limit)
+	if err != nil {
+		log.Printf("Error searching tasks: %v", err)
+		return reply("❌ Search failed: " + notion.Explain(err))
+	}
+	if scope != nil {
+		tasks = h.inScope(tasks, *scope)
+	}
+	if len(tasks) > maxFindResults {
+		tasks = tasks[:maxFindResults]
+	}
+
+	if len(tasks) == 0 {
+		return reply(fmt.Sprintf("🔍 No tasks match \"%s\"", query))
+	}
+
+	header := fmt.Sprintf("🔍 *%d match%s for \"%s\"*", len(tasks), pluralSuffix(len(tasks), "es"), escapeMarkdown(query))
+	lines := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		lines = append(lines, formatTaskLine(task))
+	}
+	return h.sendListing(message.Chat.ID, header, lines)
+}
+
+// inScope keeps the tasks created from the scope's chat
+func (h *Handler) inScope(tasks []notion.Task, scope notion.ChatScope) []notion.Task {
+	marker := fmt.Sprintf("[%d]", scope.ChatID)
+	kept := tasks[:0]
+	for _, task := range tasks {
+		if value, _ := task.Properties[scope.Property].(string); strings.HasSuffix(value, marker) {
+			kept = append(kept, task)
+		}
+	}
+	return kept
+}
+
+// pluralSuffix returns suffix unless n is one
+func pluralSuffix(n int, suffix string) string {
+	if n == 1 {
+		return ""
+	}
+	return suffix
+}
+
+// sendListing sends a header and the lines of a listing as MarkdownV2, split into several
+// messages when it doesn't fit in one
+func (h *Handler) sendListing(chatID int64, header string, lines []string) error {
+	lines = append([]string{header, ""}, lines...)
+
+	for _, text := range splitMessage(strings.Join(lines, "\n"), telegramMessageLimit) {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = tgbotapi.ModeMarkdownV2
+		msg.DisableWebPagePreview = true
+		if _, err := h.bot.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatTaskLine renders a task as a MarkdownV2 bullet linking to Notion, with its status
+func formatTaskLine(task notion.Task) string {
+	title := task.Title
+	if title == "" {
+		title = "Untitled"
+	}
+
+	line := "• " + escapeMarkdown(title)
+	if task.URL != "" {
+		// Inside the link target only ) and \ need escaping
+		url := strings.NewReplacer(`\`, `\\`, `)`, `\)`).Replace(task.URL)
+		line = fmt.Sprintf("• [%s](%s)", escapeMarkdown(title), url)
+	}
+	if status, ok := task.Properties["status"].(string); ok && status != "" {
+		line += " — _" + escapeMarkdown(status) + "_"
+	}
+	return line
+}
+
+// escapeMarkdown escapes text for MarkdownV2
+func escapeMarkdown(text string) string {
+	return tgbotapi.EscapeText(tgbotapi.ModeMarkdownV2, text)
+}
+
+// splitMessage splits text into messages of at most limit characters, breaking between
+// lines. A single line longer than the limit is cut.
+func splitMessage(text string, limit int) []string {
+	var messages []string
+	var current strings.Builder
+	currentLength := 0
+
+	flush := func() {
+		if currentLength > 0 {
+			messages = append(messages, current.String())
+			current.Reset()
+			currentLength = 0
+		}
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		runes := []rune(line)
+		for len(runes) > limit {
+			flush()
+			messages = append(messages, string(runes[:limit]))
+			runes = runes[limit:]
+		}
+
+		// The newline joining the line to the message counts too
+		needed := len(runes)
+		if currentLength > 0 {
+			needed++
+		}
+		if currentLength+needed > limit {
+			flush()
+			needed = len(runes)
+		}
+		if currentLength > 0 {
+			current.WriteByte('\n')
+		}
+		current.WriteString(string(runes))
+		currentLength += needed
+	}
+	flush()
+
+	return messages
+}
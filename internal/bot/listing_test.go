@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// fixedScheduler is a Scheduler running in a fixed timezone
+type fixedScheduler struct {
+	location *time.Location
+}
+
+func (s fixedScheduler) RunManualCheck()          {}
+func (s fixedScheduler) RunReconcile()            {}
+func (s fixedScheduler) Timezone() *time.Location { return s.location }
+
+func TestSplitMessage(t *testing.T) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = strings.Repeat("x", 99)
+	}
+	text := strings.Join(lines, "\n")
+
+	messages := splitMessage(text, 1000)
+	if len(messages) != 10 {
+		t.Fatalf("Expected 10 messages of 10 lines, got %d", len(messages))
+	}
+	for _, message := range messages {
+		if n := len([]rune(message)); n > 1000 {
+			t.Errorf("Message is %d characters long", n)
+		}
+	}
+	if strings.Join(messages, "\n") != text {
+		t.Error("Expected the messages to add up to the text")
+	}
+}
+
+func TestSplitMessageCutsLongLines(t *testing.T) {
+	messages := splitMessage("short\n"+strings.Repeat("ж", 2500), 1000)
+	if len(messages) != 4 || messages[0] != "short" || len([]rune(messages[3])) != 500 {
+		t.Fatalf("Expected the long line to be cut into pieces of the limit, got %d messages", len(messages))
+	}
+}
+
+func TestFormatTaskLineEscapesMarkdown(t *testing.T) {
+	task := notion.Task{
+		Title:      "Fix bug_1 (urgent)!",
+		URL:        "https://notion.so/Fix-bug-1",
+		Properties: map[string]interface{}{"status": "in-progress"},
+	}
+	want := `• [Fix bug\_1 \(urgent\)\!](https://notion.so/Fix-bug-1) — _in\-progress_`
+	if got := formatTaskLine(task); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func newTodayTestHandler(t *testing.T, results string) (*Handler, *fakeTelegram, *fakeNotionAPI) {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+
+	telegram, botAPI := newFakeTelegram(t)
+	fake := &fakeNotionAPI{results: results}
+	handler := &Handler{
+		bot:          botAPI,
+		notion:       notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+		scheduler:    fixedScheduler{location: time.UTC},
+		pendingTasks: make(map[int64]map[int]*PendingTask),
+	}
+	return handler, telegram, fake
+}
+
+func todayCommand() *tgbotapi.Message {
+	message := testMessage("/today", 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/today")}}
+	return message
+}
+
+func TestTodayListsDueTasks(t *testing.T) {
+	handler, telegram, fake := newTodayTestHandler(t, fmt.Sprintf(`[{
+		"object": "page", "id": "page-1", "url": "https://notion.so/page-1",
+		"properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Buy milk"}, "plain_text": "Buy milk"}]},
+			"Date": {"id": "date", "type": "date", "date": {"start": %q}}
+		}
+	}]`, time.Now().UTC().Format("2006-01-02")))
+
+	if err := handler.HandleMessage(todayCommand()); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.Contains(sent[0].Params.Get("text"), "[Buy milk](https://notion.so/page-1)") {
+		t.Fatalf("Expected the due task to be listed, got %v", sent)
+	}
+	if sent[0].Params.Get("parse_mode") != tgbotapi.ModeMarkdownV2 {
+		t.Errorf("Expected a MarkdownV2 message, got %q", sent[0].Params.Get("parse_mode"))
+	}
+
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if query := string(fake.requests[len(fake.requests)-1].Body); !strings.Contains(query, `"on_or_after":"`+yesterday+`T00:00:00Z"`) {
+		t.Errorf("Expected a Date filter around today, got %s", query)
+	}
+}
+
+func TestTodayWithNothingDue(t *testing.T) {
+	handler, telegram, _ := newTodayTestHandler(t, "")
+
+	if err := handler.HandleMessage(todayCommand()); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.Contains(sent[0].Params.Get("text"), "Nothing due today") {
+		t.Errorf("Expected a friendly empty message, got %v", sent)
+	}
+}
+
+func findCommand(text string) *tgbotapi.Message {
+	message := testMessage(text, 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/find")}}
+	return message
+}
+
+// taskResults renders pages with the given titles as query results
+func taskResults(titles ...string) string {
+	pages := make([]string, 0, len(titles))
+	for i, title := range titles {
+		pages = append(pages, fmt.Sprintf(`{"object": "page", "id": "page-%d", "url": "https://notion.so/page-%d",
+			"properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": %q}, "plain_text": %q}]}}}`,
+			i+1, i+1, title, title))
+	}
+	return "[" + strings.Join(pages, ",") + "]"
+}
+
+func TestFindFormatsMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		results string
+		want    []string
+	}{
+		{"no matches", "", []string{`No tasks match "milk"`}},
+		{"one match", taskResults("Buy milk"), []string{`1 match for "milk"`, "• [Buy milk](https://notion.so/page-1)"}},
+		{"many matches", taskResults("Buy milk", "Oat milk", "Milk (2%)"), []string{
+			`3 matches for "milk"`,
+			"• [Buy milk](https://notion.so/page-1)\n• [Oat milk](https://notion.so/page-2)\n• [Milk \\(2%\\)](https://notion.so/page-3)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, telegram, fake := newTodayTestHandler(t, tt.results)
+
+			if err := handler.HandleMessage(findCommand("/find milk")); err != nil {
+				t.Fatalf("HandleMessage failed: %v", err)
+			}
+
+			sent := telegram.callsTo("sendMessage")
+			if len(sent) != 1 {
+				t.Fatalf("Expected 1 message, got %d", len(sent))
+			}
+			for _, want := range tt.want {
+				if text := sent[0].Params.Get("text"); !strings.Contains(text, want) {
+					t.Errorf("Expected %q in %q", want, text)
+				}
+			}
+
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if query := string(fake.requests[len(fake.requests)-1].Body); !strings.Contains(query, `"contains":"milk"`) {
+				t.Errorf("Expected a title search, got %s", query)
+			}
+		})
+	}
+}
+
+func TestFindNeedsText(t *testing.T) {
+	handler, telegram, fake := newTodayTestHandler(t, "")
+
+	if err := handler.HandleMessage(findCommand("/find m")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if sent := telegram.callsTo("sendMessage"); len(sent) != 1 || !strings.Contains(sent[0].Params.Get("text"), "Usage: /find") {
+		t.Errorf("Expected the usage, got %v", sent)
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no Notion requests, got %d", len(fake.requests))
+	}
+}
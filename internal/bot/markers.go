@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// markerPattern matches the markers of a message: #tag and !date, at the start of a word.
+// Tags and dates are letters, digits, _ and -, so "C#" or "wow!" aren't markers.
+var markerPattern = regexp.MustCompile(`(^|\s)([#!])([\p{L}\p{N}_-]+)`)
+
+// spacesPattern matches the runs of spaces removing a marker leaves inside a line
+var spacesPattern = regexp.MustCompile(`[ \t]{2,}`)
+
+// ParseTaskText strips the markers from a message, like "call plumber #home !tomorrow".
+// Each #word is a tag, in order and without duplicates, and the first !word is a hint of
+// the date the task is due, with - and _ read as spaces ("!next-friday"). title is the rest
+// of the text, line breaks kept, empty when the message is only markers.
+func ParseTaskText(text string) (title string, tags []string, dateHint string) {
+	seen := make(map[string]bool)
+	stripped := markerPattern.ReplaceAllStringFunc(text, func(match string) string {
+		parts := markerPattern.FindStringSubmatch(match)
+		space, marker, word := parts[1], parts[2], parts[3]
+		if marker == "#" {
+			if key := strings.ToLower(word); !seen[key] {
+				seen[key] = true
+				tags = append(tags, word)
+			}
+		} else if dateHint == "" {
+			dateHint = strings.Join(strings.FieldsFunc(word, func(r rune) bool { return r == '-' || r == '_' }), " ")
+			// ISO dates keep their dashes
+			if _, err := time.Parse("2006-01-02", word); err == nil {
+				dateHint = word
+			}
+		}
+		return space
+	})
+
+	lines := strings.Split(stripped, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(spacesPattern.ReplaceAllString(line, " "))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n")), tags, dateHint
+}
+
+// splitTaskText strips the markers of a message and splits the rest into the title and
+// body of its page, as a save does. A message that is only markers is kept as it is.
+func splitTaskText(client *notion.Client, text string) (title, content string, tags []string, dateHint string) {
+	stripped, tags, dateHint := ParseTaskText(text)
+	if stripped == "" {
+		stripped, tags, dateHint = text, nil, ""
+	}
+	title, content = client.SplitContent(stripped)
+	return title, content, tags, dateHint
+}
+
+// markerProperties returns properties with the tags and the resolved date of a message's
+// markers added. The properties passed in aren't changed.
+func (h *Handler) markerProperties(ctx context.Context, properties map[string]interface{}, tags []string, dateHint string) map[string]interface{} {
+	date := h.resolveDateHint(ctx, dateHint)
+	if len(tags) == 0 && date == "" {
+		return properties
+	}
+
+	merged := make(map[string]interface{}, len(properties)+2)
+	for key, value := range properties {
+		merged[key] = value
+	}
+	if len(tags) > 0 {
+		values := make([]interface{}, len(tags))
+		for i, tag := range tags {
+			values[i] = tag
+		}
+		merged["Tags"] = values
+	}
+	if date != "" {
+		merged["Date"] = date
+	}
+	return merged
+}
+
+// resolveDateHint turns the hint of a !date marker into a YYYY-MM-DD date. ISO dates are
+// taken as they are, words like "tomorrow" need the LLM's date extraction. It returns ""
+// when the hint can't be resolved.
+func (h *Handler) resolveDateHint(ctx context.Context, hint string) string {
+	if hint == "" {
+		return ""
+	}
+	now := time.Now().In(h.location())
+	if date, err := llm.ParseDate(hint, now); err == nil {
+		return date
+	}
+	if h.dates == nil {
+		log.Printf("Warning: Can't resolve the date %q without an AI provider that extracts dates", hint)
+		return ""
+	}
+	date, err := h.dates.ExtractDate(ctx, hint, now)
+	if err != nil {
+		if !errors.Is(err, llm.ErrNoDate) {
+			log.Printf("Warning: Could not resolve the date %q: %v", hint, err)
+		}
+		return ""
+	}
+	return date
+}
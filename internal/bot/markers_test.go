@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTaskText(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		title    string
+		tags     []string
+		dateHint string
+	}{
+		{"no markers", "Call the plumber", "Call the plumber", nil, ""},
+		{"tag and date", "call plumber #home !tomorrow", "call plumber", []string{"home"}, "tomorrow"},
+		{"several tags", "#work prepare slides #urgent for #work", "prepare slides for", []string{"work", "urgent"}, ""},
+		{"mid-word markers", "learn C# and F#, email me@example.com#top wow!", "learn C# and F#, email me@example.com#top wow!", nil, ""},
+		{"unicode tags", "купить молоко #дом #café", "купить молоко", []string{"дом", "café"}, ""},
+		{"date words", "renew passport !next-friday !monday", "renew passport", nil, "next friday"},
+		{"iso date", "pay rent !2026-11-01", "pay rent", nil, "2026-11-01"},
+		{"line breaks kept", "Trip #travel\nbook hotel !friday\npack", "Trip\nbook hotel\npack", []string{"travel"}, "friday"},
+		{"only markers", "#idea", "", []string{"idea"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, tags, dateHint := ParseTaskText(tt.text)
+			if title != tt.title || !reflect.DeepEqual(tags, tt.tags) || dateHint != tt.dateHint {
+				t.Errorf("ParseTaskText(%q) = %q, %v, %q, expected %q, %v, %q", tt.text, title, tags, dateHint, tt.title, tt.tags, tt.dateHint)
+			}
+		})
+	}
+}
+
+func TestMarkersBecomeProperties(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+	fake.schema = `{"Name": {"id": "title", "type": "title", "title": {}},
+		"Tags": {"id": "tags", "type": "multi_select", "multi_select": {"options": []}},
+		"Date": {"id": "date", "type": "date", "date": {}}}`
+
+	due := time.Now().AddDate(0, 0, 3).Format("2006-01-02")
+	handler.storePendingTask(testMessage("call plumber #home !"+due, 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "call plumber" {
+		t.Fatalf("Expected the title without markers, got %v", created)
+	}
+	bodies := requestBodies(fake, http.MethodPost, "/v1/pages")
+	if !strings.Contains(bodies[0], `{"name":"home"}`) || !strings.Contains(bodies[0], `"start":"`+due) {
+		t.Errorf("Expected the tag and date to be set, got %s", bodies[0])
+	}
+}
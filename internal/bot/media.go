@@ -0,0 +1,338 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+const (
+	// defaultMaxAudioBytes is the largest file transcribed without MAX_AUDIO_BYTES, the
+	// Bot API doesn't serve bigger downloads anyway
+	defaultMaxAudioBytes = 20 << 20
+	// transcriptionTimeout bounds the download and transcription of one message
+	transcriptionTimeout = 2 * time.Minute
+)
+
+// errAudioTooLarge is returned when a file is over the MAX_AUDIO_BYTES limit
+var errAudioTooLarge = errors.New("file is too large to transcribe")
+
+// transcriptions tracks the transcriptions running in the background, so shutdown can
+// cancel them and wait for them before the database is closed
+type transcriptions struct {
+	ctx    context.Context // Cancelled on shutdown
+	cancel context.CancelFunc
+
+	mu     sync.Mutex // Guards closed, held while adding to wg
+	wg     sync.WaitGroup
+	closed bool
+}
+
+func newTranscriptions() *transcriptions {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &transcriptions{ctx: ctx, cancel: cancel}
+}
+
+// start runs transcribe in the background with a context cancelled on shutdown, reporting
+// false once shutdown began
+func (t *transcriptions) start(transcribe func(ctx context.Context)) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		transcribe(t.ctx)
+	}()
+	return true
+}
+
+// stop cancels the running transcriptions and waits for them
+func (t *transcriptions) stop() {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	t.cancel()
+	t.wg.Wait()
+}
+
+// RunTranscriptions lets media be transcribed in the background until ctx is done, then
+// cancels the transcriptions still running and waits for them
+func (h *Handler) RunTranscriptions(ctx context.Context) {
+	if h.transcriptions == nil {
+		return
+	}
+	<-ctx.Done()
+	log.Printf("Cancelling running transcriptions before shutdown")
+	h.transcriptions.stop()
+}
+
+// videoMimeTypes are the video formats Gemini accepts for transcribing their speech
+var videoMimeTypes = map[string]bool{
+	"video/mp4": true, "video/mpeg": true, "video/quicktime": true, "video/webm": true,
+	"video/3gpp": true, "video/x-flv": true, "video/x-ms-wmv": true, "video/avi": true,
+}
+
+// mediaFile is the audio or video of a message that can be transcribed
+type mediaFile struct {
+	kind     string // "voice note", "audio file", "video note" or "video", for replies
+	fileID   string
+	mimeType string
+	size     int // As reported by Telegram, 0 when unknown
+}
+
+// transcribableMedia returns the voice note, audio, video note or video of a message
+func transcribableMedia(message *tgbotapi.Message) (mediaFile, bool) {
+	withDefault := func(mimeType, fallback string) string {
+		if mimeType == "" {
+			return fallback
+		}
+		return mimeType
+	}
+
+	switch {
+	case message.Voice != nil:
+		return mediaFile{"voice note", message.Voice.FileID, withDefault(message.Voice.MimeType, "audio/ogg"), message.Voice.FileSize}, true
+	case message.Audio != nil:
+		return mediaFile{"audio file", message.Audio.FileID, withDefault(message.Audio.MimeType, "audio/mpeg"), message.Audio.FileSize}, true
+	case message.VideoNote != nil:
+		// Round video notes are always MPEG-4
+		return mediaFile{"video note", message.VideoNote.FileID, "video/mp4", message.VideoNote.FileSize}, true
+	case message.Video != nil:
+		return mediaFile{"video", message.Video.FileID, withDefault(message.Video.MimeType, "video/mp4"), message.Video.FileSize}, true
+	}
+	return mediaFile{}, false
+}
+
+// maxAudioBytesFromEnv returns the MAX_AUDIO_BYTES limit, or the default
+func maxAudioBytesFromEnv() int64 {
+	value := os.Getenv("MAX_AUDIO_BYTES")
+	if value == "" {
+		return defaultMaxAudioBytes
+	}
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit <= 0 {
+		log.Printf("Warning: Invalid MAX_AUDIO_BYTES %q, using %d", value, defaultMaxAudioBytes)
+		return defaultMaxAudioBytes
+	}
+	return limit
+}
+
+// audioLimit returns the largest file the handler transcribes
+func (h *Handler) audioLimit() int64 {
+	if h.maxAudioBytes <= 0 {
+		return defaultMaxAudioBytes
+	}
+	return h.maxAudioBytes
+}
+
+// downloadMedia fetches a file of at most limit bytes. Telegram's reported size can be
+// missing, so the body is read through a limit as well.
+func downloadMedia(ctx context.Context, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > limit {
+		return nil, errAudioTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errAudioTooLarge
+	}
+	return data, nil
+}
+
+// formatSize returns a byte count in MB, or KB below a megabyte
+func formatSize(n int64) string {
+	if n < 1<<20 {
+		return fmt.Sprintf("%d KB", (n+1023)>>10)
+	}
+	return fmt.Sprintf("%g MB", math.Round(float64(n)/(1<<20)*10)/10)
+}
+
+// tooLargeReply tells the user a file is over the transcription limit
+func tooLargeReply(media mediaFile, limit int64) string {
+	return fmt.Sprintf("❌ This %s is too large to transcribe, the limit is %s.", media.kind, formatSize(limit))
+}
+
+// reply sends a short text to a chat, logging failures
+func (h *Handler) reply(chatID int64, text string) {
+	if _, err := h.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("Warning: Could not reply to chat %d: %v", chatID, err)
+	}
+}
+
+// handleMedia checks a voice note, audio file, video note or video can be transcribed and
+// transcribes it in the background. Downloading and transcribing can take longer than
+// Telegram waits for a webhook response before delivering the update again. Without
+// background transcriptions it is transcribed in the update's goroutine.
+func (h *Handler) handleMedia(message *tgbotapi.Message, media mediaFile) error {
+	chatID := message.Chat.ID
+	limit := h.audioLimit()
+	if int64(media.size) > limit {
+		log.Printf("Not transcribing %s of %d bytes, over the %d byte limit", media.kind, media.size, limit)
+		h.reply(chatID, tooLargeReply(media, limit))
+		return nil
+	}
+	if media.kind == "video" && !videoMimeTypes[media.mimeType] {
+		log.Printf("Not transcribing video of unsupported type %s", media.mimeType)
+		h.reply(chatID, "❌ This video format can't be transcribed.")
+		return nil
+	}
+
+	if h.transcriptions == nil {
+		h.transcribeMedia(context.Background(), message, media, limit)
+		return nil
+	}
+	transcribe := func(ctx context.Context) { h.transcribeMedia(ctx, message, media, limit) }
+	if !h.transcriptions.start(transcribe) {
+		h.reply(chatID, fmt.Sprintf("❌ The bot is restarting, send the %s again in a minute.", media.kind))
+	}
+	return nil
+}
+
+// transcribeMedia downloads and transcribes a file and stores the text as a pending task.
+// ctx is cancelled on shutdown, the transcription is bounded by transcriptionTimeout.
+func (h *Handler) transcribeMedia(ctx context.Context, message *tgbotapi.Message, media mediaFile, limit int64) {
+	chatID := message.Chat.ID
+
+	ctx, cancel := context.WithTimeout(ctx, transcriptionTimeout)
+	defer cancel()
+
+	// Download file from Telegram
+	url, err := h.fileURL(media.fileID)
+	if err != nil {
+		log.Printf("Failed to get file URL: %v", err)
+		// Gracefully continue without storing
+		h.reply(chatID, fmt.Sprintf("❌ Could not access the %s.", media.kind))
+		return
+	}
+	data, err := downloadMedia(ctx, url, limit)
+	if errors.Is(err, errAudioTooLarge) {
+		log.Printf("Not transcribing %s, the download is over the %d byte limit", media.kind, limit)
+		h.reply(chatID, tooLargeReply(media, limit))
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		log.Printf("Download of %s cancelled by shutdown", media.kind)
+		h.reply(chatID, fmt.Sprintf("❌ The bot restarted before the %s was transcribed, send it again in a minute.", media.kind))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to download %s: %v", media.kind, err)
+		h.reply(chatID, fmt.Sprintf("❌ Download failed for the %s.", media.kind))
+		return
+	}
+
+	// Transcribe via the configured LLM provider
+	transcript, err := h.transcriber.TranscribeAudio(ctx, data, media.mimeType)
+	if err != nil {
+		log.Printf("Transcription failed: %v", err)
+		text := "❌ Transcription failed."
+		if errors.Is(err, llm.ErrNotSupported) {
+			text = "❌ The configured AI provider can't transcribe audio."
+		} else if errors.Is(err, context.Canceled) {
+			text = fmt.Sprintf("❌ The bot restarted before the %s was transcribed, send it again in a minute.", media.kind)
+		}
+		h.reply(chatID, text)
+		return
+	}
+
+	// Store as pending task with the transcribed text
+	message.Text = transcript
+	h.storePendingTask(message)
+
+	// Brief confirmation
+	preview := transcript
+	if len([]rune(preview)) > 200 {
+		previewRunes := []rune(preview)
+		preview = string(previewRunes[:200]) + "..."
+	}
+	sent, err := h.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📝 Transcribed. Add 👍 to save, or reply to this message to correct it.\n%s", preview)))
+	if err != nil {
+		log.Printf("Warning: Could not reply to chat %d: %v", chatID, err)
+		return
+	}
+	h.recordTranscript(chatID, message.From.ID, message.MessageID, sent.MessageID)
+}
+
+// recordTranscript links the bot's transcription reply to the pending task of the message
+func (h *Handler) recordTranscript(chatID, userID int64, messageID, transcriptMessageID int) {
+	h.mu.Lock()
+	pending := h.pendingTasks[userID][messageID]
+	if pending != nil {
+		pending.TranscriptMessageID = transcriptMessageID
+	}
+	h.mu.Unlock()
+
+	if pending != nil {
+		h.persistPendingTranscript(chatID, messageID, transcriptMessageID)
+	}
+}
+
+// handleTranscriptCorrection replaces the text of a transcribed message that is waiting for
+// its 👍 with a reply to the bot's transcription. Voice notes and videos can't be edited, so
+// this is how their text is corrected. It reports whether the message was such a reply.
+func (h *Handler) handleTranscriptCorrection(message *tgbotapi.Message) (bool, error) {
+	transcript := message.ReplyToMessage
+	if transcript == nil || transcript.From == nil || transcript.From.ID != h.bot.Self.ID ||
+		message.IsCommand() || strings.TrimSpace(message.Text) == "" {
+		return false, nil
+	}
+
+	h.mu.Lock()
+	var pending *PendingTask
+	for _, task := range h.pendingTasks[message.From.ID] {
+		if task.TranscriptMessageID == transcript.MessageID {
+			pending = task
+			break
+		}
+	}
+	if pending == nil {
+		h.mu.Unlock()
+		return false, nil
+	}
+	// Like an edit, a save in flight picks the correction up once the page exists
+	pending.Text = message.Text
+	messageID, saving := pending.MessageID, pending.saving
+	h.mu.Unlock()
+
+	if !saving {
+		h.persistPendingEdit(message.Chat.ID, messageID, message.Text)
+	}
+	log.Printf("Corrected the transcription of message %d: %s", messageID, message.Text)
+
+	// ✏️ isn't among the reactions Telegram accepts, ✍️ is the closest
+	if err := h.setMessageReaction(message.Chat.ID, message.MessageID, "✍️"); err != nil {
+		log.Printf("Warning: %v", err)
+		h.reply(message.Chat.ID, "✏️ Transcription corrected, add 👍 to the original message to save it.")
+	}
+	return true, nil
+}
@@ -0,0 +1,234 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTranscriber records the media it was asked to transcribe
+type fakeTranscriber struct {
+	mimeTypes []string
+}
+
+func (f *fakeTranscriber) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	f.mimeTypes = append(f.mimeTypes, mimeType)
+	return "Call the plumber", nil
+}
+
+// blockingTranscriber holds each transcription until its context is done
+type blockingTranscriber struct {
+	started chan struct{}
+}
+
+func (b *blockingTranscriber) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	b.started <- struct{}{}
+	<-ctx.Done()
+	return "", fmt.Errorf("transcription cancelled: %w", ctx.Err())
+}
+
+func TestTranscribableMedia(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  *tgbotapi.Message
+		kind     string
+		mimeType string
+	}{
+		{"voice", &tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "f"}}, "voice note", "audio/ogg"},
+		{"audio", &tgbotapi.Message{Audio: &tgbotapi.Audio{FileID: "f", MimeType: "audio/mp4"}}, "audio file", "audio/mp4"},
+		{"video note", &tgbotapi.Message{VideoNote: &tgbotapi.VideoNote{FileID: "f", Length: 240, FileSize: 1024}}, "video note", "video/mp4"},
+		{"video", &tgbotapi.Message{Video: &tgbotapi.Video{FileID: "f", MimeType: "video/quicktime"}}, "video", "video/quicktime"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media, ok := transcribableMedia(tt.message)
+			if !ok || media.kind != tt.kind || media.mimeType != tt.mimeType || media.fileID != "f" {
+				t.Errorf("Expected a %s of type %s, got %+v", tt.kind, tt.mimeType, media)
+			}
+		})
+	}
+
+	if _, ok := transcribableMedia(testMessage("Buy milk", 0)); ok {
+		t.Error("Expected a text message to have nothing to transcribe")
+	}
+}
+
+func TestDownloadMediaEnforcesLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// Flushing first sends the body without a Content-Length
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("a", 2048)))
+	}))
+	t.Cleanup(server.Close)
+
+	data, err := downloadMedia(context.Background(), server.URL+"/file", 4096)
+	if err != nil || len(data) != 2048 {
+		t.Fatalf("Expected the whole file, got %d bytes, %v", len(data), err)
+	}
+	for _, path := range []string{"/file", "/chunked"} {
+		if _, err := downloadMedia(context.Background(), server.URL+path, 1024); !errors.Is(err, errAudioTooLarge) {
+			t.Errorf("%s: expected errAudioTooLarge, got %v", path, err)
+		}
+	}
+}
+
+func TestLargeMediaIsRejectedBeforeDownload(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+	transcriber := &fakeTranscriber{}
+	handler.transcriber = transcriber
+	handler.maxAudioBytes = 1 << 20
+
+	message := testMessage("", 0)
+	message.VideoNote = &tgbotapi.VideoNote{FileID: "video-note", Length: 240, Duration: 30, FileSize: 5 << 20}
+	if err := handler.HandleMessage(message); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if calls := telegram.callsTo("getFile"); len(calls) != 0 {
+		t.Errorf("Expected no download, got %d getFile calls", len(calls))
+	}
+	replies := telegram.callsTo("sendMessage")
+	if len(replies) != 1 || !strings.Contains(replies[0].Params.Get("text"), "too large to transcribe, the limit is 1 MB") {
+		t.Errorf("Expected a reply naming the limit, got %v", replies)
+	}
+	if len(transcriber.mimeTypes) != 0 || handler.pendingTasks[456][123] != nil {
+		t.Error("Expected nothing to be transcribed or stored")
+	}
+}
+
+func TestUnsupportedVideoIsRejected(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+	handler.transcriber = &fakeTranscriber{}
+
+	message := testMessage("", 0)
+	message.Video = &tgbotapi.Video{FileID: "video", MimeType: "video/x-matroska", FileSize: 1024}
+	if err := handler.HandleMessage(message); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	replies := telegram.callsTo("sendMessage")
+	if len(replies) != 1 || !strings.Contains(replies[0].Params.Get("text"), "format can't be transcribed") {
+		t.Errorf("Expected a reply about the format, got %v", replies)
+	}
+}
+
+func TestVideoNoteNeedsTranscriber(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+
+	message := testMessage("", 0)
+	message.VideoNote = &tgbotapi.VideoNote{FileID: "video-note", FileSize: 1024}
+	if err := handler.HandleMessage(message); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	replies := telegram.callsTo("sendMessage")
+	if len(replies) != 1 || !strings.Contains(replies[0].Params.Get("text"), "video note needs an AI provider") {
+		t.Errorf("Expected a reply about the missing provider, got %v", replies)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{512: "1 KB", 20 << 20: "20 MB", 1536 << 10: "1.5 MB"} {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, expected %q", n, got, want)
+		}
+	}
+}
+
+func TestReplyToTranscriptionCorrectsPendingText(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+
+	// What transcribeMedia leaves behind for a voice note, the reply being message 1001
+	voice := testMessage("Buy meal", 0)
+	voice.Voice = &tgbotapi.Voice{FileID: "voice", MimeType: "audio/ogg"}
+	handler.storePendingTask(voice)
+	handler.recordTranscript(789, 456, 123, 1001)
+
+	correction := testMessage("Buy milk", 0)
+	correction.MessageID = 124
+	correction.ReplyToMessage = &tgbotapi.Message{MessageID: 1001, From: &tgbotapi.User{ID: 1, IsBot: true}, Chat: correction.Chat}
+	if err := handler.HandleMessage(correction); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if handler.pendingTasks[456][124] != nil {
+		t.Errorf("Expected the correction not to become a task of its own")
+	}
+	stored, err := handler.db.GetPendingTask(789, 123)
+	if err != nil || stored == nil || stored.Text != "Buy milk" || stored.TranscriptMessageID != 1001 {
+		t.Fatalf("Expected the stored text to be corrected, got %+v, %v", stored, err)
+	}
+	acks := telegram.callsTo("setMessageReaction")
+	if last := acks[len(acks)-1]; last.Params.Get("message_id") != "124" || !strings.Contains(last.Params.Get("reaction"), "✍") {
+		t.Errorf("Expected the correction to be acknowledged, got %v", last.Params)
+	}
+
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "Buy milk" {
+		t.Errorf("Expected the task to be created with the corrected text, got %v", created)
+	}
+}
+
+func TestShutdownCancelsRunningTranscriptions(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+	transcriber := &blockingTranscriber{started: make(chan struct{}, 1)}
+	handler.transcriber = transcriber
+	handler.transcriptions = newTranscriptions()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ogg"))
+	}))
+	t.Cleanup(server.Close)
+	handler.fileURLFunc = func(fileID string) (string, error) { return server.URL, nil }
+
+	voice := testMessage("", 0)
+	voice.Voice = &tgbotapi.Voice{FileID: "voice", FileSize: 3}
+	if err := handler.HandleMessage(voice); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	select {
+	case <-transcriber.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the voice note to be transcribed in the background")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		handler.RunTranscriptions(ctx)
+		close(stopped)
+	}()
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected shutdown to cancel the running transcription")
+	}
+	replies := telegram.callsTo("sendMessage")
+	if len(replies) != 1 || !strings.Contains(replies[0].Params.Get("text"), "restarted before the voice note was transcribed") {
+		t.Fatalf("Expected the user to be asked to send the voice note again, got %v", replies)
+	}
+
+	// Voice notes arriving during shutdown aren't started
+	voice.MessageID = 124
+	if err := handler.HandleMessage(voice); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	replies = telegram.callsTo("sendMessage")
+	if len(replies) != 2 || !strings.Contains(replies[1].Params.Get("text"), "The bot is restarting") {
+		t.Errorf("Expected the late voice note to be turned away, got %v", replies)
+	}
+	if handler.pendingTasks[456] != nil {
+		t.Errorf("Expected nothing to be stored, got %v", handler.pendingTasks[456])
+	}
+}
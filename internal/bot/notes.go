@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// savedMessageRetention is how long a reply to a saved message still adds to its page
+const savedMessageRetention = 30 * 24 * time.Hour
+
+// persistSavedMessage records the page created from a message, so replies to the message
+// find it after a restart
+func (h *Handler) persistSavedMessage(userID, chatID int64, messageID int, pageID string) {
+	if h.db == nil {
+		return
+	}
+
+	err := h.db.RecordSavedMessage(database.SavedMessage{
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    userID,
+		PageID:    pageID,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := h.db.DeleteSavedMessagesBefore(time.Now().Add(-savedMessageRetention)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// savedPage returns the page a user saved from a message, empty if they saved none
+func (h *Handler) savedPage(userID, chatID int64, messageID int) string {
+	h.mu.Lock()
+	saved, ok := h.savedMessageCache().Get(savedKey{userID, messageID})
+	h.mu.Unlock()
+	if ok {
+		return saved.TaskID
+	}
+
+	if h.db == nil {
+		return ""
+	}
+	stored, err := h.db.GetSavedMessage(chatID, messageID)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return ""
+	}
+	// Another user's page may be in a workspace the replying user can't write to
+	if stored == nil || stored.UserID != userID {
+		return ""
+	}
+	return stored.PageID
+}
+
+// handleAppendNote adds a reply to a saved message to the body of the page created from
+// it and reacts 📎. It reports whether the message was such a reply, replies to messages
+// that weren't saved become pending tasks as usual.
+func (h *Handler) handleAppendNote(message *tgbotapi.Message) (bool, error) {
+	parent := message.ReplyToMessage
+	if parent == nil || message.IsCommand() || strings.TrimSpace(message.Text) == "" {
+		return false, nil
+	}
+
+	pageID := h.savedPage(message.From.ID, message.Chat.ID, parent.MessageID)
+	if pageID == "" {
+		return false, nil
+	}
+
+	if err := h.notionFor(message.From.ID).AppendBlock(context.Background(), pageID, message.Text); err != nil {
+		h.showFeedback(message.Chat.ID, message.MessageID, feedbackFailed)
+		return true, fmt.Errorf("failed to add the reply to page %s: %w", pageID, err)
+	}
+	log.Printf("Added reply %d to the task of message %d", message.MessageID, parent.MessageID)
+	h.showFeedback(message.Chat.ID, message.MessageID, feedbackNoted)
+	return true, nil
+}
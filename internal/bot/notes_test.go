@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// replyTo returns a message of the test user replying to parent
+func replyTo(parent *tgbotapi.Message, text string) *tgbotapi.Message {
+	reply := testMessage(text, 0)
+	reply.MessageID = 124
+	reply.ReplyToMessage = parent
+	return reply
+}
+
+// appendedBlocks returns the bodies of the block appends to a page
+func appendedBlocks(fake *fakeNotionAPI, pageID string) []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var bodies []string
+	for _, r := range fake.requests {
+		if r.Method == http.MethodPatch && r.Path == "/v1/blocks/"+pageID+"/children" {
+			bodies = append(bodies, string(r.Body))
+		}
+	}
+	return bodies
+}
+
+func TestReplyToSavedMessageAppendsNote(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+
+	parent := testMessage("Buy milk", 0)
+	handler.storePendingTask(parent)
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	// After a restart the page is found in the database
+	handler.savedMessages = nil
+
+	if err := handler.HandleMessage(replyTo(parent, "Oat milk, not soy")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	appended := appendedBlocks(fake, "page-1")
+	if len(appended) != 1 || !strings.Contains(appended[0], `"content":"Oat milk, not soy"`) {
+		t.Fatalf("Expected the reply appended to page-1, got %v", appended)
+	}
+	var noted bool
+	for _, call := range telegram.callsTo("setMessageReaction") {
+		if call.Params.Get("message_id") == "124" && strings.Contains(call.Params.Get("reaction"), feedbackNoted) {
+			noted = true
+		}
+	}
+	if !noted {
+		t.Errorf("Expected a %s reaction on the reply, got %v", feedbackNoted, reactions(telegram))
+	}
+	if handler.pendingTasks[456][124] != nil {
+		t.Error("Expected the reply not to become a pending task")
+	}
+}
+
+func TestReplyToUnknownMessageIsPending(t *testing.T) {
+	handler, _, fake := newRecoveryTestHandler(t)
+
+	if err := handler.HandleMessage(replyTo(testMessage("Just chatting", 0), "Call the plumber")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if appended := appendedBlocks(fake, "page-1"); len(appended) != 0 {
+		t.Errorf("Expected nothing appended, got %v", appended)
+	}
+	if pending := handler.pendingTasks[456][124]; pending == nil || pending.Text != "Call the plumber" {
+		t.Errorf("Expected the reply stored as a pending task, got %+v", pending)
+	}
+}
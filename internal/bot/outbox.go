@@ -0,0 +1,295 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/alerts"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// Operations of outbox entries
+const (
+	outboxCreateTask   = "create_task"
+	outboxUpdateStatus = "update_task_status"
+)
+
+const (
+	// outboxPollInterval is how often RunOutbox looks for entries that are due
+	outboxPollInterval = 30 * time.Second
+	// outboxBaseDelay is the wait before the first retry, doubled with each failed one
+	outboxBaseDelay = time.Minute
+	// outboxMaxDelay caps the wait between retries
+	outboxMaxDelay = time.Hour
+	// outboxMaxAttempts is how many retries an entry gets before it's parked, about 7 hours
+	outboxMaxAttempts = 12
+	// outboxWriteTimeout bounds one retried Notion write
+	outboxWriteTimeout = 30 * time.Second
+)
+
+// errOutboxBusy skips an entry whose message is being saved right now, it's not an attempt
+var errOutboxBusy = errors.New("message is being saved")
+
+// outboxTask is a page creation from a saved message
+type outboxTask struct {
+	UserID     int64                  `json:"user_id"`
+	ChatID     int64                  `json:"chat_id"`
+	MessageID  int                    `json:"message_id"`
+	DbType     string                 `json:"db_type"`
+	Text       string                 `json:"text"` // The message as it was saved
+	Title      string                 `json:"title"`
+	Content    string                 `json:"content,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	KnownTag   string                 `json:"known_tag,omitempty"`
+	Comment    string                 `json:"comment,omitempty"` // Source comment for the page, see sourceComment
+}
+
+// outboxStatus is a status change of a task, like /done
+type outboxStatus struct {
+	UserID int64  `json:"user_id"`
+	ChatID int64  `json:"chat_id"`
+	TaskID string `json:"task_id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+}
+
+// SetAlerter sets where parked outbox entries are reported, nil only logs them
+func (h *Handler) SetAlerter(alerter alerts.Alerter) {
+	h.alerter = alerter
+}
+
+// queueWrite stores a Notion write that failed with a transient error for RunOutbox. It
+// reports false without a local database or when the write couldn't be stored.
+func (h *Handler) queueWrite(operation string, chatID int64, messageID int, payload interface{}, cause error) bool {
+	if h.db == nil {
+		return false
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Warning: Could not queue %s: %v", operation, err)
+		return false
+	}
+	now := time.Now()
+	_, err = h.db.EnqueueOutbox(database.OutboxEntry{
+		Operation:   operation,
+		ChatID:      chatID,
+		MessageID:   messageID,
+		Payload:     string(encoded),
+		NextRetryAt: now.Add(outboxBaseDelay),
+		LastError:   cause.Error(),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		log.Printf("Warning: Could not queue %s: %v", operation, err)
+		return false
+	}
+	log.Printf("Queued %s of chat %d for when Notion is back: %v", operation, chatID, cause)
+	return true
+}
+
+// dropQueuedSave removes the queued save of a message that was saved another way
+func (h *Handler) dropQueuedSave(chatID int64, messageID int) {
+	if h.db == nil {
+		return
+	}
+	if err := h.db.DeleteOutboxMessage(chatID, messageID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// RunOutbox retries the queued Notion writes until ctx is done, backing off exponentially
+// per entry. Entries that run out of attempts or that Notion rejects are parked and reported
+// to the owner.
+func (h *Handler) RunOutbox(ctx context.Context) {
+	if h.db == nil {
+		return
+	}
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+	for {
+		h.drainOutbox(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainOutbox retries the entries due by now and tells each chat how many went through
+func (h *Handler) drainOutbox(ctx context.Context, now time.Time) {
+	entries, err := h.db.GetDueOutboxEntries(now)
+	if err != nil {
+		log.Printf("Warning: Could not load the outbox: %v", err)
+		return
+	}
+
+	synced := make(map[int64]int)
+	var chats []int64
+	for _, entry := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		// A write started before shutdown is finished
+		writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), outboxWriteTimeout)
+		err := h.replayWrite(writeCtx, entry)
+		cancel()
+
+		switch {
+		case errors.Is(err, errOutboxBusy):
+			continue
+		case err == nil:
+			if err := h.db.DeleteOutboxEntry(entry.ID); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+			if synced[entry.ChatID] == 0 {
+				chats = append(chats, entry.ChatID)
+			}
+			synced[entry.ChatID]++
+		default:
+			h.retryLater(entry, now, err)
+		}
+	}
+
+	for _, chatID := range chats {
+		h.reply(chatID, syncedNotice(synced[chatID]))
+	}
+}
+
+// retryLater schedules the next attempt of an entry, or parks it
+func (h *Handler) retryLater(entry database.OutboxEntry, now time.Time, err error) {
+	attempts := entry.Attempts + 1
+	if attempts < outboxMaxAttempts && notion.IsTransient(err) {
+		delay := outboxDelay(attempts)
+		log.Printf("Queued %s %d failed (%d/%d), retrying in %v: %v", entry.Operation, entry.ID, attempts, outboxMaxAttempts, delay, err)
+		if err := h.db.RescheduleOutboxEntry(entry.ID, attempts, now.Add(delay), err.Error()); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		return
+	}
+
+	log.Printf("Parking queued %s %d after %d attempts: %v", entry.Operation, entry.ID, attempts, err)
+	if err := h.db.ParkOutboxEntry(entry.ID, attempts, err.Error()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if h.alerter != nil {
+		text := fmt.Sprintf("Gave up %s after %d attempts: %s", describeWrite(entry), attempts, notion.Explain(err))
+		if err := h.alerter.Alert(text); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// outboxDelay is the wait after the given number of failed retries
+func outboxDelay(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
+
+// replayWrite runs the Notion write of an entry again
+func (h *Handler) replayWrite(ctx context.Context, entry database.OutboxEntry) error {
+	switch entry.Operation {
+	case outboxCreateTask:
+		var task outboxTask
+		if err := json.Unmarshal([]byte(entry.Payload), &task); err != nil {
+			return fmt.Errorf("invalid queued task: %w", err)
+		}
+		return h.replayCreate(ctx, task)
+	case outboxUpdateStatus:
+		var update outboxStatus
+		if err := json.Unmarshal([]byte(entry.Payload), &update); err != nil {
+			return fmt.Errorf("invalid queued status update: %w", err)
+		}
+		if err := h.notionFor(update.UserID).UpdateTaskStatus(ctx, update.TaskID, update.Status, nil); err != nil {
+			return err
+		}
+		log.Printf("Queued status %s of task %s reached Notion", update.Status, update.TaskID)
+		return nil
+	}
+	return fmt.Errorf("unknown outbox operation %q", entry.Operation)
+}
+
+// replayCreate creates the page of a queued save. The message stayed pending, so it's
+// claimed like a save and dropped once the page exists.
+func (h *Handler) replayCreate(ctx context.Context, task outboxTask) error {
+	h.mu.Lock()
+	pendingTask := h.pendingTasks[task.UserID][task.MessageID]
+	if pendingTask != nil {
+		if pendingTask.saving {
+			h.mu.Unlock()
+			return errOutboxBusy
+		}
+		pendingTask.saving = true
+	}
+	h.mu.Unlock()
+
+	client := h.notionFor(task.UserID)
+	startedAt := time.Now()
+	taskID, err := client.CreateTaskWithContent(notion.WithNewOptions(ctx), task.Title, task.Content, task.Properties, task.DbType)
+
+	h.mu.Lock()
+	if err != nil {
+		if pendingTask != nil {
+			pendingTask.saving = false
+		}
+		h.mu.Unlock()
+		return err
+	}
+	delete(h.pendingTasks[task.UserID], task.MessageID)
+	h.recordSavedMessage(task.UserID, task.MessageID, &savedMessage{
+		TaskID:        taskID,
+		Title:         task.Text,
+		SaveStartedAt: startedAt,
+	})
+	h.mu.Unlock()
+
+	log.Printf("Queued save of message %d reached Notion as %s", task.MessageID, taskID)
+	h.forgetPendingTask(task.ChatID, task.MessageID)
+	h.finishSaveAttempt(task.ChatID, task.MessageID, database.SaveStateDone, taskID)
+	h.persistSavedMessage(task.UserID, task.ChatID, task.MessageID, taskID)
+	h.attachSourceComment(ctx, client, taskID, task.Comment)
+	if client.IsTaskDatabase(task.DbType) {
+		h.recordTaskMetadata(task.UserID, taskID, task.Text, task.KnownTag)
+		if h.tagger != nil {
+			h.tagSavedTask(ctx, client, taskID, task.Text, task.KnownTag)
+		}
+	}
+	h.showFeedback(task.ChatID, task.MessageID, feedbackSaved)
+	return nil
+}
+
+// describeWrite names the write of an entry for the owner
+func describeWrite(entry database.OutboxEntry) string {
+	switch entry.Operation {
+	case outboxCreateTask:
+		var task outboxTask
+		if json.Unmarshal([]byte(entry.Payload), &task) == nil {
+			return fmt.Sprintf("saving %q to Notion", truncateRunes(task.Title, 80))
+		}
+	case outboxUpdateStatus:
+		var update outboxStatus
+		if json.Unmarshal([]byte(entry.Payload), &update) == nil {
+			return fmt.Sprintf("setting %q to %s in Notion", truncateRunes(update.Title, 80), update.Status)
+		}
+	}
+	return "a queued Notion write"
+}
+
+// syncedNotice tells a chat how many of its queued writes went through
+func syncedNotice(n int) string {
+	if n == 1 {
+		return "✅ 1 queued task was synced to Notion"
+	}
+	return fmt.Sprintf("✅ %d queued tasks were synced to Notion", n)
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// downNotion answers page creations with 503 while down and passes the other requests to the fake
+type downNotion struct {
+	*fakeNotionAPI
+	down     atomic.Bool
+	refusals atomic.Int32 // Page creations answered with 503
+}
+
+func (d *downNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !d.down.Load() || req.Method != http.MethodPost || req.URL.Path != "/v1/pages" {
+		return d.fakeNotionAPI.RoundTrip(req)
+	}
+	d.refusals.Add(1)
+	return &http.Response{
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"object": "error", "status": 503, "code": "service_unavailable", "message": "Notion is unavailable"}`)),
+		Request:    req,
+	}, nil
+}
+
+// recordingAlerter keeps the alerts it was asked to send
+type recordingAlerter struct {
+	alerts []string
+}
+
+func (a *recordingAlerter) Alert(text string) error {
+	a.alerts = append(a.alerts, text)
+	return nil
+}
+
+// newOutboxTestHandler creates a handler whose Notion is down until told otherwise
+func newOutboxTestHandler(t *testing.T) (*Handler, *fakeTelegram, *downNotion) {
+	t.Helper()
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	notionAPI := &downNotion{fakeNotionAPI: fake}
+	notionAPI.down.Store(true)
+	handler.notion = notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: notionAPI}))
+	return handler, telegram, notionAPI
+}
+
+// saveWhileDown reacts with 👍 to a new message while Notion is down
+func saveWhileDown(t *testing.T, handler *Handler, messageID int, text string) {
+	t.Helper()
+	message := testMessage(text, 0)
+	message.MessageID = messageID
+	handler.storePendingTask(message)
+
+	reaction := thumbsUp()
+	reaction.MessageID = messageID
+	if err := handler.HandleMessageReaction(context.Background(), reaction); err == nil {
+		t.Fatalf("Expected the save of message %d to fail", messageID)
+	}
+}
+
+func TestFailedSaveIsQueued(t *testing.T) {
+	handler, telegram, _ := newOutboxTestHandler(t)
+
+	saveWhileDown(t, handler, 123, "Buy milk")
+
+	due, err := handler.db.GetDueOutboxEntries(time.Now().Add(outboxBaseDelay))
+	if err != nil {
+		t.Fatalf("GetDueOutboxEntries failed: %v", err)
+	}
+	if len(due) != 1 || due[0].Operation != outboxCreateTask || due[0].MessageID != 123 || !strings.Contains(due[0].Payload, `"title":"Buy milk"`) {
+		t.Fatalf("Expected the save to be queued, got %+v", due)
+	}
+	if early, _ := handler.db.GetDueOutboxEntries(time.Now()); len(early) != 0 {
+		t.Errorf("Expected the first retry to wait, got %+v", early)
+	}
+
+	// The message stays pending, in memory and stored
+	if pending := handler.pendingTasks[456][123]; pending == nil || pending.saving {
+		t.Errorf("Expected the message to stay pending, got %+v", pending)
+	}
+	if stored, _ := handler.db.GetPendingTask(789, 123); stored == nil || stored.Text != "Buy milk" {
+		t.Errorf("Expected the pending task to stay stored, got %+v", stored)
+	}
+	if sent := telegram.callsTo("sendMessage"); len(sent) != 1 || !strings.Contains(sent[0].Params.Get("text"), "queued") {
+		t.Errorf("Expected a note that the task is queued, got %+v", sent)
+	}
+
+	// A repeated 👍 that fails again doesn't queue the message twice
+	reaction := thumbsUp()
+	handler.HandleMessageReaction(context.Background(), reaction)
+	if due, _ := handler.db.GetDueOutboxEntries(time.Now().Add(outboxBaseDelay)); len(due) != 1 {
+		t.Errorf("Expected one queued save, got %d", len(due))
+	}
+}
+
+func TestOutboxSyncsQueuedSaves(t *testing.T) {
+	handler, telegram, notionAPI := newOutboxTestHandler(t)
+	saveWhileDown(t, handler, 123, "Buy milk")
+	saveWhileDown(t, handler, 124, "Call the plumber")
+	saveWhileDown(t, handler, 125, "Water the plants")
+
+	// Before the first retry is due nothing happens
+	handler.drainOutbox(context.Background(), time.Now())
+	if created := notionAPI.titles(t, http.MethodPost, "/v1/pages"); len(created) != 0 {
+		t.Fatalf("Expected no retries before they are due, got %v", created)
+	}
+
+	notionAPI.down.Store(false)
+	handler.drainOutbox(context.Background(), time.Now().Add(outboxBaseDelay))
+
+	created := notionAPI.titles(t, http.MethodPost, "/v1/pages")
+	if strings.Join(created, ", ") != "Buy milk, Call the plumber, Water the plants" {
+		t.Errorf("Expected the queued tasks to be created in order, got %v", created)
+	}
+	if due, _ := handler.db.GetDueOutboxEntries(time.Now().Add(24 * time.Hour)); len(due) != 0 {
+		t.Errorf("Expected the outbox to be empty, got %+v", due)
+	}
+	if pending, _ := handler.db.GetPendingTasks(); len(pending) != 0 || len(handler.pendingTasks[456]) != 0 {
+		t.Errorf("Expected synced messages to no longer be pending, got %+v", pending)
+	}
+
+	got := reactions(telegram)
+	if len(got) == 0 || !strings.Contains(got[len(got)-1], feedbackSaved) {
+		t.Errorf("Expected the reaction to flip to 👍, got %v", got)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if last := sent[len(sent)-1].Params.Get("text"); last != "✅ 3 queued tasks were synced to Notion" {
+		t.Errorf("Expected the sync notice, got %q", last)
+	}
+
+	// The synced pages take later edits
+	edited := testMessage("Buy oat milk", int(time.Now().Unix()))
+	if err := handler.HandleEditedMessage(edited); err != nil {
+		t.Fatalf("HandleEditedMessage failed: %v", err)
+	}
+	if updates := notionAPI.titles(t, http.MethodPatch, "/v1/pages/page-1"); len(updates) != 1 {
+		t.Errorf("Expected the edit to update the synced page, got %v", updates)
+	}
+}
+
+func TestOutboxParksAfterMaxAttempts(t *testing.T) {
+	handler, telegram, notionAPI := newOutboxTestHandler(t)
+	alerter := &recordingAlerter{}
+	handler.SetAlerter(alerter)
+	saveWhileDown(t, handler, 123, "Buy milk")
+	sentBefore := len(telegram.callsTo("sendMessage"))
+
+	now := time.Now()
+	for i := 1; i <= outboxMaxAttempts; i++ {
+		now = now.Add(outboxDelay(i))
+		handler.drainOutbox(context.Background(), now)
+	}
+
+	parked, err := handler.db.GetParkedOutboxEntries()
+	if err != nil {
+		t.Fatalf("GetParkedOutboxEntries failed: %v", err)
+	}
+	if len(parked) != 1 || parked[0].Attempts != outboxMaxAttempts || !strings.Contains(parked[0].LastError, "unavailable") {
+		t.Fatalf("Expected the entry parked after %d attempts, got %+v", outboxMaxAttempts, parked)
+	}
+	if len(alerter.alerts) != 1 || !strings.Contains(alerter.alerts[0], `"Buy milk"`) {
+		t.Errorf("Expected one alert naming the task, got %v", alerter.alerts)
+	}
+
+	// Parked entries aren't retried any more, the message can still be saved with 👍
+	if n := notionAPI.refusals.Load(); n != 3+outboxMaxAttempts {
+		t.Errorf("Expected 3 attempts of the save and %d retries, got %d", outboxMaxAttempts, n)
+	}
+	handler.drainOutbox(context.Background(), now.Add(24*time.Hour))
+	if n := notionAPI.refusals.Load(); n != 3+outboxMaxAttempts {
+		t.Errorf("Expected parked entries to be left alone")
+	}
+	if sent := telegram.callsTo("sendMessage"); len(sent) != sentBefore {
+		t.Errorf("Expected no sync notice, got %+v", sent[sentBefore:])
+	}
+	if handler.pendingTasks[456][123] == nil {
+		t.Errorf("Expected the message to stay pending")
+	}
+}
+
+func TestOutboxDelay(t *testing.T) {
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 32 * time.Minute, time.Hour, time.Hour}
+	for i, delay := range expected {
+		if got := outboxDelay(i + 1); got != delay {
+			t.Errorf("outboxDelay(%d) = %v, expected %v", i+1, got, delay)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// maxPauseDays is the longest /pause accepted
+const maxPauseDays = 90
+
+// handlePauseCommand silences the scheduler's checks, reviews and reminders for a number of
+// days: /pause <days>. Without days it tells whether notifications are paused.
+func (h *Handler) handlePauseCommand(message *tgbotapi.Message) error {
+	if h.db == nil {
+		h.reply(message.Chat.ID, "❌ Pausing notifications needs the local database")
+		return nil
+	}
+
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		h.reply(message.Chat.ID, h.pauseStatus(message.From.ID, time.Now())+"\n\nUsage: /pause <days>, /resume to end the pause early")
+		return nil
+	}
+	days, err := strconv.Atoi(arg)
+	if err != nil || days < 1 || days > maxPauseDays {
+		h.reply(message.Chat.ID, fmt.Sprintf("❌ Give the number of days to pause for, between 1 and %d, like /pause 7", maxPauseDays))
+		return nil
+	}
+
+	until := time.Now().AddDate(0, 0, days)
+	if err := h.db.SetPausedUntil(message.From.ID, until); err != nil {
+		log.Printf("Failed to pause notifications: %v", err)
+		h.reply(message.Chat.ID, "❌ Failed to pause notifications.")
+		return nil
+	}
+	h.reply(message.Chat.ID, fmt.Sprintf("🔕 Notifications paused until %s. /cron still runs a check, /resume ends the pause.", formatPauseEnd(until, h.location())))
+	return nil
+}
+
+// handleResumeCommand ends a pause started with /pause
+func (h *Handler) handleResumeCommand(message *tgbotapi.Message) error {
+	if h.db == nil {
+		h.reply(message.Chat.ID, "❌ Pausing notifications needs the local database")
+		return nil
+	}
+
+	until, err := h.db.GetPausedUntil(message.From.ID)
+	if err == nil {
+		_, err = h.db.ClearPause(message.From.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to resume notifications: %v", err)
+		h.reply(message.Chat.ID, "❌ Failed to resume notifications.")
+		return nil
+	}
+	if !time.Now().Before(until) {
+		h.reply(message.Chat.ID, "Notifications aren't paused.")
+		return nil
+	}
+	h.reply(message.Chat.ID, "🔔 Notifications resumed.")
+	return nil
+}
+
+// pauseStatus describes whether a user's notifications are paused at now
+func (h *Handler) pauseStatus(userID int64, now time.Time) string {
+	until, err := h.db.GetPausedUntil(userID)
+	if err != nil {
+		log.Printf("Warning: Could not read whether notifications are paused: %v", err)
+		return "Could not read whether notifications are paused."
+	}
+	if !now.Before(until) {
+		return "🔔 Notifications are on."
+	}
+	return fmt.Sprintf("🔕 Notifications are paused until %s.", formatPauseEnd(until, h.location()))
+}
+
+// formatPauseEnd renders the end of a pause in the scheduler's timezone
+func formatPauseEnd(until time.Time, location *time.Location) string {
+	return until.In(location).Format("Mon 02 Jan 15:04")
+}
@@ -0,0 +1,66 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func botCommand(command, arg string) *tgbotapi.Message {
+	message := testMessage(strings.TrimSpace(command+" "+arg), 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	return message
+}
+
+func TestPauseAndResume(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+	lastReply := func() string {
+		sent := telegram.callsTo("sendMessage")
+		if len(sent) == 0 {
+			return ""
+		}
+		return sent[len(sent)-1].Params.Get("text")
+	}
+
+	for _, arg := range []string{"soon", "0", "365"} {
+		if err := handler.HandleMessage(botCommand("/pause", arg)); err != nil {
+			t.Fatalf("HandleMessage failed: %v", err)
+		}
+		if !strings.Contains(lastReply(), "number of days") {
+			t.Errorf("Expected /pause %s to be rejected, got %q", arg, lastReply())
+		}
+	}
+
+	if err := handler.HandleMessage(botCommand("/pause", "3")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	until, err := handler.db.GetPausedUntil(456)
+	if err != nil {
+		t.Fatalf("GetPausedUntil failed: %v", err)
+	}
+	if want := time.Now().AddDate(0, 0, 3); until.Before(want.Add(-time.Minute)) || until.After(want) {
+		t.Errorf("Expected a pause of 3 days, got until %v", until)
+	}
+	if !strings.Contains(lastReply(), "paused until") {
+		t.Errorf("Expected the end of the pause in the reply, got %q", lastReply())
+	}
+
+	if err := handler.HandleMessage(botCommand("/pause", "")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if !strings.Contains(lastReply(), "Notifications are paused until") {
+		t.Errorf("Expected /pause to report the pause, got %q", lastReply())
+	}
+
+	if err := handler.HandleMessage(botCommand("/resume", "")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if !strings.Contains(lastReply(), "resumed") {
+		t.Errorf("Expected notifications to be resumed, got %q", lastReply())
+	}
+	if until, err := handler.db.GetPausedUntil(456); err != nil || !until.IsZero() {
+		t.Errorf("Expected the pause to be cleared, got %v, %v", until, err)
+	}
+}
@@ -0,0 +1,271 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
+)
+
+const (
+	// pendingTaskRetention is how long a stored message waits for its 👍 before it's
+	// dropped, unless PENDING_TASK_TTL sets another duration
+	pendingTaskRetention = 7 * 24 * time.Hour
+	// defaultMaxPendingPerUser is how many messages of a user wait for their 👍 without
+	// MAX_PENDING_PER_USER, the oldest are dropped beyond it
+	defaultMaxPendingPerUser = 200
+	// pendingSweepInterval is how often pending tasks past their TTL are dropped
+	pendingSweepInterval = 10 * time.Minute
+)
+
+// pendingTTLFromEnv returns the duration of PENDING_TASK_TTL, like "72h"
+func pendingTTLFromEnv() time.Duration {
+	value := os.Getenv("PENDING_TASK_TTL")
+	if value == "" {
+		return pendingTaskRetention
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		log.Printf("Warning: Invalid PENDING_TASK_TTL %q, using %s", value, pendingTaskRetention)
+		return pendingTaskRetention
+	}
+	return ttl
+}
+
+// maxPendingPerUserFromEnv returns the cap of MAX_PENDING_PER_USER
+func maxPendingPerUserFromEnv() int {
+	value := os.Getenv("MAX_PENDING_PER_USER")
+	if value == "" {
+		return defaultMaxPendingPerUser
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		log.Printf("Warning: Invalid MAX_PENDING_PER_USER %q, using %d", value, defaultMaxPendingPerUser)
+		return defaultMaxPendingPerUser
+	}
+	return limit
+}
+
+// pendingLimit returns how many pending tasks a user keeps
+func (h *Handler) pendingLimit() int {
+	if h.maxPendingPerUser <= 0 {
+		return defaultMaxPendingPerUser
+	}
+	return h.maxPendingPerUser
+}
+
+// pendingTTL returns how long a pending task waits for its 👍
+func (h *Handler) pendingTTL() time.Duration {
+	if h.pendingTaskTTL <= 0 {
+		return pendingTaskRetention
+	}
+	return h.pendingTaskTTL
+}
+
+// evictOldestPending removes the oldest pending tasks of a user beyond the cap and returns
+// them. Tasks being saved are kept. Callers must hold h.mu.
+func (h *Handler) evictOldestPending(userID int64) []*PendingTask {
+	tasks := h.pendingTasks[userID]
+	excess := len(tasks) - h.pendingLimit()
+	if excess <= 0 {
+		return nil
+	}
+
+	candidates := make([]*PendingTask, 0, len(tasks))
+	for _, task := range tasks {
+		if !task.saving {
+			candidates = append(candidates, task)
+		}
+	}
+	// Message IDs grow within a chat and break ties of messages sent in the same second
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].ReceivedAt.Equal(candidates[j].ReceivedAt) {
+			return candidates[i].ReceivedAt.Before(candidates[j].ReceivedAt)
+		}
+		return candidates[i].MessageID < candidates[j].MessageID
+	})
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+
+	evicted := candidates[:excess]
+	for _, task := range evicted {
+		delete(tasks, task.MessageID)
+	}
+	h.pendingEvicted += len(evicted)
+	return evicted
+}
+
+// RunPendingSweeper drops pending tasks older than their TTL until ctx is done
+func (h *Handler) RunPendingSweeper(ctx context.Context) {
+	ticker := time.NewTicker(pendingSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.expirePendingTasks(time.Now())
+		}
+	}
+}
+
+// expirePendingTasks drops the pending tasks received before their TTL ended at now and
+// clears their 🤔, so the messages don't look pending forever. It returns how many it dropped.
+func (h *Handler) expirePendingTasks(now time.Time) int {
+	cutoff := now.Add(-h.pendingTTL())
+
+	h.mu.Lock()
+	var expired []*PendingTask
+	for userID, tasks := range h.pendingTasks {
+		for messageID, task := range tasks {
+			if !task.saving && task.ReceivedAt.Before(cutoff) {
+				expired = append(expired, task)
+				delete(tasks, messageID)
+			}
+		}
+		if len(tasks) == 0 {
+			delete(h.pendingTasks, userID)
+		}
+	}
+	h.pendingExpired += len(expired)
+	h.mu.Unlock()
+
+	h.dropPendingTasks(expired)
+	if len(expired) > 0 {
+		log.Printf("Dropped %d pending task(s) that waited over %s for their 👍", len(expired), h.pendingTTL())
+		metrics.RecordPendingDropped("expired", len(expired))
+	}
+	return len(expired)
+}
+
+// dropPendingTasks forgets the stored copies of pending tasks removed from memory and
+// clears the 🤔 on their messages
+func (h *Handler) dropPendingTasks(tasks []*PendingTask) {
+	for _, task := range tasks {
+		h.forgetPendingTask(task.ChatID, task.MessageID)
+		h.clearFeedback(task.ChatID, task.MessageID)
+	}
+}
+
+// pendingDropped returns how many pending tasks were evicted over the cap and expired
+// since the bot started
+func (h *Handler) pendingDropped() (evicted, expired int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.pendingEvicted, h.pendingExpired
+}
+
+// persistPendingTask writes a new pending task through to the local database, so it
+// survives a restart
+func (h *Handler) persistPendingTask(userID, chatID int64, task *PendingTask) {
+	if h.db == nil {
+		return
+	}
+
+	err := h.db.StorePendingTask(database.PendingTask{
+		UserID:     userID,
+		ChatID:     chatID,
+		MessageID:  task.MessageID,
+		Text:       task.Text,
+		SourceChat: task.SourceChat,
+		SourceURL:  task.SourceURL,
+		CreatedAt:  time.Now(),
+		Origin:     task.Origin,
+
+		TranscriptMessageID: task.TranscriptMessageID,
+		PhotoFileID:         task.PhotoFileID,
+	})
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := h.db.DeletePendingTasksBefore(time.Now().Add(-h.pendingTTL())); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// persistPendingEdit stores the edited text of a pending task
+func (h *Handler) persistPendingEdit(chatID int64, messageID int, text string) {
+	if h.db == nil {
+		return
+	}
+
+	if err := h.db.UpdatePendingTaskText(chatID, messageID, text); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// persistPendingTranscript stores the bot's transcription reply of a pending task
+func (h *Handler) persistPendingTranscript(chatID int64, messageID, transcriptMessageID int) {
+	if h.db == nil {
+		return
+	}
+
+	if err := h.db.SetPendingTaskTranscript(chatID, messageID, transcriptMessageID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// forgetPendingTask removes a stored pending task once a save claims it. From then on the
+// save attempt record covers restarts.
+func (h *Handler) forgetPendingTask(chatID int64, messageID int) {
+	if h.db == nil {
+		return
+	}
+
+	if err := h.db.DeletePendingTask(chatID, messageID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// restorePendingTasks loads the pending tasks stored before a restart, dropping ones older
+// than their TTL. Their messages still show 🤔 and can be saved with 👍.
+func (h *Handler) restorePendingTasks() {
+	if h.db == nil {
+		return
+	}
+
+	if err := h.db.DeletePendingTasksBefore(time.Now().Add(-h.pendingTTL())); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	tasks, err := h.db.GetPendingTasks()
+	if err != nil {
+		log.Printf("Warning: Could not load pending tasks: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.pendingTasks == nil {
+		h.pendingTasks = make(map[int64]map[int]*PendingTask)
+	}
+	for _, task := range tasks {
+		if h.pendingTasks[task.UserID] == nil {
+			h.pendingTasks[task.UserID] = make(map[int]*PendingTask)
+		}
+		h.pendingTasks[task.UserID][task.MessageID] = &PendingTask{
+			ChatID:     task.ChatID,
+			MessageID:  task.MessageID,
+			Text:       task.Text,
+			SourceChat: task.SourceChat,
+			SourceURL:  task.SourceURL,
+			Origin:     task.Origin,
+			ReceivedAt: task.CreatedAt,
+			// Without the chat's username public groups get their t.me/c link
+			MessageLink: messageLink(&tgbotapi.Chat{ID: task.ChatID}, task.MessageID),
+
+			TranscriptMessageID: task.TranscriptMessageID,
+			PhotoFileID:         task.PhotoFileID,
+		}
+	}
+	if len(tasks) > 0 {
+		log.Printf("Restored %d pending tasks", len(tasks))
+	}
+}
@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// clearedReactions returns the IDs of the messages whose reaction was cleared
+func clearedReactions(f *fakeTelegram) []string {
+	var ids []string
+	for _, call := range f.callsTo("setMessageReaction") {
+		if call.Params.Get("reaction") == "[]" {
+			ids = append(ids, call.Params.Get("message_id"))
+		}
+	}
+	return ids
+}
+
+func TestPendingTasksEvictOldestFirst(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+	handler.maxPendingPerUser = 2
+	sent := time.Now().Add(-time.Hour)
+
+	for i, text := range []string{"Buy milk", "Call dentist", "Renew passport", "Water plants"} {
+		message := testMessage(text, 0)
+		message.MessageID = i + 1
+		message.Date = int(sent.Add(time.Duration(i) * time.Minute).Unix())
+		handler.storePendingTask(message)
+		if i == 0 {
+			// A task being saved is never evicted
+			handler.pendingTasks[456][1].saving = true
+		}
+	}
+
+	pending := handler.pendingTasks[456]
+	if len(pending) != 2 || pending[1] == nil || pending[4] == nil {
+		t.Fatalf("Expected the saving task and the newest one kept, got %v", pending)
+	}
+	if got := clearedReactions(telegram); len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("Expected the 🤔 cleared on messages 2 and 3, got %v", got)
+	}
+	if stored, err := handler.db.GetPendingTask(789, 2); err != nil || stored != nil {
+		t.Errorf("Expected the evicted task dropped from the database, got %+v, %v", stored, err)
+	}
+	if evicted, _ := handler.pendingDropped(); evicted != 2 {
+		t.Errorf("Expected 2 evictions counted, got %d", evicted)
+	}
+}
+
+func TestPendingTasksExpire(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+	handler.pendingTaskTTL = time.Hour
+
+	old := testMessage("Buy milk", 0)
+	old.Date = int(time.Now().Add(-2 * time.Hour).Unix())
+	handler.storePendingTask(old)
+	recent := testMessage("Call dentist", 0)
+	recent.MessageID = 124
+	handler.storePendingTask(recent)
+
+	if n := handler.expirePendingTasks(time.Now()); n != 1 {
+		t.Fatalf("Expected one task expired, got %d", n)
+	}
+	if pending := handler.pendingTasks[456]; len(pending) != 1 || pending[124] == nil {
+		t.Errorf("Expected only the recent task left, got %v", pending)
+	}
+	if got := clearedReactions(telegram); len(got) != 1 || got[0] != "123" {
+		t.Errorf("Expected the 🤔 cleared on message 123, got %v", got)
+	}
+	if stored, err := handler.db.GetPendingTask(789, 123); err != nil || stored != nil {
+		t.Errorf("Expected the expired task dropped from the database, got %+v, %v", stored, err)
+	}
+	if _, expired := handler.pendingDropped(); expired != 1 {
+		t.Errorf("Expected 1 expiry counted, got %d", expired)
+	}
+
+	// Once every task is gone the user's map goes too
+	handler.expirePendingTasks(time.Now().Add(2 * time.Hour))
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if _, ok := handler.pendingTasks[456]; ok {
+		t.Error("Expected the empty map of the user removed")
+	}
+}
+
+func TestPendingTasksConcurrentAccess(t *testing.T) {
+	handler, _, _ := newRecoveryTestHandler(t)
+	handler.maxPendingPerUser = 5
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			message := testMessage("Buy milk", 0)
+			message.MessageID = 1000 + i
+			handler.storePendingTask(message)
+
+			message.Text, message.EditDate = "Buy oat milk", 1
+			if err := handler.HandleEditedMessage(message); err != nil {
+				t.Errorf("HandleEditedMessage failed: %v", err)
+			}
+			handler.expirePendingTasks(time.Now())
+		}(i)
+	}
+	wg.Wait()
+
+	handler.mu.Lock()
+	defer handler.mu.Unlock()
+	if n := len(handler.pendingTasks[456]); n != 5 {
+		t.Errorf("Expected the cap of 5 pending tasks, got %d", n)
+	}
+}
@@ -0,0 +1,167 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// photoURLProperty is the url property that links a photo in databases without a files
+	// property
+	photoURLProperty = "photo"
+	// photoTitle is the title of a photo sent without a caption
+	photoTitle = "Photo"
+	// uploadCleanupInterval is how often photos of saves that never finished are removed
+	uploadCleanupInterval = time.Hour
+)
+
+// photoSize returns the largest size of a photo within limit. Sizes Telegram didn't report
+// count as fitting, the download is limited as well.
+func photoSize(sizes []tgbotapi.PhotoSize, limit int64) (tgbotapi.PhotoSize, bool) {
+	var best tgbotapi.PhotoSize
+	found := false
+	for _, size := range sizes {
+		if int64(size.FileSize) > limit {
+			continue
+		}
+		if !found || size.Width*size.Height > best.Width*best.Height {
+			best, found = size, true
+		}
+	}
+	return best, found
+}
+
+// messagePhoto returns the Telegram file of the photo attached to a message's task, empty
+// when there is none, photos can't be stored or every size is too large
+func (h *Handler) messagePhoto(message *tgbotapi.Message) string {
+	if len(message.Photo) == 0 || !h.uploads.Enabled() {
+		return ""
+	}
+	size, ok := photoSize(message.Photo, h.uploads.Limit())
+	if !ok {
+		return ""
+	}
+	return size.FileID
+}
+
+// handlePhoto stores a photo as a pending task titled by its caption. The photo itself is
+// downloaded when the task is saved, see stagePhoto.
+func (h *Handler) handlePhoto(message *tgbotapi.Message) error {
+	if h.uploads.Enabled() && h.messagePhoto(message) == "" {
+		h.reply(message.Chat.ID, fmt.Sprintf("❌ This photo is too large to attach, the limit is %s. Its caption is saved without it.", formatSize(h.uploads.Limit())))
+	}
+	h.storePendingTask(message)
+	log.Printf("Stored photo %d as pending task: %s", message.MessageID, messageText(message))
+	return nil
+}
+
+// photoProperty returns the property of a database that links photos: its first files
+// property, or the url property named photo. It's empty when there is neither.
+func photoProperty(ctx context.Context, client *notion.Client, dbType string) string {
+	props, err := client.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		log.Printf("Warning: Could not check the %s database for a files property: %v", dbType, err)
+		return ""
+	}
+	keys := make([]string, 0, len(props))
+	for key := range props {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if props[key].GetType() == "files" {
+			return key
+		}
+	}
+	for _, key := range keys {
+		if strings.EqualFold(key, photoURLProperty) && props[key].GetType() == "url" {
+			return key
+		}
+	}
+	return ""
+}
+
+// stagePhoto downloads a photo into the upload store for the page to link. It returns the
+// staged file and the properties linking it, none when the database has no property for it
+// or the download failed, so the task is saved without the photo.
+func (h *Handler) stagePhoto(ctx context.Context, client *notion.Client, dbType, fileID string) (string, map[string]interface{}) {
+	if fileID == "" || !h.uploads.Enabled() {
+		return "", nil
+	}
+	key := photoProperty(ctx, client, dbType)
+	if key == "" {
+		log.Printf("Not attaching the photo, the %s database has no files property", dbType)
+		return "", nil
+	}
+
+	url, err := h.fileURL(fileID)
+	if err != nil {
+		log.Printf("Warning: Could not get the photo's URL: %v", err)
+		return "", nil
+	}
+	data, err := downloadMedia(ctx, url, h.uploads.Limit())
+	if err != nil {
+		log.Printf("Warning: Could not download the photo: %v", err)
+		return "", nil
+	}
+	// Telegram sends photos as JPEG
+	name, err := h.uploads.Stage(data, "jpg")
+	if err != nil {
+		log.Printf("Warning: Could not store the photo: %v", err)
+		return "", nil
+	}
+	return name, map[string]interface{}{key: h.uploads.URL(name)}
+}
+
+// finishPhoto serves a staged photo once a page links it, or removes it
+func (h *Handler) finishPhoto(name string, linked bool) {
+	if name == "" {
+		return
+	}
+	if !linked {
+		h.uploads.Discard(name)
+		return
+	}
+	if err := h.uploads.Commit(name); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// fileURL returns the download URL of a Telegram file
+func (h *Handler) fileURL(fileID string) (string, error) {
+	if h.fileURLFunc != nil {
+		return h.fileURLFunc(fileID)
+	}
+	return h.bot.GetFileDirectURL(fileID)
+}
+
+// RunUploadCleanup removes photos staged for saves that stopped with the bot, at startup and
+// then hourly until ctx is done
+func (h *Handler) RunUploadCleanup(ctx context.Context) {
+	if !h.uploads.Enabled() {
+		return
+	}
+	ticker := time.NewTicker(uploadCleanupInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := h.uploads.CleanupOrphans(time.Now()); err != nil {
+			log.Printf("Warning: %v", err)
+		} else if n > 0 {
+			log.Printf("Removed %d orphaned photo(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
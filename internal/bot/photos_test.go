@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/uploads"
+)
+
+// newPhotoTestHandler returns a handler storing photos in a temporary directory, with
+// Telegram files served by a test server. It records the files downloaded.
+func newPhotoTestHandler(t *testing.T) (*Handler, *fakeNotionAPI, *uploads.Store, *[]string) {
+	t.Helper()
+	handler, _, fake := newRecoveryTestHandler(t)
+	store := uploads.New(t.TempDir(), "https://example.com"+uploads.PathPrefix, 0)
+	handler.uploads = store
+
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "jpeg of "+strings.TrimPrefix(r.URL.Path, "/"))
+	}))
+	t.Cleanup(files.Close)
+	var downloaded []string
+	handler.fileURLFunc = func(fileID string) (string, error) {
+		downloaded = append(downloaded, fileID)
+		return files.URL + "/" + fileID, nil
+	}
+	return handler, fake, store, &downloaded
+}
+
+// photoMessage returns message 123 with a photo in two sizes and the caption
+func photoMessage(caption string) *tgbotapi.Message {
+	message := testMessage("", 0)
+	message.Caption = caption
+	message.Photo = []tgbotapi.PhotoSize{
+		{FileID: "small", Width: 90, Height: 67, FileSize: 1200},
+		{FileID: "large", Width: 1280, Height: 960, FileSize: 98000},
+	}
+	return message
+}
+
+// servedFile fetches a URL of the store from its handler
+func servedFile(store *uploads.Store, url string) (int, string) {
+	rec := httptest.NewRecorder()
+	path := strings.TrimPrefix(url, "https://example.com")
+	store.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec.Code, rec.Body.String()
+}
+
+func TestPhotoCaptionBecomesTitleWithAttachment(t *testing.T) {
+	handler, fake, store, downloaded := newPhotoTestHandler(t)
+	fake.schema = `{
+		"Name": {"id": "title", "type": "title", "title": {}},
+		"Receipts": {"id": "fi", "type": "files", "files": {}}
+	}`
+
+	if err := handler.HandleMessage(photoMessage("Lunch receipt #expenses")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if stored, err := handler.db.GetPendingTask(789, 123); err != nil || stored == nil || stored.PhotoFileID != "large" {
+		t.Fatalf("Expected the largest size stored with the pending task, got %+v, %v", stored, err)
+	}
+	if len(*downloaded) != 0 {
+		t.Errorf("Expected the photo downloaded only once it's saved, got %v", *downloaded)
+	}
+
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 || titles[0] != "Lunch receipt" {
+		t.Errorf("Expected the caption as the title, got %v", titles)
+	}
+	var files []struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		External struct {
+			URL string `json:"url"`
+		} `json:"external"`
+	}
+	fake.mu.Lock()
+	for _, r := range fake.requests {
+		if r.Method == http.MethodPost && r.Path == "/v1/pages" {
+			var body struct {
+				Properties map[string]struct {
+					Files json.RawMessage `json:"files"`
+				} `json:"properties"`
+			}
+			if err := json.Unmarshal(r.Body, &body); err != nil {
+				t.Fatalf("Invalid request body %s: %v", r.Body, err)
+			}
+			json.Unmarshal(body.Properties["Receipts"].Files, &files)
+		}
+	}
+	fake.mu.Unlock()
+	if len(files) != 1 || files[0].Type != "external" || !strings.HasPrefix(files[0].External.URL, "https://example.com/notion/mini-app/files/") {
+		t.Fatalf("Expected the photo linked as an external file, got %+v", files)
+	}
+
+	if len(*downloaded) != 1 || (*downloaded)[0] != "large" {
+		t.Errorf("Expected the large size downloaded, got %v", *downloaded)
+	}
+	if code, body := servedFile(store, files[0].External.URL); code != http.StatusOK || body != "jpeg of large" {
+		t.Errorf("Expected the stored photo served, got %d %q", code, body)
+	}
+}
+
+func TestPhotoWithoutCaptionUsesURLProperty(t *testing.T) {
+	handler, fake, store, _ := newPhotoTestHandler(t)
+	fake.schema = `{
+		"Name": {"id": "title", "type": "title", "title": {}},
+		"Photo": {"id": "ph", "type": "url", "url": {}}
+	}`
+
+	if err := handler.HandleMessage(photoMessage("")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 || titles[0] != photoTitle {
+		t.Errorf("Expected a photo without caption titled %q, got %v", photoTitle, titles)
+	}
+	url, ok := createdURL(t, fake, "Photo")
+	if !ok {
+		t.Fatal("Expected the photo linked in the url property")
+	}
+	if code, body := servedFile(store, url); code != http.StatusOK || body != "jpeg of large" {
+		t.Errorf("Expected the stored photo served, got %d %q", code, body)
+	}
+}
+
+func TestPhotoSizeWithinLimit(t *testing.T) {
+	sizes := photoMessage("").Photo
+	if size, ok := photoSize(sizes, 50000); !ok || size.FileID != "small" {
+		t.Errorf("Expected the small size under the limit, got %+v, %v", size, ok)
+	}
+	if _, ok := photoSize(sizes, 1000); ok {
+		t.Error("Expected no size under a limit below every size")
+	}
+}
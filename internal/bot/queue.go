@@ -0,0 +1,166 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// saveQueueSize is how many saves can wait for a worker before new ones are turned away
+const saveQueueSize = 100
+
+// errSaveQueueFull is returned when a save can't be queued, the message stays pending
+var errSaveQueueFull = errors.New("save queue is full")
+
+// errSaveQueueClosed is returned for saves requested during shutdown, the message stays
+// pending and can be saved after the restart
+var errSaveQueueClosed = errors.New("save queue is shut down")
+
+// saveJob is a pending message waiting to be created in Notion
+type saveJob struct {
+	ctx       context.Context // Carries the trace of the triggering update, never cancelled
+	chatID    int64
+	userID    int64
+	messageID int
+	dbType    string // Database the message is saved to
+	split     bool   // Save one task per entry of the message (✂️ or /split)
+}
+
+// saveJobKey identifies a queued message
+type saveJobKey struct {
+	chatID    int64
+	messageID int
+}
+
+// saveQueue hands saves to the worker pool so updates are answered right away. A message
+// is queued at most once until its save finishes.
+type saveQueue struct {
+	jobs chan saveJob
+
+	mu     sync.Mutex
+	queued map[saveJobKey]bool // Messages queued or being saved
+	closed bool
+}
+
+func newSaveQueue() *saveQueue {
+	return &saveQueue{
+		jobs:   make(chan saveJob, saveQueueSize),
+		queued: make(map[saveJobKey]bool),
+	}
+}
+
+// add queues a save, reporting false when the message is already queued
+func (q *saveQueue) add(job saveJob) (bool, error) {
+	key := saveJobKey{chatID: job.chatID, messageID: job.messageID}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false, errSaveQueueClosed
+	}
+	if q.queued[key] {
+		return false, nil
+	}
+	select {
+	case q.jobs <- job:
+		q.queued[key] = true
+		return true, nil
+	default:
+		return false, errSaveQueueFull
+	}
+}
+
+// done releases a message after its save so it can be queued again
+func (q *saveQueue) done(job saveJob) {
+	q.mu.Lock()
+	delete(q.queued, saveJobKey{chatID: job.chatID, messageID: job.messageID})
+	q.mu.Unlock()
+}
+
+// close stops taking new saves, workers finish the queued ones and then exit
+func (q *saveQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+}
+
+// enqueueSave queues the save of a pending message to the database of dbType for the
+// workers. Without a queue, as in handlers built for tests, the message is saved right away.
+func (h *Handler) enqueueSave(ctx context.Context, chatID, userID int64, messageID int, dbType string) error {
+	return h.enqueue(ctx, saveJob{chatID: chatID, userID: userID, messageID: messageID, dbType: dbType})
+}
+
+// enqueueSplit queues a pending message to be saved as one task per entry it lists
+func (h *Handler) enqueueSplit(ctx context.Context, chatID, userID int64, messageID int) error {
+	return h.enqueue(ctx, saveJob{chatID: chatID, userID: userID, messageID: messageID, dbType: "tasks", split: true})
+}
+
+// enqueue queues a save job, or runs it right away without a queue
+func (h *Handler) enqueue(ctx context.Context, job saveJob) error {
+	// The save latency is measured from the request, so time spent queued counts
+	h.mu.Lock()
+	if pendingTask := h.pendingTasks[job.userID][job.messageID]; pendingTask != nil && pendingTask.saveRequestedAt.IsZero() {
+		pendingTask.saveRequestedAt = time.Now()
+	}
+	h.mu.Unlock()
+
+	if h.saves == nil {
+		job.ctx = ctx
+		return h.runSaveJob(job)
+	}
+
+	// The save outlives the update that triggered it
+	job.ctx = context.WithoutCancel(ctx)
+	added, err := h.saves.add(job)
+	if err != nil {
+		log.Printf("Warning: Could not queue the save of message %d: %v", job.messageID, err)
+		return err
+	}
+	if !added {
+		log.Printf("Save of message %d is already queued, ignoring", job.messageID)
+	}
+	return nil
+}
+
+// runSaveJob saves or splits the pending message of a job
+func (h *Handler) runSaveJob(job saveJob) error {
+	if job.split {
+		return h.splitPendingTask(job.ctx, job.chatID, job.userID, job.messageID)
+	}
+	return h.savePendingTask(job.ctx, job.chatID, job.userID, job.messageID, job.dbType)
+}
+
+// RunSaveWorkers saves queued messages with the given number of workers until ctx is done.
+// Saves queued before then are still finished, so shutdown waits for them.
+func (h *Handler) RunSaveWorkers(ctx context.Context, workers int) {
+	if h.saves == nil {
+		return
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range h.saves.jobs {
+				if err := h.runSaveJob(job); err != nil {
+					log.Printf("Error saving message %d: %v", job.messageID, err)
+				}
+				h.saves.done(job)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	h.saves.close()
+	log.Printf("Finishing queued saves before shutdown")
+	wg.Wait()
+}
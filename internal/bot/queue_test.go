@@ -0,0 +1,114 @@
+package bot
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// failingCreates rejects page creations and passes the other requests to the fake
+type failingCreates struct {
+	*fakeNotionAPI
+}
+
+func (f failingCreates) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.URL.Path != "/v1/pages" {
+		return f.fakeNotionAPI.RoundTrip(req)
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, notionRequest{Method: req.Method, Path: req.URL.Path})
+	f.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"object": "error", "status": 400, "code": "validation_error", "message": "invalid"}`)),
+		Request:    req,
+	}, nil
+}
+
+// runQueuedSave reacts to a pending message with the workers running and waits until
+// they drained on shutdown
+func runQueuedSave(t *testing.T, handler *Handler) {
+	t.Helper()
+	handler.saves = newSaveQueue()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		handler.RunSaveWorkers(ctx, 2)
+		close(done)
+	}()
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	cancel()
+	<-done
+}
+
+func TestQueuedSaveIsDeduplicated(t *testing.T) {
+	handler, _, fake := newRecoveryTestHandler(t)
+	handler.saves = newSaveQueue()
+
+	// A repeated 👍 before a worker picks the first one up is queued once
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	for i := 0; i < 2; i++ {
+		if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+			t.Fatalf("HandleMessageReaction failed: %v", err)
+		}
+	}
+	if n := len(handler.saves.jobs); n != 1 {
+		t.Fatalf("Expected 1 queued save, got %d", n)
+	}
+
+	// Workers started after shutdown still finish the queued save
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler.RunSaveWorkers(ctx, 2)
+
+	if got := fake.titles(t, http.MethodPost, "/v1/pages"); len(got) != 1 || got[0] != "Buy milk" {
+		t.Errorf("Expected one created task, got %v", got)
+	}
+	handler.storePendingTask(testMessage("Buy eggs", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != errSaveQueueClosed {
+		t.Errorf("Expected saves to be refused after shutdown, got %v", err)
+	}
+}
+
+func TestQueuedSaveReactsWhenSaved(t *testing.T) {
+	handler, telegram, _ := newRecoveryTestHandler(t)
+
+	runQueuedSave(t, handler)
+
+	got := reactions(telegram)
+	if len(got) == 0 || !strings.Contains(got[len(got)-1], feedbackSaved) {
+		t.Errorf("Expected the message to end with 👍, got %v", got)
+	}
+}
+
+func TestQueuedSaveReactsWhenFailed(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.notion = notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: failingCreates{fake}}))
+
+	runQueuedSave(t, handler)
+
+	got := reactions(telegram)
+	if len(got) == 0 || !strings.Contains(got[len(got)-1], feedbackFailed) {
+		t.Errorf("Expected the message to end with 😢, got %v", got)
+	}
+	creates := 0
+	for _, r := range fake.requests {
+		if r.Method == http.MethodPost && r.Path == "/v1/pages" {
+			creates++
+		}
+	}
+	if creates != 3 {
+		t.Errorf("Expected 3 attempts, got %d", creates)
+	}
+}
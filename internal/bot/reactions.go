@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// defaultReactionMap saves messages reacted to with 👍 as tasks
+const defaultReactionMap = "👍=tasks"
+
+// reactionDbTypes are the databases a reaction can save a message to
+var reactionDbTypes = map[string]bool{"tasks": true, "notes": true, "journal": true, "projects": true}
+
+// parseReactionMap parses REACTION_MAP entries like "👍=tasks,❤️=journal,🔥=notes" into
+// database types keyed by normalized emoji, so skin tones and variation selectors match
+func parseReactionMap(value string) (map[string]string, error) {
+	reactions := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		emoji, dbType, ok := strings.Cut(entry, "=")
+		emoji = normalizeEmoji(strings.TrimSpace(emoji))
+		dbType = strings.ToLower(strings.TrimSpace(dbType))
+		if !ok || emoji == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected emoji=database", entry)
+		}
+		if !reactionDbTypes[dbType] {
+			return nil, fmt.Errorf("unknown database %q for %s, expected tasks, notes, journal or projects", dbType, emoji)
+		}
+		if previous, ok := reactions[emoji]; ok && previous != dbType {
+			return nil, fmt.Errorf("%s is mapped to both %s and %s", emoji, previous, dbType)
+		}
+		reactions[emoji] = dbType
+	}
+	if len(reactions) == 0 {
+		return nil, fmt.Errorf("no reactions in %q", value)
+	}
+	return reactions, nil
+}
+
+// reactionMapFromEnv returns the reactions of REACTION_MAP, dropping ones for databases
+// that aren't configured. hasDatabase reports whether a database type is configured.
+func reactionMapFromEnv(value string, hasDatabase func(dbType string) bool) map[string]string {
+	if value == "" {
+		value = defaultReactionMap
+	}
+	reactions, err := parseReactionMap(value)
+	if err != nil {
+		log.Printf("Warning: Ignoring REACTION_MAP: %v", err)
+		reactions, _ = parseReactionMap(defaultReactionMap)
+	}
+
+	for emoji, dbType := range reactions {
+		if !hasDatabase(dbType) {
+			log.Printf("Warning: Ignoring %s in REACTION_MAP, the %s database is not configured", emoji, dbType)
+			delete(reactions, emoji)
+		}
+	}
+	return reactions
+}
+
+// reactionTarget returns the database the first mapped emoji of a reaction saves to.
+// Handlers without a map, like the ones built in tests, save 👍 as tasks.
+func (h *Handler) reactionTarget(reactions []ReactionType) (string, bool) {
+	mapping := h.reactions
+	if mapping == nil {
+		mapping = map[string]string{"👍": "tasks"}
+	}
+	for _, r := range reactions {
+		if r.Type != "emoji" {
+			continue
+		}
+		if dbType, ok := mapping[normalizeEmoji(r.Emoji)]; ok {
+			return dbType, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseReactionMap(t *testing.T) {
+	got, err := parseReactionMap(" 👍🏽=tasks, ❤️=Journal,🔥=notes,👍=tasks ")
+	if err != nil {
+		t.Fatalf("parseReactionMap failed: %v", err)
+	}
+	want := map[string]string{"👍": "tasks", "❤": "journal", "🔥": "notes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for _, value := range []string{"", " , ", "👍", "=tasks", "👍=inbox", "👍=tasks,👍=notes"} {
+		if _, err := parseReactionMap(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestReactionMapFromEnv(t *testing.T) {
+	configured := func(dbType string) bool { return dbType == "tasks" || dbType == "journal" }
+
+	got := reactionMapFromEnv("👍=tasks,❤️=journal,🔥=notes", configured)
+	if want := map[string]string{"👍": "tasks", "❤": "journal"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the unconfigured notes database to be dropped, got %v", got)
+	}
+
+	for _, value := range []string{"", "👍=inbox"} {
+		if got := reactionMapFromEnv(value, configured); !reflect.DeepEqual(got, map[string]string{"👍": "tasks"}) {
+			t.Errorf("Expected %q to fall back to 👍=tasks, got %v", value, got)
+		}
+	}
+}
+
+// createdParents returns the database IDs of the pages created through the fake
+func createdParents(t *testing.T, fake *fakeNotionAPI) []string {
+	t.Helper()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var result []string
+	for _, r := range fake.requests {
+		if r.Method != http.MethodPost || r.Path != "/v1/pages" {
+			continue
+		}
+		var body struct {
+			Parent struct {
+				DatabaseID string `json:"database_id"`
+			} `json:"parent"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatalf("Invalid request body %s: %v", r.Body, err)
+		}
+		result = append(result, body.Parent.DatabaseID)
+	}
+	return result
+}
+
+func TestReactionRoutesToDatabase(t *testing.T) {
+	tests := []struct {
+		name   string
+		emoji  string
+		parent string
+	}{
+		{"thumbs up", "👍", "tasks-db"},
+		{"heart", "❤️", "journal-db"},
+		{"fire", "🔥", "notes-db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTION_JOURNAL_DATABASE_ID", "journal-db")
+			t.Setenv("NOTION_NOTES_DATABASE_ID", "notes-db")
+			handler, telegram, fake := newRecoveryTestHandler(t)
+			handler.reactions = reactionMapFromEnv("👍=tasks,❤️=journal,🔥=notes", handler.notion.HasDatabase)
+
+			handler.storePendingTask(testMessage("Buy milk", 0))
+			reaction := thumbsUp()
+			reaction.NewReaction = []ReactionType{{Type: "emoji", Emoji: tt.emoji}}
+			if err := handler.HandleMessageReaction(context.Background(), reaction); err != nil {
+				t.Fatalf("HandleMessageReaction failed: %v", err)
+			}
+
+			if got := createdParents(t, fake); len(got) != 1 || got[0] != tt.parent {
+				t.Errorf("Expected one page in %s, got %v", tt.parent, got)
+			}
+			// Progress is shown the same way whichever database the message goes to
+			got := reactions(telegram)
+			want := []string{`[{"emoji":"🤔","type":"emoji"}]`, `[{"emoji":"✍","type":"emoji"}]`, `[{"emoji":"👍","type":"emoji"}]`}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected 🤔, ✍️ and 👍, got %v", got)
+			}
+		})
+	}
+}
+
+func TestUnmappedReactionIsIgnored(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.reactions = map[string]string{"👍": "tasks", "❤": "journal"}
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	reaction := thumbsUp()
+	reaction.NewReaction = []ReactionType{{Type: "emoji", Emoji: "🎉"}}
+	if err := handler.HandleMessageReaction(context.Background(), reaction); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if got := createdParents(t, fake); len(got) != 0 {
+		t.Errorf("Expected nothing to be created, got %v", got)
+	}
+	if got := reactions(telegram); len(got) != 1 {
+		t.Errorf("Expected only the 🤔 of the pending message, got %v", got)
+	}
+	if handler.pendingTasks[456][123] == nil {
+		t.Error("Expected the message to stay pending")
+	}
+}
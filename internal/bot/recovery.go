@@ -0,0 +1,111 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// saveAttemptRetention is how long finished save attempts are kept
+const saveAttemptRetention = 24 * time.Hour
+
+// startSaveAttempt persists a save attempt right before Notion is called
+func (h *Handler) startSaveAttempt(chatID, userID int64, messageID int, text string, startedAt time.Time) {
+	if h.db == nil {
+		return
+	}
+
+	attempt := database.SaveAttempt{
+		ChatID:    chatID,
+		MessageID: messageID,
+		UserID:    userID,
+		Text:      text,
+		StartedAt: startedAt,
+	}
+	if err := h.db.StartSaveAttempt(attempt); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// finishSaveAttempt persists the outcome of a save and drops old finished attempts
+func (h *Handler) finishSaveAttempt(chatID int64, messageID int, state, pageID string) {
+	if h.db == nil {
+		return
+	}
+
+	if err := h.db.FinishSaveAttempt(chatID, messageID, state, pageID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := h.db.DeleteFinishedSaveAttempts(time.Now().Add(-saveAttemptRetention)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// ReconcileSaveAttempts resolves saves that were interrupted by a restart. Attempts still
+// in progress are looked up in Notion by title: found pages get their final 👍, otherwise the
+// message goes back to pending (🤔) and is saved again. Run once on startup.
+func (h *Handler) ReconcileSaveAttempts(ctx context.Context) {
+	if h.db == nil {
+		return
+	}
+
+	if err := h.db.DeleteFinishedSaveAttempts(time.Now().Add(-saveAttemptRetention)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	attempts, err := h.db.GetSaveAttemptsInState(database.SaveStateInProgress)
+	if err != nil {
+		log.Printf("Warning: Could not load interrupted saves: %v", err)
+		return
+	}
+
+	for _, attempt := range attempts {
+		client := h.notionFor(attempt.UserID)
+		// The page is titled as the save titled it, without the markers
+		title, _, _, _ := splitTaskText(client, attempt.Text)
+		pageID, err := client.FindTaskByTitle(ctx, title, attempt.StartedAt)
+		if err != nil {
+			// Leave the record for the next startup
+			log.Printf("Warning: Could not check interrupted save of message %d: %v", attempt.MessageID, err)
+			continue
+		}
+
+		if pageID != "" {
+			log.Printf("Interrupted save of message %d had created page %s", attempt.MessageID, pageID)
+			h.mu.Lock()
+			h.recordSavedMessage(attempt.UserID, attempt.MessageID, &savedMessage{
+				TaskID:        pageID,
+				Title:         attempt.Text,
+				SaveStartedAt: attempt.StartedAt,
+			})
+			h.mu.Unlock()
+			h.finishSaveAttempt(attempt.ChatID, attempt.MessageID, database.SaveStateDone, pageID)
+			h.persistSavedMessage(attempt.UserID, attempt.ChatID, attempt.MessageID, pageID)
+			h.showFeedback(attempt.ChatID, attempt.MessageID, feedbackSaved)
+			continue
+		}
+
+		log.Printf("Interrupted save of message %d didn't reach Notion, saving again", attempt.MessageID)
+		h.mu.Lock()
+		if h.pendingTasks == nil {
+			h.pendingTasks = make(map[int64]map[int]*PendingTask)
+		}
+		if h.pendingTasks[attempt.UserID] == nil {
+			h.pendingTasks[attempt.UserID] = make(map[int]*PendingTask)
+		}
+		h.pendingTasks[attempt.UserID][attempt.MessageID] = &PendingTask{
+			ChatID:     attempt.ChatID,
+			MessageID:  attempt.MessageID,
+			Text:       attempt.Text,
+			ReceivedAt: attempt.StartedAt,
+		}
+		h.mu.Unlock()
+
+		h.showFeedback(attempt.ChatID, attempt.MessageID, feedbackPending)
+		if err := h.savePendingTask(ctx, attempt.ChatID, attempt.UserID, attempt.MessageID, "tasks"); err != nil {
+			log.Printf("Warning: Retried save of message %d failed: %v", attempt.MessageID, err)
+		}
+	}
+}
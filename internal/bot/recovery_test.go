@@ -0,0 +1,202 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// newRecoveryTestHandler creates a handler with fake Telegram and Notion APIs and a temporary database
+func newRecoveryTestHandler(t *testing.T) (*Handler, *fakeTelegram, *fakeNotionAPI) {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	telegram, botAPI := newFakeTelegram(t)
+	fake := &fakeNotionAPI{}
+	handler := &Handler{
+		bot:       botAPI,
+		notion:    notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+		db:        db,
+		afterFunc: func(time.Duration, func()) {},
+
+		pendingTasks: make(map[int64]map[int]*PendingTask),
+	}
+	return handler, telegram, fake
+}
+
+// reactions returns the emojis set via setMessageReaction, in order
+func reactions(f *fakeTelegram) []string {
+	var result []string
+	for _, call := range f.callsTo("setMessageReaction") {
+		result = append(result, call.Params.Get("reaction"))
+	}
+	return result
+}
+
+// crashMidSave leaves an attempt at saving text in progress, as if the bot died after
+// setting ✍️
+func crashMidSave(t *testing.T, db *database.DB, text string) {
+	t.Helper()
+	err := db.StartSaveAttempt(database.SaveAttempt{
+		ChatID:    789,
+		MessageID: 123,
+		UserID:    456,
+		Text:      text,
+		StartedAt: time.Now().Add(-time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("Failed to store save attempt: %v", err)
+	}
+}
+
+func TestSavePersistsAttempt(t *testing.T) {
+	handler, _, _ := newRecoveryTestHandler(t)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	done, err := handler.db.GetSaveAttemptsInState(database.SaveStateDone)
+	if err != nil {
+		t.Fatalf("Failed to load save attempts: %v", err)
+	}
+	if len(done) != 1 || done[0].PageID != "page-1" || done[0].Text != "Buy milk" {
+		t.Errorf("Expected a finished attempt for page-1, got %+v", done)
+	}
+}
+
+func TestReconcileFinishesSaveThatReachedNotion(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	crashMidSave(t, handler.db, "Buy milk")
+	fake.results = `[
+		{"object": "page", "id": "page-other", "properties": {"Name": {"type": "title", "title": [{"plain_text": "Something else"}]}}},
+		{"object": "page", "id": "page-9", "properties": {"Name": {"type": "title", "title": [{"plain_text": "Buy milk"}]}}}
+	]`
+
+	handler.ReconcileSaveAttempts(context.Background())
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 0 {
+		t.Errorf("Existing page must not be created again, got %v", created)
+	}
+	if got := reactions(telegram); len(got) != 1 || got[0] != `[{"emoji":"👍","type":"emoji"}]` {
+		t.Errorf("Expected the final 👍, got %v", got)
+	}
+
+	done, err := handler.db.GetSaveAttemptsInState(database.SaveStateDone)
+	if err != nil {
+		t.Fatalf("Failed to load save attempts: %v", err)
+	}
+	if len(done) != 1 || done[0].PageID != "page-9" {
+		t.Errorf("Expected attempt marked done with page-9, got %+v", done)
+	}
+
+	// Later edits reach the recovered page
+	if err := handler.HandleEditedMessage(testMessage("Buy oat milk", int(time.Now().Unix()))); err != nil {
+		t.Fatalf("HandleEditedMessage failed: %v", err)
+	}
+	if updates := fake.titles(t, http.MethodPatch, "/v1/pages/page-9"); len(updates) != 1 {
+		t.Errorf("Expected edit to update the recovered page, got %v", updates)
+	}
+}
+
+func TestReconcileMatchesPageTitledWithoutMarkers(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	crashMidSave(t, handler.db, "Buy milk #home !2024-06-01")
+	fake.results = `[
+		{"object": "page", "id": "page-9", "properties": {"Name": {"type": "title", "title": [{"plain_text": "Buy milk"}]}}}
+	]`
+
+	handler.ReconcileSaveAttempts(context.Background())
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 0 {
+		t.Errorf("Existing page must not be created again, got %v", created)
+	}
+	if got := reactions(telegram); len(got) != 1 || got[0] != `[{"emoji":"👍","type":"emoji"}]` {
+		t.Errorf("Expected the final 👍, got %v", got)
+	}
+	if done, _ := handler.db.GetSaveAttemptsInState(database.SaveStateDone); len(done) != 1 || done[0].PageID != "page-9" {
+		t.Errorf("Expected attempt marked done with page-9, got %+v", done)
+	}
+}
+
+func TestReconcileRequeuesSaveThatNeverReachedNotion(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	crashMidSave(t, handler.db, "Buy milk")
+
+	handler.ReconcileSaveAttempts(context.Background())
+
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "Buy milk" {
+		t.Errorf("Expected the task to be saved again, got %v", created)
+	}
+
+	got := reactions(telegram)
+	if len(got) != 3 || got[0] != `[{"emoji":"🤔","type":"emoji"}]` || got[2] != `[{"emoji":"👍","type":"emoji"}]` {
+		t.Errorf("Expected 🤔 restored, then ✍️ and 👍, got %v", got)
+	}
+
+	inProgress, err := handler.db.GetSaveAttemptsInState(database.SaveStateInProgress)
+	if err != nil {
+		t.Fatalf("Failed to load save attempts: %v", err)
+	}
+	if len(inProgress) != 0 {
+		t.Errorf("No attempts should remain in progress, got %+v", inProgress)
+	}
+}
+
+func TestDeleteFinishedSaveAttempts(t *testing.T) {
+	handler, _, _ := newRecoveryTestHandler(t)
+	crashMidSave(t, handler.db, "Buy milk")
+	if err := handler.db.FinishSaveAttempt(789, 123, database.SaveStateDone, "page-1"); err != nil {
+		t.Fatalf("Failed to finish save attempt: %v", err)
+	}
+
+	if err := handler.db.DeleteFinishedSaveAttempts(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Failed to delete save attempts: %v", err)
+	}
+	done, _ := handler.db.GetSaveAttemptsInState(database.SaveStateDone)
+	if len(done) != 0 {
+		t.Errorf("Expected finished attempts to be removed, got %+v", done)
+	}
+}
+
+func TestPendingTaskSurvivesRestart(t *testing.T) {
+	handler, _, _ := newRecoveryTestHandler(t)
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	if err := handler.HandleEditedMessage(testMessage("Buy oat milk", int(time.Now().Unix()))); err != nil {
+		t.Fatalf("HandleEditedMessage failed: %v", err)
+	}
+
+	// A new handler on the same database, as after a container restart
+	restarted, _, fake := newRecoveryTestHandler(t)
+	restarted.db = handler.db
+	restarted.restorePendingTasks()
+
+	if err := restarted.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); len(created) != 1 || created[0] != "Buy oat milk" {
+		t.Errorf("Expected the restored task to be saved with the edited text, got %v", created)
+	}
+
+	pending, err := handler.db.GetPendingTasks()
+	if err != nil {
+		t.Fatalf("GetPendingTasks failed: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("Saved tasks must not stay pending, got %+v", pending)
+	}
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// snoozeCallbackPrefix marks callback data of the "Snooze" button on scheduler notifications
+const snoozeCallbackPrefix = "snooze:"
+
+// snoozeTask tags a task sometimes-later, which leaves it out of listings and the daily check
+func (h *Handler) snoozeTask(userID int64, taskID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return h.notionFor(userID).AddTagToTask(ctx, taskID, notion.SometimesLaterTag)
+}
+
+// handleSnoozeCallback snoozes the task of a notification and removes its buttons
+func (h *Handler) handleSnoozeCallback(query *tgbotapi.CallbackQuery, pageID string) error {
+	if err := h.snoozeTask(query.From.ID, pageID); err != nil {
+		log.Printf("Failed to snooze task %s: %v", pageID, err)
+		_, _ = h.bot.Request(tgbotapi.NewCallback(query.ID, "❌ Failed to snooze task"))
+		return err
+	}
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "💤 Snoozed")); err != nil {
+		log.Printf("Warning: Failed to answer callback query: %v", err)
+	}
+
+	// Keep the notification text, only the button is stale now
+	if query.Message != nil {
+		edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID,
+			tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+		if _, err := h.bot.Request(edit); err != nil {
+			log.Printf("Warning: Failed to update notification: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// handleSnoozeCommand snoozes the task of a pasted Notion link: /snooze <notion-url-or-id>
+func (h *Handler) handleSnoozeCommand(message *tgbotapi.Message) error {
+	reply := func(text string) error {
+		_, err := h.bot.Send(tgbotapi.NewMessage(message.Chat.ID, text))
+		return err
+	}
+
+	arg := strings.TrimSpace(message.CommandArguments())
+	if arg == "" {
+		return reply("Usage: /snooze <Notion link or page ID>")
+	}
+	pageID, err := notion.ParsePageID(arg)
+	if err != nil {
+		return reply("❌ That doesn't look like a Notion link or page ID.")
+	}
+
+	if err := h.snoozeTask(message.From.ID, pageID); err != nil {
+		log.Printf("Failed to snooze task %s: %v", pageID, err)
+		return reply("❌ Failed to snooze the task.")
+	}
+	return reply("💤 Snoozed, the task is tagged " + notion.SometimesLaterTag + " and left out of listings.")
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// snoozedPageID is the hyphenated ID of the page in the snooze tests
+const snoozedPageID = "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"
+
+// taggedPage is a task tagged work, as fakeNotionAPI.page
+const taggedPage = `{"Tags": {"id": "tags", "type": "multi_select", "multi_select": [{"id": "a1", "name": "work"}]}}`
+
+// requestBodies returns the bodies of the requests matching the method and path
+func requestBodies(fake *fakeNotionAPI, method, path string) []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var bodies []string
+	for _, r := range fake.requests {
+		if r.Method == method && r.Path == path {
+			bodies = append(bodies, string(r.Body))
+		}
+	}
+	return bodies
+}
+
+func snoozeCommand(arg string) *tgbotapi.Message {
+	message := testMessage("/snooze "+arg, 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/snooze")}}
+	return message
+}
+
+func TestSnoozeCommandTagsLinkedTask(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.page = taggedPage
+
+	link := "https://www.notion.so/workspace/Call-the-bank-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d?pvs=4"
+	if err := handler.HandleMessage(snoozeCommand(link)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	updates := requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID)
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update of the linked page, got %d", len(updates))
+	}
+	if !strings.Contains(updates[0], `{"name":"work"}`) || !strings.Contains(updates[0], `{"name":"sometimes-later"}`) {
+		t.Errorf("Expected the existing tag and sometimes-later, got %s", updates[0])
+	}
+
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].Params.Get("text"), "💤 Snoozed") {
+		t.Errorf("Expected a snoozed reply, got %v", sent)
+	}
+}
+
+func TestSnoozeCommandRejectsInvalidLinks(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+
+	for _, arg := range []string{"", "https://www.notion.so/workspace/Call-the-bank"} {
+		if err := handler.HandleMessage(snoozeCommand(arg)); err != nil {
+			t.Fatalf("HandleMessage(%q) failed: %v", arg, err)
+		}
+	}
+
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no Notion requests, got %d", len(fake.requests))
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 2 || !strings.HasPrefix(sent[0].Params.Get("text"), "Usage: /snooze") {
+		t.Errorf("Expected usage and an error reply, got %v", sent)
+	}
+}
+
+func TestSnoozeCallbackTagsTask(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.page = taggedPage
+
+	query := &tgbotapi.CallbackQuery{
+		ID:      "cb-1",
+		From:    &tgbotapi.User{ID: 456},
+		Message: &tgbotapi.Message{MessageID: 77, Chat: &tgbotapi.Chat{ID: 789}},
+		Data:    snoozeCallbackPrefix + snoozedPageID,
+	}
+	if err := handler.HandleCallbackQuery(query); err != nil {
+		t.Fatalf("HandleCallbackQuery failed: %v", err)
+	}
+
+	if updates := requestBodies(fake, http.MethodPatch, "/v1/pages/"+snoozedPageID); len(updates) != 1 {
+		t.Fatalf("Expected 1 update of the task, got %d", len(updates))
+	}
+	answers := telegram.callsTo("answerCallbackQuery")
+	if len(answers) != 1 || answers[0].Params.Get("text") != "💤 Snoozed" {
+		t.Errorf("Expected the callback to be answered, got %v", answers)
+	}
+	if edits := telegram.callsTo("editMessageReplyMarkup"); len(edits) != 1 {
+		t.Errorf("Expected the snooze button to be removed, got %d edits", len(edits))
+	}
+}
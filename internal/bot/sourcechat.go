@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// widenArgument makes a listing command include tasks from every chat
+const widenArgument = "all"
+
+// sourceChatProperties returns the properties stamping a new task with the chat it came
+// from, nil when SOURCE_CHAT_PROPERTY isn't set or the chat is unknown
+func (h *Handler) sourceChatProperties(sourceChat string) map[string]interface{} {
+	if h.sourceChatProp == "" || sourceChat == "" {
+		return nil
+	}
+	// Select options for new chats are created on first use, saves allow new options
+	return map[string]interface{}{h.sourceChatProp: sourceChat}
+}
+
+// chatScope returns the scope limiting a listing command to tasks created from the current
+// chat, and the command arguments without the widening "all". The scope is nil when the
+// listing should cover every chat.
+func (h *Handler) chatScope(message *tgbotapi.Message) (*notion.ChatScope, string) {
+	fields := strings.Fields(message.CommandArguments())
+	widen := false
+	rest := fields[:0]
+	for _, field := range fields {
+		if strings.EqualFold(field, widenArgument) {
+			widen = true
+			continue
+		}
+		rest = append(rest, field)
+	}
+	args := strings.Join(rest, " ")
+
+	if widen || h.sourceChatProp == "" {
+		return nil, args
+	}
+	return &notion.ChatScope{
+		Property: h.sourceChatProp,
+		ChatID:   message.Chat.ID,
+		Value:    notion.SourceChatValue(message.Chat.Title, message.Chat.ID),
+	}, args
+}
@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestSavedTaskRecordsSourceChat(t *testing.T) {
+	handler, fake := newEditTestHandler(t)
+	handler.sourceChatProp = "Source chat"
+	fake.schema = `{
+		"Name": {"id": "title", "type": "title", "title": {}},
+		"Source chat": {"id": "chat", "type": "select", "select": {"options": []}}
+	}`
+
+	message := testMessage("Buy milk", 0)
+	message.Chat.Title = "Family"
+	handler.storePendingTask(message)
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, r := range fake.requests {
+		if r.Method == http.MethodPost && r.Path == "/v1/pages" {
+			if !strings.Contains(string(r.Body), `"Source chat"`) || !strings.Contains(string(r.Body), "Family [789]") {
+				t.Errorf("Expected the source chat to be stamped, got %s", r.Body)
+			}
+			return
+		}
+	}
+	t.Fatal("Expected a page to be created")
+}
+
+func TestSourceChatIsOptional(t *testing.T) {
+	handler, _ := newEditTestHandler(t)
+	if props := handler.sourceChatProperties("Family [789]"); props != nil {
+		t.Errorf("Expected no properties without SOURCE_CHAT_PROPERTY, got %v", props)
+	}
+}
+
+func TestChatScopeWidensWithAll(t *testing.T) {
+	handler, _ := newEditTestHandler(t)
+	handler.sourceChatProp = "Source chat"
+
+	command := func(text string) *tgbotapi.Message {
+		message := testMessage(text, 0)
+		message.Chat.Title = "Family"
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: strings.IndexByte(text+" ", ' ')}}
+		return message
+	}
+
+	scope, args := handler.chatScope(command("/today groceries"))
+	if scope == nil || scope.ChatID != 789 || scope.Value != "Family [789]" || args != "groceries" {
+		t.Errorf("Expected a scope for the current chat, got %+v with args %q", scope, args)
+	}
+
+	scope, args = handler.chatScope(command("/today all groceries"))
+	if scope != nil || args != "groceries" {
+		t.Errorf("Expected \"all\" to widen the listing, got %+v with args %q", scope, args)
+	}
+}
@@ -0,0 +1,148 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+)
+
+// splitEmoji is the reaction that saves a message as one task per entry it lists
+const splitEmoji = "✂"
+
+// splitTask is a task created from one entry of a split message
+type splitTask struct {
+	id    string
+	title string
+}
+
+// hasSplitReaction reports whether a reaction asks for the message to be split
+func hasSplitReaction(reactions []ReactionType) bool {
+	for _, r := range reactions {
+		if r.Type == "emoji" && normalizeEmoji(r.Emoji) == splitEmoji {
+			return true
+		}
+	}
+	return false
+}
+
+// renderSplitReply lists the tasks created from a split message of total entries
+func renderSplitReply(created []splitTask, total int) string {
+	var b strings.Builder
+	if len(created) == total {
+		fmt.Fprintf(&b, "✂️ Created %d tasks:", total)
+	} else {
+		fmt.Fprintf(&b, "✂️ Created %d of %d tasks:", len(created), total)
+	}
+	for _, task := range created {
+		fmt.Fprintf(&b, "\n• <a href=\"%s\">%s</a>", html.EscapeString(notionPageURL(task.id)), html.EscapeString(truncateRunes(task.title, 80)))
+	}
+	return b.String()
+}
+
+// splitPendingTask saves a pending message as one task per entry the LLM finds in it and
+// replies with links to them. Messages the model can't split, or finds a single task in,
+// are saved as one task like with 👍.
+func (h *Handler) splitPendingTask(ctx context.Context, chatID, userID int64, messageID int) (err error) {
+	if h.splitter == nil {
+		log.Printf("LLM can't split messages, saving message %d as one task", messageID)
+		return h.savePendingTask(ctx, chatID, userID, messageID, "tasks")
+	}
+
+	h.mu.Lock()
+	pending := h.pendingTasks[userID][messageID]
+	var text string
+	if pending != nil {
+		text = pending.Text
+	}
+	h.mu.Unlock()
+	if pending == nil {
+		log.Printf("No pending task found for message %d", messageID)
+		return nil
+	}
+
+	titles, err := h.splitter.SplitTasks(ctx, text)
+	if err != nil {
+		log.Printf("Warning: Could not split message %d, saving it as one task: %v", messageID, err)
+		return h.savePendingTask(ctx, chatID, userID, messageID, "tasks")
+	}
+	if len(titles) < 2 {
+		log.Printf("Message %d lists a single task, saving it as is", messageID)
+		return h.savePendingTask(ctx, chatID, userID, messageID, "tasks")
+	}
+
+	pendingTask, requestedAt := h.claimPendingTask(chatID, userID, messageID)
+	if pendingTask == nil {
+		return nil
+	}
+
+	ctx, span := tracing.Start(ctx, "bot.split_task")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+	// Tags and source chats of messages are meant as written, unknown ones become options
+	ctx = notion.WithNewOptions(ctx)
+
+	h.showFeedback(chatID, messageID, feedbackSaving)
+
+	client := h.notionFor(userID)
+	target := h.activeDatabase(chatID, client)
+	properties := h.sourceChatProperties(pendingTask.SourceChat)
+	for key, value := range h.telegramLinkProperties(ctx, client, target, pendingTask.MessageLink) {
+		if properties == nil {
+			properties = make(map[string]interface{})
+		}
+		properties[key] = value
+	}
+	var created []splitTask
+	for _, title := range titles {
+		taskID, createErr := client.CreateTask(ctx, title, properties, target)
+		if createErr != nil {
+			log.Printf("Warning: Failed to create task %q from message %d: %v", title, messageID, createErr)
+			err = createErr
+			continue
+		}
+		created = append(created, splitTask{id: taskID, title: title})
+		h.recordTaskMetadata(userID, taskID, title, "")
+	}
+	if len(created) > 0 {
+		err = nil
+	}
+	metrics.ObserveTaskSave(time.Since(requestedAt), err)
+
+	h.mu.Lock()
+	delete(h.pendingTasks[userID], messageID)
+	h.mu.Unlock()
+
+	if len(created) == 0 {
+		h.showFeedback(chatID, messageID, feedbackFailed)
+		return fmt.Errorf("failed to create any of the %d tasks split from message %d: %w", len(titles), messageID, err)
+	}
+	log.Printf("Split message %d into %d tasks", messageID, len(created))
+
+	if h.tagger != nil {
+		go func() {
+			for _, task := range created {
+				h.tagSavedTask(ctx, client, task.id, task.title, "")
+			}
+		}()
+	}
+
+	msg := tgbotapi.NewMessage(chatID, renderSplitReply(created, len(titles)))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	if _, sendErr := h.bot.Send(msg); sendErr != nil {
+		log.Printf("Warning: Could not send the split summary: %v", sendErr)
+	}
+
+	h.showFeedback(chatID, messageID, feedbackSaved)
+	return nil
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+// fakeSplitter parses a canned model answer like the providers do
+type fakeSplitter struct {
+	answer string
+}
+
+func (f *fakeSplitter) SplitTasks(ctx context.Context, text string) ([]string, error) {
+	return llm.ParseSplit(f.answer)
+}
+
+func scissors() *MessageReactionUpdate {
+	reaction := thumbsUp()
+	reaction.NewReaction = []ReactionType{{Type: "emoji", Emoji: "✂️"}}
+	return reaction
+}
+
+func TestSplitReactionCreatesOneTaskPerEntry(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.splitter = &fakeSplitter{answer: `["Buy milk", "Call dentist", "Renew passport"]`}
+
+	handler.storePendingTask(testMessage("buy milk, call dentist, renew passport", 0))
+	if err := handler.HandleMessageReaction(context.Background(), scissors()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	titles := fake.titles(t, http.MethodPost, "/v1/pages")
+	if want := []string{"Buy milk", "Call dentist", "Renew passport"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("Expected %v, got %v", want, titles)
+	}
+	replies := telegram.callsTo("sendMessage")
+	if len(replies) != 1 || !strings.HasPrefix(replies[0].Params.Get("text"), "✂️ Created 3 tasks:") ||
+		strings.Count(replies[0].Params.Get("text"), "https://notion.so/") != 3 {
+		t.Errorf("Expected a summary linking 3 tasks, got %v", replies)
+	}
+	if got := reactions(telegram); len(got) == 0 || got[len(got)-1] != `[{"emoji":"👍","type":"emoji"}]` {
+		t.Errorf("Expected the message to end with 👍, got %v", got)
+	}
+	if handler.pendingTasks[456][123] != nil {
+		t.Error("Expected the message to be no longer pending")
+	}
+}
+
+func TestSplitFallsBackToOneTask(t *testing.T) {
+	tests := []struct {
+		name   string
+		answer string
+	}{
+		{"malformed json", `["Buy milk", "Call dentist"`},
+		{"oversized list", `["1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"]`},
+		{"single entry", `["Buy milk, call dentist"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, telegram, fake := newRecoveryTestHandler(t)
+			handler.splitter = &fakeSplitter{answer: tt.answer}
+
+			handler.storePendingTask(testMessage("Buy milk, call dentist", 0))
+			if err := handler.HandleMessageReaction(context.Background(), scissors()); err != nil {
+				t.Fatalf("HandleMessageReaction failed: %v", err)
+			}
+
+			if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 1 || titles[0] != "Buy milk, call dentist" {
+				t.Errorf("Expected the message saved as one task, got %v", titles)
+			}
+			if replies := telegram.callsTo("sendMessage"); len(replies) != 0 {
+				t.Errorf("Expected no split summary, got %v", replies)
+			}
+		})
+	}
+}
+
+func TestSplitCommandRepliesToMessage(t *testing.T) {
+	handler, _, fake := newRecoveryTestHandler(t)
+	handler.splitter = &fakeSplitter{answer: `["Buy milk", "Call dentist"]`}
+	handler.storePendingTask(testMessage("buy milk, call dentist", 0))
+
+	command := testMessage("/split", 0)
+	command.MessageID = 124
+	command.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 6}}
+	command.ReplyToMessage = testMessage("buy milk, call dentist", 0)
+	if err := handler.HandleMessage(command); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	if titles := fake.titles(t, http.MethodPost, "/v1/pages"); len(titles) != 2 {
+		t.Errorf("Expected 2 tasks, got %v", titles)
+	}
+}
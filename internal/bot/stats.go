@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// maxStatsTags is how many tags /stats lists before grouping the rest as other
+const maxStatsTags = 8
+
+// taskStats are the numbers /stats reports
+type taskStats struct {
+	local        bool // Whether the counts from the local database below are set
+	createdWeek  int
+	createdMonth int
+	tagsWeek     map[string]int
+	tagsMonth    map[string]int
+
+	undone  *notion.DatabaseCount // nil when Notion couldn't be queried
+	overdue *notion.DatabaseCount
+
+	pausedUntil string // End of the /pause in effect, empty when notifications are on
+
+	pendingExpired int // Messages dropped since start after waiting too long for their 👍
+	pendingEvicted int // Messages dropped since start over the per-user cap
+}
+
+// loadLocalStats fills in the tasks the bot created in the last 7 and 30 days
+func (h *Handler) loadLocalStats(stats *taskStats, now time.Time) error {
+	week, month := now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)
+
+	var err error
+	if stats.createdWeek, err = h.db.CountTasksSince(week); err != nil {
+		return err
+	}
+	if stats.createdMonth, err = h.db.CountTasksSince(month); err != nil {
+		return err
+	}
+	if stats.tagsWeek, err = h.db.CountByTag(week); err != nil {
+		return err
+	}
+	if stats.tagsMonth, err = h.db.CountByTag(month); err != nil {
+		return err
+	}
+	stats.local = true
+	return nil
+}
+
+// loadNotionStats fills in the tasks that are open and overdue in Notion now
+func (h *Handler) loadNotionStats(ctx context.Context, stats *taskStats, now time.Time) error {
+	undone, err := h.notion.CountUndoneTasks(ctx)
+	if err != nil {
+		return err
+	}
+	overdue, err := h.notion.GetOverdueTasks(ctx, "tasks", now.In(h.location()), maxOverdueTasks)
+	if err != nil {
+		return err
+	}
+	stats.undone = undone
+	stats.overdue = &notion.DatabaseCount{Count: len(overdue), Approximate: len(overdue) == maxOverdueTasks}
+	return nil
+}
+
+// handleStatsCommand replies with the tasks created through the bot, by tag, and the open
+// and overdue tasks in Notion. Without the local database only the Notion counts are shown.
+func (h *Handler) handleStatsCommand(message *tgbotapi.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	var stats taskStats
+	if h.db != nil {
+		if err := h.loadLocalStats(&stats, now); err != nil {
+			log.Printf("Warning: Could not load task stats from the local database: %v", err)
+		}
+	}
+	if h.db != nil {
+		if until, err := h.db.GetPausedUntil(message.From.ID); err == nil && now.Before(until) {
+			stats.pausedUntil = formatPauseEnd(until, h.location())
+		}
+	}
+	stats.pendingEvicted, stats.pendingExpired = h.pendingDropped()
+	notionErr := h.loadNotionStats(ctx, &stats, now)
+	if notionErr != nil {
+		log.Printf("Warning: Could not load task stats from Notion: %v", notionErr)
+	}
+
+	if !stats.local && stats.undone == nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("❌ Failed to load stats: %v", notionErr))
+		_, err := h.bot.Send(msg)
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, formatStats(stats, h.db != nil))
+	msg.ParseMode = tgbotapi.ModeMarkdownV2
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// formatStats renders stats as MarkdownV2, with the counts in monospace Markdown tables.
+// hasDB tells a missing local database apart from one that failed to load.
+func formatStats(stats taskStats, hasDB bool) string {
+	var b strings.Builder
+	b.WriteString("📊 *Task stats*\n")
+
+	b.WriteString("\n*Created via the bot*\n")
+	switch {
+	case stats.local:
+		rows := [][]string{{"all", strconv.Itoa(stats.createdWeek), strconv.Itoa(stats.createdMonth)}}
+		listed := make(map[string]bool)
+		for _, tag := range statsTags(stats.tagsMonth) {
+			listed[tag] = true
+			name := tag
+			if name == "" {
+				name = "untagged"
+			}
+			rows = append(rows, []string{name, strconv.Itoa(stats.tagsWeek[tag]), strconv.Itoa(stats.tagsMonth[tag])})
+		}
+		if other := unlistedCount(stats.tagsMonth, listed); other > 0 {
+			rows = append(rows, []string{"other", strconv.Itoa(unlistedCount(stats.tagsWeek, listed)), strconv.Itoa(other)})
+		}
+		b.WriteString(markdownTable([]string{"Tag", "7d", "30d"}, rows))
+	case hasDB:
+		b.WriteString(escapeMarkdown("Could not read the local database") + "\n")
+	default:
+		b.WriteString(escapeMarkdown("Not recorded, the local database is disabled") + "\n")
+	}
+
+	b.WriteString("\n*Open in Notion*\n")
+	if stats.undone == nil {
+		b.WriteString(escapeMarkdown("Could not load the counts from Notion") + "\n")
+	} else {
+		b.WriteString(markdownTable([]string{"Tasks", "Now"}, [][]string{
+			{"undone", formatCount(*stats.undone)},
+			{"overdue", formatCount(*stats.overdue)},
+		}))
+	}
+
+	if stats.pendingExpired > 0 || stats.pendingEvicted > 0 {
+		b.WriteString("\n" + escapeMarkdown(fmt.Sprintf("🧹 Pending messages dropped since the bot started: %d expired, %d over the per-user limit",
+			stats.pendingExpired, stats.pendingEvicted)) + "\n")
+	}
+
+	if stats.pausedUntil != "" {
+		b.WriteString("\n" + escapeMarkdown("🔕 Notifications paused until "+stats.pausedUntil+", /resume to turn them back on") + "\n")
+	}
+	return b.String()
+}
+
+// statsTags returns the tags to list, the most used first and at most maxStatsTags
+func statsTags(counts map[string]int) []string {
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	if len(tags) > maxStatsTags {
+		tags = tags[:maxStatsTags]
+	}
+	return tags
+}
+
+// unlistedCount sums the counts of the tags that aren't listed
+func unlistedCount(counts map[string]int, listed map[string]bool) int {
+	total := 0
+	for tag, n := range counts {
+		if !listed[tag] {
+			total += n
+		}
+	}
+	return total
+}
+
+// formatCount renders a count, marking capped ones with a plus
+func formatCount(count notion.DatabaseCount) string {
+	if count.Approximate {
+		return strconv.Itoa(count.Count) + "+"
+	}
+	return strconv.Itoa(count.Count)
+}
+
+// markdownTable renders a Markdown table in a MarkdownV2 code block, padded so the columns
+// line up in monospace. Columns after the first are numbers and aligned right.
+func markdownTable(header []string, rows [][]string) string {
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			if n := len([]rune(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	line := func(cells []string) string {
+		padded := make([]string, len(cells))
+		for i, cell := range cells {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(cell)))
+			if i == 0 {
+				padded[i] = cell + pad
+			} else {
+				padded[i] = pad + cell
+			}
+		}
+		return "| " + strings.Join(padded, " | ") + " |"
+	}
+
+	rules := make([]string, len(header))
+	for i, width := range widths {
+		if i == 0 {
+			rules[i] = strings.Repeat("-", width)
+		} else {
+			rules[i] = strings.Repeat("-", width-1) + ":"
+		}
+	}
+
+	lines := []string{line(header), "| " + strings.Join(rules, " | ") + " |"}
+	for _, row := range rows {
+		lines = append(lines, line(row))
+	}
+	// Inside a code block only ` and \ need escaping
+	code := strings.NewReplacer(`\`, `\\`, "`", "\\`").Replace(strings.Join(lines, "\n"))
+	return "```\n" + code + "\n```\n"
+}
@@ -0,0 +1,118 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+func TestFormatStats(t *testing.T) {
+	stats := taskStats{
+		local:        true,
+		createdWeek:  3,
+		createdMonth: 12,
+		tagsWeek:     map[string]int{"task": 2, "journal": 1},
+		tagsMonth:    map[string]int{"task": 8, "journal": 3, "link": 1},
+		undone:       &notion.DatabaseCount{Count: 42},
+		overdue:      &notion.DatabaseCount{Count: 100, Approximate: true},
+	}
+
+	want := "📊 *Task stats*\n" +
+		"\n*Created via the bot*\n" +
+		"```\n" +
+		"| Tag     | 7d | 30d |\n" +
+		"| ------- | -: | --: |\n" +
+		"| all     |  3 |  12 |\n" +
+		"| task    |  2 |   8 |\n" +
+		"| journal |  1 |   3 |\n" +
+		"| link    |  0 |   1 |\n" +
+		"```\n" +
+		"\n*Open in Notion*\n" +
+		"```\n" +
+		"| Tasks   |  Now |\n" +
+		"| ------- | ---: |\n" +
+		"| undone  |   42 |\n" +
+		"| overdue | 100+ |\n" +
+		"```\n"
+	if got := formatStats(stats, true); got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestFormatStatsGroupsRareTags(t *testing.T) {
+	stats := taskStats{local: true, tagsWeek: map[string]int{"t9": 1}, tagsMonth: map[string]int{}}
+	for i := 0; i < maxStatsTags+2; i++ {
+		stats.tagsMonth[string(rune('a'+i))] = 10 - i
+	}
+	stats.tagsMonth["t9"] = 1
+
+	got := formatStats(stats, true)
+	// The three least used tags are summed into other, including the one used this week
+	if want := "| other |  1 |   4 |\n"; !strings.Contains(got, want) {
+		t.Errorf("Expected an other row %q in\n%s", want, got)
+	}
+}
+
+func TestFormatStatsWithoutLocalDatabase(t *testing.T) {
+	stats := taskStats{undone: &notion.DatabaseCount{Count: 5}, overdue: &notion.DatabaseCount{Count: 1}}
+
+	got := formatStats(stats, false)
+	if want := "Not recorded, the local database is disabled\n"; !strings.Contains(got, want) {
+		t.Errorf("Expected %q in\n%s", want, got)
+	}
+	if want := "| undone  |   5 |\n"; !strings.Contains(got, want) {
+		t.Errorf("Expected the Notion counts in\n%s", got)
+	}
+
+	// A configured database that failed isn't reported as disabled
+	if got := formatStats(stats, true); !strings.Contains(got, "Could not read the local database\n") {
+		t.Errorf("Expected the local database failure in\n%s", got)
+	}
+}
+
+func TestFormatStatsWithoutNotion(t *testing.T) {
+	stats := taskStats{local: true, createdWeek: 1, createdMonth: 1, tagsWeek: map[string]int{"task": 1}, tagsMonth: map[string]int{"task": 1}}
+
+	if got := formatStats(stats, true); !strings.Contains(got, "Could not load the counts from Notion\n") {
+		t.Errorf("Expected the Notion failure in\n%s", got)
+	}
+}
+
+func TestFormatStatsShowsPause(t *testing.T) {
+	stats := taskStats{undone: &notion.DatabaseCount{Count: 5}, overdue: &notion.DatabaseCount{Count: 1}}
+	if got := formatStats(stats, false); strings.Contains(got, "paused") {
+		t.Errorf("Expected no pause line without a pause in\n%s", got)
+	}
+
+	stats.pausedUntil = "Sat 17 Oct 09:00"
+	if got := formatStats(stats, false); !strings.Contains(got, escapeMarkdown("🔕 Notifications paused until Sat 17 Oct 09:00")) {
+		t.Errorf("Expected the pause in\n%s", got)
+	}
+}
+
+func TestStatsCommandWithoutDatabase(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.db = nil
+	fake.results = `[{"object": "page", "id": "page-1", "properties": {}}, {"object": "page", "id": "page-2", "properties": {}}]`
+
+	message := testMessage("/stats", 0)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 6}}
+	if err := handler.HandleMessage(message); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	replies := telegram.callsTo("sendMessage")
+	if len(replies) != 1 {
+		t.Fatalf("Expected 1 reply, got %d", len(replies))
+	}
+	text := replies[0].Params.Get("text")
+	if !strings.Contains(text, "the local database is disabled") || !strings.Contains(text, "| undone  |   2 |") {
+		t.Errorf("Expected Notion-only stats, got\n%s", text)
+	}
+	if handler.pendingTasks[456][123] != nil {
+		t.Error("Expected /stats not to be stored as a pending task")
+	}
+}
@@ -0,0 +1,338 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// tagTaskLimit is how many open tasks /tags looks at
+	tagTaskLimit = 1000
+	// tagPreviewLines is how many proposed tags /tags preview lists
+	tagPreviewLines = 30
+	// tagPlanTTL is how long /tags apply can run a preview, tasks change after a while
+	tagPlanTTL = time.Hour
+)
+
+// tagProposal is the tag the LLM proposes for a task
+type tagProposal struct {
+	taskID string
+	title  string
+	tag    string
+}
+
+// tagPlan is the outcome of tagging the open tasks, applied right away by /tags or kept
+// by /tags preview for /tags apply
+type tagPlan struct {
+	proposals []tagProposal
+	skipped   int // Tasks already tagged, done or sometimes-later
+	llmErrors int // Tasks the LLM failed on, proposed with the default tag
+	createdAt time.Time
+}
+
+// Phases of a tagging run
+const (
+	tagPhaseProposing = "proposing"
+	tagPhaseApplying  = "applying"
+	tagPhaseFinished  = "finished"
+)
+
+// tagRun is the progress of a /tags run of a user, reported by /tags status. Guarded by
+// Handler.mu.
+type tagRun struct {
+	phase      string
+	done       int // Tasks given a tag by the LLM while proposing, or written to Notion while applying
+	failed     int // Tags that couldn't be written
+	total      int
+	finishedAt time.Time
+}
+
+// handleTagsCommand handles /tags and its subcommands: plain /tags tags the open tasks
+// right away, preview only proposes tags, apply writes the proposed ones and status
+// reports the progress
+func (h *Handler) handleTagsCommand(message *tgbotapi.Message) error {
+	chatID, userID := message.Chat.ID, message.From.ID
+	subcommand := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+
+	if subcommand == "status" {
+		h.reply(chatID, h.tagStatus(userID))
+		return nil
+	}
+	if subcommand != "" && subcommand != "preview" && subcommand != "apply" {
+		h.reply(chatID, "Usage: /tags to tag the open tasks, /tags preview to see the tags first, /tags apply to write the previewed tags, /tags status for the progress.")
+		return nil
+	}
+	if h.tagger == nil {
+		h.reply(chatID, "❌ AI tagging not configured")
+		return nil
+	}
+
+	var plan *tagPlan
+	if subcommand == "apply" {
+		h.mu.Lock()
+		plan = h.tagPlans[userID]
+		if plan != nil && time.Since(plan.createdAt) > tagPlanTTL {
+			delete(h.tagPlans, userID)
+			plan = nil
+		}
+		h.mu.Unlock()
+		if plan == nil {
+			h.reply(chatID, "Nothing to apply, send /tags preview first.")
+			return nil
+		}
+	}
+
+	if !h.startTagRun(userID) {
+		h.reply(chatID, "🏷️ Tagging is already running, see /tags status.")
+		return nil
+	}
+	if plan != nil {
+		h.mu.Lock()
+		delete(h.tagPlans, userID)
+		h.mu.Unlock()
+	}
+
+	client := h.notionFor(userID)
+	switch {
+	case subcommand == "preview":
+		h.reply(chatID, "🏷️ Proposing tags for the open tasks, nothing is changed yet... This may take a while.")
+	case plan != nil:
+		h.reply(chatID, fmt.Sprintf("🏷️ Applying %d tags from the preview...", len(plan.proposals)))
+	default:
+		h.reply(chatID, "🏷️ Starting to tag all tasks... This may take a while.")
+	}
+
+	go func() {
+		ctx := context.Background()
+		if plan == nil {
+			var err error
+			plan, err = h.buildTagPlan(ctx, client, userID)
+			if err != nil {
+				log.Printf("Error retrieving tasks for tagging: %v", err)
+				h.finishTagRun(userID)
+				h.reply(chatID, "❌ Failed to retrieve tasks: "+notion.Explain(err))
+				return
+			}
+		}
+
+		if subcommand == "preview" {
+			h.mu.Lock()
+			if h.tagPlans == nil {
+				h.tagPlans = make(map[int64]*tagPlan)
+			}
+			h.tagPlans[userID] = plan
+			h.mu.Unlock()
+			h.finishTagRun(userID)
+			h.reply(chatID, renderTagPreview(plan))
+			return
+		}
+
+		tagged, failed := h.applyTagPlan(ctx, client, userID, plan)
+		h.finishTagRun(userID)
+
+		summary := fmt.Sprintf(
+			"✅ Tagging complete!\n\n"+
+				"📊 Summary:\n"+
+				"• Tagged: %d tasks\n"+
+				"• Skipped (already tagged): %d\n"+
+				"• Errors: %d\n"+
+				"• Total processed: %d",
+			tagged, plan.skipped, plan.llmErrors+failed, len(plan.proposals)+plan.skipped)
+		h.reply(chatID, summary)
+		log.Printf("/tags command: Completed. Tagged=%d, Skipped=%d, Errors=%d", tagged, plan.skipped, plan.llmErrors+failed)
+	}()
+
+	return nil
+}
+
+// buildTagPlan asks the LLM for the tag of every open task that has none yet
+func (h *Handler) buildTagPlan(ctx context.Context, client *notion.Client, userID int64) (*tagPlan, error) {
+	log.Printf("/tags command: Starting to tag all tasks")
+
+	// Get all non-done tasks
+	tasks, err := client.GetRecentTasks(ctx, "tasks", tagTaskLimit)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("/tags command: Found %d tasks to tag", len(tasks))
+
+	plan := &tagPlan{createdAt: time.Now()}
+	var untagged []notion.Task
+	for _, task := range tasks {
+		if skipTagging(task) {
+			plan.skipped++
+			continue
+		}
+		untagged = append(untagged, task)
+	}
+	h.updateTagRun(userID, func(run *tagRun) { run.total = len(untagged) })
+
+	for i, task := range untagged {
+		log.Printf("/tags command: Processing task %d/%d: %s", i+1, len(untagged), task.Title)
+		tag, err := h.tagger.TagTask(ctx, task.Title)
+		if err != nil {
+			log.Printf("/tags command: Failed to tag task %s: %v", task.ID, err)
+			plan.llmErrors++
+			// Use default tag on error
+			tag = llm.DefaultTag
+		}
+		plan.proposals = append(plan.proposals, tagProposal{taskID: task.ID, title: task.Title, tag: tag})
+		h.updateTagRun(userID, func(run *tagRun) { run.done++ })
+	}
+	return plan, nil
+}
+
+// skipTagging reports whether /tags leaves a task alone: it's tagged already, or done or
+// sometimes-later in case the filter didn't catch it
+func skipTagging(task notion.Task) bool {
+	if existingTag, ok := task.Properties["llm_tag"].(string); ok && existingTag != "" {
+		log.Printf("/tags command: Task %s already has llm_tag '%s', skipping", task.ID, existingTag)
+		return true
+	}
+	if status, ok := task.Properties["status"].(string); ok && status == "done" {
+		log.Printf("/tags command: Task %s has status 'done', skipping", task.ID)
+		return true
+	}
+	if tags, ok := task.Properties["Tags"].([]string); ok {
+		for _, tag := range tags {
+			if tag == "sometimes-later" {
+				log.Printf("/tags command: Task %s has 'sometimes-later' tag, skipping", task.ID)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyTagPlan writes the proposed tags to Notion, returning how many were written and failed
+func (h *Handler) applyTagPlan(ctx context.Context, client *notion.Client, userID int64, plan *tagPlan) (tagged, failed int) {
+	h.updateTagRun(userID, func(run *tagRun) {
+		run.phase, run.done, run.failed, run.total = tagPhaseApplying, 0, 0, len(plan.proposals)
+	})
+
+	for _, proposal := range plan.proposals {
+		if err := client.UpdateTaskLLMTag(ctx, proposal.taskID, proposal.tag); err != nil {
+			log.Printf("/tags command: Failed to update task %s in Notion: %v", proposal.taskID, err)
+			failed++
+			h.updateTagRun(userID, func(run *tagRun) { run.failed++ })
+			continue
+		}
+		log.Printf("/tags command: Successfully tagged task %s as '%s'", proposal.taskID, proposal.tag)
+		tagged++
+		h.updateTagRun(userID, func(run *tagRun) { run.done++ })
+	}
+	return tagged, failed
+}
+
+// startTagRun records a new run of the user, reporting false while one is running
+func (h *Handler) startTagRun(userID int64) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if run := h.tagRuns[userID]; run != nil && run.phase != tagPhaseFinished {
+		return false
+	}
+	if h.tagRuns == nil {
+		h.tagRuns = make(map[int64]*tagRun)
+	}
+	h.tagRuns[userID] = &tagRun{phase: tagPhaseProposing}
+	return true
+}
+
+// updateTagRun changes the progress of the user's run
+func (h *Handler) updateTagRun(userID int64, update func(run *tagRun)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if run := h.tagRuns[userID]; run != nil {
+		update(run)
+	}
+}
+
+// finishTagRun marks the user's run finished, its counts stay for /tags status
+func (h *Handler) finishTagRun(userID int64) {
+	h.updateTagRun(userID, func(run *tagRun) {
+		run.phase = tagPhaseFinished
+		run.finishedAt = time.Now()
+	})
+}
+
+// tagStatus describes the progress of the user's run for /tags status
+func (h *Handler) tagStatus(userID int64) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	run := h.tagRuns[userID]
+	var status string
+	switch {
+	case run == nil:
+		status = "🏷️ No tagging has run since the bot started."
+	case run.phase == tagPhaseProposing:
+		status = fmt.Sprintf("🏷️ Proposing tags: %d/%d tasks", run.done, run.total)
+	case run.phase == tagPhaseApplying:
+		status = fmt.Sprintf("🏷️ Applying tags: %d/%d tagged", run.done, run.total)
+	default:
+		status = fmt.Sprintf("🏷️ Last tagging finished at %s", run.finishedAt.In(h.location()).Format("15:04"))
+		if run.total > 0 {
+			status += fmt.Sprintf(": %d/%d", run.done, run.total)
+		}
+	}
+	if run != nil && run.failed > 0 {
+		status += fmt.Sprintf(", %d errors", run.failed)
+	}
+	if plan := h.tagPlans[userID]; plan != nil && time.Since(plan.createdAt) <= tagPlanTTL {
+		status += fmt.Sprintf("\n\nA preview of %d tags is waiting for /tags apply.", len(plan.proposals))
+	}
+	return status
+}
+
+// renderTagPreview lists the first proposed tags and the count of each tag
+func renderTagPreview(plan *tagPlan) string {
+	if len(plan.proposals) == 0 {
+		return fmt.Sprintf("🏷️ No open tasks without a tag (%d skipped), nothing to apply.", plan.skipped)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "🏷️ Proposed tags for %d tasks:\n\n", len(plan.proposals))
+	for i, proposal := range plan.proposals {
+		if i == tagPreviewLines {
+			fmt.Fprintf(&b, "… and %d more\n", len(plan.proposals)-tagPreviewLines)
+			break
+		}
+		fmt.Fprintf(&b, "• %s → %s\n", truncateRunes(proposal.title, 60), proposal.tag)
+	}
+
+	counts := make(map[string]int)
+	for _, proposal := range plan.proposals {
+		counts[proposal.tag]++
+	}
+	tags := make([]string, 0, len(counts))
+	for tag := range counts {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	b.WriteString("\nPer tag: ")
+	for i, tag := range tags {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s %d", tag, counts[tag])
+	}
+	fmt.Fprintf(&b, "\nSkipped (already tagged): %d", plan.skipped)
+	if plan.llmErrors > 0 {
+		fmt.Fprintf(&b, "\nThe AI failed on %d, they get %q", plan.llmErrors, llm.DefaultTag)
+	}
+	b.WriteString("\n\nSend /tags apply within an hour to write exactly these tags.")
+	return b.String()
+}
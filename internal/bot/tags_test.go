@@ -0,0 +1,210 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+// titleTagger tags tasks by title and fails on titles it doesn't know
+type titleTagger struct {
+	tags map[string]string
+}
+
+func (f *titleTagger) TagTask(ctx context.Context, content string) (string, error) {
+	if tag, ok := f.tags[content]; ok {
+		return tag, nil
+	}
+	return "", errors.New("model overloaded")
+}
+
+func (f *titleTagger) TagTasksBatch(ctx context.Context, contents []string) ([]string, error) {
+	tags := make([]string, len(contents))
+	for i, content := range contents {
+		tags[i], _ = f.TagTask(ctx, content)
+	}
+	return tags, nil
+}
+
+// tagTestResults renders two untagged tasks, one that fails in the LLM and one tagged already
+const tagTestResults = `[
+	{"object": "page", "id": "page-1", "properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Buy milk"}, "plain_text": "Buy milk"}]}}},
+	{"object": "page", "id": "page-2", "properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Fix the bike"}, "plain_text": "Fix the bike"}]}}},
+	{"object": "page", "id": "page-3", "properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Mystery"}, "plain_text": "Mystery"}]}}},
+	{"object": "page", "id": "page-4", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Call mom"}, "plain_text": "Call mom"}]},
+		"llm_tag": {"id": "tag", "type": "select", "select": {"name": "family"}}
+	}}
+]`
+
+func newTagsTestHandler(t *testing.T) (*Handler, *fakeTelegram, *fakeNotionAPI) {
+	t.Helper()
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	fake.results = tagTestResults
+	handler.tagger = &titleTagger{tags: map[string]string{"Buy milk": "errands", "Fix the bike": "home"}}
+	return handler, telegram, fake
+}
+
+// llmTagUpdates returns the llm_tag written to each page
+func llmTagUpdates(t *testing.T, fake *fakeNotionAPI) map[string]string {
+	t.Helper()
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	updates := make(map[string]string)
+	for _, r := range fake.requests {
+		if r.Method != http.MethodPatch {
+			continue
+		}
+		var body struct {
+			Properties map[string]struct {
+				RichText []struct {
+					Text struct {
+						Content string `json:"content"`
+					} `json:"text"`
+				} `json:"rich_text"`
+			} `json:"properties"`
+		}
+		if err := json.Unmarshal(r.Body, &body); err != nil {
+			t.Fatalf("Invalid request body %s: %v", r.Body, err)
+		}
+		if tag := body.Properties["llm_tag"].RichText; len(tag) > 0 {
+			updates[strings.TrimPrefix(r.Path, "/v1/pages/")] = tag[0].Text.Content
+		}
+	}
+	return updates
+}
+
+// waitForTagRun waits until the background /tags run of the test user finishes
+func waitForTagRun(t *testing.T, handler *Handler) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		handler.mu.Lock()
+		run := handler.tagRuns[456]
+		finished := run != nil && run.phase == tagPhaseFinished
+		handler.mu.Unlock()
+		if finished {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("The /tags run didn't finish")
+}
+
+func TestBuildTagPlan(t *testing.T) {
+	handler, _, _ := newTagsTestHandler(t)
+	handler.startTagRun(456)
+
+	plan, err := handler.buildTagPlan(context.Background(), handler.notion, 456)
+	if err != nil {
+		t.Fatalf("buildTagPlan failed: %v", err)
+	}
+
+	expected := []tagProposal{
+		{taskID: "page-1", title: "Buy milk", tag: "errands"},
+		{taskID: "page-2", title: "Fix the bike", tag: "home"},
+		{taskID: "page-3", title: "Mystery", tag: llm.DefaultTag},
+	}
+	if fmt.Sprint(plan.proposals) != fmt.Sprint(expected) {
+		t.Errorf("Expected proposals %v, got %v", expected, plan.proposals)
+	}
+	if plan.skipped != 1 || plan.llmErrors != 1 {
+		t.Errorf("Expected 1 skipped and 1 LLM error, got %d and %d", plan.skipped, plan.llmErrors)
+	}
+	if status := handler.tagStatus(456); status != "🏷️ Proposing tags: 3/3 tasks" {
+		t.Errorf("Expected the proposing progress, got %q", status)
+	}
+}
+
+func TestTagsPreviewThenApply(t *testing.T) {
+	handler, telegram, fake := newTagsTestHandler(t)
+
+	if err := handler.HandleMessage(botCommand("/tags", "preview")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	waitForTagRun(t, handler)
+
+	if updates := llmTagUpdates(t, fake); len(updates) != 0 {
+		t.Fatalf("Expected the preview to change nothing, got %v", updates)
+	}
+	sent := telegram.callsTo("sendMessage")
+	preview := sent[len(sent)-1].Params.Get("text")
+	for _, want := range []string{"• Buy milk → errands", "• Mystery → " + llm.DefaultTag, "Per tag: ", "errands 1", "Skipped (already tagged): 1", "/tags apply"} {
+		if !strings.Contains(preview, want) {
+			t.Errorf("Expected the preview to contain %q, got %q", want, preview)
+		}
+	}
+	if status := handler.tagStatus(456); !strings.Contains(status, "A preview of 3 tags is waiting") {
+		t.Errorf("Expected the status to mention the preview, got %q", status)
+	}
+
+	// Apply writes the previewed tags without asking the LLM again
+	handler.tagger = &titleTagger{tags: map[string]string{"Buy milk": "shopping"}}
+	if err := handler.HandleMessage(botCommand("/tags", "apply")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	waitForTagRun(t, handler)
+
+	expected := map[string]string{"page-1": "errands", "page-2": "home", "page-3": llm.DefaultTag}
+	if updates := llmTagUpdates(t, fake); fmt.Sprint(updates) != fmt.Sprint(expected) {
+		t.Errorf("Expected the tags of the preview %v, got %v", expected, updates)
+	}
+	if status := handler.tagStatus(456); !strings.HasSuffix(status, ": 3/3") {
+		t.Errorf("Expected all 3 tags to be applied, got %q", status)
+	}
+
+	// The plan is used once
+	if err := handler.HandleMessage(botCommand("/tags", "apply")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	sent = telegram.callsTo("sendMessage")
+	if last := sent[len(sent)-1].Params.Get("text"); !strings.Contains(last, "Nothing to apply") {
+		t.Errorf("Expected a second apply to be refused, got %q", last)
+	}
+}
+
+func TestTagsRefusesConcurrentRuns(t *testing.T) {
+	handler, telegram, _ := newTagsTestHandler(t)
+	handler.startTagRun(456)
+	handler.updateTagRun(456, func(run *tagRun) {
+		run.phase, run.done, run.failed, run.total = tagPhaseApplying, 5, 1, 20
+	})
+
+	if status := handler.tagStatus(456); status != "🏷️ Applying tags: 5/20 tagged, 1 errors" {
+		t.Errorf("Expected the applying progress, got %q", status)
+	}
+	if err := handler.HandleMessage(botCommand("/tags", "")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if last := sent[len(sent)-1].Params.Get("text"); !strings.Contains(last, "already running") {
+		t.Errorf("Expected the second run to be refused, got %q", last)
+	}
+}
+
+func TestRenderTagPreviewLimitsLines(t *testing.T) {
+	plan := &tagPlan{}
+	for i := 0; i < tagPreviewLines+5; i++ {
+		tag := "work"
+		if i%3 == 0 {
+			tag = "home"
+		}
+		plan.proposals = append(plan.proposals, tagProposal{taskID: fmt.Sprintf("page-%d", i), title: fmt.Sprintf("Task %d", i), tag: tag})
+	}
+
+	preview := renderTagPreview(plan)
+	if lines := strings.Count(preview, "\n• "); lines != tagPreviewLines {
+		t.Errorf("Expected %d listed tasks, got %d", tagPreviewLines, lines)
+	}
+	if !strings.Contains(preview, "… and 5 more") || !strings.Contains(preview, "Per tag: work 23, home 12") {
+		t.Errorf("Expected the remaining count and the per tag counts, got %q", preview)
+	}
+}
@@ -0,0 +1,276 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// templateCallbackPrefix marks callback data of the buttons /template lists, followed by
+// the index of the template in the file
+const templateCallbackPrefix = "template:"
+
+// placeholderPattern matches placeholders like {{date}} or {{date+3}} in template titles
+// and property values
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*(?:([+-])\s*(\d+)\s*)?\}\}`)
+
+// TaskTemplate is a set of tasks /template creates at once, like weekly planning
+type TaskTemplate struct {
+	Name       string                 `json:"name"`
+	Tasks      []string               `json:"tasks"`                // Titles of the tasks, with placeholders
+	Properties map[string]interface{} `json:"properties,omitempty"` // Given to every task, string values with placeholders
+}
+
+// templateTask is the outcome of creating one task of a template
+type templateTask struct {
+	id    string
+	title string
+	err   error
+}
+
+// TemplatesFromEnv loads the templates of TASK_TEMPLATES_FILE, none when it isn't set
+func TemplatesFromEnv() ([]TaskTemplate, error) {
+	path := os.Getenv("TASK_TEMPLATES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	templates, err := LoadTemplates(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TASK_TEMPLATES_FILE: %w", err)
+	}
+	return templates, nil
+}
+
+// LoadTemplates reads a JSON array of templates. Names must be unique, every template
+// needs a task and the placeholders must be known, so mistakes show at startup.
+func LoadTemplates(path string) ([]TaskTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var templates []TaskTemplate
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&templates); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	names := make(map[string]bool)
+	for i, template := range templates {
+		name := strings.TrimSpace(template.Name)
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("template %d has no name", i+1)
+		case names[strings.ToLower(name)]:
+			return nil, fmt.Errorf("template %q is defined twice", name)
+		case len(template.Tasks) == 0:
+			return nil, fmt.Errorf("template %q has no tasks", name)
+		}
+		names[strings.ToLower(name)] = true
+
+		for _, title := range template.Tasks {
+			if expanded, err := expandPlaceholders(title, time.Now()); err != nil {
+				return nil, fmt.Errorf("template %q: %w", name, err)
+			} else if strings.TrimSpace(expanded) == "" {
+				return nil, fmt.Errorf("template %q has a task without a title", name)
+			}
+		}
+		if _, err := expandProperties(template.Properties, time.Now()); err != nil {
+			return nil, fmt.Errorf("template %q: %w", name, err)
+		}
+	}
+	return templates, nil
+}
+
+// SetTemplates sets the templates /template offers
+func (h *Handler) SetTemplates(templates []TaskTemplate) {
+	h.templates = templates
+}
+
+// expandPlaceholders replaces {{date}} with today as YYYY-MM-DD, and {{date+N}} or
+// {{date-N}} with the day N days later or earlier
+func expandPlaceholders(text string, today time.Time) (string, error) {
+	var err error
+	expanded := placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		match := placeholderPattern.FindStringSubmatch(placeholder)
+		if match[1] != "date" {
+			err = errors.Join(err, fmt.Errorf("unknown placeholder %s, expected {{date}} or {{date+N}}", placeholder))
+			return placeholder
+		}
+
+		days := 0
+		if match[3] != "" {
+			days, _ = strconv.Atoi(match[3])
+			if match[2] == "-" {
+				days = -days
+			}
+		}
+		return today.AddDate(0, 0, days).Format("2006-01-02")
+	})
+	return expanded, err
+}
+
+// expandProperties copies properties with the placeholders of string values, also in
+// lists, expanded
+func expandProperties(properties map[string]interface{}, today time.Time) (map[string]interface{}, error) {
+	if len(properties) == 0 {
+		return nil, nil
+	}
+
+	expanded := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		switch v := value.(type) {
+		case string:
+			s, err := expandPlaceholders(v, today)
+			if err != nil {
+				return nil, fmt.Errorf("property %s: %w", key, err)
+			}
+			expanded[key] = s
+		case []interface{}:
+			items := make([]interface{}, len(v))
+			for i, item := range v {
+				items[i] = item
+				if s, ok := item.(string); ok {
+					expandedItem, err := expandPlaceholders(s, today)
+					if err != nil {
+						return nil, fmt.Errorf("property %s: %w", key, err)
+					}
+					items[i] = expandedItem
+				}
+			}
+			expanded[key] = items
+		default:
+			expanded[key] = value
+		}
+	}
+	return expanded, nil
+}
+
+// handleTemplateCommand lists the templates as buttons, pressing one creates its tasks
+func (h *Handler) handleTemplateCommand(message *tgbotapi.Message) error {
+	if len(h.templates) == 0 {
+		h.reply(message.Chat.ID, "No templates configured. Define them in the JSON file of TASK_TEMPLATES_FILE.")
+		return nil
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(h.templates))
+	for i, template := range h.templates {
+		label := fmt.Sprintf("%s (%d)", template.Name, len(template.Tasks))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, templateCallbackPrefix+strconv.Itoa(i)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, "📋 Pick a template to create its tasks:")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	_, err := h.bot.Send(msg)
+	return err
+}
+
+// handleTemplateCallback creates the tasks of the pressed template and replies with them
+func (h *Handler) handleTemplateCallback(query *tgbotapi.CallbackQuery, arg string) error {
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 0 || index >= len(h.templates) || query.Message == nil {
+		log.Printf("Unknown template in callback data: %s", query.Data)
+		_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "❌ Unknown template"))
+		return err
+	}
+	template := h.templates[index]
+
+	if _, err := h.bot.Request(tgbotapi.NewCallback(query.ID, "📋 Creating tasks...")); err != nil {
+		log.Printf("Warning: Failed to answer callback query: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	client := h.notionFor(query.From.ID)
+	tasks := h.createTemplateTasks(ctx, query.From.ID, client, template, time.Now().In(h.location()))
+
+	msg := tgbotapi.NewMessage(query.Message.Chat.ID, renderTemplateReply(template.Name, tasks))
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.DisableWebPagePreview = true
+	if _, err := h.bot.Send(msg); err != nil {
+		log.Printf("Warning: Could not send the template summary: %v", err)
+	}
+
+	var created []templateTask
+	for _, task := range tasks {
+		if task.err == nil {
+			created = append(created, task)
+		}
+	}
+	if len(created) == 0 {
+		return fmt.Errorf("failed to create any of the %d tasks of template %q: %w", len(tasks), template.Name, tasks[len(tasks)-1].err)
+	}
+	if h.tagger != nil {
+		go func() {
+			for _, task := range created {
+				h.tagSavedTask(context.Background(), client, task.id, task.title, "")
+			}
+		}()
+	}
+	return nil
+}
+
+// createTemplateTasks creates the tasks of a template for a user, with its placeholders
+// resolved for today. A task that fails doesn't stop the others.
+func (h *Handler) createTemplateTasks(ctx context.Context, userID int64, client *notion.Client, template TaskTemplate, today time.Time) []templateTask {
+	// Options in the template are meant as written, unknown ones become options
+	ctx = notion.WithNewOptions(ctx)
+
+	// Checked when loading, the expansion can't fail
+	properties, _ := expandProperties(template.Properties, today)
+
+	tasks := make([]templateTask, 0, len(template.Tasks))
+	for _, title := range template.Tasks {
+		title, _ = expandPlaceholders(title, today)
+		taskID, err := client.CreateTask(ctx, title, properties, "tasks")
+		if err != nil {
+			log.Printf("Warning: Failed to create task %q of template %q: %v", title, template.Name, err)
+		} else {
+			h.recordTaskMetadata(userID, taskID, title, "")
+		}
+		tasks = append(tasks, templateTask{id: taskID, title: title, err: err})
+	}
+	log.Printf("Created the tasks of template %q", template.Name)
+	return tasks
+}
+
+// renderTemplateReply lists the tasks created from a template and the ones that failed
+func renderTemplateReply(name string, tasks []templateTask) string {
+	var created, failed []templateTask
+	for _, task := range tasks {
+		if task.err == nil {
+			created = append(created, task)
+		} else {
+			failed = append(failed, task)
+		}
+	}
+
+	var b strings.Builder
+	if len(failed) == 0 {
+		fmt.Fprintf(&b, "📋 Created %d tasks from \"%s\":", len(created), html.EscapeString(name))
+	} else {
+		fmt.Fprintf(&b, "📋 Created %d of %d tasks from \"%s\":", len(created), len(tasks), html.EscapeString(name))
+	}
+	for _, task := range created {
+		fmt.Fprintf(&b, "\n• <a href=\"%s\">%s</a>", html.EscapeString(notionPageURL(task.id)), html.EscapeString(truncateRunes(task.title, 80)))
+	}
+	for _, task := range failed {
+		fmt.Fprintf(&b, "\n❌ %s: %s", html.EscapeString(truncateRunes(task.title, 80)), html.EscapeString(notion.Explain(task.err)))
+	}
+	return b.String()
+}
@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+func TestExpandPlaceholders(t *testing.T) {
+	today := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{text: "Plan the week", want: "Plan the week"},
+		{text: "Review {{date}}", want: "Review 2024-05-01"},
+		{text: "Report due {{date+3}}", want: "Report due 2024-05-04"},
+		{text: "Since {{ date - 1 }}", want: "Since 2024-04-30"},
+		{text: "{{date+30}} to {{date+31}}", want: "2024-05-31 to 2024-06-01"},
+		{text: "Call {{name}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := expandPlaceholders(tt.text, today)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected %q to fail, got %q", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("expandPlaceholders(%q) = %q, %v, expected %q", tt.text, got, err, tt.want)
+		}
+	}
+
+	properties, err := expandProperties(map[string]interface{}{"Date": "{{date+3}}", "Tags": []interface{}{"planning", "{{date}}"}, "Estimate": 2.0}, today)
+	if err != nil {
+		t.Fatalf("expandProperties failed: %v", err)
+	}
+	if properties["Date"] != "2024-05-04" || propertyStrings(properties["Tags"])[1] != "2024-05-01" || properties["Estimate"] != 2.0 {
+		t.Errorf("Expected the string values expanded, got %v", properties)
+	}
+}
+
+// writeTemplates writes a templates file and returns its path
+func writeTemplates(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "templates.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write templates: %v", err)
+	}
+	return path
+}
+
+func TestLoadTemplates(t *testing.T) {
+	templates, err := LoadTemplates(writeTemplates(t, `[
+		{"name": "Weekly planning", "tasks": ["Review inbox", "Plan until {{date+6}}"], "properties": {"Tags": ["planning"], "Date": "{{date}}"}}
+	]`))
+	if err != nil {
+		t.Fatalf("LoadTemplates failed: %v", err)
+	}
+	if len(templates) != 1 || templates[0].Name != "Weekly planning" || len(templates[0].Tasks) != 2 {
+		t.Errorf("Expected the weekly planning template, got %+v", templates)
+	}
+
+	invalid := map[string]string{
+		"not JSON":            `[{"name": "Weekly planning",`,
+		"unknown field":       `[{"name": "Weekly planning", "tasks": ["Review"], "title": "x"}]`,
+		"no name":             `[{"tasks": ["Review"]}]`,
+		"duplicate name":      `[{"name": "Weekly", "tasks": ["Review"]}, {"name": "weekly", "tasks": ["Plan"]}]`,
+		"no tasks":            `[{"name": "Weekly", "tasks": []}]`,
+		"unknown placeholder": `[{"name": "Weekly", "tasks": ["Review {{week}}"]}]`,
+		"bad property":        `[{"name": "Weekly", "tasks": ["Review"], "properties": {"Date": "{{tomorrow}}"}}]`,
+	}
+	for name, content := range invalid {
+		if _, err := LoadTemplates(writeTemplates(t, content)); err == nil {
+			t.Errorf("Expected the %s file to be rejected", name)
+		}
+	}
+}
+
+// failingTitle rejects the creation of pages whose request contains a title
+type failingTitle struct {
+	*fakeNotionAPI
+	title string
+}
+
+func (f *failingTitle) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.URL.Path != "/v1/pages" {
+		return f.fakeNotionAPI.RoundTrip(req)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if !bytes.Contains(body, []byte(f.title)) {
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		return f.fakeNotionAPI.RoundTrip(req)
+	}
+	return &http.Response{
+		StatusCode: http.StatusBadRequest,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"object": "error", "status": 400, "code": "validation_error", "message": "Title is invalid."}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestTemplateCreatesItsTasks(t *testing.T) {
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	handler.notion = notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: &failingTitle{fakeNotionAPI: fake, title: "Broken"}}))
+	handler.SetTemplates([]TaskTemplate{
+		{Name: "Errands", Tasks: []string{"Buy milk"}},
+		{Name: "Weekly planning", Tasks: []string{"Review inbox {{date}}", "Broken task", "Plan until {{date+6}}"}},
+	})
+
+	if err := handler.HandleMessage(testMessage("/template", 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 || !strings.Contains(sent[0].Params.Get("reply_markup"), `"callback_data":"template:1"`) {
+		t.Fatalf("Expected a button per template, got %+v", sent)
+	}
+
+	query := &tgbotapi.CallbackQuery{
+		ID:      "cb-1",
+		From:    &tgbotapi.User{ID: 456},
+		Message: &tgbotapi.Message{MessageID: 1001, Chat: &tgbotapi.Chat{ID: 789}},
+		Data:    templateCallbackPrefix + "1",
+	}
+	if err := handler.HandleCallbackQuery(query); err != nil {
+		t.Fatalf("Expected the template to succeed with one failed task, got %v", err)
+	}
+
+	today := time.Now().In(handler.location())
+	want := []string{"Review inbox " + today.Format("2006-01-02"), "Plan until " + today.AddDate(0, 0, 6).Format("2006-01-02")}
+	if created := fake.titles(t, http.MethodPost, "/v1/pages"); strings.Join(created, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected the tasks %v, got %v", want, created)
+	}
+
+	sent = telegram.callsTo("sendMessage")
+	summary := sent[len(sent)-1].Params.Get("text")
+	for _, part := range []string{`📋 Created 2 of 3 tasks from "Weekly planning":`, `<a href="https://notion.so/page1">` + want[0] + `</a>`, "❌ Broken task: Notion rejected the request: Title is invalid."} {
+		if !strings.Contains(summary, part) {
+			t.Errorf("Expected the summary to contain %q, got %q", part, summary)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestReactionSaveSpanHierarchy(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracing.Use(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { tracing.Use(nil) })
+
+	geminiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "work"}]}}]}`))
+	}))
+	defer geminiServer.Close()
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_URL", geminiServer.URL)
+
+	handler, fake := newEditTestHandler(t)
+	handler.notion = notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: tracing.Transport(fake, "notion")}))
+	handler.tagger = gemini.NewClient()
+
+	handler.storePendingTask(testMessage("Buy milk", 0))
+	ctx, root := tracing.Start(context.Background(), "telegram.webhook")
+	if err := handler.HandleMessageReaction(ctx, thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+	root.End()
+
+	// Tagging runs in the background after the save
+	deadline := time.Now().Add(5 * time.Second)
+	spans := make(map[string]tracetest.SpanStub)
+	for time.Now().Before(deadline) {
+		for _, span := range exporter.GetSpans() {
+			if _, ok := spans[span.Name]; !ok {
+				spans[span.Name] = span
+			}
+		}
+		if _, ok := spans["gemini generateContent"]; ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	parentOf := func(child, parent string) {
+		t.Helper()
+		c, ok := spans[child]
+		if !ok {
+			t.Fatalf("Expected a %q span, got %v", child, spanNames(spans))
+		}
+		if c.Parent.SpanID() != spans[parent].SpanContext.SpanID() {
+			t.Errorf("Expected %q to be a child of %q", child, parent)
+		}
+	}
+	parentOf("bot.save_task", "telegram.webhook")
+	parentOf("notion POST", "bot.save_task")
+	parentOf("gemini generateContent", "bot.save_task")
+}
+
+func spanNames(spans map[string]tracetest.SpanStub) []string {
+	names := make([]string, 0, len(spans))
+	for name := range spans {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,68 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// handleUseCommand picks the task database the chat's messages are saved to, of the
+// databases named in NOTION_DATABASES. Without a name it lists them and marks the active one.
+func (h *Handler) handleUseCommand(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	if h.db == nil {
+		h.reply(chatID, "❌ Picking a database needs the local database")
+		return nil
+	}
+
+	client := h.notionFor(message.From.ID)
+	names := client.TaskDatabases()
+	active := h.activeDatabase(chatID, client)
+
+	name := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+	if name == "" {
+		var lines []string
+		for _, n := range names {
+			if n == active {
+				lines = append(lines, fmt.Sprintf("• %s ✅", n))
+			} else {
+				lines = append(lines, "• "+n)
+			}
+		}
+		h.reply(chatID, "🗂 Tasks from this chat are saved to:\n"+strings.Join(lines, "\n")+"\n\nSwitch with /use <name>, databases are named in NOTION_DATABASES.")
+		return nil
+	}
+
+	if !client.IsTaskDatabase(name) {
+		h.reply(chatID, fmt.Sprintf("❌ Unknown database %q, expected one of: %s", name, strings.Join(names, ", ")))
+		return nil
+	}
+	if err := h.db.SetChatDatabase(chatID, name, time.Now()); err != nil {
+		log.Printf("Failed to store the database of chat %d: %v", chatID, err)
+		h.reply(chatID, "❌ Failed to switch the database.")
+		return nil
+	}
+	h.reply(chatID, fmt.Sprintf("✅ Tasks from this chat now go to the %s database, including messages still waiting for their 👍.", name))
+	return nil
+}
+
+// activeDatabase returns the task database a chat picked with /use, tasks when it picked
+// none or its pick is no longer configured
+func (h *Handler) activeDatabase(chatID int64, client *notion.Client) string {
+	if h.db == nil {
+		return "tasks"
+	}
+	name, err := h.db.GetChatDatabase(chatID)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return "tasks"
+	}
+	if name == "" || !client.IsTaskDatabase(name) {
+		return "tasks"
+	}
+	return name
+}
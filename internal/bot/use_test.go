@@ -0,0 +1,80 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// createdIn returns the database IDs pages were created in, in order
+func createdIn(fake *fakeNotionAPI) []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	var ids []string
+	for _, r := range fake.requests {
+		if r.Method != http.MethodPost || r.Path != "/v1/pages" {
+			continue
+		}
+		body := string(r.Body)
+		for _, id := range []string{"tasks-db", "work-db"} {
+			if strings.Contains(body, `"database_id":"`+id+`"`) {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func TestUseRoutesTasksToTheChatDatabase(t *testing.T) {
+	t.Setenv("NOTION_DATABASES", `{"work": "work-db"}`)
+	handler, telegram, fake := newRecoveryTestHandler(t)
+	lastReply := func() string {
+		sent := telegram.callsTo("sendMessage")
+		if len(sent) == 0 {
+			return ""
+		}
+		return sent[len(sent)-1].Params.Get("text")
+	}
+
+	if err := handler.HandleMessage(botCommand("/use", "home")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if !strings.Contains(lastReply(), "Unknown database") {
+		t.Errorf("Expected an unknown database to be rejected, got %q", lastReply())
+	}
+
+	// A message waiting for its 👍 is saved to the database active at the save
+	handler.storePendingTask(testMessage("Send the report", 0))
+	if err := handler.HandleMessage(botCommand("/use", "Work")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if name, err := handler.db.GetChatDatabase(789); err != nil || name != "work" {
+		t.Fatalf("Expected work stored for the chat, got %q, %v", name, err)
+	}
+	if err := handler.HandleMessageReaction(context.Background(), thumbsUp()); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if err := handler.HandleMessage(botCommand("/use", "")); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+	if got := lastReply(); !strings.Contains(got, "• tasks\n• work ✅") {
+		t.Errorf("Expected the list to mark work active, got %q", got)
+	}
+
+	// Other chats keep the tasks database
+	other := testMessage("Buy milk", 0)
+	other.MessageID, other.Chat.ID = 200, 790
+	handler.storePendingTask(other)
+	reaction := thumbsUp()
+	reaction.MessageID, reaction.Chat.ID = 200, 790
+	if err := handler.HandleMessageReaction(context.Background(), reaction); err != nil {
+		t.Fatalf("HandleMessageReaction failed: %v", err)
+	}
+
+	if got := createdIn(fake); len(got) != 2 || got[0] != "work-db" || got[1] != "tasks-db" {
+		t.Errorf("Expected a page in work-db, then in tasks-db, got %v", got)
+	}
+}
@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultMiniAppURL is where the mini app is served without MINI_APP_URL
+	defaultMiniAppURL = "https://tralalero-tralala.ru/notion/mini-app"
+	// miniAppButtonText labels the buttons opening the mini app
+	miniAppButtonText = "Open Mini App"
+)
+
+// miniAppURL returns MINI_APP_URL, or the default deployment's URL
+func miniAppURL() string {
+	if url := os.Getenv("MINI_APP_URL"); url != "" {
+		return url
+	}
+	return defaultMiniAppURL
+}
+
+// webAppInfo is the Bot API's WebAppInfo, which the library doesn't have
+type webAppInfo struct {
+	URL string `json:"url"`
+}
+
+// webAppButton is an inline keyboard button opening a mini app, with the web_app field
+// the library's InlineKeyboardButton lacks
+type webAppButton struct {
+	Text   string     `json:"text"`
+	WebApp webAppInfo `json:"web_app"`
+}
+
+// webAppKeyboard is an inline keyboard of web_app buttons, sent as a message's reply_markup
+type webAppKeyboard struct {
+	InlineKeyboard [][]webAppButton `json:"inline_keyboard"`
+}
+
+// menuButtonWebApp is the Bot API's MenuButtonWebApp
+type menuButtonWebApp struct {
+	Type   string     `json:"type"`
+	Text   string     `json:"text"`
+	WebApp webAppInfo `json:"web_app"`
+}
+
+// newWebAppKeyboard builds a keyboard with a single button opening the mini app at url
+func newWebAppKeyboard(url string) webAppKeyboard {
+	return webAppKeyboard{InlineKeyboard: [][]webAppButton{{{Text: miniAppButtonText, WebApp: webAppInfo{URL: url}}}}}
+}
+
+// SetMenuButton makes the chat menu button open the mini app in every private chat with
+// the bot, so it's reachable from the attach menu
+func (h *Handler) SetMenuButton() error {
+	params := tgbotapi.Params{}
+	button := menuButtonWebApp{Type: "web_app", Text: miniAppButtonText, WebApp: webAppInfo{URL: miniAppURL()}}
+	if err := params.AddInterface("menu_button", button); err != nil {
+		return fmt.Errorf("failed to marshal menu button: %w", err)
+	}
+	if _, err := h.bot.MakeRequest("setChatMenuButton", params); err != nil {
+		return fmt.Errorf("failed to set the menu button: %w", err)
+	}
+	return nil
+}
+
+// handleMiniAppButton sends a button opening the mini app inside Telegram, which gives it
+// initData and the chat's theme. Telegram refuses web_app buttons for some URLs, such as
+// plain http ones, so a failure falls back to a button opening it as a web page.
+func (h *Handler) handleMiniAppButton(message *tgbotapi.Message) error {
+	url := miniAppURL()
+	msg := tgbotapi.NewMessage(message.Chat.ID, "Click the button below to open the mini app:")
+	msg.ReplyMarkup = newWebAppKeyboard(url)
+	_, err := h.bot.Send(msg)
+	if err == nil {
+		return nil
+	}
+	log.Printf("Warning: Could not send the web app button, sending a link instead: %v", err)
+
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonURL(miniAppButtonText, url),
+		),
+	)
+	_, err = h.bot.Send(msg)
+	return err
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// jsonParam decodes a JSON-encoded Bot API parameter
+func jsonParam(t *testing.T, value string) interface{} {
+	t.Helper()
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		t.Fatalf("Invalid JSON parameter %q: %v", value, err)
+	}
+	return decoded
+}
+
+func TestSetMenuButtonOpensTheMiniApp(t *testing.T) {
+	t.Setenv("MINI_APP_URL", "https://example.com/mini-app")
+	handler, telegram, _ := newRecoveryTestHandler(t)
+
+	if err := handler.SetMenuButton(); err != nil {
+		t.Fatalf("SetMenuButton failed: %v", err)
+	}
+
+	calls := telegram.callsTo("setChatMenuButton")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 setChatMenuButton call, got %d", len(calls))
+	}
+	want := jsonParam(t, `{"type": "web_app", "text": "Open Mini App", "web_app": {"url": "https://example.com/mini-app"}}`)
+	if got := jsonParam(t, calls[0].Params.Get("menu_button")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected menu button %v, got %v", want, got)
+	}
+}
+
+func TestMiniAppButtonIsAWebAppButton(t *testing.T) {
+	t.Setenv("MINI_APP_URL", "https://example.com/mini-app")
+	handler, telegram, _ := newRecoveryTestHandler(t)
+
+	if err := handler.HandleMessage(testMessage(miniAppButtonText, 0)); err != nil {
+		t.Fatalf("HandleMessage failed: %v", err)
+	}
+
+	sent := telegram.callsTo("sendMessage")
+	if len(sent) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(sent))
+	}
+	want := jsonParam(t, `{"inline_keyboard": [[{"text": "Open Mini App", "web_app": {"url": "https://example.com/mini-app"}}]]}`)
+	if got := jsonParam(t, sent[0].Params.Get("reply_markup")); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected reply markup %v, got %v", want, got)
+	}
+}
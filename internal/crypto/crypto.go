@@ -0,0 +1,132 @@
+// Package crypto encrypts values stored at rest with AES-256-GCM, such as the message
+// texts the local database keeps and the Notion tokens of connected workspaces
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	// KeySize is the length of a key once decoded, for AES-256
+	KeySize = 32
+	// textPrefix marks a text value encrypted by EncryptString, so plaintext written
+	// before encryption was turned on still reads
+	textPrefix = "enc:v1:"
+)
+
+// Cipher seals values with a random nonce prepended. A nil Cipher leaves text values in
+// plaintext, which is what the database does without DATA_ENCRYPTION_KEY.
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher creates a cipher with a 32 byte key
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+// KeyFromEnv decodes the base64 key in the environment variable name, nil when it's unset
+func KeyFromEnv(name string) ([]byte, error) {
+	encoded := strings.TrimSpace(os.Getenv(name))
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return key, nil
+}
+
+// FromEnv creates a cipher with the key in the environment variable name. Without one it
+// warns and returns nil, so values are stored in plaintext.
+func FromEnv(name string) (*Cipher, error) {
+	key, err := KeyFromEnv(name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		log.Printf("WARNING: %s is not set, sensitive values are stored in plaintext", name)
+		return nil, nil
+	}
+	c, err := NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return c, nil
+}
+
+// Seal encrypts plaintext, authenticating additionalData with it. The same additional
+// data must be passed to Open.
+func (c *Cipher) Seal(plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Open decrypts a value sealed by Seal
+func (c *Cipher) Open(sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("encrypted value is too short")
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt, was the key changed? %w", err)
+	}
+	return plaintext, nil
+}
+
+// EncryptString encrypts a text column's value into printable text. Empty values and a nil
+// Cipher leave it unchanged.
+func (c *Cipher) EncryptString(value string) (string, error) {
+	if c == nil || value == "" {
+		return value, nil
+	}
+	sealed, err := c.Seal([]byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	return textPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString reverses EncryptString. Values without its prefix were stored in plaintext
+// and are returned as they are.
+func (c *Cipher) DecryptString(value string) (string, error) {
+	if !strings.HasPrefix(value, textPrefix) {
+		return value, nil
+	}
+	if c == nil {
+		return "", errors.New("value is encrypted but no encryption key is set")
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, textPrefix))
+	if err != nil {
+		return "", fmt.Errorf("malformed encrypted value: %w", err)
+	}
+	plaintext, err := c.Open(sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	c, err := NewCipher(bytes.Repeat([]byte{7}, KeySize))
+	if err != nil {
+		t.Fatalf("NewCipher failed: %v", err)
+	}
+
+	sealed, err := c.EncryptString("Call the bank")
+	if err != nil {
+		t.Fatalf("EncryptString failed: %v", err)
+	}
+	if strings.Contains(sealed, "Call the bank") {
+		t.Errorf("Expected the text to be encrypted, got %q", sealed)
+	}
+	if again, _ := c.EncryptString("Call the bank"); again == sealed {
+		t.Errorf("Expected a new nonce for every value")
+	}
+	if opened, err := c.DecryptString(sealed); err != nil || opened != "Call the bank" {
+		t.Errorf("Expected the text back, got %q, %v", opened, err)
+	}
+
+	// Plaintext stored before encryption was turned on reads as it is
+	if opened, err := c.DecryptString("Buy milk"); err != nil || opened != "Buy milk" {
+		t.Errorf("Expected plaintext to pass through, got %q, %v", opened, err)
+	}
+
+	other, _ := NewCipher(bytes.Repeat([]byte{8}, KeySize))
+	if _, err := other.DecryptString(sealed); err == nil {
+		t.Errorf("Expected another key not to decrypt")
+	}
+	var none *Cipher
+	if _, err := none.DecryptString(sealed); err == nil {
+		t.Errorf("Expected an encrypted value not to read without a key")
+	}
+}
+
+func TestFromEnvWithoutKeyWarnsAndStoresPlaintext(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	t.Setenv("DATA_ENCRYPTION_KEY", "")
+	c, err := FromEnv("DATA_ENCRYPTION_KEY")
+	if err != nil || c != nil {
+		t.Fatalf("Expected no cipher and no error without a key, got %v, %v", c, err)
+	}
+	if !strings.Contains(logs.String(), "WARNING: DATA_ENCRYPTION_KEY is not set") {
+		t.Errorf("Expected a warning, got %q", logs.String())
+	}
+	if sealed, err := c.EncryptString("Buy milk"); err != nil || sealed != "Buy milk" {
+		t.Errorf("Expected plaintext without a key, got %q, %v", sealed, err)
+	}
+
+	t.Setenv("DATA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString([]byte("too short")))
+	if _, err := FromEnv("DATA_ENCRYPTION_KEY"); err == nil {
+		t.Errorf("Expected a key of the wrong size to be rejected")
+	}
+}
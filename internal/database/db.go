@@ -1,29 +1,176 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/numero_quadro/notion-mini-app/internal/crypto"
 )
 
+// OwnWorkspace is the workspace of the tasks created in the bot's own Notion workspace.
+// Tasks of users who connected theirs are recorded with the user's ID.
+const OwnWorkspace int64 = 0
+
 type TaskMetadata struct {
 	ID        int       `json:"id"`
+	Workspace int64     `json:"workspace_user_id"` // OwnWorkspace or the connected user
 	TaskID    string    `json:"task_id"`
 	TaskTitle string    `json:"task_title"`
 	LLMTag    string    `json:"llm_tag"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // When the tag last changed
+}
+
+// Digest message kinds
+const (
+	DigestMessageHeader = "header"
+	DigestMessageBody   = "body"
+	DigestMessageFooter = "footer"
+)
+
+// DigestMessage is a single Telegram message sent as part of a daily digest
+type DigestMessage struct {
+	MessageID int    `json:"message_id"`
+	Kind      string `json:"kind"`
+}
+
+// DigestRun records the messages sent by one scheduler run
+type DigestRun struct {
+	ID        int64           `json:"id"`
+	ChatID    int64           `json:"chat_id"`
+	Flagged   int             `json:"flagged"`
+	CreatedAt time.Time       `json:"created_at"`
+	Messages  []DigestMessage `json:"messages"`
+}
+
+// MissedDigest is a digest that couldn't be sent, kept as its rendered messages
+type MissedDigest struct {
+	ID        int64     `json:"id"`
+	ChatID    int64     `json:"chat_id"`
+	Messages  []string  `json:"messages"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxEntry is a Notion write that failed while Notion was unreachable, retried in the
+// background until it goes through or runs out of attempts
+type OutboxEntry struct {
+	ID          int64     `json:"id"`
+	Operation   string    `json:"operation"`
+	ChatID      int64     `json:"chat_id"`
+	MessageID   int       `json:"message_id,omitempty"` // The saved message, 0 for other writes
+	Payload     string    `json:"payload"`              // JSON of the operation, encrypted at rest
+	Attempts    int       `json:"attempts"`
+	NextRetryAt time.Time `json:"next_retry_at"`
+	LastError   string    `json:"last_error,omitempty"`
+	Parked      bool      `json:"parked"` // Gave up after the last attempt, kept for inspection
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Feedback modes for showing save progress in a chat
+const (
+	FeedbackModeReactions = "reactions"
+	FeedbackModeReplies   = "replies"
+)
+
+// Save attempt states
+const (
+	SaveStateInProgress = "in_progress"
+	SaveStateDone       = "done"
+	SaveStateFailed     = "failed"
+)
+
+// SaveAttempt records a task save so it can be reconciled if the bot dies mid-save
+type SaveAttempt struct {
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	UserID    int64     `json:"user_id"`
+	Text      string    `json:"text"`
+	State     string    `json:"state"`
+	PageID    string    `json:"page_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SavedMessage links a Telegram message to the Notion page created from it
+type SavedMessage struct {
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	UserID    int64     `json:"user_id"`
+	PageID    string    `json:"page_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PendingTask is a message waiting for a 👍 to be saved to Notion
+type PendingTask struct {
+	UserID     int64     `json:"user_id"`
+	ChatID     int64     `json:"chat_id"`
+	MessageID  int       `json:"message_id"`
+	Text       string    `json:"text"`
+	SourceChat string    `json:"source_chat,omitempty"`
+	SourceURL  string    `json:"source_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// The bot's reply with the transcription of a voice note or video, 0 for text messages
+	TranscriptMessageID int `json:"transcript_message_id,omitempty"`
+	// What the message was when it wasn't typed, like "voice note" or "forwarded message"
+	Origin string `json:"origin,omitempty"`
+	// The Telegram file of a photo message, empty for others
+	PhotoFileID string `json:"photo_file_id,omitempty"`
+}
+
+// SchemaChangeRecord is a persisted diff between two schemas of a Notion database
+type SchemaChangeRecord struct {
+	ID         int64           `json:"id"`
+	DatabaseID string          `json:"database_id"`
+	DbType     string          `json:"db_type"`
+	Changes    json.RawMessage `json:"changes"`
+	DetectedAt time.Time       `json:"detected_at"`
+}
+
+// Share is a public read-only link to the open tasks matching a tag or project
+type Share struct {
+	Slug      string    `json:"slug"`
+	Filter    string    `json:"filter"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// User is someone who connected their own Notion workspace. The token is stored encrypted,
+// the database never sees it in clear.
+type User struct {
+	TelegramUserID int64     `json:"telegram_user_id"`
+	NotionToken    []byte    `json:"-"`
+	TasksDbID      string    `json:"tasks_db_id"`
+	NotesDbID      string    `json:"notes_db_id,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
 }
 
 type DB struct {
 	conn *sql.DB
+	// cipher encrypts message texts and links (DATA_ENCRYPTION_KEY), nil stores them in plaintext
+	cipher *crypto.Cipher
 }
 
-// NewDB creates a new database connection and initializes the schema
+// busyTimeout is how long a write waits for another connection's lock before failing
+const busyTimeout = 5 * time.Second
+
+// NewDB creates a new database connection and migrates the schema. The database is opened
+// in write-ahead logging mode, so the scheduler and handlers can read while one writes.
+// Message texts are encrypted with DATA_ENCRYPTION_KEY when it's set.
 func NewDB(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath)
+	textCipher, err := crypto.FromEnv("DATA_ENCRYPTION_KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := sql.Open("sqlite3", dataSourceName(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -33,10 +180,11 @@ func NewDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db := &DB{conn: conn}
+	db := &DB{conn: conn, cipher: textCipher}
 
-	// Initialize schema
-	if err := db.initSchema(); err != nil {
+	// Bring the schema up to date
+	if err := db.migrate(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
@@ -44,36 +192,51 @@ func NewDB(dbPath string) (*DB, error) {
 	return db, nil
 }
 
-// initSchema creates the necessary tables if they don't exist
-func (db *DB) initSchema() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS task_metadata (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		task_id TEXT NOT NULL UNIQUE,
-		task_title TEXT NOT NULL,
-		llm_tag TEXT NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE INDEX IF NOT EXISTS idx_created_at ON task_metadata(created_at);
-	CREATE INDEX IF NOT EXISTS idx_llm_tag ON task_metadata(llm_tag);
-	`
+// dataSourceName adds the journal mode and busy timeout to a database path. They are set
+// through the DSN so every pooled connection gets them, not just the first.
+func dataSourceName(dbPath string) string {
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_journal_mode=WAL&_busy_timeout=%d", dbPath, separator, busyTimeout.Milliseconds())
+}
 
-	_, err := db.conn.Exec(query)
+// withTx runs fn in a transaction, committing when it succeeds and rolling back otherwise
+func (db *DB) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	return nil
+}
 
+// Check runs a trivial query, failing when the database file is gone or unreadable
+func (db *DB) Check(ctx context.Context) error {
+	var n int
+	if err := db.conn.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master").Scan(&n); err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
 	return nil
 }
 
-// StoreTaskMetadata stores task metadata in the database
-func (db *DB) StoreTaskMetadata(taskID, taskTitle, llmTag string) error {
+// StoreTaskMetadata stores task metadata in the database, for a task created in workspace
+func (db *DB) StoreTaskMetadata(workspace int64, taskID, taskTitle, llmTag string) error {
 	query := `
-		INSERT INTO task_metadata (task_id, task_title, llm_tag, created_at)
-		VALUES (?, ?, ?, ?)
+		INSERT INTO task_metadata (workspace_user_id, task_id, task_title, llm_tag, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := db.conn.Exec(query, taskID, taskTitle, llmTag, time.Now())
+	now := time.Now()
+	_, err := db.conn.Exec(query, workspace, taskID, taskTitle, llmTag, now, now)
 	if err != nil {
 		return fmt.Errorf("failed to store task metadata: %w", err)
 	}
@@ -82,10 +245,19 @@ func (db *DB) StoreTaskMetadata(taskID, taskTitle, llmTag string) error {
 	return nil
 }
 
+// UpdateTaskTag sets the LLM tag of a stored task, tasks that aren't stored are ignored
+func (db *DB) UpdateTaskTag(taskID, llmTag string) error {
+	query := `UPDATE task_metadata SET llm_tag = ?, updated_at = ? WHERE task_id = ?`
+	if _, err := db.conn.Exec(query, llmTag, time.Now(), taskID); err != nil {
+		return fmt.Errorf("failed to update task tag: %w", err)
+	}
+	return nil
+}
+
 // GetTasksSince retrieves all tasks created since the specified time
 func (db *DB) GetTasksSince(since time.Time) ([]TaskMetadata, error) {
 	query := `
-		SELECT id, task_id, task_title, llm_tag, created_at
+		SELECT id, workspace_user_id, task_id, task_title, llm_tag, created_at, updated_at
 		FROM task_metadata
 		WHERE created_at >= ?
 		ORDER BY created_at DESC
@@ -100,10 +272,15 @@ func (db *DB) GetTasksSince(since time.Time) ([]TaskMetadata, error) {
 	var tasks []TaskMetadata
 	for rows.Next() {
 		var task TaskMetadata
-		err := rows.Scan(&task.ID, &task.TaskID, &task.TaskTitle, &task.LLMTag, &task.CreatedAt)
+		var updatedAt sql.NullTime
+		err := rows.Scan(&task.ID, &task.Workspace, &task.TaskID, &task.TaskTitle, &task.LLMTag, &task.CreatedAt, &updatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan task: %w", err)
 		}
+		task.UpdatedAt = task.CreatedAt
+		if updatedAt.Valid {
+			task.UpdatedAt = updatedAt.Time
+		}
 		tasks = append(tasks, task)
 	}
 
@@ -114,6 +291,69 @@ func (db *DB) GetTasksSince(since time.Time) ([]TaskMetadata, error) {
 	return tasks, nil
 }
 
+// CountTasksSince returns how many tasks were created since the specified time
+func (db *DB) CountTasksSince(since time.Time) (int, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM task_metadata WHERE created_at >= ?`, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tasks: %w", err)
+	}
+	return count, nil
+}
+
+// CountByTag returns how many tasks were created since the specified time, by LLM tag
+func (db *DB) CountByTag(since time.Time) (map[string]int, error) {
+	query := `
+		SELECT llm_tag, COUNT(*)
+		FROM task_metadata
+		WHERE created_at >= ?
+		GROUP BY llm_tag
+	`
+
+	rows, err := db.conn.Query(query, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tasks by tag: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var tag string
+		var count int
+		if err := rows.Scan(&tag, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag count: %w", err)
+		}
+		counts[tag] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag counts: %w", err)
+	}
+	return counts, nil
+}
+
+// GetTaskIDs returns the IDs of every task stored for workspace, oldest first
+func (db *DB) GetTaskIDs(workspace int64) ([]string, error) {
+	rows, err := db.conn.Query(`SELECT task_id FROM task_metadata WHERE workspace_user_id = ? ORDER BY created_at`, workspace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query task IDs: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan task ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating task IDs: %w", err)
+	}
+	return ids, nil
+}
+
 // DeleteTask removes task metadata from the database
 func (db *DB) DeleteTask(taskID string) error {
 	query := `DELETE FROM task_metadata WHERE task_id = ?`
@@ -124,7 +364,1005 @@ func (db *DB) DeleteTask(taskID string) error {
 	return nil
 }
 
-// Close closes the database connection
-func (db *DB) Close() error {
-	return db.conn.Close()
+// StoreDigestRun records the messages sent by a digest run
+func (db *DB) StoreDigestRun(chatID int64, flagged int, messages []DigestMessage, createdAt time.Time) (int64, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO digest_runs (chat_id, flagged, created_at) VALUES (?, ?, ?)`, chatID, flagged, createdAt.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("failed to store digest run: %w", err)
+	}
+
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get digest run ID: %w", err)
+	}
+
+	for _, msg := range messages {
+		_, err := tx.Exec(`INSERT INTO digest_messages (run_id, message_id, kind) VALUES (?, ?, ?)`, runID, msg.MessageID, msg.Kind)
+		if err != nil {
+			return 0, fmt.Errorf("failed to store digest message: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit digest run: %w", err)
+	}
+
+	return runID, nil
+}
+
+// GetUncollapsedDigestRuns retrieves digest runs for a chat created before the specified time
+// that haven't been collapsed yet, oldest first
+func (db *DB) GetUncollapsedDigestRuns(chatID int64, before time.Time) ([]DigestRun, error) {
+	query := `
+		SELECT r.id, r.chat_id, r.flagged, r.created_at, m.message_id, m.kind
+		FROM digest_runs r
+		LEFT JOIN digest_messages m ON m.run_id = r.id
+		WHERE r.chat_id = ? AND r.collapsed = 0 AND r.created_at < ?
+		ORDER BY r.created_at ASC, r.id ASC
+	`
+
+	rows, err := db.conn.Query(query, chatID, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []DigestRun
+	for rows.Next() {
+		var run DigestRun
+		var messageID sql.NullInt64
+		var kind sql.NullString
+		if err := rows.Scan(&run.ID, &run.ChatID, &run.Flagged, &run.CreatedAt, &messageID, &kind); err != nil {
+			return nil, fmt.Errorf("failed to scan digest run: %w", err)
+		}
+
+		if len(runs) == 0 || runs[len(runs)-1].ID != run.ID {
+			runs = append(runs, run)
+		}
+		if messageID.Valid {
+			last := &runs[len(runs)-1]
+			last.Messages = append(last.Messages, DigestMessage{MessageID: int(messageID.Int64), Kind: kind.String})
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating digest runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// MarkDigestRunCollapsed marks a digest run as collapsed so it isn't processed again
+func (db *DB) MarkDigestRunCollapsed(runID int64) error {
+	_, err := db.conn.Exec(`UPDATE digest_runs SET collapsed = 1 WHERE id = ?`, runID)
+	if err != nil {
+		return fmt.Errorf("failed to mark digest run collapsed: %w", err)
+	}
+	return nil
+}
+
+// StoreMissedDigest keeps the messages of a digest that couldn't be sent
+func (db *DB) StoreMissedDigest(digest MissedDigest) error {
+	messages, err := db.sealDigestMessages(digest.Messages)
+	if err != nil {
+		return err
+	}
+	_, err = db.conn.Exec(`INSERT INTO missed_digests (chat_id, messages, created_at) VALUES (?, ?, ?)`, digest.ChatID, messages, digest.CreatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to store missed digest: %w", err)
+	}
+	return nil
+}
+
+// GetMissedDigests retrieves the digests of a chat that couldn't be sent, oldest first
+func (db *DB) GetMissedDigests(chatID int64) ([]MissedDigest, error) {
+	query := `
+		SELECT id, chat_id, messages, created_at
+		FROM missed_digests
+		WHERE chat_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query missed digests: %w", err)
+	}
+	defer rows.Close()
+
+	var digests []MissedDigest
+	for rows.Next() {
+		var digest MissedDigest
+		var messages string
+		if err := rows.Scan(&digest.ID, &digest.ChatID, &messages, &digest.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan missed digest: %w", err)
+		}
+		plain, err := db.cipher.DecryptString(messages)
+		if err == nil {
+			err = json.Unmarshal([]byte(plain), &digest.Messages)
+		}
+		if err != nil {
+			log.Printf("Warning: Skipping missed digest %d: %v", digest.ID, err)
+			continue
+		}
+		digests = append(digests, digest)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating missed digests: %w", err)
+	}
+
+	return digests, nil
+}
+
+// SetMissedDigestMessages replaces the messages of a missed digest still to be sent
+func (db *DB) SetMissedDigestMessages(id int64, messages []string) error {
+	sealed, err := db.sealDigestMessages(messages)
+	if err != nil {
+		return err
+	}
+	if _, err := db.conn.Exec(`UPDATE missed_digests SET messages = ? WHERE id = ?`, sealed, id); err != nil {
+		return fmt.Errorf("failed to update missed digest: %w", err)
+	}
+	return nil
+}
+
+// DeleteMissedDigest removes a missed digest once it was sent
+func (db *DB) DeleteMissedDigest(id int64) error {
+	if _, err := db.conn.Exec(`DELETE FROM missed_digests WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete missed digest: %w", err)
+	}
+	return nil
+}
+
+// sealDigestMessages encodes the messages of a missed digest, encrypted like other message
+// texts since they list task titles
+func (db *DB) sealDigestMessages(messages []string) (string, error) {
+	encoded, err := json.Marshal(messages)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode missed digest: %w", err)
+	}
+	sealed, err := db.cipher.EncryptString(string(encoded))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt missed digest: %w", err)
+	}
+	return sealed, nil
+}
+
+// EnqueueOutbox stores a failed Notion write, replacing the waiting one of the same message
+func (db *DB) EnqueueOutbox(entry OutboxEntry) (int64, error) {
+	payload, err := db.cipher.EncryptString(entry.Payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt outbox entry: %w", err)
+	}
+
+	var id int64
+	err = db.withTx(func(tx *sql.Tx) error {
+		if entry.MessageID != 0 {
+			_, err := tx.Exec(`DELETE FROM outbox WHERE chat_id = ? AND message_id = ? AND operation = ? AND parked = 0`, entry.ChatID, entry.MessageID, entry.Operation)
+			if err != nil {
+				return err
+			}
+		}
+		result, err := tx.Exec(`
+			INSERT INTO outbox (operation, chat_id, message_id, payload, attempts, next_retry_at, last_error, parked, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?)`,
+			entry.Operation, entry.ChatID, entry.MessageID, payload, entry.Attempts, entry.NextRetryAt.UTC(), entry.LastError, entry.CreatedAt.UTC())
+		if err != nil {
+			return err
+		}
+		id, err = result.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to store outbox entry: %w", err)
+	}
+	return id, nil
+}
+
+// GetDueOutboxEntries retrieves the waiting outbox entries due by now, oldest first
+func (db *DB) GetDueOutboxEntries(now time.Time) ([]OutboxEntry, error) {
+	return db.queryOutbox(`WHERE parked = 0 AND next_retry_at <= ?`, now.UTC())
+}
+
+// GetParkedOutboxEntries retrieves the outbox entries that ran out of attempts, oldest first
+func (db *DB) GetParkedOutboxEntries() ([]OutboxEntry, error) {
+	return db.queryOutbox(`WHERE parked = 1`)
+}
+
+// CountOutbox returns how many outbox entries are waiting for a retry and how many were
+// parked
+func (db *DB) CountOutbox() (queued, parked int, err error) {
+	err = db.conn.QueryRow(`SELECT COUNT(*) - COALESCE(SUM(parked), 0), COALESCE(SUM(parked), 0) FROM outbox`).Scan(&queued, &parked)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count outbox entries: %w", err)
+	}
+	return queued, parked, nil
+}
+
+func (db *DB) queryOutbox(where string, args ...interface{}) ([]OutboxEntry, error) {
+	query := `
+		SELECT id, operation, chat_id, message_id, payload, attempts, next_retry_at, last_error, parked, created_at
+		FROM outbox
+		` + where + `
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var e OutboxEntry
+		if err := rows.Scan(&e.ID, &e.Operation, &e.ChatID, &e.MessageID, &e.Payload, &e.Attempts, &e.NextRetryAt, &e.LastError, &e.Parked, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %w", err)
+		}
+		payload, err := db.cipher.DecryptString(e.Payload)
+		if err != nil {
+			log.Printf("Warning: Skipping outbox entry %d: %v", e.ID, err)
+			continue
+		}
+		e.Payload = payload
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating outbox: %w", err)
+	}
+
+	return entries, nil
+}
+
+// RescheduleOutboxEntry records a failed retry and when to try again
+func (db *DB) RescheduleOutboxEntry(id int64, attempts int, nextRetryAt time.Time, lastError string) error {
+	query := `UPDATE outbox SET attempts = ?, next_retry_at = ?, last_error = ? WHERE id = ?`
+
+	if _, err := db.conn.Exec(query, attempts, nextRetryAt.UTC(), lastError, id); err != nil {
+		return fmt.Errorf("failed to reschedule outbox entry: %w", err)
+	}
+	return nil
+}
+
+// ParkOutboxEntry stops retrying an outbox entry after its last failed attempt
+func (db *DB) ParkOutboxEntry(id int64, attempts int, lastError string) error {
+	query := `UPDATE outbox SET attempts = ?, last_error = ?, parked = 1 WHERE id = ?`
+
+	if _, err := db.conn.Exec(query, attempts, lastError, id); err != nil {
+		return fmt.Errorf("failed to park outbox entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteOutboxEntry removes an outbox entry once its write went through
+func (db *DB) DeleteOutboxEntry(id int64) error {
+	if _, err := db.conn.Exec(`DELETE FROM outbox WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete outbox entry: %w", err)
+	}
+	return nil
+}
+
+// DeleteOutboxMessage removes the outbox entries of a message that was saved another way
+func (db *DB) DeleteOutboxMessage(chatID int64, messageID int) error {
+	if _, err := db.conn.Exec(`DELETE FROM outbox WHERE chat_id = ? AND message_id = ?`, chatID, messageID); err != nil {
+		return fmt.Errorf("failed to delete outbox entries: %w", err)
+	}
+	return nil
+}
+
+// StartSaveAttempt records that a save is about to call Notion
+func (db *DB) StartSaveAttempt(attempt SaveAttempt) error {
+	query := `
+		INSERT INTO save_attempts (chat_id, message_id, user_id, text, state, page_id, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, '', ?, ?)
+		ON CONFLICT(chat_id, message_id) DO UPDATE SET
+			user_id = excluded.user_id, text = excluded.text, state = excluded.state,
+			page_id = '', started_at = excluded.started_at, updated_at = excluded.updated_at
+	`
+
+	text, err := db.cipher.EncryptString(attempt.Text)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt save attempt: %w", err)
+	}
+	startedAt := attempt.StartedAt.UTC()
+	_, err = db.conn.Exec(query, attempt.ChatID, attempt.MessageID, attempt.UserID, text, SaveStateInProgress, startedAt, startedAt)
+	if err != nil {
+		return fmt.Errorf("failed to store save attempt: %w", err)
+	}
+	return nil
+}
+
+// FinishSaveAttempt records the outcome of a save
+func (db *DB) FinishSaveAttempt(chatID int64, messageID int, state, pageID string) error {
+	query := `UPDATE save_attempts SET state = ?, page_id = ?, updated_at = ? WHERE chat_id = ? AND message_id = ?`
+
+	if _, err := db.conn.Exec(query, state, pageID, time.Now().UTC(), chatID, messageID); err != nil {
+		return fmt.Errorf("failed to update save attempt: %w", err)
+	}
+	return nil
+}
+
+// GetSaveAttemptsInState retrieves save attempts in the given state, oldest first
+func (db *DB) GetSaveAttemptsInState(state string) ([]SaveAttempt, error) {
+	query := `
+		SELECT chat_id, message_id, user_id, text, state, page_id, started_at, updated_at
+		FROM save_attempts
+		WHERE state = ?
+		ORDER BY started_at ASC
+	`
+
+	rows, err := db.conn.Query(query, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query save attempts: %w", err)
+	}
+	defer rows.Close()
+
+	var attempts []SaveAttempt
+	for rows.Next() {
+		var a SaveAttempt
+		if err := rows.Scan(&a.ChatID, &a.MessageID, &a.UserID, &a.Text, &a.State, &a.PageID, &a.StartedAt, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan save attempt: %w", err)
+		}
+		text, err := db.cipher.DecryptString(a.Text)
+		if err != nil {
+			log.Printf("Warning: Skipping the save attempt of message %d: %v", a.MessageID, err)
+			continue
+		}
+		a.Text = text
+		attempts = append(attempts, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating save attempts: %w", err)
+	}
+
+	return attempts, nil
+}
+
+// DeleteFinishedSaveAttempts removes completed and failed save attempts last updated before the specified time
+func (db *DB) DeleteFinishedSaveAttempts(before time.Time) error {
+	query := `DELETE FROM save_attempts WHERE state != ? AND updated_at < ?`
+
+	if _, err := db.conn.Exec(query, SaveStateInProgress, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete save attempts: %w", err)
+	}
+	return nil
+}
+
+// StorePendingTask stores a pending task, replacing one for the same message
+func (db *DB) StorePendingTask(task PendingTask) error {
+	query := `
+		INSERT INTO pending_tasks (user_id, chat_id, message_id, text, source_chat, source_url, created_at, transcript_message_id, origin, photo_file_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, message_id) DO UPDATE SET
+			user_id = excluded.user_id, text = excluded.text, source_chat = excluded.source_chat,
+			source_url = excluded.source_url, created_at = excluded.created_at,
+			transcript_message_id = excluded.transcript_message_id, origin = excluded.origin,
+			photo_file_id = excluded.photo_file_id
+	`
+
+	text, err := db.cipher.EncryptString(task.Text)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt pending task: %w", err)
+	}
+	sourceURL, err := db.cipher.EncryptString(task.SourceURL)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt pending task: %w", err)
+	}
+	_, err = db.conn.Exec(query, task.UserID, task.ChatID, task.MessageID, text, task.SourceChat, sourceURL, task.CreatedAt.UTC(), task.TranscriptMessageID, task.Origin, task.PhotoFileID)
+	if err != nil {
+		return fmt.Errorf("failed to store pending task: %w", err)
+	}
+	return nil
+}
+
+// UpdatePendingTaskText replaces the text of a pending task after its message was edited
+func (db *DB) UpdatePendingTaskText(chatID int64, messageID int, text string) error {
+	query := `UPDATE pending_tasks SET text = ? WHERE chat_id = ? AND message_id = ?`
+
+	text, err := db.cipher.EncryptString(text)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt pending task: %w", err)
+	}
+	if _, err := db.conn.Exec(query, text, chatID, messageID); err != nil {
+		return fmt.Errorf("failed to update pending task: %w", err)
+	}
+	return nil
+}
+
+// SetPendingTaskTranscript records the bot's transcription reply of a pending task
+func (db *DB) SetPendingTaskTranscript(chatID int64, messageID, transcriptMessageID int) error {
+	query := `UPDATE pending_tasks SET transcript_message_id = ? WHERE chat_id = ? AND message_id = ?`
+
+	if _, err := db.conn.Exec(query, transcriptMessageID, chatID, messageID); err != nil {
+		return fmt.Errorf("failed to update pending task: %w", err)
+	}
+	return nil
+}
+
+// GetPendingTask returns the pending task of a message, or nil if there is none
+func (db *DB) GetPendingTask(chatID int64, messageID int) (*PendingTask, error) {
+	query := `
+		SELECT user_id, chat_id, message_id, text, source_chat, source_url, created_at, transcript_message_id, origin, photo_file_id
+		FROM pending_tasks
+		WHERE chat_id = ? AND message_id = ?
+	`
+
+	var task PendingTask
+	err := db.conn.QueryRow(query, chatID, messageID).Scan(&task.UserID, &task.ChatID, &task.MessageID, &task.Text, &task.SourceChat, &task.SourceURL, &task.CreatedAt, &task.TranscriptMessageID, &task.Origin, &task.PhotoFileID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending task: %w", err)
+	}
+	if err := db.decryptPendingTask(&task); err != nil {
+		return nil, fmt.Errorf("failed to get pending task: %w", err)
+	}
+	return &task, nil
+}
+
+// GetPendingTasks retrieves all pending tasks, oldest first
+func (db *DB) GetPendingTasks() ([]PendingTask, error) {
+	query := `
+		SELECT user_id, chat_id, message_id, text, source_chat, source_url, created_at, transcript_message_id, origin, photo_file_id
+		FROM pending_tasks
+		ORDER BY created_at ASC
+	`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []PendingTask
+	for rows.Next() {
+		var task PendingTask
+		if err := rows.Scan(&task.UserID, &task.ChatID, &task.MessageID, &task.Text, &task.SourceChat, &task.SourceURL, &task.CreatedAt, &task.TranscriptMessageID, &task.Origin, &task.PhotoFileID); err != nil {
+			return nil, fmt.Errorf("failed to scan pending task: %w", err)
+		}
+		if err := db.decryptPendingTask(&task); err != nil {
+			log.Printf("Warning: Skipping the pending task of message %d: %v", task.MessageID, err)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending tasks: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// decryptPendingTask decrypts the text and link of a pending task read from the database
+func (db *DB) decryptPendingTask(task *PendingTask) error {
+	text, err := db.cipher.DecryptString(task.Text)
+	if err != nil {
+		return err
+	}
+	sourceURL, err := db.cipher.DecryptString(task.SourceURL)
+	if err != nil {
+		return err
+	}
+	task.Text, task.SourceURL = text, sourceURL
+	return nil
+}
+
+// DeletePendingTask removes the pending task of a message
+func (db *DB) DeletePendingTask(chatID int64, messageID int) error {
+	query := `DELETE FROM pending_tasks WHERE chat_id = ? AND message_id = ?`
+
+	if _, err := db.conn.Exec(query, chatID, messageID); err != nil {
+		return fmt.Errorf("failed to delete pending task: %w", err)
+	}
+	return nil
+}
+
+// DeletePendingTasksBefore removes pending tasks created before the specified time
+func (db *DB) DeletePendingTasksBefore(before time.Time) error {
+	if _, err := db.conn.Exec(`DELETE FROM pending_tasks WHERE created_at < ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete old pending tasks: %w", err)
+	}
+	return nil
+}
+
+// GetSchemaSnapshot returns the last stored schema snapshot of a database, or an empty string
+func (db *DB) GetSchemaSnapshot(dbID string) (string, error) {
+	var snapshot string
+	err := db.conn.QueryRow(`SELECT snapshot FROM schema_snapshots WHERE db_id = ?`, dbID).Scan(&snapshot)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get schema snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// execer runs statements on the database or inside a transaction
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// SaveSchemaSnapshot stores the latest schema snapshot of a database
+func (db *DB) SaveSchemaSnapshot(dbID, snapshot string) error {
+	return saveSchemaSnapshot(db.conn, dbID, snapshot)
+}
+
+func saveSchemaSnapshot(e execer, dbID, snapshot string) error {
+	query := `
+		INSERT INTO schema_snapshots (db_id, snapshot, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(db_id) DO UPDATE SET snapshot = excluded.snapshot, updated_at = excluded.updated_at
+	`
+
+	if _, err := e.Exec(query, dbID, snapshot, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to save schema snapshot: %w", err)
+	}
+	return nil
+}
+
+// StoreSchemaChanges records a schema diff given as JSON
+func (db *DB) StoreSchemaChanges(dbID, dbType string, changes []byte, detectedAt time.Time) error {
+	return storeSchemaChanges(db.conn, dbID, dbType, changes, detectedAt)
+}
+
+func storeSchemaChanges(e execer, dbID, dbType string, changes []byte, detectedAt time.Time) error {
+	query := `INSERT INTO schema_changes (db_id, db_type, changes, detected_at) VALUES (?, ?, ?, ?)`
+
+	if _, err := e.Exec(query, dbID, dbType, string(changes), detectedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to store schema changes: %w", err)
+	}
+	return nil
+}
+
+// StoreSchemaChangesWithSnapshot records a schema diff together with the snapshot it leads
+// to, so the same diff isn't recorded again when saving the snapshot fails
+func (db *DB) StoreSchemaChangesWithSnapshot(dbID, dbType string, changes []byte, snapshot string, detectedAt time.Time) error {
+	return db.withTx(func(tx *sql.Tx) error {
+		if err := storeSchemaChanges(tx, dbID, dbType, changes, detectedAt); err != nil {
+			return err
+		}
+		return saveSchemaSnapshot(tx, dbID, snapshot)
+	})
+}
+
+// GetRecentSchemaChanges retrieves the latest schema diffs, newest first
+func (db *DB) GetRecentSchemaChanges(limit int) ([]SchemaChangeRecord, error) {
+	query := `
+		SELECT id, db_id, db_type, changes, detected_at
+		FROM schema_changes
+		ORDER BY detected_at DESC, id DESC
+		LIMIT ?
+	`
+
+	rows, err := db.conn.Query(query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schema changes: %w", err)
+	}
+	defer rows.Close()
+
+	records := []SchemaChangeRecord{}
+	for rows.Next() {
+		var record SchemaChangeRecord
+		var changes string
+		if err := rows.Scan(&record.ID, &record.DatabaseID, &record.DbType, &changes, &record.DetectedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan schema change: %w", err)
+		}
+		record.Changes = json.RawMessage(changes)
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating schema changes: %w", err)
+	}
+
+	return records, nil
+}
+
+// GetChatFeedbackMode returns the stored feedback mode of a chat and when reactions were
+// last probed. An empty mode means nothing is stored.
+func (db *DB) GetChatFeedbackMode(chatID int64) (string, time.Time, error) {
+	var mode string
+	var probedAt time.Time
+
+	err := db.conn.QueryRow(`SELECT mode, probed_at FROM chat_feedback_modes WHERE chat_id = ?`, chatID).Scan(&mode, &probedAt)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get feedback mode: %w", err)
+	}
+	return mode, probedAt, nil
+}
+
+// SetChatFeedbackMode stores the feedback mode of a chat
+func (db *DB) SetChatFeedbackMode(chatID int64, mode string, probedAt time.Time) error {
+	query := `
+		INSERT INTO chat_feedback_modes (chat_id, mode, probed_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET mode = excluded.mode, probed_at = excluded.probed_at
+	`
+
+	if _, err := db.conn.Exec(query, chatID, mode, probedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to set feedback mode: %w", err)
+	}
+	return nil
+}
+
+// SetPausedUntil pauses the scheduler's notifications for a user until the given time
+func (db *DB) SetPausedUntil(userID int64, until time.Time) error {
+	query := `
+		INSERT INTO pauses (user_id, paused_until)
+		VALUES (?, ?)
+		ON CONFLICT(user_id) DO UPDATE SET paused_until = excluded.paused_until
+	`
+	if _, err := db.conn.Exec(query, userID, until.UTC()); err != nil {
+		return fmt.Errorf("failed to store pause: %w", err)
+	}
+	return nil
+}
+
+// GetPausedUntil returns until when a user paused notifications, zero if they never did.
+// Pauses that ended are returned too, callers compare with the current time.
+func (db *DB) GetPausedUntil(userID int64) (time.Time, error) {
+	var until time.Time
+	err := db.conn.QueryRow(`SELECT paused_until FROM pauses WHERE user_id = ?`, userID).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get pause: %w", err)
+	}
+	return until, nil
+}
+
+// ClearPause resumes a user's notifications, reporting whether a pause was stored
+func (db *DB) ClearPause(userID int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM pauses WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to clear pause: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to clear pause: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Close closes the database connection
+func (db *DB) Close() error {
+	return db.conn.Close()
+}
+
+// AddDailyUsage adds metric counts to the totals of a day (formatted as 2006-01-02)
+func (db *DB) AddDailyUsage(day string, values map[string]int64) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO daily_usage (day, metric, value)
+		VALUES (?, ?, ?)
+		ON CONFLICT(day, metric) DO UPDATE SET value = value + excluded.value
+	`
+	for metric, value := range values {
+		if _, err := tx.Exec(query, day, metric, value); err != nil {
+			return fmt.Errorf("failed to store daily usage: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit daily usage: %w", err)
+	}
+	return nil
+}
+
+// GetUsageTotals sums the daily usage of each metric between two days, inclusive
+func (db *DB) GetUsageTotals(fromDay, toDay string) (map[string]int64, error) {
+	query := `
+		SELECT metric, SUM(value)
+		FROM daily_usage
+		WHERE day >= ? AND day <= ?
+		GROUP BY metric
+	`
+
+	rows, err := db.conn.Query(query, fromDay, toDay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query usage totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int64)
+	for rows.Next() {
+		var metric string
+		var value int64
+		if err := rows.Scan(&metric, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan usage total: %w", err)
+		}
+		totals[metric] = value
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating usage totals: %w", err)
+	}
+	return totals, nil
+}
+
+// CreateShare stores a share link. It fails if the slug is already taken.
+func (db *DB) CreateShare(share Share) error {
+	query := `INSERT INTO shares (slug, filter, created_at, expires_at) VALUES (?, ?, ?, ?)`
+
+	if _, err := db.conn.Exec(query, share.Slug, share.Filter, share.CreatedAt.UTC(), share.ExpiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to create share: %w", err)
+	}
+	return nil
+}
+
+// GetShare returns the share with the given slug, or nil if there is none
+func (db *DB) GetShare(slug string) (*Share, error) {
+	query := `SELECT slug, filter, created_at, expires_at FROM shares WHERE slug = ?`
+
+	var share Share
+	err := db.conn.QueryRow(query, slug).Scan(&share.Slug, &share.Filter, &share.CreatedAt, &share.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share: %w", err)
+	}
+	return &share, nil
+}
+
+// DeleteShare removes a share and reports whether it existed
+func (db *DB) DeleteShare(slug string) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM shares WHERE slug = ?`, slug)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete share: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete share: %w", err)
+	}
+	return deleted > 0, nil
+}
+
+// DeleteExpiredShares removes shares that expired before now
+func (db *DB) DeleteExpiredShares(now time.Time) error {
+	if _, err := db.conn.Exec(`DELETE FROM shares WHERE expires_at < ?`, now.UTC()); err != nil {
+		return fmt.Errorf("failed to delete expired shares: %w", err)
+	}
+	return nil
+}
+
+// ReminderSent reports whether the reminder for a task due at due (its Date as stored in
+// Notion) was already sent
+func (db *DB) ReminderSent(taskID, due string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM reminders WHERE task_id = ? AND due = ?`, taskID, due).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check reminder: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecordReminder records that the reminder for a task due at due was sent
+func (db *DB) RecordReminder(taskID, due string, sentAt time.Time) error {
+	query := `INSERT OR IGNORE INTO reminders (task_id, due, sent_at) VALUES (?, ?, ?)`
+
+	if _, err := db.conn.Exec(query, taskID, due, sentAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record reminder: %w", err)
+	}
+	return nil
+}
+
+// DeleteRemindersBefore removes reminders sent before the specified time
+func (db *DB) DeleteRemindersBefore(before time.Time) error {
+	if _, err := db.conn.Exec(`DELETE FROM reminders WHERE sent_at < ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete old reminders: %w", err)
+	}
+	return nil
+}
+
+// RecurrenceSpawned reports whether the next occurrence of a repeating task due at due
+// (its Date as stored in Notion, empty without one) was already created
+func (db *DB) RecurrenceSpawned(sourceTaskID, due string) (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM recurrences WHERE source_task_id = ? AND due = ?`, sourceTaskID, due).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check recurrence: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecordRecurrence records the task created as the next occurrence of a repeating task
+func (db *DB) RecordRecurrence(sourceTaskID, due, spawnedTaskID string, createdAt time.Time) error {
+	query := `INSERT OR IGNORE INTO recurrences (source_task_id, due, spawned_task_id, created_at) VALUES (?, ?, ?, ?)`
+
+	if _, err := db.conn.Exec(query, sourceTaskID, due, spawnedTaskID, createdAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record recurrence: %w", err)
+	}
+	return nil
+}
+
+// JournalCopy returns the journal entry copied from a task that wasn't archived yet, empty
+// when there is none
+func (db *DB) JournalCopy(taskID string) (string, error) {
+	var journalID string
+	err := db.conn.QueryRow(`SELECT journal_id FROM journal_copies WHERE task_id = ?`, taskID).Scan(&journalID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get journal copy: %w", err)
+	}
+	return journalID, nil
+}
+
+// RecordJournalCopy records the journal entry copied from a task that couldn't be archived
+func (db *DB) RecordJournalCopy(taskID, journalID string, createdAt time.Time) error {
+	query := `INSERT OR REPLACE INTO journal_copies (task_id, journal_id, created_at) VALUES (?, ?, ?)`
+
+	if _, err := db.conn.Exec(query, taskID, journalID, createdAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record journal copy: %w", err)
+	}
+	return nil
+}
+
+// DeleteJournalCopy removes the journal copy of a task once the task was archived
+func (db *DB) DeleteJournalCopy(taskID string) error {
+	if _, err := db.conn.Exec(`DELETE FROM journal_copies WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("failed to delete journal copy: %w", err)
+	}
+	return nil
+}
+
+// RecordSavedMessage records the page created from a message
+func (db *DB) RecordSavedMessage(saved SavedMessage) error {
+	query := `
+		INSERT INTO saved_messages (chat_id, message_id, user_id, page_id, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(chat_id, message_id) DO UPDATE SET
+			user_id = excluded.user_id, page_id = excluded.page_id, created_at = excluded.created_at
+	`
+
+	if _, err := db.conn.Exec(query, saved.ChatID, saved.MessageID, saved.UserID, saved.PageID, saved.CreatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record saved message: %w", err)
+	}
+	return nil
+}
+
+// GetSavedMessage returns the page created from a message, or nil if there is none
+func (db *DB) GetSavedMessage(chatID int64, messageID int) (*SavedMessage, error) {
+	query := `SELECT chat_id, message_id, user_id, page_id, created_at FROM saved_messages WHERE chat_id = ? AND message_id = ?`
+
+	var saved SavedMessage
+	err := db.conn.QueryRow(query, chatID, messageID).Scan(&saved.ChatID, &saved.MessageID, &saved.UserID, &saved.PageID, &saved.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved message: %w", err)
+	}
+	return &saved, nil
+}
+
+// DeleteSavedMessagesBefore removes saved messages recorded before the specified time
+func (db *DB) DeleteSavedMessagesBefore(before time.Time) error {
+	if _, err := db.conn.Exec(`DELETE FROM saved_messages WHERE created_at < ?`, before.UTC()); err != nil {
+		return fmt.Errorf("failed to delete saved messages: %w", err)
+	}
+	return nil
+}
+
+// SetChatDatabase stores the task database a chat picked with /use
+func (db *DB) SetChatDatabase(chatID int64, name string, updatedAt time.Time) error {
+	query := `
+		INSERT INTO chat_databases (chat_id, name, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET name = excluded.name, updated_at = excluded.updated_at
+	`
+
+	if _, err := db.conn.Exec(query, chatID, name, updatedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to store chat database: %w", err)
+	}
+	return nil
+}
+
+// GetChatDatabase returns the task database a chat picked, empty if it picked none
+func (db *DB) GetChatDatabase(chatID int64) (string, error) {
+	var name string
+	err := db.conn.QueryRow(`SELECT name FROM chat_databases WHERE chat_id = ?`, chatID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get chat database: %w", err)
+	}
+	return name, nil
+}
+
+// SaveUser stores a user's credentials, replacing the ones they connected before
+func (db *DB) SaveUser(user User) error {
+	query := `
+	INSERT INTO users (telegram_user_id, notion_token, tasks_db_id, notes_db_id, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	ON CONFLICT(telegram_user_id) DO UPDATE SET
+		notion_token = excluded.notion_token,
+		tasks_db_id = excluded.tasks_db_id,
+		notes_db_id = excluded.notes_db_id,
+		updated_at = excluded.updated_at`
+
+	_, err := db.conn.Exec(query, user.TelegramUserID, user.NotionToken, user.TasksDbID, user.NotesDbID,
+		user.CreatedAt.UTC(), user.UpdatedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to save user: %w", err)
+	}
+	return nil
+}
+
+// GetUser returns the credentials of a user, or nil if they haven't connected a workspace
+func (db *DB) GetUser(telegramUserID int64) (*User, error) {
+	query := `SELECT telegram_user_id, notion_token, tasks_db_id, notes_db_id, created_at, updated_at FROM users WHERE telegram_user_id = ?`
+
+	var user User
+	err := db.conn.QueryRow(query, telegramUserID).Scan(&user.TelegramUserID, &user.NotionToken,
+		&user.TasksDbID, &user.NotesDbID, &user.CreatedAt, &user.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUsers returns every user who connected a workspace
+func (db *DB) GetUsers() ([]User, error) {
+	query := `SELECT telegram_user_id, notion_token, tasks_db_id, notes_db_id, created_at, updated_at FROM users ORDER BY telegram_user_id`
+
+	rows, err := db.conn.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var user User
+		if err := rows.Scan(&user.TelegramUserID, &user.NotionToken, &user.TasksDbID, &user.NotesDbID, &user.CreatedAt, &user.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+	return users, nil
+}
+
+// DeleteUser removes a user's credentials and reports whether they had any
+func (db *DB) DeleteUser(telegramUserID int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM users WHERE telegram_user_id = ?`, telegramUserID)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete user: %w", err)
+	}
+	return deleted > 0, nil
 }
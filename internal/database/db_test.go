@@ -0,0 +1,374 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPendingTasksSurviveReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+
+	task := PendingTask{
+		UserID:     456,
+		ChatID:     789,
+		MessageID:  123,
+		Text:       "Buy milk",
+		SourceChat: "Private chat [789]",
+		SourceURL:  "https://t.me/golangweekly/42",
+		CreatedAt:  time.Now().Truncate(time.Second),
+	}
+	if err := db.StorePendingTask(task); err != nil {
+		t.Fatalf("StorePendingTask failed: %v", err)
+	}
+	db.Close()
+
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	tasks, err := db.GetPendingTasks()
+	if err != nil {
+		t.Fatalf("GetPendingTasks failed: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 pending task, got %d", len(tasks))
+	}
+	got := tasks[0]
+	if got.UserID != task.UserID || got.ChatID != task.ChatID || got.MessageID != task.MessageID ||
+		got.Text != task.Text || got.SourceChat != task.SourceChat || got.SourceURL != task.SourceURL ||
+		!got.CreatedAt.Equal(task.CreatedAt) {
+		t.Errorf("Expected %+v, got %+v", task, got)
+	}
+}
+
+func TestPendingTasksTableGainsSourceURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	// The table as it was before source_url
+	_, err = conn.Exec(`CREATE TABLE pending_tasks (
+		user_id INTEGER NOT NULL,
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		source_chat TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	)`)
+	if err != nil {
+		t.Fatalf("Failed to create the old table: %v", err)
+	}
+	_, err = conn.Exec(`INSERT INTO pending_tasks (user_id, chat_id, message_id, text, created_at) VALUES (456, 789, 1, 'Old', ?)`, time.Now().UTC())
+	if err != nil {
+		t.Fatalf("Failed to insert into the old table: %v", err)
+	}
+	conn.Close()
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open the old database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.StorePendingTask(PendingTask{UserID: 456, ChatID: 789, MessageID: 2, Text: "New", SourceURL: "https://example.com", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("StorePendingTask failed: %v", err)
+	}
+	tasks, err := db.GetPendingTasks()
+	if err != nil {
+		t.Fatalf("GetPendingTasks failed: %v", err)
+	}
+	if len(tasks) != 2 || tasks[0].SourceURL != "" || tasks[1].SourceURL != "https://example.com" {
+		t.Errorf("Expected the old task without a URL and the new one with it, got %+v", tasks)
+	}
+}
+
+func TestUpdateAndDeletePendingTask(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.StorePendingTask(PendingTask{UserID: 456, ChatID: 789, MessageID: 123, Text: "Buy milk", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("StorePendingTask failed: %v", err)
+	}
+	if err := db.UpdatePendingTaskText(789, 123, "Buy oat milk"); err != nil {
+		t.Fatalf("UpdatePendingTaskText failed: %v", err)
+	}
+
+	task, err := db.GetPendingTask(789, 123)
+	if err != nil {
+		t.Fatalf("GetPendingTask failed: %v", err)
+	}
+	if task == nil || task.Text != "Buy oat milk" {
+		t.Errorf("Expected the edited text, got %+v", task)
+	}
+
+	if err := db.DeletePendingTask(789, 123); err != nil {
+		t.Fatalf("DeletePendingTask failed: %v", err)
+	}
+	if task, err := db.GetPendingTask(789, 123); err != nil || task != nil {
+		t.Errorf("Expected the pending task to be gone, got %+v, %v", task, err)
+	}
+}
+
+func TestDeletePendingTasksBefore(t *testing.T) {
+	db := newTestDB(t)
+
+	now := time.Now()
+	for i, age := range []time.Duration{8 * 24 * time.Hour, time.Hour} {
+		task := PendingTask{UserID: 456, ChatID: 789, MessageID: i + 1, Text: "Task", CreatedAt: now.Add(-age)}
+		if err := db.StorePendingTask(task); err != nil {
+			t.Fatalf("StorePendingTask failed: %v", err)
+		}
+	}
+
+	if err := db.DeletePendingTasksBefore(now.Add(-7 * 24 * time.Hour)); err != nil {
+		t.Fatalf("DeletePendingTasksBefore failed: %v", err)
+	}
+
+	tasks, err := db.GetPendingTasks()
+	if err != nil {
+		t.Fatalf("GetPendingTasks failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].MessageID != 2 {
+		t.Errorf("Expected only the recent task to remain, got %+v", tasks)
+	}
+}
+
+func TestCountTasksSinceAndByTag(t *testing.T) {
+	db := newTestDB(t)
+
+	now := time.Now()
+	tasks := []struct {
+		id  string
+		tag string
+		age time.Duration
+	}{
+		{"task-1", "task", time.Hour},
+		{"task-2", "task", 3 * 24 * time.Hour},
+		{"task-3", "journal", 2 * 24 * time.Hour},
+		{"task-4", "link", 20 * 24 * time.Hour},
+		{"task-5", "task", 40 * 24 * time.Hour},
+	}
+	for _, task := range tasks {
+		_, err := db.conn.Exec(`INSERT INTO task_metadata (task_id, task_title, llm_tag, created_at) VALUES (?, ?, ?, ?)`,
+			task.id, "Title", task.tag, now.Add(-task.age))
+		if err != nil {
+			t.Fatalf("Failed to insert task metadata: %v", err)
+		}
+	}
+
+	week, err := db.CountTasksSince(now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("CountTasksSince failed: %v", err)
+	}
+	if week != 3 {
+		t.Errorf("Expected 3 tasks in the last 7 days, got %d", week)
+	}
+
+	byTag, err := db.CountByTag(now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("CountByTag failed: %v", err)
+	}
+	if want := map[string]int{"task": 2, "journal": 1, "link": 1}; !reflect.DeepEqual(byTag, want) {
+		t.Errorf("Expected %v in the last 30 days, got %v", want, byTag)
+	}
+
+	empty, err := db.CountByTag(now.Add(time.Minute))
+	if err != nil || len(empty) != 0 {
+		t.Errorf("Expected no tags for a future window, got %v, %v", empty, err)
+	}
+}
+
+func TestRemindersSurviveReopenAndPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	now := time.Now()
+	if err := db.RecordReminder("task-1", "2024-03-16", now.Add(-40*24*time.Hour)); err != nil {
+		t.Fatalf("RecordReminder failed: %v", err)
+	}
+	if err := db.RecordReminder("task-2", "2024-03-16T10:00:00+03:00", now); err != nil {
+		t.Fatalf("RecordReminder failed: %v", err)
+	}
+	// Recording twice is harmless
+	if err := db.RecordReminder("task-2", "2024-03-16T10:00:00+03:00", now); err != nil {
+		t.Fatalf("RecordReminder failed on a repeat: %v", err)
+	}
+	db.Close()
+
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	for _, check := range []struct {
+		taskID, due string
+		want        bool
+	}{
+		{"task-1", "2024-03-16", true},
+		{"task-2", "2024-03-16T10:00:00+03:00", true},
+		{"task-2", "2024-03-17", false}, // Rescheduled tasks are reminded again
+	} {
+		sent, err := db.ReminderSent(check.taskID, check.due)
+		if err != nil || sent != check.want {
+			t.Errorf("ReminderSent(%s, %s) = %v, %v, expected %v", check.taskID, check.due, sent, err, check.want)
+		}
+	}
+
+	if err := db.DeleteRemindersBefore(now.AddDate(0, 0, -30)); err != nil {
+		t.Fatalf("DeleteRemindersBefore failed: %v", err)
+	}
+	if sent, _ := db.ReminderSent("task-1", "2024-03-16"); sent {
+		t.Error("Expected the old reminder to be pruned")
+	}
+	if sent, _ := db.ReminderSent("task-2", "2024-03-16T10:00:00+03:00"); !sent {
+		t.Error("Expected the recent reminder to remain")
+	}
+}
+
+func TestPendingTextsAreEncryptedWithDataKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	// Stored before the key was set
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.StorePendingTask(PendingTask{UserID: 456, ChatID: 789, MessageID: 1, Text: "Old plaintext", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("StorePendingTask failed: %v", err)
+	}
+	db.Close()
+
+	t.Setenv("DATA_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	task := PendingTask{UserID: 456, ChatID: 789, MessageID: 2, Text: "Call the bank", SourceURL: "https://t.me/c/1/2", CreatedAt: time.Now()}
+	if err := db.StorePendingTask(task); err != nil {
+		t.Fatalf("StorePendingTask failed: %v", err)
+	}
+
+	var text, sourceURL string
+	if err := db.conn.QueryRow(`SELECT text, source_url FROM pending_tasks WHERE message_id = 2`).Scan(&text, &sourceURL); err != nil {
+		t.Fatalf("Failed to read the raw row: %v", err)
+	}
+	if strings.Contains(text, task.Text) || strings.Contains(sourceURL, task.SourceURL) {
+		t.Errorf("Expected the text and link to be encrypted, got %q and %q", text, sourceURL)
+	}
+
+	tasks, err := db.GetPendingTasks()
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("Expected 2 pending tasks, got %d, %v", len(tasks), err)
+	}
+	if tasks[0].Text != "Old plaintext" || tasks[1].Text != task.Text || tasks[1].SourceURL != task.SourceURL {
+		t.Errorf("Expected both texts to read back, got %+v", tasks)
+	}
+	db.Close()
+
+	// Without the key the encrypted task is skipped, the plaintext one still reads
+	t.Setenv("DATA_ENCRYPTION_KEY", "")
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	tasks, err = db.GetPendingTasks()
+	if err != nil || len(tasks) != 1 || tasks[0].MessageID != 1 {
+		t.Errorf("Expected only the plaintext task without the key, got %+v, %v", tasks, err)
+	}
+	if _, err := db.GetPendingTask(789, 2); err == nil {
+		t.Errorf("Expected the encrypted task not to read without the key")
+	}
+}
+
+func TestPauseSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	until := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	if err := db.SetPausedUntil(42, until.Add(-time.Hour)); err != nil {
+		t.Fatalf("SetPausedUntil failed: %v", err)
+	}
+	// Pausing again moves the end
+	if err := db.SetPausedUntil(42, until); err != nil {
+		t.Fatalf("SetPausedUntil failed: %v", err)
+	}
+	db.Close()
+
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	if got, err := db.GetPausedUntil(42); err != nil || !got.Equal(until) {
+		t.Errorf("Expected the pause to end at %v after reopening, got %v, %v", until, got, err)
+	}
+	if got, err := db.GetPausedUntil(43); err != nil || !got.IsZero() {
+		t.Errorf("Expected no pause for another user, got %v, %v", got, err)
+	}
+	if cleared, err := db.ClearPause(42); err != nil || !cleared {
+		t.Errorf("Expected the pause to be cleared, got %v, %v", cleared, err)
+	}
+	if cleared, err := db.ClearPause(42); err != nil || cleared {
+		t.Errorf("Expected nothing left to clear, got %v, %v", cleared, err)
+	}
+}
+
+func TestChatDatabaseSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	now := time.Now()
+	if err := db.SetChatDatabase(789, "personal", now); err != nil {
+		t.Fatalf("SetChatDatabase failed: %v", err)
+	}
+	// Picking again replaces the choice
+	if err := db.SetChatDatabase(789, "work", now); err != nil {
+		t.Fatalf("SetChatDatabase failed: %v", err)
+	}
+	db.Close()
+
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+
+	if got, err := db.GetChatDatabase(789); err != nil || got != "work" {
+		t.Errorf("Expected work after reopening, got %q, %v", got, err)
+	}
+	if got, err := db.GetChatDatabase(790); err != nil || got != "" {
+		t.Errorf("Expected no database for another chat, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,428 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration upgrades the schema by one version. Each runs in its own transaction, together
+// with recording its version.
+type migration struct {
+	version int
+	name    string
+	apply   func(tx *sql.Tx) error
+}
+
+// migrations are applied in order to bring a database up to the latest version. Append new
+// ones at the end and never change one that has shipped.
+var migrations = []migration{
+	{1, "initial schema", migrateInitialSchema},
+	{2, "task_metadata updated_at", migrateTaskMetadataUpdatedAt},
+	{3, "reminders", migrateReminders},
+	{4, "users", migrateUsers},
+	{5, "pending_tasks transcript_message_id", migratePendingTranscripts},
+	{6, "pauses", migratePauses},
+	{7, "missed_digests", migrateMissedDigests},
+	{8, "outbox", migrateOutbox},
+	{9, "pending_tasks origin", migratePendingOrigins},
+	{10, "recurrences", migrateRecurrences},
+	{11, "saved_messages", migrateSavedMessages},
+	{12, "chat_databases", migrateChatDatabases},
+	{13, "pending_tasks photo_file_id", migratePendingPhotos},
+	{14, "task_metadata workspace", migrateTaskWorkspaces},
+	{15, "journal_copies", migrateJournalCopies},
+}
+
+// migrate applies the migrations newer than the database's schema version
+func (db *DB) migrate() error {
+	_, err := db.conn.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := db.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].version; current > latest {
+		log.Printf("Warning: Database schema version %d is newer than this build knows (%d)", current, latest)
+		return nil
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		err := db.withTx(func(tx *sql.Tx) error {
+			if err := m.apply(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)`, m.version, m.name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("migration %03d (%s) failed: %w", m.version, m.name, err)
+		}
+		log.Printf("Applied database migration %03d: %s", m.version, m.name)
+	}
+	return nil
+}
+
+// schemaVersion returns the version of the last applied migration, 0 for a new database
+// or one created before migrations existed
+func (db *DB) schemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := db.conn.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// migrateInitialSchema creates the tables the database had before migrations. Databases
+// created back then already have them, so everything is created only if missing.
+func migrateInitialSchema(tx *sql.Tx) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS task_metadata (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL UNIQUE,
+		task_title TEXT NOT NULL,
+		llm_tag TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_created_at ON task_metadata(created_at);
+	CREATE INDEX IF NOT EXISTS idx_llm_tag ON task_metadata(llm_tag);
+
+	CREATE TABLE IF NOT EXISTS digest_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id INTEGER NOT NULL,
+		flagged INTEGER NOT NULL DEFAULT 0,
+		collapsed INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_digest_runs_chat ON digest_runs(chat_id, collapsed);
+
+	CREATE TABLE IF NOT EXISTS digest_messages (
+		run_id INTEGER NOT NULL REFERENCES digest_runs(id) ON DELETE CASCADE,
+		message_id INTEGER NOT NULL,
+		kind TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_digest_messages_run ON digest_messages(run_id);
+
+	CREATE TABLE IF NOT EXISTS save_attempts (
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		state TEXT NOT NULL,
+		page_id TEXT NOT NULL DEFAULT '',
+		started_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_save_attempts_state ON save_attempts(state, updated_at);
+
+	CREATE TABLE IF NOT EXISTS schema_snapshots (
+		db_id TEXT PRIMARY KEY,
+		snapshot TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS schema_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		db_id TEXT NOT NULL,
+		db_type TEXT NOT NULL,
+		changes TEXT NOT NULL,
+		detected_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS daily_usage (
+		day TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		value INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, metric)
+	);
+
+	CREATE TABLE IF NOT EXISTS shares (
+		slug TEXT PRIMARY KEY,
+		filter TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS pending_tasks (
+		user_id INTEGER NOT NULL,
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		source_chat TEXT NOT NULL DEFAULT '',
+		source_url TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_pending_tasks_created ON pending_tasks(created_at);
+
+	CREATE TABLE IF NOT EXISTS chat_feedback_modes (
+		chat_id INTEGER PRIMARY KEY,
+		mode TEXT NOT NULL,
+		probed_at TIMESTAMP NOT NULL
+	);
+	`
+
+	if _, err := tx.Exec(query); err != nil {
+		return fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	// Columns added after a table was first created
+	return addColumnIfMissing(tx, "pending_tasks", "source_url", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateTaskMetadataUpdatedAt records when the tag of a task last changed, backfilled
+// with the creation time
+func migrateTaskMetadataUpdatedAt(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "task_metadata", "updated_at", "TIMESTAMP"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE task_metadata SET updated_at = created_at WHERE updated_at IS NULL`); err != nil {
+		return fmt.Errorf("failed to backfill task_metadata.updated_at: %w", err)
+	}
+	return nil
+}
+
+// migrateReminders records the due-date reminders sent, so a restart doesn't send them again
+func migrateReminders(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS reminders (
+		task_id TEXT NOT NULL,
+		due TEXT NOT NULL,
+		sent_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (task_id, due)
+	);
+	CREATE INDEX IF NOT EXISTS idx_reminders_sent ON reminders(sent_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create reminders table: %w", err)
+	}
+	return nil
+}
+
+// migrateUsers stores the Notion credentials of users who connected their own workspace
+func migrateUsers(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS users (
+		telegram_user_id INTEGER PRIMARY KEY,
+		notion_token BLOB NOT NULL,
+		tasks_db_id TEXT NOT NULL,
+		notes_db_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create users table: %w", err)
+	}
+	return nil
+}
+
+// migratePendingTranscripts records the bot's transcription of a voice note or video,
+// which can be answered with a correction
+func migratePendingTranscripts(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "pending_tasks", "transcript_message_id", "INTEGER NOT NULL DEFAULT 0")
+}
+
+// migratePendingOrigins records whether a pending message was a voice note, a video or
+// a forward, for the source comment of its task
+func migratePendingOrigins(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "pending_tasks", "origin", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migratePendingPhotos records the Telegram file of a photo, attached to the task once saved
+func migratePendingPhotos(tx *sql.Tx) error {
+	return addColumnIfMissing(tx, "pending_tasks", "photo_file_id", "TEXT NOT NULL DEFAULT ''")
+}
+
+// migrateTaskWorkspaces records whose workspace each task was created in, so the daily
+// prune and the reconciliation look it up with that workspace's integration. Tasks saved
+// from the message of a user who connected a workspace are taken to be theirs, the rest
+// the bot's own.
+func migrateTaskWorkspaces(tx *sql.Tx) error {
+	if err := addColumnIfMissing(tx, "task_metadata", "workspace_user_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+	UPDATE task_metadata SET workspace_user_id = (
+		SELECT s.user_id FROM saved_messages s JOIN users u ON u.telegram_user_id = s.user_id
+		WHERE s.page_id = task_metadata.task_id LIMIT 1
+	)
+	WHERE EXISTS (
+		SELECT 1 FROM saved_messages s JOIN users u ON u.telegram_user_id = s.user_id
+		WHERE s.page_id = task_metadata.task_id
+	);
+	CREATE INDEX IF NOT EXISTS idx_task_metadata_workspace ON task_metadata(workspace_user_id);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill task_metadata.workspace_user_id: %w", err)
+	}
+	return nil
+}
+
+// migrateJournalCopies records the journal entries copied from tasks that couldn't be
+// archived, so the next check archives the task instead of copying it again
+func migrateJournalCopies(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS journal_copies (
+		task_id TEXT PRIMARY KEY,
+		journal_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create journal_copies table: %w", err)
+	}
+	return nil
+}
+
+// migratePauses records until when a user paused the scheduler's notifications
+func migratePauses(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS pauses (
+		user_id INTEGER PRIMARY KEY,
+		paused_until TIMESTAMP NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create pauses table: %w", err)
+	}
+	return nil
+}
+
+// migrateMissedDigests keeps the digests Telegram couldn't be reached to send, until a
+// later run delivers them
+func migrateMissedDigests(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS missed_digests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chat_id INTEGER NOT NULL,
+		messages TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_missed_digests_chat ON missed_digests(chat_id, created_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create missed_digests table: %w", err)
+	}
+	return nil
+}
+
+// migrateOutbox queues Notion writes that failed while Notion was down, for the retrier
+func migrateOutbox(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		operation TEXT NOT NULL,
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL DEFAULT 0,
+		payload TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_retry_at TIMESTAMP NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		parked BOOLEAN NOT NULL DEFAULT 0,
+		created_at TIMESTAMP NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_due ON outbox(parked, next_retry_at);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create outbox table: %w", err)
+	}
+	return nil
+}
+
+// migrateRecurrences records the next occurrences spawned for repeating tasks, so a
+// task isn't spawned twice for the same due date
+func migrateRecurrences(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS recurrences (
+		source_task_id TEXT NOT NULL,
+		due TEXT NOT NULL,
+		spawned_task_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (source_task_id, due)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create recurrences table: %w", err)
+	}
+	return nil
+}
+
+func migrateSavedMessages(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS saved_messages (
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		page_id TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create saved_messages table: %w", err)
+	}
+	return nil
+}
+
+func migrateChatDatabases(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+	CREATE TABLE IF NOT EXISTS chat_databases (
+		chat_id INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create chat_databases table: %w", err)
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to a table created before the column existed
+func addColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dflt      sql.NullString
+			isPrimary int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &isPrimary); err != nil {
+			return fmt.Errorf("failed to scan columns of %s: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating columns of %s: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add column %s to %s: %w", column, table, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// legacySchema is part of the schema initSchema created before migrations, with data
+const legacySchema = `
+	CREATE TABLE task_metadata (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id TEXT NOT NULL UNIQUE,
+		task_title TEXT NOT NULL,
+		llm_tag TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX idx_created_at ON task_metadata(created_at);
+	CREATE INDEX idx_llm_tag ON task_metadata(llm_tag);
+
+	CREATE TABLE shares (
+		slug TEXT PRIMARY KEY,
+		filter TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	);
+
+	CREATE TABLE pending_tasks (
+		user_id INTEGER NOT NULL,
+		chat_id INTEGER NOT NULL,
+		message_id INTEGER NOT NULL,
+		text TEXT NOT NULL,
+		source_chat TEXT NOT NULL DEFAULT '',
+		source_url TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL,
+		PRIMARY KEY (chat_id, message_id)
+	);
+`
+
+// appliedVersions returns the versions recorded in schema_version, in order
+func appliedVersions(t *testing.T, db *DB) []int {
+	t.Helper()
+	rows, err := db.conn.Query(`SELECT version FROM schema_version ORDER BY version`)
+	if err != nil {
+		t.Fatalf("Failed to read schema_version: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			t.Fatalf("Failed to scan version: %v", err)
+		}
+		versions = append(versions, version)
+	}
+	return versions
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	db := newTestDB(t)
+
+	if got := appliedVersions(t, db); len(got) != len(migrations) || got[len(got)-1] != migrations[len(migrations)-1].version {
+		t.Errorf("Expected all %d migrations applied, got %v", len(migrations), got)
+	}
+
+	var mode string
+	if err := db.conn.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("Expected WAL journal mode, got %q, %v", mode, err)
+	}
+	var timeout int
+	if err := db.conn.QueryRow(`PRAGMA busy_timeout`).Scan(&timeout); err != nil || timeout != int(busyTimeout.Milliseconds()) {
+		t.Errorf("Expected a busy timeout of %v, got %dms, %v", busyTimeout, timeout, err)
+	}
+
+	if err := db.StoreTaskMetadata(OwnWorkspace, "task-1", "Buy milk", "task"); err != nil {
+		t.Fatalf("StoreTaskMetadata failed: %v", err)
+	}
+	tasks, err := db.GetTasksSince(time.Now().Add(-time.Hour))
+	if err != nil || len(tasks) != 1 || tasks[0].UpdatedAt.IsZero() {
+		t.Errorf("Expected the task with its update time, got %+v, %v", tasks, err)
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := conn.Exec(legacySchema); err != nil {
+		t.Fatalf("Failed to create the legacy schema: %v", err)
+	}
+	createdAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	_, err = conn.Exec(`INSERT INTO task_metadata (task_id, task_title, llm_tag, created_at) VALUES ('task-1', 'Old task', 'journal', ?)`, createdAt)
+	if err != nil {
+		t.Fatalf("Failed to insert into the legacy schema: %v", err)
+	}
+	conn.Close()
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to upgrade the legacy database: %v", err)
+	}
+	defer db.Close()
+
+	if got := appliedVersions(t, db); len(got) != len(migrations) {
+		t.Errorf("Expected all %d migrations applied, got %v", len(migrations), got)
+	}
+
+	// Existing rows survive and get their update time backfilled
+	tasks, err := db.GetTasksSince(time.Now().Add(-72 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetTasksSince failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].TaskTitle != "Old task" || !tasks[0].UpdatedAt.Equal(createdAt) {
+		t.Errorf("Expected the old task with updated_at backfilled to %v, got %+v", createdAt, tasks)
+	}
+
+	// Tables the legacy schema lacked are created
+	if err := db.SetChatFeedbackMode(789, FeedbackModeReplies, time.Now()); err != nil {
+		t.Errorf("Expected the missing tables to be created, got %v", err)
+	}
+}
+
+func TestMigrateRunsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	for i := 0; i < 2; i++ {
+		db, err := NewDB(path)
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		if got := appliedVersions(t, db); len(got) != len(migrations) {
+			t.Errorf("Expected each migration recorded once after open %d, got %v", i+1, got)
+		}
+		db.Close()
+	}
+}
+
+func TestFailedMigrationRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	db.Close()
+
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	migrations = append(append([]migration(nil), saved...), migration{
+		version: saved[len(saved)-1].version + 1,
+		name:    "broken",
+		apply: func(tx *sql.Tx) error {
+			if _, err := tx.Exec(`CREATE TABLE half_done (id INTEGER)`); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+
+	if _, err := NewDB(path); err == nil {
+		t.Fatal("Expected the broken migration to fail opening the database")
+	}
+
+	migrations = saved
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer db.Close()
+	if got := appliedVersions(t, db); len(got) != len(saved) {
+		t.Errorf("Expected the broken migration not to be recorded, got %v", got)
+	}
+	var n int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("Expected the broken migration's table to be rolled back, got %d, %v", n, err)
+	}
+}
+
+func TestMigrateTaskWorkspacesBackfillsConnectedUsers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	saved := migrations
+	t.Cleanup(func() { migrations = saved })
+	for i, m := range saved {
+		if m.name == "task_metadata workspace" {
+			migrations = saved[:i]
+		}
+	}
+
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	now := time.Now()
+	for _, id := range []string{"page-own", "page-connected"} {
+		if _, err := db.conn.Exec(`INSERT INTO task_metadata (task_id, task_title, llm_tag, created_at, updated_at) VALUES (?, 'Title', 'task', ?, ?)`, id, now, now); err != nil {
+			t.Fatalf("Failed to insert task: %v", err)
+		}
+	}
+	if err := db.SaveUser(User{TelegramUserID: 77, NotionToken: []byte("sealed"), TasksDbID: "their-tasks", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	for i, saved := range []SavedMessage{{UserID: 456, PageID: "page-own"}, {UserID: 77, PageID: "page-connected"}} {
+		saved.ChatID, saved.MessageID, saved.CreatedAt = 789, i+1, now
+		if err := db.RecordSavedMessage(saved); err != nil {
+			t.Fatalf("RecordSavedMessage failed: %v", err)
+		}
+	}
+	db.Close()
+
+	migrations = saved
+	db, err = NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to upgrade database: %v", err)
+	}
+	defer db.Close()
+
+	for workspace, want := range map[int64]string{OwnWorkspace: "page-own", 77: "page-connected"} {
+		if ids, err := db.GetTaskIDs(workspace); err != nil || len(ids) != 1 || ids[0] != want {
+			t.Errorf("Expected %s in workspace %d, got %v, %v", want, workspace, ids, err)
+		}
+	}
+}
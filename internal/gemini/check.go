@@ -0,0 +1,37 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+var _ llm.Checker = (*Client)(nil)
+
+// Check verifies the API key by fetching the tagging model's metadata, which costs no tokens
+func (c *Client) Check(ctx context.Context) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("GEMINI_API_KEY not set")
+	}
+
+	endpoint := fmt.Sprintf("%s/v1beta/models/%s?key=%s", c.baseURL, c.model, url.QueryEscape(c.apiKey))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Gemini: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gemini returned status %d for model %s: %s", resp.StatusCode, c.model, body)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package gemini
 
 import (
     "bytes"
+    "context"
     "encoding/base64"
     "encoding/json"
     "fmt"
@@ -9,7 +10,15 @@ import (
     "log"
     "net/http"
     "os"
+    "strconv"
     "strings"
+    "time"
+
+    "github.com/numero_quadro/notion-mini-app/internal/llm"
+    "github.com/numero_quadro/notion-mini-app/internal/metrics"
+    "github.com/numero_quadro/notion-mini-app/internal/tracing"
+    "github.com/numero_quadro/notion-mini-app/internal/usage"
+    "go.opentelemetry.io/otel/attribute"
 )
 
 type Client struct {
@@ -17,8 +26,19 @@ type Client struct {
     model  string
     audioModel string
     apiVersion string
+    baseURL    string
+
+    httpClient   *http.Client
+    audioClient  *http.Client // Sends transcriptions, bounded by the caller's context only
+    retryBackoff time.Duration // Wait before retrying a transcription, growing with each attempt
 }
 
+// Client implements every LLM capability
+var _ llm.Provider = (*Client)(nil)
+
+// defaultBaseURL is the public Gemini API endpoint
+const defaultBaseURL = "https://generativelanguage.googleapis.com"
+
 type GeminiRequest struct {
 	Contents []Content `json:"contents"`
 }
@@ -33,7 +53,23 @@ type Part struct {
 }
 
 type GeminiResponse struct {
-	Candidates []Candidate `json:"candidates"`
+	Candidates    []Candidate    `json:"candidates"`
+	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
+}
+
+// UsageMetadata reports the tokens a request consumed
+type UsageMetadata struct {
+	PromptTokenCount     int64 `json:"promptTokenCount"`
+	CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+	TotalTokenCount      int64 `json:"totalTokenCount"`
+}
+
+// recordUsage adds a call and its tokens to the usage counters
+func recordUsage(resp GeminiResponse) {
+	usage.Add(usage.GeminiCalls, 1)
+	if resp.UsageMetadata != nil {
+		usage.Add(usage.GeminiTokens, resp.UsageMetadata.TotalTokenCount)
+	}
 }
 
 type Candidate struct {
@@ -54,8 +90,45 @@ type InlineData struct {
     Data     string `json:"data"`
 }
 
+// defaultHTTPTimeout bounds a whole Gemini request, so a stalled connection fails instead
+// of hanging the caller
+const defaultHTTPTimeout = 30 * time.Second
+
+// defaultModel is the text model used for tagging and dates without GEMINI_MODEL
+const defaultModel = "gemini-2.0-flash-lite"
+
+// newHTTPClient returns the client text requests go through by default
+func newHTTPClient() *http.Client {
+    transport := newTransport()
+    transport.ResponseHeaderTimeout = defaultHTTPTimeout
+    return &http.Client{Timeout: defaultHTTPTimeout, Transport: transport}
+}
+
+// newAudioHTTPClient returns the client transcriptions go through by default. Uploading
+// and transcribing a long recording can outlast defaultHTTPTimeout, so only the caller's
+// context bounds it.
+func newAudioHTTPClient() *http.Client {
+    return &http.Client{Transport: newTransport()}
+}
+
+// newTransport returns a transport with the settings both default clients share
+func newTransport() *http.Transport {
+    transport := http.DefaultTransport.(*http.Transport).Clone()
+    transport.TLSHandshakeTimeout = 10 * time.Second
+    transport.MaxIdleConnsPerHost = 4
+    return transport
+}
+
 // NewClient creates a new Gemini API client
 func NewClient() *Client {
+    client := NewClientWithHTTP(newHTTPClient())
+    client.audioClient = newAudioHTTPClient()
+    return client
+}
+
+// NewClientWithHTTP creates a Gemini API client configured from the environment that
+// sends its requests through httpClient, e.g. one pointed at a fake server in tests
+func NewClientWithHTTP(httpClient *http.Client) *Client {
     apiKey := os.Getenv("GEMINI_API_KEY")
     if apiKey == "" {
         log.Printf("WARNING: GEMINI_API_KEY not set")
@@ -68,37 +141,95 @@ func NewClient() *Client {
         audioModel = "gemini-2.5-flash"
     }
 
+    // Allow switching the text model without a rebuild
+    model := os.Getenv("GEMINI_MODEL")
+    if model == "" {
+        model = defaultModel
+    }
+
     apiVersion := os.Getenv("GEMINI_API_VERSION")
     if apiVersion == "" {
         apiVersion = "v1beta"
     }
 
+    // Allow pointing at a proxy or a local fake
+    baseURL := strings.TrimSuffix(os.Getenv("GEMINI_API_URL"), "/")
+    if baseURL == "" {
+        baseURL = defaultBaseURL
+    }
+
     return &Client{
         apiKey:     apiKey,
-        model:      "gemini-2.0-flash-lite", // Text-only tagging
-        audioModel: audioModel,               // Multimodal model for audio transcription
+        model:      model,      // Text-only tagging
+        audioModel: audioModel, // Multimodal model for audio transcription
         apiVersion: apiVersion,
+        baseURL:    baseURL,
+
+        httpClient:   httpClient,
+        audioClient:  httpClient,
+        retryBackoff: defaultTranscribeBackoff,
     }
 }
 
 // TagTask analyzes the task content and returns an appropriate tag
-func (c *Client) TagTask(taskContent string) (string, error) {
-	if c.apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY not configured")
+func (c *Client) TagTask(ctx context.Context, taskContent string) (string, error) {
+	text, err := c.generate(ctx, llm.TagPrompt(taskContent))
+	if err != nil {
+		return "", err
+	}
+
+	tag := llm.NormalizeTag(text)
+	if tag != strings.TrimSpace(strings.ToLower(text)) {
+		log.Printf("Gemini returned %q, using tag '%s'", text, tag)
+	}
+	metrics.RecordTag(tag)
+
+	log.Printf("Gemini tagged task as: %s", tag)
+	return tag, nil
+}
+
+// TagTasksBatch tags several task entries with a single request
+func (c *Client) TagTasksBatch(ctx context.Context, contents []string) ([]string, error) {
+	if len(contents) == 0 {
+		return nil, nil
 	}
 
-	// Create the prompt
-	prompt := fmt.Sprintf(`Analyze the following task entry and categorize it with a single tag.
+	text, err := c.generate(ctx, llm.BatchTagPrompt(contents))
+	if err != nil {
+		return nil, err
+	}
+	return llm.ParseBatchTags(text, len(contents))
+}
 
-Rules:
-- If the entry is ONLY a URL/link (starts with http, https, or looks like a web link), respond with exactly: "link"
-- If the entry mentions thoughts, emotions, observations, feelings, reflections, or is a personal journal-style entry, respond with exactly: "journal"
-- If the entry mentions a deadline, date reference (like "today", "tomorrow", "next week", "23 october", "by friday", "due on", etc.) OR mentions university/college subjects, courses, homework, assignments, exams, labs, or academic tasks (including Software Engineering topics like highload, data analysis, algorithms, databases, or any subject related to ITMO University or software engineering studies), respond with exactly: "date"
-- If none of the above apply, respond with exactly: "task"
+// Summarize returns a short paragraph on the themes of the items
+func (c *Client) Summarize(ctx context.Context, items []string) (string, error) {
+	if len(items) == 0 {
+		return "", nil
+	}
 
-Task entry: "%s"
+	summary, err := c.generate(ctx, llm.SummaryPrompt(items))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// generate sends a text-only prompt to the tagging model and returns the first candidate's text
+func (c *Client) generate(ctx context.Context, prompt string) (text string, err error) {
+	ctx, span := tracing.Start(ctx, "gemini generateContent")
+	start := time.Now()
+	defer func() {
+		metrics.ObserveGemini("generateContent", time.Since(start), err)
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+	if span.IsRecording() {
+		span.SetAttributes(attribute.String("gemini.model", c.model))
+	}
 
-Respond with ONLY ONE WORD from: link, journal, date, or task`, taskContent)
+	if c.apiKey == "" {
+		return "", fmt.Errorf("GEMINI_API_KEY not configured")
+	}
 
 	// Create request body
 	reqBody := GeminiRequest{
@@ -117,9 +248,15 @@ Respond with ONLY ONE WORD from: link, journal, date, or task`, taskContent)
 	}
 
 	// Make API request
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", c.model, c.apiKey)
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", c.baseURL, c.model, c.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("failed to call Gemini API: %w", err)
 	}
@@ -135,33 +272,57 @@ Respond with ONLY ONE WORD from: link, journal, date, or task`, taskContent)
 	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
 		return "", fmt.Errorf("failed to decode response: %w", err)
 	}
+	recordUsage(geminiResp)
 
-	// Extract tag from response
 	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
 		return "", fmt.Errorf("empty response from Gemini API")
 	}
 
-	tag := strings.TrimSpace(strings.ToLower(geminiResp.Candidates[0].Content.Parts[0].Text))
-
-	// Validate tag
-	validTags := map[string]bool{
-		"link":    true,
-		"journal": true,
-		"date":    true,
-		"task":    true,
-	}
+	return geminiResp.Candidates[0].Content.Parts[0].Text, nil
+}
 
-	if !validTags[tag] {
-		log.Printf("Invalid tag received from Gemini: %s, defaulting to 'task'", tag)
-		tag = "task"
-	}
+const (
+    // maxTranscribeAttempts is how often one model is asked before a rate limit or server
+    // error is returned
+    maxTranscribeAttempts = 3
+    // defaultTranscribeBackoff is the wait before the second attempt, growing with each one
+    defaultTranscribeBackoff = 2 * time.Second
+)
 
-	log.Printf("Gemini tagged task as: %s", tag)
-	return tag, nil
+// transcriptionPrompt asks for the bare transcription of the attached audio or video
+const transcriptionPrompt = "Transcribe this audio to plain text. Respond with only the transcription."
+
+// transcriptionBody streams the JSON generateContent request for audio, base64-encoding
+// it as it's sent so the encoded copy is never held in memory
+func transcriptionBody(audio []byte, mimeType string) io.ReadCloser {
+    pr, pw := io.Pipe()
+    go func() {
+        prompt, _ := json.Marshal(transcriptionPrompt)
+        mime, _ := json.Marshal(mimeType)
+        if _, err := fmt.Fprintf(pw, `{"contents":[{"parts":[{"text":%s},{"inlineData":{"mimeType":%s,"data":"`, prompt, mime); err != nil {
+            pw.CloseWithError(err)
+            return
+        }
+        encoder := base64.NewEncoder(base64.StdEncoding, pw)
+        if _, err := encoder.Write(audio); err != nil {
+            pw.CloseWithError(err)
+            return
+        }
+        if err := encoder.Close(); err != nil {
+            pw.CloseWithError(err)
+            return
+        }
+        _, err := io.WriteString(pw, `"}}]}]}`)
+        pw.CloseWithError(err)
+    }()
+    return pr
 }
 
-// TranscribeAudio sends audio bytes to Gemini and returns the transcription text
-func (c *Client) TranscribeAudio(audio []byte, mimeType string) (string, error) {
+// TranscribeAudio sends audio bytes to Gemini and returns the transcription text. Video
+// notes and videos can be passed too, Gemini transcribes their speech.
+func (c *Client) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (text string, err error) {
+    start := time.Now()
+    defer func() { metrics.ObserveGemini("transcribe", time.Since(start), err) }()
     if c.apiKey == "" {
         return "", fmt.Errorf("GEMINI_API_KEY not configured")
     }
@@ -173,22 +334,6 @@ func (c *Client) TranscribeAudio(audio []byte, mimeType string) (string, error)
         mimeType = "audio/ogg"
     }
 
-    reqBody := GeminiRequest{
-        Contents: []Content{
-            {
-                Parts: []Part{
-                    {Text: "Transcribe this audio to plain text. Respond with only the transcription."},
-                    {InlineData: &InlineData{MimeType: mimeType, Data: base64.StdEncoding.EncodeToString(audio)}},
-                },
-            },
-        },
-    }
-
-    jsonData, err := json.Marshal(reqBody)
-    if err != nil {
-        return "", fmt.Errorf("failed to marshal request: %w", err)
-    }
-
     // Try model and API version fallbacks for compatibility
     modelCandidates := []string{}
     if m := os.Getenv("GEMINI_AUDIO_MODEL"); m != "" {
@@ -214,37 +359,22 @@ func (c *Client) TranscribeAudio(audio []byte, mimeType string) (string, error)
     var lastErr error
     for _, model := range modelCandidates {
         for _, ver := range versionCandidates {
-            url := fmt.Sprintf("https://generativelanguage.googleapis.com/%s/models/%s:generateContent?key=%s", ver, model, c.apiKey)
             log.Printf("Gemini transcription using model=%s api=%s", model, ver)
-            resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-            if err != nil {
-                lastErr = fmt.Errorf("failed request for %s/%s: %w", ver, model, err)
-                continue
-            }
-            bodyBytes, _ := io.ReadAll(resp.Body)
-            resp.Body.Close()
-
-            if resp.StatusCode == http.StatusOK {
-                var geminiResp GeminiResponse
-                if err := json.Unmarshal(bodyBytes, &geminiResp); err != nil {
-                    return "", fmt.Errorf("failed to decode response: %w", err)
-                }
-                if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-                    return "", fmt.Errorf("empty response from Gemini API")
-                }
-                text := strings.TrimSpace(geminiResp.Candidates[0].Content.Parts[0].Text)
+            text, status, err := c.transcribeWith(ctx, ver, model, audio, mimeType)
+            if err == nil {
                 log.Printf("Gemini transcription length: %d chars (model=%s)", len(text), model)
                 return text, nil
             }
-
-            // If not OK, decide whether to try next or fail
-            if resp.StatusCode == http.StatusNotFound {
-                // Model not found for this version; try next combination
-                lastErr = fmt.Errorf("404 for %s/%s: %s", ver, model, string(bodyBytes))
+            if ctx.Err() != nil {
+                return "", fmt.Errorf("transcription cancelled: %w", ctx.Err())
+            }
+            // Model not found for this version, or no answer at all; try next combination
+            if status == http.StatusNotFound || status == 0 {
+                lastErr = err
                 continue
             }
             // For other errors, return immediately to surface real issues (quota, auth, etc.)
-            return "", fmt.Errorf("Gemini API returned status %d for %s/%s: %s", resp.StatusCode, ver, model, string(bodyBytes))
+            return "", err
         }
     }
     if lastErr != nil {
@@ -252,3 +382,74 @@ func (c *Client) TranscribeAudio(audio []byte, mimeType string) (string, error)
     }
     return "", fmt.Errorf("no available Gemini model for audio transcription")
 }
+
+// transcribeWith asks one model for the transcription, retrying rate limits and server
+// errors with a growing backoff. It returns the last HTTP status, 0 when the request
+// itself failed.
+func (c *Client) transcribeWith(ctx context.Context, ver, model string, audio []byte, mimeType string) (string, int, error) {
+    url := fmt.Sprintf("%s/%s/models/%s:generateContent?key=%s", c.baseURL, ver, model, c.apiKey)
+    for attempt := 1; ; attempt++ {
+        body := transcriptionBody(audio, mimeType)
+        req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+        if err != nil {
+            body.Close()
+            return "", 0, fmt.Errorf("failed to create request: %w", err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+        resp, err := c.audioClient.Do(req)
+        if err != nil {
+            return "", 0, fmt.Errorf("failed request for %s/%s: %w", ver, model, err)
+        }
+        bodyBytes, _ := io.ReadAll(resp.Body)
+        resp.Body.Close()
+
+        if resp.StatusCode == http.StatusOK {
+            text, err := transcriptionText(bodyBytes)
+            return text, resp.StatusCode, err
+        }
+        if resp.StatusCode == http.StatusNotFound {
+            return "", resp.StatusCode, fmt.Errorf("404 for %s/%s: %s", ver, model, string(bodyBytes))
+        }
+
+        err = fmt.Errorf("Gemini API returned status %d for %s/%s: %s", resp.StatusCode, ver, model, string(bodyBytes))
+        retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+        if !retryable || attempt == maxTranscribeAttempts {
+            return "", resp.StatusCode, err
+        }
+
+        wait := time.Duration(attempt) * c.retryBackoff
+        if seconds, convErr := strconv.Atoi(resp.Header.Get("Retry-After")); convErr == nil && seconds > 0 {
+            wait = time.Duration(seconds) * time.Second
+        }
+        log.Printf("Gemini transcription attempt %d/%d got status %d, retrying in %v", attempt, maxTranscribeAttempts, resp.StatusCode, wait)
+        select {
+        case <-ctx.Done():
+            return "", resp.StatusCode, err
+        case <-time.After(wait):
+        }
+    }
+}
+
+// transcriptionText returns the whole text of a generateContent response, failing when
+// there is none
+func transcriptionText(body []byte) (string, error) {
+    var geminiResp GeminiResponse
+    if err := json.Unmarshal(body, &geminiResp); err != nil {
+        return "", fmt.Errorf("failed to decode response: %w", err)
+    }
+    recordUsage(geminiResp)
+    if len(geminiResp.Candidates) == 0 {
+        return "", fmt.Errorf("empty response from Gemini API")
+    }
+
+    // Long answers can come in several parts
+    var text strings.Builder
+    for _, part := range geminiResp.Candidates[0].Content.Parts {
+        text.WriteString(part.Text)
+    }
+    transcript := strings.TrimSpace(text.String())
+    if transcript == "" {
+        return "", fmt.Errorf("Gemini returned no transcription text")
+    }
+    return transcript, nil
+}
@@ -0,0 +1,225 @@
+package gemini
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGemini answers generateContent calls with the responses in order, repeating the last
+type fakeGemini struct {
+	mu        sync.Mutex
+	responses []fakeResponse
+	paths     []string
+	bodies    [][]byte
+}
+
+type fakeResponse struct {
+	status int
+	body   string
+}
+
+func (f *fakeGemini) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	f.paths = append(f.paths, r.URL.Path)
+	f.bodies = append(f.bodies, body)
+	response := f.responses[len(f.responses)-1]
+	if len(f.paths) <= len(f.responses) {
+		response = f.responses[len(f.paths)-1]
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.status)
+	io.WriteString(w, response.body)
+}
+
+func (f *fakeGemini) requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.paths)
+}
+
+// newTranscriptionClient returns a client whose calls are served by the fake
+func newTranscriptionClient(t *testing.T, fake *fakeGemini) *Client {
+	t.Helper()
+	t.Setenv("GEMINI_AUDIO_MODEL", "")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return &Client{
+		apiKey:       "test-key",
+		audioModel:   "audio-model",
+		apiVersion:   "v1beta",
+		baseURL:      server.URL,
+		httpClient:   server.Client(),
+		audioClient:  server.Client(),
+		retryBackoff: time.Millisecond,
+	}
+}
+
+const transcriptionJSON = `{"candidates": [{"content": {"parts": [{"text": "Call the plumber "}, {"text": "about the kitchen sink"}]}}]}`
+
+func TestTranscribeAudio(t *testing.T) {
+	fake := &fakeGemini{responses: []fakeResponse{{http.StatusOK, transcriptionJSON}}}
+	client := newTranscriptionClient(t, fake)
+
+	text, err := client.TranscribeAudio(context.Background(), []byte("ogg bytes"), "audio/ogg")
+	if err != nil {
+		t.Fatalf("TranscribeAudio failed: %v", err)
+	}
+	if text != "Call the plumber about the kitchen sink" {
+		t.Errorf("Expected every part of the transcription, got %q", text)
+	}
+	if fake.paths[0] != "/v1beta/models/audio-model:generateContent" {
+		t.Errorf("Unexpected path %s", fake.paths[0])
+	}
+
+	// The streamed body is the same request json.Marshal would build
+	var request GeminiRequest
+	if err := json.Unmarshal(fake.bodies[0], &request); err != nil {
+		t.Fatalf("Invalid request body %s: %v", fake.bodies[0], err)
+	}
+	parts := request.Contents[0].Parts
+	if len(parts) != 2 || parts[0].Text != transcriptionPrompt || parts[1].InlineData == nil {
+		t.Fatalf("Expected the prompt and the audio, got %s", fake.bodies[0])
+	}
+	if parts[1].InlineData.MimeType != "audio/ogg" || parts[1].InlineData.Data != base64.StdEncoding.EncodeToString([]byte("ogg bytes")) {
+		t.Errorf("Unexpected inline data %+v", parts[1].InlineData)
+	}
+}
+
+func TestTranscribeAudioRetriesRateLimits(t *testing.T) {
+	fake := &fakeGemini{responses: []fakeResponse{
+		{http.StatusTooManyRequests, `{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED"}}`},
+		{http.StatusServiceUnavailable, `{"error": {"code": 503, "status": "UNAVAILABLE"}}`},
+		{http.StatusOK, transcriptionJSON},
+	}}
+	client := newTranscriptionClient(t, fake)
+
+	if _, err := client.TranscribeAudio(context.Background(), []byte("ogg bytes"), "audio/ogg"); err != nil {
+		t.Fatalf("TranscribeAudio failed: %v", err)
+	}
+	if n := fake.requests(); n != 3 {
+		t.Errorf("Expected 3 requests, got %d", n)
+	}
+	for _, path := range fake.paths {
+		if !strings.Contains(path, "audio-model") {
+			t.Errorf("Expected the retries to stay on the first model, got %s", path)
+		}
+	}
+}
+
+func TestTranscribeAudioGivesUpAfterRetries(t *testing.T) {
+	fake := &fakeGemini{responses: []fakeResponse{{http.StatusTooManyRequests, `{}`}}}
+	client := newTranscriptionClient(t, fake)
+
+	_, err := client.TranscribeAudio(context.Background(), []byte("ogg bytes"), "audio/ogg")
+	if err == nil || !strings.Contains(err.Error(), "status 429") {
+		t.Errorf("Expected the rate limit error, got %v", err)
+	}
+	if n := fake.requests(); n != maxTranscribeAttempts {
+		t.Errorf("Expected %d attempts, got %d", maxTranscribeAttempts, n)
+	}
+}
+
+func TestTranscribeAudioRejectsEmptyResponse(t *testing.T) {
+	for _, body := range []string{`{"candidates": []}`, `{"candidates": [{"content": {"parts": [{"text": "  "}]}}]}`} {
+		fake := &fakeGemini{responses: []fakeResponse{{http.StatusOK, body}}}
+		client := newTranscriptionClient(t, fake)
+
+		if _, err := client.TranscribeAudio(context.Background(), []byte("ogg bytes"), "audio/ogg"); err == nil {
+			t.Errorf("Expected an error for %s", body)
+		}
+		if n := fake.requests(); n != 1 {
+			t.Errorf("Expected an empty answer not to be retried, got %d requests", n)
+		}
+	}
+}
+
+func TestTranscribeAudioStopsOnCancel(t *testing.T) {
+	fake := &fakeGemini{responses: []fakeResponse{{http.StatusServiceUnavailable, `{}`}}}
+	client := newTranscriptionClient(t, fake)
+	client.retryBackoff = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.TranscribeAudio(ctx, []byte("ogg bytes"), "audio/ogg")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to end the retries, got %v", err)
+	}
+	if n := fake.requests(); n != 1 {
+		t.Errorf("Expected 1 request before the deadline, got %d", n)
+	}
+}
+
+func TestGeminiModelFromEnv(t *testing.T) {
+	fake := &fakeGemini{responses: []fakeResponse{{http.StatusOK, `{"candidates": [{"content": {"parts": [{"text": "task"}]}}]}`}}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_URL", server.URL)
+
+	for _, tt := range []struct{ env, model string }{{"", defaultModel}, {"gemini-1.5-flash", "gemini-1.5-flash"}} {
+		t.Setenv("GEMINI_MODEL", tt.env)
+		client := NewClientWithHTTP(server.Client())
+		if _, err := client.TagTask(context.Background(), "Buy milk"); err != nil {
+			t.Fatalf("TagTask failed: %v", err)
+		}
+		if path := fake.paths[len(fake.paths)-1]; path != "/v1beta/models/"+tt.model+":generateContent" {
+			t.Errorf("GEMINI_MODEL=%q: expected model %s, got path %s", tt.env, tt.model, path)
+		}
+	}
+}
+
+func TestRequestDeadlineAbortsSlowServer(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_URL", server.URL)
+	client := NewClientWithHTTP(server.Client())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.TagTask(ctx, "Buy milk")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the deadline to abort the request, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the request to end at the deadline, took %v", elapsed)
+	}
+}
+
+func TestDefaultHTTPClientHasTimeout(t *testing.T) {
+	if client := NewClient(); client.httpClient.Timeout != defaultHTTPTimeout {
+		t.Errorf("Expected a %v timeout, got %v", defaultHTTPTimeout, client.httpClient.Timeout)
+	}
+}
+
+func TestDefaultAudioClientHasNoTimeout(t *testing.T) {
+	client := NewClient()
+	if client.audioClient.Timeout != 0 {
+		t.Errorf("Expected transcriptions to be bounded by their context only, got a %v timeout", client.audioClient.Timeout)
+	}
+	if transport := client.audioClient.Transport.(*http.Transport); transport.ResponseHeaderTimeout != 0 {
+		t.Errorf("Expected no response header timeout on transcriptions, got %v", transport.ResponseHeaderTimeout)
+	}
+}
@@ -0,0 +1,19 @@
+package gemini
+
+import (
+	"context"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+var _ llm.DateExtractor = (*Client)(nil)
+
+// ExtractDate asks for the date the task mentions, relative to now
+func (c *Client) ExtractDate(ctx context.Context, taskContent string, now time.Time) (string, error) {
+	text, err := c.generate(ctx, llm.DatePrompt(taskContent, now))
+	if err != nil {
+		return "", err
+	}
+	return llm.ParseDate(text, now)
+}
@@ -0,0 +1,18 @@
+package gemini
+
+import (
+	"context"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+var _ llm.Splitter = (*Client)(nil)
+
+// SplitTasks asks for the separate tasks a message lists
+func (c *Client) SplitTasks(ctx context.Context, text string) ([]string, error) {
+	answer, err := c.generate(ctx, llm.SplitPrompt(text))
+	if err != nil {
+		return nil, err
+	}
+	return llm.ParseSplit(answer)
+}
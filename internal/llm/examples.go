@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// defaultPromptLanguages names the languages entries are expected in without GEMINI_PROMPT_LANG
+const defaultPromptLanguages = "Russian or English"
+
+// tagExample is an entry shown to the model with the tag it should get
+type tagExample struct {
+	entry string
+	tag   string
+}
+
+// defaultTagExamples teach the tags on entries in both languages, most of all the Russian
+// ones an English prompt misreads
+var defaultTagExamples = []tagExample{
+	{"https://habr.com/ru/articles/812345/", TagLink},
+	{"сделать до завтра", TagDate},
+	{"сдать лабу по базам данных к пятнице", TagDate},
+	{"finish the report by friday", TagDate},
+	{"сегодня весь день думал о переезде, немного тревожно", TagJournal},
+	{"felt great after the morning run", TagJournal},
+	{"купить молоко", TagTask},
+	{"call the plumber", TagTask},
+}
+
+// tagSynonyms maps answers in other words or languages, mostly Russian, to the tag they mean
+var tagSynonyms = map[string]string{
+	"links":         TagLink,
+	"url":           TagLink,
+	"ссылка":        TagLink,
+	"ссылки":        TagLink,
+	"линк":          TagLink,
+	"journal entry": TagJournal,
+	"diary":         TagJournal,
+	"дневник":       TagJournal,
+	"журнал":        TagJournal,
+	"запись":        TagJournal,
+	"deadline":      TagDate,
+	"due date":      TagDate,
+	"дата":          TagDate,
+	"срок":          TagDate,
+	"дедлайн":       TagDate,
+	"todo":          TagTask,
+	"to-do":         TagTask,
+	"tasks":         TagTask,
+	"задача":        TagTask,
+	"задание":       TagTask,
+	"дело":          TagTask,
+}
+
+// promptLanguages returns the languages the prompts mention, from GEMINI_PROMPT_LANG
+func promptLanguages() string {
+	if languages := strings.TrimSpace(os.Getenv("GEMINI_PROMPT_LANG")); languages != "" {
+		return languages
+	}
+	return defaultPromptLanguages
+}
+
+// examplesCache keeps the parsed GEMINI_PROMPT_EXAMPLES, so a bad value is only reported
+// once and not with every prompt
+var examplesCache struct {
+	sync.Mutex
+	raw      string
+	examples []tagExample
+}
+
+// tagExamples returns the examples of the tag prompts: GEMINI_PROMPT_EXAMPLES when it's set,
+// as "entry=tag" pairs separated by semicolons, or the defaults
+func tagExamples() []tagExample {
+	raw := strings.TrimSpace(os.Getenv("GEMINI_PROMPT_EXAMPLES"))
+	if raw == "" {
+		return defaultTagExamples
+	}
+
+	examplesCache.Lock()
+	defer examplesCache.Unlock()
+	if examplesCache.raw != raw {
+		examplesCache.raw = raw
+		examplesCache.examples = parseTagExamples(raw)
+	}
+	if len(examplesCache.examples) == 0 {
+		return defaultTagExamples
+	}
+	return examplesCache.examples
+}
+
+// parseTagExamples parses "entry=tag; entry=tag", skipping pairs without a known tag
+func parseTagExamples(raw string) []tagExample {
+	var examples []tagExample
+	for _, pair := range strings.Split(raw, ";") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		// The tag follows the last =, entries may contain one
+		cut := strings.LastIndex(pair, "=")
+		if cut <= 0 {
+			log.Printf("Warning: Ignoring GEMINI_PROMPT_EXAMPLES entry %q, expected entry=tag", pair)
+			continue
+		}
+		entry, tag := strings.TrimSpace(pair[:cut]), strings.ToLower(strings.TrimSpace(pair[cut+1:]))
+		if !validTags[tag] {
+			log.Printf("Warning: Ignoring GEMINI_PROMPT_EXAMPLES entry %q, unknown tag %q", pair, tag)
+			continue
+		}
+		examples = append(examples, tagExample{entry, tag})
+	}
+	if len(examples) == 0 {
+		log.Printf("Warning: GEMINI_PROMPT_EXAMPLES has no valid entries, using the built-in examples")
+	}
+	return examples
+}
+
+// tagGuide is the part of the tag prompts explaining the tags, the languages entries may be
+// in and examples
+func tagGuide() string {
+	var b strings.Builder
+	b.WriteString(tagRules)
+	fmt.Fprintf(&b, "\n\nEntries may be written in %s, or another language. Judge an entry by its meaning in its own language, and always answer with the English tag word.\n\nExamples:\n", promptLanguages())
+	for _, example := range tagExamples() {
+		fmt.Fprintf(&b, "%q → %s\n", example.entry, example.tag)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,237 @@
+// Package llm defines the interfaces the bot and scheduler use for AI features,
+// so the provider (Gemini, a local Ollama model, or none) can be swapped.
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tagger classifies task entries into one of the known tags
+type Tagger interface {
+	TagTask(ctx context.Context, content string) (string, error)
+	// TagTasksBatch tags several entries with one request, returning tags in input order
+	TagTasksBatch(ctx context.Context, contents []string) ([]string, error)
+}
+
+// Transcriber turns audio, or the speech in a video, into text
+type Transcriber interface {
+	TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// Summarizer writes a short paragraph on the themes of a list of items, like task titles
+type Summarizer interface {
+	Summarize(ctx context.Context, items []string) (string, error)
+}
+
+// Provider is implemented by every LLM backend
+type Provider interface {
+	Tagger
+	Transcriber
+	Summarizer
+}
+
+// DateExtractor resolves the due date a task mentions, as YYYY-MM-DD
+type DateExtractor interface {
+	ExtractDate(ctx context.Context, content string, now time.Time) (string, error)
+}
+
+// Splitter breaks a message listing several tasks into their titles
+type Splitter interface {
+	SplitTasks(ctx context.Context, text string) ([]string, error)
+}
+
+// Checker is implemented by providers that can verify their configuration cheaply, such
+// as an API key, without generating anything
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// ErrNoDate is returned by ParseDate when the model found no date in the entry
+var ErrNoDate = errors.New("no date in the entry")
+
+// ErrNotSupported is returned when a provider can't perform an operation, e.g. Ollama transcription
+var ErrNotSupported = errors.New("operation not supported by this LLM provider")
+
+// Known tags, DefaultTag is used for anything the model returns that isn't recognized
+const (
+	TagLink    = "link"
+	TagJournal = "journal"
+	TagDate    = "date"
+	TagTask    = "task"
+
+	DefaultTag = TagTask
+)
+
+var validTags = map[string]bool{
+	TagLink:    true,
+	TagJournal: true,
+	TagDate:    true,
+	TagTask:    true,
+}
+
+// tagRules describes the tags to the model, shared by the single and batch prompts through
+// tagGuide
+const tagRules = `Rules:
+- If the entry is ONLY a URL/link (starts with http, https, or looks like a web link), respond with exactly: "link"
+- If the entry mentions thoughts, emotions, observations, feelings, reflections, or is a personal journal-style entry, respond with exactly: "journal"
+- If the entry mentions a deadline, date reference (like "today", "tomorrow", "next week", "23 october", "by friday", "due on", etc.) OR mentions university/college subjects, courses, homework, assignments, exams, labs, or academic tasks (including Software Engineering topics like highload, data analysis, algorithms, databases, or any subject related to ITMO University or software engineering studies), respond with exactly: "date"
+- If none of the above apply, respond with exactly: "task"`
+
+// TagPrompt builds the prompt asking for a single entry's tag
+func TagPrompt(content string) string {
+	return fmt.Sprintf(`Analyze the following task entry and categorize it with a single tag.
+
+%s
+
+Task entry: "%s"
+
+Respond with ONLY ONE WORD from: link, journal, date, or task`, tagGuide(), content)
+}
+
+// BatchTagPrompt builds the prompt asking for the tags of several numbered entries
+func BatchTagPrompt(contents []string) string {
+	var entries strings.Builder
+	for i, content := range contents {
+		fmt.Fprintf(&entries, "%d. %q\n", i+1, content)
+	}
+
+	return fmt.Sprintf(`Analyze each of the following numbered task entries and categorize each with a single tag.
+
+%s
+
+Task entries:
+%s
+Respond with one line per entry in the form "<number>. <tag>", using ONLY the words link, journal, date, or task`, tagGuide(), entries.String())
+}
+
+// SummaryPrompt builds the prompt asking for the themes of a list of items
+func SummaryPrompt(items []string) string {
+	var list strings.Builder
+	for _, item := range items {
+		fmt.Fprintf(&list, "- %s\n", item)
+	}
+
+	return fmt.Sprintf(`The following items are tasks from someone's week. Write one short paragraph, at most three sentences, on the themes they share. Respond with only the paragraph.
+
+%s`, list.String())
+}
+
+// DatePrompt builds the prompt asking for the date an entry mentions, resolved relative to
+// now in now's timezone
+func DatePrompt(content string, now time.Time) string {
+	return fmt.Sprintf(`Today is %s, %s. Find the date the following task entry is due, resolving relative references like "tomorrow", "next friday" or "23 october" from today. A date without a year is its next occurrence.
+
+The entry may be written in %s. Relative references in Russian work the same way: "сегодня" is today, "завтра" tomorrow, "послезавтра" the day after tomorrow, "в пятницу" or "к пятнице" the next friday, "через неделю" a week from today, "через 3 дня" three days from today, "на следующей неделе" next monday and "до 23 октября" the 23rd of october.
+
+Task entry: "%s"
+
+Respond with ONLY the date as YYYY-MM-DD, or exactly "none" if the entry mentions no date`, now.Format("Monday"), now.Format("2006-01-02"), promptLanguages(), content)
+}
+
+// MaxSplitTasks is the most tasks a message is split into, longer answers are taken for
+// a misread
+const MaxSplitTasks = 10
+
+// SplitPrompt builds the prompt asking for the separate tasks a message lists
+func SplitPrompt(text string) string {
+	return fmt.Sprintf(`The following message may list several separate tasks, like "buy milk, call dentist, renew passport". Split it into one short title per task, keeping the wording of the message.
+
+Message: "%s"
+
+Respond with ONLY a JSON array of strings, like ["buy milk", "call dentist"]`, text)
+}
+
+// ParseSplit parses a split answer into task titles. Answers that aren't a JSON array of
+// strings, or list no tasks or more than MaxSplitTasks, are an error.
+func ParseSplit(response string) ([]string, error) {
+	answer := strings.TrimSpace(response)
+	// Models like to wrap JSON in a code fence
+	answer = strings.TrimPrefix(answer, "```json")
+	answer = strings.Trim(strings.TrimSpace(answer), "`")
+
+	var entries []string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(answer)), &entries); err != nil {
+		return nil, fmt.Errorf("unexpected split answer %q: %w", response, err)
+	}
+
+	var titles []string
+	for _, entry := range entries {
+		if title := strings.TrimSpace(entry); title != "" {
+			titles = append(titles, title)
+		}
+	}
+	if len(titles) == 0 {
+		return nil, fmt.Errorf("split answer %q lists no tasks", response)
+	}
+	if len(titles) > MaxSplitTasks {
+		return nil, fmt.Errorf("split answer lists %d tasks, more than %d", len(titles), MaxSplitTasks)
+	}
+	return titles, nil
+}
+
+// maxDateDistance is how far from now an extracted date may be, further ones are taken
+// for a misread
+const maxDateDistance = 5 * 365 * 24 * time.Hour
+
+// ParseDate validates a date answer, returning ErrNoDate for "none" and an error for
+// anything that isn't a plausible YYYY-MM-DD date
+func ParseDate(response string, now time.Time) (string, error) {
+	answer := strings.Trim(strings.TrimSpace(response), "\"'`.*")
+	if strings.EqualFold(answer, "none") {
+		return "", ErrNoDate
+	}
+	date, err := time.ParseInLocation("2006-01-02", answer, now.Location())
+	if err != nil {
+		return "", fmt.Errorf("unexpected date answer %q", response)
+	}
+	if distance := date.Sub(now); distance > maxDateDistance || distance < -maxDateDistance {
+		return "", fmt.Errorf("date answer %s is too far from today", answer)
+	}
+	return answer, nil
+}
+
+// NormalizeTag cleans up a raw model answer, falling back to DefaultTag for unknown tags.
+// Quotes and punctuation around the answer are ignored, and answers in other words or
+// languages, like "ссылка", are mapped to the tag they mean.
+func NormalizeTag(raw string) string {
+	tag := strings.ToLower(strings.TrimSpace(raw))
+	tag = strings.Trim(tag, "\"'`.,:;*!?()[]«»“”„ \t\n")
+	if validTags[tag] {
+		return tag
+	}
+	if synonym, ok := tagSynonyms[tag]; ok {
+		return synonym
+	}
+	return DefaultTag
+}
+
+// ParseBatchTags parses a numbered batch answer into n tags. Entries missing from the
+// answer are an error, unknown tags are normalized to DefaultTag.
+func ParseBatchTags(response string, n int) ([]string, error) {
+	tags := make([]string, n)
+
+	for _, line := range strings.Split(response, "\n") {
+		number, tag, ok := strings.Cut(strings.TrimSpace(line), ".")
+		if !ok {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSpace(number))
+		if err != nil || index < 1 || index > n {
+			continue
+		}
+		tags[index-1] = NormalizeTag(tag)
+	}
+
+	for i, tag := range tags {
+		if tag == "" {
+			return nil, fmt.Errorf("no tag for entry %d in batch response", i+1)
+		}
+	}
+	return tags, nil
+}
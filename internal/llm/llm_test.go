@@ -0,0 +1,307 @@
+package llm_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/ollama"
+)
+
+// fakeModel holds the answer a fake provider server returns and the last prompt it received
+type fakeModel struct {
+	mu         sync.Mutex
+	answer     string
+	lastPrompt string
+}
+
+func (f *fakeModel) reply(prompt string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastPrompt = prompt
+	return f.answer
+}
+
+func (f *fakeModel) setAnswer(answer string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.answer = answer
+}
+
+// newFakeGemini serves the generateContent API and returns a client pointed at it
+func newFakeGemini(t *testing.T, model *fakeModel) llm.Provider {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req gemini.GeminiRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		answer := model.reply(req.Contents[0].Parts[0].Text)
+
+		json.NewEncoder(w).Encode(gemini.GeminiResponse{Candidates: []gemini.Candidate{{
+			Content: gemini.ContentResponse{Parts: []gemini.PartResponse{{Text: answer}}},
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("GEMINI_API_KEY", "test-key")
+	t.Setenv("GEMINI_API_URL", server.URL)
+	return gemini.NewClient()
+}
+
+// newFakeOllama serves the /api/generate API and returns a client pointed at it
+func newFakeOllama(t *testing.T, model *fakeModel) llm.Provider {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/generate" {
+			http.NotFound(w, r)
+			return
+		}
+		var req ollama.GenerateRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Stream {
+			t.Error("Ollama requests must disable streaming")
+		}
+		json.NewEncoder(w).Encode(ollama.GenerateResponse{Model: req.Model, Response: model.reply(req.Prompt), Done: true})
+	}))
+	t.Cleanup(server.Close)
+
+	t.Setenv("OLLAMA_URL", server.URL)
+	t.Setenv("OLLAMA_MODEL", "test-model")
+	return ollama.NewClient()
+}
+
+var providers = []struct {
+	name string
+	new  func(*testing.T, *fakeModel) llm.Provider
+}{
+	{"gemini", newFakeGemini},
+	{"ollama", newFakeOllama},
+}
+
+func TestTagValidation(t *testing.T) {
+	cases := []struct {
+		answer   string
+		expected string
+	}{
+		{"link", llm.TagLink},
+		{"Journal\n", llm.TagJournal},
+		{`"date"`, llm.TagDate},
+		{"task.", llm.TagTask},
+		{"  LINK  ", llm.TagLink},
+		{"banana", llm.DefaultTag},
+		{"this is a link", llm.DefaultTag},
+	}
+
+	for _, provider := range providers {
+		t.Run(provider.name, func(t *testing.T) {
+			model := &fakeModel{}
+			client := provider.new(t, model)
+
+			for _, c := range cases {
+				model.setAnswer(c.answer)
+				tag, err := client.TagTask(context.Background(), "Buy milk")
+				if err != nil {
+					t.Fatalf("TagTask failed: %v", err)
+				}
+				if tag != c.expected {
+					t.Errorf("Answer %q: expected tag %s, got %s", c.answer, c.expected, tag)
+				}
+			}
+
+			if !strings.Contains(model.lastPrompt, `"Buy milk"`) {
+				t.Errorf("Prompt should contain the task entry, got %s", model.lastPrompt)
+			}
+		})
+	}
+}
+
+func TestTagTasksBatch(t *testing.T) {
+	for _, provider := range providers {
+		t.Run(provider.name, func(t *testing.T) {
+			model := &fakeModel{answer: "1. link\n2. Journal\n3. banana"}
+			client := provider.new(t, model)
+
+			tags, err := client.TagTasksBatch(context.Background(), []string{"https://example.com", "Felt great today", "Buy milk"})
+			if err != nil {
+				t.Fatalf("TagTasksBatch failed: %v", err)
+			}
+			expected := []string{llm.TagLink, llm.TagJournal, llm.DefaultTag}
+			for i := range expected {
+				if tags[i] != expected[i] {
+					t.Errorf("Entry %d: expected %s, got %s", i+1, expected[i], tags[i])
+				}
+			}
+
+			// A response missing entries is rejected rather than misaligned
+			model.setAnswer("1. link")
+			if _, err := client.TagTasksBatch(context.Background(), []string{"a", "b"}); err == nil {
+				t.Error("Expected an error for an incomplete batch response")
+			}
+		})
+	}
+}
+
+func TestNormalizeTranslatedTags(t *testing.T) {
+	cases := []struct {
+		answer   string
+		expected string
+	}{
+		{"«Ссылка».", llm.TagLink},
+		{"Дневник!", llm.TagJournal},
+		{"дата", llm.TagDate},
+		{"**date**", llm.TagDate},
+		{"Задача\n", llm.TagTask},
+		{"“deadline”", llm.TagDate},
+		{"(journal)", llm.TagJournal},
+		{"banana", llm.DefaultTag},
+		{"это ссылка", llm.DefaultTag},
+	}
+	for _, c := range cases {
+		if tag := llm.NormalizeTag(c.answer); tag != c.expected {
+			t.Errorf("Answer %q: expected tag %s, got %s", c.answer, c.expected, tag)
+		}
+	}
+}
+
+func TestTagPromptExamples(t *testing.T) {
+	prompt := llm.TagPrompt("купить хлеб")
+	for _, want := range []string{"Russian or English", `"сделать до завтра" → date`, `"купить хлеб"`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the default prompt to contain %q, got %s", want, prompt)
+		}
+	}
+
+	t.Setenv("GEMINI_PROMPT_LANG", "German")
+	t.Setenv("GEMINI_PROMPT_EXAMPLES", "bis morgen erledigen=date; Tagebuch=journal; kaputt=banana")
+	prompt = llm.BatchTagPrompt([]string{"Milch kaufen"})
+	for _, want := range []string{"German", `"bis morgen erledigen" → date`, `"Tagebuch" → journal`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("Expected the configured prompt to contain %q, got %s", want, prompt)
+		}
+	}
+	for _, unwanted := range []string{"сделать до завтра", "kaputt"} {
+		if strings.Contains(prompt, unwanted) {
+			t.Errorf("Expected the configured prompt to leave out %q, got %s", unwanted, prompt)
+		}
+	}
+}
+
+func TestOllamaTranscriptionNotSupported(t *testing.T) {
+	client := newFakeOllama(t, &fakeModel{})
+	if _, err := client.TranscribeAudio(context.Background(), []byte("audio"), "audio/ogg"); !errors.Is(err, llm.ErrNotSupported) {
+		t.Errorf("Expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestExtractDate(t *testing.T) {
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC) // A Wednesday
+	cases := []struct {
+		answer  string
+		want    string
+		wantErr error
+	}{
+		{"2026-10-15", "2026-10-15", nil},
+		{" \"2026-10-16\".\n", "2026-10-16", nil},
+		{"none", "", llm.ErrNoDate},
+		{"next friday", "", nil},
+		{"2099-01-01", "", nil},
+	}
+
+	for _, p := range providers {
+		model := &fakeModel{}
+		provider := p.new(t, model)
+		extractor, ok := provider.(llm.DateExtractor)
+		if !ok {
+			t.Fatalf("%s: Expected the provider to extract dates", p.name)
+		}
+
+		for _, c := range cases {
+			model.setAnswer(c.answer)
+			got, err := extractor.ExtractDate(context.Background(), "Dentist tomorrow", now)
+			switch {
+			case c.want != "" && (err != nil || got != c.want):
+				t.Errorf("%s: Expected %s for %q, got %q, %v", p.name, c.want, c.answer, got, err)
+			case c.want == "" && err == nil:
+				t.Errorf("%s: Expected an error for %q, got %q", p.name, c.answer, got)
+			case c.wantErr != nil && !errors.Is(err, c.wantErr):
+				t.Errorf("%s: Expected %v for %q, got %v", p.name, c.wantErr, c.answer, err)
+			}
+		}
+
+		if !strings.Contains(model.lastPrompt, "2026-10-14") || !strings.Contains(model.lastPrompt, "Wednesday") {
+			t.Errorf("%s: Expected the prompt to give today's date, got %q", p.name, model.lastPrompt)
+		}
+	}
+}
+
+func TestSplitTasks(t *testing.T) {
+	cases := []struct {
+		name   string
+		answer string
+		want   []string
+	}{
+		{"json array", `["buy milk", "call dentist", " renew passport "]`, []string{"buy milk", "call dentist", "renew passport"}},
+		{"code fence", "```json\n[\"buy milk\", \"call dentist\"]\n```", []string{"buy milk", "call dentist"}},
+		{"malformed json", `["buy milk", "call dentist"`, nil},
+		{"not a list of strings", `{"tasks": ["buy milk"]}`, nil},
+		{"empty list", `[" "]`, nil},
+		{"oversized list", `["1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11"]`, nil},
+	}
+
+	for _, p := range providers {
+		model := &fakeModel{}
+		provider := p.new(t, model)
+		splitter, ok := provider.(llm.Splitter)
+		if !ok {
+			t.Fatalf("%s: Expected the provider to split messages", p.name)
+		}
+
+		for _, c := range cases {
+			model.setAnswer(c.answer)
+			got, err := splitter.SplitTasks(context.Background(), "buy milk, call dentist, renew passport")
+			if c.want == nil {
+				if err == nil {
+					t.Errorf("%s, %s: Expected an error, got %q", p.name, c.name, got)
+				}
+				continue
+			}
+			if err != nil || strings.Join(got, "|") != strings.Join(c.want, "|") {
+				t.Errorf("%s, %s: Expected %q, got %q, %v", p.name, c.name, c.want, got, err)
+			}
+		}
+
+		if !strings.Contains(model.lastPrompt, "renew passport") {
+			t.Errorf("%s: Expected the prompt to include the message, got %q", p.name, model.lastPrompt)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	for _, p := range providers {
+		model := &fakeModel{answer: "  A week of errands and paperwork.\n"}
+		provider := p.new(t, model)
+
+		summary, err := provider.Summarize(context.Background(), []string{"Renew passport", "Pay the electricity bill"})
+		if err != nil || summary != "A week of errands and paperwork." {
+			t.Errorf("%s: Expected the trimmed answer, got %q, %v", p.name, summary, err)
+		}
+		if !strings.Contains(model.lastPrompt, "- Renew passport\n- Pay the electricity bill") {
+			t.Errorf("%s: Expected the prompt to list the items, got %q", p.name, model.lastPrompt)
+		}
+
+		// Nothing to summarize doesn't call the model
+		model.lastPrompt = ""
+		if summary, err := provider.Summarize(context.Background(), nil); err != nil || summary != "" || model.lastPrompt != "" {
+			t.Errorf("%s: Expected no summary without items, got %q, %v", p.name, summary, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package lru provides a size-bounded map with optional entry expiry, for in-memory state
+// that would otherwise grow for the lifetime of the process
+package lru
+
+import (
+	"container/list"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache is a map holding at most a fixed number of entries. Adding to a full cache evicts
+// the least recently used entry. Entries older than the TTL are treated as missing and
+// dropped when found. It is safe for concurrent use.
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration // Zero disables expiry
+	order    *list.List    // Most recently used first
+	items    map[K]*list.Element
+	now      func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New creates a cache holding up to capacity entries, each expiring ttl after it was added
+func New[K comparable, V any](capacity int, ttl time.Duration) *Cache[K, V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		items:    make(map[K]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get returns the value for key and marks it as recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+
+	e := element.Value.(*entry[K, V])
+	if c.expired(e) {
+		c.removeElement(element)
+		return zero, false
+	}
+
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// Add sets the value for key, restarting its TTL, and evicts entries over the capacity
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = c.now().Add(c.ttl)
+	}
+
+	if element, ok := c.items[key]; ok {
+		e := element.Value.(*entry[K, V])
+		e.value = value
+		e.expires = expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+
+	for c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+	}
+	// Expired entries at the cold end are dropped early
+	for back := c.order.Back(); back != nil && c.expired(back.Value.(*entry[K, V])); back = c.order.Back() {
+		c.removeElement(back)
+	}
+}
+
+// Remove deletes key from the cache
+func (c *Cache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+// Purge deletes every entry
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = make(map[K]*list.Element)
+}
+
+// Len returns the number of entries held in memory, including expired ones not yet dropped
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Cap returns the maximum number of entries
+func (c *Cache[K, V]) Cap() int {
+	return c.capacity
+}
+
+func (c *Cache[K, V]) expired(e *entry[K, V]) bool {
+	return c.ttl > 0 && !c.now().Before(e.expires)
+}
+
+// removeElement deletes an entry. Callers must hold c.mu.
+func (c *Cache[K, V]) removeElement(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry[K, V]).key)
+}
+
+// Size is the current and maximum size of a registered cache
+type Size struct {
+	Name string `json:"name"`
+	Len  int    `json:"len"`
+	Cap  int    `json:"cap"`
+}
+
+// Sizer is implemented by every Cache
+type Sizer interface {
+	Len() int
+	Cap() int
+}
+
+var registry = struct {
+	mu     sync.Mutex
+	caches map[string]Sizer
+}{caches: make(map[string]Sizer)}
+
+// Register makes a cache's size visible through Sizes. Registering a name again replaces
+// the previous cache, so short-lived owners don't accumulate.
+func Register(name string, cache Sizer) {
+	registry.mu.Lock()
+	registry.caches[name] = cache
+	registry.mu.Unlock()
+}
+
+// Sizes returns the sizes of all registered caches, sorted by name
+func Sizes() []Size {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	sizes := make([]Size, 0, len(registry.caches))
+	for name, cache := range registry.caches {
+		sizes = append(sizes, Size{Name: name, Len: cache.Len(), Cap: cache.Cap()})
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].Name < sizes[j].Name })
+	return sizes
+}
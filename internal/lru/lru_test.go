@@ -0,0 +1,97 @@
+package lru
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := New[string, int](2, 0)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	cache.Get("a") // b is now the least recently used
+	cache.Add("c", 3)
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Errorf("Expected a=1, got %d, %v", v, ok)
+	}
+	if v, ok := cache.Get("c"); !ok || v != 3 {
+		t.Errorf("Expected c=3, got %d, %v", v, ok)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	cache := New[string, int](10, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.Add("a", 1)
+	now = now.Add(59 * time.Second)
+	if _, ok := cache.Get("a"); !ok {
+		t.Error("Entry should still be fresh")
+	}
+
+	// Adding again restarts the TTL
+	cache.Add("a", 2)
+	now = now.Add(59 * time.Second)
+	if v, ok := cache.Get("a"); !ok || v != 2 {
+		t.Errorf("Expected a=2 after re-adding, got %d, %v", v, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := cache.Get("a"); ok {
+		t.Error("Entry should have expired")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Expired entry should be dropped, %d left", cache.Len())
+	}
+}
+
+func TestStaysAtCapacity(t *testing.T) {
+	cache := New[int, string](1000, time.Hour)
+	for i := 0; i < 100000; i++ {
+		cache.Add(i, fmt.Sprint(i))
+	}
+
+	if cache.Len() != 1000 || len(cache.items) != 1000 {
+		t.Errorf("Expected 1000 entries, got %d in the list and %d in the map", cache.Len(), len(cache.items))
+	}
+	if _, ok := cache.Get(99999); !ok {
+		t.Error("Newest entry should be kept")
+	}
+}
+
+func TestPurge(t *testing.T) {
+	cache := New[string, int](10, 0)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+	cache.Purge()
+
+	if _, ok := cache.Get("a"); ok || cache.Len() != 0 {
+		t.Errorf("Expected an empty cache, %d left", cache.Len())
+	}
+	cache.Add("c", 3)
+	if v, ok := cache.Get("c"); !ok || v != 3 {
+		t.Errorf("Expected c=3 after purging, got %d, %v", v, ok)
+	}
+}
+
+func TestSizes(t *testing.T) {
+	cache := New[int, int](5, 0)
+	cache.Add(1, 1)
+	Register("test.sizes", cache)
+
+	for _, size := range Sizes() {
+		if size.Name == "test.sizes" {
+			if size.Len != 1 || size.Cap != 5 {
+				t.Errorf("Expected len 1 and cap 5, got %+v", size)
+			}
+			return
+		}
+	}
+	t.Error("Registered cache missing from Sizes")
+}
@@ -0,0 +1,186 @@
+// Package metrics exposes Prometheus metrics for task creation, the Notion and Gemini
+// APIs and the scheduler. Unlike the usage counters, which are rolled up into daily totals
+// for the weekly summary, these are scraped live and reset on restart.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Result label values
+const (
+	resultOK    = "ok"
+	resultError = "error"
+)
+
+var registry = prometheus.NewRegistry()
+
+var (
+	notionRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "notion_api_request_duration_seconds",
+		Help:    "Duration of Notion API requests by method, resource and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "resource", "code"})
+
+	taskCreates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "task_create_total",
+		Help: "Pages created in Notion by database type and result.",
+	}, []string{"db_type", "result"})
+
+	taskSaveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "task_save_duration_seconds",
+		Help:    "Time from the reaction or /save to the task being created in Notion, including retries.",
+		Buckets: []float64{0.25, 0.5, 1, 2, 5, 10, 20, 40, 60},
+	}, []string{"result"})
+
+	geminiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gemini_request_duration_seconds",
+		Help:    "Duration of Gemini API calls by operation and result.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 40},
+	}, []string{"operation", "result"})
+
+	geminiTags = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "gemini_tag_results_total",
+		Help: "Tags Gemini assigned to tasks.",
+	}, []string{"tag"})
+
+	schedulerNotifications = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "scheduler_notifications_total",
+		Help: "Tasks reported by the daily check by kind.",
+	}, []string{"kind"})
+
+	pendingDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pending_tasks_dropped_total",
+		Help: "Messages dropped while waiting for their reaction, evicted over the per-user cap or expired.",
+	}, []string{"reason"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		notionRequestDuration,
+		taskCreates,
+		taskSaveDuration,
+		geminiRequestDuration,
+		geminiTags,
+		schedulerNotifications,
+		pendingDropped,
+		lruCollector{},
+	)
+}
+
+// Handler serves the metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// result returns the result label for err
+func result(err error) string {
+	if err != nil {
+		return resultError
+	}
+	return resultOK
+}
+
+// RecordTaskCreate counts a page creation in the database of dbType
+func RecordTaskCreate(dbType string, err error) {
+	taskCreates.WithLabelValues(dbType, result(err)).Inc()
+}
+
+// ObserveTaskSave records how long saving a message as a task took
+func ObserveTaskSave(duration time.Duration, err error) {
+	taskSaveDuration.WithLabelValues(result(err)).Observe(duration.Seconds())
+}
+
+// RecordPendingDropped counts n pending tasks dropped for reason, evicted or expired
+func RecordPendingDropped(reason string, n int) {
+	pendingDropped.WithLabelValues(reason).Add(float64(n))
+}
+
+// ObserveGemini records the duration of a Gemini call, operation names the API method
+func ObserveGemini(operation string, duration time.Duration, err error) {
+	geminiRequestDuration.WithLabelValues(operation, result(err)).Observe(duration.Seconds())
+}
+
+// RecordTag counts a tag Gemini assigned
+func RecordTag(tag string) {
+	geminiTags.WithLabelValues(tag).Inc()
+}
+
+// RecordNotifications counts n tasks the daily check reported as kind
+func RecordNotifications(kind string, n int) {
+	if n > 0 {
+		schedulerNotifications.WithLabelValues(kind).Add(float64(n))
+	}
+}
+
+var (
+	lruEntries  = prometheus.NewDesc("lru_cache_entries", "Entries held by an in-memory cache.", []string{"cache"}, nil)
+	lruCapacity = prometheus.NewDesc("lru_cache_capacity", "Entries an in-memory cache holds before evicting.", []string{"cache"}, nil)
+)
+
+// lruCollector reports the sizes of the registered caches when scraped, caches register
+// while the bot starts so the set isn't known up front
+type lruCollector struct{}
+
+func (lruCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lruEntries
+	ch <- lruCapacity
+}
+
+func (lruCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, size := range lru.Sizes() {
+		ch <- prometheus.MustNewConstMetric(lruEntries, prometheus.GaugeValue, float64(size.Len), size.Name)
+		ch <- prometheus.MustNewConstMetric(lruCapacity, prometheus.GaugeValue, float64(size.Cap), size.Name)
+	}
+}
+
+// notionTransport times the requests made through an http.RoundTripper
+type notionTransport struct {
+	base http.RoundTripper
+}
+
+func (t *notionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	notionRequestDuration.WithLabelValues(req.Method, notionResource(req.URL.Path), code).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// NotionTransport wraps base (http.DefaultTransport when nil) to time Notion calls
+func NotionTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &notionTransport{base: base}
+}
+
+// notionResource returns the kind of object a Notion API path addresses, like pages or
+// databases, keeping IDs out of the labels
+func notionResource(path string) string {
+	parts := strings.Split(strings.TrimPrefix(path, "/v1/"), "/")
+	resource := parts[0]
+	if len(parts) >= 3 {
+		// /v1/databases/<id>/query, /v1/blocks/<id>/children
+		resource += "/" + parts[2]
+	}
+	switch resource {
+	case "pages", "databases", "databases/query", "blocks", "blocks/children", "search", "users", "comments":
+		return resource
+	}
+	return "other"
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNotionResource(t *testing.T) {
+	tests := map[string]string{
+		"/v1/pages":                       "pages",
+		"/v1/pages/abc-123":               "pages",
+		"/v1/databases/abc/query":         "databases/query",
+		"/v1/databases/abc":               "databases",
+		"/v1/blocks/abc/children":         "blocks/children",
+		"/v1/search":                      "search",
+		"/v1/pages/abc/properties/title":  "other",
+		"/v1/something-new/abc/elsewhere": "other",
+	}
+	for path, want := range tests {
+		if got := notionResource(path); got != want {
+			t.Errorf("%s: expected %s, got %s", path, want, got)
+		}
+	}
+}
+
+func TestNotionTransportRecordsDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NotionTransport(nil)}
+	resp, err := client.Post(server.URL+"/v1/databases/tasks-db/query", "application/json", nil)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Histograms are compared through their exposition, the count line has the labels
+	want := `notion_api_request_duration_seconds_count{code="401",method="POST",resource="databases/query"} 1`
+	if !strings.Contains(scrape(t), want) {
+		t.Errorf("Expected %q in the metrics", want)
+	}
+}
+
+func TestRecordTaskCreate(t *testing.T) {
+	before := testutil.ToFloat64(taskCreates.WithLabelValues("tasks", "error"))
+	RecordTaskCreate("tasks", errors.New("unauthorized"))
+	RecordTaskCreate("tasks", nil)
+
+	if got := testutil.ToFloat64(taskCreates.WithLabelValues("tasks", "error")); got != before+1 {
+		t.Errorf("Expected %v failed creates, got %v", before+1, got)
+	}
+}
+
+func TestRecordNotificationsSkipsZero(t *testing.T) {
+	RecordNotifications("link", 0)
+	if strings.Contains(scrape(t), `scheduler_notifications_total{kind="link"}`) {
+		t.Error("Expected no series for a kind without notifications")
+	}
+	RecordNotifications("link", 3)
+	if got := testutil.ToFloat64(schedulerNotifications.WithLabelValues("link")); got != 3 {
+		t.Errorf("Expected 3 notifications, got %v", got)
+	}
+}
+
+func TestHandlerReportsCacheSizes(t *testing.T) {
+	cache := lru.New[int, bool](5, 0)
+	lru.Register("metrics_test.ids", cache)
+	cache.Add(1, true)
+	cache.Add(2, true)
+
+	metrics := scrape(t)
+	for _, want := range []string{
+		`lru_cache_entries{cache="metrics_test.ids"} 2`,
+		`lru_cache_capacity{cache="metrics_test.ids"} 5`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("Expected %q in the metrics", want)
+		}
+	}
+}
+
+// scrape returns the metrics as the endpoint serves them
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	return rec.Body.String()
+}
@@ -0,0 +1,41 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jomei/notionapi"
+)
+
+// HasDatabase reports whether a database ID is configured for dbType
+func (c *Client) HasDatabase(dbType string) bool {
+	return c.getDbIDForType(dbType) != ""
+}
+
+// CheckDatabase fetches the database configured for dbType, catching a wrong ID or a
+// database not shared with the integration before it's used. It returns the title.
+func (c *Client) CheckDatabase(ctx context.Context, dbType string) (string, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return "", fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	db, err := c.client.Database.Get(ctx, notionapi.DatabaseID(dbID))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s database %s: %w", dbType, dbID, err)
+	}
+	title := ""
+	for _, text := range db.Title {
+		title += text.PlainText
+	}
+	return title, nil
+}
+
+// CheckToken makes sure the API token is accepted, returning the integration's name
+func (c *Client) CheckToken(ctx context.Context) (string, error) {
+	me, err := c.client.User.Me(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify the Notion token: %w", err)
+	}
+	return me.Name, nil
+}
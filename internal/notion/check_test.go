@@ -0,0 +1,35 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCheckDatabase(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if path == "/v1/databases/tasks-db" {
+			return http.StatusOK, `{"object": "database", "id": "tasks-db", "title": [{"type": "text", "plain_text": "Tasks"}], "properties": {}}`
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find database"}`
+	}}
+	client := newTestClient(fake)
+
+	title, err := client.CheckDatabase(context.Background(), "tasks")
+	if err != nil || title != "Tasks" {
+		t.Errorf("Expected the tasks database to pass with its title, got %q, %v", title, err)
+	}
+
+	if _, err := client.CheckDatabase(context.Background(), "journal"); err == nil || !strings.Contains(err.Error(), "journal-db") {
+		t.Errorf("Expected the unknown journal database to fail naming its ID, got %v", err)
+	}
+
+	client.notesDbID = ""
+	if client.HasDatabase("notes") {
+		t.Error("Expected notes without an ID not to be configured")
+	}
+	if _, err := client.CheckDatabase(context.Background(), "notes"); err == nil {
+		t.Error("Expected an error for a database that isn't configured")
+	}
+}
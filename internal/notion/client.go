@@ -2,13 +2,21 @@ package notion
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+	"github.com/numero_quadro/notion-mini-app/internal/usage"
 )
 
 // ButtonProperty represents a Notion button property
@@ -44,16 +52,88 @@ type Task struct {
 }
 
 type Client struct {
-	client        *notionapi.Client
-	taskDbID      string
-	notesDbID     string
-	journalDbID   string
-	projectsDbID  string
+	client       *notionapi.Client
+	apiToken     string
+	rawHTTP      *http.Client // Requests the library can't decode fully, nil when NewClient got options
+	taskDbID     string
+	notesDbID    string
+	journalDbID  string
+	projectsDbID string
+	databases    map[string]string // Named databases of NOTION_DATABASES, by lowercased name
+
+	// cacheMu guards the schema caches below, which handlers, the scheduler and background
+	// bot commands use concurrently
+	cacheMu       sync.RWMutex
 	dbCache       map[string]map[string]notionapi.PropertyConfig
 	dbCacheExpiry map[string]time.Time
+	titleKeys     map[string]string // Discovered title property name per database ID
+	guessed       map[string]bool   // Database IDs whose cached schema was guessed from a page
+	refreshAfter  map[string]time.Time
+	statusCache   map[string]statusOptionsEntry // Options of status properties per database ID
+
+	schemaHook func(dbID, dbType string, properties map[string]notionapi.PropertyConfig)
+
+	mentionMu    sync.Mutex
+	mentionNames *lru.Cache[string, string] // Resolved mention names by "user:<id>" or "page:<id>"
+
+	countsMu     sync.Mutex
+	counts       *Counts
+	countsExpiry time.Time
+
+	journalMu sync.Mutex // Serializes finding or creating the daily journal page
+
+	projectsMu     sync.Mutex
+	projectIDs     map[string]string // Project page IDs by lowercased name, for relation values
+	projectsExpiry time.Time
+
+	usersMu     sync.Mutex
+	users       []User // People of the workspace, for people values given by email
+	usersExpiry time.Time
+
+	titleMaxLength  int            // Longer single-line texts are split into title and body
+	allowNewOptions bool           // Unknown select options are created instead of rejected (ALLOW_NEW_OPTIONS)
+	dateOrder       dateOrder      // How ambiguous numeric dates are read (DATE_ORDER)
+	location        *time.Location // Where dates with a time but no offset are read (TZ), nil is time.Local
+
+	defaultProperties map[string]map[string]interface{} // Set on every new page, by database type
 }
 
-func NewClient() *Client {
+// databaseTypes are the kinds of databases a client can be configured with
+var databaseTypes = []string{"tasks", "notes", "journal", "projects"}
+
+// IsDatabaseType reports whether dbType names a kind of database, like "tasks"
+func IsDatabaseType(dbType string) bool {
+	for _, known := range databaseTypes {
+		if dbType == known {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultTitleKey is the title property name Notion uses for new English databases
+const defaultTitleKey = "Name"
+
+const (
+	// schemaCacheTTL is how long a fetched database schema is reused
+	schemaCacheTTL = 10 * time.Minute
+	// guessedSchemaCacheTTL is shorter, the full fetch often works again soon after failing
+	guessedSchemaCacheTTL = time.Minute
+	// projectNamesCacheTTL is how long project names are resolved without asking Notion
+	projectNamesCacheTTL = 5 * time.Minute
+)
+
+// notionAPIVersion is the Notion-Version every request is sent with. Requests and
+// responses are shaped by it, so it's only raised after checking Notion's changelog.
+const notionAPIVersion = "2022-06-28"
+
+// errButtonProperty is the library error for databases with button properties
+const errButtonProperty = "unsupported property type: button"
+
+// NewClient creates a client configured from the environment. Calls are counted for the
+// usage summary. Options are passed through to the underlying notionapi client, e.g.
+// notionapi.WithHTTPClient in tests.
+func NewClient(opts ...notionapi.ClientOption) *Client {
 	apiToken := os.Getenv("NOTION_API_KEY")
 	taskDbID := os.Getenv("NOTION_TASKS_DATABASE_ID")
 	notesDbID := os.Getenv("NOTION_NOTES_DATABASE_ID")
@@ -84,17 +164,68 @@ func NewClient() *Client {
 		log.Printf("WARNING: NOTION_PROJECTS_DATABASE_ID environment variable is not set")
 	}
 
-	// Create standard Notion client
-	client := notionapi.NewClient(notionapi.Token(apiToken))
+	// NOTION_DATABASES wins over the variables of each database
+	databases := databasesFromEnv()
+	for name, id := range databases {
+		switch name {
+		case "tasks":
+			taskDbID = id
+		case "notes":
+			notesDbID = id
+		case "journal":
+			journalDbID = id
+		case "projects":
+			projectsDbID = id
+		}
+	}
+
+	client := newClient(apiToken, opts...)
+	client.taskDbID = taskDbID
+	client.notesDbID = notesDbID
+	client.journalDbID = journalDbID
+	client.projectsDbID = projectsDbID
+	client.databases = databases
+	client.defaultProperties = defaultPropertiesFromEnv()
+	return client
+}
+
+// NewWorkspaceClient creates a client for another workspace than the environment's, with
+// its own token and tasks and notes databases. Journal and project features are off and
+// the environment's default properties aren't applied, they describe other databases.
+func NewWorkspaceClient(apiToken, taskDbID, notesDbID string, opts ...notionapi.ClientOption) *Client {
+	client := newClient(apiToken, opts...)
+	client.taskDbID = taskDbID
+	client.notesDbID = notesDbID
+	return client
+}
+
+// newClient creates a client without databases configured
+func newClient(apiToken string, opts ...notionapi.ClientOption) *Client {
+	// Create standard Notion client. Rate limiting and 429 retries happen in the transport,
+	// the library's own retry would resend requests without their body.
+	transport := newRateLimitedTransport(usage.NotionTransport(metrics.NotionTransport(tracing.Transport(nil, "notion"))), rpsFromEnv())
+	httpClient := &http.Client{Transport: transport}
+	// Options replacing the HTTP client can't be inspected, raw requests are only made
+	// through the default one
+	var rawHTTP *http.Client
+	if len(opts) == 0 {
+		rawHTTP = httpClient
+	}
+	opts = append([]notionapi.ClientOption{notionapi.WithHTTPClient(httpClient), notionapi.WithRetry(1), notionapi.WithVersion(notionAPIVersion)}, opts...)
+	client := notionapi.NewClient(notionapi.Token(apiToken), opts...)
 
 	return &Client{
 		client:        client,
-		taskDbID:      taskDbID,
-		notesDbID:     notesDbID,
-		journalDbID:   journalDbID,
-		projectsDbID:  projectsDbID,
+		apiToken:      apiToken,
+		rawHTTP:       rawHTTP,
 		dbCache:       make(map[string]map[string]notionapi.PropertyConfig),
 		dbCacheExpiry: make(map[string]time.Time),
+		titleKeys:     make(map[string]string),
+
+		titleMaxLength:  titleMaxLengthFromEnv(),
+		allowNewOptions: allowNewOptionsFromEnv(),
+		dateOrder:       dateOrderFromEnv(),
+		location:        dateLocationFromEnv(),
 	}
 }
 
@@ -114,18 +245,23 @@ func (c *Client) GetProjectsDatabaseID() string {
 	return c.projectsDbID
 }
 
-func (c *Client) CreateTask(ctx context.Context, title string, properties map[string]interface{}, dbType string) (string, error) {
-	dbID := c.getDbIDForType(dbType)
-	log.Printf("Creating task in %s database: %s with properties: %v", dbType, title, properties)
-
-	if title == "" {
-		return "", fmt.Errorf("task title cannot be empty")
-	}
+// CreatePlan is the page creation request CreateTask would send to Notion, together with
+// diagnostics about how the supplied properties were handled. It never contains credentials.
+type CreatePlan struct {
+	DatabaseID string                       `json:"database_id"`
+	DbType     string                       `json:"db_type"`
+	Request    *notionapi.PageCreateRequest `json:"request"`
+	Warnings   []string                     `json:"warnings,omitempty"`
+	Skipped    []string                     `json:"skipped_properties,omitempty"`
+}
 
-	if dbID == "" {
-		return "", fmt.Errorf("database ID for %s not configured", dbType)
-	}
+func (c *Client) CreateTask(ctx context.Context, title string, properties map[string]interface{}, dbType string) (string, error) {
+	return c.CreateTaskWithContent(ctx, title, "", properties, dbType)
+}
 
+// CreateTaskWithContent creates a page like CreateTask, adding content as paragraphs of its
+// body. Use SplitContent to get the title and content of a long message.
+func (c *Client) CreateTaskWithContent(ctx context.Context, title, content string, properties map[string]interface{}, dbType string) (string, error) {
 	// Check if context has a deadline (timeout)
 	if deadline, ok := ctx.Deadline(); ok {
 		remaining := time.Until(deadline)
@@ -138,21 +274,53 @@ func (c *Client) CreateTask(ctx context.Context, title string, properties map[st
 		log.Printf("Added 10s timeout to context")
 	}
 
+	plan, err := c.PlanCreateTask(ctx, title, properties, dbType)
+	if err != nil {
+		return "", err
+	}
+	plan.SetContent(content)
+
+	return c.ExecuteCreatePlan(ctx, plan)
+}
+
+// PlanCreateTask runs the full property-building pipeline of CreateTask without calling
+// the page creation API. The database schema may still be fetched (and cached).
+func (c *Client) PlanCreateTask(ctx context.Context, title string, properties map[string]interface{}, dbType string) (*CreatePlan, error) {
+	dbID := c.getDbIDForType(dbType)
+	log.Printf("Creating task in %s database: %s with properties: %v", dbType, title, properties)
+
+	if title == "" {
+		return nil, fmt.Errorf("task title cannot be empty")
+	}
+
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	plan := &CreatePlan{
+		DatabaseID: dbID,
+		DbType:     dbType,
+	}
+	supplied := properties
+	properties = c.withDefaults(dbType, properties)
+
 	// Get database properties to check for button types
 	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
 	if err != nil {
 		log.Printf("Warning: Could not fetch database properties: %v", err)
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("could not fetch database schema, using fallback property handling: %v", err))
 		// Continue anyway but be more cautious
 	}
 
 	// Create the base request with title property
+	titleKey := c.titlePropertyKey(dbID, dbProps)
 	page := &notionapi.PageCreateRequest{
 		Parent: notionapi.Parent{
 			Type:       notionapi.ParentTypeDatabaseID,
 			DatabaseID: notionapi.DatabaseID(dbID),
 		},
 		Properties: notionapi.Properties{
-			"Name": notionapi.TitleProperty{
+			titleKey: notionapi.TitleProperty{
 				Title: []notionapi.RichText{
 					{
 						Type: notionapi.ObjectType("text"),
@@ -164,15 +332,27 @@ func (c *Client) CreateTask(ctx context.Context, title string, properties map[st
 			},
 		},
 	}
+	plan.Request = page
+
+	skip := func(key, reason string) {
+		plan.Skipped = append(plan.Skipped, key)
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s: %s", key, reason))
+	}
 
 	// Add custom properties - but filter out button properties
 	for key, value := range properties {
 		log.Printf("Processing property: %s = %v", key, value)
 
+		// Nulls only mean "clear" when updating, a new page has nothing to clear
+		if value == nil {
+			skip(key, "null value")
+			continue
+		}
+
 		// Skip known button properties or properties that might be buttons
-		if key == "complete" || key == "done" || key == "button" ||
-			key == "checkbox" || strings.Contains(strings.ToLower(key), "button") {
+		if isButtonLike(key) {
 			log.Printf("Skipping known button-like property: %s", key)
+			skip(key, "button-like property")
 			continue
 		}
 
@@ -185,42 +365,30 @@ func (c *Client) CreateTask(ctx context.Context, title string, properties map[st
 				// Skip button and unsupported types
 				if propType == "button" || propType == "unsupported" {
 					log.Printf("Skipping unsupported property type: %s (type: %s)", key, propType)
+					skip(key, fmt.Sprintf("unsupported property type %s", propType))
 					continue
 				}
 
-				// Handle property based on its type in the database
-				switch propType {
-				case "multi_select":
-					c.handleMultiSelectProperty(page, key, value)
-					continue
-				case "select":
-					c.handleSelectProperty(page, key, value)
-					continue
-				case "date":
-					c.handleDateProperty(page, key, value)
-					continue
-				case "checkbox":
-					c.handleCheckboxProperty(page, key, value)
-					continue
-				case "rich_text":
-					c.handleTextProperty(page, key, value)
-					continue
-				case "number":
-					c.handleNumberProperty(page, key, value)
-					continue
-				case "url":
-					c.handleURLProperty(page, key, value)
-					continue
-				case "email":
-					c.handleEmailProperty(page, key, value)
-					continue
-				case "phone_number":
-					c.handlePhoneProperty(page, key, value)
+				// Handle property based on its type in the database. Defaults from the
+				// environment are deliberate and may create new options.
+				propCtx := ctx
+				if _, given := supplied[key]; !given {
+					propCtx = WithNewOptions(ctx)
+				}
+				handled, err := c.setProperty(propCtx, page.Properties, key, prop, value)
+				if err != nil {
+					return nil, err
+				}
+				if handled {
+					if _, ok := page.Properties[key]; !ok {
+						skip(key, fmt.Sprintf("value %v could not be converted to %s", value, propType))
+					}
 					continue
 				}
 			} else {
 				// Property doesn't exist in database schema
 				log.Printf("Property %s does not exist in database schema, skipping", key)
+				skip(key, "property does not exist in database schema")
 				continue
 			}
 		}
@@ -228,25 +396,50 @@ func (c *Client) CreateTask(ctx context.Context, title string, properties map[st
 		// Fallback logic for when we couldn't determine property type or don't have schema
 		switch key {
 		case "Tags":
-			c.handleMultiSelectProperty(page, key, value)
+			c.handleMultiSelectProperty(ctx, page.Properties, key, nil, value)
 
 		case "project":
-			c.handleSelectProperty(page, key, value)
+			c.handleSelectProperty(ctx, page.Properties, key, nil, value)
 
 		case "Date":
-			c.handleDateProperty(page, key, value)
+			c.handleDateProperty(page.Properties, key, value)
 
 		default:
 			// Handle text properties as default
-			c.handleTextProperty(page, key, value)
+			c.handleTextProperty(page.Properties, key, value)
+		}
+		if _, ok := page.Properties[key]; !ok {
+			skip(key, fmt.Sprintf("value %v could not be converted", value))
 		}
 	}
 
+	return plan, nil
+}
+
+// ExecuteCreatePlan sends a planned page creation request to Notion
+func (c *Client) ExecuteCreatePlan(ctx context.Context, plan *CreatePlan) (pageID string, err error) {
+	defer func() { metrics.RecordTaskCreate(plan.DbType, err) }()
 	log.Printf("Sending create page request to Notion API")
 	creationStart := time.Now()
 
+	// Notion takes a limited number of body blocks with the page, the rest is appended after
+	request := plan.Request
+	var remaining []notionapi.Block
+	if len(request.Children) > maxBlocksPerRequest {
+		first := *request
+		first.Children = request.Children[:maxBlocksPerRequest]
+		remaining = request.Children[maxBlocksPerRequest:]
+		request = &first
+	}
+
 	// Create the page in Notion
-	createdPage, err := c.client.Page.Create(ctx, page)
+	createdPage, err := c.client.Page.Create(ctx, request)
+
+	// The title was keyed as "Name" without a schema; re-key it and retry once
+	if err != nil && isMissingTitleError(err) && c.rekeyTitle(ctx, plan) {
+		log.Printf("Title property is not named %q, retrying with detected title property", defaultTitleKey)
+		createdPage, err = c.client.Page.Create(ctx, request)
+	}
 
 	elapsedTime := time.Since(creationStart)
 	log.Printf("Notion API request took %v", elapsedTime)
@@ -265,14 +458,88 @@ func (c *Client) CreateTask(ctx context.Context, title string, properties map[st
 			return "", fmt.Errorf("database contains button properties which are not supported by Notion API. Please remove button properties from the request")
 		}
 
-		return "", fmt.Errorf("Notion API error: %w", err)
+		return "", fmt.Errorf("failed to create page: %w", err)
+	}
+
+	if len(remaining) > 0 {
+		if err := c.appendRemainingBlocks(ctx, string(createdPage.ID), remaining); err != nil {
+			log.Printf("Warning: Task %s was created without all of its content: %v", createdPage.ID, err)
+		}
 	}
 
 	log.Printf("Task created successfully with ID: %s", createdPage.ID)
 	return string(createdPage.ID), nil
 }
 
+// titlePropertyKey returns the name of the title property of a database. Databases created
+// in other languages don't call it "Name", so the key is detected from the schema and cached.
+func (c *Client) titlePropertyKey(dbID string, dbProps map[string]notionapi.PropertyConfig) string {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	if key, ok := c.titleKeys[dbID]; ok {
+		return key
+	}
+
+	if dbProps == nil {
+		// No schema to inspect, assume the default
+		return defaultTitleKey
+	}
+
+	if prop, ok := dbProps[defaultTitleKey]; ok && prop.GetType() == notionapi.PropertyConfigTypeTitle {
+		c.titleKeys[dbID] = defaultTitleKey
+		return defaultTitleKey
+	}
+
+	for key, prop := range dbProps {
+		if prop.GetType() == notionapi.PropertyConfigTypeTitle {
+			log.Printf("Detected title property %q for database %s", key, dbID)
+			c.titleKeys[dbID] = key
+			return key
+		}
+	}
+
+	return defaultTitleKey
+}
+
+// isMissingTitleError reports whether Notion rejected a request because the
+// default title property doesn't exist in the database
+func isMissingTitleError(err error) bool {
+	return strings.Contains(err.Error(), defaultTitleKey+" is not a property that exists")
+}
+
+// rekeyTitle refreshes the schema of the plan's database and moves the title value to the
+// detected title property. Returns false if there is nothing to retry with.
+func (c *Client) rekeyTitle(ctx context.Context, plan *CreatePlan) bool {
+	title, ok := plan.Request.Properties[defaultTitleKey]
+	if !ok {
+		return false
+	}
+
+	// Drop stale cache entries so the schema is fetched again
+	c.invalidateDatabase(plan.DatabaseID)
+
+	dbProps, err := c.GetDatabaseProperties(ctx, plan.DbType)
+	if err != nil {
+		log.Printf("Warning: Could not fetch database properties to detect title: %v", err)
+		return false
+	}
+
+	titleKey := c.titlePropertyKey(plan.DatabaseID, dbProps)
+	if titleKey == defaultTitleKey {
+		return false
+	}
+
+	delete(plan.Request.Properties, defaultTitleKey)
+	plan.Request.Properties[titleKey] = title
+	return true
+}
+
+// getDbIDForType returns the ID of a database type or a name of NOTION_DATABASES
 func (c *Client) getDbIDForType(dbType string) string {
+	if id := c.databases[dbType]; id != "" {
+		return id
+	}
 	switch dbType {
 	case "notes":
 		return c.notesDbID
@@ -293,12 +560,9 @@ func (c *Client) GetDatabaseProperties(ctx context.Context, dbType string) (map[
 	dbID := c.getDbIDForType(dbType)
 
 	// Check cache first
-	if props, ok := c.dbCache[dbID]; ok {
-		// Check if cache is still valid (10 minute cache)
-		if expiryTime, ok := c.dbCacheExpiry[dbID]; ok && time.Now().Before(expiryTime) {
-			log.Printf("Using cached database properties for %s", dbType)
-			return props, nil
-		}
+	if props, ok := c.cachedSchema(dbID); ok {
+		log.Printf("Using cached database properties for %s", dbType)
+		return props, nil
 	}
 
 	if dbID == "" {
@@ -316,7 +580,7 @@ func (c *Client) GetDatabaseProperties(ctx context.Context, dbType string) (map[
 	db, err := c.client.Database.Get(ctx, notionapi.DatabaseID(dbID))
 	if err != nil {
 		// Check if it's a button property error
-		if strings.Contains(err.Error(), "unsupported property type: button") {
+		if strings.Contains(err.Error(), errButtonProperty) {
 			log.Printf("Warning: Database has button properties which are not supported by the API library")
 			// Try a different approach to get database properties
 			return c.getPropertiesWithButtonWorkaround(ctx, dbID)
@@ -337,13 +601,172 @@ func (c *Client) GetDatabaseProperties(ctx context.Context, dbType string) (map[
 		properties[key] = prop
 	}
 
-	// Cache the result with 10 minute expiry
-	c.dbCache[dbID] = properties
-	c.dbCacheExpiry[dbID] = time.Now().Add(10 * time.Minute)
+	// Cache the result
+	c.storeSchema(dbID, properties, schemaCacheTTL, false)
+
+	// Only full schemas are reported, the button workaround can't see options
+	if c.schemaHook != nil {
+		c.schemaHook(dbID, dbType, properties)
+	}
 
 	return properties, nil
 }
 
+// SchemaGuessed reports whether the cached schema of a database was guessed from a single
+// page by the button workaround, so selects have no options and types may be missing
+func (c *Client) SchemaGuessed(dbType string) bool {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+	return c.guessed[c.getDbIDForType(dbType)]
+}
+
+// InvalidateCache drops the cached schema and title property of a database, so the next
+// call fetches them from Notion again. Use it after changing the database schema.
+func (c *Client) InvalidateCache(dbType string) {
+	c.invalidateDatabase(c.getDbIDForType(dbType))
+}
+
+// InvalidateAllCaches drops the cached schemas and title properties of every database
+func (c *Client) InvalidateAllCaches() {
+	for _, dbType := range databaseTypes {
+		if dbID := c.getDbIDForType(dbType); dbID != "" {
+			c.invalidateDatabase(dbID)
+		}
+	}
+}
+
+// SchemaCacheEntry describes the cached schema of a database
+type SchemaCacheEntry struct {
+	DatabaseType string              `json:"db_type"`
+	DatabaseID   string              `json:"database_id"`
+	Properties   map[string]string   `json:"properties"`        // Property types by name
+	Options      map[string][]string `json:"options,omitempty"` // Option names of select, multi-select and status properties
+	Guessed      bool                `json:"guessed"`           // Guessed from a page, see SchemaGuessed
+	ExpiresAt    time.Time           `json:"expires_at"`
+	Expired      bool                `json:"expired"` // Expired entries are fetched again on their next use
+}
+
+// CachedSchemas returns the schemas cached for the configured databases, expired ones
+// included, ordered like databaseTypes
+func (c *Client) CachedSchemas() []SchemaCacheEntry {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+
+	entries := []SchemaCacheEntry{}
+	for _, dbType := range databaseTypes {
+		dbID := c.getDbIDForType(dbType)
+		props, ok := c.dbCache[dbID]
+		if dbID == "" || !ok {
+			continue
+		}
+
+		entry := SchemaCacheEntry{
+			DatabaseType: dbType,
+			DatabaseID:   dbID,
+			Properties:   make(map[string]string, len(props)),
+			Options:      make(map[string][]string),
+			Guessed:      c.guessed[dbID],
+			ExpiresAt:    c.dbCacheExpiry[dbID],
+			Expired:      !time.Now().Before(c.dbCacheExpiry[dbID]),
+		}
+		for name, prop := range props {
+			entry.Properties[name] = string(prop.GetType())
+			var options []notionapi.Option
+			switch config := prop.(type) {
+			case *notionapi.SelectPropertyConfig:
+				options = config.Select.Options
+			case *notionapi.MultiSelectPropertyConfig:
+				options = config.MultiSelect.Options
+			}
+			for _, option := range options {
+				entry.Options[name] = append(entry.Options[name], option.Name)
+			}
+		}
+		// Status options are cached apart, the library doesn't decode them
+		if status, ok := c.statusCache[dbID]; ok {
+			for name, prop := range status.properties {
+				entry.Properties[name] = "status"
+				for _, option := range prop.Options {
+					entry.Options[name] = append(entry.Options[name], option.Name)
+				}
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// cachedSchema returns the cached schema of a database if it hasn't expired
+func (c *Client) cachedSchema(dbID string) (map[string]notionapi.PropertyConfig, bool) {
+	c.cacheMu.RLock()
+	defer c.cacheMu.RUnlock()
+
+	props, ok := c.dbCache[dbID]
+	if !ok || !time.Now().Before(c.dbCacheExpiry[dbID]) {
+		return nil, false
+	}
+	return props, true
+}
+
+// storeSchema caches the schema of a database for ttl
+func (c *Client) storeSchema(dbID string, properties map[string]notionapi.PropertyConfig, ttl time.Duration, guessed bool) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	c.dbCache[dbID] = properties
+	c.dbCacheExpiry[dbID] = time.Now().Add(ttl)
+	if !guessed {
+		delete(c.guessed, dbID)
+		return
+	}
+	if c.guessed == nil {
+		c.guessed = make(map[string]bool)
+	}
+	c.guessed[dbID] = true
+}
+
+// invalidateDatabase drops every cache entry of a database
+func (c *Client) invalidateDatabase(dbID string) {
+	c.cacheMu.Lock()
+	defer c.cacheMu.Unlock()
+
+	delete(c.dbCache, dbID)
+	delete(c.dbCacheExpiry, dbID)
+	delete(c.titleKeys, dbID)
+	delete(c.guessed, dbID)
+	delete(c.statusCache, dbID)
+}
+
+// refreshSchema drops the cached schema of a database and fetches it again, reporting whether
+// the full schema is available. It runs at most once per guessedSchemaCacheTTL per database,
+// so a persistently broken database doesn't cost an extra fetch on every listing.
+func (c *Client) refreshSchema(ctx context.Context, dbType string) bool {
+	dbID := c.getDbIDForType(dbType)
+
+	c.cacheMu.Lock()
+	if time.Now().Before(c.refreshAfter[dbID]) {
+		c.cacheMu.Unlock()
+		return false
+	}
+	if c.refreshAfter == nil {
+		c.refreshAfter = make(map[string]time.Time)
+	}
+	c.refreshAfter[dbID] = time.Now().Add(guessedSchemaCacheTTL)
+	delete(c.dbCache, dbID)
+	delete(c.dbCacheExpiry, dbID)
+	c.cacheMu.Unlock()
+
+	if _, err := c.GetDatabaseProperties(ctx, dbType); err != nil {
+		return false
+	}
+	return !c.SchemaGuessed(dbType)
+}
+
+// SetSchemaHook registers a function called with every schema freshly fetched from Notion
+func (c *Client) SetSchemaHook(hook func(dbID, dbType string, properties map[string]notionapi.PropertyConfig)) {
+	c.schemaHook = hook
+}
+
 // getPropertiesWithButtonWorkaround is a fallback method to get database properties
 // when the standard approach fails due to button properties
 func (c *Client) getPropertiesWithButtonWorkaround(ctx context.Context, dbID string) (map[string]notionapi.PropertyConfig, error) {
@@ -412,10 +835,16 @@ func (c *Client) getPropertiesWithButtonWorkaround(ctx context.Context, dbID str
 				config = &notionapi.CheckboxPropertyConfig{
 					Type: notionapi.PropertyConfigTypeCheckbox,
 				}
+			case "status":
+				config = &notionapi.StatusPropertyConfig{}
 			case "url":
 				config = &notionapi.URLPropertyConfig{
 					Type: notionapi.PropertyConfigTypeURL,
 				}
+			case "files":
+				config = &notionapi.FilesPropertyConfig{
+					Type: notionapi.PropertyConfigTypeFiles,
+				}
 			case "email":
 				config = &notionapi.EmailPropertyConfig{
 					Type: notionapi.PropertyConfigTypeEmail,
@@ -424,6 +853,14 @@ func (c *Client) getPropertiesWithButtonWorkaround(ctx context.Context, dbID str
 				config = &notionapi.PhoneNumberPropertyConfig{
 					Type: notionapi.PropertyConfigTypePhoneNumber,
 				}
+			case "relation":
+				config = &notionapi.RelationPropertyConfig{
+					Type: notionapi.PropertyConfigTypeRelation,
+				}
+			case "people":
+				config = &notionapi.PeoplePropertyConfig{
+					Type: notionapi.PropertyConfigTypePeople,
+				}
 			default:
 				// Skip unsupported property types
 				log.Printf("Skipping unsupported property type: %s for property %s", prop.GetType(), key)
@@ -434,9 +871,8 @@ func (c *Client) getPropertiesWithButtonWorkaround(ctx context.Context, dbID str
 		}
 	}
 
-	// Cache the properties
-	c.dbCache[dbID] = properties
-	c.dbCacheExpiry[dbID] = time.Now().Add(10 * time.Minute)
+	// Cache the guess briefly so the full fetch is retried soon
+	c.storeSchema(dbID, properties, guessedSchemaCacheTTL, true)
 
 	return properties, nil
 }
@@ -447,59 +883,132 @@ func (c *Client) CreateItem(ctx context.Context, title string, properties map[st
 	return err
 }
 
+// isButtonLike reports whether a property name suggests a button, which the API can't set
+func isButtonLike(key string) bool {
+	return key == "complete" || key == "done" || key == "button" ||
+		key == "checkbox" || strings.Contains(strings.ToLower(key), "button")
+}
+
+// setProperty converts value with the handler for the type of prop in the schema. It returns
+// false for types without a handler; a handled value that couldn't be converted leaves props
+// unchanged. Relations to pages and people that can't be found and unknown select options
+// are an error.
+func (c *Client) setProperty(ctx context.Context, props notionapi.Properties, key string, prop notionapi.PropertyConfig, value interface{}) (bool, error) {
+	switch prop.GetType() {
+	case "relation":
+		if err := c.handleRelationProperty(ctx, props, key, value); err != nil {
+			return true, err
+		}
+	case "people":
+		if err := c.handlePeopleProperty(ctx, props, key, value); err != nil {
+			return true, err
+		}
+	case "multi_select":
+		if err := c.handleMultiSelectProperty(ctx, props, key, propertyOptions(prop), value); err != nil {
+			return true, err
+		}
+	case "select":
+		if err := c.handleSelectProperty(ctx, props, key, propertyOptions(prop), value); err != nil {
+			return true, err
+		}
+	case "date":
+		c.handleDateProperty(props, key, value)
+	case "checkbox":
+		c.handleCheckboxProperty(props, key, value)
+	case "rich_text":
+		c.handleTextProperty(props, key, value)
+	case "number":
+		c.handleNumberProperty(props, key, value)
+	case "url":
+		c.handleURLProperty(props, key, value)
+	case "files":
+		c.handleFilesProperty(props, key, value)
+	case "email":
+		c.handleEmailProperty(props, key, value)
+	case "phone_number":
+		c.handlePhoneProperty(props, key, value)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
 // Helper methods for handling different property types
 
-func (c *Client) handleMultiSelectProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
-	if tags, ok := value.([]interface{}); ok {
-		var options []notionapi.Option
-		for _, tag := range tags {
+// handleMultiSelectProperty sets the values of a multi-select, matched against the property's
+// options from the schema, see resolveOption
+func (c *Client) handleMultiSelectProperty(ctx context.Context, props notionapi.Properties, key string, known []notionapi.Option, value interface{}) error {
+	var tags []string
+	if list, ok := value.([]interface{}); ok {
+		for _, tag := range list {
 			if tagStr, ok := tag.(string); ok {
-				options = append(options, notionapi.Option{
-					Name: tagStr,
-				})
+				tags = append(tags, tagStr)
 			}
 		}
-		page.Properties[key] = notionapi.MultiSelectProperty{
-			MultiSelect: options,
-		}
 	} else if tagStr, ok := value.(string); ok {
 		// Handle single string
-		page.Properties[key] = notionapi.MultiSelectProperty{
-			MultiSelect: []notionapi.Option{
-				{Name: tagStr},
-			},
-		}
+		tags = []string{tagStr}
+	} else {
+		return nil
 	}
-}
 
-func (c *Client) handleSelectProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
-	if projectStr, ok := value.(string); ok {
-		page.Properties[key] = notionapi.SelectProperty{
-			Select: notionapi.Option{
-				Name: projectStr,
-			},
+	options := []notionapi.Option{}
+	for _, tag := range tags {
+		name, err := c.resolveOption(ctx, key, tag, known)
+		if err != nil {
+			return err
 		}
+		options = append(options, notionapi.Option{Name: name})
 	}
+	props[key] = notionapi.MultiSelectProperty{
+		MultiSelect: options,
+	}
+	return nil
 }
 
-func (c *Client) handleDateProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
-	if dateStr, ok := value.(string); ok && dateStr != "" {
-		// Parse and convert to Notion's Date type
-		parsedDate := parseToNotionDate(dateStr)
+// handleSelectProperty sets the value of a select, matched against the property's options
+// from the schema, see resolveOption
+func (c *Client) handleSelectProperty(ctx context.Context, props notionapi.Properties, key string, known []notionapi.Option, value interface{}) error {
+	projectStr, ok := value.(string)
+	if !ok {
+		return nil
+	}
+	name, err := c.resolveOption(ctx, key, projectStr, known)
+	if err != nil {
+		return err
+	}
+	props[key] = notionapi.SelectProperty{
+		Select: notionapi.Option{
+			Name: name,
+		},
+	}
+	return nil
+}
 
-		// Create a DateProperty with the proper structure required by Notion
-		page.Properties[key] = notionapi.DateProperty{
-			Date: &notionapi.DateObject{
-				Start: parsedDate,
-				End:   nil, // End date is optional and can be nil
-			},
-		}
+// handleDateProperty sets a date from a string, a day or a moment, see formatDateString
+func (c *Client) handleDateProperty(props notionapi.Properties, key string, value interface{}) {
+	dateStr, ok := value.(string)
+	if !ok || dateStr == "" {
+		return
+	}
+	date, err := parseToNotionDate(dateStr, c.dateLocation(), c.dateOrder)
+	if err != nil {
+		log.Printf("Could not parse '%s' as a date, skipping property %s: %v", dateStr, key, err)
+		return
+	}
+	props[key] = date
+	log.Printf("Added Date property: %s", date.Start)
+}
 
-		log.Printf("Added Date property: %s", parsedDate.String())
+// dateLocation returns where dates with a time but no offset are read
+func (c *Client) dateLocation() *time.Location {
+	if c.location != nil {
+		return c.location
 	}
+	return time.Local
 }
 
-func (c *Client) handleCheckboxProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
+func (c *Client) handleCheckboxProperty(props notionapi.Properties, key string, value interface{}) {
 	var checked bool
 	switch v := value.(type) {
 	case bool:
@@ -513,14 +1022,14 @@ func (c *Client) handleCheckboxProperty(page *notionapi.PageCreateRequest, key s
 	default:
 		checked = false
 	}
-	page.Properties[key] = notionapi.CheckboxProperty{
+	props[key] = notionapi.CheckboxProperty{
 		Checkbox: checked,
 	}
 }
 
-func (c *Client) handleTextProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
+func (c *Client) handleTextProperty(props notionapi.Properties, key string, value interface{}) {
 	if valueStr, ok := value.(string); ok {
-		page.Properties[key] = notionapi.RichTextProperty{
+		props[key] = notionapi.RichTextProperty{
 			RichText: []notionapi.RichText{
 				{
 					Text: &notionapi.Text{
@@ -532,7 +1041,7 @@ func (c *Client) handleTextProperty(page *notionapi.PageCreateRequest, key strin
 	}
 }
 
-func (c *Client) handleNumberProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
+func (c *Client) handleNumberProperty(props notionapi.Properties, key string, value interface{}) {
 	var number float64
 	switch v := value.(type) {
 	case float64:
@@ -552,138 +1061,226 @@ func (c *Client) handleNumberProperty(page *notionapi.PageCreateRequest, key str
 		log.Printf("Unsupported type for number property %s, skipping", key)
 		return
 	}
-	page.Properties[key] = notionapi.NumberProperty{
+	props[key] = notionapi.NumberProperty{
 		Number: number,
 	}
 }
 
-func (c *Client) handleURLProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
+func (c *Client) handleURLProperty(props notionapi.Properties, key string, value interface{}) {
 	if urlStr, ok := value.(string); ok {
-		page.Properties[key] = notionapi.URLProperty{
+		props[key] = notionapi.URLProperty{
 			URL: urlStr,
 		}
 	}
 }
 
-func (c *Client) handleEmailProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
+// handleFilesProperty links the files at value, a URL or a list of them. Files are
+// external, named after the last part of their URL.
+func (c *Client) handleFilesProperty(props notionapi.Properties, key string, value interface{}) {
+	var urls []string
+	switch v := value.(type) {
+	case string:
+		urls = []string{v}
+	case []string:
+		urls = v
+	case []interface{}:
+		for _, item := range v {
+			if urlStr, ok := item.(string); ok {
+				urls = append(urls, urlStr)
+			}
+		}
+	default:
+		return
+	}
+
+	files := []notionapi.File{}
+	for _, urlStr := range urls {
+		if urlStr == "" {
+			continue
+		}
+		files = append(files, notionapi.File{
+			Name:     path.Base(urlStr),
+			Type:     notionapi.FileTypeExternal,
+			External: &notionapi.FileObject{URL: urlStr},
+		})
+	}
+	props[key] = notionapi.FilesProperty{
+		Files: files,
+	}
+}
+
+func (c *Client) handleEmailProperty(props notionapi.Properties, key string, value interface{}) {
 	if emailStr, ok := value.(string); ok {
-		page.Properties[key] = notionapi.EmailProperty{
+		props[key] = notionapi.EmailProperty{
 			Email: emailStr,
 		}
 	}
 }
 
-func (c *Client) handlePhoneProperty(page *notionapi.PageCreateRequest, key string, value interface{}) {
+func (c *Client) handlePhoneProperty(props notionapi.Properties, key string, value interface{}) {
 	if phoneStr, ok := value.(string); ok {
-		page.Properties[key] = notionapi.PhoneNumberProperty{
+		props[key] = notionapi.PhoneNumberProperty{
 			PhoneNumber: phoneStr,
 		}
 	}
 }
 
-// parseToNotionDate converts a string to a Notion Date pointer
-func parseToNotionDate(dateStr string) *notionapi.Date {
-	formattedStr := formatDateString(dateStr)
+// ErrRelationNotFound is returned for relation values naming no known page
+var ErrRelationNotFound = errors.New("related page not found")
 
-	// Parse the formatted date string to a time.Time
-	t, err := time.Parse("2006-01-02", formattedStr)
-	if err != nil {
-		log.Printf("Error parsing date %s: %v", formattedStr, err)
+// handleRelationProperty links the pages given by value: a page ID, page URL or project
+// name, or a list of them. A name that matches no project is an error rather than a task
+// created without the link.
+func (c *Client) handleRelationProperty(ctx context.Context, props notionapi.Properties, key string, value interface{}) error {
+	var refs []string
+	switch v := value.(type) {
+	case string:
+		refs = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if ref, ok := item.(string); ok {
+				refs = append(refs, ref)
+			}
+		}
+	default:
+		log.Printf("Unsupported type for relation property %s, skipping", key)
 		return nil
 	}
 
-	// Convert to Notion Date type
-	notionDate := notionapi.Date(t)
-	return &notionDate
+	relations := make([]notionapi.Relation, 0, len(refs))
+	for _, ref := range refs {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+		id, err := c.relatedPageID(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		relations = append(relations, notionapi.Relation{ID: notionapi.PageID(id)})
+	}
+	props[key] = notionapi.RelationProperty{
+		Relation: relations,
+	}
+	return nil
 }
 
-// formatDateString converts various date formats to the format Notion expects
-// Notion requires ISO 8601 date strings (YYYY-MM-DD) for dates
-func formatDateString(date string) string {
-	// Check if the date is already in YYYY-MM-DD format
-	if len(date) >= 10 && date[4] == '-' && date[7] == '-' {
-		// Already in YYYY-MM-DD format, return first 10 chars
-		return date[:10]
+// relatedPageID returns the page ID a relation value refers to, looking names up in the
+// projects database
+func (c *Client) relatedPageID(ctx context.Context, ref string) (string, error) {
+	if id, err := ParsePageID(ref); err == nil {
+		return id, nil
 	}
+	return c.projectIDByName(ctx, ref)
+}
 
-	// Check MM/DD/YYYY format (common in US)
-	if len(date) >= 10 && date[2] == '/' && date[5] == '/' {
-		month := date[0:2]
-		day := date[3:5]
-		year := date[6:10]
-		return year + "-" + month + "-" + day
-	}
+// projectIDByName resolves a project name, ignoring case. The names are cached, a miss
+// fetches them again in case the project was created since.
+func (c *Client) projectIDByName(ctx context.Context, name string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
 
-	// Try to parse with Go's time package
-	layouts := []string{
-		"01/02/2006", // MM/DD/YYYY
-		"2006-01-02", // YYYY-MM-DD
-		"02-01-2006", // DD-MM-YYYY
-		"01-02-2006", // MM-DD-YYYY
-		time.RFC3339, // YYYY-MM-DDTHH:MM:SSZ
+	c.projectsMu.Lock()
+	defer c.projectsMu.Unlock()
+	if time.Now().Before(c.projectsExpiry) {
+		if id, ok := c.projectIDs[key]; ok {
+			return id, nil
+		}
 	}
 
-	for _, layout := range layouts {
-		if t, err := time.Parse(layout, date); err == nil {
-			return t.Format("2006-01-02") // Return as YYYY-MM-DD
+	projects, err := c.GetProjects(ctx)
+	if err != nil {
+		return "", fmt.Errorf("could not look up project %q: %w", name, err)
+	}
+	c.projectIDs = make(map[string]string, len(projects))
+	for _, project := range projects {
+		if project.Name != "" {
+			c.projectIDs[strings.ToLower(project.Name)] = project.ID
 		}
 	}
+	c.projectsExpiry = time.Now().Add(projectNamesCacheTTL)
+
+	if id, ok := c.projectIDs[key]; ok {
+		return id, nil
+	}
+	return "", fmt.Errorf("%w: no project is named %q", ErrRelationNotFound, strings.TrimSpace(name))
+}
 
-	// For other formats, just return the original and log a warning
-	log.Printf("Warning: Unrecognized date format: %s", date)
-	return date
+// ListInfo describes how a task listing was served, so results degraded by the button
+// workaround can be flagged
+type ListInfo struct {
+	Degraded bool   `json:"degraded"`
+	Reason   string `json:"reason,omitempty"`
+	// Filters is "server" when Notion applied them and "client" when pages were filtered here
+	Filters      string `json:"filters"`
+	PagesScanned int    `json:"pages_scanned"`
+	PagesMatched int    `json:"pages_matched"`
 }
 
+const (
+	filtersServer = "server"
+	filtersClient = "client"
+)
+
 // GetRecentTasks retrieves recent tasks from the specified Notion database
 // Filters for tasks that are not done and don't have 'sometimes-later' tag
 func (c *Client) GetRecentTasks(ctx context.Context, dbType string, limit int) ([]Task, error) {
+	tasks, _, err := c.GetRecentTasksWithInfo(ctx, dbType, limit)
+	return tasks, err
+}
+
+// GetRecentTasksWithInfo is GetRecentTasks, also reporting whether the listing was degraded
+func (c *Client) GetRecentTasksWithInfo(ctx context.Context, dbType string, limit int) ([]Task, *ListInfo, error) {
 	dbID := c.getDbIDForType(dbType)
 	if dbID == "" {
-		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+		return nil, nil, fmt.Errorf("database ID for %s not configured", dbType)
 	}
 
-	// Create database query filter
+	// Create database query filter: status not done, as a select or status condition, and
+	// tags not containing "sometimes-later"
 	filter := &notionapi.DatabaseQueryRequest{
-		Filter: notionapi.AndCompoundFilter{
-			// Filter for status not being "done"
-			notionapi.PropertyFilter{
-				Property: "status",
-				Select: &notionapi.SelectFilterCondition{
-					DoesNotEqual: "done",
-				},
-			},
-			// Filter for tags not containing "sometimes-later"
+		Filter: append(notionapi.AndCompoundFilter(c.notDoneFilters(ctx, dbType)),
 			notionapi.PropertyFilter{
 				Property: "tags",
 				MultiSelect: &notionapi.MultiSelectFilterCondition{
 					DoesNotContain: "sometimes-later",
 				},
 			},
-		},
+		),
 		Sorts: []notionapi.SortObject{
 			{
 				Property:  "Created time",
 				Direction: "descending",
 			},
 		},
-		PageSize: limit,
+		PageSize: pageSizeFor(limit),
 	}
 
-	// Query the database
-	response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), filter)
+	// Query the database, following cursors until the limit is reached
+	var pages []notionapi.Page
+	collect := func(page notionapi.Page) bool {
+		pages = append(pages, page)
+		return len(pages) < limit
+	}
+	err := c.queryPages(ctx, dbID, filter, collect)
+	if err != nil && strings.Contains(err.Error(), errButtonProperty) && c.refreshSchema(ctx, dbType) {
+		// The error is often transient, retry once the full schema is available again
+		log.Printf("Warning: Button property detected during query, retrying after a schema refresh")
+		pages = nil
+		err = c.queryPages(ctx, dbID, filter, collect)
+	}
 	if err != nil {
 		// Handle button property error gracefully
-		if strings.Contains(err.Error(), "unsupported property type: button") {
+		if strings.Contains(err.Error(), errButtonProperty) {
 			log.Printf("Warning: Button property detected during query. Using workaround...")
 			return c.getRecentTasksWithButtonWorkaround(ctx, dbID, limit)
 		}
-		return nil, fmt.Errorf("failed to query database: %w", err)
+		return nil, nil, fmt.Errorf("failed to query database: %w", err)
 	}
 
 	// Transform the results
-	tasks := make([]Task, 0, len(response.Results))
-	for _, page := range response.Results {
-		task, err := c.transformPageToTask(page)
+	tasks := make([]Task, 0, len(pages))
+	mentions := c.newMentionResolver(ctx)
+	for _, page := range pages {
+		task, err := c.transformPageToTask(page, mentions)
 		if err != nil {
 			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
 			continue
@@ -691,141 +1288,175 @@ func (c *Client) GetRecentTasks(ctx context.Context, dbType string, limit int) (
 		tasks = append(tasks, task)
 	}
 
-	return tasks, nil
+	info := &ListInfo{Filters: filtersServer, PagesScanned: len(pages), PagesMatched: len(tasks)}
+	return tasks, info, nil
 }
 
-// GetUndoneTasksExcludingSometimesLater retrieves all undone tasks excluding those tagged 'sometimes-later'.
-// Unlike GetRecentTasks fallback, this does NOT filter out tasks that already have a Date.
-func (c *Client) GetUndoneTasksExcludingSometimesLater(ctx context.Context, dbType string, limit int) ([]Task, error) {
-    dbID := c.getDbIDForType(dbType)
-    if dbID == "" {
-        return nil, fmt.Errorf("database ID for %s not configured", dbType)
-    }
-
-    // Build query: status != done AND tags does not contain 'sometimes-later'
-    query := &notionapi.DatabaseQueryRequest{
-        Filter: notionapi.AndCompoundFilter{
-            notionapi.PropertyFilter{
-                Property: "status",
-                Select: &notionapi.SelectFilterCondition{
-                    DoesNotEqual: "done",
-                },
-            },
-            notionapi.PropertyFilter{
-                Property: "tags",
-                MultiSelect: &notionapi.MultiSelectFilterCondition{
-                    DoesNotContain: "sometimes-later",
-                },
-            },
-        },
-        Sorts: []notionapi.SortObject{
-            {
-                Property:  "Created time",
-                Direction: "descending",
-            },
-        },
-        PageSize: limit,
-    }
-
-    response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), query)
-    if err != nil {
-        if strings.Contains(err.Error(), "unsupported property type: button") {
-            log.Printf("Warning: Button property detected during tagging query. Using workaround...")
-            return c.getUndoneTasksExcludingSometimesLaterWorkaround(ctx, dbID, limit)
-        }
-        return nil, fmt.Errorf("failed to query database: %w", err)
-    }
-
-    tasks := make([]Task, 0, len(response.Results))
-    for _, page := range response.Results {
-        task, err := c.transformPageToTask(page)
-        if err != nil {
-            log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
-            continue
-        }
-        tasks = append(tasks, task)
-    }
-    return tasks, nil
+// maxQueryPageSize is the largest page size the Notion query API accepts
+const maxQueryPageSize = 100
+
+// pageSizeFor returns the query page size for fetching limit results
+func pageSizeFor(limit int) int {
+	if limit <= 0 || limit > maxQueryPageSize {
+		return maxQueryPageSize
+	}
+	return limit
 }
 
-// getUndoneTasksExcludingSometimesLaterWorkaround fetches tasks and manually filters without excluding dated tasks.
-func (c *Client) getUndoneTasksExcludingSometimesLaterWorkaround(ctx context.Context, dbID string, limit int) ([]Task, error) {
-    // Simple query without filters; we'll filter manually
-    queryRequest := &notionapi.DatabaseQueryRequest{
-        Sorts: []notionapi.SortObject{
-            {
-                Property:  "Created time",
-                Direction: "descending",
-            },
-        },
-        PageSize: 200, // fetch more to allow manual filtering
-    }
-
-    response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), queryRequest)
-    if err != nil {
-        return nil, fmt.Errorf("failed to query database: %w", err)
-    }
-
-    tasks := make([]Task, 0, limit)
-    for _, page := range response.Results {
-        if len(tasks) >= limit {
-            break
-        }
-
-        // Skip done
-        if statusProp, ok := page.Properties["status"]; ok {
-            if selectProp, ok := statusProp.(*notionapi.SelectProperty); ok {
-                if strings.EqualFold(selectProp.Select.Name, "done") {
-                    continue
-                }
-            }
-        }
-
-        // Skip if tags contain 'sometimes-later'
-        if tagsProp, ok := page.Properties["Tags"]; ok {
-            if multiSelectProp, ok := tagsProp.(*notionapi.MultiSelectProperty); ok {
-                hasSometimesLater := false
-                for _, tag := range multiSelectProp.MultiSelect {
-                    if tag.Name == "sometimes-later" {
-                        hasSometimesLater = true
-                        break
-                    }
-                }
-                if hasSometimesLater {
-                    continue
-                }
-            }
-        }
-
-        task, err := c.transformPageToTask(page)
-        if err != nil {
-            log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
-            continue
-        }
-        tasks = append(tasks, task)
-    }
-
-    return tasks, nil
-}
-// transformPageToTask converts a Notion page to a Task struct
-func (c *Client) transformPageToTask(page notionapi.Page) (Task, error) {
-	task := Task{
+// queryPages runs a database query and calls visit with every result in order, following
+// cursors until there are no more results or visit returns false
+func (c *Client) queryPages(ctx context.Context, dbID string, request *notionapi.DatabaseQueryRequest, visit func(notionapi.Page) bool) error {
+	query := *request
+	for {
+		response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), &query)
+		if err != nil {
+			return err
+		}
+
+		for _, page := range response.Results {
+			if !visit(page) {
+				return nil
+			}
+		}
+
+		if !response.HasMore || response.NextCursor == "" {
+			return nil
+		}
+		query.StartCursor = response.NextCursor
+	}
+}
+
+// GetUndoneTasksExcludingSometimesLater retrieves all undone tasks excluding those tagged 'sometimes-later'.
+// Unlike GetRecentTasks fallback, this does NOT filter out tasks that already have a Date.
+func (c *Client) GetUndoneTasksExcludingSometimesLater(ctx context.Context, dbType string, limit int) ([]Task, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	// Build query: status != done AND tags does not contain 'sometimes-later'
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: append(notionapi.AndCompoundFilter(c.notDoneFilters(ctx, dbType)),
+			notionapi.PropertyFilter{
+				Property: "tags",
+				MultiSelect: &notionapi.MultiSelectFilterCondition{
+					DoesNotContain: "sometimes-later",
+				},
+			},
+		),
+		Sorts: []notionapi.SortObject{
+			{
+				Property:  "Created time",
+				Direction: "descending",
+			},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	var pages []notionapi.Page
+	collect := func(page notionapi.Page) bool {
+		pages = append(pages, page)
+		return len(pages) < limit
+	}
+	err := c.queryPages(ctx, dbID, query, collect)
+	if err != nil && strings.Contains(err.Error(), errButtonProperty) && c.refreshSchema(ctx, dbType) {
+		log.Printf("Warning: Button property detected during tagging query, retrying after a schema refresh")
+		pages = nil
+		err = c.queryPages(ctx, dbID, query, collect)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), errButtonProperty) {
+			log.Printf("Warning: Button property detected during tagging query. Using workaround...")
+			return c.getUndoneTasksExcludingSometimesLaterWorkaround(ctx, dbID, limit)
+		}
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	tasks := make([]Task, 0, len(pages))
+	mentions := c.newMentionResolver(ctx)
+	for _, page := range pages {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// getUndoneTasksExcludingSometimesLaterWorkaround fetches tasks and manually filters without excluding dated tasks.
+func (c *Client) getUndoneTasksExcludingSometimesLaterWorkaround(ctx context.Context, dbID string, limit int) ([]Task, error) {
+	// Simple query without filters; we'll filter manually
+	queryRequest := &notionapi.DatabaseQueryRequest{
+		Sorts: []notionapi.SortObject{
+			{
+				Property:  "Created time",
+				Direction: "descending",
+			},
+		},
+		PageSize: maxQueryPageSize, // fetch more to allow manual filtering
+	}
+
+	tasks := make([]Task, 0, pageSizeFor(limit))
+	mentions := c.newMentionResolver(ctx)
+	err := c.queryPages(ctx, dbID, queryRequest, func(page notionapi.Page) bool {
+		// Skip done
+		if statusProp, ok := page.Properties["status"]; ok {
+			if selectProp, ok := statusProp.(*notionapi.SelectProperty); ok {
+				if strings.EqualFold(selectProp.Select.Name, "done") {
+					return true
+				}
+			}
+		}
+
+		// Skip if tags contain 'sometimes-later'
+		if tagsProp, ok := page.Properties["Tags"]; ok {
+			if multiSelectProp, ok := tagsProp.(*notionapi.MultiSelectProperty); ok {
+				for _, tag := range multiSelectProp.MultiSelect {
+					if tag.Name == "sometimes-later" {
+						return true
+					}
+				}
+			}
+		}
+
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			return true
+		}
+		tasks = append(tasks, task)
+		return len(tasks) < limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	return tasks, nil
+}
+
+// transformPageToTask converts a Notion page to a Task struct, resolving mentions in the
+// title and text properties with the given resolver
+func (c *Client) transformPageToTask(page notionapi.Page, mentions *mentionResolver) (Task, error) {
+	task := Task{
 		ID:         string(page.ID),
 		URL:        page.URL,
 		CreatedAt:  page.CreatedTime,
 		Properties: make(map[string]interface{}),
 	}
 
-	// Extract title from Name property
-	if titleProp, ok := page.Properties["Name"]; ok {
+	// Extract title from the title property (not necessarily called "Name")
+	titleKey := pageTitleKey(page)
+	if titleProp, ok := page.Properties[titleKey]; ok {
 		if title, ok := titleProp.(*notionapi.TitleProperty); ok && len(title.Title) > 0 {
-			task.Title = title.Title[0].PlainText
+			task.Title = mentions.plainText(title.Title)
 		}
 	}
 
 	// Add other properties
 	for key, prop := range page.Properties {
-		if key == "Name" {
+		if key == titleKey {
 			continue // Already handled above
 		}
 
@@ -834,45 +1465,119 @@ func (c *Client) transformPageToTask(page notionapi.Page) (Task, error) {
 			continue
 		}
 
-		switch prop.GetType() {
-		case "select":
-			if selectProp, ok := prop.(*notionapi.SelectProperty); ok && selectProp.Select.Name != "" {
-				task.Properties[key] = selectProp.Select.Name
-			}
-		case "multi_select":
-			if multiSelectProp, ok := prop.(*notionapi.MultiSelectProperty); ok {
-				tags := make([]string, 0, len(multiSelectProp.MultiSelect))
-				for _, opt := range multiSelectProp.MultiSelect {
-					tags = append(tags, opt.Name)
-				}
-				task.Properties[key] = tags
-			}
-		case "date":
-			if dateProp, ok := prop.(*notionapi.DateProperty); ok && dateProp.Date != nil {
-				task.Properties[key] = dateProp.Date.Start.String()
-			}
-		case "checkbox":
-			if checkboxProp, ok := prop.(*notionapi.CheckboxProperty); ok {
-				task.Properties[key] = checkboxProp.Checkbox
-			}
-		case "rich_text":
-			if textProp, ok := prop.(*notionapi.RichTextProperty); ok && len(textProp.RichText) > 0 {
-				var text strings.Builder
-				for _, t := range textProp.RichText {
-					text.WriteString(t.PlainText)
-				}
-				task.Properties[key] = text.String()
-			}
-		default:
-			// Skip other property types
+		if value, ok := propertyValue(prop, mentions); ok {
+			task.Properties[key] = value
 		}
 	}
 
 	return task, nil
 }
 
+// unsupportedProperty stands in for properties of types without a JSON mapping, so the
+// mini app can show they exist
+type unsupportedProperty struct {
+	Type        notionapi.PropertyType `json:"type"`
+	Unsupported bool                   `json:"unsupported"`
+}
+
+// propertyValue returns the JSON value of a page property, reporting false for empty
+// selects, dates, texts and links, which are left out
+func propertyValue(prop notionapi.Property, mentions *mentionResolver) (interface{}, bool) {
+	switch prop := prop.(type) {
+	case *notionapi.SelectProperty:
+		return prop.Select.Name, prop.Select.Name != ""
+	case *notionapi.StatusProperty:
+		return prop.Status.Name, prop.Status.Name != ""
+	case *notionapi.MultiSelectProperty:
+		tags := make([]string, 0, len(prop.MultiSelect))
+		for _, opt := range prop.MultiSelect {
+			tags = append(tags, opt.Name)
+		}
+		return tags, true
+	case *notionapi.DateProperty:
+		if prop.Date == nil || prop.Date.Start == nil {
+			return nil, false
+		}
+		return prop.Date.Start.String(), true
+	case *notionapi.CheckboxProperty:
+		return prop.Checkbox, true
+	case *notionapi.RichTextProperty:
+		return mentions.plainText(prop.RichText), len(prop.RichText) > 0
+	case *notionapi.NumberProperty:
+		return prop.Number, true
+	case *notionapi.URLProperty:
+		return prop.URL, prop.URL != ""
+	case *notionapi.EmailProperty:
+		return prop.Email, prop.Email != ""
+	case *notionapi.PhoneNumberProperty:
+		return prop.PhoneNumber, prop.PhoneNumber != ""
+	case *notionapi.PeopleProperty:
+		names := make([]string, 0, len(prop.People))
+		for _, user := range prop.People {
+			names = append(names, userName(user))
+		}
+		return names, true
+	case *notionapi.CreatedByProperty:
+		return userName(prop.CreatedBy), true
+	case *notionapi.LastEditedByProperty:
+		return userName(prop.LastEditedBy), true
+	case *notionapi.RelationProperty:
+		ids := make([]string, 0, len(prop.Relation))
+		for _, relation := range prop.Relation {
+			ids = append(ids, string(relation.ID))
+		}
+		return ids, true
+	case *notionapi.FormulaProperty:
+		return formulaValue(prop.Formula)
+	case *notionapi.CreatedTimeProperty:
+		return prop.CreatedTime.Format(time.RFC3339), true
+	case *notionapi.LastEditedTimeProperty:
+		return prop.LastEditedTime.Format(time.RFC3339), true
+	}
+	return unsupportedProperty{Type: prop.GetType(), Unsupported: true}, true
+}
+
+// formulaValue returns the computed value of a formula
+func formulaValue(formula notionapi.Formula) (interface{}, bool) {
+	switch formula.Type {
+	case notionapi.FormulaTypeString:
+		return formula.String, true
+	case notionapi.FormulaTypeNumber:
+		return formula.Number, true
+	case notionapi.FormulaTypeBoolean:
+		return formula.Boolean, true
+	case notionapi.FormulaTypeDate:
+		if formula.Date == nil || formula.Date.Start == nil {
+			return nil, false
+		}
+		return formula.Date.Start.String(), true
+	}
+	return nil, false
+}
+
+// userName returns a user's name, or the ID when Notion leaves the name out
+func userName(user notionapi.User) string {
+	if user.Name != "" {
+		return user.Name
+	}
+	return string(user.ID)
+}
+
+// pageTitleKey returns the name of the title property of a page
+func pageTitleKey(page notionapi.Page) string {
+	if _, ok := page.Properties[defaultTitleKey].(*notionapi.TitleProperty); ok {
+		return defaultTitleKey
+	}
+	for key, prop := range page.Properties {
+		if _, ok := prop.(*notionapi.TitleProperty); ok {
+			return key
+		}
+	}
+	return defaultTitleKey
+}
+
 // getRecentTasksWithButtonWorkaround provides a fallback method for querying databases with button properties
-func (c *Client) getRecentTasksWithButtonWorkaround(ctx context.Context, dbID string, limit int) ([]Task, error) {
+func (c *Client) getRecentTasksWithButtonWorkaround(ctx context.Context, dbID string, limit int) ([]Task, *ListInfo, error) {
 	// Simple query without filters to get recent tasks
 	queryRequest := &notionapi.DatabaseQueryRequest{
 		Sorts: []notionapi.SortObject{
@@ -881,27 +1586,25 @@ func (c *Client) getRecentTasksWithButtonWorkaround(ctx context.Context, dbID st
 				Direction: "descending",
 			},
 		},
-		PageSize: 100, // Get more to allow for manual filtering
+		PageSize: maxQueryPageSize, // Get more to allow for manual filtering
 	}
 
-	response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), queryRequest)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query database: %w", err)
+	// Manual filtering, fetching further pages until enough tasks match
+	tasks := make([]Task, 0, pageSizeFor(limit))
+	mentions := c.newMentionResolver(ctx)
+	info := &ListInfo{
+		Degraded: true,
+		Reason:   "database has button properties, filters were applied to the latest pages only",
+		Filters:  filtersClient,
 	}
-
-	// Manual filtering
-	tasks := make([]Task, 0, limit)
-	for _, page := range response.Results {
-		// Skip if we already have enough tasks
-		if len(tasks) >= limit {
-			break
-		}
+	err := c.queryPages(ctx, dbID, queryRequest, func(page notionapi.Page) bool {
+		info.PagesScanned++
 
 		// Check status property
 		if statusProp, ok := page.Properties["status"]; ok {
 			if selectProp, ok := statusProp.(*notionapi.SelectProperty); ok {
 				if selectProp.Select.Name == "done" {
-					continue // Skip if status is done
+					return true // Skip if status is done
 				}
 			}
 		}
@@ -910,7 +1613,7 @@ func (c *Client) getRecentTasksWithButtonWorkaround(ctx context.Context, dbID st
 		if dateProp, ok := page.Properties["Date"]; ok {
 			if dateValue, ok := dateProp.(*notionapi.DateProperty); ok {
 				if dateValue.Date != nil && dateValue.Date.Start != nil {
-					continue // Skip if date is not empty
+					return true // Skip if date is not empty
 				}
 			}
 		}
@@ -926,84 +1629,297 @@ func (c *Client) getRecentTasksWithButtonWorkaround(ctx context.Context, dbID st
 					}
 				}
 				if hasSometimesLater {
-					continue // Skip if has sometimes-later tag
+					return true // Skip if has sometimes-later tag
 				}
 			}
 		}
 
 		// If we got here, the task passed all filters
-		task, err := c.transformPageToTask(page)
+		task, err := c.transformPageToTask(page, mentions)
 		if err != nil {
 			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
-			continue
+			return true
 		}
 		tasks = append(tasks, task)
+		return len(tasks) < limit
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query database: %w", err)
 	}
 
-	return tasks, nil
+	info.PagesMatched = len(tasks)
+	return tasks, info, nil
 }
 
-// UpdateTaskStatus updates the status of a task in Notion
-func (c *Client) UpdateTaskStatus(taskID string, status string, properties map[string]interface{}) error {
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// UpdatePlan is the page update request UpdateTaskStatus or UpdateTask would send to Notion
+type UpdatePlan struct {
+	PageID   string                       `json:"page_id"`
+	Request  *notionapi.PageUpdateRequest `json:"request"`
+	Warnings []string                     `json:"warnings,omitempty"`
+	Skipped  []string                     `json:"skipped_properties,omitempty"`
+}
 
-	// Initialize properties map if nil
-	if properties == nil {
-		properties = make(map[string]interface{})
+// GetPage retrieves a single page from Notion by ID, written with or without hyphens.
+// Pages that don't exist or aren't shared with the integration return ErrNotFound.
+func (c *Client) GetPage(ctx context.Context, pageID string) (*notionapi.Page, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+	}
+	// IDs that don't parse are passed on, Notion rejects them itself
+	if normalized, err := ParsePageID(pageID); err == nil {
+		pageID = normalized
 	}
 
-	// Ensure status is set
-	properties["status"] = status
+	page, err := c.client.Page.Get(ctx, notionapi.PageID(pageID))
+	if notionErr, ok := AsNotionError(err); ok && notionErr.Status == http.StatusNotFound {
+		return nil, fmt.Errorf("failed to get page: %w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page: %w", err)
+	}
+	return page, nil
+}
 
-	// Create the page update request
-	updateRequest := &notionapi.PageUpdateRequest{
-		Properties: make(notionapi.Properties),
+// GetTaskByID retrieves a single page as a Task, like the listings return them
+func (c *Client) GetTaskByID(ctx context.Context, pageID string) (Task, error) {
+	page, err := c.GetPage(ctx, pageID)
+	if err != nil {
+		return Task{}, err
+	}
+	return c.transformPageToTask(*page, c.newMentionResolver(ctx))
+}
+
+// maxContentBlocks is how many body blocks GetTask renders
+const maxContentBlocks = 20
+
+// TaskDetail is a task with its page body, for the mini app's detail view
+type TaskDetail struct {
+	Task
+	Content    string    `json:"content"`
+	LastEdited time.Time `json:"last_edited"`
+}
+
+// GetTask retrieves a task with the first maxContentBlocks blocks of its body rendered as
+// markdown
+func (c *Client) GetTask(ctx context.Context, pageID string) (*TaskDetail, error) {
+	page, err := c.GetPage(ctx, pageID)
+	if err != nil {
+		return nil, err
+	}
+	mentions := c.newMentionResolver(ctx)
+	task, err := c.transformPageToTask(*page, mentions)
+	if err != nil {
+		return nil, err
 	}
 
-	// Add status property
-	updateRequest.Properties["status"] = notionapi.StatusProperty{
-		Status: notionapi.Option{
-			Name: status,
+	children, err := c.client.Block.GetChildren(ctx, notionapi.BlockID(page.ID), &notionapi.Pagination{PageSize: maxContentBlocks})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get page content: %w", err)
+	}
+	return &TaskDetail{
+		Task:       task,
+		Content:    renderBlocks(children.Results, mentions),
+		LastEdited: page.LastEditedTime,
+	}, nil
+}
+
+// UpdateTaskLLMTag updates the llm_tag property in Notion, as a select or text depending
+// on its type in the tasks database
+func (c *Client) UpdateTaskLLMTag(ctx context.Context, taskID, tag string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var value notionapi.Property = notionapi.RichTextProperty{
+		RichText: []notionapi.RichText{
+			{
+				Text: &notionapi.Text{
+					Content: tag,
+				},
+			},
 		},
 	}
+	dbProps, err := c.GetDatabaseProperties(ctx, "tasks")
+	if err != nil {
+		log.Printf("Warning: Could not fetch database properties, writing llm_tag as text: %v", err)
+	} else if prop, ok := dbProps["llm_tag"]; ok && prop.GetType() == notionapi.PropertyConfigTypeSelect {
+		value = notionapi.SelectProperty{Select: notionapi.Option{Name: tag}}
+	}
 
-	// Update the page in Notion
-	_, err := c.client.Page.Update(ctx, notionapi.PageID(taskID), updateRequest)
+	updateRequest := &notionapi.PageUpdateRequest{
+		Properties: notionapi.Properties{"llm_tag": value},
+	}
+
+	_, err = c.client.Page.Update(ctx, notionapi.PageID(taskID), updateRequest)
 	if err != nil {
-		// Handle button property error gracefully
-		if strings.Contains(err.Error(), "unsupported property type: button") {
-			log.Printf("Warning: Button property detected during update. Task status might not be updated correctly.")
+		// The update was applied, only the returned page couldn't be parsed
+		if strings.Contains(err.Error(), errButtonProperty) {
+			log.Printf("Warning: Button property in the updated page of %s, assuming llm_tag was set", taskID)
+			return nil
 		}
-		return fmt.Errorf("failed to update task: %w", err)
+		return fmt.Errorf("failed to update llm_tag: %w", err)
 	}
 
-	log.Printf("Successfully updated task %s status to %s", taskID, status)
+	log.Printf("Updated llm_tag='%s' for task %s", tag, taskID)
 	return nil
 }
 
-// GetPage retrieves a single page from Notion by ID
-func (c *Client) GetPage(ctx context.Context, pageID string) (*notionapi.Page, error) {
-	page, err := c.client.Page.Get(ctx, notionapi.PageID(pageID))
+// FindTaskByTitle looks for a task with exactly this title created at or after since.
+// It returns the page ID, or an empty string when there is no such task.
+func (c *Client) FindTaskByTitle(ctx context.Context, title string, since time.Time) (string, error) {
+	if c.taskDbID == "" {
+		return "", fmt.Errorf("database ID for tasks not configured")
+	}
+
+	// Notion filters on minutes, so allow for clock skew and compare titles locally
+	after := notionapi.Date(since.Add(-time.Minute))
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.TimestampFilter{
+			Timestamp:   notionapi.TimestampCreated,
+			CreatedTime: &notionapi.DateFilterCondition{OnOrAfter: &after},
+		},
+		PageSize: 100,
+	}
+
+	for {
+		response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(c.taskDbID), query)
+		if err != nil {
+			return "", fmt.Errorf("failed to query database: %w", err)
+		}
+
+		for _, page := range response.Results {
+			task, err := c.transformPageToTask(page, noLookups)
+			if err != nil {
+				continue
+			}
+			if task.Title == title {
+				return task.ID, nil
+			}
+		}
+
+		if !response.HasMore || response.NextCursor == "" {
+			return "", nil
+		}
+		query.StartCursor = response.NextCursor
+	}
+}
+
+// minSearchLength is the shortest text SearchTasks looks for
+const minSearchLength = 2
+
+// SearchTasks returns up to limit tasks whose title contains query, newest first. Without
+// a schema the title property can't be detected, and if it isn't called "Name" the
+// Search API is used instead. Queries shorter than two characters return an error
+// wrapping ErrInvalidQuery.
+func (c *Client) SearchTasks(ctx context.Context, dbType, query string, limit int) ([]Task, error) {
+	query = strings.TrimSpace(query)
+	if len([]rune(query)) < minSearchLength {
+		return nil, fmt.Errorf("%w: search text must be at least %d characters", ErrInvalidQuery, minSearchLength)
+	}
+
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get page: %w", err)
+		log.Printf("Warning: Could not fetch database properties for search: %v", err)
 	}
-	return page, nil
+	// Notion applies text conditions to title properties too, the library has no title filter
+	request := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.PropertyFilter{
+			Property: c.titlePropertyKey(dbID, dbProps),
+			RichText: &notionapi.TextFilterCondition{Contains: query},
+		},
+		Sorts: []notionapi.SortObject{
+			{Timestamp: notionapi.TimestampCreated, Direction: notionapi.SortOrderDESC},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	var tasks []Task
+	mentions := c.newMentionResolver(ctx)
+	err = c.queryPages(ctx, dbID, request, func(page notionapi.Page) bool {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			return true
+		}
+		tasks = append(tasks, task)
+		return len(tasks) < limit
+	})
+	if err != nil && isMissingTitleError(err) {
+		log.Printf("Title property is not named %q, searching with the Search API", defaultTitleKey)
+		return c.searchTasksWithSearchAPI(ctx, dbID, query, limit)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search tasks: %w", err)
+	}
+	return tasks, nil
 }
 
-// UpdateTaskLLMTag updates the llm_tag property in Notion
-func (c *Client) UpdateTaskLLMTag(taskID, tag string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// searchTasksWithSearchAPI searches all pages shared with the integration, keeping the
+// ones in the database. The Search API matches titles, like the database filter.
+func (c *Client) searchTasksWithSearchAPI(ctx context.Context, dbID, query string, limit int) ([]Task, error) {
+	request := &notionapi.SearchRequest{
+		Query:    query,
+		Filter:   notionapi.SearchFilter{Property: "object", Value: "page"},
+		PageSize: maxQueryPageSize,
+	}
+
+	var tasks []Task
+	mentions := c.newMentionResolver(ctx)
+	for {
+		response, err := c.client.Search.Do(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search tasks: %w", err)
+		}
+
+		for _, result := range response.Results {
+			page, ok := result.(*notionapi.Page)
+			if !ok || !sameID(string(page.Parent.DatabaseID), dbID) {
+				continue
+			}
+			task, err := c.transformPageToTask(*page, mentions)
+			if err != nil {
+				log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+				continue
+			}
+			tasks = append(tasks, task)
+			if len(tasks) >= limit {
+				return tasks, nil
+			}
+		}
+
+		if !response.HasMore || response.NextCursor == "" {
+			return tasks, nil
+		}
+		request.StartCursor = response.NextCursor
+	}
+}
+
+// sameID compares Notion IDs, which are written with or without hyphens
+func sameID(a, b string) bool {
+	return strings.EqualFold(strings.ReplaceAll(a, "-", ""), strings.ReplaceAll(b, "-", ""))
+}
+
+// UpdateTaskTitle replaces the title of a task, e.g. after its Telegram message was edited
+func (c *Client) UpdateTaskTitle(ctx context.Context, taskID, title string) error {
+	dbProps, err := c.GetDatabaseProperties(ctx, "tasks")
+	if err != nil {
+		log.Printf("Warning: Could not fetch database properties: %v", err)
+	}
 
 	updateRequest := &notionapi.PageUpdateRequest{
 		Properties: notionapi.Properties{
-			"llm_tag": notionapi.RichTextProperty{
-				RichText: []notionapi.RichText{
+			c.titlePropertyKey(c.taskDbID, dbProps): notionapi.TitleProperty{
+				Title: []notionapi.RichText{
 					{
+						Type: notionapi.ObjectType("text"),
 						Text: &notionapi.Text{
-							Content: tag,
+							Content: title,
 						},
 					},
 				},
@@ -1011,17 +1927,59 @@ func (c *Client) UpdateTaskLLMTag(taskID, tag string) error {
 		},
 	}
 
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(taskID), updateRequest); err != nil {
+		return fmt.Errorf("failed to update task title: %w", err)
+	}
+
+	log.Printf("Updated title of task %s", taskID)
+	return nil
+}
+
+// ArchiveTask archives (soft-deletes) a page in Notion
+func (c *Client) ArchiveTask(ctx context.Context, taskID string) error {
+	updateRequest := &notionapi.PageUpdateRequest{
+		Properties: notionapi.Properties{},
+		Archived:   true,
+	}
+
 	_, err := c.client.Page.Update(ctx, notionapi.PageID(taskID), updateRequest)
 	if err != nil {
-		return fmt.Errorf("failed to update llm_tag: %w", err)
+		return fmt.Errorf("failed to archive task: %w", err)
 	}
 
-	log.Printf("Updated llm_tag='%s' for task %s", tag, taskID)
+	log.Printf("Archived task %s", taskID)
 	return nil
 }
 
-// GetProjects retrieves projects from Notion
-func (c *Client) GetProjects(ctx context.Context) ([]map[string]interface{}, error) {
+// RestoreTask takes an archived page out of Notion's trash
+func (c *Client) RestoreTask(ctx context.Context, taskID string) error {
+	updateRequest := &notionapi.PageUpdateRequest{
+		Properties: notionapi.Properties{},
+		Archived:   false,
+	}
+
+	_, err := c.client.Page.Update(ctx, notionapi.PageID(taskID), updateRequest)
+	if err != nil {
+		return fmt.Errorf("failed to restore task: %w", err)
+	}
+
+	log.Printf("Restored task %s", taskID)
+	return nil
+}
+
+// Project is an entry of the projects database
+type Project struct {
+	ID         string                 `json:"id"`
+	URL        string                 `json:"url"`
+	Name       string                 `json:"name,omitempty"`
+	Status     string                 `json:"status,omitempty"`
+	Priority   string                 `json:"priority,omitempty"`
+	EndDate    string                 `json:"end_date,omitempty"` // DD/MM/YYYY
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GetProjects retrieves all projects, approaching deadlines first
+func (c *Client) GetProjects(ctx context.Context) ([]Project, error) {
 	dbID := c.projectsDbID
 	if dbID == "" {
 		return nil, fmt.Errorf("projects database ID not configured")
@@ -1035,109 +1993,92 @@ func (c *Client) GetProjects(ctx context.Context) ([]map[string]interface{}, err
 				Direction: "ascending", // Show approaching deadlines first
 			},
 		},
+		PageSize: maxQueryPageSize,
 	}
 
 	// Query the database
-	response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), queryRequest)
+	projects := make([]Project, 0)
+	err := c.queryPages(ctx, dbID, queryRequest, func(page notionapi.Page) bool {
+		projects = append(projects, transformPageToProject(page))
+		return true
+	})
 	if err != nil {
 		// Handle button property error gracefully
-		if strings.Contains(err.Error(), "unsupported property type: button") {
+		if strings.Contains(err.Error(), errButtonProperty) {
 			log.Printf("Warning: Button property detected during projects query.")
 			return nil, fmt.Errorf("button properties detected, not supported for projects view")
 		}
 		return nil, fmt.Errorf("failed to query projects: %w", err)
 	}
 
-	// Transform the results to a simplified format for the frontend
-	projects := make([]map[string]interface{}, 0, len(response.Results))
+	return projects, nil
+}
 
-	// Transform each page into a project
-	for _, page := range response.Results {
-		project := map[string]interface{}{
-			"id":         string(page.ID),
-			"url":        page.URL,
-			"properties": make(map[string]interface{}),
-		}
+// transformPageToProject converts a page of the projects database to a Project
+func transformPageToProject(page notionapi.Page) Project {
+	project := Project{
+		ID:         string(page.ID),
+		URL:        page.URL,
+		Properties: make(map[string]interface{}),
+	}
 
-		// Extract title from Project name property
-		if titleProp, ok := page.Properties["Project name"]; ok {
-			if title, ok := titleProp.(*notionapi.TitleProperty); ok && len(title.Title) > 0 {
-				project["name"] = title.Title[0].PlainText
-			}
-		} else if titleProp, ok := page.Properties["Name"]; ok {
-			if title, ok := titleProp.(*notionapi.TitleProperty); ok && len(title.Title) > 0 {
-				project["name"] = title.Title[0].PlainText
-			}
-		}
+	// Extract the name from the title property, usually "Project name"
+	titleKey := pageTitleKey(page)
+	if title, ok := page.Properties[titleKey].(*notionapi.TitleProperty); ok {
+		project.Name = noLookups.plainText(title.Title)
+	}
 
-		// Extract status
-		if statusProp, ok := page.Properties["Status"]; ok {
-			if status, ok := statusProp.(*notionapi.SelectProperty); ok && status.Select.Name != "" {
-				project["status"] = status.Select.Name
-			}
-		}
+	// Extract status, a select or Notion's status type
+	switch status := page.Properties["Status"].(type) {
+	case *notionapi.SelectProperty:
+		project.Status = status.Select.Name
+	case *notionapi.StatusProperty:
+		project.Status = status.Status.Name
+	}
 
-		// Extract priority
-		if priorityProp, ok := page.Properties["Priority"]; ok {
-			if priority, ok := priorityProp.(*notionapi.SelectProperty); ok && priority.Select.Name != "" {
-				project["priority"] = priority.Select.Name
-			}
-		}
+	// Extract priority
+	if priority, ok := page.Properties["Priority"].(*notionapi.SelectProperty); ok {
+		project.Priority = priority.Select.Name
+	}
 
-		// Extract end date
-		if dateProp, ok := page.Properties["End date"]; ok {
-			if date, ok := dateProp.(*notionapi.DateProperty); ok && date.Date != nil && date.Date.Start != nil {
-				// Create string from notionapi.Date
-				startTime := time.Time(*date.Date.Start)
-				project["end_date"] = startTime.Format("02/01/2006") // DD/MM/YYYY format
-			}
-		}
+	// Extract end date
+	if date, ok := page.Properties["End date"].(*notionapi.DateProperty); ok && date.Date != nil && date.Date.Start != nil {
+		project.EndDate = time.Time(*date.Date.Start).Format("02/01/2006") // DD/MM/YYYY format
+	}
 
-		// Add other properties that might be useful
-		projectProps := make(map[string]interface{})
-		for key, prop := range page.Properties {
-			if key == "Project name" || key == "Name" || key == "Status" || key == "Priority" || key == "End date" {
-				continue // Already handled above
-			}
+	// Add other properties that might be useful
+	for key, prop := range page.Properties {
+		if key == titleKey || key == "Status" || key == "Priority" || key == "End date" {
+			continue // Already handled above
+		}
 
-			// Skip button properties
-			if prop.GetType() == "button" {
-				continue
+		switch prop.GetType() {
+		case "select":
+			if selectProp, ok := prop.(*notionapi.SelectProperty); ok && selectProp.Select.Name != "" {
+				project.Properties[key] = selectProp.Select.Name
 			}
-
-			switch prop.GetType() {
-			case "select":
-				if selectProp, ok := prop.(*notionapi.SelectProperty); ok && selectProp.Select.Name != "" {
-					projectProps[key] = selectProp.Select.Name
-				}
-			case "multi_select":
-				if multiSelectProp, ok := prop.(*notionapi.MultiSelectProperty); ok {
-					tags := make([]string, 0, len(multiSelectProp.MultiSelect))
-					for _, opt := range multiSelectProp.MultiSelect {
-						tags = append(tags, opt.Name)
-					}
-					projectProps[key] = tags
-				}
-			case "date":
-				if dateProp, ok := prop.(*notionapi.DateProperty); ok && dateProp.Date != nil && dateProp.Date.Start != nil {
-					// Create string from notionapi.Date
-					startTime := time.Time(*dateProp.Date.Start)
-					projectProps[key] = startTime.Format("02/01/2006")
-				}
-			case "number":
-				if numProp, ok := prop.(*notionapi.NumberProperty); ok {
-					projectProps[key] = numProp.Number
-				}
-			case "checkbox":
-				if checkboxProp, ok := prop.(*notionapi.CheckboxProperty); ok {
-					projectProps[key] = checkboxProp.Checkbox
+		case "multi_select":
+			if multiSelectProp, ok := prop.(*notionapi.MultiSelectProperty); ok {
+				tags := make([]string, 0, len(multiSelectProp.MultiSelect))
+				for _, opt := range multiSelectProp.MultiSelect {
+					tags = append(tags, opt.Name)
 				}
+				project.Properties[key] = tags
+			}
+		case "date":
+			if dateProp, ok := prop.(*notionapi.DateProperty); ok && dateProp.Date != nil && dateProp.Date.Start != nil {
+				project.Properties[key] = time.Time(*dateProp.Date.Start).Format("02/01/2006")
+			}
+		case "number":
+			if numProp, ok := prop.(*notionapi.NumberProperty); ok {
+				project.Properties[key] = numProp.Number
+			}
+		case "checkbox":
+			if checkboxProp, ok := prop.(*notionapi.CheckboxProperty); ok {
+				project.Properties[key] = checkboxProp.Checkbox
 			}
 		}
-
-		project["properties"] = projectProps
-		projects = append(projects, project)
 	}
 
-	return projects, nil
+	return project
 }
@@ -0,0 +1,1129 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// recordedRequest is a single request received by fakeNotion
+type recordedRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// fakeNotion is an http.RoundTripper standing in for the Notion API
+type fakeNotion struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+	// respond returns the status code and JSON body for a request
+	respond func(method, path string, body []byte) (int, string)
+}
+
+func (f *fakeNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, recordedRequest{Method: req.Method, Path: req.URL.Path, Body: body})
+	f.mu.Unlock()
+
+	status, response := f.respond(req.Method, req.URL.Path, body)
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(response)),
+		Request:    req,
+	}, nil
+}
+
+// requestsTo returns recorded requests matching the method and path
+func (f *fakeNotion) requestsTo(method, path string) []recordedRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []recordedRequest
+	for _, r := range f.requests {
+		if r.Method == method && r.Path == path {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// newTestClient creates a Client whose API calls are served by the fake
+func newTestClient(fake *fakeNotion) *Client {
+	return &Client{
+		client:        notionapi.NewClient("test-token", notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+		apiToken:      "test-token",
+		rawHTTP:       &http.Client{Transport: fake},
+		taskDbID:      "tasks-db",
+		notesDbID:     "notes-db",
+		journalDbID:   "journal-db",
+		projectsDbID:  "projects-db",
+		dbCache:       make(map[string]map[string]notionapi.PropertyConfig),
+		dbCacheExpiry: make(map[string]time.Time),
+		titleKeys:     make(map[string]string),
+	}
+}
+
+// tasksSchemaJSON is a database response with a few common property types
+const tasksSchemaJSON = `{
+	"object": "database",
+	"id": "tasks-db",
+	"properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": [{"name": "home"}]}},
+		"Date": {"id": "date", "name": "Date", "type": "date", "date": {}},
+		"Estimate": {"id": "est", "name": "Estimate", "type": "number", "number": {"format": "number"}}
+	}
+}`
+
+// jsonEqual compares two JSON documents ignoring formatting and key order
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestPlanCreateTaskMatchesActualRequest(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch {
+		case method == http.MethodGet && path == "/v1/databases/tasks-db":
+			return http.StatusOK, tasksSchemaJSON
+		case method == http.MethodPost && path == "/v1/pages":
+			return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+	client := newTestClient(fake)
+
+	properties := map[string]interface{}{
+		"Tags":     []interface{}{"home"},
+		"Date":     "2024-06-01",
+		"Estimate": "3",
+	}
+
+	plan, err := client.PlanCreateTask(context.Background(), "Buy milk", properties, "tasks")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+	if plan.DatabaseID != "tasks-db" {
+		t.Errorf("Expected database tasks-db, got %s", plan.DatabaseID)
+	}
+	if len(fake.requestsTo(http.MethodPost, "/v1/pages")) != 0 {
+		t.Fatal("Planning must not create a page")
+	}
+
+	planned, err := json.Marshal(plan.Request)
+	if err != nil {
+		t.Fatalf("Failed to marshal plan: %v", err)
+	}
+
+	taskID, err := client.CreateTask(context.Background(), "Buy milk", properties, "tasks")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if taskID != "page-1" {
+		t.Errorf("Expected page-1, got %s", taskID)
+	}
+
+	created := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(created) != 1 {
+		t.Fatalf("Expected 1 create request, got %d", len(created))
+	}
+	if !jsonEqual(t, planned, created[0].Body) {
+		t.Errorf("Dry-run payload differs from actual request:\nplan:   %s\nactual: %s", planned, created[0].Body)
+	}
+
+	// The schema is cached, so only the first call fetches it
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/databases/tasks-db")); n != 1 {
+		t.Errorf("Expected schema to be fetched once, got %d", n)
+	}
+}
+
+func TestPlanCreateTaskReportsSkippedProperties(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, tasksSchemaJSON
+	}}
+	client := newTestClient(fake)
+
+	plan, err := client.PlanCreateTask(context.Background(), "Buy milk", map[string]interface{}{
+		"Unknown":     "value",
+		"done_button": true,
+		"Estimate":    "many",
+		"Date":        "next blue moon",
+	}, "tasks")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+
+	skipped := map[string]bool{}
+	for _, key := range plan.Skipped {
+		skipped[key] = true
+	}
+	for _, key := range []string{"Unknown", "done_button", "Estimate", "Date"} {
+		if !skipped[key] {
+			t.Errorf("Expected %s to be reported as skipped, got %v", key, plan.Skipped)
+		}
+		if _, ok := plan.Request.Properties[key]; ok {
+			t.Errorf("Skipped property %s must not be in the request", key)
+		}
+	}
+	if len(plan.Warnings) != 4 {
+		t.Errorf("Expected 4 warnings, got %v", plan.Warnings)
+	}
+}
+
+// localizedSchemaJSON is a database whose title property isn't called "Name"
+const localizedSchemaJSON = `{
+	"object": "database",
+	"id": "tasks-db",
+	"properties": {
+		"Название": {"id": "title", "name": "Название", "type": "title", "title": {}},
+		"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": []}}
+	}
+}`
+
+// missingNameJSON is the validation error Notion returns for a non-existent "Name" property
+const missingNameJSON = `{"object": "error", "status": 400, "code": "validation_error", "message": "Name is not a property that exists."}`
+
+// createdTitleKey returns the single property key of a recorded create request
+func createdTitleKey(t *testing.T, req recordedRequest) string {
+	t.Helper()
+
+	var body struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(req.Body, &body); err != nil {
+		t.Fatalf("Invalid create body %s: %v", req.Body, err)
+	}
+	for key := range body.Properties {
+		return key
+	}
+	return ""
+}
+
+func TestCreateTaskDetectsTitleProperty(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, localizedSchemaJSON
+		}
+		if strings.Contains(string(body), `"Name"`) {
+			return http.StatusBadRequest, missingNameJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.CreateTask(context.Background(), "Купить молоко", nil, "tasks"); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+	}
+
+	created := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(created) != 2 {
+		t.Fatalf("Expected 2 create requests without retries, got %d", len(created))
+	}
+	for _, req := range created {
+		if key := createdTitleKey(t, req); key != "Название" {
+			t.Errorf("Expected title keyed as Название, got %q", key)
+		}
+	}
+	if client.titleKeys["tasks-db"] != "Название" {
+		t.Errorf("Expected detected title key to be cached, got %q", client.titleKeys["tasks-db"])
+	}
+}
+
+func TestCreateTaskRetriesWithDetectedTitle(t *testing.T) {
+	var mu sync.Mutex
+	schemaRequests := 0
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			mu.Lock()
+			defer mu.Unlock()
+			schemaRequests++
+			// The schema is unavailable when the plan is built
+			if schemaRequests == 1 {
+				return http.StatusInternalServerError, `{"object": "error", "status": 500, "code": "internal_server_error", "message": "unavailable"}`
+			}
+			return http.StatusOK, localizedSchemaJSON
+		}
+		if strings.Contains(string(body), `"Name"`) {
+			return http.StatusBadRequest, missingNameJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+
+	taskID, err := client.CreateTask(context.Background(), "Купить молоко", nil, "tasks")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if taskID != "page-1" {
+		t.Errorf("Expected page-1, got %s", taskID)
+	}
+
+	created := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(created) != 2 {
+		t.Fatalf("Expected one failed attempt and one retry, got %d requests", len(created))
+	}
+	if key := createdTitleKey(t, created[0]); key != "Name" {
+		t.Errorf("First attempt should fall back to Name, got %q", key)
+	}
+	if key := createdTitleKey(t, created[1]); key != "Название" {
+		t.Errorf("Retry should use the detected title key, got %q", key)
+	}
+}
+
+func TestCreateTaskDoesNotRetryOtherErrors(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		return http.StatusBadRequest, `{"object": "error", "status": 400, "code": "validation_error", "message": "body failed validation"}`
+	}}
+	client := newTestClient(fake)
+
+	if _, err := client.CreateTask(context.Background(), "Buy milk", nil, "tasks"); err == nil {
+		t.Fatal("Expected CreateTask to fail")
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/pages")); n != 1 {
+		t.Errorf("Expected a single create attempt, got %d", n)
+	}
+}
+
+func TestCreateTaskLinksExternalFiles(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, `{"object": "database", "id": "tasks-db", "properties": {
+				"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+				"Attachments": {"id": "fi", "name": "Attachments", "type": "files", "files": {}}
+			}}`
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+
+	properties := map[string]interface{}{"Attachments": "https://example.com/files/receipt.jpg"}
+	if _, err := client.CreateTask(context.Background(), "Lunch receipt", properties, "tasks"); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	created := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(created) != 1 {
+		t.Fatalf("Expected 1 create request, got %d", len(created))
+	}
+	var body struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(created[0].Body, &body); err != nil {
+		t.Fatalf("Invalid request body: %v", err)
+	}
+	want := `{"files": [{"name": "receipt.jpg", "type": "external", "external": {"url": "https://example.com/files/receipt.jpg"}}]}`
+	if !jsonEqual(t, body.Properties["Attachments"], []byte(want)) {
+		t.Errorf("Expected %s, got %s", want, body.Properties["Attachments"])
+	}
+}
+
+func TestTransformPageToTaskUsesTitleProperty(t *testing.T) {
+	client := newTestClient(&fakeNotion{})
+	page := notionapi.Page{
+		ID: "page-1",
+		Properties: notionapi.Properties{
+			"Название": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Купить молоко"}}},
+		},
+	}
+
+	task, err := client.transformPageToTask(page, noLookups)
+	if err != nil {
+		t.Fatalf("transformPageToTask failed: %v", err)
+	}
+	if task.Title != "Купить молоко" {
+		t.Errorf("Expected title from localized property, got %q", task.Title)
+	}
+	if _, ok := task.Properties["Название"]; ok {
+		t.Error("Title property should not be duplicated in Properties")
+	}
+}
+
+// everyPropertyPageJSON is a page with a property of every type Notion returns
+const everyPropertyPageJSON = `{"object": "page", "id": "page-1", "properties": {
+	"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Plan trip"}, "plain_text": "Plan trip"}]},
+	"Notes": {"id": "n", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "Book early"}, "plain_text": "Book early"}]},
+	"Empty notes": {"id": "en", "type": "rich_text", "rich_text": []},
+	"Priority": {"id": "p", "type": "select", "select": {"name": "high"}},
+	"Tags": {"id": "t", "type": "multi_select", "multi_select": [{"name": "travel"}, {"name": "family"}]},
+	"Stage": {"id": "s", "type": "status", "status": {"name": "In progress"}},
+	"Date": {"id": "d", "type": "date", "date": {"start": "2024-03-17T00:00:00Z"}},
+	"No date": {"id": "nd", "type": "date", "date": null},
+	"Booked": {"id": "b", "type": "checkbox", "checkbox": true},
+	"Budget": {"id": "bu", "type": "number", "number": 1250.5},
+	"Link": {"id": "l", "type": "url", "url": "https://example.com/trip"},
+	"No link": {"id": "nl", "type": "url", "url": null},
+	"Email": {"id": "e", "type": "email", "email": "ann@example.com"},
+	"Phone": {"id": "ph", "type": "phone_number", "phone_number": "+7 900 000-00-00"},
+	"Assignee": {"id": "a", "type": "people", "people": [{"object": "user", "id": "user-1", "name": "Ann"}, {"object": "user", "id": "user-2"}]},
+	"Project": {"id": "r", "type": "relation", "relation": [{"id": "project-1"}, {"id": "project-2"}]},
+	"Days left": {"id": "f1", "type": "formula", "formula": {"type": "number", "number": 12}},
+	"Label": {"id": "f2", "type": "formula", "formula": {"type": "string", "string": "soon"}},
+	"Urgent": {"id": "f3", "type": "formula", "formula": {"type": "boolean", "boolean": true}},
+	"Reminder": {"id": "f4", "type": "formula", "formula": {"type": "date", "date": {"start": "2024-03-10T00:00:00Z"}}},
+	"Created": {"id": "ct", "type": "created_time", "created_time": "2024-03-01T09:30:00.000Z"},
+	"Edited": {"id": "et", "type": "last_edited_time", "last_edited_time": "2024-03-02T10:00:00.000Z"},
+	"Author": {"id": "cb", "type": "created_by", "created_by": {"object": "user", "id": "user-1", "name": "Ann"}},
+	"Editor": {"id": "eb", "type": "last_edited_by", "last_edited_by": {"object": "user", "id": "user-3"}},
+	"Tickets": {"id": "fi", "type": "files", "files": []},
+	"Total": {"id": "ro", "type": "rollup", "rollup": {"type": "number", "number": 3}}
+}}`
+
+func TestTransformPageToTaskCoversPropertyTypes(t *testing.T) {
+	var page notionapi.Page
+	if err := json.Unmarshal([]byte(everyPropertyPageJSON), &page); err != nil {
+		t.Fatalf("Invalid fixture: %v", err)
+	}
+	task, err := newTestClient(&fakeNotion{}).transformPageToTask(page, noLookups)
+	if err != nil {
+		t.Fatalf("transformPageToTask failed: %v", err)
+	}
+
+	tests := []struct {
+		property string
+		want     string // JSON of the value
+	}{
+		{"Notes", `"Book early"`},
+		{"Priority", `"high"`},
+		{"Tags", `["travel", "family"]`},
+		{"Stage", `"In progress"`},
+		{"Date", `"2024-03-17T00:00:00Z"`},
+		{"Booked", `true`},
+		{"Budget", `1250.5`},
+		{"Link", `"https://example.com/trip"`},
+		{"Email", `"ann@example.com"`},
+		{"Phone", `"+7 900 000-00-00"`},
+		{"Assignee", `["Ann", "user-2"]`},
+		{"Project", `["project-1", "project-2"]`},
+		{"Days left", `12`},
+		{"Label", `"soon"`},
+		{"Urgent", `true`},
+		{"Reminder", `"2024-03-10T00:00:00Z"`},
+		{"Created", `"2024-03-01T09:30:00Z"`},
+		{"Edited", `"2024-03-02T10:00:00Z"`},
+		{"Author", `"Ann"`},
+		{"Editor", `"user-3"`},
+		{"Tickets", `{"type": "files", "unsupported": true}`},
+		{"Total", `{"type": "rollup", "unsupported": true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.property, func(t *testing.T) {
+			value, ok := task.Properties[tt.property]
+			if !ok {
+				t.Fatalf("Expected %s in the task properties", tt.property)
+			}
+			got, err := json.Marshal(value)
+			if err != nil {
+				t.Fatalf("Could not encode %s: %v", tt.property, err)
+			}
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("Expected %s to be %s, got %s", tt.property, tt.want, got)
+			}
+		})
+	}
+
+	for _, empty := range []string{"Name", "Empty notes", "No date", "No link"} {
+		if value, ok := task.Properties[empty]; ok {
+			t.Errorf("Expected %s to be left out, got %v", empty, value)
+		}
+	}
+}
+
+func TestTransformPageToTaskResolvesMentions(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch path {
+		case "/v1/users/user-1":
+			return http.StatusOK, `{"object": "user", "id": "user-1", "type": "person", "name": "Ada"}`
+		case "/v1/pages/page-2":
+			return http.StatusOK, `{"object": "page", "id": "page-2", "properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Launch plan"}, "plain_text": "Launch plan"}]}}}`
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "Not found"}`
+	}}
+	client := newTestClient(fake)
+
+	start := notionapi.Date(time.Date(2025, 10, 12, 0, 0, 0, 0, time.UTC))
+	page := notionapi.Page{
+		ID: "page-1",
+		Properties: notionapi.Properties{
+			"Name": &notionapi.TitleProperty{Title: []notionapi.RichText{
+				{Type: "text", PlainText: "Ask "},
+				{Type: "mention", Mention: &notionapi.Mention{Type: "user", User: &notionapi.User{ID: "user-1"}}},
+				{Type: "text", PlainText: " about "},
+				{Type: "mention", Mention: &notionapi.Mention{Type: "page", Page: &notionapi.PageMention{ID: "page-2"}}, PlainText: "Untitled"},
+				{Type: "text", PlainText: " before "},
+				{Type: "mention", Mention: &notionapi.Mention{Type: "date", Date: &notionapi.DateObject{Start: &start}}},
+			}},
+		},
+	}
+
+	for run := 0; run < 2; run++ {
+		task, err := client.transformPageToTask(page, client.newMentionResolver(context.Background()))
+		if err != nil {
+			t.Fatalf("transformPageToTask failed: %v", err)
+		}
+		if expected := "Ask @Ada about Launch plan before 2025-10-12"; task.Title != expected {
+			t.Errorf("Run %d: expected %q, got %q", run, expected, task.Title)
+		}
+	}
+
+	// The second run is served from the cache
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/users/user-1")); n != 1 {
+		t.Errorf("Expected a single user lookup, got %d", n)
+	}
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/pages/page-2")); n != 1 {
+		t.Errorf("Expected a single page lookup, got %d", n)
+	}
+}
+
+func TestMentionLookupsAreCapped(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "user", "id": "user", "type": "person", "name": "Someone"}`
+	}}
+	client := newTestClient(fake)
+
+	var segments []notionapi.RichText
+	for i := 0; i < maxMentionLookups+5; i++ {
+		id := notionapi.UserID(fmt.Sprintf("user-%d", i))
+		segments = append(segments, notionapi.RichText{
+			Type:      "mention",
+			Mention:   &notionapi.Mention{Type: "user", User: &notionapi.User{ID: id}},
+			PlainText: "@?",
+		})
+	}
+
+	text := client.newMentionResolver(context.Background()).plainText(segments)
+	if n := len(fake.requests); n != maxMentionLookups {
+		t.Errorf("Expected %d lookups, got %d", maxMentionLookups, n)
+	}
+	// Mentions over the budget fall back to Notion's plain text
+	if !strings.HasSuffix(text, "@?@?@?@?@?") {
+		t.Errorf("Expected unresolved mentions to keep their plain text, got %q", text)
+	}
+}
+
+func TestMentionCacheStaysBounded(t *testing.T) {
+	client := newTestClient(&fakeNotion{})
+
+	for i := 0; i < 100000; i++ {
+		// A fresh resolver per run, so the lookup budget doesn't stop the test
+		resolver := client.newMentionResolver(context.Background())
+		resolver.lookup(fmt.Sprintf("user:%d", i), func() (string, error) { return "Someone", nil })
+	}
+
+	if n := client.mentionNames.Len(); n != mentionCacheCap {
+		t.Errorf("Expected %d cached names, got %d", mentionCacheCap, n)
+	}
+}
+
+// listingPagesJSON is a query response with one open and one done task
+const listingPagesJSON = `{"object": "list", "has_more": false, "results": [
+	{"object": "page", "id": "page-1", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "plain_text": "Buy milk", "text": {"content": "Buy milk"}}]},
+		"status": {"id": "st", "type": "select", "select": {"name": "todo"}}}},
+	{"object": "page", "id": "page-2", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "plain_text": "Call mum", "text": {"content": "Call mum"}}]},
+		"status": {"id": "st", "type": "select", "select": {"name": "done"}}}}
+]}`
+
+// buttonPagesJSON is a response the library fails to parse because of a button property
+const buttonPagesJSON = `{"object": "list", "results": [
+	{"object": "page", "id": "page-1", "properties": {"Complete": {"id": "btn", "type": "button", "button": {}}}}
+]}`
+
+// buttonSchemaJSON is a database the library fails to parse because of a button property
+const buttonSchemaJSON = `{"object": "database", "id": "tasks-db", "properties": {
+	"Complete": {"id": "btn", "name": "Complete", "type": "button", "button": {}}
+}}`
+
+// newListingFake serves listings; filtered queries fail with the button error while
+// brokenQueries is positive, and the schema fetch fails while brokenSchema is set
+func newListingFake(brokenQueries int, brokenSchema bool) *fakeNotion {
+	var mu sync.Mutex
+	return &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if method == http.MethodGet {
+			if brokenSchema {
+				return http.StatusOK, buttonSchemaJSON
+			}
+			return http.StatusOK, tasksSchemaJSON
+		}
+		if strings.Contains(string(body), `"filter"`) && brokenQueries > 0 {
+			brokenQueries--
+			return http.StatusOK, buttonPagesJSON
+		}
+		return http.StatusOK, listingPagesJSON
+	}}
+}
+
+func TestGetRecentTasksReportsHealthyListing(t *testing.T) {
+	client := newTestClient(newListingFake(0, false))
+
+	tasks, info, err := client.GetRecentTasksWithInfo(context.Background(), "tasks", 10)
+	if err != nil {
+		t.Fatalf("GetRecentTasksWithInfo failed: %v", err)
+	}
+	expected := ListInfo{Filters: "server", PagesScanned: 2, PagesMatched: 2}
+	if *info != expected || len(tasks) != 2 {
+		t.Errorf("Expected %+v, got %+v with %d tasks", expected, *info, len(tasks))
+	}
+}
+
+func TestGetRecentTasksReportsDegradedListing(t *testing.T) {
+	fake := newListingFake(1000, true)
+	client := newTestClient(fake)
+
+	tasks, info, err := client.GetRecentTasksWithInfo(context.Background(), "tasks", 10)
+	if err != nil {
+		t.Fatalf("GetRecentTasksWithInfo failed: %v", err)
+	}
+	if !info.Degraded || info.Filters != "client" || info.PagesScanned != 2 || info.PagesMatched != 1 {
+		t.Errorf("Expected a degraded client-side listing matching 1 of 2 pages, got %+v", *info)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Buy milk" {
+		t.Errorf("Expected only the open task, got %+v", tasks)
+	}
+	if !client.SchemaGuessed("tasks") {
+		t.Error("Expected the schema to be marked as guessed")
+	}
+
+	// The schema refresh isn't repeated on every listing while the database stays broken
+	schemaFetches := len(fake.requestsTo(http.MethodGet, "/v1/databases/tasks-db"))
+	if _, _, err := client.GetRecentTasksWithInfo(context.Background(), "tasks", 10); err != nil {
+		t.Fatalf("GetRecentTasksWithInfo failed: %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/databases/tasks-db")); n != schemaFetches {
+		t.Errorf("Expected no extra schema fetch, got %d after %d", n, schemaFetches)
+	}
+}
+
+func TestGetRecentTasksRetriesAfterSchemaRefresh(t *testing.T) {
+	fake := newListingFake(1, false)
+	client := newTestClient(fake)
+
+	_, info, err := client.GetRecentTasksWithInfo(context.Background(), "tasks", 10)
+	if err != nil {
+		t.Fatalf("GetRecentTasksWithInfo failed: %v", err)
+	}
+	if info.Degraded || info.Filters != "server" {
+		t.Errorf("Expected the retried primary path to succeed, got %+v", *info)
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")); n != 2 {
+		t.Errorf("Expected the failed query and one retry, got %d queries", n)
+	}
+	if client.SchemaGuessed("tasks") {
+		t.Error("A full schema must not be marked as guessed")
+	}
+}
+
+// pagedQueryFake serves total numbered pages to queries, pageSize at a time, with cursors
+func pagedQueryFake(total int) *fakeNotion {
+	return &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+
+		var query struct {
+			StartCursor string `json:"start_cursor"`
+			PageSize    int    `json:"page_size"`
+		}
+		json.Unmarshal(body, &query)
+		start := 0
+		if query.StartCursor != "" {
+			fmt.Sscanf(query.StartCursor, "cursor-%d", &start)
+		}
+		end := start + query.PageSize
+		if end > total {
+			end = total
+		}
+
+		results := make([]string, 0, end-start)
+		for i := start; i < end; i++ {
+			results = append(results, fmt.Sprintf(`{"object": "page", "id": "page-%d", "properties": {
+				"Name": {"id": "title", "type": "title", "title": [{"type": "text", "plain_text": "Task %d", "text": {"content": "Task %d"}}]}}}`, i, i, i))
+		}
+		nextCursor := "null"
+		if end < total {
+			nextCursor = fmt.Sprintf(`"cursor-%d"`, end)
+		}
+		return http.StatusOK, fmt.Sprintf(`{"object": "list", "results": [%s], "has_more": %t, "next_cursor": %s}`,
+			strings.Join(results, ","), end < total, nextCursor)
+	}}
+}
+
+func TestGetRecentTasksFollowsCursors(t *testing.T) {
+	fake := pagedQueryFake(250)
+	client := newTestClient(fake)
+
+	tasks, err := client.GetRecentTasks(context.Background(), "tasks", 230)
+	if err != nil {
+		t.Fatalf("GetRecentTasks failed: %v", err)
+	}
+	if len(tasks) != 230 {
+		t.Fatalf("Expected 230 tasks, got %d", len(tasks))
+	}
+	for i, task := range tasks {
+		if task.ID != fmt.Sprintf("page-%d", i) {
+			t.Fatalf("Expected page-%d at position %d, got %s", i, i, task.ID)
+		}
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")); n != 3 {
+		t.Errorf("Expected 3 query requests, got %d", n)
+	}
+
+	// Everything is returned when the database holds fewer tasks than the limit
+	client = newTestClient(pagedQueryFake(150))
+	if tasks, err := client.GetRecentTasks(context.Background(), "tasks", 1000); err != nil || len(tasks) != 150 {
+		t.Errorf("Expected all 150 tasks, got %d (%v)", len(tasks), err)
+	}
+}
+
+func TestButtonWorkaroundFollowsCursors(t *testing.T) {
+	paged := pagedQueryFake(250)
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, buttonSchemaJSON
+		}
+		if strings.Contains(string(body), `"filter"`) {
+			return http.StatusOK, buttonPagesJSON
+		}
+		return paged.respond(method, path, body)
+	}}
+	client := newTestClient(fake)
+
+	tasks, info, err := client.GetRecentTasksWithInfo(context.Background(), "tasks", 180)
+	if err != nil {
+		t.Fatalf("GetRecentTasksWithInfo failed: %v", err)
+	}
+	if !info.Degraded || len(tasks) != 180 || info.PagesScanned != 180 {
+		t.Fatalf("Expected 180 tasks from the workaround, got %d (%+v)", len(tasks), *info)
+	}
+	if tasks[0].ID != "page-0" || tasks[179].ID != "page-179" {
+		t.Errorf("Expected tasks in query order, got %s..%s", tasks[0].ID, tasks[179].ID)
+	}
+}
+
+func TestUpdateTaskLLMTagFollowsSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		expected string
+	}{
+		{"select", `{"object": "database", "id": "tasks-db", "properties": {
+			"llm_tag": {"id": "tag", "name": "llm_tag", "type": "select", "select": {"options": []}}}}`,
+			`{"select": {"name": "work"}}`},
+		{"rich_text", `{"object": "database", "id": "tasks-db", "properties": {
+			"llm_tag": {"id": "tag", "name": "llm_tag", "type": "rich_text", "rich_text": {}}}}`,
+			`{"rich_text": [{"text": {"content": "work"}}]}`},
+		{"schema unavailable", buttonSchemaJSON, `{"rich_text": [{"text": {"content": "work"}}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				if method == http.MethodGet {
+					return http.StatusOK, tt.schema
+				}
+				if path == "/v1/databases/tasks-db/query" {
+					return http.StatusOK, `{"object": "list", "results": []}`
+				}
+				return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+			}}
+			client := newTestClient(fake)
+
+			if err := client.UpdateTaskLLMTag(context.Background(), "page-1", "work"); err != nil {
+				t.Fatalf("UpdateTaskLLMTag failed: %v", err)
+			}
+			updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")
+			if len(updates) != 1 {
+				t.Fatalf("Expected 1 update, got %d", len(updates))
+			}
+			var body struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+			json.Unmarshal(updates[0].Body, &body)
+			if !jsonEqual(t, body.Properties["llm_tag"], []byte(tt.expected)) {
+				t.Errorf("Expected llm_tag %s, got %s", tt.expected, body.Properties["llm_tag"])
+			}
+		})
+	}
+}
+
+func TestUpdateTaskLLMTagToleratesButtonResponse(t *testing.T) {
+	client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {"Complete": {"id": "btn", "type": "button", "button": {}}}}`
+	}})
+
+	if err := client.UpdateTaskLLMTag(context.Background(), "page-1", "work"); err != nil {
+		t.Errorf("Expected an unparseable button page after the update to be tolerated, got %v", err)
+	}
+}
+
+func TestGetUndoneTasksQuery(t *testing.T) {
+	fake := newListingFake(0, false)
+	client := newTestClient(fake)
+
+	if _, err := client.GetUndoneTasksExcludingSometimesLater(context.Background(), "tasks", 1000); err != nil {
+		t.Fatalf("GetUndoneTasksExcludingSometimesLater failed: %v", err)
+	}
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if len(queries) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(queries))
+	}
+	expected := `{
+		"filter": {"and": [
+			{"property": "status", "select": {"does_not_equal": "done"}},
+			{"property": "tags", "multi_select": {"does_not_contain": "sometimes-later"}}
+		]},
+		"sorts": [{"property": "Created time", "direction": "descending"}],
+		"page_size": 100
+	}`
+	if !jsonEqual(t, queries[0].Body, []byte(expected)) {
+		t.Errorf("Unexpected query body: %s", queries[0].Body)
+	}
+}
+
+func TestGetUndoneTasksFallsBackOnButtons(t *testing.T) {
+	client := newTestClient(newListingFake(1000, true))
+
+	tasks, err := client.GetUndoneTasksExcludingSometimesLater(context.Background(), "tasks", 1000)
+	if err != nil {
+		t.Fatalf("GetUndoneTasksExcludingSometimesLater failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Buy milk" {
+		t.Errorf("Expected only the open task from the workaround, got %+v", tasks)
+	}
+}
+
+func TestGetDbIDForTypeRoutesDatabases(t *testing.T) {
+	client := newTestClient(&fakeNotion{})
+
+	expected := map[string]string{
+		"tasks":    "tasks-db",
+		"notes":    "notes-db",
+		"journal":  "journal-db",
+		"projects": "projects-db",
+		"":         "tasks-db",
+		"unknown":  "tasks-db",
+	}
+	for dbType, want := range expected {
+		if got := client.getDbIDForType(dbType); got != want {
+			t.Errorf("getDbIDForType(%q) = %q, expected %q", dbType, got, want)
+		}
+	}
+}
+
+func TestPlanCreateTaskTargetsJournal(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet && path == "/v1/databases/journal-db" {
+			return http.StatusOK, `{"object": "database", "id": "journal-db", "properties": {
+				"Entry": {"id": "title", "name": "Entry", "type": "title", "title": {}}
+			}}`
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+	client := newTestClient(fake)
+
+	plan, err := client.PlanCreateTask(context.Background(), "Long walk by the river", nil, "journal")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+	if plan.DatabaseID != "journal-db" {
+		t.Errorf("Expected database journal-db, got %s", plan.DatabaseID)
+	}
+	if _, ok := plan.Request.Properties["Entry"]; !ok {
+		t.Errorf("Expected the journal title property, got %v", plan.Request.Properties)
+	}
+}
+
+func TestGetTaskByIDNormalizesIDs(t *testing.T) {
+	const hyphenated = "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if path != "/v1/pages/"+hyphenated {
+			return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find page"}`
+		}
+		return http.StatusOK, `{"object": "page", "id": "` + hyphenated + `", "url": "https://www.notion.so/Call-the-bank-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d",
+			"properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Call the bank"}, "plain_text": "Call the bank"}]}}}`
+	}}
+	client := newTestClient(fake)
+
+	for _, id := range []string{hyphenated, "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d", "1A2B3C4D5E6F7A8B9C0D1E2F3A4B5C6D"} {
+		task, err := client.GetTaskByID(context.Background(), id)
+		if err != nil {
+			t.Errorf("GetTaskByID(%q) failed: %v", id, err)
+			continue
+		}
+		if task.ID != hyphenated || task.Title != "Call the bank" {
+			t.Errorf("GetTaskByID(%q) = %+v, expected the page's task", id, task)
+		}
+	}
+}
+
+func TestGetPageMapsNotFound(t *testing.T) {
+	status := http.StatusNotFound
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return status, fmt.Sprintf(`{"object": "error", "status": %d, "code": "error", "message": "failed"}`, status)
+	}}
+	client := newTestClient(fake)
+
+	_, err := client.GetTaskByID(context.Background(), "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a 404, got %v", err)
+	}
+	if notionErr, ok := AsNotionError(err); !ok || notionErr.Status != http.StatusNotFound {
+		t.Errorf("Expected the Notion error to stay in the chain, got %v", err)
+	}
+
+	status = http.StatusInternalServerError
+	if _, err := client.GetPage(context.Background(), "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected a server error not to look like a deleted page, got %v", err)
+	}
+}
+
+func TestTransformPageToProject(t *testing.T) {
+	end := notionapi.Date(time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC))
+	page := notionapi.Page{
+		ID:  "project-1",
+		URL: "https://www.notion.so/project-1",
+		Properties: notionapi.Properties{
+			"Project name": &notionapi.TitleProperty{Title: []notionapi.RichText{{PlainText: "Garden"}}},
+			"Status":       &notionapi.StatusProperty{Type: "status", Status: notionapi.Status{Name: "In progress"}},
+			"Priority":     &notionapi.SelectProperty{Type: "select", Select: notionapi.Option{Name: "High"}},
+			"End date":     &notionapi.DateProperty{Type: "date", Date: &notionapi.DateObject{Start: &end}},
+			"Area":         &notionapi.SelectProperty{Type: "select", Select: notionapi.Option{Name: "Home"}},
+		},
+	}
+
+	project := transformPageToProject(page)
+	if project.ID != "project-1" || project.Name != "Garden" || project.Status != "In progress" {
+		t.Errorf("Unexpected project %+v", project)
+	}
+	if project.Priority != "High" || project.EndDate != "30/06/2024" {
+		t.Errorf("Expected priority and end date, got %+v", project)
+	}
+	if len(project.Properties) != 1 || project.Properties["Area"] != "Home" {
+		t.Errorf("Expected only the remaining properties, got %v", project.Properties)
+	}
+}
+
+func TestGetProjectsQueriesProjectsDatabase(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "list", "has_more": false, "results": [
+			{"object": "page", "id": "project-1", "properties": {
+				"Name": {"id": "title", "type": "title", "title": [{"type": "text", "plain_text": "Garden", "text": {"content": "Garden"}}]},
+				"Status": {"id": "st", "type": "select", "select": {"name": "Planned"}}
+			}}
+		]}`
+	}}
+	client := newTestClient(fake)
+
+	projects, err := client.GetProjects(context.Background())
+	if err != nil {
+		t.Fatalf("GetProjects failed: %v", err)
+	}
+	if len(fake.requestsTo(http.MethodPost, "/v1/databases/projects-db/query")) != 1 {
+		t.Error("Expected the projects database to be queried")
+	}
+	if len(projects) != 1 || projects[0].Name != "Garden" || projects[0].Status != "Planned" {
+		t.Errorf("Unexpected projects %+v", projects)
+	}
+}
+
+func TestSchemaCacheIsSafeForConcurrentUse(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, tasksSchemaJSON
+	}}
+	client := newTestClient(fake)
+
+	// Run with -race, overlapping reads, fetches and invalidations
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				props, err := client.GetDatabaseProperties(context.Background(), "tasks")
+				if err != nil {
+					t.Errorf("GetDatabaseProperties failed: %v", err)
+					return
+				}
+				client.titlePropertyKey("tasks-db", props)
+				client.SchemaGuessed("tasks")
+				if (i+j)%10 == 0 {
+					client.InvalidateCache("tasks")
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestInvalidateCacheRefetchesSchema(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, tasksSchemaJSON
+	}}
+	client := newTestClient(fake)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetDatabaseProperties(context.Background(), "tasks"); err != nil {
+			t.Fatalf("GetDatabaseProperties failed: %v", err)
+		}
+	}
+	client.InvalidateCache("tasks")
+	if _, err := client.GetDatabaseProperties(context.Background(), "tasks"); err != nil {
+		t.Fatalf("GetDatabaseProperties failed: %v", err)
+	}
+
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/databases/tasks-db")); n != 2 {
+		t.Errorf("Expected one fetch before and one after invalidation, got %d", n)
+	}
+}
+
+func TestCachedSchemasAndInvalidateAll(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, tasksSchemaJSON
+	}}
+	client := newTestClient(fake)
+
+	for _, dbType := range []string{"tasks", "notes"} {
+		if _, err := client.GetDatabaseProperties(context.Background(), dbType); err != nil {
+			t.Fatalf("GetDatabaseProperties failed: %v", err)
+		}
+	}
+	schemas := client.CachedSchemas()
+	if len(schemas) != 2 || schemas[0].DatabaseType != "tasks" || schemas[1].DatabaseID != "notes-db" {
+		t.Fatalf("Expected the tasks and notes schemas, got %+v", schemas)
+	}
+	tasks := schemas[0]
+	if tasks.Properties["Tags"] != "multi_select" || len(tasks.Options["Tags"]) != 1 || tasks.Options["Tags"][0] != "home" {
+		t.Errorf("Expected the Tags property with its option, got %+v", tasks)
+	}
+	if tasks.Expired || !tasks.ExpiresAt.After(time.Now()) {
+		t.Errorf("Expected a future expiry, got %v", tasks.ExpiresAt)
+	}
+
+	client.InvalidateAllCaches()
+	if schemas := client.CachedSchemas(); len(schemas) != 0 {
+		t.Errorf("Expected no cached schemas after invalidating all, got %+v", schemas)
+	}
+	if _, err := client.GetDatabaseProperties(context.Background(), "notes"); err != nil {
+		t.Fatalf("GetDatabaseProperties failed: %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/databases/notes-db")); n != 2 {
+		t.Errorf("Expected the notes schema to be fetched again, got %d fetches", n)
+	}
+}
+
+// relationSchemaJSON is a tasks database whose Project property links to the projects database
+const relationSchemaJSON = `{"object": "database", "id": "tasks-db", "properties": {
+	"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+	"Project": {"id": "rel", "name": "Project", "type": "relation", "relation": {"database_id": "projects-db"}}
+}}`
+
+// projectsJSON is a projects database query returning two projects
+const projectsJSON = `{"object": "list", "has_more": false, "results": [
+	{"object": "page", "id": "project-1", "properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "plain_text": "Garden", "text": {"content": "Garden"}}]}}},
+	{"object": "page", "id": "project-2", "properties": {"Name": {"id": "title", "type": "title", "title": [{"type": "text", "plain_text": "Tax return", "text": {"content": "Tax return"}}]}}}
+]}`
+
+func newRelationTestClient() (*Client, *fakeNotion) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch {
+		case method == http.MethodGet:
+			return http.StatusOK, relationSchemaJSON
+		case path == "/v1/databases/projects-db/query":
+			return http.StatusOK, projectsJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-42", "properties": {}}`
+	}}
+	return newTestClient(fake), fake
+}
+
+func TestRelationPropertyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		lookups int // Projects database queries
+	}{
+		{"page id", "0123456789abcdef0123456789ABCDEF", `{"relation": [{"id": "01234567-89ab-cdef-0123-456789abcdef"}]}`, 0},
+		{"page url", "https://www.notion.so/Garden-0123456789abcdef0123456789abcdef", `{"relation": [{"id": "01234567-89ab-cdef-0123-456789abcdef"}]}`, 0},
+		{"project name", "garden", `{"relation": [{"id": "project-1"}]}`, 1},
+		{"several", []interface{}{"Tax return", "0123456789abcdef0123456789abcdef", "Garden"}, `{"relation": [{"id": "project-2"}, {"id": "01234567-89ab-cdef-0123-456789abcdef"}, {"id": "project-1"}]}`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newRelationTestClient()
+
+			plan, err := client.PlanCreateTask(context.Background(), "Weed the beds", map[string]interface{}{"Project": tt.value}, "tasks")
+			if err != nil {
+				t.Fatalf("PlanCreateTask failed: %v", err)
+			}
+			got, _ := json.Marshal(plan.Request.Properties["Project"])
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/projects-db/query")); n != tt.lookups {
+				t.Errorf("Expected %d project lookups, got %d", tt.lookups, n)
+			}
+		})
+	}
+}
+
+func TestRelationProjectNamesAreCached(t *testing.T) {
+	client, fake := newRelationTestClient()
+
+	for _, name := range []string{"Garden", "tax return"} {
+		if _, err := client.PlanCreateTask(context.Background(), "Task", map[string]interface{}{"Project": name}, "tasks"); err != nil {
+			t.Fatalf("PlanCreateTask(%q) failed: %v", name, err)
+		}
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/projects-db/query")); n != 1 {
+		t.Errorf("Expected the second name to come from the cache, got %d lookups", n)
+	}
+}
+
+func TestUnknownRelationNameFailsTheTask(t *testing.T) {
+	client, fake := newRelationTestClient()
+
+	_, err := client.CreateTask(context.Background(), "Task", map[string]interface{}{"Project": "Holiday"}, "tasks")
+	if !errors.Is(err, ErrRelationNotFound) {
+		t.Fatalf("Expected ErrRelationNotFound, got %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/pages")); n != 0 {
+		t.Errorf("Expected no task to be created without its link, got %d creates", n)
+	}
+}
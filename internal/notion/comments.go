@@ -0,0 +1,32 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// AddComment posts text as a comment on a page. Text over the rich text limit is split
+// into several rich text objects of the same comment.
+func (c *Client) AddComment(ctx context.Context, pageID, text string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	var richText []notionapi.RichText
+	for _, chunk := range chunkText(text, maxRichTextLength) {
+		richText = append(richText, notionapi.RichText{Text: &notionapi.Text{Content: chunk}})
+	}
+	request := &notionapi.CommentCreateRequest{
+		Parent:   notionapi.Parent{Type: notionapi.ParentTypePageID, PageID: notionapi.PageID(pageID)},
+		RichText: richText,
+	}
+	if _, err := c.client.Comment.Create(ctx, request); err != nil {
+		return fmt.Errorf("failed to comment on page %s: %w", pageID, err)
+	}
+
+	log.Printf("Added a comment to page %s", pageID)
+	return nil
+}
@@ -0,0 +1,58 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestAddComment(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "comment", "id": "comment-1", "parent": {"type": "page_id", "page_id": "page-42"}, "rich_text": []}`
+	}}
+	client := newTestClient(fake)
+
+	text := "Created from Telegram voice message; original transcript: " + strings.Repeat("a", maxRichTextLength)
+	if err := client.AddComment(context.Background(), "page-42", text); err != nil {
+		t.Fatalf("AddComment failed: %v", err)
+	}
+
+	requests := fake.requestsTo(http.MethodPost, "/v1/comments")
+	if len(requests) != 1 {
+		t.Fatalf("Expected one comment request, got %d", len(requests))
+	}
+	var body struct {
+		Parent struct {
+			Type   string `json:"type"`
+			PageID string `json:"page_id"`
+		} `json:"parent"`
+		RichText []struct {
+			Text struct {
+				Content string `json:"content"`
+			} `json:"text"`
+		} `json:"rich_text"`
+	}
+	if err := json.Unmarshal(requests[0].Body, &body); err != nil {
+		t.Fatalf("Invalid request body %s: %v", requests[0].Body, err)
+	}
+	if body.Parent.Type != "page_id" || body.Parent.PageID != "page-42" {
+		t.Errorf("Expected the comment on page-42, got %+v", body.Parent)
+	}
+	if len(body.RichText) != 2 || body.RichText[0].Text.Content+body.RichText[1].Text.Content != text {
+		t.Errorf("Expected the text split into 2 rich text objects, got %+v", body.RichText)
+	}
+}
+
+func TestAddCommentFails(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusForbidden, `{"object": "error", "status": 403, "code": "restricted_resource", "message": "Insufficient permissions for this endpoint."}`
+	}}
+	client := newTestClient(fake)
+
+	err := client.AddComment(context.Background(), "page-42", "Created from a forwarded message")
+	if err == nil || !strings.Contains(err.Error(), "page-42") {
+		t.Errorf("Expected an error naming the page, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jomei/notionapi"
+)
+
+const (
+	// defaultTitleMaxLength is how long a single-line text may get before it's split into
+	// a title and page body
+	defaultTitleMaxLength = 200
+
+	// maxRichTextLength is Notion's limit for the content of one rich text object
+	maxRichTextLength = 2000
+
+	// maxBlocksPerRequest is how many children Notion accepts in one create or append request
+	maxBlocksPerRequest = 100
+)
+
+// titleMaxLengthFromEnv returns the threshold set by NOTION_TITLE_MAX_LENGTH, or the default
+func titleMaxLengthFromEnv() int {
+	value := os.Getenv("NOTION_TITLE_MAX_LENGTH")
+	if value == "" {
+		return defaultTitleMaxLength
+	}
+	length, err := strconv.Atoi(value)
+	if err != nil || length <= 0 {
+		log.Printf("Warning: Invalid NOTION_TITLE_MAX_LENGTH %q, using %d", value, defaultTitleMaxLength)
+		return defaultTitleMaxLength
+	}
+	return length
+}
+
+// SplitContent splits a message into a page title and body. Texts with several lines or
+// longer than the title threshold keep their first line as the title and the rest goes
+// into the body. A first line that is too long itself is shortened for the title and
+// the whole text becomes the body, so nothing is lost.
+func (c *Client) SplitContent(text string) (title, content string) {
+	maxLength := c.titleMaxLength
+	if maxLength <= 0 {
+		maxLength = defaultTitleMaxLength
+	}
+	return splitContent(text, maxLength)
+}
+
+func splitContent(text string, maxLength int) (title, content string) {
+	text = strings.TrimSpace(text)
+	firstLine, rest, _ := strings.Cut(text, "\n")
+	firstLine = strings.TrimSpace(firstLine)
+	rest = strings.TrimSpace(rest)
+
+	if len([]rune(firstLine)) <= maxLength {
+		return firstLine, rest
+	}
+	return shortenTitle(firstLine, maxLength), text
+}
+
+// shortenTitle cuts a line at the last word boundary within maxLength and marks the cut
+func shortenTitle(line string, maxLength int) string {
+	runes := []rune(line)
+	cut := maxLength - 1 // Room for the ellipsis
+	for i := cut; i > cut/2; i-- {
+		if unicode.IsSpace(runes[i]) {
+			cut = i
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…"
+}
+
+// contentBlocks turns text into paragraph blocks, one per line. Lines longer than Notion
+// allows for one rich text object are chunked into several within the same paragraph.
+func contentBlocks(content string) []notionapi.Block {
+	var blocks []notionapi.Block
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			continue
+		}
+
+		var richText []notionapi.RichText
+		for _, chunk := range chunkText(line, maxRichTextLength) {
+			richText = append(richText, notionapi.RichText{
+				Type: notionapi.ObjectType("text"),
+				Text: &notionapi.Text{Content: chunk},
+			})
+		}
+		blocks = append(blocks, notionapi.ParagraphBlock{
+			BasicBlock: notionapi.BasicBlock{
+				Object: notionapi.ObjectTypeBlock,
+				Type:   notionapi.BlockTypeParagraph,
+			},
+			Paragraph: notionapi.Paragraph{RichText: richText},
+		})
+	}
+	return blocks
+}
+
+// chunkText splits text into pieces of at most limit characters. Notion counts length in
+// UTF-16 code units, so characters outside the BMP like most emoji count twice and are
+// never split in half.
+func chunkText(text string, limit int) []string {
+	var chunks []string
+	start, length := 0, 0
+	for i, r := range text {
+		width := 1
+		if r > 0xFFFF {
+			width = 2
+		}
+		if length+width > limit {
+			chunks = append(chunks, text[start:i])
+			start, length = i, 0
+		}
+		length += width
+	}
+	if start < len(text) {
+		chunks = append(chunks, text[start:])
+	}
+	return chunks
+}
+
+// SetContent adds text as paragraph blocks to the body of the planned page
+func (p *CreatePlan) SetContent(content string) {
+	p.Request.Children = contentBlocks(content)
+}
+
+// appendRemainingBlocks adds body blocks to a page, like those beyond the first request's
+// limit to a created page
+func (c *Client) appendRemainingBlocks(ctx context.Context, pageID string, blocks []notionapi.Block) error {
+	for len(blocks) > 0 {
+		n := len(blocks)
+		if n > maxBlocksPerRequest {
+			n = maxBlocksPerRequest
+		}
+		if _, err := c.client.Block.AppendChildren(ctx, notionapi.BlockID(pageID), &notionapi.AppendBlockChildrenRequest{Children: blocks[:n]}); err != nil {
+			return fmt.Errorf("failed to append page content: %w", err)
+		}
+		blocks = blocks[n:]
+	}
+	return nil
+}
+
+// AppendBlock adds text to the end of a page body, as one paragraph per line
+func (c *Client) AppendBlock(ctx context.Context, pageID, text string) error {
+	blocks := contentBlocks(text)
+	if len(blocks) == 0 {
+		return fmt.Errorf("nothing to append to page %s", pageID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	if err := c.appendRemainingBlocks(ctx, pageID, blocks); err != nil {
+		return err
+	}
+
+	log.Printf("Appended %d block(s) to page %s", len(blocks), pageID)
+	return nil
+}
+
+// renderBlocks renders body blocks as markdown, one line per block. Blocks without text,
+// like images or child databases, are left out; nested blocks aren't fetched.
+func renderBlocks(blocks []notionapi.Block, mentions *mentionResolver) string {
+	var lines []string
+	number := 0 // Position within a run of numbered list items
+	for _, block := range blocks {
+		if _, ok := block.(*notionapi.NumberedListItemBlock); ok {
+			number++
+		} else {
+			number = 0
+		}
+
+		switch block := block.(type) {
+		case *notionapi.ParagraphBlock:
+			lines = append(lines, mentions.plainText(block.Paragraph.RichText))
+		case *notionapi.Heading1Block:
+			lines = append(lines, "# "+mentions.plainText(block.Heading1.RichText))
+		case *notionapi.Heading2Block:
+			lines = append(lines, "## "+mentions.plainText(block.Heading2.RichText))
+		case *notionapi.Heading3Block:
+			lines = append(lines, "### "+mentions.plainText(block.Heading3.RichText))
+		case *notionapi.BulletedListItemBlock:
+			lines = append(lines, "- "+mentions.plainText(block.BulletedListItem.RichText))
+		case *notionapi.NumberedListItemBlock:
+			lines = append(lines, fmt.Sprintf("%d. %s", number, mentions.plainText(block.NumberedListItem.RichText)))
+		case *notionapi.ToDoBlock:
+			box := "[ ]"
+			if block.ToDo.Checked {
+				box = "[x]"
+			}
+			lines = append(lines, "- "+box+" "+mentions.plainText(block.ToDo.RichText))
+		case *notionapi.QuoteBlock:
+			lines = append(lines, "> "+mentions.plainText(block.Quote.RichText))
+		case *notionapi.CalloutBlock:
+			lines = append(lines, mentions.plainText(block.Callout.RichText))
+		case *notionapi.ToggleBlock:
+			lines = append(lines, mentions.plainText(block.Toggle.RichText))
+		case *notionapi.CodeBlock:
+			lines = append(lines, "```"+block.Code.Language, mentions.plainText(block.Code.RichText), "```")
+		case *notionapi.DividerBlock:
+			lines = append(lines, "---")
+		case *notionapi.BookmarkBlock:
+			lines = append(lines, block.Bookmark.URL)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,231 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/jomei/notionapi"
+)
+
+func TestSplitContent(t *testing.T) {
+	long := strings.Repeat("word ", 60) // 300 characters on one line
+	tests := []struct {
+		name        string
+		text        string
+		wantTitle   string
+		wantContent string
+	}{
+		{"short text", "Buy milk", "Buy milk", ""},
+		{"multiple lines", "Groceries\nmilk\neggs", "Groceries", "milk\neggs"},
+		{"surrounding whitespace", "  Groceries  \n\n  milk \n", "Groceries", "milk"},
+		{"long single line", long, shortenTitle(strings.TrimSpace(long), 200), strings.TrimSpace(long)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			title, content := splitContent(tt.text, 200)
+			if title != tt.wantTitle || content != tt.wantContent {
+				t.Errorf("Expected %q / %q, got %q / %q", tt.wantTitle, tt.wantContent, title, content)
+			}
+		})
+	}
+}
+
+func TestShortenTitleCutsAtWordBoundary(t *testing.T) {
+	title := shortenTitle(strings.Repeat("word ", 60), 200)
+	if n := len([]rune(title)); n > 200 {
+		t.Errorf("Expected at most 200 characters, got %d", n)
+	}
+	if !strings.HasSuffix(title, "word…") {
+		t.Errorf("Expected the title to end on a whole word, got %q", title)
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	text := strings.Repeat("a", 4500)
+	chunks := chunkText(text, maxRichTextLength)
+	if len(chunks) != 3 || len(chunks[0]) != 2000 || len(chunks[1]) != 2000 || len(chunks[2]) != 500 {
+		t.Fatalf("Expected chunks of 2000, 2000 and 500, got %d chunks", len(chunks))
+	}
+	if strings.Join(chunks, "") != text {
+		t.Error("Expected the chunks to add up to the text")
+	}
+
+	// Emoji take two UTF-16 units and must not be split
+	emoji := strings.Repeat("👍", 1500)
+	chunks = chunkText(emoji, maxRichTextLength)
+	if len(chunks) != 2 || strings.Join(chunks, "") != emoji {
+		t.Fatalf("Expected 2 chunks adding up to the text, got %d", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if n := len(utf16.Encode([]rune(chunk))); n > maxRichTextLength {
+			t.Errorf("Chunk is %d UTF-16 units long", n)
+		}
+	}
+}
+
+func TestCreateTaskWithContentAddsBody(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+
+	// 150 lines make 150 paragraphs, more than fit in the create request
+	lines := make([]string, 150)
+	for i := range lines {
+		lines[i] = "line"
+	}
+	lines[0] = strings.Repeat("x", 2500)
+	if _, err := client.CreateTaskWithContent(context.Background(), "Notes", strings.Join(lines, "\n"), nil, "tasks"); err != nil {
+		t.Fatalf("CreateTaskWithContent failed: %v", err)
+	}
+
+	creates := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(creates) != 1 {
+		t.Fatalf("Expected 1 create request, got %d", len(creates))
+	}
+	var created struct {
+		Children []struct {
+			Paragraph struct {
+				RichText []struct {
+					Text struct {
+						Content string `json:"content"`
+					} `json:"text"`
+				} `json:"rich_text"`
+			} `json:"paragraph"`
+		} `json:"children"`
+	}
+	if err := json.Unmarshal(creates[0].Body, &created); err != nil {
+		t.Fatalf("Invalid create request: %v", err)
+	}
+	if len(created.Children) != maxBlocksPerRequest {
+		t.Fatalf("Expected %d blocks with the page, got %d", maxBlocksPerRequest, len(created.Children))
+	}
+	if richText := created.Children[0].Paragraph.RichText; len(richText) != 2 || len(richText[0].Text.Content) != 2000 {
+		t.Errorf("Expected the long line to be chunked into 2 rich texts, got %+v", richText)
+	}
+
+	appends := fake.requestsTo(http.MethodPatch, "/v1/blocks/page-1/children")
+	if len(appends) != 1 {
+		t.Fatalf("Expected the remaining blocks to be appended once, got %d", len(appends))
+	}
+	var appended struct {
+		Children []json.RawMessage `json:"children"`
+	}
+	if err := json.Unmarshal(appends[0].Body, &appended); err != nil {
+		t.Fatalf("Invalid append request: %v", err)
+	}
+	if len(appended.Children) != 50 {
+		t.Errorf("Expected 50 appended blocks, got %d", len(appended.Children))
+	}
+}
+
+func TestAppendBlock(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "list", "results": []}`
+	}}
+	client := newTestClient(fake)
+
+	if err := client.AppendBlock(context.Background(), "page-1", "Ask for the blue one\n\nIn aisle 4"); err != nil {
+		t.Fatalf("AppendBlock failed: %v", err)
+	}
+
+	appends := fake.requestsTo(http.MethodPatch, "/v1/blocks/page-1/children")
+	if len(appends) != 1 {
+		t.Fatalf("Expected one append request, got %d", len(appends))
+	}
+	want := `{"children": [
+		{"object": "block", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "Ask for the blue one"}}]}},
+		{"object": "block", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "In aisle 4"}}]}}
+	]}`
+	if !jsonEqual(t, appends[0].Body, []byte(want)) {
+		t.Errorf("Unexpected append payload: %s", appends[0].Body)
+	}
+
+	if err := client.AppendBlock(context.Background(), "page-1", "  \n"); err == nil {
+		t.Error("Expected blank text to fail")
+	}
+}
+
+func TestCreateTaskWithoutContentHasNoChildren(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+
+	if _, err := client.CreateTask(context.Background(), "Buy milk", nil, "tasks"); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	creates := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(creates) != 1 || strings.Contains(string(creates[0].Body), "children") {
+		t.Errorf("Expected a create request without children, got %s", creates[0].Body)
+	}
+}
+
+func TestGetTaskRendersBody(t *testing.T) {
+	text := func(content string) string {
+		return `[{"type": "text", "text": {"content": "` + content + `"}, "plain_text": "` + content + `"}]`
+	}
+	children := `{"object": "list", "results": [
+		{"object": "block", "type": "paragraph", "paragraph": {"rich_text": ` + text("Groceries for the week") + `}},
+		{"object": "block", "type": "bulleted_list_item", "bulleted_list_item": {"rich_text": ` + text("milk") + `}},
+		{"object": "block", "type": "bulleted_list_item", "bulleted_list_item": {"rich_text": ` + text("eggs") + `}},
+		{"object": "block", "type": "numbered_list_item", "numbered_list_item": {"rich_text": ` + text("go to the shop") + `}},
+		{"object": "block", "type": "numbered_list_item", "numbered_list_item": {"rich_text": ` + text("pay") + `}},
+		{"object": "block", "type": "to_do", "to_do": {"rich_text": ` + text("bring bags") + `, "checked": true}},
+		{"object": "block", "type": "to_do", "to_do": {"rich_text": ` + text("check the fridge") + `, "checked": false}},
+		{"object": "block", "type": "image", "image": {"type": "external", "external": {"url": "https://example.com/a.png"}}}
+	], "has_more": true}`
+
+	var query string
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch path {
+		case "/v1/pages/page-1":
+			return http.StatusOK, `{"object": "page", "id": "page-1", "url": "https://www.notion.so/page-1",
+				"last_edited_time": "2026-10-01T12:00:00.000Z",
+				"properties": {"Name": {"id": "title", "type": "title", "title": ` + text("Shopping") + `}}}`
+		case "/v1/blocks/page-1/children":
+			return http.StatusOK, children
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+	client := newTestClient(fake)
+	client.client = notionapi.NewClient("test-token", notionapi.WithHTTPClient(&http.Client{Transport: queryRecorder{fake, &query}}))
+
+	task, err := client.GetTask(context.Background(), "page-1")
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+
+	expected := "Groceries for the week\n- milk\n- eggs\n1. go to the shop\n2. pay\n- [x] bring bags\n- [ ] check the fridge"
+	if task.Content != expected {
+		t.Errorf("Expected content %q, got %q", expected, task.Content)
+	}
+	if task.Title != "Shopping" || !task.LastEdited.Equal(time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the page's title and edit time, got %q, %v", task.Title, task.LastEdited)
+	}
+	if query != "page_size=20" {
+		t.Errorf("Expected the first 20 blocks to be fetched, got query %q", query)
+	}
+}
+
+// queryRecorder keeps the query of the last request passed to a fake
+type queryRecorder struct {
+	fake  *fakeNotion
+	query *string
+}
+
+func (q queryRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	*q.query = req.URL.RawQuery
+	return q.fake.RoundTrip(req)
+}
@@ -0,0 +1,141 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+const (
+	// countCap limits how many pages are paged through per count, Notion has no total count
+	countCap = 500
+	// countsCacheTTL is how long computed counts are reused
+	countsCacheTTL = 5 * time.Minute
+	// recentNotesWindow is how far back notes are counted
+	recentNotesWindow = 30 * 24 * time.Hour
+)
+
+// DatabaseCount is the number of matching items in a single database
+type DatabaseCount struct {
+	Count       int  `json:"count"`
+	Approximate bool `json:"approximate"`
+}
+
+// Counts holds open-item counts for the mini app tab badges.
+// Databases that aren't configured or couldn't be queried are left nil.
+type Counts struct {
+	Tasks       *DatabaseCount `json:"tasks,omitempty"`
+	Notes       *DatabaseCount `json:"notes,omitempty"`
+	Journal     *DatabaseCount `json:"journal,omitempty"`
+	Approximate bool           `json:"approximate"`
+	ComputedAt  time.Time      `json:"computed_at"`
+}
+
+// GetCounts returns the number of non-done tasks, notes created in the last 30 days and
+// journal entries created this week. Results are cached for five minutes.
+func (c *Client) GetCounts(ctx context.Context, now time.Time) (*Counts, error) {
+	c.countsMu.Lock()
+	defer c.countsMu.Unlock()
+
+	if c.counts != nil && now.Before(c.countsExpiry) {
+		return c.counts, nil
+	}
+
+	counts := &Counts{ComputedAt: now}
+	var lastErr error
+
+	if c.taskDbID != "" {
+		tasks, err := c.countPages(ctx, c.taskDbID, c.notDoneFilter(ctx, "tasks"))
+		if err != nil {
+			lastErr = err
+		}
+		counts.Tasks = tasks
+	}
+
+	if c.notesDbID != "" {
+		since := notionapi.Date(now.Add(-recentNotesWindow))
+		filter := notionapi.TimestampFilter{
+			Timestamp:   notionapi.TimestampCreated,
+			CreatedTime: &notionapi.DateFilterCondition{OnOrAfter: &since},
+		}
+		notes, err := c.countPages(ctx, c.notesDbID, filter)
+		if err != nil {
+			lastErr = err
+		}
+		counts.Notes = notes
+	}
+
+	if c.journalDbID != "" {
+		since := notionapi.Date(startOfWeek(now))
+		filter := notionapi.TimestampFilter{
+			Timestamp:   notionapi.TimestampCreated,
+			CreatedTime: &notionapi.DateFilterCondition{OnOrAfter: &since},
+		}
+		journal, err := c.countPages(ctx, c.journalDbID, filter)
+		if err != nil {
+			lastErr = err
+		}
+		counts.Journal = journal
+	}
+
+	if counts.Tasks == nil && counts.Notes == nil && counts.Journal == nil && lastErr != nil {
+		return nil, lastErr
+	}
+
+	for _, count := range []*DatabaseCount{counts.Tasks, counts.Notes, counts.Journal} {
+		if count != nil && count.Approximate {
+			counts.Approximate = true
+		}
+	}
+
+	c.counts = counts
+	c.countsExpiry = now.Add(countsCacheTTL)
+	return counts, nil
+}
+
+// CountUndoneTasks returns the number of tasks that aren't done, capped at countCap
+func (c *Client) CountUndoneTasks(ctx context.Context) (*DatabaseCount, error) {
+	if c.taskDbID == "" {
+		return nil, fmt.Errorf("database ID for tasks not configured")
+	}
+	return c.countPages(ctx, c.taskDbID, c.notDoneFilter(ctx, "tasks"))
+}
+
+// countPages pages through the matching items of a database, stopping at countCap
+func (c *Client) countPages(ctx context.Context, dbID string, filter notionapi.Filter) (*DatabaseCount, error) {
+	count := &DatabaseCount{}
+	query := &notionapi.DatabaseQueryRequest{
+		Filter:   filter,
+		PageSize: 100,
+	}
+
+	for {
+		response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), query)
+		if err != nil {
+			log.Printf("Warning: Could not count items in database %s: %v", dbID, err)
+			return nil, fmt.Errorf("failed to query database: %w", err)
+		}
+
+		count.Count += len(response.Results)
+		if count.Count > countCap || (count.Count == countCap && response.HasMore) {
+			count.Count = countCap
+			count.Approximate = true
+			return count, nil
+		}
+
+		if !response.HasMore || response.NextCursor == "" {
+			return count, nil
+		}
+		query.StartCursor = response.NextCursor
+	}
+}
+
+// startOfWeek returns midnight of the Monday of the week containing t
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	day := t.AddDate(0, 0, -daysSinceMonday)
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, t.Location())
+}
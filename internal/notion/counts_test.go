@@ -0,0 +1,132 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// queryPageJSON builds a database query response with n pages
+func queryPageJSON(n int, hasMore bool, nextCursor string) string {
+	results := make([]string, n)
+	for i := range results {
+		results[i] = fmt.Sprintf(`{"object": "page", "id": "page-%d", "properties": {}}`, i)
+	}
+	cursor := "null"
+	if nextCursor != "" {
+		cursor = fmt.Sprintf("%q", nextCursor)
+	}
+	return fmt.Sprintf(`{"object": "list", "results": [%s], "has_more": %t, "next_cursor": %s}`,
+		strings.Join(results, ","), hasMore, cursor)
+}
+
+func TestGetCountsCapsPagination(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch path {
+		case "/v1/databases/tasks-db/query":
+			// An endless database, every page reports more results
+			return http.StatusOK, queryPageJSON(100, true, "next")
+		case "/v1/databases/notes-db/query":
+			return http.StatusOK, queryPageJSON(7, false, "")
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+	client := newTestClient(fake)
+
+	counts, err := client.GetCounts(context.Background(), time.Now())
+	if err != nil {
+		t.Fatalf("GetCounts failed: %v", err)
+	}
+
+	if counts.Tasks == nil || counts.Tasks.Count != countCap || !counts.Tasks.Approximate {
+		t.Errorf("Expected capped approximate task count, got %+v", counts.Tasks)
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")); n != countCap/100 {
+		t.Errorf("Expected %d task queries, got %d", countCap/100, n)
+	}
+	if counts.Notes == nil || counts.Notes.Count != 7 || counts.Notes.Approximate {
+		t.Errorf("Expected exact note count of 7, got %+v", counts.Notes)
+	}
+	if counts.Journal != nil {
+		t.Errorf("Journal database isn't configured, got %+v", counts.Journal)
+	}
+	if !counts.Approximate {
+		t.Error("Expected counts to be marked approximate when a cap was hit")
+	}
+
+	// Pagination continues from the returned cursor
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	var second struct {
+		StartCursor string `json:"start_cursor"`
+	}
+	if err := json.Unmarshal(queries[1].Body, &second); err != nil {
+		t.Fatalf("Invalid query body: %v", err)
+	}
+	if second.StartCursor != "next" {
+		t.Errorf("Expected second query to start at cursor next, got %q", second.StartCursor)
+	}
+}
+
+func TestGetCountsReusesCache(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, queryPageJSON(3, false, "")
+	}}
+	client := newTestClient(fake)
+	now := time.Date(2024, 6, 5, 12, 0, 0, 0, time.UTC)
+
+	if _, err := client.GetCounts(context.Background(), now); err != nil {
+		t.Fatalf("GetCounts failed: %v", err)
+	}
+	cached, err := client.GetCounts(context.Background(), now.Add(countsCacheTTL-time.Second))
+	if err != nil {
+		t.Fatalf("GetCounts failed: %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")); n != 1 {
+		t.Errorf("Expected cached counts to be reused, got %d task queries", n)
+	}
+	if cached.Tasks == nil || cached.Tasks.Count != 3 {
+		t.Errorf("Expected cached task count of 3, got %+v", cached.Tasks)
+	}
+
+	// Once expired the counts are computed again
+	if _, err := client.GetCounts(context.Background(), now.Add(countsCacheTTL)); err != nil {
+		t.Fatalf("GetCounts failed: %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")); n != 2 {
+		t.Errorf("Expected counts to be refreshed after expiry, got %d task queries", n)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	sunday := time.Date(2024, 6, 9, 18, 30, 0, 0, time.UTC)
+	if got := startOfWeek(sunday); !got.Equal(time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Monday 3 June, got %v", got)
+	}
+}
+
+func TestCountUndoneTasks(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if path == "/v1/databases/tasks-db/query" {
+			return http.StatusOK, queryPageJSON(42, false, "")
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+	client := newTestClient(fake)
+
+	count, err := client.CountUndoneTasks(context.Background())
+	if err != nil {
+		t.Fatalf("CountUndoneTasks failed: %v", err)
+	}
+	if count.Count != 42 || count.Approximate {
+		t.Errorf("Expected exactly 42 undone tasks, got %+v", count)
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if len(queries) != 1 || !strings.Contains(string(queries[0].Body), `"does_not_equal":"done"`) {
+		t.Errorf("Expected one query for tasks that aren't done, got %v", queries)
+	}
+}
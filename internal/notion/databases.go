@@ -0,0 +1,76 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ParseDatabases parses NOTION_DATABASES, a JSON object of database IDs by name like
+// {"tasks": "id1", "work": "id2"}. Names are matched ignoring case. tasks, notes, journal
+// and projects replace the databases of their own variables, other names are task databases
+// chats can pick with /use.
+func ParseDatabases(value string) (map[string]string, error) {
+	var raw map[string]string
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of database IDs by name: %w", err)
+	}
+
+	databases := make(map[string]string, len(raw))
+	for name, id := range raw {
+		key := strings.ToLower(strings.TrimSpace(name))
+		id = strings.TrimSpace(id)
+		if key == "" || strings.ContainsAny(key, " \t\n") {
+			return nil, fmt.Errorf("invalid database name %q, expected a single word", name)
+		}
+		if id == "" {
+			return nil, fmt.Errorf("database %q has no ID", name)
+		}
+		if _, ok := databases[key]; ok {
+			return nil, fmt.Errorf("database %q is listed twice", key)
+		}
+		databases[key] = id
+	}
+	return databases, nil
+}
+
+// databasesFromEnv returns the databases of NOTION_DATABASES, none when it's unset or invalid
+func databasesFromEnv() map[string]string {
+	value := os.Getenv("NOTION_DATABASES")
+	if value == "" {
+		return nil
+	}
+	databases, err := ParseDatabases(value)
+	if err != nil {
+		log.Printf("Warning: Ignoring NOTION_DATABASES: %v", err)
+		return nil
+	}
+	return databases
+}
+
+// IsTaskDatabase reports whether name is a configured task database: tasks or one of the
+// other names of NOTION_DATABASES
+func (c *Client) IsTaskDatabase(name string) bool {
+	if name == "tasks" {
+		return c.taskDbID != ""
+	}
+	return !IsDatabaseType(name) && c.databases[name] != ""
+}
+
+// TaskDatabases lists the configured task databases, tasks first and the others by name
+func (c *Client) TaskDatabases() []string {
+	var names []string
+	for name := range c.databases {
+		if !IsDatabaseType(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if c.taskDbID != "" {
+		names = append([]string{"tasks"}, names...)
+	}
+	return names
+}
@@ -0,0 +1,73 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDatabases(t *testing.T) {
+	got, err := ParseDatabases(`{"tasks": "id1", " Work ": "id2", "notes": "id3"}`)
+	if err != nil {
+		t.Fatalf("ParseDatabases failed: %v", err)
+	}
+	if want := map[string]string{"tasks": "id1", "work": "id2", "notes": "id3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	for _, value := range []string{
+		`["id1"]`,
+		`{"work": ""}`,
+		`{"": "id1"}`,
+		`{"side project": "id1"}`,
+		`{"Work": "id1", "work": "id2"}`,
+	} {
+		if _, err := ParseDatabases(value); err == nil {
+			t.Errorf("Expected %s to fail", value)
+		}
+	}
+}
+
+func TestNewClientReadsNamedDatabases(t *testing.T) {
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "env-tasks")
+	t.Setenv("NOTION_NOTES_DATABASE_ID", "env-notes")
+	t.Setenv("NOTION_DATABASES", `{"tasks": "personal-db", "work": "work-db"}`)
+
+	client := NewClient()
+	for dbType, want := range map[string]string{"tasks": "personal-db", "work": "work-db", "notes": "env-notes"} {
+		if got := client.getDbIDForType(dbType); got != want {
+			t.Errorf("Expected %s for %s, got %q", want, dbType, got)
+		}
+	}
+	if got := client.TaskDatabases(); !reflect.DeepEqual(got, []string{"tasks", "work"}) {
+		t.Errorf("Expected tasks and work, got %v", got)
+	}
+	if !client.IsTaskDatabase("work") || client.IsTaskDatabase("notes") || client.IsTaskDatabase("home") {
+		t.Error("Expected only tasks and work to be task databases")
+	}
+}
+
+func TestCreateTaskInNamedDatabase(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+	client.databases = map[string]string{"work": "work-db"}
+
+	if _, err := client.CreateTask(context.Background(), "Send the report", nil, "work"); err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+
+	if gets := fake.requestsTo(http.MethodGet, "/v1/databases/work-db"); len(gets) == 0 {
+		t.Error("Expected the schema of the work database to be fetched")
+	}
+	creates := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(creates) != 1 || !strings.Contains(string(creates[0].Body), `"database_id":"work-db"`) {
+		t.Errorf("Expected the page created in work-db, got %v", creates)
+	}
+}
@@ -0,0 +1,168 @@
+package notion
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// dateOrder is how a numeric date whose first two parts could both be the month is read,
+// like 03/04/2024
+type dateOrder string
+
+const (
+	monthFirst dateOrder = "MDY"
+	dayFirst   dateOrder = "DMY"
+)
+
+// numericDatePattern matches dates like 2024-06-01, 01/06/2024 or 1.6.2024, optionally
+// followed by a time with or without seconds after a "T" or a space
+var numericDatePattern = regexp.MustCompile(`^(\d{1,4})[-/.](\d{1,2})[-/.](\d{1,4})(?:[T ](\d{1,2}):(\d{2})(?::(\d{2}))?)?$`)
+
+// dateOrderFromEnv returns DATE_ORDER, MDY or DMY, defaulting to month first
+func dateOrderFromEnv() dateOrder {
+	value := strings.ToUpper(strings.TrimSpace(os.Getenv("DATE_ORDER")))
+	switch dateOrder(value) {
+	case "":
+		return monthFirst
+	case monthFirst, dayFirst:
+		return dateOrder(value)
+	}
+	log.Printf("Warning: Invalid DATE_ORDER %q, expected MDY or DMY, using %s", value, monthFirst)
+	return monthFirst
+}
+
+// dateLocationFromEnv returns the TZ location, Europe/Moscow like the scheduler when unset
+func dateLocationFromEnv() *time.Location {
+	name := os.Getenv("TZ")
+	if name == "" {
+		name = "Europe/Moscow"
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Warning: Invalid TZ %q, reading dates in the local timezone: %v", name, err)
+		return time.Local
+	}
+	return loc
+}
+
+// dateProperty sets a date property to a day or a moment. The library's Date always
+// renders a timestamp, which Notion shows as a time instead of a plain day.
+type dateProperty struct {
+	Start string // YYYY-MM-DD, or RFC 3339 with an offset
+}
+
+// GetType returns the date property type
+func (p dateProperty) GetType() notionapi.PropertyType {
+	return notionapi.PropertyTypeDate
+}
+
+// MarshalJSON renders the property as {"date": {"start": "<start>"}}
+func (p dateProperty) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"date": map[string]string{"start": p.Start}})
+}
+
+// parseToNotionDate converts a date or a date and time to a date property value. Times
+// without an offset are read in loc, ambiguous days follow order.
+func parseToNotionDate(dateStr string, loc *time.Location, order dateOrder) (dateProperty, error) {
+	start, err := formatDateString(dateStr, loc, order)
+	if err != nil {
+		return dateProperty{}, err
+	}
+	return dateProperty{Start: start}, nil
+}
+
+// formatDateString converts the date formats people and models write to a start Notion
+// accepts: YYYY-MM-DD for a day, which reads the same in every timezone, and RFC 3339 with
+// the offset for a moment. Midnight UTC is a day, it's how Notion returns days.
+func formatDateString(date string, loc *time.Location, order dateOrder) (string, error) {
+	date = strings.TrimSpace(date)
+
+	if t, err := time.Parse(time.RFC3339Nano, date); err == nil {
+		if t.Location() == time.UTC && t.Equal(startOfDay(t)) {
+			return t.Format("2006-01-02"), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+
+	parts := numericDatePattern.FindStringSubmatch(date)
+	if parts == nil {
+		return "", fmt.Errorf("unrecognized date format: %s", date)
+	}
+	n := make([]int, len(parts))
+	for i, part := range parts[1:] {
+		n[i+1], _ = strconv.Atoi(part)
+	}
+
+	// Year first, or the month and day in the configured order with the other order for
+	// dates that only read one way
+	var candidates [][3]int
+	switch {
+	case len(parts[1]) == 4:
+		candidates = [][3]int{{n[1], n[2], n[3]}}
+	case len(parts[3]) == 4:
+		monthDay, dayMonth := [3]int{n[3], n[1], n[2]}, [3]int{n[3], n[2], n[1]}
+		candidates = [][3]int{monthDay, dayMonth}
+		if order == dayFirst {
+			candidates = [][3]int{dayMonth, monthDay}
+		}
+	default:
+		return "", fmt.Errorf("unrecognized date format, expected a four-digit year: %s", date)
+	}
+
+	for _, c := range candidates {
+		year, month, day := c[0], c[1], c[2]
+		if !validDay(year, month, day) {
+			continue
+		}
+		if parts[4] == "" {
+			return fmt.Sprintf("%04d-%02d-%02d", year, month, day), nil
+		}
+		hour, minute, second := n[4], n[5], n[6]
+		if hour > 23 || minute > 59 || second > 59 {
+			return "", fmt.Errorf("invalid time in date: %s", date)
+		}
+		return localTime(year, month, day, hour, minute, second, loc).Format(time.RFC3339), nil
+	}
+	return "", fmt.Errorf("invalid date: %s", date)
+}
+
+// validDay reports whether the day exists, unlike February 30
+func validDay(year, month, day int) bool {
+	if month < 1 || month > 12 || day < 1 {
+		return false
+	}
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return t.Day() == day
+}
+
+// localTime returns the moment a wall clock in loc shows the time. A time skipped when
+// clocks move forward is read as after the gap, one repeated when they move back is its
+// first occurrence. time.Date leaves both unspecified.
+func localTime(year, month, day, hour, minute, second int, loc *time.Location) time.Time {
+	wall := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	guess := time.Date(year, time.Month(month), day, hour, minute, second, 0, loc)
+	_, before := guess.Add(-12 * time.Hour).Zone()
+	_, after := guess.Add(12 * time.Hour).Zone()
+
+	var first time.Time
+	for _, offset := range []int{before, after} {
+		t := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		shown := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		if shown.Equal(wall) && (first.IsZero() || t.Before(first)) {
+			first = t
+		}
+	}
+	if !first.IsZero() {
+		return first
+	}
+	// The clock skipped the time, read it with the offset from before the change
+	return wall.Add(-time.Duration(before) * time.Second).In(loc)
+}
@@ -0,0 +1,160 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func loadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("Timezone data for %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+func TestFormatDateString(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		tz    string
+		order dateOrder
+		want  string
+	}{
+		// Days stay days, whatever the timezone
+		{"iso day", "2024-06-01", "Europe/Moscow", monthFirst, "2024-06-01"},
+		{"iso day west of UTC", "2024-06-01", "America/Los_Angeles", monthFirst, "2024-06-01"},
+		{"iso day single digits", "2024-6-1", "UTC", monthFirst, "2024-06-01"},
+		{"leap day", "2024-02-29", "UTC", monthFirst, "2024-02-29"},
+		{"midnight UTC is a day", "2024-06-01T00:00:00Z", "Europe/Moscow", monthFirst, "2024-06-01"},
+		{"midnight UTC with millis", "2024-06-01T00:00:00.000Z", "Europe/Moscow", monthFirst, "2024-06-01"},
+		{"padding", "  2024-06-01 ", "UTC", monthFirst, "2024-06-01"},
+
+		// Times without an offset are read in the timezone
+		{"space without seconds", "2024-06-01 18:00", "Europe/Moscow", monthFirst, "2024-06-01T18:00:00+03:00"},
+		{"space with seconds", "2024-06-01 18:00:30", "Europe/Moscow", monthFirst, "2024-06-01T18:00:30+03:00"},
+		{"T without seconds", "2024-06-01T18:00", "Europe/Moscow", monthFirst, "2024-06-01T18:00:00+03:00"},
+		{"T with seconds", "2024-06-01T18:00:30", "Europe/Moscow", monthFirst, "2024-06-01T18:00:30+03:00"},
+		{"single digit hour", "2024-06-01 9:05", "UTC", monthFirst, "2024-06-01T09:05:00Z"},
+		{"west of UTC", "2024-06-01 18:00", "America/New_York", monthFirst, "2024-06-01T18:00:00-04:00"},
+		{"summer offset", "2024-07-01 12:00", "Europe/Berlin", monthFirst, "2024-07-01T12:00:00+02:00"},
+		{"winter offset", "2024-01-15 12:00", "Europe/Berlin", monthFirst, "2024-01-15T12:00:00+01:00"},
+		{"half hour offset", "2024-06-01 18:00", "Asia/Kolkata", monthFirst, "2024-06-01T18:00:00+05:30"},
+		{"midnight local is a moment", "2024-06-01 00:00", "Europe/Moscow", monthFirst, "2024-06-01T00:00:00+03:00"},
+
+		// Offsets in the input win over the timezone
+		{"explicit offset", "2024-06-01T18:00:00+05:00", "Europe/Moscow", monthFirst, "2024-06-01T18:00:00+05:00"},
+		{"explicit UTC time", "2024-06-01T18:00:00Z", "Europe/Moscow", monthFirst, "2024-06-01T18:00:00Z"},
+		{"explicit offset at midnight", "2024-06-01T00:00:00+03:00", "UTC", monthFirst, "2024-06-01T00:00:00+03:00"},
+
+		// DATE_ORDER decides dates that read both ways
+		{"ambiguous slashes month first", "03/04/2024", "UTC", monthFirst, "2024-03-04"},
+		{"ambiguous slashes day first", "03/04/2024", "UTC", dayFirst, "2024-04-03"},
+		{"ambiguous dashes month first", "03-04-2024", "UTC", monthFirst, "2024-03-04"},
+		{"ambiguous dashes day first", "03-04-2024", "UTC", dayFirst, "2024-04-03"},
+		{"ambiguous dots day first", "3.4.2024", "UTC", dayFirst, "2024-04-03"},
+		{"ambiguous with time day first", "03/04/2024 18:00", "Europe/Moscow", dayFirst, "2024-04-03T18:00:00+03:00"},
+		{"same both ways", "05/05/2024", "UTC", dayFirst, "2024-05-05"},
+
+		// Dates that only read one way ignore the order
+		{"day over 12 month first", "25/12/2024", "UTC", monthFirst, "2024-12-25"},
+		{"day over 12 day first", "12/25/2024", "UTC", dayFirst, "2024-12-25"},
+		{"dots over 12", "31.01.2024", "UTC", monthFirst, "2024-01-31"},
+
+		// Clocks moving forward skip a time, it's read as after the gap
+		{"Berlin gap", "2024-03-31 02:30", "Europe/Berlin", monthFirst, "2024-03-31T03:30:00+02:00"},
+		{"New York gap", "2024-03-10 02:30", "America/New_York", monthFirst, "2024-03-10T03:30:00-04:00"},
+		{"before Berlin gap", "2024-03-31 01:59", "Europe/Berlin", monthFirst, "2024-03-31T01:59:00+01:00"},
+		{"after Berlin gap", "2024-03-31 03:00", "Europe/Berlin", monthFirst, "2024-03-31T03:00:00+02:00"},
+		// Clocks moving back repeat a time, it's its first occurrence
+		{"Berlin overlap", "2024-10-27 02:30", "Europe/Berlin", monthFirst, "2024-10-27T02:30:00+02:00"},
+		{"New York overlap", "2024-11-03 01:30", "America/New_York", monthFirst, "2024-11-03T01:30:00-04:00"},
+		{"after Berlin overlap", "2024-10-27 03:00", "Europe/Berlin", monthFirst, "2024-10-27T03:00:00+01:00"},
+		{"day of a DST change", "2024-03-31", "Europe/Berlin", monthFirst, "2024-03-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := formatDateString(tt.input, loadLocation(t, tt.tz), tt.order)
+			if err != nil {
+				t.Fatalf("formatDateString(%q) failed: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("formatDateString(%q) in %s = %s, want %s", tt.input, tt.tz, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatDateStringRejectsInvalidDates(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"tomorrow",
+		"2024-02-30",
+		"2023-02-29",
+		"13/13/2024",
+		"03/04/24",
+		"2024-06-01 24:00",
+		"2024-06-01 18:60",
+		"2024-06-01 18",
+		"2024-06-01T18:00:00+25:00",
+	} {
+		if got, err := formatDateString(input, time.UTC, monthFirst); err == nil {
+			t.Errorf("Expected %q to be rejected, got %s", input, got)
+		}
+	}
+}
+
+func TestDateOrderFromEnv(t *testing.T) {
+	for value, want := range map[string]dateOrder{"": monthFirst, "DMY": dayFirst, " dmy ": dayFirst, "MDY": monthFirst, "YMD": monthFirst} {
+		t.Setenv("DATE_ORDER", value)
+		if got := dateOrderFromEnv(); got != want {
+			t.Errorf("Expected DATE_ORDER %q to be %s, got %s", value, want, got)
+		}
+	}
+}
+
+func TestDateLocationFromEnv(t *testing.T) {
+	t.Setenv("TZ", "")
+	if got := dateLocationFromEnv(); got.String() != "Europe/Moscow" {
+		t.Errorf("Expected Europe/Moscow without TZ, got %s", got)
+	}
+	t.Setenv("TZ", "America/New_York")
+	if got := dateLocationFromEnv(); got.String() != "America/New_York" {
+		t.Errorf("Expected America/New_York, got %s", got)
+	}
+}
+
+func TestCreateTaskSendsDaysAndLocalTimes(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	client := newTestClient(fake)
+	client.location = loadLocation(t, "Europe/Moscow")
+	client.dateOrder = dayFirst
+
+	for input, want := range map[string]string{
+		"2024-06-01":       `{"date": {"start": "2024-06-01"}}`,
+		"01/06/2024 18:00": `{"date": {"start": "2024-06-01T18:00:00+03:00"}}`,
+	} {
+		if _, err := client.CreateTask(context.Background(), "Pick up the parcel", map[string]interface{}{"Date": input}, "tasks"); err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		created := fake.requestsTo(http.MethodPost, "/v1/pages")
+		var body struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		if err := json.Unmarshal(created[len(created)-1].Body, &body); err != nil {
+			t.Fatalf("Invalid request body: %v", err)
+		}
+		if !jsonEqual(t, body.Properties["Date"], []byte(want)) {
+			t.Errorf("Expected %s for %q, got %s", want, input, body.Properties["Date"])
+		}
+	}
+}
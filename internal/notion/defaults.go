@@ -0,0 +1,50 @@
+package notion
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+)
+
+// defaultPropertiesFromEnv reads the properties every new page of a database type gets,
+// from DEFAULT_PROPERTIES_TASKS, DEFAULT_PROPERTIES_NOTES and so on. Each holds a JSON
+// object in the shape CreateTask takes, like {"Tags":["from-telegram"]}. Invalid values
+// are ignored with a warning.
+func defaultPropertiesFromEnv() map[string]map[string]interface{} {
+	defaults := make(map[string]map[string]interface{})
+	for _, dbType := range databaseTypes {
+		name := "DEFAULT_PROPERTIES_" + strings.ToUpper(dbType)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		var properties map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &properties); err != nil {
+			log.Printf("Warning: Ignoring %s, expected a JSON object: %v", name, err)
+			continue
+		}
+		if len(properties) > 0 {
+			defaults[dbType] = properties
+		}
+	}
+	return defaults
+}
+
+// withDefaults returns properties on top of the defaults of dbType, so supplied values win.
+// Defaults the database doesn't have are skipped like any other missing property.
+func (c *Client) withDefaults(dbType string, properties map[string]interface{}) map[string]interface{} {
+	defaults := c.defaultProperties[dbType]
+	if len(defaults) == 0 {
+		return properties
+	}
+
+	merged := make(map[string]interface{}, len(defaults)+len(properties))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range properties {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,76 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestDefaultPropertiesFromEnv(t *testing.T) {
+	t.Setenv("DEFAULT_PROPERTIES_TASKS", `{"Tags": ["from-telegram"]}`)
+	t.Setenv("DEFAULT_PROPERTIES_NOTES", `["not", "an", "object"]`)
+
+	defaults := defaultPropertiesFromEnv()
+	if _, ok := defaults["tasks"]["Tags"]; !ok {
+		t.Errorf("Expected the tasks defaults to have Tags, got %v", defaults)
+	}
+	if _, ok := defaults["notes"]; ok {
+		t.Errorf("Expected invalid notes defaults to be ignored, got %v", defaults["notes"])
+	}
+}
+
+func TestCreateTaskAppliesDefaults(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, tasksSchemaJSON
+	}}
+	client := newTestClient(fake)
+	client.defaultProperties = map[string]map[string]interface{}{
+		"tasks": {
+			"Tags":     []interface{}{"from-telegram"},
+			"Estimate": 1,
+			"Source":   "bot", // Not in the schema
+		},
+	}
+
+	plan, err := client.PlanCreateTask(context.Background(), "Buy milk", map[string]interface{}{"Estimate": 3}, "tasks")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+
+	request, err := json.Marshal(plan.Request.Properties)
+	if err != nil {
+		t.Fatalf("Failed to marshal the properties: %v", err)
+	}
+	var properties struct {
+		Tags struct {
+			MultiSelect []struct {
+				Name string `json:"name"`
+			} `json:"multi_select"`
+		}
+		Estimate struct {
+			Number float64 `json:"number"`
+		}
+	}
+	if err := json.Unmarshal(request, &properties); err != nil {
+		t.Fatalf("Invalid properties %s: %v", request, err)
+	}
+	if tags := properties.Tags.MultiSelect; len(tags) != 1 || tags[0].Name != "from-telegram" {
+		t.Errorf("Expected the default tag, got %s", request)
+	}
+	if properties.Estimate.Number != 3 {
+		t.Errorf("Expected the supplied Estimate to win over the default, got %s", request)
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0] != "Source" {
+		t.Errorf("Expected the missing Source default to be skipped, got %v", plan.Skipped)
+	}
+
+	// Other databases don't get the tasks defaults
+	plan, err = client.PlanCreateTask(context.Background(), "Idea", nil, "notes")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+	if len(plan.Request.Properties) != 1 || len(plan.Skipped) != 0 {
+		t.Errorf("Expected only a title for notes, got %v, skipped %v", plan.Request.Properties, plan.Skipped)
+	}
+}
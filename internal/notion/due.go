@@ -0,0 +1,223 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// datePropertyKey is the date property holding when a task is due
+const datePropertyKey = "Date"
+
+// maxDueTasks caps the listings of due tasks, they are meant to fit in a chat message or two
+const maxDueTasks = 200
+
+// startOfDay returns midnight of the calendar day of t, in t's location
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// dueOnFilter matches tasks whose Date is within a day of the calendar day of day. Notion
+// compares dates without a time in UTC, so the results are rechecked with DueDay.
+func dueOnFilter(day time.Time) notionapi.AndCompoundFilter {
+	after := notionapi.Date(startOfDay(day).AddDate(0, 0, -1))
+	before := notionapi.Date(startOfDay(day).AddDate(0, 0, 1))
+	return notionapi.AndCompoundFilter{
+		notionapi.PropertyFilter{
+			Property: datePropertyKey,
+			Date:     &notionapi.DateFilterCondition{OnOrAfter: &after},
+		},
+		notionapi.PropertyFilter{
+			Property: datePropertyKey,
+			Date:     &notionapi.DateFilterCondition{OnOrBefore: &before},
+		},
+	}
+}
+
+// GetTasksDueOn returns the tasks whose Date is the given day, in day's location. Extra
+// filters, such as a ChatScopeFilter, narrow the listing further.
+func (c *Client) GetTasksDueOn(ctx context.Context, dbType string, day time.Time, filters ...notionapi.Filter) ([]Task, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	start := startOfDay(day)
+	query := &notionapi.DatabaseQueryRequest{
+		Filter:   append(dueOnFilter(day), filters...),
+		PageSize: pageSizeFor(maxDueTasks),
+	}
+
+	var tasks []Task
+	mentions := c.newMentionResolver(ctx)
+	err := c.queryPages(ctx, dbID, query, func(page notionapi.Page) bool {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			return true
+		}
+		if due, ok := DueDay(task, start.Location()); !ok || !due.Equal(start) {
+			return true
+		}
+		tasks = append(tasks, task)
+		return len(tasks) < maxDueTasks
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks due on %s: %w", day.Format("2006-01-02"), err)
+	}
+	return tasks, nil
+}
+
+// DueDay returns the calendar day a task is due, at midnight in loc. Dates without a time
+// are the same day everywhere, dates with a time fall on their day in loc.
+func DueDay(task Task, loc *time.Location) (time.Time, bool) {
+	value, ok := task.Properties[datePropertyKey].(string)
+	if !ok || value == "" {
+		return time.Time{}, false
+	}
+	due, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	// Notion dates without a time are parsed as midnight UTC
+	if due.Location() == time.UTC && due.Equal(startOfDay(due)) {
+		return time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, loc), true
+	}
+	return startOfDay(due.In(loc)), true
+}
+
+// GetOverdueTasks returns up to limit tasks that aren't done and were due before the day of
+// today, in today's location. Pass the current time in the user's timezone, a task due
+// today must not count as overdue just because it's already tomorrow in UTC.
+func (c *Client) GetOverdueTasks(ctx context.Context, dbType string, today time.Time, limit int, filters ...notionapi.Filter) ([]Task, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	start := startOfDay(today)
+	before := notionapi.Date(start)
+	filter := notionapi.AndCompoundFilter{
+		notionapi.PropertyFilter{
+			Property: datePropertyKey,
+			Date:     &notionapi.DateFilterCondition{Before: &before},
+		},
+	}
+	filter = append(filter, c.notDoneFilters(ctx, dbType)...)
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: append(filter, filters...),
+		Sorts: []notionapi.SortObject{
+			{Property: datePropertyKey, Direction: notionapi.SortOrderASC},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	var tasks []Task
+	mentions := c.newMentionResolver(ctx)
+	err := c.queryPages(ctx, dbID, query, func(page notionapi.Page) bool {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			return true
+		}
+
+		// Notion compares dates without a time in UTC, recheck the day in today's timezone
+		if due, ok := DueDay(task, start.Location()); !ok || !due.Before(start) {
+			return true
+		}
+		tasks = append(tasks, task)
+		return len(tasks) < limit
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query overdue tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// dueWithin reports whether a task comes due within window after now. Dates with a time
+// must be after now; dates without one are due from the start of their day in now's
+// location and count until the day is over.
+func dueWithin(task Task, now time.Time, window time.Duration) bool {
+	value, ok := task.Properties[datePropertyKey].(string)
+	if !ok || value == "" {
+		return false
+	}
+	due, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+	end := due
+	// Notion dates without a time are parsed as midnight UTC
+	if due.Location() == time.UTC && due.Equal(startOfDay(due)) {
+		due = time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, now.Location())
+		end = due.AddDate(0, 0, 1)
+	}
+	return end.After(now) && !due.After(now.Add(window))
+}
+
+// GetTasksDueWithin returns the open tasks coming due within window from now, soonest
+// first. Dates without a time fall on their day in the local timezone, which is TZ.
+func (c *Client) GetTasksDueWithin(ctx context.Context, window time.Duration) ([]Task, error) {
+	return c.getTasksDueWithin(ctx, time.Now(), window)
+}
+
+func (c *Client) getTasksDueWithin(ctx context.Context, now time.Time, window time.Duration) ([]Task, error) {
+	dbID := c.getDbIDForType("tasks")
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for tasks not configured")
+	}
+
+	// Notion compares dates without a time in UTC, a day either side covers every timezone
+	after := notionapi.Date(startOfDay(now).AddDate(0, 0, -1))
+	before := notionapi.Date(now.Add(window).AddDate(0, 0, 1))
+	filter := notionapi.AndCompoundFilter{
+		notionapi.PropertyFilter{
+			Property: datePropertyKey,
+			Date:     &notionapi.DateFilterCondition{OnOrAfter: &after},
+		},
+		notionapi.PropertyFilter{
+			Property: datePropertyKey,
+			Date:     &notionapi.DateFilterCondition{OnOrBefore: &before},
+		},
+	}
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: append(filter, c.notDoneFilters(ctx, "tasks")...),
+		Sorts: []notionapi.SortObject{
+			{Property: datePropertyKey, Direction: notionapi.SortOrderASC},
+		},
+		PageSize: pageSizeFor(maxDueTasks),
+	}
+
+	var tasks []Task
+	mentions := c.newMentionResolver(ctx)
+	err := c.queryPages(ctx, dbID, query, func(page notionapi.Page) bool {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			return true
+		}
+		if dueWithin(task, now, window) {
+			tasks = append(tasks, task)
+		}
+		return len(tasks) < maxDueTasks
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tasks due within %v: %w", window, err)
+	}
+	return tasks, nil
+}
+
+// UpdateTaskDate sets the Date of a task to a day given as YYYY-MM-DD
+func (c *Client) UpdateTaskDate(ctx context.Context, taskID, date string) error {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return fmt.Errorf("%w: invalid date %q", ErrInvalidUpdate, date)
+	}
+	if err := c.UpdateTask(ctx, taskID, "", map[string]interface{}{datePropertyKey: date}); err != nil {
+		return fmt.Errorf("failed to set the date of %s: %w", taskID, err)
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// duePageJSON renders a page with a Date, titled after its id
+func duePageJSON(id, date string) string {
+	return fmt.Sprintf(`{
+		"object": "page", "id": %q,
+		"properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": %q}, "plain_text": %q}]},
+			"status": {"id": "st", "type": "select", "select": {"name": "in progress"}},
+			"Date": {"id": "date", "type": "date", "date": {"start": %q}}
+		}
+	}`, id, id, id, date)
+}
+
+func TestGetTasksDueOnFiltersByDay(t *testing.T) {
+	// Notion compares dates without a time in UTC, the window around the day returns
+	// neighbours too
+	results := strings.Join([]string{
+		duePageJSON("yesterday", "2024-03-14"),
+		duePageJSON("today", "2024-03-15"),
+		duePageJSON("early-today", "2024-03-14T22:30:00.000Z"),
+		duePageJSON("late-today", "2024-03-15T23:30:00.000+03:00"),
+		duePageJSON("early-tomorrow", "2024-03-15T21:30:00.000Z"),
+		duePageJSON("tomorrow", "2024-03-16"),
+	}, ",")
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "list", "results": [` + results + `], "has_more": false}`
+	}}
+	client := newTestClient(fake)
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	day := time.Date(2024, 3, 15, 23, 30, 0, 0, moscow)
+	tasks, err := client.GetTasksDueOn(context.Background(), "tasks", day)
+	if err != nil {
+		t.Fatalf("GetTasksDueOn failed: %v", err)
+	}
+	var titles []string
+	for _, task := range tasks {
+		titles = append(titles, task.Title)
+	}
+	if strings.Join(titles, ",") != "today,early-today,late-today" {
+		t.Errorf("Expected only the tasks due on the day in MSK, got %v", titles)
+	}
+	if len(tasks) > 0 && tasks[0].Properties["status"] != "in progress" {
+		t.Errorf("Unexpected task: %+v", tasks[0])
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if len(queries) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(queries))
+	}
+	// The window is around midnight in the day's own timezone, not in UTC
+	want := `{"and": [
+		{"property": "Date", "date": {"on_or_after": "2024-03-14T00:00:00+03:00"}},
+		{"property": "Date", "date": {"on_or_before": "2024-03-16T00:00:00+03:00"}}
+	]}`
+	var query struct {
+		Filter json.RawMessage `json:"filter"`
+	}
+	if err := json.Unmarshal(queries[0].Body, &query); err != nil {
+		t.Fatalf("Invalid query: %v", err)
+	}
+	if !jsonEqual(t, query.Filter, []byte(want)) {
+		t.Errorf("Expected filter %s, got %s", want, query.Filter)
+	}
+}
+
+func TestGetTasksDueOnCombinesFilters(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "list", "results": [], "has_more": false}`
+	}}
+	client := newTestClient(fake)
+
+	scope := notionapi.PropertyFilter{Property: "Source chat", Select: &notionapi.SelectFilterCondition{Equals: "Family [789]"}}
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+	if _, err := client.GetTasksDueOn(context.Background(), "tasks", day, scope); err != nil {
+		t.Fatalf("GetTasksDueOn failed: %v", err)
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	want := `{"and": [
+		{"property": "Date", "date": {"on_or_after": "2024-03-14T00:00:00Z"}},
+		{"property": "Date", "date": {"on_or_before": "2024-03-16T00:00:00Z"}},
+		{"property": "Source chat", "select": {"equals": "Family [789]"}}
+	]}`
+	var query struct {
+		Filter json.RawMessage `json:"filter"`
+	}
+	if err := json.Unmarshal(queries[0].Body, &query); err != nil {
+		t.Fatalf("Invalid query: %v", err)
+	}
+	if !jsonEqual(t, query.Filter, []byte(want)) {
+		t.Errorf("Expected filter %s, got %s", want, query.Filter)
+	}
+}
+
+func TestDueDay(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	tests := []struct {
+		value string
+		want  string
+	}{
+		// Dates without a time are the same calendar day in every timezone
+		{"2024-03-15T00:00:00Z", "2024-03-15"},
+		// Times fall on their day in the scheduler's timezone
+		{"2024-03-14T23:30:00+03:00", "2024-03-14"},
+		{"2024-03-14T22:30:00Z", "2024-03-15"},
+	}
+	for _, tt := range tests {
+		task := Task{Properties: map[string]interface{}{"Date": tt.value}}
+		day, ok := DueDay(task, moscow)
+		if !ok || day.Format("2006-01-02") != tt.want || day.Location() != moscow {
+			t.Errorf("%s: expected %s in MSK, got %v", tt.value, tt.want, day)
+		}
+	}
+
+	if _, ok := DueDay(Task{Properties: map[string]interface{}{}}, moscow); ok {
+		t.Error("Expected no due day without a Date")
+	}
+}
+
+func TestGetOverdueTasksUsesLocalDay(t *testing.T) {
+	page := func(id, date string) string {
+		return fmt.Sprintf(`{
+			"object": "page", "id": %q,
+			"properties": {
+				"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": %q}, "plain_text": %q}]},
+				"Date": {"id": "date", "type": "date", "date": {"start": %q}}
+			}
+		}`, id, id, id, date)
+	}
+	// Notion compares dates without a time in UTC, so it may return a task due today
+	results := strings.Join([]string{
+		page("yesterday", "2024-03-14"),
+		page("late-yesterday", "2024-03-14T23:30:00.000+03:00"),
+		page("today", "2024-03-15"),
+	}, ",")
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "list", "results": [` + results + `], "has_more": false}`
+	}}
+	client := newTestClient(fake)
+
+	// Half past midnight in Moscow is still the previous day in UTC
+	moscow := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2024, 3, 15, 0, 30, 0, 0, moscow)
+	tasks, err := client.GetOverdueTasks(context.Background(), "tasks", now, 10)
+	if err != nil {
+		t.Fatalf("GetOverdueTasks failed: %v", err)
+	}
+
+	var titles []string
+	for _, task := range tasks {
+		titles = append(titles, task.Title)
+	}
+	if strings.Join(titles, ",") != "yesterday,late-yesterday" {
+		t.Errorf("Expected only the tasks due before today in MSK, got %v", titles)
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	want := `{"and": [
+		{"property": "Date", "date": {"before": "2024-03-15T00:00:00+03:00"}},
+		{"property": "status", "select": {"does_not_equal": "done"}}
+	]}`
+	var query struct {
+		Filter json.RawMessage `json:"filter"`
+	}
+	if err := json.Unmarshal(queries[0].Body, &query); err != nil {
+		t.Fatalf("Invalid query: %v", err)
+	}
+	if !jsonEqual(t, query.Filter, []byte(want)) {
+		t.Errorf("Expected filter %s, got %s", want, query.Filter)
+	}
+}
+
+func TestGetTasksDueWithinWindowBoundary(t *testing.T) {
+	dates := []string{
+		"2024-03-14",                // Yesterday, already over
+		"2024-03-15T09:00:00+03:00", // An hour ago
+		"2024-03-15",                // Today, not over yet
+		"2024-03-16",                // Tomorrow, starts within the window
+		"2024-03-16T10:00:00+03:00", // Exactly at the end of the window
+		"2024-03-16T10:01:00+03:00", // A minute past it
+		"2024-03-17",
+	}
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method != http.MethodPost {
+			return http.StatusOK, tasksSchemaJSON
+		}
+		var results []string
+		for _, date := range dates {
+			results = append(results, fmt.Sprintf(`{"object": "page", "id": "page-%s", "properties": {
+				"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": %q}, "plain_text": %q}]},
+				"Date": {"id": "date", "type": "date", "date": {"start": %q}}
+			}}`, date, date, date, date))
+		}
+		return http.StatusOK, `{"object": "list", "results": [` + strings.Join(results, ",") + `], "has_more": false}`
+	}}
+	client := newTestClient(fake)
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, moscow)
+	tasks, err := client.getTasksDueWithin(context.Background(), now, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("getTasksDueWithin failed: %v", err)
+	}
+	var titles []string
+	for _, task := range tasks {
+		titles = append(titles, task.Title)
+	}
+	want := "2024-03-15, 2024-03-16, 2024-03-16T10:00:00+03:00"
+	if got := strings.Join(titles, ", "); got != want {
+		t.Errorf("Expected tasks due %s, got %s", want, got)
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if len(queries) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(queries))
+	}
+	if !strings.Contains(string(queries[0].Body), `"on_or_after":"2024-03-14T00:00:00+03:00"`) {
+		t.Errorf("Expected the query to start a day before today, got %s", queries[0].Body)
+	}
+}
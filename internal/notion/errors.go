@@ -0,0 +1,130 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// Error codes of the Notion API, see https://developers.notion.com/reference/status-codes
+const (
+	ErrCodeObjectNotFound     = "object_not_found"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeRestrictedResource = "restricted_resource"
+	ErrCodeValidation         = "validation_error"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeConflict           = "conflict_error"
+	ErrCodeInternal           = "internal_server_error"
+	ErrCodeUnavailable        = "service_unavailable"
+)
+
+// ErrNotFound is returned for pages that were deleted or were never shared with the
+// integration, which Notion doesn't tell apart
+var ErrNotFound = errors.New("page not found")
+
+// NotionError is an error response of the Notion API
+type NotionError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *NotionError) Error() string {
+	return fmt.Sprintf("Notion API error %d (%s): %s", e.Status, e.Code, e.Message)
+}
+
+// validationProperty finds the property a validation error is about, in messages like
+// "body.properties.Date.date.start should be ..." or "Tags is expected to be multi_select."
+var validationProperty = []*regexp.Regexp{
+	regexp.MustCompile(`body\.properties\.([^.\s]+)\.`),
+	regexp.MustCompile(`^(.+?) is expected to be `),
+	regexp.MustCompile(`^(.+?) is not a property that exists`),
+	regexp.MustCompile(`Could not find property with name or id: (.+?)\.?$`),
+}
+
+// Property returns the name of the property a validation error is about, if the message
+// names one
+func (e *NotionError) Property() string {
+	if e.Code != ErrCodeValidation {
+		return ""
+	}
+	for _, pattern := range validationProperty {
+		if match := pattern.FindStringSubmatch(e.Message); match != nil {
+			return strings.TrimSpace(match[1])
+		}
+	}
+	return ""
+}
+
+// AsNotionError finds the Notion API error in err's chain. Errors returned by the notionapi
+// library are converted, so callers only deal with NotionError.
+func AsNotionError(err error) (*NotionError, bool) {
+	var notionErr *NotionError
+	if errors.As(err, &notionErr) {
+		return notionErr, true
+	}
+	var apiErr *notionapi.Error
+	if errors.As(err, &apiErr) {
+		return &NotionError{Status: apiErr.Status, Code: string(apiErr.Code), Message: apiErr.Message}, true
+	}
+	return nil, false
+}
+
+// IsTransient reports whether an error is Notion being down or unreachable rather than a
+// request it rejects: rate limits, server errors, conflicts, timeouts and network errors.
+// Retrying the request later can succeed.
+func IsTransient(err error) bool {
+	if notionErr, ok := AsNotionError(err); ok {
+		return notionErr.Status == 429 || notionErr.Status >= 500 || notionErr.Code == ErrCodeConflict
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// parseError reads the error body of a Notion API response made without the library.
+// Bodies that aren't Notion errors keep the status alone.
+func parseError(status int, body io.Reader) error {
+	notionErr := &NotionError{Status: status}
+	if err := json.NewDecoder(io.LimitReader(body, 64<<10)).Decode(notionErr); err != nil || notionErr.Code == "" {
+		return &NotionError{Status: status, Code: "unknown", Message: fmt.Sprintf("unexpected status %d", status)}
+	}
+	return notionErr
+}
+
+// Explain describes an error for the user. Common Notion errors say what to check, any
+// other error is returned as it is.
+func Explain(err error) string {
+	notionErr, ok := AsNotionError(err)
+	if !ok {
+		return err.Error()
+	}
+
+	switch notionErr.Code {
+	case ErrCodeObjectNotFound:
+		return "the database ID looks wrong or the integration lacks access, share the database with the integration in Notion"
+	case ErrCodeUnauthorized:
+		return "Notion rejected the API token, check NOTION_API_KEY"
+	case ErrCodeRestrictedResource:
+		return "the integration lacks the capability this needs, check its capabilities in Notion"
+	case ErrCodeValidation:
+		if property := notionErr.Property(); property != "" {
+			return fmt.Sprintf("Notion rejected the value of the %q property: %s", property, notionErr.Message)
+		}
+		return "Notion rejected the request: " + notionErr.Message
+	case ErrCodeRateLimited:
+		return "Notion is rate limiting requests, try again in a minute"
+	case ErrCodeConflict:
+		return "the page was changed at the same time, try again"
+	case ErrCodeInternal, ErrCodeUnavailable:
+		return "Notion is having trouble, try again later"
+	default:
+		return notionErr.Error()
+	}
+}
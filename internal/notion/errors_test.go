@@ -0,0 +1,122 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+// Error bodies as the Notion API sends them
+const (
+	notFoundBody     = `{"object": "error", "status": 404, "code": "object_not_found", "message": "Could not find database with ID: 1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d. Make sure the relevant pages and databases are shared with your integration.", "request_id": "2c5d6f0e-8f1a-4c4f-9d4b-1d6f0a3b2c1e"}`
+	unauthorizedBody = `{"object": "error", "status": 401, "code": "unauthorized", "message": "API token is invalid.", "request_id": "b1f1d6b2-8a55-4d2e-8cf5-23f1b2f6c9aa"}`
+	validationBody   = `{"object": "error", "status": 400, "code": "validation_error", "message": "body failed validation: body.properties.Date.date.start should be a valid ISO 8601 date string, instead was ` + "`\\\"tomorrow\\\"`" + `.", "request_id": "6f0a9c1e-2b3d-4e5f-8a7b-9c0d1e2f3a4b"}`
+	wrongTypeBody    = `{"object": "error", "status": 400, "code": "validation_error", "message": "Tags is expected to be multi_select.", "request_id": "0d1e2f3a-4b5c-6d7e-8f9a-0b1c2d3e4f5a"}`
+)
+
+func TestNotionErrorsAreTypedAndExplained(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		code     string
+		property string
+		explain  string
+	}{
+		{"object not found", http.StatusNotFound, notFoundBody, ErrCodeObjectNotFound, "", "the database ID looks wrong or the integration lacks access"},
+		{"unauthorized", http.StatusUnauthorized, unauthorizedBody, ErrCodeUnauthorized, "", "check NOTION_API_KEY"},
+		{"invalid value", http.StatusBadRequest, validationBody, ErrCodeValidation, "Date", `Notion rejected the value of the "Date" property`},
+		{"wrong type", http.StatusBadRequest, wrongTypeBody, ErrCodeValidation, "Tags", `Notion rejected the value of the "Tags" property`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Through the library
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				return tt.status, tt.body
+			}}
+			_, err := newTestClient(fake).CheckDatabase(context.Background(), "tasks")
+			notionErr, ok := AsNotionError(err)
+			if !ok {
+				t.Fatalf("Expected a NotionError in %v", err)
+			}
+			if notionErr.Status != tt.status || notionErr.Code != tt.code || notionErr.Message == "" {
+				t.Errorf("Expected status %d and code %s, got %+v", tt.status, tt.code, notionErr)
+			}
+			if got := notionErr.Property(); got != tt.property {
+				t.Errorf("Expected property %q, got %q", tt.property, got)
+			}
+			if got := Explain(err); !strings.Contains(got, tt.explain) {
+				t.Errorf("Expected the explanation to contain %q, got %q", tt.explain, got)
+			}
+
+			// Through a request made without the library
+			parsed, ok := AsNotionError(parseError(tt.status, strings.NewReader(tt.body)))
+			if !ok || *parsed != *notionErr {
+				t.Errorf("Expected %+v from the raw body, got %+v", notionErr, parsed)
+			}
+		})
+	}
+
+	// Other errors are shown as they are
+	if got := Explain(context.DeadlineExceeded); got != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected a non-Notion error unchanged, got %q", got)
+	}
+	if parsed, ok := AsNotionError(parseError(http.StatusBadGateway, strings.NewReader("<html>Bad gateway</html>"))); !ok || parsed.Status != http.StatusBadGateway {
+		t.Errorf("Expected a body that isn't JSON to keep its status, got %+v", parsed)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"unavailable", &NotionError{Status: http.StatusServiceUnavailable, Code: ErrCodeUnavailable}, true},
+		{"wrapped bad gateway", fmt.Errorf("failed to create task: %w", &NotionError{Status: http.StatusBadGateway, Code: "unknown"}), true},
+		{"rate limited", &notionapi.Error{Status: http.StatusTooManyRequests, Code: ErrCodeRateLimited}, true},
+		{"conflict", &NotionError{Status: http.StatusConflict, Code: ErrCodeConflict}, true},
+		{"network", &url.Error{Op: "Post", URL: "https://api.notion.com/v1/pages", Err: errors.New("connection refused")}, true},
+		{"timeout", fmt.Errorf("failed to create task: %w", context.DeadlineExceeded), true},
+		{"validation", &NotionError{Status: http.StatusBadRequest, Code: ErrCodeValidation}, false},
+		{"unauthorized", &notionapi.Error{Status: http.StatusUnauthorized, Code: ErrCodeUnauthorized}, false},
+		{"unknown option", &OptionError{Property: "Tags", Value: "music"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.transient {
+			t.Errorf("%s: Expected IsTransient to be %v for %v", tt.name, tt.transient, tt.err)
+		}
+	}
+}
+
+// headerRecorder records the Notion-Version of requests
+type headerRecorder struct {
+	fake     *fakeNotion
+	versions []string
+}
+
+func (h *headerRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	h.versions = append(h.versions, req.Header.Get("Notion-Version"))
+	return h.fake.RoundTrip(req)
+}
+
+func TestClientSendsPinnedNotionVersion(t *testing.T) {
+	recorder := &headerRecorder{fake: &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "user", "id": "bot-1", "type": "bot", "name": "Tasks bot", "bot": {}}`
+	}}}
+	client := NewWorkspaceClient("test-token", "tasks-db", "", notionapi.WithHTTPClient(&http.Client{Transport: recorder}))
+
+	if _, err := client.CheckToken(context.Background()); err != nil {
+		t.Fatalf("CheckToken failed: %v", err)
+	}
+	if len(recorder.versions) != 1 || recorder.versions[0] != notionAPIVersion {
+		t.Errorf("Expected Notion-Version %s, got %v", notionAPIVersion, recorder.versions)
+	}
+}
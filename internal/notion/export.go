@@ -0,0 +1,65 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jomei/notionapi"
+)
+
+// ExportColumns returns the names of a database's properties a Task can hold, sorted,
+// leaving out the title and buttons. Exports use them as columns.
+func (c *Client) ExportColumns(ctx context.Context, dbType string) ([]string, error) {
+	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch database schema: %w", err)
+	}
+
+	columns := make([]string, 0, len(dbProps))
+	for key, prop := range dbProps {
+		switch prop.GetType() {
+		case notionapi.PropertyConfigTypeTitle, "button":
+			continue
+		}
+		if isButtonLike(key) {
+			continue
+		}
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	return columns, nil
+}
+
+// ExportTasks calls visit with every page of a database, the oldest first, fetching
+// pages of results as they are visited so the whole database is never held at once.
+// An error from visit stops the export and is returned.
+func (c *Client) ExportTasks(ctx context.Context, dbType string, visit func(Task) error) error {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	query := &notionapi.DatabaseQueryRequest{
+		Sorts: []notionapi.SortObject{
+			{Timestamp: notionapi.TimestampCreated, Direction: notionapi.SortOrderASC},
+		},
+		PageSize: maxQueryPageSize,
+	}
+
+	mentions := c.newMentionResolver(ctx)
+	var visitErr error
+	err := c.queryPages(ctx, dbID, query, func(page notionapi.Page) bool {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			visitErr = fmt.Errorf("could not transform page %s: %w", page.ID, err)
+			return false
+		}
+		visitErr = visit(task)
+		return visitErr == nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %w", dbType, err)
+	}
+	return visitErr
+}
@@ -0,0 +1,75 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportTasksVisitsEveryPage(t *testing.T) {
+	fake := pagedQueryFake(250)
+	client := newTestClient(fake)
+
+	var ids []string
+	err := client.ExportTasks(context.Background(), "tasks", func(task Task) error {
+		ids = append(ids, task.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExportTasks failed: %v", err)
+	}
+	if len(ids) != 250 || ids[0] != "page-0" || ids[249] != "page-249" {
+		t.Errorf("Expected the 250 pages in order, got %d", len(ids))
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if len(queries) != 3 {
+		t.Fatalf("Expected 3 pages of results, got %d queries", len(queries))
+	}
+	if !strings.Contains(string(queries[0].Body), `"sorts":[{"timestamp":"created_time","direction":"ascending"}]`) ||
+		strings.Contains(string(queries[0].Body), `"filter"`) {
+		t.Errorf("Expected an unfiltered query, oldest first, got %s", queries[0].Body)
+	}
+}
+
+func TestExportTasksStopsAtVisitError(t *testing.T) {
+	fake := pagedQueryFake(250)
+	client := newTestClient(fake)
+
+	stop := errors.New("client went away")
+	visited := 0
+	err := client.ExportTasks(context.Background(), "tasks", func(task Task) error {
+		visited++
+		if visited == 3 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || visited != 3 {
+		t.Errorf("Expected the visit error after 3 tasks, got %v after %d", err, visited)
+	}
+	if queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query"); len(queries) != 1 {
+		t.Errorf("Expected no further pages to be fetched, got %d queries", len(queries))
+	}
+}
+
+func TestExportColumnsLeaveOutTitleAndButtons(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "database", "id": "tasks-db", "properties": {
+			"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+			"status": {"id": "st", "name": "status", "type": "select", "select": {"options": []}},
+			"Date": {"id": "dt", "name": "Date", "type": "date", "date": {}},
+			"Done button": {"id": "btn", "name": "Done button", "type": "checkbox", "checkbox": {}}
+		}}`
+	}}
+
+	columns, err := newTestClient(fake).ExportColumns(context.Background(), "tasks")
+	if err != nil {
+		t.Fatalf("ExportColumns failed: %v", err)
+	}
+	if strings.Join(columns, ",") != "Date,status" {
+		t.Errorf("Expected Date and status, got %q", columns)
+	}
+}
@@ -0,0 +1,248 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// maxCopiedBlocks caps how much of a page body is read for copying
+const maxCopiedBlocks = 100
+
+// blockText returns the plain text of a text block, false for blocks without text such
+// as images or dividers
+func blockText(block notionapi.Block) (string, bool) {
+	var richText []notionapi.RichText
+	switch b := block.(type) {
+	case *notionapi.ParagraphBlock:
+		richText = b.Paragraph.RichText
+	case *notionapi.Heading1Block:
+		richText = b.Heading1.RichText
+	case *notionapi.Heading2Block:
+		richText = b.Heading2.RichText
+	case *notionapi.Heading3Block:
+		richText = b.Heading3.RichText
+	case *notionapi.BulletedListItemBlock:
+		richText = b.BulletedListItem.RichText
+	case *notionapi.NumberedListItemBlock:
+		richText = b.NumberedListItem.RichText
+	case *notionapi.ToDoBlock:
+		richText = b.ToDo.RichText
+	case *notionapi.QuoteBlock:
+		richText = b.Quote.RichText
+	case *notionapi.CalloutBlock:
+		richText = b.Callout.RichText
+	default:
+		return "", false
+	}
+
+	var text strings.Builder
+	for _, rt := range richText {
+		text.WriteString(rt.PlainText)
+	}
+	return text.String(), true
+}
+
+// pageBodyText returns the text of a page's top-level blocks, one line per block
+func (c *Client) pageBodyText(ctx context.Context, pageID string) (string, error) {
+	response, err := c.client.Block.GetChildren(ctx, notionapi.BlockID(pageID), &notionapi.Pagination{PageSize: maxCopiedBlocks})
+	if err != nil {
+		return "", fmt.Errorf("failed to get page content: %w", err)
+	}
+
+	var lines []string
+	for _, block := range response.Results {
+		if text, ok := blockText(block); ok {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// journalDateKey returns the date property of the journal database that receives the
+// day moved tasks were created, preferring one named Date
+func journalDateKey(dbProps map[string]notionapi.PropertyConfig) string {
+	if prop, ok := dbProps[datePropertyKey]; ok && prop.GetType() == notionapi.PropertyConfigTypeDate {
+		return datePropertyKey
+	}
+	for key, prop := range dbProps {
+		if prop.GetType() == notionapi.PropertyConfigTypeDate {
+			return key
+		}
+	}
+	return ""
+}
+
+// MoveTaskToJournal copies a task into the journal database and archives the original.
+// The title, the day it was created (into the journal's date property), rich text properties
+// the journal also has and the text of the page body are copied. The new page's ID is
+// returned even when archiving the task fails afterwards.
+func (c *Client) MoveTaskToJournal(ctx context.Context, taskID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if c.journalDbID == "" {
+		return "", fmt.Errorf("database ID for journal not configured")
+	}
+
+	page, err := c.client.Page.Get(ctx, notionapi.PageID(taskID))
+	if err != nil {
+		return "", fmt.Errorf("failed to get task %s: %w", taskID, err)
+	}
+	task, err := c.transformPageToTask(*page, c.newMentionResolver(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to read task %s: %w", taskID, err)
+	}
+	body, err := c.pageBodyText(ctx, taskID)
+	if err != nil {
+		return "", err
+	}
+
+	journalProps, err := c.GetDatabaseProperties(ctx, "journal")
+	if err != nil {
+		return "", fmt.Errorf("could not fetch journal schema: %w", err)
+	}
+	properties := make(map[string]interface{})
+	if key := journalDateKey(journalProps); key != "" {
+		properties[key] = task.CreatedAt.Format("2006-01-02")
+	}
+	for key, prop := range page.Properties {
+		text, ok := prop.(*notionapi.RichTextProperty)
+		if !ok || len(text.RichText) == 0 {
+			continue
+		}
+		if config, ok := journalProps[key]; ok && config.GetType() == notionapi.PropertyConfigTypeRichText {
+			properties[key] = task.Properties[key]
+		}
+	}
+
+	title := task.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	plan, err := c.PlanCreateTask(ctx, title, properties, "journal")
+	if err != nil {
+		return "", err
+	}
+	plan.SetContent(body)
+	journalID, err := c.ExecuteCreatePlan(ctx, plan)
+	if err != nil {
+		return "", fmt.Errorf("failed to create journal entry: %w", err)
+	}
+
+	archive := &notionapi.PageUpdateRequest{Properties: notionapi.Properties{}, Archived: true}
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(taskID), archive); err != nil && !strings.Contains(err.Error(), errButtonProperty) {
+		return journalID, fmt.Errorf("created journal entry %s but failed to archive task %s: %w", journalID, taskID, err)
+	}
+
+	log.Printf("Moved task %s to journal entry %s", taskID, journalID)
+	return journalID, nil
+}
+
+// dailyJournalTitle is the title of the journal page created for a day
+func dailyJournalTitle(day time.Time) string {
+	return day.Format("Monday, 2 January 2006")
+}
+
+// AppendToDailyJournal appends text, prefixed with the time of date, to the journal page
+// whose date property is date's calendar day, creating the page when there is none yet
+func (c *Client) AppendToDailyJournal(ctx context.Context, date time.Time, text string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if c.journalDbID == "" {
+		return fmt.Errorf("database ID for journal not configured")
+	}
+
+	c.journalMu.Lock()
+	pageID, err := c.dailyJournalPage(ctx, date)
+	c.journalMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	blocks := contentBlocks(date.Format("15:04") + " " + text)
+	if len(blocks) == 0 {
+		return fmt.Errorf("nothing to append to the journal")
+	}
+	if err := c.appendRemainingBlocks(ctx, pageID, blocks); err != nil {
+		return fmt.Errorf("failed to append to journal page %s: %w", pageID, err)
+	}
+	return nil
+}
+
+// dailyJournalPage returns the journal page of date's day, creating it if needed. The lock
+// only covers this process, so after creating a page the day is looked up again and when
+// someone else created one at the same time the oldest page is kept and ours is archived.
+func (c *Client) dailyJournalPage(ctx context.Context, date time.Time) (string, error) {
+	journalProps, err := c.GetDatabaseProperties(ctx, "journal")
+	if err != nil {
+		return "", fmt.Errorf("could not fetch journal schema: %w", err)
+	}
+	key := journalDateKey(journalProps)
+	if key == "" {
+		return "", fmt.Errorf("journal database has no date property")
+	}
+
+	existing, err := c.findJournalPage(ctx, key, date)
+	if err != nil {
+		return "", err
+	}
+	if existing != "" {
+		return existing, nil
+	}
+
+	day := date.Format("2006-01-02")
+	plan, err := c.PlanCreateTask(ctx, dailyJournalTitle(date), map[string]interface{}{key: day}, "journal")
+	if err != nil {
+		return "", err
+	}
+	created, err := c.ExecuteCreatePlan(ctx, plan)
+	if err != nil {
+		return "", fmt.Errorf("failed to create journal page for %s: %w", day, err)
+	}
+	log.Printf("Created journal page %s for %s", created, day)
+
+	existing, err = c.findJournalPage(ctx, key, date)
+	if err != nil {
+		log.Printf("Warning: Could not check for another journal page for %s: %v", day, err)
+		return created, nil
+	}
+	if existing == "" || existing == created {
+		return created, nil
+	}
+
+	log.Printf("Journal page %s for %s already existed, archiving duplicate %s", existing, day, created)
+	archive := &notionapi.PageUpdateRequest{Properties: notionapi.Properties{}, Archived: true}
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(created), archive); err != nil && !strings.Contains(err.Error(), errButtonProperty) {
+		log.Printf("Warning: Could not archive duplicate journal page %s: %v", created, err)
+	}
+	return existing, nil
+}
+
+// findJournalPage returns the oldest journal page whose date property key is date's day,
+// empty when there is none
+func (c *Client) findJournalPage(ctx context.Context, key string, date time.Time) (string, error) {
+	// Daily pages are dated without a time, which Notion compares as midnight UTC
+	start := notionapi.Date(time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC))
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.PropertyFilter{
+			Property: key,
+			Date:     &notionapi.DateFilterCondition{Equals: &start},
+		},
+		Sorts:    []notionapi.SortObject{{Timestamp: notionapi.TimestampCreated, Direction: notionapi.SortOrderASC}},
+		PageSize: 1,
+	}
+	response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(c.journalDbID), query)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up the journal page for %s: %w", date.Format("2006-01-02"), err)
+	}
+	if len(response.Results) == 0 {
+		return "", nil
+	}
+	return string(response.Results[0].ID), nil
+}
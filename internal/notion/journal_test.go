@@ -0,0 +1,305 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// movableTask answers the requests MoveTaskToJournal makes for a task with a note and a
+// two-line body, failing archive requests when archiveStatus isn't OK
+func movableTask(archiveStatus int) *fakeNotion {
+	return &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch {
+		case method == http.MethodGet && path == "/v1/pages/task-1":
+			return http.StatusOK, `{
+				"object": "page", "id": "task-1", "created_time": "2024-03-15T08:30:00.000Z",
+				"parent": {"type": "database_id", "database_id": "tasks-db"},
+				"properties": {
+					"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Felt great after the run"}, "plain_text": "Felt great after the run"}]},
+					"Notes": {"id": "notes", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "5km"}, "plain_text": "5km"}]},
+					"Estimate": {"id": "est", "type": "number", "number": 2}
+				}
+			}`
+		case method == http.MethodGet && path == "/v1/blocks/task-1/children":
+			return http.StatusOK, `{"object": "list", "results": [
+				{"object": "block", "id": "b1", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "Morning run"}, "plain_text": "Morning run"}]}},
+				{"object": "block", "id": "b2", "type": "divider", "divider": {}},
+				{"object": "block", "id": "b3", "type": "bulleted_list_item", "bulleted_list_item": {"rich_text": [{"type": "text", "text": {"content": "no knee pain"}, "plain_text": "no knee pain"}]}}
+			], "has_more": false}`
+		case method == http.MethodGet && path == "/v1/databases/journal-db":
+			return http.StatusOK, `{"object": "database", "id": "journal-db", "properties": {
+				"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+				"Date": {"id": "date", "name": "Date", "type": "date", "date": {}},
+				"Notes": {"id": "notes", "name": "Notes", "type": "rich_text", "rich_text": {}}
+			}}`
+		case method == http.MethodPost && path == "/v1/pages":
+			return http.StatusOK, `{"object": "page", "id": "journal-1"}`
+		case method == http.MethodPatch && path == "/v1/pages/task-1":
+			if archiveStatus != http.StatusOK {
+				return archiveStatus, `{"object": "error", "status": 500, "code": "internal_server_error", "message": "boom"}`
+			}
+			return http.StatusOK, `{"object": "page", "id": "task-1", "archived": true}`
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+}
+
+func TestMoveTaskToJournal(t *testing.T) {
+	fake := movableTask(http.StatusOK)
+	client := newTestClient(fake)
+
+	journalID, err := client.MoveTaskToJournal(context.Background(), "task-1")
+	if err != nil {
+		t.Fatalf("MoveTaskToJournal failed: %v", err)
+	}
+	if journalID != "journal-1" {
+		t.Errorf("Expected journal-1, got %s", journalID)
+	}
+
+	creates := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(creates) != 1 {
+		t.Fatalf("Expected 1 page creation, got %d", len(creates))
+	}
+	// The number property has no counterpart in the journal and the divider has no text
+	want := `{
+		"parent": {"type": "database_id", "database_id": "journal-db"},
+		"properties": {
+			"Name": {"title": [{"type": "text", "text": {"content": "Felt great after the run"}}]},
+			"Date": {"date": {"start": "2024-03-15"}},
+			"Notes": {"rich_text": [{"text": {"content": "5km"}}]}
+		},
+		"children": [
+			{"object": "block", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "Morning run"}}]}},
+			{"object": "block", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "no knee pain"}}]}}
+		]
+	}`
+	if !jsonEqual(t, creates[0].Body, []byte(want)) {
+		t.Errorf("Expected payload %s, got %s", want, creates[0].Body)
+	}
+
+	archives := fake.requestsTo(http.MethodPatch, "/v1/pages/task-1")
+	if len(archives) != 1 {
+		t.Fatalf("Expected 1 archive request, got %d", len(archives))
+	}
+	var archive struct {
+		Archived bool `json:"archived"`
+	}
+	if err := json.Unmarshal(archives[0].Body, &archive); err != nil || !archive.Archived {
+		t.Errorf("Expected the task to be archived, got %s", archives[0].Body)
+	}
+}
+
+func TestMoveTaskToJournalArchiveFailure(t *testing.T) {
+	client := newTestClient(movableTask(http.StatusInternalServerError))
+
+	// The entry already exists, so its ID is returned for the caller to report
+	journalID, err := client.MoveTaskToJournal(context.Background(), "task-1")
+	if err == nil || !strings.Contains(err.Error(), "archive") {
+		t.Errorf("Expected an archive error, got %v", err)
+	}
+	if journalID != "journal-1" {
+		t.Errorf("Expected journal-1 despite the error, got %q", journalID)
+	}
+}
+
+func TestMoveTaskToJournalWithoutJournal(t *testing.T) {
+	fake := movableTask(http.StatusOK)
+	client := newTestClient(fake)
+	client.journalDbID = ""
+
+	if _, err := client.MoveTaskToJournal(context.Background(), "task-1"); err == nil {
+		t.Error("Expected an error without a journal database")
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no API calls, got %d", len(fake.requests))
+	}
+}
+
+// dailyJournal answers the requests AppendToDailyJournal makes. Lookups return the oldest of
+// pages, a list of journal page IDs for the day that grows with every page created. When
+// rival is set, it is dated the same day by someone else the moment our first page exists.
+type dailyJournal struct {
+	mu      sync.Mutex
+	pages   []string
+	rival   string
+	created int
+}
+
+func (d *dailyJournal) fake() *fakeNotion {
+	return &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		switch {
+		case method == http.MethodGet && path == "/v1/databases/journal-db":
+			return http.StatusOK, `{"object": "database", "id": "journal-db", "properties": {
+				"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+				"Day": {"id": "day", "name": "Day", "type": "date", "date": {}}
+			}}`
+		case method == http.MethodPost && path == "/v1/databases/journal-db/query":
+			if len(d.pages) == 0 {
+				return http.StatusOK, `{"object": "list", "results": [], "has_more": false}`
+			}
+			return http.StatusOK, `{"object": "list", "results": [{"object": "page", "id": "` + d.pages[0] + `"}], "has_more": false}`
+		case method == http.MethodPost && path == "/v1/pages":
+			d.created++
+			id := fmt.Sprintf("journal-new-%d", d.created)
+			if d.rival != "" {
+				// The rival page was created first, so it sorts before ours
+				d.pages = append(d.pages, d.rival)
+				d.rival = ""
+			}
+			d.pages = append(d.pages, id)
+			return http.StatusOK, `{"object": "page", "id": "` + id + `"}`
+		case method == http.MethodPatch && strings.HasPrefix(path, "/v1/pages/"):
+			return http.StatusOK, `{"object": "page", "id": "` + strings.TrimPrefix(path, "/v1/pages/") + `", "archived": true}`
+		case method == http.MethodPatch && strings.HasPrefix(path, "/v1/blocks/"):
+			return http.StatusOK, `{"object": "list", "results": []}`
+		}
+		return http.StatusNotFound, `{"object": "error", "status": 404, "code": "object_not_found", "message": "not found"}`
+	}}
+}
+
+func TestAppendToDailyJournalExistingPage(t *testing.T) {
+	journal := &dailyJournal{pages: []string{"journal-1"}}
+	fake := journal.fake()
+	client := newTestClient(fake)
+
+	moscow := time.FixedZone("MSK", 3*60*60)
+	date := time.Date(2024, 3, 15, 0, 30, 0, 0, moscow)
+	if err := client.AppendToDailyJournal(context.Background(), date, "Slept well"); err != nil {
+		t.Fatalf("AppendToDailyJournal failed: %v", err)
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/journal-db/query")
+	if len(queries) != 1 {
+		t.Fatalf("Expected 1 lookup, got %d", len(queries))
+	}
+	// The day is the local one, it is still March 14 in UTC
+	wantQuery := `{
+		"filter": {"property": "Day", "date": {"equals": "2024-03-15T00:00:00Z"}},
+		"sorts": [{"timestamp": "created_time", "direction": "ascending"}],
+		"page_size": 1
+	}`
+	if !jsonEqual(t, queries[0].Body, []byte(wantQuery)) {
+		t.Errorf("Expected lookup %s, got %s", wantQuery, queries[0].Body)
+	}
+	if creates := fake.requestsTo(http.MethodPost, "/v1/pages"); len(creates) != 0 {
+		t.Errorf("Expected no page creation, got %d", len(creates))
+	}
+
+	appends := fake.requestsTo(http.MethodPatch, "/v1/blocks/journal-1/children")
+	if len(appends) != 1 {
+		t.Fatalf("Expected 1 append to journal-1, got %d", len(appends))
+	}
+	wantAppend := `{"children": [
+		{"object": "block", "type": "paragraph", "paragraph": {"rich_text": [{"type": "text", "text": {"content": "00:30 Slept well"}}]}}
+	]}`
+	if !jsonEqual(t, appends[0].Body, []byte(wantAppend)) {
+		t.Errorf("Expected append %s, got %s", wantAppend, appends[0].Body)
+	}
+}
+
+func TestAppendToDailyJournalCreatesPage(t *testing.T) {
+	journal := &dailyJournal{}
+	fake := journal.fake()
+	client := newTestClient(fake)
+
+	date := time.Date(2024, 3, 15, 18, 5, 0, 0, time.UTC)
+	if err := client.AppendToDailyJournal(context.Background(), date, "Finished the book"); err != nil {
+		t.Fatalf("AppendToDailyJournal failed: %v", err)
+	}
+
+	creates := fake.requestsTo(http.MethodPost, "/v1/pages")
+	if len(creates) != 1 {
+		t.Fatalf("Expected 1 page creation, got %d", len(creates))
+	}
+	wantCreate := `{
+		"parent": {"type": "database_id", "database_id": "journal-db"},
+		"properties": {
+			"Name": {"title": [{"type": "text", "text": {"content": "Friday, 15 March 2024"}}]},
+			"Day": {"date": {"start": "2024-03-15"}}
+		}
+	}`
+	if !jsonEqual(t, creates[0].Body, []byte(wantCreate)) {
+		t.Errorf("Expected payload %s, got %s", wantCreate, creates[0].Body)
+	}
+	if archives := fake.requestsTo(http.MethodPatch, "/v1/pages/journal-new-1"); len(archives) != 0 {
+		t.Errorf("Expected the new page to be kept, got %d archive requests", len(archives))
+	}
+	if appends := fake.requestsTo(http.MethodPatch, "/v1/blocks/journal-new-1/children"); len(appends) != 1 {
+		t.Errorf("Expected 1 append to the new page, got %d", len(appends))
+	}
+}
+
+func TestAppendToDailyJournalCreateRace(t *testing.T) {
+	// Another instance creates the day's page between our lookup and our create
+	journal := &dailyJournal{rival: "journal-rival"}
+	fake := journal.fake()
+	client := newTestClient(fake)
+
+	date := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	if err := client.AppendToDailyJournal(context.Background(), date, "Coffee with Anna"); err != nil {
+		t.Fatalf("AppendToDailyJournal failed: %v", err)
+	}
+
+	archives := fake.requestsTo(http.MethodPatch, "/v1/pages/journal-new-1")
+	if len(archives) != 1 {
+		t.Fatalf("Expected our duplicate to be archived, got %d archive requests", len(archives))
+	}
+	if !strings.Contains(string(archives[0].Body), `"archived":true`) {
+		t.Errorf("Expected an archive request, got %s", archives[0].Body)
+	}
+	if appends := fake.requestsTo(http.MethodPatch, "/v1/blocks/journal-rival/children"); len(appends) != 1 {
+		t.Errorf("Expected 1 append to the rival page, got %d", len(appends))
+	}
+	if appends := fake.requestsTo(http.MethodPatch, "/v1/blocks/journal-new-1/children"); len(appends) != 0 {
+		t.Errorf("Expected nothing appended to the duplicate, got %d", len(appends))
+	}
+}
+
+func TestAppendToDailyJournalConcurrentMessages(t *testing.T) {
+	journal := &dailyJournal{}
+	client := newTestClient(journal.fake())
+
+	date := time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, text := range []string{"First thought", "Second thought"} {
+		wg.Add(1)
+		go func(text string) {
+			defer wg.Done()
+			errs <- client.AppendToDailyJournal(context.Background(), date, text)
+		}(text)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("AppendToDailyJournal failed: %v", err)
+		}
+	}
+	if journal.created != 1 {
+		t.Errorf("Expected 1 daily page for messages in the same minute, got %d", journal.created)
+	}
+}
+
+func TestAppendToDailyJournalWithoutDateProperty(t *testing.T) {
+	client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "database", "id": "journal-db", "properties": {
+			"Name": {"id": "title", "name": "Name", "type": "title", "title": {}}
+		}}`
+	}})
+
+	err := client.AppendToDailyJournal(context.Background(), time.Now(), "Hello")
+	if err == nil || !strings.Contains(err.Error(), "date property") {
+		t.Errorf("Expected a missing date property error, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package notion
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/lru"
+)
+
+const (
+	// mentionCacheTTL is how long resolved user and page names are reused
+	mentionCacheTTL = time.Hour
+	// maxMentionLookups caps the API calls a single listing makes to resolve mentions
+	maxMentionLookups = 20
+	// mentionCacheCap bounds how many resolved names are kept
+	mentionCacheCap = 1000
+)
+
+// mentionResolver renders rich text with mentions resolved to readable names. One resolver
+// is used per listing so its lookup budget bounds the requests a single run can make.
+type mentionResolver struct {
+	client  *Client
+	ctx     context.Context
+	lookups int
+}
+
+// noLookups renders rich text without resolving mentions through the API
+var noLookups *mentionResolver
+
+// newMentionResolver creates a resolver for one listing
+func (c *Client) newMentionResolver(ctx context.Context) *mentionResolver {
+	return &mentionResolver{client: c, ctx: ctx}
+}
+
+// plainText joins rich text segments. User and page mentions are resolved to their names
+// and date mentions rendered as dates. A nil resolver doesn't look anything up.
+func (r *mentionResolver) plainText(segments []notionapi.RichText) string {
+	var text strings.Builder
+	for _, segment := range segments {
+		if segment.Mention == nil {
+			text.WriteString(segment.PlainText)
+			continue
+		}
+		text.WriteString(r.mention(segment))
+	}
+	return text.String()
+}
+
+// mention renders a single mention segment, falling back to Notion's plain text
+func (r *mentionResolver) mention(segment notionapi.RichText) string {
+	mention := segment.Mention
+
+	switch mention.Type {
+	case "user":
+		if mention.User == nil {
+			break
+		}
+		if mention.User.Name != "" {
+			return "@" + mention.User.Name
+		}
+		if name := r.lookup("user:"+string(mention.User.ID), func() (string, error) {
+			user, err := r.client.client.User.Get(r.ctx, mention.User.ID)
+			if err != nil {
+				return "", err
+			}
+			return user.Name, nil
+		}); name != "" {
+			return "@" + name
+		}
+
+	case "page":
+		if mention.Page == nil {
+			break
+		}
+		if name := r.lookup("page:"+string(mention.Page.ID), func() (string, error) {
+			page, err := r.client.client.Page.Get(r.ctx, notionapi.PageID(mention.Page.ID))
+			if err != nil {
+				return "", err
+			}
+			// One level only, mentions inside the target title are not resolved
+			if title, ok := page.Properties[pageTitleKey(*page)].(*notionapi.TitleProperty); ok {
+				return noLookups.plainText(title.Title), nil
+			}
+			return "", nil
+		}); name != "" {
+			return name
+		}
+
+	case "date":
+		if mention.Date != nil && mention.Date.Start != nil {
+			text := formatMentionDate(mention.Date.Start)
+			if mention.Date.End != nil {
+				text += " → " + formatMentionDate(mention.Date.End)
+			}
+			return text
+		}
+	}
+
+	return segment.PlainText
+}
+
+// lookup returns a cached name or fetches it while the resolver has budget left.
+// Failed lookups are cached too, so a broken mention isn't retried on every run.
+func (r *mentionResolver) lookup(key string, fetch func() (string, error)) string {
+	if r == nil {
+		return ""
+	}
+	cache := r.client.mentionCache()
+
+	if name, ok := cache.Get(key); ok {
+		return name
+	}
+
+	if r.lookups >= maxMentionLookups {
+		return ""
+	}
+	r.lookups++
+
+	name, err := fetch()
+	if err != nil {
+		log.Printf("Warning: Could not resolve mention %s: %v", key, err)
+	}
+
+	cache.Add(key, name)
+	return name
+}
+
+// mentionCache returns the resolved mention names, creating the cache on first use
+func (c *Client) mentionCache() *lru.Cache[string, string] {
+	c.mentionMu.Lock()
+	defer c.mentionMu.Unlock()
+
+	if c.mentionNames == nil {
+		c.mentionNames = lru.New[string, string](mentionCacheCap, mentionCacheTTL)
+		lru.Register("notion.mentions", c.mentionNames)
+	}
+	return c.mentionNames
+}
+
+// formatMentionDate renders a mentioned date, without the time for date-only values
+func formatMentionDate(date *notionapi.Date) string {
+	t := time.Time(*date)
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("2006-01-02 15:04")
+}
@@ -0,0 +1,170 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// maxOptionSuggestions is how many of the nearest options an unknown value is answered with
+const maxOptionSuggestions = 3
+
+// ErrUnknownOption is returned for select and multi-select values that are no option of the
+// property, see OptionError
+var ErrUnknownOption = errors.New("unknown option")
+
+// OptionError reports a select or multi-select value that isn't an option of its property,
+// with the nearest options
+type OptionError struct {
+	Property    string
+	Value       string
+	Suggestions []string
+}
+
+func (e *OptionError) Error() string {
+	msg := fmt.Sprintf("%s: %q is not an option", e.Property, e.Value)
+	if len(e.Suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean %s?", strings.Join(e.Suggestions, ", "))
+	}
+	return msg
+}
+
+func (e *OptionError) Unwrap() error {
+	return ErrUnknownOption
+}
+
+// newOptionsKey marks a context whose writes may create select options
+type newOptionsKey struct{}
+
+// WithNewOptions returns a context in which select and multi-select values that match no
+// option are created as new options instead of being rejected
+func WithNewOptions(ctx context.Context) context.Context {
+	return context.WithValue(ctx, newOptionsKey{}, true)
+}
+
+// newOptionsAllowed reports whether unknown options are created, for this request or for
+// every request with ALLOW_NEW_OPTIONS=true
+func (c *Client) newOptionsAllowed(ctx context.Context) bool {
+	allowed, _ := ctx.Value(newOptionsKey{}).(bool)
+	return allowed || c.allowNewOptions
+}
+
+// allowNewOptionsFromEnv reads ALLOW_NEW_OPTIONS
+func allowNewOptionsFromEnv() bool {
+	return strings.EqualFold(os.Getenv("ALLOW_NEW_OPTIONS"), "true")
+}
+
+// propertyOptions returns the options of a select or multi-select property config, nil for
+// other types and without a schema
+func propertyOptions(prop notionapi.PropertyConfig) []notionapi.Option {
+	switch config := prop.(type) {
+	case *notionapi.SelectPropertyConfig:
+		return config.Select.Options
+	case *notionapi.MultiSelectPropertyConfig:
+		return config.MultiSelect.Options
+	}
+	return nil
+}
+
+// resolveOption matches a value against the options of a property. An exact match is used
+// as it is, and a value differing only in case is corrected to the option. When new options
+// are allowed anything else becomes one, a value a typo away may well be a new option like
+// another chat's. Otherwise a typo is corrected and the rest is an OptionError. Properties
+// listing no options accept every value, the schemas guessed from a page don't know them.
+func (c *Client) resolveOption(ctx context.Context, key, value string, options []notionapi.Option) (string, error) {
+	if len(options) == 0 {
+		return value, nil
+	}
+	for _, option := range options {
+		if option.Name == value {
+			return value, nil
+		}
+	}
+
+	trimmed := strings.TrimSpace(value)
+	for _, option := range options {
+		if strings.EqualFold(option.Name, trimmed) {
+			log.Printf("Corrected %s option %q to %q", key, value, option.Name)
+			return option.Name, nil
+		}
+	}
+
+	if c.newOptionsAllowed(ctx) {
+		log.Printf("Creating new %s option %q", key, value)
+		return value, nil
+	}
+
+	nearest := nearestOptions(trimmed, options)
+	if len(nearest) == 1 || (len(nearest) > 1 && nearest[0].distance < nearest[1].distance) {
+		if best := nearest[0]; best.distance <= typoDistance(trimmed) {
+			log.Printf("Corrected %s option %q to %q", key, value, best.name)
+			return best.name, nil
+		}
+	}
+
+	optErr := &OptionError{Property: key, Value: value}
+	for i := 0; i < len(nearest) && i < maxOptionSuggestions; i++ {
+		optErr.Suggestions = append(optErr.Suggestions, nearest[i].name)
+	}
+	return "", optErr
+}
+
+// typoDistance is the largest edit distance a value is corrected over: one edit for short
+// values, two for longer ones
+func typoDistance(value string) int {
+	if len([]rune(value)) <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// optionDistance is an option with its edit distance to a value
+type optionDistance struct {
+	name     string
+	distance int
+}
+
+// nearestOptions orders the options by their edit distance to value, ignoring case
+func nearestOptions(value string, options []notionapi.Option) []optionDistance {
+	nearest := make([]optionDistance, 0, len(options))
+	for _, option := range options {
+		nearest = append(nearest, optionDistance{option.Name, editDistance(strings.ToLower(value), strings.ToLower(option.Name))})
+	}
+	sort.SliceStable(nearest, func(i, j int) bool {
+		return nearest[i].distance < nearest[j].distance
+	})
+	return nearest
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of adjacent runes
+// turning a into b, the optimal string alignment distance
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows := make([][]int, len(ra)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(rb)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(ra)][len(rb)]
+}
@@ -0,0 +1,139 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+// optionsSchemaJSON is a database with a multi-select and a select listing their options
+const optionsSchemaJSON = `{
+	"object": "database",
+	"id": "tasks-db",
+	"properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": [{"name": "home"}, {"name": "work"}, {"name": "errands"}]}},
+		"project": {"id": "proj", "name": "project", "type": "select", "select": {"options": [{"name": "Home"}, {"name": "Garden"}, {"name": "Taxes"}]}}
+	}
+}`
+
+func TestSelectOptionValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		allowNew bool
+		expected []string // Option names set, nil when the value is rejected
+		suggest  []string
+	}{
+		{"exact", "Garden", false, []string{"Garden"}, nil},
+		{"case", "garden", false, []string{"Garden"}, nil},
+		{"typo", "Hme", false, []string{"Home"}, nil},
+		{"two typos", "Gardne", false, []string{"Garden"}, nil},
+		{"tags", []interface{}{"Home", "wrok"}, false, []string{"home", "work"}, nil},
+		{"unknown", "Vacation", false, nil, []string{"Garden", "Taxes", "Home"}},
+		{"unknown tag", []interface{}{"home", "music"}, false, nil, []string{"home", "work", "errands"}},
+		{"allow new", "Vacation", true, []string{"Vacation"}, nil},
+		{"allow new still corrects case", "home", true, []string{"Home"}, nil},
+		{"allow new keeps near misses", "hme", true, []string{"hme"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				return http.StatusOK, optionsSchemaJSON
+			}})
+			key := "project"
+			if _, ok := tt.value.([]interface{}); ok {
+				key = "Tags"
+			}
+			ctx := context.Background()
+			if tt.allowNew {
+				ctx = WithNewOptions(ctx)
+			}
+
+			plan, err := client.PlanCreateTask(ctx, "Buy milk", map[string]interface{}{key: tt.value}, "tasks")
+			if tt.expected == nil {
+				var optionErr *OptionError
+				if !errors.As(err, &optionErr) || !errors.Is(err, ErrUnknownOption) {
+					t.Fatalf("Expected an OptionError, got %v", err)
+				}
+				if optionErr.Property != key || !reflect.DeepEqual(optionErr.Suggestions, tt.suggest) {
+					t.Errorf("Expected suggestions %v for %s, got %+v", tt.suggest, key, optionErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PlanCreateTask failed: %v", err)
+			}
+
+			var names []string
+			switch prop := plan.Request.Properties[key].(type) {
+			case notionapi.SelectProperty:
+				names = []string{prop.Select.Name}
+			case notionapi.MultiSelectProperty:
+				for _, option := range prop.MultiSelect {
+					names = append(names, option.Name)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestNewOptionsKeepNearMissChats(t *testing.T) {
+	client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "database", "id": "tasks-db", "properties": {
+			"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+			"Source": {"id": "src", "name": "Source", "type": "select", "select": {"options": [{"name": "Family [-100111]"}]}}
+		}}`
+	}})
+
+	plan, err := client.PlanCreateTask(WithNewOptions(context.Background()), "Buy milk", map[string]interface{}{"Source": "Family [-100112]"}, "tasks")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+	if prop, _ := plan.Request.Properties["Source"].(notionapi.SelectProperty); prop.Select.Name != "Family [-100112]" {
+		t.Errorf("Expected the other chat's option to be created, got %+v", plan.Request.Properties["Source"])
+	}
+}
+
+func TestAllowNewOptionsFromEnv(t *testing.T) {
+	t.Setenv("ALLOW_NEW_OPTIONS", "true")
+	client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, optionsSchemaJSON
+	}})
+	client.allowNewOptions = allowNewOptionsFromEnv()
+
+	plan, err := client.PlanCreateTask(context.Background(), "Buy milk", map[string]interface{}{"project": "Vacation"}, "tasks")
+	if err != nil {
+		t.Fatalf("Expected ALLOW_NEW_OPTIONS to accept a new option, got %v", err)
+	}
+	if prop, _ := plan.Request.Properties["project"].(notionapi.SelectProperty); prop.Select.Name != "Vacation" {
+		t.Errorf("Expected the new option, got %+v", plan.Request.Properties["project"])
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"home", "home", 0},
+		{"hme", "home", 1},
+		{"wrok", "work", 1},
+		{"gardne", "garden", 1},
+		{"дом", "дым", 1},
+		{"", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.expected {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+const (
+	// AssigneePropertyKey is the tasks database people property /assign sets
+	AssigneePropertyKey = "Assignee"
+	// usersCacheTTL is how long the workspace's users are reused for email lookups
+	usersCacheTTL = 10 * time.Minute
+)
+
+// ErrUserNotFound is returned for people values naming no user of the workspace
+var ErrUserNotFound = errors.New("notion user not found")
+
+// User is a person of the Notion workspace
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// ListUsers returns the people of the workspace, bots left out. The list is cached for
+// usersCacheTTL.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	c.usersMu.Lock()
+	defer c.usersMu.Unlock()
+	if time.Now().Before(c.usersExpiry) {
+		return c.users, nil
+	}
+	return c.fetchUsers(ctx)
+}
+
+// fetchUsers lists the users through the API and caches them. Callers hold usersMu.
+func (c *Client) fetchUsers(ctx context.Context) ([]User, error) {
+	var users []User
+	pagination := &notionapi.Pagination{PageSize: maxQueryPageSize}
+	for {
+		response, err := c.client.User.List(ctx, pagination)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list users: %w", err)
+		}
+		for _, user := range response.Results {
+			if user.Type == notionapi.UserTypeBot {
+				continue
+			}
+			u := User{ID: string(user.ID), Name: user.Name}
+			if user.Person != nil {
+				u.Email = user.Person.Email
+			}
+			users = append(users, u)
+		}
+		if !response.HasMore || response.NextCursor == "" {
+			break
+		}
+		pagination.StartCursor = response.NextCursor
+	}
+
+	c.users = users
+	c.usersExpiry = time.Now().Add(usersCacheTTL)
+	return users, nil
+}
+
+// userByEmail resolves an email, ignoring case. A miss lists the users again in case the
+// person joined the workspace since.
+func (c *Client) userByEmail(ctx context.Context, email string) (User, error) {
+	email = strings.TrimSpace(email)
+	find := func(users []User) (User, bool) {
+		for _, user := range users {
+			if strings.EqualFold(user.Email, email) {
+				return user, true
+			}
+		}
+		return User{}, false
+	}
+
+	c.usersMu.Lock()
+	defer c.usersMu.Unlock()
+	if time.Now().Before(c.usersExpiry) {
+		if user, ok := find(c.users); ok {
+			return user, nil
+		}
+	}
+
+	users, err := c.fetchUsers(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("could not look up %s: %w", email, err)
+	}
+	if user, ok := find(users); ok {
+		return user, nil
+	}
+	return User{}, fmt.Errorf("%w: no one in the workspace has the email %s", ErrUserNotFound, email)
+}
+
+// userID returns the ID of the user a people value refers to: a user ID as it is, or an
+// email looked up among the workspace's users
+func (c *Client) userID(ctx context.Context, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if strings.Contains(ref, "@") {
+		user, err := c.userByEmail(ctx, ref)
+		if err != nil {
+			return "", err
+		}
+		return user.ID, nil
+	}
+	if id, err := ParsePageID(ref); err == nil {
+		return id, nil
+	}
+	return "", fmt.Errorf("%w: %q is neither a user ID nor an email", ErrUserNotFound, ref)
+}
+
+// handlePeopleProperty sets the people given by value: a user ID or email, or a list of
+// them. An email that matches no one is an error rather than a task left unassigned.
+func (c *Client) handlePeopleProperty(ctx context.Context, props notionapi.Properties, key string, value interface{}) error {
+	var refs []string
+	switch v := value.(type) {
+	case string:
+		refs = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if ref, ok := item.(string); ok {
+				refs = append(refs, ref)
+			}
+		}
+	default:
+		log.Printf("Unsupported type for people property %s, skipping", key)
+		return nil
+	}
+
+	people := make([]notionapi.User, 0, len(refs))
+	for _, ref := range refs {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+		id, err := c.userID(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		people = append(people, notionapi.User{Object: notionapi.ObjectTypeUser, ID: notionapi.UserID(id)})
+	}
+	props[key] = notionapi.PeopleProperty{
+		People: people,
+	}
+	return nil
+}
+
+// AssignTask sets the Assignee of a task to the user with the given email or ID
+func (c *Client) AssignTask(ctx context.Context, taskID, user string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	plan, err := c.PlanUpdateTask(ctx, taskID, "", map[string]interface{}{AssigneePropertyKey: user})
+	if err != nil {
+		return err
+	}
+	if _, ok := plan.Request.Properties[AssigneePropertyKey]; !ok {
+		return fmt.Errorf("%w: %s", ErrInvalidUpdate, strings.Join(plan.Warnings, "; "))
+	}
+
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(plan.PageID), plan.Request); err != nil {
+		return fmt.Errorf("failed to assign task: %w", err)
+	}
+
+	log.Printf("Assigned task %s to %s", taskID, user)
+	return nil
+}
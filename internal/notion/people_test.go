@@ -0,0 +1,119 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// peopleSchemaJSON is a tasks database with an Assignee people property
+const peopleSchemaJSON = `{"object": "database", "id": "tasks-db", "properties": {
+	"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+	"Assignee": {"id": "ppl", "name": "Assignee", "type": "people", "people": {}}
+}}`
+
+// usersPagesJSON are the two pages of the workspace's users, with a bot on the first
+var usersPagesJSON = []string{
+	`{"object": "list", "has_more": true, "next_cursor": "page-2", "results": [
+		{"object": "user", "id": "user-1", "type": "person", "name": "Anna", "person": {"email": "anna@example.com"}},
+		{"object": "user", "id": "bot-1", "type": "bot", "name": "Tasks bot", "bot": {}}
+	]}`,
+	`{"object": "list", "has_more": false, "next_cursor": null, "results": [
+		{"object": "user", "id": "user-2", "type": "person", "name": "Boris", "person": {"email": "Boris@Example.com"}}
+	]}`,
+}
+
+func newPeopleTestClient() (*Client, *fakeNotion) {
+	var listed int
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch {
+		case path == "/v1/users":
+			page := usersPagesJSON[listed%len(usersPagesJSON)]
+			listed++
+			return http.StatusOK, page
+		case method == http.MethodGet:
+			return http.StatusOK, peopleSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-42", "properties": {}}`
+	}}
+	return newTestClient(fake), fake
+}
+
+func TestListUsersIsCached(t *testing.T) {
+	client, fake := newPeopleTestClient()
+
+	for i := 0; i < 2; i++ {
+		users, err := client.ListUsers(context.Background())
+		if err != nil {
+			t.Fatalf("ListUsers failed: %v", err)
+		}
+		want := []User{{ID: "user-1", Name: "Anna", Email: "anna@example.com"}, {ID: "user-2", Name: "Boris", Email: "Boris@Example.com"}}
+		if len(users) != len(want) || users[0] != want[0] || users[1] != want[1] {
+			t.Errorf("Expected the people of both pages without the bot, got %+v", users)
+		}
+	}
+	if n := len(fake.requestsTo(http.MethodGet, "/v1/users")); n != 2 {
+		t.Errorf("Expected the second listing to come from the cache, got %d requests", n)
+	}
+}
+
+func TestPeoplePropertyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		lookups int // Users list requests
+	}{
+		{"user id", "0123456789abcdef0123456789abcdef", `{"people": [{"object": "user", "id": "01234567-89ab-cdef-0123-456789abcdef"}]}`, 0},
+		{"email", "anna@example.com", `{"people": [{"object": "user", "id": "user-1"}]}`, 2},
+		{"email in another case", " boris@example.COM ", `{"people": [{"object": "user", "id": "user-2"}]}`, 2},
+		{"several", []interface{}{"boris@example.com", "anna@example.com"}, `{"people": [{"object": "user", "id": "user-2"}, {"object": "user", "id": "user-1"}]}`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newPeopleTestClient()
+
+			plan, err := client.PlanCreateTask(context.Background(), "Water the plants", map[string]interface{}{"Assignee": tt.value}, "tasks")
+			if err != nil {
+				t.Fatalf("PlanCreateTask failed: %v", err)
+			}
+			got, _ := json.Marshal(plan.Request.Properties["Assignee"])
+			if !jsonEqual(t, got, []byte(tt.want)) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+			if n := len(fake.requestsTo(http.MethodGet, "/v1/users")); n != tt.lookups {
+				t.Errorf("Expected %d users requests, got %d", tt.lookups, n)
+			}
+		})
+	}
+}
+
+func TestUnknownEmailFailsTheTask(t *testing.T) {
+	client, fake := newPeopleTestClient()
+
+	_, err := client.CreateTask(context.Background(), "Task", map[string]interface{}{"Assignee": "carla@example.com"}, "tasks")
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("Expected ErrUserNotFound, got %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodPost, "/v1/pages")); n != 0 {
+		t.Errorf("Expected no task to be created unassigned, got %d creates", n)
+	}
+}
+
+func TestAssignTask(t *testing.T) {
+	client, fake := newPeopleTestClient()
+
+	if err := client.AssignTask(context.Background(), "page-42", "anna@example.com"); err != nil {
+		t.Fatalf("AssignTask failed: %v", err)
+	}
+	updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-42")
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update, got %d", len(updates))
+	}
+	want := `{"properties": {"Assignee": {"people": [{"object": "user", "id": "user-1"}]}}, "archived": false}`
+	if !jsonEqual(t, updates[0].Body, []byte(want)) {
+		t.Errorf("Expected %s, got %s", want, updates[0].Body)
+	}
+}
@@ -0,0 +1,349 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// ErrInvalidQuery is returned for task queries the database schema can't answer, such as
+// a status that isn't one of its options
+var ErrInvalidQuery = errors.New("invalid query")
+
+const (
+	// defaultTaskQueryLimit is the page size of GetTasksFiltered without a limit
+	defaultTaskQueryLimit = 10
+
+	// StatusAny lists tasks of every status, including done ones
+	StatusAny = "any"
+
+	// SometimesLaterTag marks tasks left out of listings unless asked for, like snoozed ones
+	SometimesLaterTag = "sometimes-later"
+)
+
+// TaskQueryOptions selects the tasks GetTasksFiltered returns. The zero value lists the
+// latest tasks that aren't done and aren't tagged sometimes-later, like GetRecentTasks.
+type TaskQueryOptions struct {
+	Limit   int    // Tasks per page, 1 to 100, defaults to 10
+	Cursor  string // NextCursor of the previous page
+	Status  string // Only this status; empty for every status but done, StatusAny for all
+	Tag     string // Only tasks with this tag; without it sometimes-later tasks are left out
+	Project string // Only tasks of this project
+	HasDate *bool  // Only tasks with (true) or without (false) a Date
+}
+
+// TaskPage is one page of GetTasksFiltered results
+type TaskPage struct {
+	Tasks      []Task    `json:"tasks"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	Info       *ListInfo `json:"-"`
+}
+
+// taskQueryProperties are the names of the properties a task query filters on, as they
+// are spelled in the database
+type taskQueryProperties struct {
+	status, tags, project, date string
+	statusIsStatus              bool // The status property is Notion's status type, not a select
+	statusIsCheckbox            bool // The status property is a checkbox, checked when done
+}
+
+// resolveTaskQueryProperties finds the filtered properties in the schema, ignoring case.
+// Without a schema the names the tasks database is known to use are assumed.
+func resolveTaskQueryProperties(dbProps map[string]notionapi.PropertyConfig) taskQueryProperties {
+	find := func(name string) string {
+		if _, ok := dbProps[name]; ok {
+			return name
+		}
+		for key := range dbProps {
+			if strings.EqualFold(key, name) {
+				return key
+			}
+		}
+		return name
+	}
+
+	names := taskQueryProperties{
+		status:  find(statusPropertyKey),
+		tags:    find("tags"),
+		project: find("project"),
+		date:    find(datePropertyKey),
+	}
+	_, names.statusIsStatus = dbProps[names.status].(*notionapi.StatusPropertyConfig)
+	if prop, ok := dbProps[names.status]; ok && !names.statusIsStatus {
+		names.statusIsCheckbox = prop.GetType() == notionapi.PropertyConfigTypeCheckbox
+	}
+	return names
+}
+
+// validateTaskQuery checks the options against the schema, so typos get an error instead
+// of an empty listing
+func validateTaskQuery(dbProps map[string]notionapi.PropertyConfig, names taskQueryProperties, opts TaskQueryOptions) error {
+	if opts.Limit < 0 || opts.Limit > maxQueryPageSize {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, maxQueryPageSize)
+	}
+	if dbProps == nil {
+		return nil
+	}
+
+	checkOption := func(param, property, value string) error {
+		prop, ok := dbProps[property]
+		if !ok {
+			return fmt.Errorf("%w: %s needs a %q property", ErrInvalidQuery, param, property)
+		}
+		var options []notionapi.Option
+		switch config := prop.(type) {
+		case *notionapi.SelectPropertyConfig:
+			options = config.Select.Options
+		case *notionapi.MultiSelectPropertyConfig:
+			options = config.MultiSelect.Options
+		default:
+			return nil // The status type doesn't list its options
+		}
+		for _, option := range options {
+			if option.Name == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %q is not a %s option", ErrInvalidQuery, value, property)
+	}
+
+	if opts.Status != "" && opts.Status != StatusAny {
+		if err := checkOption("status", names.status, opts.Status); err != nil {
+			return err
+		}
+	}
+	if opts.Tag != "" {
+		if err := checkOption("tag", names.tags, opts.Tag); err != nil {
+			return err
+		}
+	}
+	if opts.Project != "" {
+		if err := checkOption("project", names.project, opts.Project); err != nil {
+			return err
+		}
+	}
+	if opts.HasDate != nil {
+		if _, ok := dbProps[names.date]; !ok {
+			return fmt.Errorf("%w: has_date needs a %q property", ErrInvalidQuery, names.date)
+		}
+	}
+	return nil
+}
+
+// taskQueryFilter translates the options into a Notion filter, nil when nothing is filtered.
+// done holds the status values that mean done, from statusDoneNames.
+func taskQueryFilter(names taskQueryProperties, opts TaskQueryOptions, done []string) notionapi.Filter {
+	var filters notionapi.AndCompoundFilter
+
+	switch opts.Status {
+	case "":
+		filters = append(filters, notDoneConditions(names, done)...)
+	case StatusAny:
+	default:
+		filter := notionapi.PropertyFilter{Property: names.status}
+		switch {
+		case names.statusIsCheckbox:
+			checked := checkedStatus(opts.Status)
+			filter.Checkbox = &notionapi.CheckboxFilterCondition{Equals: checked, DoesNotEqual: !checked}
+		case names.statusIsStatus:
+			filter.Status = &notionapi.StatusFilterCondition{Equals: opts.Status}
+		default:
+			filter.Select = &notionapi.SelectFilterCondition{Equals: opts.Status}
+		}
+		filters = append(filters, filter)
+	}
+
+	if opts.Tag != "" {
+		filters = append(filters, notionapi.PropertyFilter{
+			Property:    names.tags,
+			MultiSelect: &notionapi.MultiSelectFilterCondition{Contains: opts.Tag},
+		})
+	} else {
+		filters = append(filters, notionapi.PropertyFilter{
+			Property:    names.tags,
+			MultiSelect: &notionapi.MultiSelectFilterCondition{DoesNotContain: SometimesLaterTag},
+		})
+	}
+
+	if opts.Project != "" {
+		filters = append(filters, notionapi.PropertyFilter{
+			Property: names.project,
+			Select:   &notionapi.SelectFilterCondition{Equals: opts.Project},
+		})
+	}
+
+	if opts.HasDate != nil {
+		condition := &notionapi.DateFilterCondition{IsEmpty: !*opts.HasDate, IsNotEmpty: *opts.HasDate}
+		filters = append(filters, notionapi.PropertyFilter{Property: names.date, Date: condition})
+	}
+
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return filters
+}
+
+// matchesTaskQuery applies the options to a task, for the button workaround where Notion
+// can't filter. done holds the status values that mean done, from statusDoneNames.
+func matchesTaskQuery(task Task, names taskQueryProperties, opts TaskQueryOptions, done []string) bool {
+	status, _ := task.Properties[names.status].(string)
+	checked, _ := task.Properties[names.status].(bool)
+	isDone := checked
+	if !names.statusIsCheckbox {
+		for _, name := range done {
+			isDone = isDone || status == name
+		}
+	}
+	switch opts.Status {
+	case "":
+		if isDone {
+			return false
+		}
+	case StatusAny:
+	default:
+		if names.statusIsCheckbox && checked != checkedStatus(opts.Status) || !names.statusIsCheckbox && status != opts.Status {
+			return false
+		}
+	}
+
+	tags, _ := task.Properties[names.tags].([]string)
+	hasTag := func(tag string) bool {
+		for _, t := range tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+	if opts.Tag != "" && !hasTag(opts.Tag) || opts.Tag == "" && hasTag(SometimesLaterTag) {
+		return false
+	}
+
+	if project, _ := task.Properties[names.project].(string); opts.Project != "" && project != opts.Project {
+		return false
+	}
+
+	if opts.HasDate != nil {
+		date, _ := task.Properties[names.date].(string)
+		if (date != "") != *opts.HasDate {
+			return false
+		}
+	}
+	return true
+}
+
+// GetTasksFiltered returns one page of the latest tasks matching the options. Pass the
+// returned NextCursor in the options to get the next page. Options the schema can't
+// answer return an error wrapping ErrInvalidQuery.
+func (c *Client) GetTasksFiltered(ctx context.Context, dbType string, opts TaskQueryOptions) (*TaskPage, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		log.Printf("Warning: Could not fetch database properties, filtering by the default property names: %v", err)
+	}
+	names := resolveTaskQueryProperties(dbProps)
+	if err := validateTaskQuery(dbProps, names, opts); err != nil {
+		return nil, err
+	}
+	if opts.Limit == 0 {
+		opts.Limit = defaultTaskQueryLimit
+	}
+	done := c.statusDoneNames(ctx, dbType, names)
+
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: taskQueryFilter(names, opts, done),
+		Sorts: []notionapi.SortObject{
+			{Property: "Created time", Direction: "descending"},
+		},
+		StartCursor: notionapi.Cursor(opts.Cursor),
+		PageSize:    opts.Limit,
+	}
+
+	response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), query)
+	if err != nil && strings.Contains(err.Error(), errButtonProperty) && c.refreshSchema(ctx, dbType) {
+		log.Printf("Warning: Button property detected during filtered query, retrying after a schema refresh")
+		response, err = c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), query)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), errButtonProperty) {
+			log.Printf("Warning: Button property detected during filtered query. Using workaround...")
+			return c.getTasksFilteredWithButtonWorkaround(ctx, dbID, names, opts, done)
+		}
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	page := &TaskPage{Tasks: make([]Task, 0, len(response.Results)), Info: &ListInfo{Filters: filtersServer}}
+	mentions := c.newMentionResolver(ctx)
+	for _, result := range response.Results {
+		task, err := c.transformPageToTask(result, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", result.ID, err)
+			continue
+		}
+		page.Tasks = append(page.Tasks, task)
+	}
+	if response.HasMore {
+		page.NextCursor = string(response.NextCursor)
+	}
+	page.Info.PagesScanned = len(response.Results)
+	page.Info.PagesMatched = len(page.Tasks)
+	return page, nil
+}
+
+// getTasksFilteredWithButtonWorkaround queries without filters and applies them here. Whole
+// result pages are scanned so the cursor stays exact, a page may hold more than the limit.
+func (c *Client) getTasksFilteredWithButtonWorkaround(ctx context.Context, dbID string, names taskQueryProperties, opts TaskQueryOptions, done []string) (*TaskPage, error) {
+	query := &notionapi.DatabaseQueryRequest{
+		Sorts: []notionapi.SortObject{
+			{Property: "Created time", Direction: "descending"},
+		},
+		StartCursor: notionapi.Cursor(opts.Cursor),
+		PageSize:    maxQueryPageSize,
+	}
+
+	page := &TaskPage{Info: &ListInfo{
+		Degraded: true,
+		Reason:   "database has button properties, filters were applied to the latest pages only",
+		Filters:  filtersClient,
+	}}
+	mentions := c.newMentionResolver(ctx)
+	for {
+		response, err := c.client.Database.Query(ctx, notionapi.DatabaseID(dbID), query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query database: %w", err)
+		}
+
+		for _, result := range response.Results {
+			page.Info.PagesScanned++
+			task, err := c.transformPageToTask(result, mentions)
+			if err != nil {
+				log.Printf("Warning: Could not transform page %s: %v", result.ID, err)
+				continue
+			}
+			if matchesTaskQuery(task, names, opts, done) {
+				page.Tasks = append(page.Tasks, task)
+			}
+		}
+
+		page.NextCursor = ""
+		if !response.HasMore || response.NextCursor == "" {
+			break
+		}
+		page.NextCursor = string(response.NextCursor)
+		if len(page.Tasks) >= opts.Limit {
+			break
+		}
+		query.StartCursor = response.NextCursor
+	}
+
+	page.Info.PagesMatched = len(page.Tasks)
+	return page, nil
+}
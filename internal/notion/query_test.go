@@ -0,0 +1,260 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jomei/notionapi"
+)
+
+// filterSchemaJSON is a tasks database with the properties task queries filter on
+const filterSchemaJSON = `{
+	"object": "database",
+	"id": "tasks-db",
+	"properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"status": {"id": "st", "name": "status", "type": "select", "select": {"options": [{"name": "todo"}, {"name": "done"}]}},
+		"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": [{"name": "home"}, {"name": "sometimes-later"}]}},
+		"project": {"id": "pr", "name": "project", "type": "select", "select": {"options": [{"name": "Thesis"}]}},
+		"Date": {"id": "date", "name": "Date", "type": "date", "date": {}}
+	}
+}`
+
+func newFilterFake() *fakeNotion {
+	return &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, filterSchemaJSON
+		}
+		return http.StatusOK, `{"object": "list", "results": [], "has_more": true, "next_cursor": "cursor-2"}`
+	}}
+}
+
+func TestGetTasksFilteredBuildsFilters(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name string
+		opts TaskQueryOptions
+		want string
+	}{
+		{
+			"defaults",
+			TaskQueryOptions{},
+			`{"and": [
+				{"property": "status", "select": {"does_not_equal": "done"}},
+				{"property": "Tags", "multi_select": {"does_not_contain": "sometimes-later"}}
+			]}`,
+		},
+		{
+			"done tasks with a tag",
+			TaskQueryOptions{Status: "done", Tag: "home"},
+			`{"and": [
+				{"property": "status", "select": {"equals": "done"}},
+				{"property": "Tags", "multi_select": {"contains": "home"}}
+			]}`,
+		},
+		{
+			"any status of a project with a date",
+			TaskQueryOptions{Status: StatusAny, Project: "Thesis", HasDate: &yes},
+			`{"and": [
+				{"property": "Tags", "multi_select": {"does_not_contain": "sometimes-later"}},
+				{"property": "project", "select": {"equals": "Thesis"}},
+				{"property": "Date", "date": {"is_not_empty": true}}
+			]}`,
+		},
+		{
+			"unscheduled sometimes-later tasks",
+			TaskQueryOptions{Status: StatusAny, Tag: "sometimes-later", HasDate: &no},
+			`{"and": [
+				{"property": "Tags", "multi_select": {"contains": "sometimes-later"}},
+				{"property": "Date", "date": {"is_empty": true}}
+			]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFilterFake()
+			client := newTestClient(fake)
+
+			if _, err := client.GetTasksFiltered(context.Background(), "tasks", tt.opts); err != nil {
+				t.Fatalf("GetTasksFiltered failed: %v", err)
+			}
+
+			queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+			if len(queries) != 1 {
+				t.Fatalf("Expected 1 query, got %d", len(queries))
+			}
+			var query struct {
+				Filter json.RawMessage `json:"filter"`
+			}
+			if err := json.Unmarshal(queries[0].Body, &query); err != nil {
+				t.Fatalf("Invalid query: %v", err)
+			}
+			if !jsonEqual(t, query.Filter, []byte(tt.want)) {
+				t.Errorf("Expected filter %s, got %s", tt.want, query.Filter)
+			}
+		})
+	}
+}
+
+func TestGetTasksFilteredFollowsStatusType(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		status string
+		want   string
+	}{
+		{"select", selectSchemaJSON, "", `{"property": "status", "select": {"does_not_equal": "done"}}`},
+		{"select done", selectSchemaJSON, "done", `{"property": "status", "select": {"equals": "done"}}`},
+		{"checkbox", checkboxSchemaJSON, "", `{"property": "status", "checkbox": {"does_not_equal": true}}`},
+		{"checkbox done", checkboxSchemaJSON, "done", `{"property": "status", "checkbox": {"equals": true}}`},
+		{"checkbox todo", checkboxSchemaJSON, "todo", `{"property": "status", "checkbox": {"does_not_equal": true}}`},
+		{
+			"status without a done option",
+			statusTypeSchemaJSON(`[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Shipped"}]`, `["o2"]`),
+			"",
+			`{"property": "status", "status": {"does_not_equal": "Shipped"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				if method == http.MethodGet {
+					return http.StatusOK, tt.schema
+				}
+				return http.StatusOK, `{"object": "list", "results": [], "has_more": false}`
+			}}
+			if _, err := newTestClient(fake).GetTasksFiltered(context.Background(), "tasks", TaskQueryOptions{Status: tt.status}); err != nil {
+				t.Fatalf("GetTasksFiltered failed: %v", err)
+			}
+
+			queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+			if len(queries) != 1 {
+				t.Fatalf("Expected 1 query, got %d", len(queries))
+			}
+			var query struct {
+				Filter struct {
+					And []json.RawMessage `json:"and"`
+				} `json:"filter"`
+			}
+			if err := json.Unmarshal(queries[0].Body, &query); err != nil || len(query.Filter.And) == 0 {
+				t.Fatalf("Invalid query %s: %v", queries[0].Body, err)
+			}
+			if !jsonEqual(t, query.Filter.And[0], []byte(tt.want)) {
+				t.Errorf("Expected status filter %s, got %s", tt.want, query.Filter.And[0])
+			}
+		})
+	}
+}
+
+func TestGetTasksFilteredPaginates(t *testing.T) {
+	fake := newFilterFake()
+	client := newTestClient(fake)
+
+	page, err := client.GetTasksFiltered(context.Background(), "tasks", TaskQueryOptions{Limit: 25, Cursor: "cursor-1"})
+	if err != nil {
+		t.Fatalf("GetTasksFiltered failed: %v", err)
+	}
+	if page.NextCursor != "cursor-2" {
+		t.Errorf("Expected the next cursor, got %q", page.NextCursor)
+	}
+
+	var query struct {
+		StartCursor string `json:"start_cursor"`
+		PageSize    int    `json:"page_size"`
+	}
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if err := json.Unmarshal(queries[0].Body, &query); err != nil {
+		t.Fatalf("Invalid query: %v", err)
+	}
+	if query.StartCursor != "cursor-1" || query.PageSize != 25 {
+		t.Errorf("Expected the cursor and limit to be passed on, got %+v", query)
+	}
+}
+
+func TestGetTasksFilteredRejectsUnknownOptions(t *testing.T) {
+	yes := true
+	tests := map[string]TaskQueryOptions{
+		"unknown status":  {Status: "someday"},
+		"unknown tag":     {Tag: "garden"},
+		"unknown project": {Project: "Novel"},
+		"limit too large": {Limit: 500},
+	}
+	for name, opts := range tests {
+		fake := newFilterFake()
+		client := newTestClient(fake)
+
+		if _, err := client.GetTasksFiltered(context.Background(), "tasks", opts); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", name, err)
+		}
+		if queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query"); len(queries) != 0 {
+			t.Errorf("%s: expected no query to be sent", name)
+		}
+	}
+
+	// A database without a Date property can't filter on it
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, `{"object": "database", "id": "tasks-db", "properties": {"Name": {"id": "title", "type": "title", "title": {}}}}`
+	}}
+	if _, err := newTestClient(fake).GetTasksFiltered(context.Background(), "tasks", TaskQueryOptions{HasDate: &yes}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("Expected ErrInvalidQuery for has_date without a Date property, got %v", err)
+	}
+}
+
+func TestMatchesTaskQuery(t *testing.T) {
+	names := resolveTaskQueryProperties(map[string]notionapi.PropertyConfig{})
+	task := Task{Properties: map[string]interface{}{"status": "todo", "tags": []string{"home"}, "Date": "2024-03-15T00:00:00Z"}}
+	yes, no := true, false
+
+	tests := []struct {
+		opts TaskQueryOptions
+		want bool
+	}{
+		{TaskQueryOptions{}, true},
+		{TaskQueryOptions{Status: "done"}, false},
+		{TaskQueryOptions{Tag: "home", HasDate: &yes}, true},
+		{TaskQueryOptions{HasDate: &no}, false},
+		{TaskQueryOptions{Project: "Thesis"}, false},
+	}
+	for _, tt := range tests {
+		if got := matchesTaskQuery(task, names, tt.opts, []string{doneStatus}); got != tt.want {
+			t.Errorf("%+v: expected %v, got %v", tt.opts, tt.want, got)
+		}
+	}
+}
+
+func TestMatchesTaskQueryFollowsStatusType(t *testing.T) {
+	selectNames := resolveTaskQueryProperties(map[string]notionapi.PropertyConfig{
+		"status": &notionapi.SelectPropertyConfig{Type: notionapi.PropertyConfigTypeSelect},
+	})
+	checkboxNames := resolveTaskQueryProperties(map[string]notionapi.PropertyConfig{
+		"status": &notionapi.CheckboxPropertyConfig{Type: notionapi.PropertyConfigTypeCheckbox},
+	})
+	statusNames := resolveTaskQueryProperties(map[string]notionapi.PropertyConfig{
+		"status": &notionapi.StatusPropertyConfig{},
+	})
+	tests := []struct {
+		name   string
+		names  taskQueryProperties
+		done   []string
+		status interface{}
+		opts   TaskQueryOptions
+		want   bool
+	}{
+		{"select open", selectNames, []string{doneStatus}, "todo", TaskQueryOptions{}, true},
+		{"select done", selectNames, []string{doneStatus}, "done", TaskQueryOptions{}, false},
+		{"checkbox unchecked", checkboxNames, []string{doneStatus}, false, TaskQueryOptions{}, true},
+		{"checkbox checked", checkboxNames, []string{doneStatus}, true, TaskQueryOptions{}, false},
+		{"checkbox checked asked for done", checkboxNames, []string{doneStatus}, true, TaskQueryOptions{Status: "done"}, true},
+		{"checkbox unchecked asked for done", checkboxNames, []string{doneStatus}, false, TaskQueryOptions{Status: "done"}, false},
+		{"status in the complete group", statusNames, []string{"Shipped"}, "Shipped", TaskQueryOptions{}, false},
+	}
+	for _, tt := range tests {
+		task := Task{Properties: map[string]interface{}{"status": tt.status}}
+		if got := matchesTaskQuery(task, tt.names, tt.opts, tt.done); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
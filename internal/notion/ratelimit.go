@@ -0,0 +1,155 @@
+package notion
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRPS stays under Notion's average limit of three requests per second
+	defaultRPS = 3.0
+
+	// maxRateLimitRetries is how often a request rejected with 429 is sent again
+	maxRateLimitRetries = 5
+
+	// rateLimitBackoff is the first wait after a 429 without Retry-After, doubled per retry
+	rateLimitBackoff = time.Second
+
+	// maxRateLimitBackoff caps the exponential wait
+	maxRateLimitBackoff = 30 * time.Second
+)
+
+// rpsFromEnv returns the request rate set by NOTION_RPS, or defaultRPS
+func rpsFromEnv() float64 {
+	value := os.Getenv("NOTION_RPS")
+	if value == "" {
+		return defaultRPS
+	}
+	rps, err := strconv.ParseFloat(value, 64)
+	if err != nil || rps <= 0 {
+		log.Printf("Warning: Invalid NOTION_RPS %q, using %v", value, defaultRPS)
+		return defaultRPS
+	}
+	return rps
+}
+
+// limiter spaces requests evenly at a fixed rate. It's shared by every call of a client,
+// so the bot, scheduler and HTTP handlers together stay under the limit.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time // When the next request may start
+}
+
+func newLimiter(rps float64) *limiter {
+	return &limiter{interval: time.Duration(float64(time.Second) / rps)}
+}
+
+// wait blocks until a request may start, or ctx is done
+func (l *limiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.interval)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, time.Until(start))
+}
+
+// pause holds back all requests for d, the rate limit applies to the whole integration
+func (l *limiter) pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.next) {
+		l.next = until
+	}
+}
+
+// sleepContext waits for d unless ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitedTransport paces Notion API calls and retries ones rejected with 429, waiting
+// for Retry-After or backing off exponentially when Notion doesn't say
+type rateLimitedTransport struct {
+	base    http.RoundTripper
+	limiter *limiter
+	backoff time.Duration // First wait without Retry-After
+}
+
+// newRateLimitedTransport wraps base, sending at most rps requests per second
+func newRateLimitedTransport(base http.RoundTripper, rps float64) *rateLimitedTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitedTransport{base: base, limiter: newLimiter(rps), backoff: rateLimitBackoff}
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if err := t.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests || attempt == maxRateLimitRetries {
+			return resp, err
+		}
+
+		// The body was consumed, so it can only be retried if it can be read again
+		retry := req
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return resp, nil
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			retry = req.Clone(ctx)
+			retry.Body = body
+		}
+
+		delay := t.retryDelay(resp, attempt)
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		log.Printf("Warning: Notion rate limited %s %s, retrying in %v (%d/%d)", req.Method, req.URL.Path, delay, attempt+1, maxRateLimitRetries)
+		t.limiter.pause(delay)
+		req = retry
+	}
+}
+
+// retryDelay returns how long to wait before retrying a 429 response
+func (t *rateLimitedTransport) retryDelay(resp *http.Response, attempt int) time.Duration {
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	delay := t.backoff << attempt
+	if delay > maxRateLimitBackoff || delay <= 0 {
+		delay = maxRateLimitBackoff
+	}
+	return delay
+}
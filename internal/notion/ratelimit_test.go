@@ -0,0 +1,110 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// rateLimitedFake rejects the first n queries with 429 and the given Retry-After, schema
+// fetches are answered
+type rateLimitedFake struct {
+	fakeNotion
+	rejections int
+	retryAfter string
+}
+
+func (f *rateLimitedFake) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := f.fakeNotion.RoundTrip(req)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rejections > 0 && req.Method == http.MethodPost {
+		f.rejections--
+		resp.StatusCode = http.StatusTooManyRequests
+		if f.retryAfter != "" {
+			resp.Header.Set("Retry-After", f.retryAfter)
+		}
+	}
+	return resp, err
+}
+
+func newRateLimitedTestClient(fake *rateLimitedFake) *Client {
+	transport := newRateLimitedTransport(fake, 1000)
+	transport.backoff = time.Millisecond
+	client := newTestClient(&fake.fakeNotion)
+	client.client = notionapi.NewClient("test-token", notionapi.WithHTTPClient(&http.Client{Transport: transport}), notionapi.WithRetry(1))
+	return client
+}
+
+func newQueryFake(rejections int, retryAfter string) *rateLimitedFake {
+	return &rateLimitedFake{
+		fakeNotion: fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+			if method == http.MethodGet {
+				return http.StatusOK, tasksSchemaJSON
+			}
+			return http.StatusOK, `{"object": "list", "results": [], "has_more": false}`
+		}},
+		rejections: rejections,
+		retryAfter: retryAfter,
+	}
+}
+
+func TestRetriesRateLimitedRequests(t *testing.T) {
+	for _, retryAfter := range []string{"0", ""} {
+		fake := newQueryFake(2, retryAfter)
+		client := newRateLimitedTestClient(fake)
+
+		if _, err := client.GetRecentTasks(context.Background(), "tasks", 10); err != nil {
+			t.Fatalf("Retry-After %q: expected the query to succeed after retries, got %v", retryAfter, err)
+		}
+
+		queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+		if len(queries) != 3 {
+			t.Fatalf("Retry-After %q: expected 2 rejected queries and 1 retry that succeeds, got %d", retryAfter, len(queries))
+		}
+		// Retries must resend the body, not just the URL
+		for _, q := range queries {
+			if !strings.Contains(string(q.Body), "page_size") {
+				t.Errorf("Retry-After %q: expected every attempt to carry the query, got %q", retryAfter, q.Body)
+			}
+		}
+	}
+}
+
+func TestRateLimitRetriesGiveUp(t *testing.T) {
+	fake := newQueryFake(maxRateLimitRetries+1, "0")
+	client := newRateLimitedTestClient(fake)
+
+	if _, err := client.GetRecentTasks(context.Background(), "tasks", 10); err == nil {
+		t.Fatal("Expected an error once the retries are used up")
+	}
+}
+
+func TestLimiterSpacesRequests(t *testing.T) {
+	l := newLimiter(50)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		if err := l.wait(context.Background()); err != nil {
+			t.Fatalf("wait failed: %v", err)
+		}
+	}
+	// The first request starts immediately, the other five 20ms apart
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected requests to be spaced at 50/s, 6 took %v", elapsed)
+	}
+}
+
+func TestRPSFromEnv(t *testing.T) {
+	tests := map[string]float64{"": defaultRPS, "10": 10, "0.5": 0.5, "zero": defaultRPS, "-1": defaultRPS}
+	for value, want := range tests {
+		t.Setenv("NOTION_RPS", value)
+		if got := rpsFromEnv(); got != want {
+			t.Errorf("NOTION_RPS=%q: expected %v, got %v", value, want, got)
+		}
+	}
+}
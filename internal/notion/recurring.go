@@ -0,0 +1,59 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// RepeatPropertyKey is the select property saying how often a task recurs: daily, weekly
+// or monthly
+const RepeatPropertyKey = "Repeat"
+
+// GetDoneRepeatingTasks returns up to limit done tasks with a Repeat value, last edited
+// since the given time. Databases without a Repeat select have none.
+func (c *Client) GetDoneRepeatingTasks(ctx context.Context, since time.Time, limit int) ([]Task, error) {
+	dbID := c.getDbIDForType("tasks")
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for tasks not configured")
+	}
+
+	dbProps, err := c.GetDatabaseProperties(ctx, "tasks")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch database properties: %w", err)
+	}
+	repeat := ""
+	for key, prop := range dbProps {
+		if _, ok := prop.(*notionapi.SelectPropertyConfig); ok && strings.EqualFold(key, RepeatPropertyKey) {
+			repeat = key
+		}
+	}
+	if repeat == "" {
+		return nil, nil
+	}
+
+	after := notionapi.Date(since)
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.AndCompoundFilter{
+			c.doneFilter(ctx, "tasks"),
+			notionapi.PropertyFilter{
+				Property: repeat,
+				Select:   &notionapi.SelectFilterCondition{IsNotEmpty: true},
+			},
+			notionapi.TimestampFilter{
+				Timestamp:      notionapi.TimestampLastEdited,
+				LastEditedTime: &notionapi.DateFilterCondition{OnOrAfter: &after},
+			},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	tasks, err := c.collectTasks(ctx, dbID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query repeating tasks: %w", err)
+	}
+	return tasks, nil
+}
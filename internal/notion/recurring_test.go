@@ -0,0 +1,106 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetDoneRepeatingTasks(t *testing.T) {
+	schema := `{"object": "database", "id": "tasks-db", "properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"repeat": {"id": "rep", "name": "repeat", "type": "select", "select": {"options": [{"name": "weekly"}]}}
+	}}`
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, schema
+		}
+		return http.StatusOK, queryPageJSON(2, false, "")
+	}}
+
+	since := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	tasks, err := newTestClient(fake).GetDoneRepeatingTasks(context.Background(), since, 50)
+	if err != nil {
+		t.Fatalf("GetDoneRepeatingTasks failed: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks, got %d", len(tasks))
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	want := `{"filter": {"and": [
+		{"property": "status", "select": {"equals": "done"}},
+		{"property": "repeat", "select": {"is_not_empty": true}},
+		{"timestamp": "last_edited_time", "last_edited_time": {"on_or_after": "2024-03-10T18:00:00Z"}}
+	]}, "page_size": 50}`
+	if len(queries) != 1 || !jsonEqual(t, queries[0].Body, []byte(want)) {
+		t.Errorf("Expected query %s, got %+v", want, queries)
+	}
+}
+
+func TestGetDoneRepeatingTasksWithoutRepeat(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, strings.Replace(tasksSchemaJSON, `"Estimate"`, `"Repeat"`, 1)
+		}
+		return http.StatusOK, queryPageJSON(2, false, "")
+	}}
+
+	// A Repeat that isn't a select doesn't count either
+	tasks, err := newTestClient(fake).GetDoneRepeatingTasks(context.Background(), time.Now(), 50)
+	if err != nil || tasks != nil {
+		t.Errorf("Expected no tasks and no error, got %v, %v", tasks, err)
+	}
+	if queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query"); len(queries) != 0 {
+		t.Errorf("Expected no query, got %d", len(queries))
+	}
+}
+
+func TestGetDoneRepeatingTasksFollowsStatusType(t *testing.T) {
+	repeat := `"repeat": {"id": "rep", "name": "repeat", "type": "select", "select": {"options": [{"name": "weekly"}]}},
+		"Name":`
+	tests := []struct {
+		name    string
+		options string
+		want    string
+	}{
+		{
+			"done option",
+			`[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Done"}]`,
+			`{"property": "status", "status": {"equals": "Done"}}`,
+		},
+		{
+			"complete group",
+			`[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Shipped"}, {"id": "o3", "name": "Dropped"}]`,
+			`{"or": [{"property": "status", "status": {"equals": "Shipped"}}, {"property": "status", "status": {"equals": "Dropped"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := strings.Replace(statusTypeSchemaJSON(tt.options, `["o2", "o3"]`), `"Name":`, repeat, 1)
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				if method == http.MethodGet {
+					return http.StatusOK, schema
+				}
+				return http.StatusOK, queryPageJSON(1, false, "")
+			}}
+
+			since := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+			if _, err := newTestClient(fake).GetDoneRepeatingTasks(context.Background(), since, 50); err != nil {
+				t.Fatalf("GetDoneRepeatingTasks failed: %v", err)
+			}
+
+			queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+			want := `{"filter": {"and": [
+				` + tt.want + `,
+				{"property": "repeat", "select": {"is_not_empty": true}},
+				{"timestamp": "last_edited_time", "last_edited_time": {"on_or_after": "2024-03-10T18:00:00Z"}}
+			]}, "page_size": 50}`
+			if len(queries) != 1 || !jsonEqual(t, queries[0].Body, []byte(want)) {
+				t.Errorf("Expected query %s, got %+v", want, queries)
+			}
+		})
+	}
+}
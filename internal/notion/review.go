@@ -0,0 +1,128 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// collectTasks runs a query over a database, returning up to limit tasks
+func (c *Client) collectTasks(ctx context.Context, dbID string, query *notionapi.DatabaseQueryRequest, limit int) ([]Task, error) {
+	var tasks []Task
+	mentions := c.newMentionResolver(ctx)
+	err := c.queryPages(ctx, dbID, query, func(page notionapi.Page) bool {
+		task, err := c.transformPageToTask(page, mentions)
+		if err != nil {
+			log.Printf("Warning: Could not transform page %s: %v", page.ID, err)
+			return true
+		}
+		tasks = append(tasks, task)
+		return len(tasks) < limit
+	})
+	return tasks, err
+}
+
+// GetTasksCompletedBetween returns up to limit done tasks last edited in [from, to), the
+// latest first. Notion doesn't record when a task was completed, marking it done is
+// usually its last edit.
+func (c *Client) GetTasksCompletedBetween(ctx context.Context, dbType string, from, to time.Time, limit int) ([]Task, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	after, before := notionapi.Date(from), notionapi.Date(to)
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.AndCompoundFilter{
+			c.doneFilter(ctx, dbType),
+			notionapi.TimestampFilter{
+				Timestamp:      notionapi.TimestampLastEdited,
+				LastEditedTime: &notionapi.DateFilterCondition{OnOrAfter: &after},
+			},
+			notionapi.TimestampFilter{
+				Timestamp:      notionapi.TimestampLastEdited,
+				LastEditedTime: &notionapi.DateFilterCondition{Before: &before},
+			},
+		},
+		Sorts: []notionapi.SortObject{
+			{Timestamp: notionapi.TimestampLastEdited, Direction: notionapi.SortOrderDESC},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	tasks, err := c.collectTasks(ctx, dbID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query completed tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// GetTasksCreatedBetween returns up to limit tasks created in [from, to), the oldest first
+func (c *Client) GetTasksCreatedBetween(ctx context.Context, dbType string, from, to time.Time, limit int) ([]Task, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	after, before := notionapi.Date(from), notionapi.Date(to)
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.AndCompoundFilter{
+			notionapi.TimestampFilter{
+				Timestamp:   notionapi.TimestampCreated,
+				CreatedTime: &notionapi.DateFilterCondition{OnOrAfter: &after},
+			},
+			notionapi.TimestampFilter{
+				Timestamp:   notionapi.TimestampCreated,
+				CreatedTime: &notionapi.DateFilterCondition{Before: &before},
+			},
+		},
+		Sorts: []notionapi.SortObject{
+			{Timestamp: notionapi.TimestampCreated, Direction: notionapi.SortOrderASC},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	tasks, err := c.collectTasks(ctx, dbID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query created tasks: %w", err)
+	}
+	return tasks, nil
+}
+
+// GetStaleTasks returns up to limit tasks created before the given time that still aren't
+// done, the oldest first. Tasks tagged sometimes-later were put off on purpose and are
+// left out.
+func (c *Client) GetStaleTasks(ctx context.Context, dbType string, before time.Time, limit int) ([]Task, error) {
+	dbID := c.getDbIDForType(dbType)
+	if dbID == "" {
+		return nil, fmt.Errorf("database ID for %s not configured", dbType)
+	}
+
+	createdBefore := notionapi.Date(before)
+	filter := append(c.notDoneFilters(ctx, dbType),
+		notionapi.PropertyFilter{
+			Property:    "tags",
+			MultiSelect: &notionapi.MultiSelectFilterCondition{DoesNotContain: SometimesLaterTag},
+		},
+		notionapi.TimestampFilter{
+			Timestamp:   notionapi.TimestampCreated,
+			CreatedTime: &notionapi.DateFilterCondition{Before: &createdBefore},
+		},
+	)
+	query := &notionapi.DatabaseQueryRequest{
+		Filter: notionapi.AndCompoundFilter(filter),
+		Sorts: []notionapi.SortObject{
+			{Timestamp: notionapi.TimestampCreated, Direction: notionapi.SortOrderASC},
+		},
+		PageSize: pageSizeFor(limit),
+	}
+
+	tasks, err := c.collectTasks(ctx, dbID, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stale tasks: %w", err)
+	}
+	return tasks, nil
+}
@@ -0,0 +1,101 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestReviewQueriesFilterByTimestamps(t *testing.T) {
+	from := time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 17, 18, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query func(c *Client) error
+		want  string
+	}{
+		{
+			"completed",
+			func(c *Client) error {
+				_, err := c.GetTasksCompletedBetween(context.Background(), "tasks", from, to, 50)
+				return err
+			},
+			`{"filter": {"and": [
+				{"property": "status", "select": {"equals": "done"}},
+				{"timestamp": "last_edited_time", "last_edited_time": {"on_or_after": "2024-03-10T18:00:00Z"}},
+				{"timestamp": "last_edited_time", "last_edited_time": {"before": "2024-03-17T18:00:00Z"}}
+			]}, "sorts": [{"timestamp": "last_edited_time", "direction": "descending"}], "page_size": 50}`,
+		},
+		{
+			"created",
+			func(c *Client) error {
+				_, err := c.GetTasksCreatedBetween(context.Background(), "tasks", from, to, 50)
+				return err
+			},
+			`{"filter": {"and": [
+				{"timestamp": "created_time", "created_time": {"on_or_after": "2024-03-10T18:00:00Z"}},
+				{"timestamp": "created_time", "created_time": {"before": "2024-03-17T18:00:00Z"}}
+			]}, "sorts": [{"timestamp": "created_time", "direction": "ascending"}], "page_size": 50}`,
+		},
+		{
+			"stale",
+			func(c *Client) error {
+				_, err := c.GetStaleTasks(context.Background(), "tasks", from, 50)
+				return err
+			},
+			`{"filter": {"and": [
+				{"property": "status", "select": {"does_not_equal": "done"}},
+				{"property": "tags", "multi_select": {"does_not_contain": "sometimes-later"}},
+				{"timestamp": "created_time", "created_time": {"before": "2024-03-10T18:00:00Z"}}
+			]}, "sorts": [{"timestamp": "created_time", "direction": "ascending"}], "page_size": 50}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				return http.StatusOK, queryPageJSON(2, false, "")
+			}}
+			if err := tt.query(newTestClient(fake)); err != nil {
+				t.Fatalf("Query failed: %v", err)
+			}
+
+			queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+			if len(queries) != 1 {
+				t.Fatalf("Expected 1 query, got %d", len(queries))
+			}
+			if !jsonEqual(t, queries[0].Body, []byte(tt.want)) {
+				t.Errorf("Expected query %s, got %s", tt.want, queries[0].Body)
+			}
+		})
+	}
+}
+
+func TestGetTasksCreatedBetweenStopsAtLimit(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, queryPageJSON(3, true, "next")
+	}}
+	client := newTestClient(fake)
+
+	to := time.Now()
+	tasks, err := client.GetTasksCreatedBetween(context.Background(), "tasks", to.AddDate(0, 0, -7), to, 2)
+	if err != nil {
+		t.Fatalf("GetTasksCreatedBetween failed: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Errorf("Expected 2 tasks, got %d", len(tasks))
+	}
+	if queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query"); len(queries) != 1 {
+		t.Errorf("Expected the limit to stop paging, got %d queries", len(queries))
+	}
+
+	var query struct {
+		PageSize int `json:"page_size"`
+	}
+	json.Unmarshal(fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")[0].Body, &query)
+	if query.PageSize != 2 {
+		t.Errorf("Expected a page size of 2, got %d", query.PageSize)
+	}
+}
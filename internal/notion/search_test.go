@@ -0,0 +1,93 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestSearchTasksFiltersOnDetectedTitle(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, `{"object": "database", "id": "tasks-db", "properties": {"Задача": {"id": "title", "type": "title", "title": {}}}}`
+		}
+		return http.StatusOK, `{"object": "list", "results": [], "has_more": false}`
+	}}
+	client := newTestClient(fake)
+
+	if _, err := client.SearchTasks(context.Background(), "tasks", " milk ", 5); err != nil {
+		t.Fatalf("SearchTasks failed: %v", err)
+	}
+
+	queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+	if len(queries) != 1 {
+		t.Fatalf("Expected 1 query, got %d", len(queries))
+	}
+	var query struct {
+		Filter   json.RawMessage `json:"filter"`
+		PageSize int             `json:"page_size"`
+	}
+	if err := json.Unmarshal(queries[0].Body, &query); err != nil {
+		t.Fatalf("Invalid query: %v", err)
+	}
+	want := `{"property": "Задача", "rich_text": {"contains": "milk"}}`
+	if !jsonEqual(t, query.Filter, []byte(want)) {
+		t.Errorf("Expected filter %s, got %s", want, query.Filter)
+	}
+	if query.PageSize != 5 {
+		t.Errorf("Expected page size 5, got %d", query.PageSize)
+	}
+}
+
+func TestSearchTasksRejectsShortQueries(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, tasksSchemaJSON
+	}}
+	client := newTestClient(fake)
+
+	for _, query := range []string{"", "a", " ж "} {
+		if _, err := client.SearchTasks(context.Background(), "tasks", query, 5); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("%q: expected ErrInvalidQuery, got %v", query, err)
+		}
+	}
+	if len(fake.requests) != 0 {
+		t.Errorf("Expected no requests for short queries, got %d", len(fake.requests))
+	}
+}
+
+func TestSearchTasksFallsBackToSearchAPI(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		switch {
+		case method == http.MethodGet:
+			return http.StatusServiceUnavailable, `{"object": "error", "status": 503, "code": "service_unavailable", "message": "unavailable"}`
+		case path == "/v1/search":
+			return http.StatusOK, `{"object": "list", "has_more": false, "results": [
+				{"object": "page", "id": "page-1", "parent": {"type": "database_id", "database_id": "tasks-db"},
+				 "properties": {"Task": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Buy milk"}, "plain_text": "Buy milk"}]}}},
+				{"object": "page", "id": "page-2", "parent": {"type": "database_id", "database_id": "notes-db"},
+				 "properties": {"Task": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Milk prices"}, "plain_text": "Milk prices"}]}}}
+			]}`
+		}
+		return http.StatusBadRequest, missingNameJSON
+	}}
+	client := newTestClient(fake)
+
+	tasks, err := client.SearchTasks(context.Background(), "tasks", "milk", 5)
+	if err != nil {
+		t.Fatalf("SearchTasks failed: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Title != "Buy milk" {
+		t.Errorf("Expected only the match from the tasks database, got %+v", tasks)
+	}
+
+	searches := fake.requestsTo(http.MethodPost, "/v1/search")
+	if len(searches) != 1 {
+		t.Fatalf("Expected 1 search request, got %d", len(searches))
+	}
+	want := `{"query": "milk", "filter": {"property": "object", "value": "page"}, "page_size": 100}`
+	if !jsonEqual(t, searches[0].Body, []byte(want)) {
+		t.Errorf("Expected search %s, got %s", want, searches[0].Body)
+	}
+}
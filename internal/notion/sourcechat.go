@@ -0,0 +1,70 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jomei/notionapi"
+)
+
+// SourceChatValue is the value stamped into the source chat property of a task, the chat
+// title followed by its ID so the task can be matched even after the chat is renamed
+func SourceChatValue(title string, chatID int64) string {
+	if title == "" {
+		title = "Private chat"
+	}
+	return fmt.Sprintf("%s [%d]", title, chatID)
+}
+
+// ChatScope limits a listing to tasks created from one chat
+type ChatScope struct {
+	Property string // Name of the source chat property
+	ChatID   int64
+	Value    string // Current SourceChatValue of the chat
+}
+
+// ChatScopeFilter builds the query filter for a scope, matching the chat ID so renamed
+// chats keep their tasks. Select filters can't match part of a value, so every option
+// stamped with the chat ID is matched instead.
+func (c *Client) ChatScopeFilter(ctx context.Context, dbType string, scope ChatScope) (notionapi.Filter, error) {
+	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		return nil, err
+	}
+
+	prop, ok := dbProps[scope.Property]
+	if !ok {
+		return nil, fmt.Errorf("source chat property %q does not exist in the %s database", scope.Property, dbType)
+	}
+
+	suffix := fmt.Sprintf("[%d]", scope.ChatID)
+	switch prop.GetType() {
+	case notionapi.PropertyConfigTypeSelect:
+		values := []string{scope.Value}
+		if config, ok := prop.(*notionapi.SelectPropertyConfig); ok {
+			for _, option := range config.Select.Options {
+				if strings.HasSuffix(option.Name, suffix) && option.Name != scope.Value {
+					values = append(values, option.Name)
+				}
+			}
+		}
+		var filters notionapi.OrCompoundFilter
+		for _, value := range values {
+			filters = append(filters, notionapi.PropertyFilter{
+				Property: scope.Property,
+				Select:   &notionapi.SelectFilterCondition{Equals: value},
+			})
+		}
+		if len(filters) == 1 {
+			return filters[0], nil
+		}
+		return filters, nil
+	case notionapi.PropertyConfigTypeRichText:
+		return notionapi.PropertyFilter{
+			Property: scope.Property,
+			RichText: &notionapi.TextFilterCondition{Contains: suffix},
+		}, nil
+	}
+	return nil, fmt.Errorf("source chat property %q must be a select or text property, not %s", scope.Property, prop.GetType())
+}
@@ -0,0 +1,75 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// sourceChatSchemaJSON is a database with source chat properties of both supported types
+const sourceChatSchemaJSON = `{
+	"object": "database",
+	"id": "tasks-db",
+	"properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"Chat": {"id": "chat", "name": "Chat", "type": "select", "select": {"options": []}},
+		"Chat text": {"id": "chatt", "name": "Chat text", "type": "rich_text", "rich_text": {}},
+		"Date": {"id": "date", "name": "Date", "type": "date", "date": {}}
+	}
+}`
+
+func TestChatScopeFilter(t *testing.T) {
+	client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, sourceChatSchemaJSON
+	}})
+	value := SourceChatValue("Family", -100123)
+
+	tests := []struct {
+		property string
+		expected string
+	}{
+		{"Chat", `{"property": "Chat", "select": {"equals": "Family [-100123]"}}`},
+		{"Chat text", `{"property": "Chat text", "rich_text": {"contains": "[-100123]"}}`},
+	}
+	for _, tt := range tests {
+		filter, err := client.ChatScopeFilter(context.Background(), "tasks", ChatScope{Property: tt.property, ChatID: -100123, Value: value})
+		if err != nil {
+			t.Fatalf("ChatScopeFilter(%s) failed: %v", tt.property, err)
+		}
+		encoded, _ := json.Marshal(filter)
+		if !jsonEqual(t, encoded, []byte(tt.expected)) {
+			t.Errorf("Expected %s, got %s", tt.expected, encoded)
+		}
+	}
+
+	for _, property := range []string{"Date", "Missing"} {
+		if _, err := client.ChatScopeFilter(context.Background(), "tasks", ChatScope{Property: property, ChatID: 1, Value: "x"}); err == nil {
+			t.Errorf("Expected %s to be rejected as a source chat property", property)
+		}
+	}
+}
+
+func TestChatScopeFilterMatchesRenamedChats(t *testing.T) {
+	schema := strings.Replace(sourceChatSchemaJSON, `"select": {"options": []}`,
+		`"select": {"options": [{"name": "Family [-100123]"}, {"name": "Work [-1001234]"}, {"name": "Smiths [-100123]"}]}`, 1)
+	client := newTestClient(&fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, schema
+	}})
+
+	// Tasks stamped before and after the chat was renamed, not those of a longer ID
+	filter, err := client.ChatScopeFilter(context.Background(), "tasks", ChatScope{Property: "Chat", ChatID: -100123, Value: SourceChatValue("The Smiths", -100123)})
+	if err != nil {
+		t.Fatalf("ChatScopeFilter failed: %v", err)
+	}
+	expected := `{"or": [
+		{"property": "Chat", "select": {"equals": "The Smiths [-100123]"}},
+		{"property": "Chat", "select": {"equals": "Family [-100123]"}},
+		{"property": "Chat", "select": {"equals": "Smiths [-100123]"}}
+	]}`
+	encoded, _ := json.Marshal(filter)
+	if !jsonEqual(t, encoded, []byte(expected)) {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+}
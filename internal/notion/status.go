@@ -0,0 +1,213 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+const (
+	// doneStatus is the status the bot sets on finished tasks and filters out of listings
+	doneStatus = "done"
+	// completeStatusGroup is the group of a status property holding its finished options
+	completeStatusGroup = "Complete"
+	// notionAPIURL is used for requests made without the library
+	notionAPIURL = "https://api.notion.com/v1/"
+)
+
+// statusOptions are the options and groups of a status property. The library decodes
+// status properties without them, so they're read from the raw database response.
+type statusOptions struct {
+	Options []notionapi.Option `json:"options"`
+	Groups  []struct {
+		Name      string   `json:"name"`
+		OptionIDs []string `json:"option_ids"`
+	} `json:"groups"`
+}
+
+// statusOptionsEntry caches the status properties of a database
+type statusOptionsEntry struct {
+	properties map[string]statusOptions
+	expires    time.Time
+}
+
+// getStatusOptions returns the options of a status property, cached like the schema
+func (c *Client) getStatusOptions(ctx context.Context, dbID, property string) (statusOptions, error) {
+	c.cacheMu.RLock()
+	entry, ok := c.statusCache[dbID]
+	c.cacheMu.RUnlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.properties[property], nil
+	}
+
+	if c.rawHTTP == nil {
+		return statusOptions{}, fmt.Errorf("status options of %s can't be read without the client's own HTTP client", property)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, notionAPIURL+"databases/"+dbID, nil)
+	if err != nil {
+		return statusOptions{}, fmt.Errorf("failed to create database request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	req.Header.Set("Notion-Version", notionAPIVersion)
+	resp, err := c.rawHTTP.Do(req)
+	if err != nil {
+		return statusOptions{}, fmt.Errorf("failed to get database: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusOptions{}, fmt.Errorf("failed to get database: %w", parseError(resp.StatusCode, resp.Body))
+	}
+
+	var db struct {
+		Properties map[string]struct {
+			Type   string        `json:"type"`
+			Status statusOptions `json:"status"`
+		} `json:"properties"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&db); err != nil {
+		return statusOptions{}, fmt.Errorf("failed to decode database: %w", err)
+	}
+	entry = statusOptionsEntry{properties: make(map[string]statusOptions), expires: time.Now().Add(schemaCacheTTL)}
+	for key, prop := range db.Properties {
+		if prop.Type == "status" {
+			entry.properties[key] = prop.Status
+		}
+	}
+
+	c.cacheMu.Lock()
+	if c.statusCache == nil {
+		c.statusCache = make(map[string]statusOptionsEntry)
+	}
+	c.statusCache[dbID] = entry
+	c.cacheMu.Unlock()
+	return entry.properties[property], nil
+}
+
+// doneStatuses returns the options of a status property that mean done: the one named
+// "done" if there is one, otherwise the options of the Complete group. It returns nil when
+// the options can't be read or none fits, callers then use "done" as it is.
+func (c *Client) doneStatuses(ctx context.Context, dbID, property string) []string {
+	options, err := c.getStatusOptions(ctx, dbID, property)
+	if err != nil {
+		log.Printf("Warning: Could not read the options of %s, assuming %q: %v", property, doneStatus, err)
+		return nil
+	}
+
+	for _, option := range options.Options {
+		if strings.EqualFold(option.Name, doneStatus) {
+			return []string{option.Name}
+		}
+	}
+
+	var names []string
+	for _, group := range options.Groups {
+		if !strings.EqualFold(group.Name, completeStatusGroup) {
+			continue
+		}
+		for _, id := range group.OptionIDs {
+			for _, option := range options.Options {
+				if string(option.ID) == id {
+					names = append(names, option.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// statusDoneNames returns the values of the status property in names that mean done:
+// doneStatuses for a status property, "done" for a select or when none fits
+func (c *Client) statusDoneNames(ctx context.Context, dbType string, names taskQueryProperties) []string {
+	if names.statusIsStatus {
+		if done := c.doneStatuses(ctx, c.getDbIDForType(dbType), names.status); len(done) > 0 {
+			return done
+		}
+	}
+	return []string{doneStatus}
+}
+
+// notDoneFilters returns the filters leaving out done tasks, as select, status or checkbox
+// conditions depending on the status property's type in the schema. A status property
+// without a "done" option leaves out every option of its Complete group.
+func (c *Client) notDoneFilters(ctx context.Context, dbType string) []notionapi.Filter {
+	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		log.Printf("Warning: Could not fetch database properties, filtering status as a select: %v", err)
+	}
+	names := resolveTaskQueryProperties(dbProps)
+	return notDoneConditions(names, c.statusDoneNames(ctx, dbType, names))
+}
+
+// notDoneConditions returns a condition on the status property in names for each of the
+// done values, or one on the checkbox
+func notDoneConditions(names taskQueryProperties, done []string) []notionapi.Filter {
+	if names.statusIsCheckbox {
+		return []notionapi.Filter{notionapi.PropertyFilter{
+			Property: names.status,
+			Checkbox: &notionapi.CheckboxFilterCondition{DoesNotEqual: true},
+		}}
+	}
+
+	var filters []notionapi.Filter
+	for _, name := range done {
+		filter := notionapi.PropertyFilter{Property: names.status}
+		if names.statusIsStatus {
+			filter.Status = &notionapi.StatusFilterCondition{DoesNotEqual: name}
+		} else {
+			filter.Select = &notionapi.SelectFilterCondition{DoesNotEqual: name}
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// notDoneFilter returns the filters of notDoneFilters as one, for queries filtering on
+// nothing else
+func (c *Client) notDoneFilter(ctx context.Context, dbType string) notionapi.Filter {
+	filters := c.notDoneFilters(ctx, dbType)
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return notionapi.AndCompoundFilter(filters)
+}
+
+// doneFilter returns the filter keeping only done tasks, the counterpart of notDoneFilters.
+// A status property without a "done" option matches any option of its Complete group.
+func (c *Client) doneFilter(ctx context.Context, dbType string) notionapi.Filter {
+	dbProps, err := c.GetDatabaseProperties(ctx, dbType)
+	if err != nil {
+		log.Printf("Warning: Could not fetch database properties, filtering status as a select: %v", err)
+	}
+	names := resolveTaskQueryProperties(dbProps)
+	if names.statusIsCheckbox {
+		return notionapi.PropertyFilter{
+			Property: names.status,
+			Checkbox: &notionapi.CheckboxFilterCondition{Equals: true},
+		}
+	}
+	if !names.statusIsStatus {
+		return notionapi.PropertyFilter{
+			Property: names.status,
+			Select:   &notionapi.SelectFilterCondition{Equals: doneStatus},
+		}
+	}
+
+	done := c.statusDoneNames(ctx, dbType, names)
+	var filters notionapi.OrCompoundFilter
+	for _, name := range done {
+		filters = append(filters, notionapi.PropertyFilter{
+			Property: names.status,
+			Status:   &notionapi.StatusFilterCondition{Equals: name},
+		})
+	}
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return filters
+}
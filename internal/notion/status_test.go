@@ -0,0 +1,201 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statusTypeSchemaJSON returns a tasks database whose status is Notion's status type
+func statusTypeSchemaJSON(options, completeIDs string) string {
+	return `{"object": "database", "id": "tasks-db", "properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"status": {"id": "st", "name": "status", "type": "status", "status": {
+			"options": ` + options + `,
+			"groups": [
+				{"id": "g1", "name": "To-do", "option_ids": ["o1"]},
+				{"id": "g2", "name": "Complete", "option_ids": ` + completeIDs + `}
+			]
+		}}
+	}}`
+}
+
+// selectSchemaJSON is a tasks database whose status is a select
+const selectSchemaJSON = `{"object": "database", "id": "tasks-db", "properties": {
+	"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+	"status": {"id": "st", "name": "status", "type": "select", "select": {"options": [{"name": "todo"}, {"name": "done"}]}}
+}}`
+
+// checkboxSchemaJSON is a tasks database whose status is a checkbox
+const checkboxSchemaJSON = `{"object": "database", "id": "tasks-db", "properties": {
+	"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+	"status": {"id": "st", "name": "status", "type": "checkbox", "checkbox": {}}
+}}`
+
+func TestNotDoneFilterFollowsStatusType(t *testing.T) {
+	sometimesLater := `{"property": "tags", "multi_select": {"does_not_contain": "sometimes-later"}}`
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{
+			"select",
+			selectSchemaJSON,
+			`[{"property": "status", "select": {"does_not_equal": "done"}}, ` + sometimesLater + `]`,
+		},
+		{
+			"checkbox",
+			checkboxSchemaJSON,
+			`[{"property": "status", "checkbox": {"does_not_equal": true}}, ` + sometimesLater + `]`,
+		},
+		{
+			"status with a done option",
+			statusTypeSchemaJSON(`[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Done"}]`, `["o2"]`),
+			`[{"property": "status", "status": {"does_not_equal": "Done"}}, ` + sometimesLater + `]`,
+		},
+		{
+			"status without one",
+			statusTypeSchemaJSON(`[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Shipped"}, {"id": "o3", "name": "Dropped"}]`, `["o2", "o3"]`),
+			`[{"property": "status", "status": {"does_not_equal": "Shipped"}}, {"property": "status", "status": {"does_not_equal": "Dropped"}}, ` + sometimesLater + `]`,
+		},
+	}
+	queries := map[string]func(c *Client) error{
+		"GetRecentTasks": func(c *Client) error {
+			_, err := c.GetRecentTasks(context.Background(), "tasks", 10)
+			return err
+		},
+		"GetUndoneTasksExcludingSometimesLater": func(c *Client) error {
+			_, err := c.GetUndoneTasksExcludingSometimesLater(context.Background(), "tasks", 10)
+			return err
+		},
+	}
+	for _, tt := range tests {
+		for name, query := range queries {
+			t.Run(tt.name+"/"+name, func(t *testing.T) {
+				fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+					if method == http.MethodGet {
+						return http.StatusOK, tt.schema
+					}
+					return http.StatusOK, queryPageJSON(1, false, "")
+				}}
+				if err := query(newTestClient(fake)); err != nil {
+					t.Fatalf("%s failed: %v", name, err)
+				}
+
+				queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+				if len(queries) != 1 {
+					t.Fatalf("Expected 1 query, got %d", len(queries))
+				}
+				var body struct {
+					Filter struct {
+						And json.RawMessage `json:"and"`
+					} `json:"filter"`
+				}
+				json.Unmarshal(queries[0].Body, &body)
+				if !jsonEqual(t, body.Filter.And, []byte(tt.want)) {
+					t.Errorf("Expected filter %s, got %s", tt.want, body.Filter.And)
+				}
+			})
+		}
+	}
+}
+
+func TestUpdateTaskStatusMapsDoneToCompleteGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		options string
+		want    string
+	}{
+		{"done option", `[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Done"}]`, `{"status": {"name": "Done"}}`},
+		{"complete group", `[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Shipped"}]`, `{"status": {"name": "Shipped"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				if method == http.MethodGet {
+					return http.StatusOK, statusTypeSchemaJSON(tt.options, `["o2"]`)
+				}
+				return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+			}}
+			client := newTestClient(fake)
+
+			if err := client.UpdateTaskStatus(context.Background(), "page-1", "done", nil); err != nil {
+				t.Fatalf("UpdateTaskStatus failed: %v", err)
+			}
+			updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")
+			if len(updates) != 1 {
+				t.Fatalf("Expected 1 update request, got %d", len(updates))
+			}
+			var body struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+			json.Unmarshal(updates[0].Body, &body)
+			if !jsonEqual(t, body.Properties["status"], []byte(tt.want)) {
+				t.Errorf("Expected status %s, got %s", tt.want, body.Properties["status"])
+			}
+		})
+	}
+}
+
+func TestTaskQueriesFilterStatusByType(t *testing.T) {
+	notDone := []string{`"status":{"does_not_equal":"Shipped"}`, `"status":{"does_not_equal":"Dropped"}`}
+	done := []string{`{"or":[{"property":"status","status":{"equals":"Shipped"}},{"property":"status","status":{"equals":"Dropped"}}]}`}
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	queries := map[string]struct {
+		query func(c *Client) error
+		want  []string
+	}{
+		"CountUndoneTasks": {func(c *Client) error {
+			_, err := c.CountUndoneTasks(context.Background())
+			return err
+		}, notDone},
+		"GetCounts": {func(c *Client) error {
+			_, err := c.GetCounts(context.Background(), now)
+			return err
+		}, notDone},
+		"GetOverdueTasks": {func(c *Client) error {
+			_, err := c.GetOverdueTasks(context.Background(), "tasks", now, 10)
+			return err
+		}, notDone},
+		"GetStaleTasks": {func(c *Client) error {
+			_, err := c.GetStaleTasks(context.Background(), "tasks", now, 10)
+			return err
+		}, notDone},
+		"GetTasksCompletedBetween": {func(c *Client) error {
+			_, err := c.GetTasksCompletedBetween(context.Background(), "tasks", now.AddDate(0, 0, -7), now, 10)
+			return err
+		}, done},
+	}
+	schema := statusTypeSchemaJSON(`[{"id": "o1", "name": "Not started"}, {"id": "o2", "name": "Shipped"}, {"id": "o3", "name": "Dropped"}]`, `["o2", "o3"]`)
+	for name, tt := range queries {
+		t.Run(name, func(t *testing.T) {
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				if method == http.MethodGet {
+					return http.StatusOK, schema
+				}
+				return http.StatusOK, queryPageJSON(1, false, "")
+			}}
+			if err := tt.query(newTestClient(fake)); err != nil {
+				t.Fatalf("%s failed: %v", name, err)
+			}
+
+			queries := fake.requestsTo(http.MethodPost, "/v1/databases/tasks-db/query")
+			if len(queries) != 1 {
+				t.Fatalf("Expected 1 query, got %d", len(queries))
+			}
+			body := string(queries[0].Body)
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("Expected %s in the query, got %s", want, body)
+				}
+			}
+			if strings.Contains(body, `"select"`) {
+				t.Errorf("Expected no select condition on a status property, got %s", body)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// pageIDLength is the number of hex digits in a Notion ID without hyphens
+const pageIDLength = 32
+
+// ParsePageID returns the hyphenated page ID of a Notion URL or a bare ID, written with or
+// without hyphens. Page URLs end in the ID, usually after a slug of the title; links to
+// pages opened from a database view carry it in the p parameter.
+func ParsePageID(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	link := s
+	if strings.Contains(link, "/") && !strings.Contains(link, "://") {
+		link = "https://" + link // Pasted without the scheme, like notion.so/...
+	}
+	if u, err := url.Parse(link); err == nil && u.Host != "" {
+		if p := u.Query().Get("p"); p != "" {
+			s = p
+		} else {
+			s = strings.TrimRight(u.Path, "/")
+			s = s[strings.LastIndex(s, "/")+1:]
+		}
+	}
+
+	digits := strings.ReplaceAll(s, "-", "")
+	if len(digits) < pageIDLength {
+		return "", fmt.Errorf("no Notion page ID in %q", s)
+	}
+	digits = strings.ToLower(digits[len(digits)-pageIDLength:])
+	for _, r := range digits {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return "", fmt.Errorf("no Notion page ID in %q", s)
+		}
+	}
+	return digits[:8] + "-" + digits[8:12] + "-" + digits[12:16] + "-" + digits[16:20] + "-" + digits[20:], nil
+}
+
+// pageTags returns the key and options of the tags multi_select of a page, matching the
+// property name without case
+func pageTags(properties notionapi.Properties) (string, []notionapi.Option, bool) {
+	for key, prop := range properties {
+		if !strings.EqualFold(key, "tags") {
+			continue
+		}
+		if multiSelect, ok := prop.(*notionapi.MultiSelectProperty); ok {
+			return key, multiSelect.MultiSelect, true
+		}
+	}
+	return "", nil, false
+}
+
+// AddTagToTask adds an option to the tags of a task. Notion replaces a multi_select as a
+// whole, so the page is read first and the tags it already has are sent along.
+func (c *Client) AddTagToTask(ctx context.Context, taskID, tag string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	page, err := c.GetPage(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	key, current, ok := pageTags(page.Properties)
+	if !ok {
+		return fmt.Errorf("task %s has no tags property", taskID)
+	}
+
+	options := make([]notionapi.Option, 0, len(current)+1)
+	for _, option := range current {
+		if option.Name == tag {
+			log.Printf("Task %s is already tagged %s", taskID, tag)
+			return nil
+		}
+		options = append(options, notionapi.Option{Name: option.Name})
+	}
+	options = append(options, notionapi.Option{Name: tag})
+
+	updateRequest := &notionapi.PageUpdateRequest{
+		Properties: notionapi.Properties{key: notionapi.MultiSelectProperty{MultiSelect: options}},
+	}
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(taskID), updateRequest); err != nil {
+		// The update was applied, only the returned page couldn't be parsed
+		if strings.Contains(err.Error(), errButtonProperty) {
+			log.Printf("Warning: Button property in the updated page of %s, assuming it was tagged %s", taskID, tag)
+			return nil
+		}
+		return fmt.Errorf("failed to add tag: %w", err)
+	}
+
+	log.Printf("Tagged task %s with %s", taskID, tag)
+	return nil
+}
@@ -0,0 +1,110 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// taggedPageJSON is a task tagged work and errand
+const taggedPageJSON = `{
+	"object": "page",
+	"id": "page-1",
+	"properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Call the bank"}, "plain_text": "Call the bank"}]},
+		"Tags": {"id": "tags", "type": "multi_select", "multi_select": [
+			{"id": "a1", "name": "work", "color": "blue"},
+			{"id": "b2", "name": "errand", "color": "red"}
+		]}
+	}
+}`
+
+func TestAddTagToTaskKeepsExistingTags(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, taggedPageJSON
+	}}
+	client := newTestClient(fake)
+
+	if err := client.AddTagToTask(context.Background(), "page-1", SometimesLaterTag); err != nil {
+		t.Fatalf("AddTagToTask failed: %v", err)
+	}
+
+	updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update request, got %d", len(updates))
+	}
+	var body struct {
+		Properties map[string]struct {
+			MultiSelect []struct {
+				Name string `json:"name"`
+			} `json:"multi_select"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(updates[0].Body, &body); err != nil {
+		t.Fatalf("Invalid update body: %v", err)
+	}
+	tags, ok := body.Properties["Tags"]
+	if !ok || len(body.Properties) != 1 {
+		t.Fatalf("Expected only Tags to be updated, got %s", updates[0].Body)
+	}
+	var names []string
+	for _, option := range tags.MultiSelect {
+		names = append(names, option.Name)
+	}
+	want := []string{"work", "errand", SometimesLaterTag}
+	if len(names) != len(want) {
+		t.Fatalf("Expected tags %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected tags %v, got %v", want, names)
+		}
+	}
+}
+
+func TestAddTagToTaskSkipsTaggedTasks(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, taggedPageJSON
+	}}
+	client := newTestClient(fake)
+
+	if err := client.AddTagToTask(context.Background(), "page-1", "work"); err != nil {
+		t.Fatalf("AddTagToTask failed: %v", err)
+	}
+	if updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1"); len(updates) != 0 {
+		t.Errorf("Expected no update for a tag the task has, got %d", len(updates))
+	}
+}
+
+func TestParsePageID(t *testing.T) {
+	const want = "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d"
+	tests := map[string]string{
+		"clean ID":            "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d",
+		"hyphenated ID":       want,
+		"upper case":          "1A2B3C4D5E6F7A8B9C0D1E2F3A4B5C6D",
+		"URL with slug":       "https://www.notion.so/workspace/Call-the-bank-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d",
+		"URL with query":      "https://www.notion.so/Call-the-bank-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d?pvs=4",
+		"URL with clean ID":   "https://notion.so/1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d",
+		"URL with hyphens":    "https://notion.so/" + want,
+		"URL without scheme":  "notion.so/workspace/Cafe-1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d#section",
+		"page opened in view": "https://www.notion.so/ffffffffffffffffffffffffffffffff?v=00000000000000000000000000000000&p=1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d",
+		"surrounding spaces":  "  1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d\n",
+	}
+	for name, input := range tests {
+		got, err := ParsePageID(input)
+		if err != nil {
+			t.Errorf("%s: ParsePageID(%q) failed: %v", name, input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: ParsePageID(%q) = %q, want %q", name, input, got, want)
+		}
+	}
+
+	for _, input := range []string{"", "not an id", "https://www.notion.so/workspace/Call-the-bank", "1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6z"} {
+		if got, err := ParsePageID(input); err == nil {
+			t.Errorf("ParsePageID(%q) = %q, expected an error", input, got)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jomei/notionapi"
+)
+
+// ErrInvalidUpdate is returned for updates the database schema doesn't allow, such as
+// clearing the title
+var ErrInvalidUpdate = errors.New("invalid update")
+
+// nullProperty clears a property whose empty value Notion represents as null. The
+// library's select and number types can't express null.
+type nullProperty struct {
+	Type notionapi.PropertyType
+}
+
+// GetType returns the type of the cleared property
+func (p nullProperty) GetType() notionapi.PropertyType {
+	return p.Type
+}
+
+// MarshalJSON renders the property as {"<type>": null}
+func (p nullProperty) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{string(p.Type): nil})
+}
+
+// clearedProperty returns the value that empties a property of the given type, or false
+// if the type can't be cleared
+func clearedProperty(propType notionapi.PropertyConfigType) (notionapi.Property, bool) {
+	switch propType {
+	case "date":
+		return notionapi.DateProperty{Date: nil}, true
+	case "multi_select":
+		return notionapi.MultiSelectProperty{MultiSelect: []notionapi.Option{}}, true
+	case "rich_text":
+		return notionapi.RichTextProperty{RichText: []notionapi.RichText{}}, true
+	case "relation":
+		return notionapi.RelationProperty{Relation: []notionapi.Relation{}}, true
+	case "people":
+		return notionapi.PeopleProperty{People: []notionapi.User{}}, true
+	case "files":
+		return notionapi.FilesProperty{Files: []notionapi.File{}}, true
+	case "select", "number", "url", "email", "phone_number":
+		return nullProperty{Type: notionapi.PropertyType(propType)}, true
+	}
+	return nil, false
+}
+
+// UpdateTask changes the title (unless empty) and properties of a task. A nil property
+// value clears the property.
+func (c *Client) UpdateTask(ctx context.Context, taskID, title string, properties map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	plan, err := c.PlanUpdateTask(ctx, taskID, title, properties)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(plan.PageID), plan.Request); err != nil {
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	log.Printf("Successfully updated task %s", taskID)
+	return nil
+}
+
+// PlanUpdateTask builds the update request UpdateTask would send without calling the API.
+// Values are converted with the same per-type handlers as CreateTask, so the tasks
+// database schema is required.
+func (c *Client) PlanUpdateTask(ctx context.Context, taskID, title string, properties map[string]interface{}) (*UpdatePlan, error) {
+	if taskID == "" {
+		return nil, fmt.Errorf("%w: task ID is required", ErrInvalidUpdate)
+	}
+
+	dbProps, err := c.GetDatabaseProperties(ctx, "tasks")
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch database schema: %w", err)
+	}
+
+	plan := &UpdatePlan{
+		PageID:  taskID,
+		Request: &notionapi.PageUpdateRequest{Properties: make(notionapi.Properties)},
+	}
+
+	titleKey := c.titlePropertyKey(c.getDbIDForType("tasks"), dbProps)
+	if title != "" {
+		plan.Request.Properties[titleKey] = titleProperty(title)
+	}
+
+	if err := c.planProperties(ctx, plan, dbProps, properties); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planProperties adds property values to an update plan, skipping ones the schema doesn't
+// have or the per-type handlers can't convert
+func (c *Client) planProperties(ctx context.Context, plan *UpdatePlan, dbProps map[string]notionapi.PropertyConfig, properties map[string]interface{}) error {
+	props := plan.Request.Properties
+
+	skip := func(key, reason string) {
+		plan.Skipped = append(plan.Skipped, key)
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("%s: %s", key, reason))
+	}
+
+	for key, value := range properties {
+		prop, exists := dbProps[key]
+		if !exists {
+			skip(key, "property does not exist in database schema")
+			continue
+		}
+		propType := prop.GetType()
+
+		if propType == notionapi.PropertyConfigTypeTitle {
+			text, _ := value.(string)
+			if text == "" {
+				return fmt.Errorf("%w: the title can't be cleared", ErrInvalidUpdate)
+			}
+			props[key] = titleProperty(text)
+			continue
+		}
+
+		if isButtonLike(key) || propType == "button" || propType == "unsupported" {
+			skip(key, fmt.Sprintf("unsupported property type %s", propType))
+			continue
+		}
+
+		if value == nil {
+			cleared, ok := clearedProperty(propType)
+			if !ok {
+				return fmt.Errorf("%w: %s properties like %s can't be cleared", ErrInvalidUpdate, propType, key)
+			}
+			props[key] = cleared
+			continue
+		}
+
+		handled, err := c.setProperty(ctx, props, key, prop, value)
+		if err != nil {
+			return err
+		}
+		if !handled {
+			skip(key, fmt.Sprintf("unsupported property type %s", propType))
+			continue
+		}
+		if _, ok := props[key]; !ok {
+			skip(key, fmt.Sprintf("value %v could not be converted to %s", value, propType))
+		}
+	}
+
+	return nil
+}
+
+// statusPropertyKey is the tasks database property holding whether a task is done
+const statusPropertyKey = "status"
+
+// UpdateTaskStatus sets the status of a task along with any extra properties. The status is
+// written as a select, status or checkbox depending on the property type in the schema.
+func (c *Client) UpdateTaskStatus(ctx context.Context, taskID, status string, extra map[string]interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	plan, err := c.PlanUpdateTaskStatus(ctx, taskID, status, extra)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.client.Page.Update(ctx, notionapi.PageID(plan.PageID), plan.Request); err != nil {
+		// The update was applied, only the returned page couldn't be parsed
+		if strings.Contains(err.Error(), errButtonProperty) {
+			log.Printf("Warning: Button property in the updated page of %s, assuming the status was set", taskID)
+			return nil
+		}
+		return fmt.Errorf("failed to update task: %w", err)
+	}
+
+	log.Printf("Successfully updated task %s status to %s", taskID, status)
+	return nil
+}
+
+// PlanUpdateTaskStatus builds the update request UpdateTaskStatus would send without calling
+// the API. Extra properties go through the same per-type handlers as CreateTask.
+func (c *Client) PlanUpdateTaskStatus(ctx context.Context, taskID, status string, extra map[string]interface{}) (*UpdatePlan, error) {
+	if taskID == "" || status == "" {
+		return nil, fmt.Errorf("%w: task ID and status are required", ErrInvalidUpdate)
+	}
+
+	dbProps, err := c.GetDatabaseProperties(ctx, "tasks")
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch database schema: %w", err)
+	}
+
+	prop, ok := dbProps[statusPropertyKey]
+	if !ok {
+		return nil, fmt.Errorf("%w: the tasks database has no %s property", ErrInvalidUpdate, statusPropertyKey)
+	}
+	// A status property may name its finished option differently, like "Completed"
+	if _, ok := prop.(*notionapi.StatusPropertyConfig); ok && strings.EqualFold(status, doneStatus) {
+		if done := c.doneStatuses(ctx, c.getDbIDForType("tasks"), statusPropertyKey); len(done) > 0 {
+			status = done[0]
+		}
+	}
+	value, err := statusProperty(prop, status)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &UpdatePlan{
+		PageID:  taskID,
+		Request: &notionapi.PageUpdateRequest{Properties: make(notionapi.Properties)},
+	}
+	if err := c.planProperties(ctx, plan, dbProps, extra); err != nil {
+		return nil, err
+	}
+	// The status argument wins over a status among the extra properties
+	plan.Request.Properties[statusPropertyKey] = value
+
+	return plan, nil
+}
+
+// statusProperty builds the value of a status property for its type in the schema. A
+// checkbox is checked for "done" and the values handleCheckboxProperty treats as true.
+func statusProperty(prop notionapi.PropertyConfig, status string) (notionapi.Property, error) {
+	// The library reports no type for Notion's own status properties
+	if _, ok := prop.(*notionapi.StatusPropertyConfig); ok {
+		return notionapi.StatusProperty{Status: notionapi.Status{Name: status}}, nil
+	}
+
+	switch prop.GetType() {
+	case notionapi.PropertyConfigTypeSelect:
+		return notionapi.SelectProperty{Select: notionapi.Option{Name: status}}, nil
+	case notionapi.PropertyConfigTypeCheckbox:
+		return notionapi.CheckboxProperty{Checkbox: checkedStatus(status)}, nil
+	}
+	return nil, fmt.Errorf("%w: the %s property must be a select, status or checkbox, not %s", ErrInvalidUpdate, statusPropertyKey, prop.GetType())
+}
+
+// checkedStatus reports whether a status means a checked checkbox: "done" and the values
+// handleCheckboxProperty treats as true
+func checkedStatus(status string) bool {
+	switch strings.ToLower(status) {
+	case doneStatus, "true", "yes", "1":
+		return true
+	}
+	return false
+}
+
+// titleProperty builds a title property holding plain text
+func titleProperty(title string) notionapi.TitleProperty {
+	return notionapi.TitleProperty{
+		Title: []notionapi.RichText{
+			{
+				Type: notionapi.ObjectType("text"),
+				Text: &notionapi.Text{Content: title},
+			},
+		},
+	}
+}
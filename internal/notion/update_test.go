@@ -0,0 +1,252 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// clearableSchemaJSON is a database with one property of every clearable type
+const clearableSchemaJSON = `{
+	"object": "database",
+	"id": "tasks-db",
+	"properties": {
+		"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+		"Date": {"id": "date", "name": "Date", "type": "date", "date": {}},
+		"project": {"id": "proj", "name": "project", "type": "select", "select": {"options": []}},
+		"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": []}},
+		"Notes": {"id": "notes", "name": "Notes", "type": "rich_text", "rich_text": {}},
+		"Estimate": {"id": "est", "name": "Estimate", "type": "number", "number": {"format": "number"}},
+		"Link": {"id": "url", "name": "Link", "type": "url", "url": {}},
+		"Mail": {"id": "mail", "name": "Mail", "type": "email", "email": {}},
+		"Phone": {"id": "tel", "name": "Phone", "type": "phone_number", "phone_number": {}},
+		"Blocked by": {"id": "rel", "name": "Blocked by", "type": "relation", "relation": {"database_id": "tasks-db", "type": "single_property", "single_property": {}}},
+		"Owner": {"id": "ppl", "name": "Owner", "type": "people", "people": {}},
+		"Attachments": {"id": "files", "name": "Attachments", "type": "files", "files": {}},
+		"Urgent": {"id": "urg", "name": "Urgent", "type": "checkbox", "checkbox": {}}
+	}
+}`
+
+func newUpdateTestClient() (*Client, *fakeNotion) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		if method == http.MethodGet {
+			return http.StatusOK, clearableSchemaJSON
+		}
+		return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+	}}
+	return newTestClient(fake), fake
+}
+
+func TestUpdateTaskNullClearsProperties(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"Date", `{"date": null}`},
+		{"project", `{"select": null}`},
+		{"Tags", `{"multi_select": []}`},
+		{"Notes", `{"rich_text": []}`},
+		{"Estimate", `{"number": null}`},
+		{"Link", `{"url": null}`},
+		{"Mail", `{"email": null}`},
+		{"Phone", `{"phone_number": null}`},
+		{"Blocked by", `{"relation": []}`},
+		{"Owner", `{"people": []}`},
+		{"Attachments", `{"files": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			client, fake := newUpdateTestClient()
+
+			if err := client.UpdateTask(context.Background(), "page-1", "", map[string]interface{}{tt.key: nil}); err != nil {
+				t.Fatalf("UpdateTask failed: %v", err)
+			}
+
+			updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")
+			if len(updates) != 1 {
+				t.Fatalf("Expected 1 update request, got %d", len(updates))
+			}
+			var body struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+			if err := json.Unmarshal(updates[0].Body, &body); err != nil {
+				t.Fatalf("Invalid update body: %v", err)
+			}
+			if len(body.Properties) != 1 {
+				t.Errorf("Expected only the cleared property, got %s", updates[0].Body)
+			}
+			if !jsonEqual(t, body.Properties[tt.key], []byte(tt.want)) {
+				t.Errorf("Expected %s to be cleared as %s, got %s", tt.key, tt.want, body.Properties[tt.key])
+			}
+		})
+	}
+}
+
+func TestUpdateTaskRejectsInvalidClears(t *testing.T) {
+	client, fake := newUpdateTestClient()
+
+	for _, key := range []string{"Name", "Urgent"} {
+		err := client.UpdateTask(context.Background(), "page-1", "", map[string]interface{}{key: nil})
+		if !errors.Is(err, ErrInvalidUpdate) {
+			t.Errorf("Expected clearing %s to be rejected, got %v", key, err)
+		}
+	}
+	if n := len(fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")); n != 0 {
+		t.Errorf("Rejected updates must not reach Notion, got %d requests", n)
+	}
+}
+
+func TestUpdateTaskSetsValues(t *testing.T) {
+	client, _ := newUpdateTestClient()
+
+	plan, err := client.PlanUpdateTask(context.Background(), "page-1", "Buy oat milk", map[string]interface{}{
+		"Date":    "2024-06-01",
+		"Unknown": "value",
+	})
+	if err != nil {
+		t.Fatalf("PlanUpdateTask failed: %v", err)
+	}
+	for _, key := range []string{"Name", "Date"} {
+		if _, ok := plan.Request.Properties[key]; !ok {
+			t.Errorf("Expected %s in the update, got %v", key, plan.Request.Properties)
+		}
+	}
+	if len(plan.Skipped) != 1 || plan.Skipped[0] != "Unknown" {
+		t.Errorf("Expected Unknown to be skipped, got %v", plan.Skipped)
+	}
+}
+
+func TestUpdateTaskReplacesTitleAndTags(t *testing.T) {
+	client, fake := newUpdateTestClient()
+
+	err := client.UpdateTask(context.Background(), "page-1", "Buy oat milk", map[string]interface{}{
+		"Tags": []interface{}{"groceries", "weekly"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateTask failed: %v", err)
+	}
+
+	updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")
+	if len(updates) != 1 {
+		t.Fatalf("Expected 1 update request, got %d", len(updates))
+	}
+	var body struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(updates[0].Body, &body); err != nil {
+		t.Fatalf("Invalid update body: %v", err)
+	}
+
+	// Notion replaces a multi-select with the options sent, so the list is the new value
+	expected := map[string]string{
+		"Name": `{"title": [{"type": "text", "text": {"content": "Buy oat milk"}}]}`,
+		"Tags": `{"multi_select": [{"name": "groceries"}, {"name": "weekly"}]}`,
+	}
+	if len(body.Properties) != len(expected) {
+		t.Errorf("Expected only the title and tags, got %s", updates[0].Body)
+	}
+	for key, want := range expected {
+		if !jsonEqual(t, body.Properties[key], []byte(want)) {
+			t.Errorf("Expected %s to be %s, got %s", key, want, body.Properties[key])
+		}
+	}
+}
+
+func TestCreateTaskSkipsNulls(t *testing.T) {
+	client, _ := newUpdateTestClient()
+
+	plan, err := client.PlanCreateTask(context.Background(), "Buy milk", map[string]interface{}{
+		"Date":   nil,
+		"Urgent": nil,
+	}, "tasks")
+	if err != nil {
+		t.Fatalf("PlanCreateTask failed: %v", err)
+	}
+	for _, key := range []string{"Date", "Urgent"} {
+		if _, ok := plan.Request.Properties[key]; ok {
+			t.Errorf("Null %s must not be sent on create", key)
+		}
+	}
+	if len(plan.Skipped) != 2 {
+		t.Errorf("Expected both nulls to be reported as skipped, got %v", plan.Skipped)
+	}
+}
+
+// statusSchemaJSON is a tasks database whose status property has the given definition
+func statusSchemaJSON(statusProp string) string {
+	return `{
+		"object": "database",
+		"id": "tasks-db",
+		"properties": {
+			"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+			"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": []}},
+			"status": ` + statusProp + `
+		}
+	}`
+}
+
+func TestUpdateTaskStatusFollowsPropertyType(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusProp string
+		status     string
+		want       string
+	}{
+		{"select", `{"id": "st", "type": "select", "select": {"options": []}}`, "done", `{"select": {"name": "done"}}`},
+		{"status", `{"id": "st", "type": "status", "status": {}}`, "Done", `{"status": {"name": "Done"}}`},
+		{"checkbox done", `{"id": "st", "type": "checkbox", "checkbox": {}}`, "done", `{"checkbox": true}`},
+		{"checkbox open", `{"id": "st", "type": "checkbox", "checkbox": {}}`, "todo", `{"checkbox": false}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+				if method == http.MethodGet {
+					return http.StatusOK, statusSchemaJSON(tt.statusProp)
+				}
+				return http.StatusOK, `{"object": "page", "id": "page-1", "properties": {}}`
+			}}
+			client := newTestClient(fake)
+
+			err := client.UpdateTaskStatus(context.Background(), "page-1", tt.status, map[string]interface{}{
+				"Tags": []interface{}{"home"},
+			})
+			if err != nil {
+				t.Fatalf("UpdateTaskStatus failed: %v", err)
+			}
+
+			updates := fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")
+			if len(updates) != 1 {
+				t.Fatalf("Expected 1 update request, got %d", len(updates))
+			}
+			var body struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			}
+			if err := json.Unmarshal(updates[0].Body, &body); err != nil {
+				t.Fatalf("Invalid update body: %v", err)
+			}
+			if !jsonEqual(t, body.Properties["status"], []byte(tt.want)) {
+				t.Errorf("Expected status %s, got %s", tt.want, body.Properties["status"])
+			}
+			if !jsonEqual(t, body.Properties["Tags"], []byte(`{"multi_select": [{"name": "home"}]}`)) {
+				t.Errorf("Expected the extra Tags property, got %s", body.Properties["Tags"])
+			}
+		})
+	}
+}
+
+func TestUpdateTaskStatusRejectsOtherTypes(t *testing.T) {
+	fake := &fakeNotion{respond: func(method, path string, body []byte) (int, string) {
+		return http.StatusOK, statusSchemaJSON(`{"id": "st", "type": "rich_text", "rich_text": {}}`)
+	}}
+	client := newTestClient(fake)
+
+	err := client.UpdateTaskStatus(context.Background(), "page-1", "done", nil)
+	if !errors.Is(err, ErrInvalidUpdate) {
+		t.Errorf("Expected a text status property to be rejected, got %v", err)
+	}
+	if n := len(fake.requestsTo(http.MethodPatch, "/v1/pages/page-1")); n != 0 {
+		t.Errorf("Rejected updates must not reach Notion, got %d requests", n)
+	}
+}
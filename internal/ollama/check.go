@@ -0,0 +1,46 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+var _ llm.Checker = (*Client)(nil)
+
+// Check verifies the server is reachable and has the configured model pulled
+func (c *Client) Check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url+"/api/tags", nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Ollama at %s: %w", c.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("ollama returned status %d", resp.StatusCode)
+	}
+
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	for _, model := range tags.Models {
+		// Models are listed with their tag, "llama3.2" is pulled as "llama3.2:latest"
+		if model.Name == c.model || strings.TrimSuffix(model.Name, ":latest") == c.model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %s is not pulled, run: ollama pull %s", c.model, c.model)
+}
@@ -0,0 +1,178 @@
+// Package ollama implements the llm interfaces on top of a local Ollama server
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+)
+
+const (
+	defaultURL   = "http://localhost:11434"
+	defaultModel = "llama3.2"
+)
+
+// Client talks to the Ollama /api/generate endpoint
+type Client struct {
+	url        string
+	model      string
+	httpClient *http.Client
+}
+
+// Client implements every LLM capability, transcription is reported as unsupported
+var _ llm.Provider = (*Client)(nil)
+var _ llm.DateExtractor = (*Client)(nil)
+var _ llm.Splitter = (*Client)(nil)
+
+// GenerateRequest is the body of a non-streaming /api/generate call
+type GenerateRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+	Stream bool   `json:"stream"`
+}
+
+// GenerateResponse is the reply of a non-streaming /api/generate call
+type GenerateResponse struct {
+	Model    string `json:"model"`
+	Response string `json:"response"`
+	Done     bool   `json:"done"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewClient creates a client for the Ollama server at OLLAMA_URL using OLLAMA_MODEL
+func NewClient() *Client {
+	url := strings.TrimSuffix(os.Getenv("OLLAMA_URL"), "/")
+	if url == "" {
+		url = defaultURL
+	}
+
+	model := os.Getenv("OLLAMA_MODEL")
+	if model == "" {
+		model = defaultModel
+	}
+
+	log.Printf("Using Ollama model %s at %s", model, url)
+
+	return &Client{
+		url:   url,
+		model: model,
+		// Local models can be slow to load on first use
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+// TagTask analyzes the task content and returns an appropriate tag
+func (c *Client) TagTask(ctx context.Context, taskContent string) (string, error) {
+	text, err := c.generate(ctx, llm.TagPrompt(taskContent))
+	if err != nil {
+		return "", err
+	}
+
+	tag := llm.NormalizeTag(text)
+	if tag != strings.TrimSpace(strings.ToLower(text)) {
+		log.Printf("Ollama returned %q, using tag '%s'", text, tag)
+	}
+
+	log.Printf("Ollama tagged task as: %s", tag)
+	return tag, nil
+}
+
+// TagTasksBatch tags several task entries with a single request
+func (c *Client) TagTasksBatch(ctx context.Context, contents []string) ([]string, error) {
+	if len(contents) == 0 {
+		return nil, nil
+	}
+
+	text, err := c.generate(ctx, llm.BatchTagPrompt(contents))
+	if err != nil {
+		return nil, err
+	}
+	return llm.ParseBatchTags(text, len(contents))
+}
+
+// Summarize returns a short paragraph on the themes of the items
+func (c *Client) Summarize(ctx context.Context, items []string) (string, error) {
+	if len(items) == 0 {
+		return "", nil
+	}
+
+	summary, err := c.generate(ctx, llm.SummaryPrompt(items))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(summary), nil
+}
+
+// SplitTasks asks the model for the separate tasks a message lists
+func (c *Client) SplitTasks(ctx context.Context, text string) ([]string, error) {
+	answer, err := c.generate(ctx, llm.SplitPrompt(text))
+	if err != nil {
+		return nil, err
+	}
+	return llm.ParseSplit(answer)
+}
+
+// TranscribeAudio is not available, Ollama's generate API doesn't accept audio
+func (c *Client) TranscribeAudio(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return "", fmt.Errorf("ollama transcription: %w", llm.ErrNotSupported)
+}
+
+// generate runs a prompt through the model and returns the full response text
+func (c *Client) generate(ctx context.Context, prompt string) (string, error) {
+	jsonData, err := json.Marshal(GenerateRequest{
+		Model:  c.model,
+		Prompt: prompt,
+		Stream: false,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/api/generate", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("Ollama returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var generateResp GenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&generateResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if generateResp.Error != "" {
+		return "", fmt.Errorf("Ollama error: %s", generateResp.Error)
+	}
+	if strings.TrimSpace(generateResp.Response) == "" {
+		return "", fmt.Errorf("empty response from Ollama")
+	}
+
+	return generateResp.Response, nil
+}
+
+// ExtractDate asks the model for the date the task mentions, relative to now
+func (c *Client) ExtractDate(ctx context.Context, taskContent string, now time.Time) (string, error) {
+	text, err := c.generate(ctx, llm.DatePrompt(taskContent, now))
+	if err != nil {
+		return "", err
+	}
+	return llm.ParseDate(text, now)
+}
@@ -0,0 +1,65 @@
+// Package redact masks secrets in log output, so a bot token or Notion key printed by a
+// library or an error message doesn't end up in the logs
+package redact
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces every secret found
+const Mask = "[REDACTED]"
+
+// tokenPatterns match secrets that weren't passed explicitly: Telegram bot tokens, which
+// appear in Bot API URLs, and Notion integration tokens
+var tokenPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\d{6,}:[A-Za-z0-9_-]{30,}`),
+	regexp.MustCompile(`\b(?:secret|ntn)_[A-Za-z0-9]{20,}`),
+}
+
+// Sanitizer masks known secrets and anything shaped like a token
+type Sanitizer struct {
+	secrets []string
+}
+
+// New creates a sanitizer for the given secrets, empty ones are ignored
+func New(secrets ...string) *Sanitizer {
+	s := &Sanitizer{}
+	for _, secret := range secrets {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			s.secrets = append(s.secrets, secret)
+		}
+	}
+	return s
+}
+
+// String returns text with every secret masked
+func (s *Sanitizer) String(text string) string {
+	for _, secret := range s.secrets {
+		text = strings.ReplaceAll(text, secret, Mask)
+	}
+	for _, pattern := range tokenPatterns {
+		text = pattern.ReplaceAllString(text, Mask)
+	}
+	return text
+}
+
+// Writer returns a writer masking secrets before writing to w. Each write should be a
+// whole log line, which is how the log package writes.
+func (s *Sanitizer) Writer(w io.Writer) io.Writer {
+	return &writer{sanitizer: s, w: w}
+}
+
+type writer struct {
+	sanitizer *Sanitizer
+	w         io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.sanitizer.String(string(p))); err != nil {
+		return 0, err
+	}
+	// The caller wrote all of p, however long the masked text is
+	return len(p), nil
+}
@@ -0,0 +1,35 @@
+package redact
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSanitizerMasksTokensMidString(t *testing.T) {
+	botToken := "123456789:AAHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+	notionKey := "secret_4bXq9LmP2zR7tY1wK8vN3cJ6hF0dS5aG"
+
+	var out bytes.Buffer
+	logger := log.New(New(botToken, notionKey, "").Writer(&out), "", 0)
+	logger.Printf("Endpoint: getUpdates, params: https://api.telegram.org/bot%s/getUpdates?offset=1 (key %s)", botToken, notionKey)
+
+	logged := out.String()
+	if strings.Contains(logged, botToken) || strings.Contains(logged, notionKey) {
+		t.Fatalf("Expected the secrets to be masked, got %q", logged)
+	}
+	want := "Endpoint: getUpdates, params: https://api.telegram.org/bot" + Mask + "/getUpdates?offset=1 (key " + Mask + ")\n"
+	if logged != want {
+		t.Errorf("Expected %q, got %q", want, logged)
+	}
+
+	// Tokens that weren't passed are masked by their shape
+	other := "987654321:ZZHdqTcvCH1vGWJxfSeofSAs0K5PALDsaw"
+	if got := New().String("url=/bot" + other + "/getMe ok"); got != "url=/bot"+Mask+"/getMe ok" {
+		t.Errorf("Expected an unknown bot token to be masked, got %q", got)
+	}
+	if got := New().String("task 12:30 at secret_garden"); got != "task 12:30 at secret_garden" {
+		t.Errorf("Expected ordinary text to be left alone, got %q", got)
+	}
+}
@@ -0,0 +1,487 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// checkedTasks are the open tasks of a daily check, one per flagged tag plus a regular task
+const checkedTasks = `[
+	{"object": "page", "id": "task-date", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Submit report"}, "plain_text": "Submit report"}]},
+		"llm_tag": {"id": "tag", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "date"}, "plain_text": "date"}]}
+	}},
+	{"object": "page", "id": "task-journal", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Quiet evening"}, "plain_text": "Quiet evening"}]},
+		"llm_tag": {"id": "tag", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "journal"}, "plain_text": "journal"}]}
+	}},
+	{"object": "page", "id": "task-link", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "https://example.com"}, "plain_text": "https://example.com"}]},
+		"llm_tag": {"id": "tag", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "link"}, "plain_text": "link"}]}
+	}},
+	{"object": "page", "id": "task-plain", "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Buy milk"}, "plain_text": "Buy milk"}]},
+		"llm_tag": {"id": "tag", "type": "rich_text", "rich_text": [{"type": "text", "text": {"content": "task"}, "plain_text": "task"}]}
+	}}
+]`
+
+// checkNotion serves the Notion requests of a daily check over checkedTasks, including
+// moving the journal task, and records the archive requests and created pages
+type checkNotion struct {
+	failArchive bool
+	// journalAssignee assigns the journal task to a person of that name
+	journalAssignee string
+
+	mu       sync.Mutex
+	archived []string
+	created  int
+}
+
+func (n *checkNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	status, body := http.StatusOK, `{"object": "page", "id": "journal-1"}`
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/query"):
+		tasks := checkedTasks
+		if n.journalAssignee != "" {
+			assignee := `"Assignee": {"id": "ppl", "type": "people", "people": [{"object": "user", "id": "user-1", "name": "` + n.journalAssignee + `"}]},
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Quiet evening"}`
+			tasks = strings.Replace(tasks, `"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Quiet evening"}`, assignee, 1)
+		}
+		body = `{"object": "list", "results": ` + tasks + `, "has_more": false}`
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/databases/"):
+		body = `{"object": "database", "id": "journal-db", "properties": {"Name": {"id": "title", "name": "Name", "type": "title", "title": {}}}}`
+	case req.Method == http.MethodGet && req.URL.Path == "/v1/pages/task-journal":
+		body = `{"object": "page", "id": "task-journal", "created_time": "2024-03-15T08:30:00.000Z", "properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Quiet evening"}, "plain_text": "Quiet evening"}]}
+		}}`
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/blocks/"):
+		body = `{"object": "list", "results": [], "has_more": false}`
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/pages":
+		n.mu.Lock()
+		n.created++
+		n.mu.Unlock()
+	case req.Method == http.MethodPatch:
+		n.mu.Lock()
+		n.archived = append(n.archived, req.URL.Path)
+		failArchive := n.failArchive
+		n.mu.Unlock()
+		if failArchive {
+			status, body = http.StatusInternalServerError, `{"object": "error", "status": 500, "code": "internal_server_error", "message": "boom"}`
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// runCheck runs a daily check over checkedTasks and returns the texts of the sent
+// messages, the digest last
+func runCheck(t *testing.T, s *Scheduler, transport http.RoundTripper) []string {
+	t.Helper()
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	t.Setenv("NOTION_JOURNAL_DATABASE_ID", "journal-db")
+	telegram, botAPI := newFakeTelegram(t)
+	s.bot = botAPI
+	s.authorizedUserID = 42
+	s.timezone = time.UTC
+	s.notionClient = notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: transport}))
+
+	s.checkTasks(context.Background())
+
+	calls := telegram.callsTo("sendMessage")
+	var texts []string
+	for _, call := range calls {
+		texts = append(texts, call.Params.Get("text"))
+	}
+	if len(calls) > 0 && calls[len(calls)-1].Params.Get("parse_mode") != "HTML" {
+		t.Errorf("Expected an HTML digest, got %q", calls[len(calls)-1].Params.Get("parse_mode"))
+	}
+	return texts
+}
+
+func TestCheckTasksSendsOneDigest(t *testing.T) {
+	texts := runCheck(t, &Scheduler{}, &checkNotion{})
+	if len(texts) != 1 {
+		t.Fatalf("Expected a single digest message, got %d: %q", len(texts), texts)
+	}
+
+	digest := texts[0]
+	for _, want := range []string{
+		"Daily Task Check",
+		"Deadlines without a date</b> (1)",
+		`<a href="https://notion.so/taskdate">Submit report</a>`,
+		"Possible journal entries</b> (1)",
+		`<a href="https://notion.so/taskjournal">Quiet evening</a>`,
+		"Link-only tasks</b> (1)",
+		"Found 3 task(s) needing attention",
+	} {
+		if !strings.Contains(digest, want) {
+			t.Errorf("Expected %q in the digest:\n%s", want, digest)
+		}
+	}
+	if strings.Contains(digest, "Buy milk") || strings.Contains(digest, "Overdue") {
+		t.Errorf("Expected only flagged tasks in the digest:\n%s", digest)
+	}
+}
+
+func TestCheckTasksDigestNamesTheAssignee(t *testing.T) {
+	texts := runCheck(t, &Scheduler{}, &checkNotion{journalAssignee: "Anna <ops>"})
+	if len(texts) != 1 {
+		t.Fatalf("Expected a single digest message, got %d: %q", len(texts), texts)
+	}
+	want := `<a href="https://notion.so/taskjournal">Quiet evening</a> — Anna &lt;ops&gt;`
+	if !strings.Contains(texts[0], want) {
+		t.Errorf("Expected %q in the digest:\n%s", want, texts[0])
+	}
+	if !strings.Contains(texts[0], `<a href="https://notion.so/taskdate">Submit report</a>`+"\n") {
+		t.Errorf("Expected unassigned tasks without an assignee:\n%s", texts[0])
+	}
+}
+
+func TestPausedCheckSendsNothingUnlessManual(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := database.NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.SetPausedUntil(42, time.Now().Add(48*time.Hour)); err != nil {
+		t.Fatalf("SetPausedUntil failed: %v", err)
+	}
+	db.Close()
+
+	// The pause outlives a restart
+	db, err = database.NewDB(path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	s := &Scheduler{db: db}
+
+	if texts := runCheck(t, s, &checkNotion{}); len(texts) != 0 {
+		t.Errorf("Expected no messages while paused, got %q", texts)
+	}
+
+	// /cron still runs the check
+	telegram, botAPI := newFakeTelegram(t)
+	s.bot = botAPI
+	s.runTaskCheck(context.Background())
+	if calls := telegram.callsTo("sendMessage"); len(calls) != 1 || !strings.Contains(calls[0].Params.Get("text"), "Daily Task Check") {
+		t.Errorf("Expected the manual check to send the digest, got %d message(s)", len(calls))
+	}
+
+	// Once resumed, the check runs again
+	if _, err := db.ClearPause(42); err != nil {
+		t.Fatalf("ClearPause failed: %v", err)
+	}
+	if texts := runCheck(t, s, &checkNotion{}); len(texts) != 1 {
+		t.Errorf("Expected the digest after resuming, got %q", texts)
+	}
+}
+
+func TestUndeliveredDigestIsSentNextCheck(t *testing.T) {
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	t.Setenv("NOTION_JOURNAL_DATABASE_ID", "journal-db")
+	telegram, botAPI := newFakeTelegram(t)
+	db := newTestDB(t)
+	s := &Scheduler{
+		bot:              botAPI,
+		authorizedUserID: 42,
+		timezone:         time.UTC,
+		db:               db,
+		notionClient:     notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: &checkNotion{}})),
+	}
+
+	// Telegram fails every attempt at the digest
+	telegram.unavailable = maxSendAttempts
+	s.runTaskCheck(context.Background())
+	missed, err := db.GetMissedDigests(42)
+	if err != nil {
+		t.Fatalf("GetMissedDigests failed: %v", err)
+	}
+	if len(missed) != 1 || !strings.Contains(missed[0].Messages[0], "Possible journal entries") {
+		t.Fatalf("Expected the digest to be kept, got %+v", missed)
+	}
+
+	// The next check sends it after a notice, then its own digest
+	before := len(telegram.callsTo("sendMessage"))
+	s.runTaskCheck(context.Background())
+	var texts []string
+	for _, call := range telegram.callsTo("sendMessage")[before:] {
+		texts = append(texts, call.Params.Get("text"))
+	}
+	if len(texts) != 3 || !strings.Contains(texts[0], "You missed 1 digest from earlier today") || texts[1] != missed[0].Messages[0] || !strings.Contains(texts[2], "Daily Task Check") {
+		t.Errorf("Expected the notice, the missed digest and the new one, got %q", texts)
+	}
+	if missed, _ := db.GetMissedDigests(42); len(missed) != 0 {
+		t.Errorf("Expected the missed digest to be deleted once sent, got %d", len(missed))
+	}
+}
+
+func TestMissedDigestsNotice(t *testing.T) {
+	now := time.Date(2026, 10, 14, 23, 0, 0, 0, time.UTC)
+	yesterday := []database.MissedDigest{{CreatedAt: now.Add(-24 * time.Hour)}}
+	if notice := missedDigestsNotice(yesterday, now); !strings.Contains(notice, "You missed 1 digest from yesterday") {
+		t.Errorf("Unexpected notice: %s", notice)
+	}
+	older := []database.MissedDigest{{CreatedAt: now.AddDate(0, 0, -3)}, {CreatedAt: now.Add(-24 * time.Hour)}}
+	if notice := missedDigestsNotice(older, now); !strings.Contains(notice, "You missed 2 digests, the first from Sun 11 Oct") {
+		t.Errorf("Unexpected notice: %s", notice)
+	}
+}
+
+func TestCheckTasksPerTaskNotifications(t *testing.T) {
+	texts := runCheck(t, &Scheduler{perTaskNotifications: true}, &checkNotion{})
+
+	// A message per flagged task, then the digest with only the summary
+	if len(texts) != 4 {
+		t.Fatalf("Expected 3 notifications and the digest, got %d: %q", len(texts), texts)
+	}
+	digest := texts[3]
+	if strings.Contains(digest, "Possible journal entries") || !strings.Contains(digest, "Found 3 task(s)") {
+		t.Errorf("Expected the digest to only summarize:\n%s", digest)
+	}
+}
+
+func TestNotificationsOfferSnooze(t *testing.T) {
+	telegram, botAPI := newFakeTelegram(t)
+	s := &Scheduler{bot: botAPI, authorizedUserID: 42}
+
+	task := notion.Task{ID: "1a2b3c4d-5e6f-7a8b-9c0d-1e2f3a4b5c6d", Title: "https://example.com", Properties: map[string]interface{}{"llm_tag": "link"}}
+	if _, err := s.sendNotification(task, false); err != nil {
+		t.Fatalf("sendNotification failed: %v", err)
+	}
+
+	calls := telegram.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 notification, got %d", len(calls))
+	}
+	markup := calls[0].Params.Get("reply_markup")
+	if !strings.Contains(markup, `"callback_data":"snooze:`+task.ID+`"`) {
+		t.Errorf("Expected a snooze button for the task, got %s", markup)
+	}
+}
+
+func TestNotificationsNameTheAssignee(t *testing.T) {
+	telegram, botAPI := newFakeTelegram(t)
+	s := &Scheduler{bot: botAPI, authorizedUserID: 42}
+
+	task := notion.Task{ID: "task-1", Title: "Dear diary", Properties: map[string]interface{}{
+		"llm_tag":                  "journal",
+		notion.AssigneePropertyKey: []string{"Anna", "Boris_K"},
+	}}
+	if _, err := s.sendNotification(task, false); err != nil {
+		t.Fatalf("sendNotification failed: %v", err)
+	}
+
+	calls := telegram.callsTo("sendMessage")
+	// Names are escaped for the Markdown of the notification
+	if len(calls) != 1 || !strings.Contains(calls[0].Params.Get("text"), "Task: Dear diary\nAssignee: Anna, Boris\\_K\n") {
+		t.Errorf("Expected the escaped assignees under the task, got %v", calls)
+	}
+}
+
+func TestCheckTasksMovesJournalEntries(t *testing.T) {
+	tests := []struct {
+		name        string
+		failArchive bool
+		want        string
+	}{
+		{"moved", false, `(<a href="https://notion.so/taskjournal">archived task</a>)`},
+		{"archive failed", true, `(copied, <a href="https://notion.so/taskjournal">archive the task</a> yourself)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := &checkNotion{failArchive: tt.failArchive}
+			texts := runCheck(t, &Scheduler{autoMoveJournal: true}, transport)
+			if len(texts) != 1 {
+				t.Fatalf("Expected a single digest message, got %d", len(texts))
+			}
+
+			digest := texts[0]
+			if !strings.Contains(digest, "Moved to your journal</b> (1)") ||
+				!strings.Contains(digest, `<a href="https://notion.so/journal1">Quiet evening</a> `+tt.want) {
+				t.Errorf("Expected the moved entry with links to both pages:\n%s", digest)
+			}
+			if strings.Contains(digest, "Possible journal entries") || !strings.Contains(digest, "Moved 1 journal entr(ies)") {
+				t.Errorf("Expected the moved task not to be flagged:\n%s", digest)
+			}
+			if len(transport.archived) != 1 || transport.archived[0] != "/v1/pages/task-journal" {
+				t.Errorf("Expected the journal task to be archived, got %v", transport.archived)
+			}
+		})
+	}
+}
+
+func TestCheckTasksDoesNotCopyJournalEntriesTwice(t *testing.T) {
+	for name, db := range map[string]func(t *testing.T) *database.DB{
+		"database":    newTestDB,
+		"no database": func(t *testing.T) *database.DB { return nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			transport := &checkNotion{failArchive: true}
+			s := &Scheduler{autoMoveJournal: true, db: db(t)}
+			runCheck(t, s, transport)
+
+			// The copy exists, the next check only retries archiving the task
+			texts := runCheck(t, s, transport)
+			if transport.created != 1 {
+				t.Errorf("Expected a single journal entry, got %d", transport.created)
+			}
+			if len(texts) != 1 || !strings.Contains(texts[0], `<a href="https://notion.so/journal1">Quiet evening</a> (copied, <a href="https://notion.so/taskjournal">archive the task</a> yourself)`) {
+				t.Errorf("Expected the existing entry to be reported again, got %v", texts)
+			}
+
+			transport.mu.Lock()
+			transport.failArchive = false
+			transport.mu.Unlock()
+			texts = runCheck(t, s, transport)
+			if transport.created != 1 || len(transport.archived) != 3 {
+				t.Errorf("Expected the third check to archive the task only, got %d entries and archives %v", transport.created, transport.archived)
+			}
+			if len(texts) != 1 || !strings.Contains(texts[0], `<a href="https://notion.so/journal1">Quiet evening</a> (<a href="https://notion.so/taskjournal">archived task</a>)`) {
+				t.Errorf("Expected the task to be reported archived, got %v", texts)
+			}
+			if journalID := s.journalCopy("task-journal"); journalID != "" {
+				t.Errorf("Expected the copy to be forgotten once archived, got %q", journalID)
+			}
+		})
+	}
+}
+
+// pageStates answers GetPage requests for recorded tasks by page ID, with a Notion status
+// and whether the page is archived
+type pageStates map[string]struct {
+	status   int
+	archived bool
+}
+
+func (p pageStates) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := strings.TrimPrefix(req.URL.Path, "/v1/pages/")
+	state := p[id]
+	body := fmt.Sprintf(`{"object": "page", "id": %q, "archived": %t, "properties": {}}`, id, state.archived)
+	if state.status != http.StatusOK {
+		body = fmt.Sprintf(`{"object": "error", "status": %d, "code": "error", "message": "failed"}`, state.status)
+	}
+	return &http.Response{
+		StatusCode: state.status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestPruneDeletedTasks(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"task-kept", "task-deleted", "task-archived", "task-unreachable"} {
+		if err := db.StoreTaskMetadata(database.OwnWorkspace, id, "Title", "task"); err != nil {
+			t.Fatalf("StoreTaskMetadata failed: %v", err)
+		}
+	}
+	pages := pageStates{
+		"task-kept":        {status: http.StatusOK},
+		"task-deleted":     {status: http.StatusNotFound},
+		"task-archived":    {status: http.StatusOK, archived: true},
+		"task-unreachable": {status: http.StatusBadGateway},
+	}
+	s := &Scheduler{db: db, notionClient: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: pages}))}
+
+	s.pruneDeletedTasks(context.Background(), time.Now())
+
+	tasks, err := db.GetTasksSince(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetTasksSince failed: %v", err)
+	}
+	var remaining []string
+	for _, task := range tasks {
+		remaining = append(remaining, task.TaskID)
+	}
+	sort.Strings(remaining)
+	// Pages Notion couldn't be asked about are kept
+	if want := []string{"task-kept", "task-unreachable"}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("Expected %v to remain, got %v", want, remaining)
+	}
+}
+
+func TestReconcileWalksEveryRecordedTask(t *testing.T) {
+	db := newTestDB(t)
+	for _, id := range []string{"task-kept", "task-deleted", "task-archived", "task-unreachable"} {
+		if err := db.StoreTaskMetadata(database.OwnWorkspace, id, "Title", "task"); err != nil {
+			t.Fatalf("StoreTaskMetadata failed: %v", err)
+		}
+	}
+	pages := pageStates{
+		"task-kept":        {status: http.StatusOK},
+		"task-deleted":     {status: http.StatusNotFound},
+		"task-archived":    {status: http.StatusOK, archived: true},
+		"task-unreachable": {status: http.StatusServiceUnavailable},
+	}
+	s := &Scheduler{db: db, notionClient: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: pages}))}
+
+	result, err := s.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if want := (ReconcileResult{Checked: 4, Removed: 2, Skipped: 1}); result != want {
+		t.Errorf("Expected %+v, got %+v", want, result)
+	}
+
+	remaining, err := db.GetTaskIDs(database.OwnWorkspace)
+	if err != nil {
+		t.Fatalf("GetTaskIDs failed: %v", err)
+	}
+	sort.Strings(remaining)
+	if want := []string{"task-kept", "task-unreachable"}; !reflect.DeepEqual(remaining, want) {
+		t.Errorf("Expected %v to remain, got %v", want, remaining)
+	}
+}
+
+func TestPruneAndReconcileStayInTheirWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.StoreTaskMetadata(database.OwnWorkspace, "task-own", "Title", "task"); err != nil {
+		t.Fatalf("StoreTaskMetadata failed: %v", err)
+	}
+	if err := db.StoreTaskMetadata(77, "task-theirs", "Title", "task"); err != nil {
+		t.Fatalf("StoreTaskMetadata failed: %v", err)
+	}
+	// The bot's integration can't see the connected user's pages
+	own := pageStates{
+		"task-own":    {status: http.StatusNotFound},
+		"task-theirs": {status: http.StatusNotFound},
+	}
+	theirs := pageStates{"task-theirs": {status: http.StatusOK, archived: true}}
+	s := &Scheduler{db: db, notionClient: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: own}))}
+
+	s.pruneDeletedTasks(context.Background(), time.Now())
+	if result, err := s.Reconcile(context.Background()); err != nil || result.Checked != 0 {
+		t.Errorf("Expected nothing left to check in the bot's workspace, got %+v, %v", result, err)
+	}
+	if ids, _ := db.GetTaskIDs(77); len(ids) != 1 {
+		t.Fatalf("Expected the connected user's task to be kept, got %v", ids)
+	}
+
+	// Their own scheduler checks it with their integration
+	connected := s.forTenant(77, notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: theirs})))
+	result, err := connected.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if want := (ReconcileResult{Checked: 1, Removed: 1}); result != want {
+		t.Errorf("Expected %+v, got %+v", want, result)
+	}
+}
@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/usage"
+)
+
+// recordDigestRun stores the message IDs of a digest run so it can be collapsed the next day
+func (s *Scheduler) recordDigestRun(messages []database.DigestMessage, flagged int, runAt time.Time) {
+	if s.db == nil || len(messages) == 0 {
+		return
+	}
+
+	if _, err := s.db.StoreDigestRun(s.authorizedUserID, flagged, messages, runAt); err != nil {
+		log.Printf("Warning: Failed to record digest run: %v", err)
+	}
+}
+
+// collapsePreviousDigests edits digests from previous days down to a single-line summary.
+// Messages that are too old to edit or delete are skipped silently.
+func (s *Scheduler) collapsePreviousDigests(now time.Time) {
+	if s.db == nil {
+		return
+	}
+
+	// Only digests from previous days are collapsed, today's runs stay expanded
+	localNow := now.In(s.timezone)
+	startOfDay := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, s.timezone)
+
+	runs, err := s.db.GetUncollapsedDigestRuns(s.authorizedUserID, startOfDay)
+	if err != nil {
+		log.Printf("Warning: Failed to load previous digests: %v", err)
+		return
+	}
+
+	for _, run := range runs {
+		summary := s.digestSummary(run)
+		headerEdited := false
+
+		for _, msg := range run.Messages {
+			if msg.Kind == database.DigestMessageHeader && !headerEdited {
+				edit := tgbotapi.NewEditMessageText(run.ChatID, msg.MessageID, summary)
+				edit.ParseMode = tgbotapi.ModeHTML
+				edit.DisableWebPagePreview = true
+				if _, err := s.bot.Request(edit); err != nil {
+					log.Printf("Could not collapse digest header %d: %v", msg.MessageID, err)
+				}
+				headerEdited = true
+				continue
+			}
+
+			if _, err := s.bot.Request(tgbotapi.NewDeleteMessage(run.ChatID, msg.MessageID)); err != nil {
+				log.Printf("Could not remove digest message %d: %v", msg.MessageID, err)
+			}
+		}
+
+		// Mark as collapsed even on failure, old messages won't become editable again
+		if err := s.db.MarkDigestRunCollapsed(run.ID); err != nil {
+			log.Printf("Warning: Failed to mark digest run %d collapsed: %v", run.ID, err)
+		}
+	}
+
+	if len(runs) > 0 {
+		log.Printf("Collapsed %d previous digest(s)", len(runs))
+	}
+}
+
+// digestSummary renders the single-line summary a collapsed digest is edited down to
+func (s *Scheduler) digestSummary(run database.DigestRun) string {
+	day := run.CreatedAt.In(s.timezone).Format("02 Jan")
+	expand := "expand in Notion review page"
+
+	if s.notionClient != nil {
+		if dbID := s.notionClient.GetTasksDatabaseID(); dbID != "" {
+			expand = fmt.Sprintf("<a href=\"https://notion.so/%s\">%s</a>", strings.ReplaceAll(dbID, "-", ""), expand)
+		}
+	}
+
+	return fmt.Sprintf("📋 %s: %d flagged — %s", day, run.Flagged, expand)
+}
+
+// sendUsageSummary sends the usage of the current week to the authorized user
+func (s *Scheduler) sendUsageSummary() {
+	if s.db == nil {
+		return
+	}
+
+	text, err := usage.WeekSummary(s.db, time.Now())
+	if err != nil {
+		log.Printf("Warning: Failed to build usage summary: %v", err)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(s.authorizedUserID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	if _, err := s.sendWithRetry(msg); err != nil {
+		log.Printf("Warning: Failed to send usage summary: %v", err)
+	}
+}
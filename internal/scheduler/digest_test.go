@@ -0,0 +1,204 @@
+package scheduler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// telegramCall is a single Bot API call received by fakeTelegram
+type telegramCall struct {
+	Method string
+	Params url.Values
+}
+
+// fakeTelegram is a local Bot API server that records every call
+type fakeTelegram struct {
+	mu            sync.Mutex
+	calls         []telegramCall
+	failMethods   map[string]bool // Methods answered with a Bot API error
+	rateLimited   int             // Number of upcoming messages answered with 429
+	retryAfter    int             // retry_after of the 429 answers, in seconds
+	unavailable   int             // Number of upcoming messages answered with 502 Bad Gateway
+	nextMessageID int
+}
+
+// newFakeTelegram starts a fake Bot API server and returns a BotAPI bound to it
+func newFakeTelegram(t *testing.T) (*fakeTelegram, *tgbotapi.BotAPI) {
+	t.Helper()
+
+	fake := &fakeTelegram{failMethods: make(map[string]bool), nextMessageID: 1000}
+	server := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(server.Close)
+
+	botAPI, err := tgbotapi.NewBotAPIWithClient("test-token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatalf("Failed to create bot API: %v", err)
+	}
+	return fake, botAPI
+}
+
+func (f *fakeTelegram) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	f.mu.Lock()
+	f.calls = append(f.calls, telegramCall{Method: method, Params: r.PostForm})
+	f.nextMessageID++
+	messageID := f.nextMessageID
+	fail := f.failMethods[method]
+	limited := method == "sendMessage" && f.rateLimited > 0
+	if limited {
+		f.rateLimited--
+	}
+	retryAfter := f.retryAfter
+	down := method == "sendMessage" && !limited && f.unavailable > 0
+	if down {
+		f.unavailable--
+	}
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case limited:
+		fmt.Fprintf(w, `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after %d","parameters":{"retry_after":%d}}`, retryAfter, retryAfter)
+	case down:
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, `{"ok":false,"error_code":502,"description":"Bad Gateway"}`)
+	case fail:
+		fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: message can't be edited"}`)
+	case method == "getMe":
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"Test","username":"test_bot"}}`)
+	case method == "sendMessage":
+		fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"date":0,"chat":{"id":%s}}}`, messageID, r.PostForm.Get("chat_id"))
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+// callsTo returns the recorded calls to the given Bot API method
+func (f *fakeTelegram) callsTo(method string) []telegramCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []telegramCall
+	for _, call := range f.calls {
+		if call.Method == method {
+			result = append(result, call)
+		}
+	}
+	return result
+}
+
+// newTestDB opens a database in a temporary directory
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestCollapsePreviousDigests(t *testing.T) {
+	fake, botAPI := newFakeTelegram(t)
+	db := newTestDB(t)
+
+	s := &Scheduler{bot: botAPI, db: db, authorizedUserID: 42, timezone: time.UTC}
+
+	now := time.Date(2024, 10, 24, 23, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+
+	// Yesterday's run should be collapsed, today's should stay untouched
+	s.recordDigestRun([]database.DigestMessage{
+		{MessageID: 11, Kind: database.DigestMessageHeader},
+		{MessageID: 12, Kind: database.DigestMessageBody},
+		{MessageID: 13, Kind: database.DigestMessageFooter},
+	}, 5, yesterday)
+	s.recordDigestRun([]database.DigestMessage{
+		{MessageID: 21, Kind: database.DigestMessageHeader},
+	}, 1, now.Add(-time.Hour))
+
+	s.collapsePreviousDigests(now)
+
+	edits := fake.callsTo("editMessageText")
+	if len(edits) != 1 {
+		t.Fatalf("Expected 1 editMessageText call, got %d", len(edits))
+	}
+	if edits[0].Params.Get("message_id") != "11" || edits[0].Params.Get("chat_id") != "42" {
+		t.Errorf("Edit should target the stored header, got chat=%s message=%s",
+			edits[0].Params.Get("chat_id"), edits[0].Params.Get("message_id"))
+	}
+	if text := edits[0].Params.Get("text"); !strings.HasPrefix(text, "📋 23 Oct: 5 flagged") {
+		t.Errorf("Unexpected summary text: %s", text)
+	}
+
+	deletes := fake.callsTo("deleteMessage")
+	if len(deletes) != 2 {
+		t.Fatalf("Expected 2 deleteMessage calls, got %d", len(deletes))
+	}
+	for i, expected := range []string{"12", "13"} {
+		if deletes[i].Params.Get("message_id") != expected {
+			t.Errorf("Expected deletion of message %s, got %s", expected, deletes[i].Params.Get("message_id"))
+		}
+	}
+
+	// A second pass must not touch the collapsed run again
+	s.collapsePreviousDigests(now)
+	if len(fake.callsTo("editMessageText")) != 1 {
+		t.Error("Collapsed digest was edited twice")
+	}
+}
+
+func TestCollapsePreviousDigestsToleratesFailures(t *testing.T) {
+	fake, botAPI := newFakeTelegram(t)
+	fake.failMethods["editMessageText"] = true
+	fake.failMethods["deleteMessage"] = true
+	db := newTestDB(t)
+
+	s := &Scheduler{bot: botAPI, db: db, authorizedUserID: 42, timezone: time.UTC}
+
+	now := time.Date(2024, 10, 24, 23, 0, 0, 0, time.UTC)
+	s.recordDigestRun([]database.DigestMessage{
+		{MessageID: 11, Kind: database.DigestMessageHeader},
+		{MessageID: 12, Kind: database.DigestMessageFooter},
+	}, 2, now.Add(-48*time.Hour))
+
+	s.collapsePreviousDigests(now)
+
+	if len(fake.callsTo("editMessageText")) != 1 || len(fake.callsTo("deleteMessage")) != 1 {
+		t.Fatal("All stored messages should be attempted despite failures")
+	}
+
+	// Too-old messages are given up on instead of being retried every night
+	runs, err := db.GetUncollapsedDigestRuns(42, now)
+	if err != nil {
+		t.Fatalf("Failed to load digest runs: %v", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("Expected failed run to be marked collapsed, %d remain", len(runs))
+	}
+}
+
+func TestCollapsePreviousDigestsWithoutDatabase(t *testing.T) {
+	fake, botAPI := newFakeTelegram(t)
+	s := &Scheduler{bot: botAPI, authorizedUserID: 42, timezone: time.UTC}
+
+	s.collapsePreviousDigests(time.Now())
+	s.recordDigestRun([]database.DigestMessage{{MessageID: 1, Kind: database.DigestMessageHeader}}, 0, time.Now())
+
+	if len(fake.callsTo("editMessageText")) != 0 {
+		t.Error("No edits expected without a database")
+	}
+}
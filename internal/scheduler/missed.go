@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// storeMissedDigest keeps the rendered messages of a digest Telegram couldn't be reached to
+// send, for the next check to deliver
+func (s *Scheduler) storeMissedDigest(messages []string, runAt time.Time) {
+	if len(messages) == 0 {
+		return
+	}
+	if s.db == nil {
+		log.Printf("Warning: Dropping %d undelivered digest message(s) without a local database", len(messages))
+		return
+	}
+
+	digest := database.MissedDigest{ChatID: s.authorizedUserID, Messages: messages, CreatedAt: runAt}
+	if err := s.db.StoreMissedDigest(digest); err != nil {
+		log.Printf("Warning: Failed to keep the undelivered digest: %v", err)
+		return
+	}
+	log.Printf("Kept %d undelivered digest message(s) for the next check", len(messages))
+}
+
+// flushMissedDigests sends the digests earlier checks couldn't deliver, announced by a
+// notice. It reports false when Telegram still can't be reached, keeping what wasn't sent.
+func (s *Scheduler) flushMissedDigests(now time.Time) bool {
+	if s.db == nil {
+		return true
+	}
+	digests, err := s.db.GetMissedDigests(s.authorizedUserID)
+	if err != nil {
+		log.Printf("Warning: Failed to load missed digests: %v", err)
+		return true
+	}
+	if len(digests) == 0 {
+		return true
+	}
+
+	if _, err := s.sendWithRetry(tgbotapi.NewMessage(s.authorizedUserID, missedDigestsNotice(digests, now.In(s.timezone)))); err != nil {
+		log.Printf("Error sending the missed digests notice: %v", err)
+		return !isTransientSendError(err)
+	}
+
+	for _, digest := range digests {
+		for i, text := range digest.Messages {
+			msg := tgbotapi.NewMessage(s.authorizedUserID, text)
+			msg.ParseMode = tgbotapi.ModeHTML
+			msg.DisableWebPagePreview = true
+			_, err := s.sendWithRetry(msg)
+			if err == nil {
+				continue
+			}
+
+			log.Printf("Error sending missed digest %d: %v", digest.ID, err)
+			if isTransientSendError(err) {
+				// Only the messages not sent yet are tried again
+				if err := s.db.SetMissedDigestMessages(digest.ID, digest.Messages[i:]); err != nil {
+					log.Printf("Warning: Failed to update missed digest %d: %v", digest.ID, err)
+				}
+				return false
+			}
+		}
+		if err := s.db.DeleteMissedDigest(digest.ID); err != nil {
+			log.Printf("Warning: Failed to delete missed digest %d: %v", digest.ID, err)
+		}
+	}
+	log.Printf("Delivered %d missed digest(s)", len(digests))
+	return true
+}
+
+// missedDigestsNotice introduces the missed digests, naming the day of the oldest
+func missedDigestsNotice(digests []database.MissedDigest, now time.Time) string {
+	oldest := digests[0].CreatedAt.In(now.Location())
+	day := "from " + oldest.Format("Mon 02 Jan")
+	switch oldest.Format("2006-01-02") {
+	case now.Format("2006-01-02"):
+		day = "from earlier today"
+	case now.AddDate(0, 0, -1).Format("2006-01-02"):
+		day = "from yesterday"
+	}
+
+	if len(digests) == 1 {
+		return fmt.Sprintf("📬 You missed 1 digest %s, Telegram couldn't be reached. Here it is:", day)
+	}
+	return fmt.Sprintf("📬 You missed %d digests, the first %s, Telegram couldn't be reached. Here they are:", len(digests), day)
+}
@@ -0,0 +1,61 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// queryResults answers every Notion request with the same database query results
+type queryResults string
+
+func (r queryResults) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"object": "list", "results": ` + string(r) + `, "has_more": false}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestOverdueSection(t *testing.T) {
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	moscow := time.FixedZone("MSK", 3*60*60)
+	s := &Scheduler{
+		authorizedUserID: 42,
+		timezone:         moscow,
+		notionClient: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: queryResults(`[
+			{"object": "page", "id": "task-1", "properties": {
+				"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Pay <rent>"}, "plain_text": "Pay <rent>"}]},
+				"Date": {"id": "date", "type": "date", "date": {"start": "2024-03-14"}}
+			}},
+			{"object": "page", "id": "task-2", "properties": {
+				"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Due today"}, "plain_text": "Due today"}]},
+				"Date": {"id": "date", "type": "date", "date": {"start": "2024-03-15"}}
+			}}
+		]`)})),
+	}
+
+	// At 00:30 MSK the task due today must not be reported, even though it's still the 14th in UTC
+	section, err := s.overdueSection(context.Background(), time.Date(2024, 3, 14, 21, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("overdueSection failed: %v", err)
+	}
+	if section.total != 1 {
+		t.Fatalf("Expected 1 overdue task, got %d", section.total)
+	}
+
+	text := strings.Join(section.lines(), "\n")
+	if !strings.Contains(text, "⏰") || !strings.Contains(text, "Pay &lt;rent&gt;") || !strings.Contains(text, "due 14 Mar") {
+		t.Errorf("Unexpected overdue section: %q", text)
+	}
+	if strings.Contains(text, "Due today") {
+		t.Errorf("Task due today reported as overdue: %q", text)
+	}
+}
@@ -0,0 +1,20 @@
+package scheduler
+
+import (
+	"log"
+	"time"
+)
+
+// pausedUntil reports whether the user paused notifications at now with /pause, and until
+// when. Without the local database nothing is ever paused.
+func (s *Scheduler) pausedUntil(now time.Time) (time.Time, bool) {
+	if s.db == nil {
+		return time.Time{}, false
+	}
+	until, err := s.db.GetPausedUntil(s.authorizedUserID)
+	if err != nil {
+		log.Printf("Warning: Could not read whether notifications are paused: %v", err)
+		return time.Time{}, false
+	}
+	return until, now.Before(until)
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// reconcileInterval spaces the Notion calls of a reconciliation pass, so walking every
+// recorded task leaves most of the rate limit to the bot and the mini app
+const reconcileInterval = time.Second
+
+// ReconcileResult counts what a reconciliation pass did
+type ReconcileResult struct {
+	Checked int // Recorded tasks looked up in Notion
+	Removed int // Records dropped because their page was deleted or archived
+	Skipped int // Records kept because Notion couldn't be asked about them
+}
+
+// Reconcile walks every task recorded for the scheduler's workspace and drops the records
+// of those deleted or archived in Notion. Tasks Notion fails to answer about are kept for
+// the next pass.
+func (s *Scheduler) Reconcile(ctx context.Context) (ReconcileResult, error) {
+	var result ReconcileResult
+	if s.db == nil {
+		return result, errors.New("reconciliation needs the bot's local database")
+	}
+	if !s.reconcileMu.TryLock() {
+		return result, errors.New("a reconciliation is already running")
+	}
+	defer s.reconcileMu.Unlock()
+
+	ids, err := s.db.GetTaskIDs(s.workspace)
+	if err != nil {
+		return result, err
+	}
+
+	var pace <-chan time.Time
+	if s.reconcilePace > 0 {
+		ticker := time.NewTicker(s.reconcilePace)
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	for i, id := range ids {
+		if i > 0 && pace != nil {
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-pace:
+			}
+		}
+
+		exists, _, err := s.checkTaskInNotion(ctx, id)
+		result.Checked++
+		if err != nil {
+			log.Printf("Warning: Could not check whether task %s still exists: %v", id, err)
+			result.Skipped++
+			continue
+		}
+		if !exists {
+			s.forgetTask(id)
+			result.Removed++
+		}
+	}
+	return result, nil
+}
+
+// RunReconcile starts a reconciliation pass and sends its report to the authorized user
+func (s *Scheduler) RunReconcile() {
+	log.Printf("Manual reconciliation triggered")
+	go s.runReconcile(context.Background(), true)
+}
+
+// runReconcile runs a reconciliation pass, sending its report when asked to
+func (s *Scheduler) runReconcile(ctx context.Context, report bool) {
+	result, err := s.Reconcile(ctx)
+	if err != nil {
+		log.Printf("Error reconciling recorded tasks: %v", err)
+		if report {
+			s.sendWithRetry(tgbotapi.NewMessage(s.authorizedUserID, fmt.Sprintf("❌ Reconciliation failed: %v", err)))
+		}
+		return
+	}
+
+	log.Printf("Reconciliation checked %d recorded task(s): %d removed, %d skipped", result.Checked, result.Removed, result.Skipped)
+	if !report {
+		return
+	}
+	text := fmt.Sprintf("🧹 Checked %d recorded task(s), removed %d deleted in Notion.", result.Checked, result.Removed)
+	if result.Skipped > 0 {
+		text += fmt.Sprintf(" %d couldn't be checked and were kept.", result.Skipped)
+	}
+	s.sendWithRetry(tgbotapi.NewMessage(s.authorizedUserID, text))
+}
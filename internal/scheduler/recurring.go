@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// recurrenceInterval is how often the recurrence pass looks for done repeating tasks
+	recurrenceInterval = 15 * time.Minute
+	// recurrenceWindow is how far back the pass looks, covering a bot down for a few days
+	recurrenceWindow = 7 * 24 * time.Hour
+	// maxRecurringTasks caps the done repeating tasks handled by a pass
+	maxRecurringTasks = 100
+)
+
+// recurrence is how often a task repeats
+type recurrence string
+
+const (
+	repeatDaily   recurrence = "daily"
+	repeatWeekly  recurrence = "weekly"
+	repeatMonthly recurrence = "monthly"
+)
+
+// parseRecurrence parses the value of a task's Repeat property, ignoring case
+func parseRecurrence(value string) (recurrence, error) {
+	switch r := recurrence(strings.ToLower(strings.TrimSpace(value))); r {
+	case repeatDaily, repeatWeekly, repeatMonthly:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown Repeat %q, expected daily, weekly or monthly", value)
+}
+
+// nextOccurrence returns the day after due the task recurs on. Monthly tasks keep their
+// day of the month, clamped to the last day of shorter months: Jan 31 recurs on Feb 28.
+func nextOccurrence(due time.Time, r recurrence) time.Time {
+	switch r {
+	case repeatDaily:
+		return due.AddDate(0, 0, 1)
+	case repeatWeekly:
+		return due.AddDate(0, 0, 7)
+	}
+	first := time.Date(due.Year(), due.Month()+1, 1, 0, 0, 0, 0, due.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(due.Day(), lastDay), 0, 0, 0, 0, due.Location())
+}
+
+// recurrenceDue reports whether the recurrence pass should run at now
+func (s *Scheduler) recurrenceDue(now time.Time) bool {
+	if now.Sub(s.lastRecurrence) < recurrenceInterval {
+		return false
+	}
+	s.lastRecurrence = now
+	return true
+}
+
+// spawnRecurringTasks creates the next occurrence of each done task with a Repeat value,
+// then clears Repeat on the done task so only the new one carries it. An occurrence is
+// created once per task and due date, even when clearing Repeat failed.
+func (s *Scheduler) spawnRecurringTasks(ctx context.Context) {
+	// A slow pass must not overlap the next one and spawn twice
+	if !s.recurrenceMu.TryLock() {
+		return
+	}
+	defer s.recurrenceMu.Unlock()
+
+	tasks, err := s.notionClient.GetDoneRepeatingTasks(ctx, time.Now().Add(-recurrenceWindow), maxRecurringTasks)
+	if err != nil {
+		log.Printf("Error retrieving done repeating tasks: %v", err)
+		return
+	}
+
+	spawned := 0
+	for _, task := range tasks {
+		repeatKey, value := propertyFold(task, notion.RepeatPropertyKey)
+		repeat, _ := value.(string)
+		r, err := parseRecurrence(repeat)
+		if err != nil {
+			log.Printf("Warning: Not repeating task %s: %v", task.ID, err)
+			continue
+		}
+		due, _ := task.Properties["Date"].(string)
+		if s.recurrenceSpawned(task.ID, due) {
+			continue
+		}
+
+		from, ok := notion.DueDay(task, s.timezone)
+		if !ok {
+			// Without a date the task recurs from the day it was done
+			now := time.Now().In(s.timezone)
+			from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.timezone)
+		}
+		next := nextOccurrence(from, r).Format("2006-01-02")
+
+		spawnedID, err := s.notionClient.CreateTask(notion.WithNewOptions(ctx), task.Title, occurrenceProperties(task, repeatKey, next), "tasks")
+		if err != nil {
+			log.Printf("Error creating the next occurrence of task %s: %v", task.ID, err)
+			continue
+		}
+		s.recordRecurrence(task.ID, due, spawnedID)
+		spawned++
+
+		if err := s.notionClient.UpdateTask(ctx, task.ID, "", map[string]interface{}{repeatKey: nil}); err != nil {
+			log.Printf("Warning: Could not clear Repeat of task %s: %v", task.ID, err)
+		}
+	}
+	if spawned > 0 {
+		log.Printf("Created the next occurrence of %d repeating task(s)", spawned)
+	}
+}
+
+// occurrenceProperties returns the properties of the next occurrence of a task: its tags,
+// project and Repeat, due on next
+func occurrenceProperties(task notion.Task, repeatKey, next string) map[string]interface{} {
+	properties := map[string]interface{}{"Date": next}
+	if repeat, ok := task.Properties[repeatKey]; ok {
+		properties[repeatKey] = repeat
+	}
+	for _, name := range []string{"tags", "project"} {
+		key, value := propertyFold(task, name)
+		values, ok := value.([]string)
+		if !ok || len(values) == 0 {
+			continue
+		}
+		// Lists are given to CreateTask as decoded from JSON
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		properties[key] = items
+	}
+	return properties
+}
+
+// propertyFold returns the key and value of a task property, matching its name ignoring case
+func propertyFold(task notion.Task, name string) (string, interface{}) {
+	if value, ok := task.Properties[name]; ok {
+		return name, value
+	}
+	for key, value := range task.Properties {
+		if strings.EqualFold(key, name) {
+			return key, value
+		}
+	}
+	return name, nil
+}
+
+// recurrenceSpawned reports whether the next occurrence of a task due at due was created,
+// in the local database when there is one. It is assumed created when the database can't
+// be read, rather than risking duplicates every pass.
+func (s *Scheduler) recurrenceSpawned(taskID, due string) bool {
+	if s.db == nil {
+		s.spawnedMu.Lock()
+		defer s.spawnedMu.Unlock()
+		return s.spawned[taskID+" "+due]
+	}
+	spawned, err := s.db.RecurrenceSpawned(taskID, due)
+	if err != nil {
+		log.Printf("Warning: Could not check the recurrence of task %s: %v", taskID, err)
+		return true
+	}
+	return spawned
+}
+
+// recordRecurrence remembers that the next occurrence of a task due at due was created
+func (s *Scheduler) recordRecurrence(taskID, due, spawnedID string) {
+	if s.db == nil {
+		s.spawnedMu.Lock()
+		defer s.spawnedMu.Unlock()
+		if s.spawned == nil {
+			s.spawned = make(map[string]bool)
+		}
+		s.spawned[taskID+" "+due] = true
+		return
+	}
+	if err := s.db.RecordRecurrence(taskID, due, spawnedID, time.Now()); err != nil {
+		log.Printf("Warning: Could not record the recurrence of task %s: %v", taskID, err)
+	}
+}
@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+func TestNextOccurrence(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		due    time.Time
+		repeat recurrence
+		want   time.Time
+	}{
+		{"daily", day(2024, 3, 15), repeatDaily, day(2024, 3, 16)},
+		{"daily across months", day(2024, 2, 29), repeatDaily, day(2024, 3, 1)},
+		{"weekly", day(2024, 3, 15), repeatWeekly, day(2024, 3, 22)},
+		{"weekly across years", day(2024, 12, 28), repeatWeekly, day(2025, 1, 4)},
+		{"monthly", day(2024, 3, 15), repeatMonthly, day(2024, 4, 15)},
+		{"monthly to a shorter month", day(2023, 1, 31), repeatMonthly, day(2023, 2, 28)},
+		{"monthly to a leap February", day(2024, 1, 31), repeatMonthly, day(2024, 2, 29)},
+		{"monthly to a 30-day month", day(2024, 3, 31), repeatMonthly, day(2024, 4, 30)},
+		{"monthly across years", day(2024, 12, 31), repeatMonthly, day(2025, 1, 31)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextOccurrence(tt.due, tt.repeat); !got.Equal(tt.want) {
+				t.Errorf("Expected %s, got %s", tt.want.Format("2006-01-02"), got.Format("2006-01-02"))
+			}
+		})
+	}
+
+	for value, want := range map[string]recurrence{"daily": repeatDaily, " Weekly": repeatWeekly, "MONTHLY": repeatMonthly} {
+		if got, err := parseRecurrence(value); err != nil || got != want {
+			t.Errorf("parseRecurrence(%q) = %q, %v, expected %q", value, got, err, want)
+		}
+	}
+	if _, err := parseRecurrence("yearly"); err == nil {
+		t.Error("Expected an unknown Repeat to fail")
+	}
+}
+
+// recurringNotion serves a done task repeating monthly and records the pages created and
+// updated
+type recurringNotion struct {
+	mu      sync.Mutex
+	created []map[string]json.RawMessage
+	updated []string
+}
+
+func (n *recurringNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := `{"object": "page", "id": "task-2"}`
+	switch {
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/v1/databases/"):
+		body = `{"object": "database", "id": "tasks-db", "properties": {
+			"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+			"status": {"id": "st", "name": "status", "type": "select", "select": {"options": [{"name": "done"}]}},
+			"Date": {"id": "date", "name": "Date", "type": "date", "date": {}},
+			"Tags": {"id": "tags", "name": "Tags", "type": "multi_select", "multi_select": {"options": [{"name": "home"}]}},
+			"Project": {"id": "proj", "name": "Project", "type": "relation", "relation": {"database_id": "projects-db"}},
+			"Repeat": {"id": "rep", "name": "Repeat", "type": "select", "select": {"options": [{"name": "monthly"}]}}
+		}}`
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/query"):
+		body = `{"object": "list", "has_more": false, "results": [{"object": "page", "id": "task-1", "properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Pay rent"}, "plain_text": "Pay rent"}]},
+			"status": {"id": "st", "type": "select", "select": {"name": "done"}},
+			"Date": {"id": "date", "type": "date", "date": {"start": "2024-01-31"}},
+			"Tags": {"id": "tags", "type": "multi_select", "multi_select": [{"name": "home"}]},
+			"Project": {"id": "proj", "type": "relation", "relation": [{"id": "01234567-89ab-cdef-0123-456789abcdef"}]},
+			"Repeat": {"id": "rep", "type": "select", "select": {"name": "monthly"}}
+		}}]}`
+	case req.Method == http.MethodPost && req.URL.Path == "/v1/pages":
+		var page struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		}
+		data, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		n.mu.Lock()
+		n.created = append(n.created, page.Properties)
+		n.mu.Unlock()
+	case req.Method == http.MethodPatch:
+		data, _ := io.ReadAll(req.Body)
+		n.mu.Lock()
+		n.updated = append(n.updated, req.URL.Path+" "+string(data))
+		n.mu.Unlock()
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestRecurringTasksAreSpawnedOnce(t *testing.T) {
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	fake := &recurringNotion{}
+	newScheduler := func() *Scheduler {
+		return &Scheduler{
+			authorizedUserID: 42,
+			timezone:         time.UTC,
+			db:               db,
+			notionClient:     notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: fake})),
+		}
+	}
+
+	s := newScheduler()
+	s.spawnRecurringTasks(context.Background())
+	// Notion still lists the task as repeating, as if clearing Repeat had failed
+	s.spawnRecurringTasks(context.Background())
+	// A restarted bot reads what was spawned from the database
+	newScheduler().spawnRecurringTasks(context.Background())
+
+	if len(fake.created) != 1 {
+		t.Fatalf("Expected a single occurrence, got %d", len(fake.created))
+	}
+	created := fake.created[0]
+	for key, want := range map[string]string{
+		"Date":    `"start":"2024-02-29`,
+		"Tags":    `"name":"home"`,
+		"Project": `"id":"01234567-89ab-cdef-0123-456789abcdef"`,
+		"Repeat":  `"name":"monthly"`,
+	} {
+		if !strings.Contains(string(created[key]), want) {
+			t.Errorf("Expected %s to contain %s, got %s", key, want, created[key])
+		}
+	}
+	if len(fake.updated) == 0 || !strings.HasPrefix(fake.updated[0], "/v1/pages/task-1 ") || !strings.Contains(fake.updated[0], `"Repeat":{"select":null}`) {
+		t.Errorf("Expected Repeat cleared on the done task, got %v", fake.updated)
+	}
+}
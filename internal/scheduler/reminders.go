@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// reminderInterval is how often the reminder pass looks for tasks coming due
+	reminderInterval = 15 * time.Minute
+	// reminderRetention is how long sent reminders are remembered, well past any lead
+	reminderRetention = 30 * 24 * time.Hour
+)
+
+// parseReminderLead parses REMINDER_LEAD, how long before a task is due it is reminded
+// about, like "24h". Empty or zero disables reminders.
+func parseReminderLead(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	lead, err := time.ParseDuration(value)
+	if err != nil || lead < 0 {
+		return 0, fmt.Errorf("invalid reminder lead %q, expected a duration like \"24h\"", value)
+	}
+	return lead, nil
+}
+
+// remindersDue reports whether the reminder pass should run at now
+func (s *Scheduler) remindersDue(now time.Time) bool {
+	if s.reminderLead == 0 || now.Sub(s.lastReminders) < reminderInterval {
+		return false
+	}
+	s.lastReminders = now
+	return true
+}
+
+// sendReminders messages the user about each task due within the reminder lead that
+// wasn't reminded about yet. A task is reminded again when its date changes.
+func (s *Scheduler) sendReminders(ctx context.Context) {
+	// A slow pass must not overlap the next one and remind twice
+	if !s.remindersMu.TryLock() {
+		return
+	}
+	defer s.remindersMu.Unlock()
+
+	tasks, err := s.notionClient.GetTasksDueWithin(ctx, s.reminderLead)
+	if err != nil {
+		log.Printf("Error retrieving tasks due soon: %v", err)
+		return
+	}
+
+	sent := 0
+	for _, task := range tasks {
+		due, _ := task.Properties["Date"].(string)
+		if s.reminderSent(task.ID, due) {
+			continue
+		}
+
+		msg := tgbotapi.NewMessage(s.authorizedUserID, reminderText(task, s.timezone))
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+		if _, err := s.sendWithRetry(msg); err != nil {
+			log.Printf("Error sending reminder for task %s: %v", task.ID, err)
+			continue
+		}
+		s.recordReminder(task.ID, due)
+		sent++
+	}
+
+	if s.db != nil {
+		if err := s.db.DeleteRemindersBefore(time.Now().Add(-reminderRetention)); err != nil {
+			log.Printf("Warning: Could not prune old reminders: %v", err)
+		}
+	}
+	if sent > 0 {
+		log.Printf("Sent %d reminder(s) for tasks due within %v", sent, s.reminderLead)
+	}
+}
+
+// reminderSent reports whether a task due at due was reminded about, in the local database
+// when there is one. Tasks are assumed reminded when it can't be read, rather than
+// reminding about them every pass.
+func (s *Scheduler) reminderSent(taskID, due string) bool {
+	if s.db == nil {
+		s.remindedMu.Lock()
+		defer s.remindedMu.Unlock()
+		return s.reminded[taskID+" "+due]
+	}
+	sent, err := s.db.ReminderSent(taskID, due)
+	if err != nil {
+		log.Printf("Warning: Could not check the reminder of task %s: %v", taskID, err)
+		return true
+	}
+	return sent
+}
+
+// recordReminder remembers that a task due at due was reminded about
+func (s *Scheduler) recordReminder(taskID, due string) {
+	if s.db == nil {
+		s.remindedMu.Lock()
+		defer s.remindedMu.Unlock()
+		if s.reminded == nil {
+			s.reminded = make(map[string]bool)
+		}
+		s.reminded[taskID+" "+due] = true
+		return
+	}
+	if err := s.db.RecordReminder(taskID, due, time.Now()); err != nil {
+		log.Printf("Warning: Could not record the reminder of task %s: %v", taskID, err)
+	}
+}
+
+// reminderText renders the reminder of a task, with when it is due in loc
+func reminderText(task notion.Task, loc *time.Location) string {
+	due := "soon"
+	if value, ok := task.Properties["Date"].(string); ok {
+		if at, err := time.Parse(time.RFC3339, value); err == nil {
+			// Dates without a time are parsed as midnight UTC
+			if at.Location() == time.UTC && at.Equal(at.Truncate(24*time.Hour)) {
+				due = at.Format("Mon, 02 Jan")
+			} else {
+				due = at.In(loc).Format("Mon, 02 Jan 15:04")
+			}
+		}
+	}
+	text := fmt.Sprintf("🔔 <b>Due %s</b>\n\n%s", due, taskLink(task))
+	if assignee := assigneeNames(task); assignee != "" {
+		text += "\nAssignee: " + html.EscapeString(assignee)
+	}
+	return text
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// dueTask is a query result with a task due at the given date
+func dueTask(id, date string) queryResults {
+	return queryResults(fmt.Sprintf(`[{"object": "page", "id": %q, "properties": {
+		"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "Pay rent"}, "plain_text": "Pay rent"}]},
+		"Date": {"id": "date", "type": "date", "date": {"start": %q}}
+	}}]`, id, date))
+}
+
+func TestRemindersAreSentOncePerDueDate(t *testing.T) {
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	telegram, botAPI := newFakeTelegram(t)
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	newScheduler := func(results queryResults) *Scheduler {
+		return &Scheduler{
+			bot:              botAPI,
+			authorizedUserID: 42,
+			timezone:         time.UTC,
+			db:               db,
+			reminderLead:     24 * time.Hour,
+			notionClient:     notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: results})),
+		}
+	}
+
+	due := time.Now().Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	s := newScheduler(dueTask("task-1", due))
+	s.sendReminders(context.Background())
+	s.sendReminders(context.Background())
+
+	// A restarted bot reads what was sent from the database
+	newScheduler(dueTask("task-1", due)).sendReminders(context.Background())
+
+	calls := telegram.callsTo("sendMessage")
+	if len(calls) != 1 {
+		t.Fatalf("Expected a single reminder, got %d", len(calls))
+	}
+	if text := calls[0].Params.Get("text"); !strings.Contains(text, "Due ") || !strings.Contains(text, "Pay rent") {
+		t.Errorf("Unexpected reminder: %q", text)
+	}
+
+	// Moving the task to another time reminds again
+	later := time.Now().Add(5 * time.Hour).UTC().Format(time.RFC3339)
+	newScheduler(dueTask("task-1", later)).sendReminders(context.Background())
+	if calls := telegram.callsTo("sendMessage"); len(calls) != 2 {
+		t.Errorf("Expected a new reminder for the new date, got %d messages", len(calls))
+	}
+}
+
+func TestRemindersWithoutDatabaseAreRememberedInMemory(t *testing.T) {
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+	telegram, botAPI := newFakeTelegram(t)
+	s := &Scheduler{
+		bot:              botAPI,
+		authorizedUserID: 42,
+		timezone:         time.UTC,
+		reminderLead:     time.Hour,
+		notionClient: notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: dueTask("task-1",
+			time.Now().Add(30*time.Minute).UTC().Format(time.RFC3339))})),
+	}
+
+	s.sendReminders(context.Background())
+	s.sendReminders(context.Background())
+	if calls := telegram.callsTo("sendMessage"); len(calls) != 1 {
+		t.Errorf("Expected a single reminder, got %d", len(calls))
+	}
+}
+
+func TestParseReminderLead(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 0, "24h": 24 * time.Hour, " 90m ": 90 * time.Minute, "0": 0} {
+		if got, err := parseReminderLead(value); err != nil || got != want {
+			t.Errorf("parseReminderLead(%q) = %v, %v, expected %v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"tomorrow", "-1h"} {
+		if _, err := parseReminderLead(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// telegramMessageLimit is the most characters Telegram accepts in a message. Telegram
+	// counts UTF-16 code units of the text without markup, measuring the HTML is stricter.
+	telegramMessageLimit = 4096
+	// maxDigestTitle is how many characters of a task title the digest shows
+	maxDigestTitle = 50
+)
+
+// digestSection is a group of flagged tasks in the daily digest, rendered as a heading
+// with the count followed by one bullet per task
+type digestSection struct {
+	heading string   // HTML heading, the count is appended
+	hint    string   // What to do about the tasks, optional
+	items   []string // HTML lines without the bullet
+	total   int      // Number of tasks, more than the items when the list is capped
+	more    string   // Line shown when only some of the tasks are listed
+}
+
+// add lists a task in the section
+func (d *digestSection) add(item string) {
+	d.items = append(d.items, item)
+	d.total++
+}
+
+// lines renders the section, nothing when it has no tasks
+func (d digestSection) lines() []string {
+	if d.total == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("%s (%d)", d.heading, d.total)}
+	if d.hint != "" {
+		lines = append(lines, "<i>"+html.EscapeString(d.hint)+"</i>")
+	}
+	for _, item := range d.items {
+		lines = append(lines, "• "+item)
+	}
+	if d.total > len(d.items) && d.more != "" {
+		lines = append(lines, d.more)
+	}
+	return lines
+}
+
+// notionPageURL returns the link to a Notion page, Notion URLs have no hyphens in the ID
+func notionPageURL(pageID string) string {
+	return fmt.Sprintf("https://notion.so/%s", strings.ReplaceAll(pageID, "-", ""))
+}
+
+// taskLink renders a task as its truncated title linking to the page
+func taskLink(task notion.Task) string {
+	title := task.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	return fmt.Sprintf("<a href=\"%s\">%s</a>", notionPageURL(task.ID), html.EscapeString(truncateString(title, maxDigestTitle)))
+}
+
+// taskEntry renders a task of the digest as its link followed by who it's assigned to
+func taskEntry(task notion.Task) string {
+	if assignee := assigneeNames(task); assignee != "" {
+		return taskLink(task) + " — " + html.EscapeString(assignee)
+	}
+	return taskLink(task)
+}
+
+// renderDigest lays the header, the non-empty sections and the footer out as messages of
+// at most limit characters
+func renderDigest(header string, sections []digestSection, footer string, limit int) []string {
+	lines := strings.Split(header, "\n")
+	for _, section := range sections {
+		if sectionLines := section.lines(); len(sectionLines) > 0 {
+			lines = append(lines, "")
+			lines = append(lines, sectionLines...)
+		}
+	}
+	lines = append(lines, "")
+	lines = append(lines, strings.Split(footer, "\n")...)
+	return splitMessages(lines, limit)
+}
+
+// splitMessages packs lines into as few messages of at most limit characters as possible,
+// breaking only between lines. Blank lines aren't carried over to the start of a message.
+// A line longer than the limit gets a message of its own, digest lines are far shorter.
+func splitMessages(lines []string, limit int) []string {
+	var messages []string
+	var current strings.Builder
+	currentLen := 0
+
+	for _, line := range lines {
+		lineLen := utf16Len(line)
+		if currentLen > 0 && currentLen+1+lineLen > limit {
+			messages = append(messages, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+			currentLen = 0
+		}
+		if currentLen == 0 {
+			if line == "" {
+				continue
+			}
+		} else {
+			current.WriteString("\n")
+			currentLen++
+		}
+		current.WriteString(line)
+		currentLen += lineLen
+	}
+	if currentLen > 0 {
+		messages = append(messages, strings.TrimRight(current.String(), "\n"))
+	}
+	return messages
+}
+
+// utf16Len returns the length of s as Telegram counts it
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
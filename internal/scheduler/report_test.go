@@ -0,0 +1,174 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+func TestDigestSectionLines(t *testing.T) {
+	section := digestSection{heading: "🔗 <b>Link-only tasks</b>", hint: "Give them a <name>."}
+	if lines := section.lines(); lines != nil {
+		t.Errorf("Expected an empty section to render nothing, got %q", lines)
+	}
+
+	section.add(taskLink(notion.Task{ID: "a-b-c", Title: "https://example.com"}))
+	section.add(taskLink(notion.Task{ID: "d-e-f"}))
+	want := []string{
+		"🔗 <b>Link-only tasks</b> (2)",
+		"<i>Give them a &lt;name&gt;.</i>",
+		`• <a href="https://notion.so/abc">https://example.com</a>`,
+		`• <a href="https://notion.so/def">Untitled</a>`,
+	}
+	if got := section.lines(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	// Capped sections end with the trailer
+	section.total = 5
+	section.more = "…and 3 more"
+	if lines := section.lines(); lines[len(lines)-1] != "…and 3 more" || !strings.Contains(lines[0], "(5)") {
+		t.Errorf("Expected the total and the trailer, got %q", lines)
+	}
+}
+
+func TestTaskLinkTruncatesAndEscapes(t *testing.T) {
+	title := strings.Repeat("ж", 48) + "<b>&"
+	link := taskLink(notion.Task{ID: "page-1", Title: title})
+
+	want := `<a href="https://notion.so/page1">` + strings.Repeat("ж", 48) + "&lt;b...</a>"
+	if link != want {
+		t.Errorf("Expected %q, got %q", want, link)
+	}
+}
+
+func TestSplitMessages(t *testing.T) {
+	if got := splitMessages([]string{"header", "", "a", "b", "", "footer"}, 100); len(got) != 1 || got[0] != "header\n\na\nb\n\nfooter" {
+		t.Errorf("Expected one message, got %q", got)
+	}
+
+	// Each line is 10 characters, so 8 lines with their newlines take 87 of the limit
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("line %05d", i))
+	}
+	got := splitMessages(lines, 95)
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 messages, got %d: %q", len(got), got)
+	}
+	for i, message := range got {
+		if utf16Len(message) > 95 {
+			t.Errorf("Message %d is %d characters long", i, utf16Len(message))
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(lines, "\n") {
+		t.Error("Expected every line in order")
+	}
+
+	// An exact fit stays in one message
+	if got := splitMessages(lines[:2], 21); len(got) != 1 {
+		t.Errorf("Expected 21 characters to fit, got %q", got)
+	}
+	if got := splitMessages(lines[:2], 20); len(got) != 2 {
+		t.Errorf("Expected 21 characters to be split, got %q", got)
+	}
+
+	// Emoji count twice and blank lines don't start a message
+	got = splitMessages([]string{"📔📔📔", "", "next"}, 7)
+	if len(got) != 2 || got[0] != "📔📔📔" || got[1] != "next" {
+		t.Errorf("Expected the blank line to be dropped at the split, got %q", got)
+	}
+}
+
+func TestRenderDigestSplitsLongDigests(t *testing.T) {
+	journal := digestSection{heading: "📔 <b>Possible journal entries</b>"}
+	for i := 0; i < 100; i++ {
+		journal.add(taskLink(notion.Task{ID: fmt.Sprintf("page-%d", i), Title: strings.Repeat("x", 60)}))
+	}
+	empty := digestSection{heading: "🔗 <b>Link-only tasks</b>"}
+
+	messages := renderDigest("header", []digestSection{journal, empty}, "footer", telegramMessageLimit)
+	if len(messages) < 2 {
+		t.Fatalf("Expected 100 tasks not to fit in one message, got %d", len(messages))
+	}
+	for i, message := range messages {
+		if utf16Len(message) > telegramMessageLimit {
+			t.Errorf("Message %d is %d characters long", i, utf16Len(message))
+		}
+	}
+	all := strings.Join(messages, "\n")
+	if !strings.HasPrefix(messages[0], "header\n\n📔") || !strings.HasSuffix(messages[len(messages)-1], "footer") {
+		t.Errorf("Expected the header first and the footer last, got %q … %q", messages[0][:20], messages[len(messages)-1])
+	}
+	if strings.Count(all, "• ") != 100 || strings.Contains(all, "Link-only") {
+		t.Errorf("Expected the 100 tasks and no empty section")
+	}
+}
+
+func TestSendWithRetryOnRateLimit(t *testing.T) {
+	telegram, botAPI := newFakeTelegram(t)
+	telegram.rateLimited = 2
+	s := &Scheduler{bot: botAPI, authorizedUserID: 42}
+
+	sent, err := s.sendWithRetry(tgbotapi.NewMessage(42, "digest"))
+	if err != nil || sent.MessageID == 0 {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if calls := len(telegram.callsTo("sendMessage")); calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	// Giving up after the last attempt
+	telegram.rateLimited = 3
+	if _, err := s.sendWithRetry(tgbotapi.NewMessage(42, "digest")); err == nil {
+		t.Error("Expected an error after 3 rate-limited attempts")
+	}
+
+	// Other errors aren't retried
+	telegram.failMethods["sendMessage"] = true
+	before := len(telegram.callsTo("sendMessage"))
+	if _, err := s.sendWithRetry(tgbotapi.NewMessage(42, "digest")); err == nil {
+		t.Error("Expected the error to be returned")
+	}
+	if calls := len(telegram.callsTo("sendMessage")) - before; calls != 1 {
+		t.Errorf("Expected 1 attempt, got %d", calls)
+	}
+}
+
+func TestSendWithRetryWhenTelegramIsDown(t *testing.T) {
+	telegram, botAPI := newFakeTelegram(t)
+	telegram.unavailable = 2
+	s := &Scheduler{bot: botAPI, authorizedUserID: 42}
+
+	sent, err := s.sendWithRetry(tgbotapi.NewMessage(42, "digest"))
+	if err != nil || sent.MessageID == 0 {
+		t.Fatalf("Expected the third attempt to succeed, got %v", err)
+	}
+	if calls := len(telegram.callsTo("sendMessage")); calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+
+	telegram.unavailable = 3
+	if _, err := s.sendWithRetry(tgbotapi.NewMessage(42, "digest")); err == nil || !isTransientSendError(err) {
+		t.Errorf("Expected a transient error after 3 failed attempts, got %v", err)
+	}
+}
+
+func TestSendWithRetryHonorsRetryAfter(t *testing.T) {
+	telegram, botAPI := newFakeTelegram(t)
+	telegram.rateLimited = 1
+	telegram.retryAfter = 1
+	s := &Scheduler{bot: botAPI, authorizedUserID: 42}
+
+	start := time.Now()
+	if _, err := s.sendWithRetry(tgbotapi.NewMessage(42, "digest")); err != nil {
+		t.Fatalf("sendWithRetry failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Expected to wait the second Telegram asked for, waited %v", elapsed)
+	}
+}
@@ -0,0 +1,180 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// defaultWeeklyReviewTime is when the weekly review goes out without WEEKLY_REVIEW_TIME
+	defaultWeeklyReviewTime = "Sun 18:00"
+	// staleTaskAge is how old an open task is before the weekly review calls it stale
+	staleTaskAge = 30 * 24 * time.Hour
+	// maxReviewTasks caps how many tasks each list of the weekly review counts
+	maxReviewTasks = 200
+	// maxReviewListed is how many tasks each list of the weekly review shows
+	maxReviewListed = 10
+	// summaryTimeout bounds the model call writing the review's summary
+	summaryTimeout = 30 * time.Second
+	// maxReviewSummary is how many characters of the model's summary are shown, models
+	// don't always keep to the asked length
+	maxReviewSummary = 1000
+)
+
+// parseWeeklyReviewTime parses a day and time like "Sun 18:00". "off" disables the review,
+// which returns ok false.
+func parseWeeklyReviewTime(value string) (day time.Weekday, at string, ok bool, err error) {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "off") {
+		return 0, "", false, nil
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, "", false, fmt.Errorf("invalid weekly review time %q, expected a day and HH:MM like \"Sun 18:00\"", value)
+	}
+	day, known := weekdays[strings.ToLower(fields[0])]
+	if !known {
+		return 0, "", false, fmt.Errorf("invalid weekday %q, expected a name like Sun", fields[0])
+	}
+	t, err := time.Parse("15:04", fields[1])
+	if err != nil {
+		return 0, "", false, fmt.Errorf("invalid weekly review time %q, expected HH:MM", fields[1])
+	}
+	return day, t.Format("15:04"), true, nil
+}
+
+// reviewDue reports whether the weekly review should go out at now, in the scheduler's
+// timezone. Like the daily check, a minute that already ran doesn't run again.
+func (s *Scheduler) reviewDue(now time.Time) bool {
+	if s.reviewTime == "" {
+		return false
+	}
+	local := now.In(s.timezone)
+	if local.Weekday() != s.reviewDay || local.Format("15:04") != s.reviewTime {
+		return false
+	}
+
+	run := local.Format("2006-01-02 15:04")
+	if run == s.lastReview {
+		return false
+	}
+	s.lastReview = run
+	return true
+}
+
+// weeklyReview is what the weekly review reports
+type weeklyReview struct {
+	from, to  time.Time
+	completed []notion.Task
+	created   []notion.Task
+	stale     []notion.Task
+	summary   string // Written by the model, empty when it's unavailable
+}
+
+// reviewSection lists some of the tasks under a heading with their count
+func reviewSection(heading string, tasks []notion.Task) digestSection {
+	section := digestSection{heading: heading, total: len(tasks)}
+	for i, task := range tasks {
+		if i == maxReviewListed {
+			section.more = fmt.Sprintf("…and %d more", len(tasks)-maxReviewListed)
+			break
+		}
+		section.items = append(section.items, taskLink(task))
+	}
+	if len(tasks) == maxReviewTasks {
+		section.more = fmt.Sprintf("…and more, only the first %d were counted", maxReviewTasks)
+	}
+	return section
+}
+
+// renderWeeklyReview lays the review out as messages of at most limit characters
+func renderWeeklyReview(review weeklyReview, limit int) []string {
+	header := fmt.Sprintf("━━━━━━━━━━━━━━━━━━━━\n🗓️ <b>Weekly Review</b>\n%s – %s\n━━━━━━━━━━━━━━━━━━━━",
+		review.from.Format("02 Jan"), review.to.Format("02 Jan 2006"))
+	if review.summary != "" {
+		header += "\n\n" + html.EscapeString(truncateString(review.summary, maxReviewSummary))
+	}
+
+	sections := []digestSection{
+		reviewSection("✅ <b>Completed</b>", review.completed),
+		reviewSection("🆕 <b>Created</b>", review.created),
+		reviewSection("🕸️ <b>Open for over 30 days</b>", review.stale),
+	}
+
+	footerText := fmt.Sprintf("📊 %d completed, %d created, %d stale", len(review.completed), len(review.created), len(review.stale))
+	if len(review.completed)+len(review.created)+len(review.stale) == 0 {
+		footerText = "😴 A quiet week, nothing was completed or created."
+	}
+	footer := fmt.Sprintf("━━━━━━━━━━━━━━━━━━━━\n%s\n━━━━━━━━━━━━━━━━━━━━", footerText)
+
+	return renderDigest(header, sections, footer, limit)
+}
+
+// reviewSummary asks the model for a paragraph on the themes of the week's tasks. Without
+// a model, or when it fails, the review goes out with the lists alone.
+func (s *Scheduler) reviewSummary(ctx context.Context, review weeklyReview) string {
+	if s.summarizer == nil {
+		return ""
+	}
+
+	seen := make(map[string]bool)
+	var titles []string
+	for _, task := range append(append([]notion.Task{}, review.completed...), review.created...) {
+		if task.Title != "" && !seen[task.ID] {
+			seen[task.ID] = true
+			titles = append(titles, task.Title)
+		}
+	}
+	if len(titles) == 0 {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, summaryTimeout)
+	defer cancel()
+	summary, err := s.summarizer.Summarize(ctx, titles)
+	if err != nil {
+		log.Printf("Warning: Could not summarize the week, sending the lists only: %v", err)
+		return ""
+	}
+	return summary
+}
+
+// runWeeklyReview sends the tasks completed and created over the last 7 days, the tasks
+// open for over 30 days and a summary of the week's themes
+func (s *Scheduler) runWeeklyReview(ctx context.Context) {
+	now := time.Now().In(s.timezone)
+	review := weeklyReview{from: now.AddDate(0, 0, -7), to: now}
+
+	var err error
+	if review.completed, err = s.notionClient.GetTasksCompletedBetween(ctx, "tasks", review.from, review.to, maxReviewTasks); err != nil {
+		log.Printf("Error building the weekly review: %v", err)
+		return
+	}
+	if review.created, err = s.notionClient.GetTasksCreatedBetween(ctx, "tasks", review.from, review.to, maxReviewTasks); err != nil {
+		log.Printf("Error building the weekly review: %v", err)
+		return
+	}
+	if review.stale, err = s.notionClient.GetStaleTasks(ctx, "tasks", now.Add(-staleTaskAge), maxReviewTasks); err != nil {
+		log.Printf("Error building the weekly review: %v", err)
+		return
+	}
+	review.summary = s.reviewSummary(ctx, review)
+
+	for i, text := range renderWeeklyReview(review, telegramMessageLimit) {
+		msg := tgbotapi.NewMessage(s.authorizedUserID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+		if _, err := s.sendWithRetry(msg); err != nil {
+			log.Printf("Error sending weekly review message %d: %v", i+1, err)
+		}
+	}
+	log.Printf("Weekly review sent: %d completed, %d created, %d stale", len(review.completed), len(review.created), len(review.stale))
+}
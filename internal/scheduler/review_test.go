@@ -0,0 +1,231 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// fakeSummarizer answers Summarize with a canned summary or error
+type fakeSummarizer struct {
+	summary string
+	err     error
+	items   []string
+}
+
+func (f *fakeSummarizer) Summarize(ctx context.Context, items []string) (string, error) {
+	f.items = items
+	return f.summary, f.err
+}
+
+// reviewTasks returns n tasks with the given title prefix
+func reviewTasks(prefix string, n int) []notion.Task {
+	tasks := make([]notion.Task, n)
+	for i := range tasks {
+		tasks[i] = notion.Task{ID: fmt.Sprintf("%s-%d", prefix, i), Title: fmt.Sprintf("%s %d", prefix, i)}
+	}
+	return tasks
+}
+
+func TestParseWeeklyReviewTime(t *testing.T) {
+	day, at, ok, err := parseWeeklyReviewTime(" sunday 8:30 ")
+	if err != nil || !ok || day != time.Sunday || at != "08:30" {
+		t.Errorf("Expected Sunday 08:30, got %v %q %v %v", day, at, ok, err)
+	}
+	if _, _, ok, err := parseWeeklyReviewTime("OFF"); ok || err != nil {
+		t.Errorf("Expected off to disable the review, got %v %v", ok, err)
+	}
+	for _, value := range []string{"", "18:00", "Sun", "Caturday 18:00", "Sun 6pm", "Sun 18:00 19:00"} {
+		if _, _, _, err := parseWeeklyReviewTime(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestReviewDue(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	s := &Scheduler{reviewDay: time.Sunday, reviewTime: "18:00", timezone: moscow}
+
+	// Sunday 17 March 2024
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"a minute early", time.Date(2024, 3, 17, 17, 59, 0, 0, moscow), false},
+		{"review time", time.Date(2024, 3, 17, 18, 0, 0, 0, moscow), true},
+		{"second tick in the same minute", time.Date(2024, 3, 17, 18, 0, 30, 0, moscow), false},
+		{"a minute late", time.Date(2024, 3, 17, 18, 1, 0, 0, moscow), false},
+		{"other day", time.Date(2024, 3, 18, 18, 0, 0, 0, moscow), false},
+		{"in UTC", time.Date(2024, 3, 24, 15, 0, 0, 0, time.UTC), true},
+		{"18:00 UTC", time.Date(2024, 3, 31, 18, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := s.reviewDue(tt.now); got != tt.want {
+			t.Errorf("%s: reviewDue(%v) = %v, want %v", tt.name, tt.now, got, tt.want)
+		}
+	}
+
+	off := &Scheduler{timezone: moscow}
+	if off.reviewDue(time.Date(2024, 3, 17, 18, 0, 0, 0, moscow)) {
+		t.Error("Expected no review when it is off")
+	}
+}
+
+func TestRenderWeeklyReview(t *testing.T) {
+	review := weeklyReview{
+		from:      time.Date(2024, 3, 10, 18, 0, 0, 0, time.UTC),
+		to:        time.Date(2024, 3, 17, 18, 0, 0, 0, time.UTC),
+		completed: reviewTasks("Done", 12),
+		created:   reviewTasks("New", 3),
+		summary:   "A week of <errands> & paperwork.",
+	}
+
+	messages := renderWeeklyReview(review, telegramMessageLimit)
+	if len(messages) != 1 {
+		t.Fatalf("Expected a single message, got %d", len(messages))
+	}
+	text := messages[0]
+	for _, want := range []string{
+		"Weekly Review</b>\n10 Mar – 17 Mar 2024",
+		"A week of &lt;errands&gt; &amp; paperwork.",
+		"✅ <b>Completed</b> (12)",
+		`• <a href="https://notion.so/Done9">Done 9</a>`,
+		"…and 2 more",
+		"🆕 <b>Created</b> (3)",
+		"📊 12 completed, 3 created, 0 stale",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in the review:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "Done 10") || strings.Contains(text, "Open for over 30 days") {
+		t.Errorf("Expected at most %d tasks per list and no empty section:\n%s", maxReviewListed, text)
+	}
+}
+
+func TestRenderWeeklyReviewFitsTelegramLimit(t *testing.T) {
+	long := func(prefix string) []notion.Task {
+		tasks := reviewTasks(prefix, maxReviewTasks)
+		for i := range tasks {
+			tasks[i].Title = strings.Repeat("й", 200)
+		}
+		return tasks
+	}
+	review := weeklyReview{
+		completed: long("done"),
+		created:   long("new"),
+		stale:     long("old"),
+		summary:   strings.Repeat("Errands. ", 2000),
+	}
+
+	messages := renderWeeklyReview(review, telegramMessageLimit)
+	for i, message := range messages {
+		if utf16Len(message) > telegramMessageLimit {
+			t.Errorf("Message %d is %d characters long", i, utf16Len(message))
+		}
+	}
+	if all := strings.Join(messages, "\n"); !strings.Contains(all, "only the first 200 were counted") {
+		t.Errorf("Expected capped lists to say so:\n%s", all)
+	}
+}
+
+func TestRenderWeeklyReviewQuietWeek(t *testing.T) {
+	messages := renderWeeklyReview(weeklyReview{}, telegramMessageLimit)
+	if len(messages) != 1 || !strings.Contains(messages[0], "A quiet week") {
+		t.Errorf("Expected a quiet week note, got %q", messages)
+	}
+}
+
+// reviewNotion answers the weekly review's queries: done tasks, created tasks and stale
+// tasks, told apart by their filters. The tasks database has a select status.
+type reviewNotion struct{}
+
+func (reviewNotion) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body: io.NopCloser(strings.NewReader(`{"object": "database", "id": "tasks-db", "properties": {
+				"Name": {"id": "title", "name": "Name", "type": "title", "title": {}},
+				"status": {"id": "st", "name": "status", "type": "select", "select": {"options": [{"name": "done"}]}}
+			}}`)),
+			Request: req,
+		}, nil
+	}
+	body, _ := io.ReadAll(req.Body)
+	page := func(id, title string) string {
+		return `{"object": "page", "id": "` + id + `", "properties": {
+			"Name": {"id": "title", "type": "title", "title": [{"type": "text", "text": {"content": "` + title + `"}, "plain_text": "` + title + `"}]}
+		}}`
+	}
+	var results string
+	switch query := string(body); {
+	case strings.Contains(query, `"equals":"done"`):
+		results = page("done-1", "Renew passport")
+	case strings.Contains(query, `"does_not_equal":"done"`):
+		results = page("old-1", "Fix the bike")
+	default:
+		results = page("new-1", "Pay the electricity bill")
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(`{"object": "list", "results": [` + results + `], "has_more": false}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestRunWeeklyReview(t *testing.T) {
+	tests := []struct {
+		name        string
+		summarizer  *fakeSummarizer
+		wantSummary bool
+	}{
+		{"with summary", &fakeSummarizer{summary: "Paperwork week."}, true},
+		{"summary failed", &fakeSummarizer{err: errors.New("quota exceeded")}, false},
+		{"without AI", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTION_TASKS_DATABASE_ID", "tasks-db")
+			telegram, botAPI := newFakeTelegram(t)
+			s := &Scheduler{
+				bot:              botAPI,
+				authorizedUserID: 42,
+				timezone:         time.UTC,
+				notionClient:     notion.NewClient(notionapi.WithHTTPClient(&http.Client{Transport: reviewNotion{}})),
+			}
+			if tt.summarizer != nil {
+				s.summarizer = tt.summarizer
+			}
+
+			s.runWeeklyReview(context.Background())
+
+			calls := telegram.callsTo("sendMessage")
+			if len(calls) != 1 {
+				t.Fatalf("Expected one review message, got %d", len(calls))
+			}
+			text := calls[0].Params.Get("text")
+			for _, want := range []string{"Renew passport", "Pay the electricity bill", "Fix the bike", "📊 1 completed, 1 created, 1 stale"} {
+				if !strings.Contains(text, want) {
+					t.Errorf("Expected %q in the review:\n%s", want, text)
+				}
+			}
+			if got := strings.Contains(text, "Paperwork week."); got != tt.wantSummary {
+				t.Errorf("Expected summary %v in the review:\n%s", tt.wantSummary, text)
+			}
+			if tt.summarizer != nil && strings.Join(tt.summarizer.items, "|") != "Renew passport|Pay the electricity bill" {
+				t.Errorf("Expected the completed and created titles to be summarized, got %q", tt.summarizer.items)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultCheckTime is when the daily check runs without SCHEDULER_TIMES, 11 PM
+const defaultCheckTime = "23:00"
+
+// weekdays maps the short and long English day names to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// parseCheckTimes parses comma-separated HH:MM times like "09:00,23:00" into sorted,
+// distinct "15:04" times
+func parseCheckTimes(value string) ([]string, error) {
+	seen := make(map[string]bool)
+	var times []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		t, err := time.Parse("15:04", part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid check time %q, expected HH:MM", part)
+		}
+		// Normalizes "9:00" to "09:00" so it matches the ticker's format
+		if formatted := t.Format("15:04"); !seen[formatted] {
+			seen[formatted] = true
+			times = append(times, formatted)
+		}
+	}
+	if len(times) == 0 {
+		return nil, fmt.Errorf("no check times in %q", value)
+	}
+	sort.Strings(times)
+	return times, nil
+}
+
+// parseSkipDays parses comma-separated day names like "Sat,Sun"
+func parseSkipDays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, ok := weekdays[strings.ToLower(part)]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q, expected a name like Sat", part)
+		}
+		days[day] = true
+	}
+	if len(days) == 7 {
+		return nil, fmt.Errorf("skipping every day would never run the check")
+	}
+	return days, nil
+}
+
+// due reports whether a check should run at now, in the scheduler's timezone. A minute
+// that already ran doesn't run again, in case a tick lands on it twice.
+func (s *Scheduler) due(now time.Time) bool {
+	local := now.In(s.timezone)
+	if s.skipDays[local.Weekday()] {
+		return false
+	}
+
+	current := local.Format("15:04")
+	matched := false
+	for _, checkTime := range s.checkTimes {
+		if checkTime == current {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	run := local.Format("2006-01-02 15:04")
+	if run == s.lastRun {
+		return false
+	}
+	s.lastRun = run
+	return true
+}
+
+// summaryDue reports whether the weekly usage summary goes out with the check at now: the
+// last check of Sunday, or of the last day of the week that isn't skipped
+func (s *Scheduler) summaryDue(now time.Time) bool {
+	local := now.In(s.timezone)
+	if len(s.checkTimes) == 0 || local.Format("15:04") != s.checkTimes[len(s.checkTimes)-1] {
+		return false
+	}
+
+	// Weeks end on Sunday, walk back to the last day that runs
+	for day := time.Sunday; ; day = (day + 6) % 7 {
+		if !s.skipDays[day] {
+			return local.Weekday() == day
+		}
+		if day == time.Monday {
+			return false
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCheckTimes(t *testing.T) {
+	times, err := parseCheckTimes(" 23:00, 9:00,09:00 ")
+	if err != nil {
+		t.Fatalf("parseCheckTimes failed: %v", err)
+	}
+	if want := []string{"09:00", "23:00"}; !reflect.DeepEqual(times, want) {
+		t.Errorf("Expected %v, got %v", want, times)
+	}
+
+	for _, value := range []string{"", " , ", "25:00", "9am", "09:00,noon"} {
+		if _, err := parseCheckTimes(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestParseSkipDays(t *testing.T) {
+	days, err := parseSkipDays("Sat, sunday")
+	if err != nil {
+		t.Fatalf("parseSkipDays failed: %v", err)
+	}
+	if want := map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}; !reflect.DeepEqual(days, want) {
+		t.Errorf("Expected %v, got %v", want, days)
+	}
+
+	if days, err := parseSkipDays(""); err != nil || len(days) != 0 {
+		t.Errorf("Expected no skipped days, got %v, %v", days, err)
+	}
+	if _, err := parseSkipDays("Sat,Caturday"); err == nil {
+		t.Error("Expected an unknown day to be rejected")
+	}
+	if _, err := parseSkipDays("Mon,Tue,Wed,Thu,Fri,Sat,Sun"); err == nil {
+		t.Error("Expected skipping every day to be rejected")
+	}
+}
+
+func TestDue(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	s := &Scheduler{
+		checkTimes: []string{"09:00", "23:00"},
+		skipDays:   map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+		timezone:   moscow,
+	}
+
+	// Friday 15 March 2024
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"morning check", time.Date(2024, 3, 15, 9, 0, 0, 0, moscow), true},
+		{"second tick in the same minute", time.Date(2024, 3, 15, 9, 0, 40, 0, moscow), false},
+		{"between checks", time.Date(2024, 3, 15, 14, 0, 0, 0, moscow), false},
+		{"evening check in UTC", time.Date(2024, 3, 15, 20, 0, 0, 0, time.UTC), true},
+		{"saturday", time.Date(2024, 3, 16, 9, 0, 0, 0, moscow), false},
+		// 06:00 UTC on Monday is 09:00 in Moscow
+		{"monday in the scheduler's timezone", time.Date(2024, 3, 18, 6, 0, 0, 0, time.UTC), true},
+		{"same time the next week", time.Date(2024, 3, 22, 9, 0, 0, 0, moscow), true},
+	}
+	for _, tt := range tests {
+		if got := s.due(tt.now); got != tt.want {
+			t.Errorf("%s: expected due=%v at %v, got %v", tt.name, tt.want, tt.now, got)
+		}
+	}
+}
+
+func TestDueSkipsByLocalWeekday(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	s := &Scheduler{
+		checkTimes: []string{"00:30"},
+		skipDays:   map[time.Weekday]bool{time.Saturday: true},
+		timezone:   moscow,
+	}
+
+	// Friday 21:30 UTC is Saturday 00:30 in Moscow
+	if s.due(time.Date(2024, 3, 15, 21, 30, 0, 0, time.UTC)) {
+		t.Error("Expected the check to be skipped on Saturday in the scheduler's timezone")
+	}
+	// Saturday 21:30 UTC is Sunday 00:30 in Moscow
+	if !s.due(time.Date(2024, 3, 16, 21, 30, 0, 0, time.UTC)) {
+		t.Error("Expected the check to run on Sunday in the scheduler's timezone")
+	}
+}
+
+func TestSummaryDue(t *testing.T) {
+	// 17 March 2024 is a Sunday
+	tests := []struct {
+		name     string
+		skipDays map[time.Weekday]bool
+		now      time.Time
+		want     bool
+	}{
+		{"sunday's last check", nil, time.Date(2024, 3, 17, 23, 0, 0, 0, time.UTC), true},
+		{"sunday's first check", nil, time.Date(2024, 3, 17, 9, 0, 0, 0, time.UTC), false},
+		{"saturday", nil, time.Date(2024, 3, 16, 23, 0, 0, 0, time.UTC), false},
+		{"friday with weekends skipped", map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}, time.Date(2024, 3, 15, 23, 0, 0, 0, time.UTC), true},
+		{"thursday with weekends skipped", map[time.Weekday]bool{time.Saturday: true, time.Sunday: true}, time.Date(2024, 3, 14, 23, 0, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		s := &Scheduler{checkTimes: []string{"09:00", "23:00"}, skipDays: tt.skipDays, timezone: time.UTC}
+		if got := s.summaryDue(tt.now); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
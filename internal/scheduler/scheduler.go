@@ -2,31 +2,99 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jomei/notionapi"
-	"github.com/numero_quadro/notion-mini-app/internal/gemini"
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/llm"
+	"github.com/numero_quadro/notion-mini-app/internal/metrics"
 	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/tenant"
+	"github.com/numero_quadro/notion-mini-app/internal/tracing"
+	"github.com/numero_quadro/notion-mini-app/internal/usage"
+)
+
+const (
+	// maxOverdueTasks caps how many overdue tasks the daily check counts
+	maxOverdueTasks = 1000
+	// maxOverdueListed is how many overdue tasks the daily check lists, /overdue shows the rest
+	maxOverdueListed = 30
+	// metadataPruneWindow is how far back the daily check looks for recorded tasks deleted
+	// in Notion, matching the longest window of /stats
+	metadataPruneWindow = 30 * 24 * time.Hour
+	// snoozeCallbackPrefix starts the data of the "Snooze" button, as the bot expects it
+	snoozeCallbackPrefix = "snooze:"
 )
 
 type Scheduler struct {
 	notionClient     *notion.Client
 	bot              *tgbotapi.BotAPI
-	authorizedUserID int64
-	checkTime        string // Format: "15:04" (HH:MM in 24-hour format)
+	authorizedUserID int64                 // Gets the checks, reviews and reminders (NOTIFY_USER_ID)
+	checkTimes       []string              // Sorted, format: "15:04" (HH:MM in 24-hour format)
+	skipDays         map[time.Weekday]bool // Days without checks (SCHEDULER_SKIP_DAYS)
+	lastRun          string                // Date and time of the last scheduled check, "2006-01-02 15:04"
 	timezone         *time.Location
-	geminiClient     *gemini.Client
+	tagger           llm.Tagger     // nil when AI is disabled
+	summarizer       llm.Summarizer // Writes the weekly review's summary, nil when AI is disabled
+	db               *database.DB   // Optional local database, nil when unavailable
+	collapseDigests  bool           // Collapse previous digests before sending a new one (DIGEST_COLLAPSE)
+	autoMoveJournal  bool           // Move journal-tagged tasks to the journal database (AUTO_MOVE_JOURNAL)
+	// Send a message per flagged task instead of listing them in the digest (DIGEST_PER_TASK)
+	perTaskNotifications bool
+	sendBackoff          time.Duration // Base wait before retrying a rate-limited message
+
+	reviewDay  time.Weekday // Day of the weekly review (WEEKLY_REVIEW_TIME)
+	reviewTime string       // "15:04" time of the weekly review, empty when it is off
+	lastReview string       // Date and time of the last weekly review, "2006-01-02 15:04"
+
+	reconcilePace time.Duration // Wait between the Notion calls of a reconciliation pass
+	reconcileMu   sync.Mutex    // Held by a running reconciliation pass
+
+	reminderLead  time.Duration   // How long before a task is due it is reminded about (REMINDER_LEAD), 0 when off
+	lastReminders time.Time       // When the reminder pass last ran
+	remindersMu   sync.Mutex      // Held by a running reminder pass
+	remindedMu    sync.Mutex      // Guards reminded
+	reminded      map[string]bool // Reminders sent, by task ID and due date, without a local database
+
+	lastRecurrence time.Time       // When the recurrence pass last ran
+	recurrenceMu   sync.Mutex      // Held by a running recurrence pass
+	spawnedMu      sync.Mutex      // Guards spawned
+	spawned        map[string]bool // Occurrences created, by source task ID and due date, without a local database
+
+	copiedMu sync.Mutex        // Guards copied
+	copied   map[string]string // Journal entries of tasks not archived yet, by task ID, without a local database
+
+	tenants   *tenant.Registry     // Users with their own workspace, nil when single-user
+	perTenant map[int64]*Scheduler // Schedulers of connected users, only used by Start's loop
+	workspace int64                // Whose task records are checked, database.OwnWorkspace or the connected user
 }
 
-// NewScheduler creates a new scheduler instance
-func NewScheduler(notionClient *notion.Client, bot *tgbotapi.BotAPI, authorizedUserID int64, checkTime string, geminiClient *gemini.Client) *Scheduler {
-	if checkTime == "" {
-		checkTime = "23:00" // Default to 11 PM
+// NewScheduler creates a new scheduler instance sending to the notified user of users.
+// checkTimes are comma-separated HH:MM times like "09:00,23:00", 23:00 when empty. Days
+// listed in SCHEDULER_SKIP_DAYS are skipped.
+func NewScheduler(notionClient *notion.Client, bot *tgbotapi.BotAPI, users *auth.Users, checkTimes string, tagger llm.Tagger, db *database.DB) *Scheduler {
+	if checkTimes == "" {
+		checkTimes = defaultCheckTime
+	}
+	times, err := parseCheckTimes(checkTimes)
+	if err != nil {
+		log.Printf("Warning: %v. Using %s.", err, defaultCheckTime)
+		times = []string{defaultCheckTime}
+	}
+
+	skipDays, err := parseSkipDays(os.Getenv("SCHEDULER_SKIP_DAYS"))
+	if err != nil {
+		log.Printf("Warning: Ignoring SCHEDULER_SKIP_DAYS: %v", err)
+		skipDays = nil
 	}
 
 	// Load timezone from environment variable or default to MSK
@@ -35,6 +103,25 @@ func NewScheduler(notionClient *notion.Client, bot *tgbotapi.BotAPI, authorizedU
 		tzName = "Europe/Moscow" // Default to Moscow timezone
 	}
 
+	reviewValue := os.Getenv("WEEKLY_REVIEW_TIME")
+	if reviewValue == "" {
+		reviewValue = defaultWeeklyReviewTime
+	}
+	reviewDay, reviewTime, reviewOn, err := parseWeeklyReviewTime(reviewValue)
+	if err != nil {
+		log.Printf("Warning: %v. Using %s.", err, defaultWeeklyReviewTime)
+		reviewDay, reviewTime, reviewOn, _ = parseWeeklyReviewTime(defaultWeeklyReviewTime)
+	}
+	if !reviewOn {
+		reviewTime = ""
+	}
+	summarizer, _ := tagger.(llm.Summarizer)
+
+	reminderLead, err := parseReminderLead(os.Getenv("REMINDER_LEAD"))
+	if err != nil {
+		log.Printf("Warning: %v. Reminders are off.", err)
+	}
+
 	location, err := time.LoadLocation(tzName)
 	if err != nil {
 		log.Printf("Warning: Failed to load timezone '%s': %v. Using UTC.", tzName, err)
@@ -46,16 +133,45 @@ func NewScheduler(notionClient *notion.Client, bot *tgbotapi.BotAPI, authorizedU
 	return &Scheduler{
 		notionClient:     notionClient,
 		bot:              bot,
-		authorizedUserID: authorizedUserID,
-		checkTime:        checkTime,
+		authorizedUserID: users.NotifyUserID(),
+		checkTimes:       times,
+		skipDays:         skipDays,
 		timezone:         location,
-		geminiClient:     geminiClient,
+		tagger:           tagger,
+		summarizer:       summarizer,
+		db:               db,
+		collapseDigests:  os.Getenv("DIGEST_COLLAPSE") == "true",
+		autoMoveJournal:  os.Getenv("AUTO_MOVE_JOURNAL") == "true",
+
+		perTaskNotifications: os.Getenv("DIGEST_PER_TASK") == "true",
+		sendBackoff:          defaultSendBackoff,
+
+		reviewDay:  reviewDay,
+		reviewTime: reviewTime,
+
+		reconcilePace: reconcileInterval,
+
+		reminderLead: reminderLead,
 	}
 }
 
 // Start begins the scheduler loop
 func (s *Scheduler) Start(ctx context.Context) {
-	log.Printf("Starting scheduler with daily check at %s (timezone: %s)", s.checkTime, s.timezone.String())
+	skipped := "none"
+	if len(s.skipDays) > 0 {
+		var days []string
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if s.skipDays[day] {
+				days = append(days, day.String()[:3])
+			}
+		}
+		skipped = strings.Join(days, ", ")
+	}
+	log.Printf("Starting scheduler with daily checks at %s (timezone: %s, skipped days: %s)",
+		strings.Join(s.checkTimes, ", "), s.timezone.String(), skipped)
+	if s.reminderLead > 0 {
+		log.Printf("Reminding about tasks %v before they are due", s.reminderLead)
+	}
 
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
@@ -66,29 +182,75 @@ func (s *Scheduler) Start(ctx context.Context) {
 			log.Printf("Scheduler stopped")
 			return
 		case now := <-ticker.C:
-			// Convert current time to configured timezone
-			localNow := now.In(s.timezone)
-			currentTime := localNow.Format("15:04")
-
-			// Check if current time matches check time
-			if currentTime == s.checkTime {
-				log.Printf("Running scheduled task check at %s %s", currentTime, s.timezone.String())
-				go s.checkTasks(ctx)
+			checked := s.tick(ctx, now)
+			for _, connected := range s.tenantSchedulers() {
+				connected.tick(ctx, now)
+			}
+
+			// The weekly usage summary goes out with the week's last check
+			if _, paused := s.pausedUntil(now); checked && !paused && s.summaryDue(now) {
+				go s.sendUsageSummary()
 			}
 		}
 	}
 }
 
-// RunManualCheck triggers a manual task check (for testing/recovery)
+// tick starts the reminders, recurrences, review and check due at now, reporting whether
+// the check ran
+func (s *Scheduler) tick(ctx context.Context, now time.Time) bool {
+	// Creating the next occurrence of done tasks sends nothing, so it goes on while paused
+	if s.recurrenceDue(now) {
+		go s.spawnRecurringTasks(ctx)
+	}
+	_, paused := s.pausedUntil(now)
+	if !paused && s.remindersDue(now) {
+		go s.sendReminders(ctx)
+	}
+	if !paused && s.reviewDue(now) {
+		log.Printf("Running the weekly review for user %d", s.authorizedUserID)
+		go s.runWeeklyReview(ctx)
+		// Records of tasks deleted in Notion long ago are dropped weekly, the daily check
+		// only looks at recent ones
+		go s.runReconcile(ctx, false)
+	}
+	if !s.due(now) {
+		return false
+	}
+	log.Printf("Running scheduled task check for user %d at %s %s", s.authorizedUserID, now.In(s.timezone).Format("15:04"), s.timezone.String())
+	go s.checkTasks(ctx)
+	return true
+}
+
+// Timezone returns the timezone the scheduler runs in, from TZ
+func (s *Scheduler) Timezone() *time.Location {
+	return s.timezone
+}
+
+// RunManualCheck triggers a manual task check (for testing/recovery), even while
+// notifications are paused
 func (s *Scheduler) RunManualCheck() {
 	log.Printf("Manual task check triggered")
 	ctx := context.Background()
-	go s.checkTasks(ctx)
+	go s.runTaskCheck(ctx)
 }
 
-// checkTasks performs the daily task check
+// checkTasks performs the daily task check, unless the user paused notifications
 func (s *Scheduler) checkTasks(ctx context.Context) {
+	if until, paused := s.pausedUntil(time.Now()); paused {
+		log.Printf("Skipping the task check for user %d, notifications are paused until %s", s.authorizedUserID, until.In(s.timezone).Format("2006-01-02 15:04"))
+		return
+	}
+	s.runTaskCheck(ctx)
+}
+
+// runTaskCheck performs the daily task check
+func (s *Scheduler) runTaskCheck(ctx context.Context) {
 	log.Printf("Starting task check...")
+	startedAt := time.Now()
+	defer func() { usage.RecordSchedulerRun(time.Since(startedAt)) }()
+
+	ctx, span := tracing.Start(ctx, "scheduler.check_tasks")
+	defer span.End()
 
 	// Step 0: Ensure all undone tasks (excluding 'sometimes-later') have llm_tag set
 	// This covers tasks added directly in Notion bypassing the bot.
@@ -96,31 +258,44 @@ func (s *Scheduler) checkTasks(ctx context.Context) {
 		log.Printf("Error ensuring tags for undone tasks: %v", err)
 		errorMsg := tgbotapi.NewMessage(s.authorizedUserID,
 			fmt.Sprintf("❌ Error preparing tasks for check: %v", err))
-		s.bot.Send(errorMsg)
+		s.sendWithRetry(errorMsg)
 		return
 	}
 
-	// Send header message to separate this batch from previous ones
 	checkTime := time.Now().In(s.timezone)
-	headerMsg := tgbotapi.NewMessage(s.authorizedUserID,
-		fmt.Sprintf("━━━━━━━━━━━━━━━━━━━━\n📋 **Daily Task Check**\n🕐 %s\n━━━━━━━━━━━━━━━━━━━━",
-			checkTime.Format("Mon, 02 Jan 2006 15:04 MST")))
-	headerMsg.ParseMode = "Markdown"
-	s.bot.Send(headerMsg)
+
+	// Deliver what earlier checks couldn't send. While Telegram is still unreachable the
+	// sends below are skipped and this check's digest is kept as well.
+	telegramDown := !s.flushMissedDigests(checkTime)
+
+	// Collapse yesterday's digests so the chat doesn't fill up with them
+	if s.collapseDigests {
+		s.collapsePreviousDigests(checkTime)
+	}
 
 	// Query ALL non-done tasks from Notion (not just last 24h from local DB)
-	tasks, err := s.notionClient.GetRecentTasks(ctx, "tasks", 1000) // Get up to 1000 tasks
+	tasks, info, err := s.notionClient.GetRecentTasksWithInfo(ctx, "tasks", 1000) // Get up to 1000 tasks
 	if err != nil {
 		log.Printf("Error retrieving tasks from Notion: %v", err)
 		errorMsg := tgbotapi.NewMessage(s.authorizedUserID,
 			fmt.Sprintf("❌ Error checking tasks: %v", err))
-		s.bot.Send(errorMsg)
+		s.sendWithRetry(errorMsg)
 		return
 	}
 
 	log.Printf("Found %d non-done tasks to check", len(tasks))
+	if info.Degraded {
+		log.Printf("Warning: Task listing degraded (%s), %s-side filters matched %d of %d pages",
+			info.Reason, info.Filters, info.PagesMatched, info.PagesScanned)
+	}
 
-	notificationCount := 0
+	// Track the messages of this run so it can be collapsed later
+	var digestMessages []database.DigestMessage
+
+	dateless := digestSection{heading: "⏰ <b>Deadlines without a date</b>", hint: "You mentioned a deadline but no date was added."}
+	journal := digestSection{heading: "📔 <b>Possible journal entries</b>", hint: "Consider moving them to your journal database."}
+	links := digestSection{heading: "🔗 <b>Link-only tasks</b>", hint: "Give them a descriptive name."}
+	moved := digestSection{heading: "📔 <b>Moved to your journal</b>"}
 	for _, task := range tasks {
 		// Check if task has llm_tag property in Notion
 		llmTag, hasTag := task.Properties["llm_tag"].(string)
@@ -137,39 +312,267 @@ func (s *Scheduler) checkTasks(ctx context.Context) {
 			}
 		}
 
-		// Send notifications based on tag
-		if err := s.sendNotification(task, hasDate); err != nil {
-			log.Printf("Error sending notification for task %s: %v", task.ID, err)
-		} else {
-			// Only count if notification was actually sent
-			if (llmTag == "date" && !hasDate) || llmTag == "journal" || llmTag == "link" {
-				notificationCount++
+		// Journal entries are moved instead when enabled, falling back to flagging them
+		if llmTag == "journal" && s.autoMoveJournal && s.notionClient.HasDatabase("journal") {
+			journalID, err := s.moveToJournal(ctx, task.ID)
+			if err != nil {
+				log.Printf("Warning: Failed to move task %s to the journal: %v", task.ID, err)
+			}
+			if journalID != "" {
+				if err == nil {
+					s.forgetTask(task.ID)
+				}
+				moved.add(movedEntryLine(task, journalID, err))
+				continue
 			}
 		}
+
+		if s.perTaskNotifications && !telegramDown {
+			if messageID, err := s.sendNotification(task, hasDate); err != nil {
+				log.Printf("Error sending notification for task %s: %v", task.ID, err)
+				telegramDown = isTransientSendError(err)
+			} else if messageID != 0 {
+				digestMessages = append(digestMessages, database.DigestMessage{MessageID: messageID, Kind: database.DigestMessageBody})
+			}
+		}
+
+		switch {
+		case llmTag == "date" && !hasDate:
+			dateless.add(taskEntry(task))
+		case llmTag == "journal":
+			journal.add(taskEntry(task))
+		case llmTag == "link":
+			links.add(taskEntry(task))
+		}
+	}
+	notificationCount := dateless.total + journal.total + links.total
+
+	// Flagged tasks are listed in the digest when their own notifications couldn't be sent,
+	// so it keeps them for the next check
+	sections := []digestSection{moved}
+	if !s.perTaskNotifications || telegramDown {
+		sections = append(sections, dateless, journal, links)
 	}
 
-	// Send footer message with summary
+	// Overdue tasks get a section of their own
+	overdue, err := s.overdueSection(ctx, checkTime)
+	if err != nil {
+		log.Printf("Error reporting overdue tasks: %v", err)
+	}
+	overdueCount := overdue.total
+	sections = append(sections, overdue)
+
+	// Summary at the end of the digest
 	var footerText string
-	if notificationCount == 0 {
+	switch {
+	case notificationCount == 0 && overdueCount == 0 && moved.total > 0:
+		footerText = "✅ No tasks need attention."
+	case notificationCount == 0 && overdueCount == 0:
 		footerText = "✅ All tasks look good! No issues found."
-	} else {
+	case overdueCount == 0:
 		footerText = fmt.Sprintf("📊 Found %d task(s) needing attention", notificationCount)
+	default:
+		footerText = fmt.Sprintf("📊 Found %d task(s) needing attention\n⏰ %d overdue task(s)", notificationCount, overdueCount)
+	}
+	if moved.total > 0 {
+		footerText += fmt.Sprintf("\n📔 Moved %d journal entr(ies)", moved.total)
 	}
-	footerMsg := tgbotapi.NewMessage(s.authorizedUserID,
-		fmt.Sprintf("━━━━━━━━━━━━━━━━━━━━\n%s\n━━━━━━━━━━━━━━━━━━━━", footerText))
-	s.bot.Send(footerMsg)
 
-	log.Printf("Task check completed: %d notifications sent", notificationCount)
+	header := fmt.Sprintf("━━━━━━━━━━━━━━━━━━━━\n📋 <b>Daily Task Check</b>\n🕐 %s\n━━━━━━━━━━━━━━━━━━━━",
+		checkTime.Format("Mon, 02 Jan 2006 15:04 MST"))
+	footer := fmt.Sprintf("━━━━━━━━━━━━━━━━━━━━\n%s\n━━━━━━━━━━━━━━━━━━━━", footerText)
+
+	// One message unless the digest is longer than Telegram allows. The first message is
+	// the one collapsing edits into the summary.
+	digest := renderDigest(header, sections, footer, telegramMessageLimit)
+	for i, text := range digest {
+		if telegramDown {
+			s.storeMissedDigest(digest[i:], checkTime)
+			break
+		}
+		msg := tgbotapi.NewMessage(s.authorizedUserID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+		sent, err := s.sendWithRetry(msg)
+		if err != nil {
+			log.Printf("Error sending digest message %d: %v", i+1, err)
+			if isTransientSendError(err) {
+				s.storeMissedDigest(digest[i:], checkTime)
+				break
+			}
+			continue
+		}
+		kind := database.DigestMessageBody
+		if i == 0 {
+			kind = database.DigestMessageHeader
+		}
+		digestMessages = append(digestMessages, database.DigestMessage{MessageID: sent.MessageID, Kind: kind})
+	}
+
+	s.recordDigestRun(digestMessages, notificationCount+overdueCount, checkTime)
+	s.pruneDeletedTasks(ctx, checkTime)
+	metrics.RecordNotifications("date", dateless.total)
+	metrics.RecordNotifications("journal", journal.total)
+	metrics.RecordNotifications("link", links.total)
+	metrics.RecordNotifications("overdue", overdueCount)
+	metrics.RecordNotifications("journal_moved", moved.total)
+
+	log.Printf("Task check completed: %d tasks flagged, %d overdue tasks, %d moved to the journal", notificationCount, overdueCount, moved.total)
 }
 
-// checkTaskInNotion verifies if a task exists in Notion and checks if it has a date
+// movedEntryLine renders a task moved to the journal, linking the new entry and the
+// task, which is still open when archiving it failed
+func movedEntryLine(task notion.Task, journalID string, archiveErr error) string {
+	line := fmt.Sprintf("<a href=\"%s\">%s</a>", notionPageURL(journalID), html.EscapeString(truncateString(task.Title, maxDigestTitle)))
+	if archiveErr != nil {
+		return line + fmt.Sprintf(" (copied, <a href=\"%s\">archive the task</a> yourself)", notionPageURL(task.ID))
+	}
+	return line + fmt.Sprintf(" (<a href=\"%s\">archived task</a>)", notionPageURL(task.ID))
+}
+
+// moveToJournal moves a task to the journal database. A task copied on an earlier check
+// that couldn't be archived then is only archived, so it isn't copied twice.
+func (s *Scheduler) moveToJournal(ctx context.Context, taskID string) (string, error) {
+	if journalID := s.journalCopy(taskID); journalID != "" {
+		if err := s.notionClient.ArchiveTask(ctx, taskID); err != nil {
+			return journalID, fmt.Errorf("journal entry %s exists but failed to archive task %s: %w", journalID, taskID, err)
+		}
+		s.recordJournalCopy(taskID, "")
+		return journalID, nil
+	}
+
+	journalID, err := s.notionClient.MoveTaskToJournal(ctx, taskID)
+	if err != nil && journalID != "" {
+		s.recordJournalCopy(taskID, journalID)
+	}
+	return journalID, err
+}
+
+// journalCopy returns the journal entry copied from a task that wasn't archived yet, in
+// the local database when there is one
+func (s *Scheduler) journalCopy(taskID string) string {
+	if s.db == nil {
+		s.copiedMu.Lock()
+		defer s.copiedMu.Unlock()
+		return s.copied[taskID]
+	}
+	journalID, err := s.db.JournalCopy(taskID)
+	if err != nil {
+		log.Printf("Warning: Could not check the journal copy of task %s: %v", taskID, err)
+	}
+	return journalID
+}
+
+// recordJournalCopy remembers the journal entry copied from a task that couldn't be
+// archived, or forgets it with an empty journalID once the task was archived
+func (s *Scheduler) recordJournalCopy(taskID, journalID string) {
+	if s.db == nil {
+		s.copiedMu.Lock()
+		defer s.copiedMu.Unlock()
+		if journalID == "" {
+			delete(s.copied, taskID)
+			return
+		}
+		if s.copied == nil {
+			s.copied = make(map[string]string)
+		}
+		s.copied[taskID] = journalID
+		return
+	}
+
+	var err error
+	if journalID == "" {
+		err = s.db.DeleteJournalCopy(taskID)
+	} else {
+		err = s.db.RecordJournalCopy(taskID, journalID, time.Now())
+	}
+	if err != nil {
+		log.Printf("Warning: Could not record the journal copy of task %s: %v", taskID, err)
+	}
+}
+
+// overdueSection lists the tasks that aren't done and were due before the day of
+// checkTime, in the scheduler's timezone
+func (s *Scheduler) overdueSection(ctx context.Context, checkTime time.Time) (digestSection, error) {
+	section := digestSection{heading: "⏰ <b>Overdue tasks</b>"}
+	tasks, err := s.notionClient.GetOverdueTasks(ctx, "tasks", checkTime.In(s.timezone), maxOverdueTasks)
+	if err != nil {
+		return section, err
+	}
+
+	for i, task := range tasks {
+		if i == maxOverdueListed {
+			section.more = fmt.Sprintf("…and %d more, see /overdue", len(tasks)-maxOverdueListed)
+			break
+		}
+		due := ""
+		if day, ok := notion.DueDay(task, s.timezone); ok {
+			due = fmt.Sprintf(" (due %s)", day.Format("02 Jan"))
+		}
+		section.items = append(section.items, taskEntry(task)+due)
+	}
+	section.total = len(tasks)
+	return section, nil
+}
+
+// pruneDeletedTasks drops the local records of tasks the bot created in the last
+// metadataPruneWindow whose Notion page was deleted or archived, so /stats doesn't count them.
+// Only the scheduler's workspace is checked, another one's tasks would look deleted.
+func (s *Scheduler) pruneDeletedTasks(ctx context.Context, checkTime time.Time) {
+	if s.db == nil {
+		return
+	}
+	tasks, err := s.db.GetTasksSince(checkTime.Add(-metadataPruneWindow))
+	if err != nil {
+		log.Printf("Warning: Could not list recorded tasks: %v", err)
+		return
+	}
+
+	pruned := 0
+	for _, task := range tasks {
+		if task.Workspace != s.workspace {
+			continue
+		}
+		exists, _, err := s.checkTaskInNotion(ctx, task.TaskID)
+		if err != nil {
+			log.Printf("Warning: Could not check whether task %s still exists: %v", task.TaskID, err)
+			continue
+		}
+		if !exists {
+			s.forgetTask(task.TaskID)
+			pruned++
+		}
+	}
+	if pruned > 0 {
+		log.Printf("Dropped %d deleted task(s) from the local database", pruned)
+	}
+}
+
+// forgetTask drops the local record of a task that is no longer in the tasks database
+func (s *Scheduler) forgetTask(taskID string) {
+	if s.db == nil {
+		return
+	}
+	if err := s.db.DeleteTask(taskID); err != nil {
+		log.Printf("Warning: Could not drop task %s from the local database: %v", taskID, err)
+	}
+}
+
+// checkTaskInNotion verifies if a task exists in Notion and checks if it has a date.
+// Archived tasks count as deleted.
 func (s *Scheduler) checkTaskInNotion(ctx context.Context, taskID string) (exists bool, hasDate bool, err error) {
 	// Query Notion to get the task
 	page, err := s.notionClient.GetPage(ctx, taskID)
-	if err != nil {
+	if errors.Is(err, notion.ErrNotFound) {
 		// If task not found, it was deleted
 		return false, false, nil
 	}
+	if err != nil {
+		return false, false, err
+	}
+	if page.Archived {
+		return false, false, nil
+	}
 
 	// Check if Date property exists and has a value
 	if dateProp, ok := page.Properties["Date"]; ok {
@@ -181,14 +584,18 @@ func (s *Scheduler) checkTaskInNotion(ctx context.Context, taskID string) (exist
 	return true, hasDate, nil
 }
 
-// sendNotification sends appropriate notification based on task tag
-func (s *Scheduler) sendNotification(task notion.Task, hasDate bool) error {
+// sendNotification sends appropriate notification based on task tag.
+// Returns the ID of the sent message, or 0 when no notification was needed.
+func (s *Scheduler) sendNotification(task notion.Task, hasDate bool) (int, error) {
 	var message string
 	taskPreview := truncateString(task.Title, 50)
 	// Fix Notion URL format - remove hyphens from ID
 	cleanID := strings.ReplaceAll(task.ID, "-", "")
 	taskURL := fmt.Sprintf("https://notion.so/%s", cleanID)
 	llmTag := task.Properties["llm_tag"].(string)
+	if assignee := assigneeNames(task); assignee != "" {
+		taskPreview += "\nAssignee: " + tgbotapi.EscapeText(tgbotapi.ModeMarkdown, assignee)
+	}
 
 	switch llmTag {
 	case "date":
@@ -199,7 +606,7 @@ func (s *Scheduler) sendNotification(task notion.Task, hasDate bool) error {
 				"[Open in Notion](%s)", taskPreview, taskURL)
 		} else {
 			// Task has date, no notification needed
-			return nil
+			return 0, nil
 		}
 
 	case "journal":
@@ -216,21 +623,38 @@ func (s *Scheduler) sendNotification(task notion.Task, hasDate bool) error {
 
 	default:
 		// No notification for regular tasks
-		return nil
+		return 0, nil
 	}
 
 	// Send message to authorized user
 	msg := tgbotapi.NewMessage(s.authorizedUserID, message)
 	msg.ParseMode = "Markdown"
 	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = snoozeKeyboard(task.ID)
 
-	_, err := s.bot.Send(msg)
+	sent, err := s.sendWithRetry(msg)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return 0, fmt.Errorf("failed to send message: %w", err)
 	}
 
 	log.Printf("Sent notification for task %s (tag: %s)", task.ID, llmTag)
-	return nil
+	return sent.MessageID, nil
+}
+
+// assigneeNames returns the names of the people a task is assigned to, joined for display
+func assigneeNames(task notion.Task) string {
+	names, _ := task.Properties[notion.AssigneePropertyKey].([]string)
+	return strings.Join(names, ", ")
+}
+
+// snoozeKeyboard builds the "Snooze" button of a notification, which the bot's callback
+// handler answers by tagging the task sometimes-later
+func snoozeKeyboard(taskID string) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💤 Snooze", snoozeCallbackPrefix+taskID),
+		),
+	)
 }
 
 // truncateString truncates a string to maxLen characters (UTF-8 safe)
@@ -245,8 +669,8 @@ func truncateString(s string, maxLen int) string {
 // ensureTagsForUndoneTasks tags all undone tasks (excluding 'sometimes-later') that lack an llm_tag
 // Returns an error if the operation fails critically
 func (s *Scheduler) ensureTagsForUndoneTasks(ctx context.Context) error {
-	if s.geminiClient == nil {
-		log.Printf("Gemini client not configured; skipping pre-tagging step")
+	if s.tagger == nil {
+		log.Printf("LLM not configured; skipping pre-tagging step")
 		return nil
 	}
 
@@ -270,25 +694,22 @@ func (s *Scheduler) ensureTagsForUndoneTasks(ctx context.Context) error {
 			continue
 		}
 
-		// Get tag from Gemini
-		tag, err := s.geminiClient.TagTask(task.Title)
+		// Get tag from the LLM
+		tag, err := s.tagger.TagTask(ctx, task.Title)
 		if err != nil || strings.TrimSpace(tag) == "" {
 			if err != nil {
-				log.Printf("Pre-tagging: gemini failed for %s: %v", task.ID, err)
+				log.Printf("Pre-tagging: tagging failed for %s: %v", task.ID, err)
 			}
-			tag = "task"
+			tag = llm.DefaultTag
 		}
 
-		if err := s.notionClient.UpdateTaskLLMTag(task.ID, tag); err != nil {
+		if err := s.notionClient.UpdateTaskLLMTag(ctx, task.ID, tag); err != nil {
 			log.Printf("Pre-tagging: failed to update llm_tag for %s: %v", task.ID, err)
 			errors++
 		} else {
 			log.Printf("Pre-tagging: successfully tagged task %s with '%s'", task.ID, tag)
 			tagged++
 		}
-
-		// Small delay to avoid rate limits
-		time.Sleep(300 * time.Millisecond)
 	}
 
 	log.Printf("Pre-tagging complete. tagged=%d skipped=%d errors=%d", tagged, skipped, errors)
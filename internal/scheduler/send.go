@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxSendAttempts is how often a message is sent before giving up
+	maxSendAttempts = 3
+	// defaultSendBackoff is the wait before retrying a message when Telegram doesn't say how
+	// long to wait, multiplied by the attempt
+	defaultSendBackoff = 2 * time.Second
+)
+
+// sendWithRetry sends a message, retrying when Telegram is unreachable, fails on its side or
+// answers 429 Too Many Requests. It waits the retry_after Telegram asks for, or a growing
+// backoff without one. Requests Telegram rejects, like 400 Bad Request, aren't retried.
+func (s *Scheduler) sendWithRetry(msg tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var sent tgbotapi.Message
+	var err error
+	for attempt := 1; attempt <= maxSendAttempts; attempt++ {
+		sent, err = s.bot.Send(msg)
+		if err == nil || !isTransientSendError(err) || attempt == maxSendAttempts {
+			return sent, err
+		}
+
+		wait := time.Duration(attempt) * s.sendBackoff
+		var tgErr *tgbotapi.Error
+		if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+			wait = time.Duration(tgErr.RetryAfter) * time.Second
+		}
+		log.Printf("Sending to Telegram failed (%v), retrying in %v (attempt %d/%d)", err, wait, attempt, maxSendAttempts)
+		time.Sleep(wait)
+	}
+	return sent, err
+}
+
+// isTransientSendError reports whether a failed send may succeed later: Telegram couldn't
+// be reached or answered, failed on its side or rate limited the bot
+func isTransientSendError(err error) bool {
+	var tgErr *tgbotapi.Error
+	if !errors.As(err, &tgErr) {
+		return true
+	}
+	return tgErr.Code == http.StatusTooManyRequests || tgErr.Code >= http.StatusInternalServerError
+}
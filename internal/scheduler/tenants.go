@@ -0,0 +1,70 @@
+package scheduler
+
+import (
+	"log"
+
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+	"github.com/numero_quadro/notion-mini-app/internal/tenant"
+)
+
+// SetTenants makes the scheduler also run the checks, reviews and reminders of the users
+// who connected their own workspace, sent to them
+func (s *Scheduler) SetTenants(r *tenant.Registry) {
+	s.tenants = r
+}
+
+// tenantSchedulers returns a scheduler per connected user. They are kept across ticks so
+// they remember what already ran, and replaced when the user connects another workspace.
+func (s *Scheduler) tenantSchedulers() []*Scheduler {
+	if s.tenants == nil {
+		return nil
+	}
+	ids, err := s.tenants.Connected()
+	if err != nil {
+		log.Printf("Warning: Could not list connected workspaces: %v", err)
+		return nil
+	}
+
+	current := make(map[int64]*Scheduler, len(ids))
+	var schedulers []*Scheduler
+	for _, userID := range ids {
+		client := s.tenants.Client(userID)
+		if client == nil {
+			continue
+		}
+		connected := s.perTenant[userID]
+		if connected == nil || connected.notionClient != client {
+			connected = s.forTenant(userID, client)
+		}
+		current[userID] = connected
+		schedulers = append(schedulers, connected)
+	}
+	s.perTenant = current
+	return schedulers
+}
+
+// forTenant creates a scheduler with the same settings for a connected user's workspace
+func (s *Scheduler) forTenant(userID int64, client *notion.Client) *Scheduler {
+	return &Scheduler{
+		notionClient:     client,
+		bot:              s.bot,
+		authorizedUserID: userID,
+		checkTimes:       s.checkTimes,
+		skipDays:         s.skipDays,
+		timezone:         s.timezone,
+		tagger:           s.tagger,
+		summarizer:       s.summarizer,
+		db:               s.db,
+		collapseDigests:  s.collapseDigests,
+		autoMoveJournal:  s.autoMoveJournal,
+
+		perTaskNotifications: s.perTaskNotifications,
+		sendBackoff:          s.sendBackoff,
+
+		reviewDay:  s.reviewDay,
+		reviewTime: s.reviewTime,
+
+		reminderLead: s.reminderLead,
+		workspace:    userID,
+	}
+}
@@ -0,0 +1,291 @@
+// Package schemawatch detects changes to Notion database schemas, so renamed or deleted
+// properties are noticed when they happen rather than when something breaks later.
+package schemawatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/alerts"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// RecentChangesLimit is how many diffs the schema-changes endpoint returns
+const RecentChangesLimit = 20
+
+// Change kinds
+const (
+	ChangeAdded          = "added"
+	ChangeRemoved        = "removed"
+	ChangeRenamed        = "renamed"
+	ChangeRetyped        = "retyped"
+	ChangeOptionsChanged = "options_changed"
+)
+
+// watchedProperties are the tasks database properties the bot reads or writes by name.
+// The title property is watched by type since its name varies.
+var watchedProperties = map[string]bool{
+	"status":  true,
+	"date":    true,
+	"tags":    true,
+	"llm_tag": true,
+	"project": true,
+}
+
+// Option is a select, multi-select or status option
+type Option struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+// Property is the part of a property config that matters for diffs
+type Property struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Options []Option `json:"options,omitempty"`
+}
+
+// Snapshot is a database schema keyed by property ID, which survives renames
+type Snapshot map[string]Property
+
+// Change is a single difference between two snapshots
+type Change struct {
+	Kind             string   `json:"kind"`
+	Property         string   `json:"property"`
+	NewName          string   `json:"new_name,omitempty"`
+	OldType          string   `json:"old_type,omitempty"`
+	NewType          string   `json:"new_type,omitempty"`
+	AddedOptions     []string `json:"added_options,omitempty"`
+	RemovedOptions   []string `json:"removed_options,omitempty"`
+	RecoloredOptions []string `json:"recolored_options,omitempty"`
+}
+
+// NewSnapshot converts a schema returned by the Notion client into a snapshot
+func NewSnapshot(properties map[string]notionapi.PropertyConfig) Snapshot {
+	snapshot := make(Snapshot, len(properties))
+
+	for name, config := range properties {
+		// The config types differ per property type, their JSON form is uniform
+		var raw struct {
+			ID          string                     `json:"id"`
+			Type        string                     `json:"type"`
+			Select      struct{ Options []Option } `json:"select"`
+			MultiSelect struct{ Options []Option } `json:"multi_select"`
+			Status      struct{ Options []Option } `json:"status"`
+		}
+		if data, err := json.Marshal(config); err == nil {
+			json.Unmarshal(data, &raw)
+		}
+
+		property := Property{ID: raw.ID, Name: name, Type: string(config.GetType())}
+		property.Options = append(property.Options, raw.Select.Options...)
+		property.Options = append(property.Options, raw.MultiSelect.Options...)
+		property.Options = append(property.Options, raw.Status.Options...)
+
+		key := raw.ID
+		if key == "" {
+			key = "name:" + name
+		}
+		snapshot[key] = property
+	}
+
+	return snapshot
+}
+
+// Diff lists the changes from old to new. Option order is ignored, and so are option colors
+// unless trackColors is set.
+func Diff(old, new Snapshot, trackColors bool) []Change {
+	var changes []Change
+
+	for key, before := range old {
+		after, ok := new[key]
+		if !ok {
+			changes = append(changes, Change{Kind: ChangeRemoved, Property: before.Name, OldType: before.Type})
+			continue
+		}
+
+		if after.Name != before.Name {
+			changes = append(changes, Change{Kind: ChangeRenamed, Property: before.Name, NewName: after.Name})
+		}
+		if after.Type != before.Type {
+			changes = append(changes, Change{Kind: ChangeRetyped, Property: before.Name, OldType: before.Type, NewType: after.Type})
+			continue
+		}
+
+		if change, ok := diffOptions(before, after, trackColors); ok {
+			changes = append(changes, change)
+		}
+	}
+
+	for key, after := range new {
+		if _, ok := old[key]; !ok {
+			changes = append(changes, Change{Kind: ChangeAdded, Property: after.Name, NewType: after.Type})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Property != changes[j].Property {
+			return changes[i].Property < changes[j].Property
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
+
+// diffOptions compares the options of a property by name
+func diffOptions(before, after Property, trackColors bool) (Change, bool) {
+	oldOptions := make(map[string]string, len(before.Options))
+	for _, option := range before.Options {
+		oldOptions[option.Name] = option.Color
+	}
+	newOptions := make(map[string]string, len(after.Options))
+	for _, option := range after.Options {
+		newOptions[option.Name] = option.Color
+	}
+
+	change := Change{Kind: ChangeOptionsChanged, Property: before.Name}
+	for name, color := range newOptions {
+		oldColor, ok := oldOptions[name]
+		switch {
+		case !ok:
+			change.AddedOptions = append(change.AddedOptions, name)
+		case trackColors && oldColor != color:
+			change.RecoloredOptions = append(change.RecoloredOptions, name)
+		}
+	}
+	for name := range oldOptions {
+		if _, ok := newOptions[name]; !ok {
+			change.RemovedOptions = append(change.RemovedOptions, name)
+		}
+	}
+
+	if len(change.AddedOptions) == 0 && len(change.RemovedOptions) == 0 && len(change.RecoloredOptions) == 0 {
+		return Change{}, false
+	}
+	sort.Strings(change.AddedOptions)
+	sort.Strings(change.RemovedOptions)
+	sort.Strings(change.RecoloredOptions)
+	return change, true
+}
+
+// BreakingChanges filters the changes that break properties the bot relies on: removing,
+// renaming or retyping the title or one of the watched properties
+func BreakingChanges(changes []Change) []Change {
+	var breaking []Change
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeRemoved, ChangeRenamed, ChangeRetyped:
+		default:
+			continue
+		}
+		if watchedProperties[strings.ToLower(change.Property)] || change.OldType == string(notionapi.PropertyConfigTypeTitle) {
+			breaking = append(breaking, change)
+		}
+	}
+	return breaking
+}
+
+// describe renders a change for an alert
+func describe(change Change) string {
+	switch change.Kind {
+	case ChangeRemoved:
+		return fmt.Sprintf("%q was removed", change.Property)
+	case ChangeRenamed:
+		return fmt.Sprintf("%q was renamed to %q", change.Property, change.NewName)
+	case ChangeRetyped:
+		return fmt.Sprintf("%q changed type from %s to %s", change.Property, change.OldType, change.NewType)
+	}
+	return fmt.Sprintf("%q changed (%s)", change.Property, change.Kind)
+}
+
+// Watcher compares every freshly fetched schema with the previous one stored in the database
+type Watcher struct {
+	db          *database.DB
+	alerter     alerts.Alerter // Optional, nil disables alerts
+	trackColors bool           // Report option color changes (OPTION_COLOR_TRACKING)
+	mu          sync.Mutex
+}
+
+// NewWatcher creates a watcher persisting snapshots and diffs in db
+func NewWatcher(db *database.DB, alerter alerts.Alerter) *Watcher {
+	return &Watcher{
+		db:          db,
+		alerter:     alerter,
+		trackColors: os.Getenv("OPTION_COLOR_TRACKING") == "true",
+	}
+}
+
+// Observe diffs a schema against the stored snapshot, records the diff and alerts on
+// breaking changes to the tasks database. It matches notion.Client.SetSchemaHook.
+func (w *Watcher) Observe(dbID, dbType string, properties map[string]notionapi.PropertyConfig) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	current := NewSnapshot(properties)
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		log.Printf("Warning: Failed to encode schema snapshot: %v", err)
+		return
+	}
+
+	previousJSON, err := w.db.GetSchemaSnapshot(dbID)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return
+	}
+
+	if string(currentJSON) == previousJSON {
+		return
+	}
+
+	if previousJSON != "" {
+		var previous Snapshot
+		if err := json.Unmarshal([]byte(previousJSON), &previous); err != nil {
+			log.Printf("Warning: Ignoring unreadable schema snapshot for %s: %v", dbID, err)
+		} else if changes := Diff(previous, current, w.trackColors); len(changes) > 0 {
+			w.record(dbID, dbType, changes, string(currentJSON))
+			return
+		}
+	}
+
+	if err := w.db.SaveSchemaSnapshot(dbID, string(currentJSON)); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// record persists a diff with the snapshot it leads to and sends an alert for breaking
+// changes
+func (w *Watcher) record(dbID, dbType string, changes []Change, snapshot string) {
+	log.Printf("Detected %d schema change(s) in %s database", len(changes), dbType)
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		log.Printf("Warning: Failed to encode schema changes: %v", err)
+		return
+	}
+	if err := w.db.StoreSchemaChangesWithSnapshot(dbID, dbType, changesJSON, snapshot, time.Now()); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	breaking := BreakingChanges(changes)
+	if dbType != "tasks" || len(breaking) == 0 || w.alerter == nil {
+		return
+	}
+
+	descriptions := make([]string, len(breaking))
+	for i, change := range breaking {
+		descriptions[i] = describe(change)
+	}
+	text := fmt.Sprintf("Notion tasks database schema changed, the bot may stop working: %s", strings.Join(descriptions, "; "))
+	if err := w.alerter.Alert(text); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,183 @@
+package schemawatch
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jomei/notionapi"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+// fakeAlerter records the alerts it receives
+type fakeAlerter struct {
+	texts []string
+}
+
+func (f *fakeAlerter) Alert(text string) error {
+	f.texts = append(f.texts, text)
+	return nil
+}
+
+func selectConfig(id string, options ...notionapi.Option) notionapi.SelectPropertyConfig {
+	return notionapi.SelectPropertyConfig{
+		ID:     notionapi.ObjectID(id),
+		Type:   notionapi.PropertyConfigTypeSelect,
+		Select: notionapi.Select{Options: options},
+	}
+}
+
+// tasksSchema returns a tasks database schema with the given Tags options
+func tasksSchema(tags ...notionapi.Option) map[string]notionapi.PropertyConfig {
+	return map[string]notionapi.PropertyConfig{
+		"Name":   notionapi.TitlePropertyConfig{ID: "title", Type: notionapi.PropertyConfigTypeTitle},
+		"Date":   notionapi.DatePropertyConfig{ID: "d1", Type: notionapi.PropertyConfigTypeDate},
+		"Status": selectConfig("s1", notionapi.Option{Name: "todo", Color: "red"}, notionapi.Option{Name: "done", Color: "green"}),
+		"Tags":   selectConfig("t1", tags...),
+		"Notes":  notionapi.RichTextPropertyConfig{ID: "n1", Type: notionapi.PropertyConfigTypeRichText},
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := NewSnapshot(tasksSchema(notionapi.Option{Name: "work", Color: "blue"}))
+
+	cases := []struct {
+		name     string
+		change   func(map[string]notionapi.PropertyConfig)
+		expected []Change
+	}{
+		{
+			name: "rename keeps the property ID",
+			change: func(props map[string]notionapi.PropertyConfig) {
+				props["Due"] = props["Date"]
+				delete(props, "Date")
+			},
+			expected: []Change{{Kind: ChangeRenamed, Property: "Date", NewName: "Due"}},
+		},
+		{
+			name:     "delete",
+			change:   func(props map[string]notionapi.PropertyConfig) { delete(props, "Notes") },
+			expected: []Change{{Kind: ChangeRemoved, Property: "Notes", OldType: "rich_text"}},
+		},
+		{
+			name: "retype",
+			change: func(props map[string]notionapi.PropertyConfig) {
+				props["Date"] = notionapi.RichTextPropertyConfig{ID: "d1", Type: notionapi.PropertyConfigTypeRichText}
+			},
+			expected: []Change{{Kind: ChangeRetyped, Property: "Date", OldType: "date", NewType: "rich_text"}},
+		},
+		{
+			name: "add property and option",
+			change: func(props map[string]notionapi.PropertyConfig) {
+				props["Tags"] = selectConfig("t1", notionapi.Option{Name: "home"}, notionapi.Option{Name: "work", Color: "blue"})
+				props["Project"] = selectConfig("p1")
+			},
+			expected: []Change{
+				{Kind: ChangeAdded, Property: "Project", NewType: "select"},
+				{Kind: ChangeOptionsChanged, Property: "Tags", AddedOptions: []string{"home"}},
+			},
+		},
+		{
+			name: "color changes are ignored by default",
+			change: func(props map[string]notionapi.PropertyConfig) {
+				props["Tags"] = selectConfig("t1", notionapi.Option{Name: "work", Color: "pink"})
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			props := tasksSchema(notionapi.Option{Name: "work", Color: "blue"})
+			c.change(props)
+
+			if changes := Diff(old, NewSnapshot(props), false); !reflect.DeepEqual(changes, c.expected) {
+				t.Errorf("Expected %+v, got %+v", c.expected, changes)
+			}
+		})
+	}
+}
+
+func TestDiffTracksColors(t *testing.T) {
+	old := NewSnapshot(tasksSchema(notionapi.Option{Name: "work", Color: "blue"}))
+	new := NewSnapshot(tasksSchema(notionapi.Option{Name: "work", Color: "pink"}))
+
+	expected := []Change{{Kind: ChangeOptionsChanged, Property: "Tags", RecoloredOptions: []string{"work"}}}
+	if changes := Diff(old, new, true); !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, changes)
+	}
+}
+
+func TestBreakingChanges(t *testing.T) {
+	changes := []Change{
+		{Kind: ChangeRenamed, Property: "Name", NewName: "Task"},
+		{Kind: ChangeRemoved, Property: "Title", OldType: "title"},
+		{Kind: ChangeRemoved, Property: "Notes", OldType: "rich_text"},
+		{Kind: ChangeRetyped, Property: "Status", OldType: "select", NewType: "status"},
+		{Kind: ChangeOptionsChanged, Property: "Tags", RemovedOptions: []string{"work"}},
+		{Kind: ChangeAdded, Property: "Date", NewType: "date"},
+	}
+
+	expected := []Change{changes[1], changes[3]}
+	if breaking := BreakingChanges(changes); !reflect.DeepEqual(breaking, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, breaking)
+	}
+}
+
+func TestWatcherObserve(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	alerter := &fakeAlerter{}
+	watcher := NewWatcher(db, alerter)
+
+	// The first schema is only stored
+	watcher.Observe("tasks-db", "tasks", tasksSchema())
+	if records, _ := db.GetRecentSchemaChanges(RecentChangesLimit); len(records) != 0 {
+		t.Fatalf("No changes expected for the first snapshot, got %d", len(records))
+	}
+
+	// A non-breaking change is recorded without an alert
+	props := tasksSchema()
+	delete(props, "Notes")
+	watcher.Observe("tasks-db", "tasks", props)
+	if len(alerter.texts) != 0 {
+		t.Errorf("No alert expected for a non-breaking change, got %v", alerter.texts)
+	}
+
+	// Removing Status breaks the bot
+	delete(props, "Status")
+	watcher.Observe("tasks-db", "tasks", props)
+	if len(alerter.texts) != 1 || !strings.Contains(alerter.texts[0], `"Status" was removed`) {
+		t.Errorf("Expected an alert about Status, got %v", alerter.texts)
+	}
+
+	// Breaking changes outside the tasks database are recorded but not alerted
+	watcher.Observe("notes-db", "notes", tasksSchema())
+	watcher.Observe("notes-db", "notes", map[string]notionapi.PropertyConfig{})
+	if len(alerter.texts) != 1 {
+		t.Errorf("Expected no alerts for the notes database, got %v", alerter.texts)
+	}
+
+	records, err := db.GetRecentSchemaChanges(RecentChangesLimit)
+	if err != nil {
+		t.Fatalf("GetRecentSchemaChanges failed: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 recorded diffs, got %d", len(records))
+	}
+
+	// Newest first
+	var changes []Change
+	if err := json.Unmarshal(records[1].Changes, &changes); err != nil {
+		t.Fatalf("Invalid changes JSON: %v", err)
+	}
+	expected := []Change{{Kind: ChangeRemoved, Property: "Status", OldType: "select"}}
+	if records[1].DatabaseID != "tasks-db" || !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %+v for tasks-db, got %s %+v", expected, records[1].DatabaseID, changes)
+	}
+}
@@ -0,0 +1,122 @@
+// Package selfcheck validates the configured external services, at startup so a wrong
+// token or database ID fails fast instead of on the first saved task, and for readiness
+// probes.
+package selfcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// checkTimeout bounds each check, a hanging service must not stall startup
+const checkTimeout = 15 * time.Second
+
+// ErrNotConfigured is returned by a check for a component that isn't set up, it's skipped
+var ErrNotConfigured = errors.New("not configured")
+
+// Check validates one component. Run returns a short detail shown on success, such as
+// the bot's username.
+type Check struct {
+	Component string
+	Optional  bool // Failures only warn, the bot works without the component
+	Run       func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Component string
+	Optional  bool
+	Detail    string
+	Err       error
+}
+
+// Status is OK, SKIP for components that aren't configured, WARN for failed optional
+// components and FAIL otherwise
+func (r Result) Status() string {
+	switch {
+	case r.Err == nil:
+		return "OK"
+	case errors.Is(r.Err, ErrNotConfigured):
+		return "SKIP"
+	case r.Optional:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Run performs the checks in order
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		detail, err := check.Run(checkCtx)
+		cancel()
+		results = append(results, Result{Component: check.Component, Optional: check.Optional, Detail: detail, Err: err})
+	}
+	return results
+}
+
+// Passed reports whether every required component passed. Skipped required components
+// fail too, the bot can't run without them.
+func Passed(results []Result) bool {
+	for _, result := range results {
+		if result.Err != nil && !result.Optional {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTable writes one aligned line per component with its status and detail or error
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "COMPONENT\tSTATUS\tDETAIL")
+	for _, result := range results {
+		detail := result.Detail
+		if result.Err != nil {
+			detail = result.Err.Error()
+		}
+		// Keep multi-line API errors on their row
+		detail = strings.Join(strings.Fields(detail), " ")
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", result.Component, result.Status(), detail)
+	}
+	return tw.Flush()
+}
+
+// Prober runs checks at most once per interval and serves the cached results in between,
+// so frequent health probes don't turn into API calls
+type Prober struct {
+	checks   []Check
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex // Held while checking, concurrent probes wait for the same run
+	checkedAt time.Time
+	results   []Result
+}
+
+// NewProber creates a prober running the checks at most once per interval
+func NewProber(checks []Check, interval time.Duration) *Prober {
+	return &Prober{checks: checks, interval: interval, now: time.Now}
+}
+
+// Results returns the latest results, running the checks when they are older than the
+// interval. It also returns when they were checked.
+func (p *Prober) Results(ctx context.Context) ([]Result, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.results == nil || p.now().Sub(p.checkedAt) >= p.interval {
+		// A probe giving up must not cache its cancelled checks as failures
+		p.results = Run(context.WithoutCancel(ctx), p.checks)
+		p.checkedAt = p.now()
+	}
+	return p.results, p.checkedAt
+}
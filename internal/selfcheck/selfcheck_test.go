@@ -0,0 +1,92 @@
+package selfcheck
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func check(component string, optional bool, err error) Check {
+	return Check{Component: component, Optional: optional, Run: func(context.Context) (string, error) {
+		return "fine", err
+	}}
+}
+
+func TestOptionalFailuresOnlyWarn(t *testing.T) {
+	results := Run(context.Background(), []Check{
+		check("telegram", false, nil),
+		check("gemini", true, errors.New("invalid key")),
+		check("journal", true, ErrNotConfigured),
+	})
+
+	if !Passed(results) {
+		t.Error("Expected optional failures not to fail the check")
+	}
+	want := []string{"OK", "WARN", "SKIP"}
+	for i, result := range results {
+		if result.Status() != want[i] {
+			t.Errorf("Expected %s for %s, got %s", want[i], result.Component, result.Status())
+		}
+	}
+}
+
+func TestRequiredFailureFails(t *testing.T) {
+	for _, err := range []error{errors.New("404 not found"), ErrNotConfigured} {
+		results := Run(context.Background(), []Check{check("tasks", false, err)})
+		if Passed(results) {
+			t.Errorf("Expected a required component failing with %v to fail the check", err)
+		}
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	var out strings.Builder
+	err := WriteTable(&out, []Result{
+		{Component: "telegram", Detail: "@bot"},
+		{Component: "tasks database", Err: errors.New("could not find\ndatabase")},
+	})
+	if err != nil {
+		t.Fatalf("WriteTable failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %q", out.String())
+	}
+	if !strings.Contains(lines[1], "OK") || !strings.Contains(lines[1], "@bot") {
+		t.Errorf("Expected an OK row with the detail, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], "FAIL") || !strings.Contains(lines[2], "could not find database") {
+		t.Errorf("Expected a FAIL row with the error on one line, got %q", lines[2])
+	}
+}
+
+func TestProberCachesResults(t *testing.T) {
+	runs := 0
+	var failing error
+	prober := NewProber([]Check{{Component: "notion", Run: func(context.Context) (string, error) {
+		runs++
+		return "", failing
+	}}}, 30*time.Second)
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	prober.now = func() time.Time { return now }
+
+	prober.Results(context.Background())
+	failing = errors.New("unauthorized")
+	now = now.Add(29 * time.Second)
+	results, _ := prober.Results(context.Background())
+	if runs != 1 || !Passed(results) {
+		t.Fatalf("Expected the cached passing result within the interval, got %d runs", runs)
+	}
+
+	now = now.Add(time.Second)
+	results, checkedAt := prober.Results(context.Background())
+	if runs != 2 || Passed(results) {
+		t.Fatalf("Expected a new failing run after the interval, got %d runs", runs)
+	}
+	if !checkedAt.Equal(now) {
+		t.Errorf("Expected the results to be checked at %v, got %v", now, checkedAt)
+	}
+}
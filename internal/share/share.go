@@ -0,0 +1,237 @@
+// Package share serves public read-only snapshots of open tasks matching a tag or project,
+// so a list can be shared with a plain link and without access to the bot or Notion
+package share
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+const (
+	// DefaultTTL is how long a share link works
+	DefaultTTL = 7 * 24 * time.Hour
+	// PathPrefix is where share pages are served, followed by the slug
+	PathPrefix = "/notion/mini-app/share/"
+
+	// tasksCacheTTL is how long fetched tasks are reused across page views
+	tasksCacheTTL = time.Minute
+	// slugBytes is the amount of randomness in a slug
+	slugBytes = 16
+	// maxSlugAttempts bounds retries when a generated slug is already taken
+	maxSlugAttempts = 3
+)
+
+// NewSlug returns a random URL-safe slug
+func NewSlug() (string, error) {
+	b := make([]byte, slugBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate slug: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Create stores a new share for filter expiring after DefaultTTL and returns it
+func Create(db *database.DB, filter string, now time.Time) (*database.Share, error) {
+	if err := db.DeleteExpiredShares(now); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	var err error
+	for attempt := 0; attempt < maxSlugAttempts; attempt++ {
+		var slug string
+		if slug, err = NewSlug(); err != nil {
+			return nil, err
+		}
+
+		share := database.Share{Slug: slug, Filter: filter, CreatedAt: now, ExpiresAt: now.Add(DefaultTTL)}
+		if err = db.CreateShare(share); err == nil {
+			return &share, nil
+		}
+	}
+	return nil, err
+}
+
+// Matches reports whether a task has filter as one of its tags or as its project
+func Matches(task notion.Task, filter string) bool {
+	for key, value := range task.Properties {
+		switch {
+		case strings.EqualFold(key, "tags"):
+			if tags, ok := value.([]string); ok {
+				for _, tag := range tags {
+					if strings.EqualFold(tag, filter) {
+						return true
+					}
+				}
+			}
+		case strings.EqualFold(key, "project"):
+			if project, ok := value.(string); ok && strings.EqualFold(project, filter) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// TaskSource lists open tasks, implemented by notion.Client
+type TaskSource interface {
+	GetRecentTasks(ctx context.Context, dbType string, limit int) ([]notion.Task, error)
+}
+
+// Server renders share pages
+type Server struct {
+	db    *database.DB // nil disables sharing
+	tasks TaskSource
+	now   func() time.Time
+
+	mu            sync.Mutex
+	cachedTasks   []notion.Task
+	cachedTasksAt time.Time
+}
+
+// NewServer creates a server for shares stored in db, listing tasks from source
+func NewServer(db *database.DB, source TaskSource) *Server {
+	return &Server{db: db, tasks: source, now: time.Now}
+}
+
+// row is a task as shown on a share page
+type row struct {
+	Title  string
+	Date   string
+	Status string
+}
+
+var pageTemplate = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Filter}}</title>
+<style>
+body { font-family: -apple-system, sans-serif; margin: 1.5em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 0.4em 0.6em; border-bottom: 1px solid #eee; }
+.muted { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Filter}}</h1>
+{{if .Rows}}<table>
+<tr><th>Task</th><th>Date</th><th>Status</th></tr>
+{{range .Rows}}<tr><td>{{.Title}}</td><td>{{.Date}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>{{else}}<p class="muted">Nothing open right now.</p>{{end}}
+<p class="muted">Updated {{.UpdatedAt}} · link expires {{.ExpiresAt}}</p>
+</body>
+</html>
+`))
+
+// ServeHTTP renders the share page for the slug in the request path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	slug := strings.TrimPrefix(r.URL.Path, PathPrefix)
+	if s.db == nil || slug == "" || strings.Contains(slug, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	share, err := s.db.GetShare(slug)
+	if err != nil {
+		log.Printf("Error loading share: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	now := s.now()
+	if share == nil || !now.Before(share.ExpiresAt) {
+		http.Error(w, "This link has expired or was revoked.", http.StatusNotFound)
+		return
+	}
+
+	tasks, err := s.openTasks(r.Context())
+	if err != nil {
+		log.Printf("Error loading tasks for share: %v", err)
+		http.Error(w, "Tasks are unavailable right now, try again later.", http.StatusBadGateway)
+		return
+	}
+
+	rows := make([]row, 0)
+	for _, task := range tasks {
+		if !Matches(task, share.Filter) {
+			continue
+		}
+		rows = append(rows, row{Title: task.Title, Date: taskDate(task), Status: taskStatus(task)})
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		// Dated tasks first, soonest first
+		if (rows[i].Date == "") != (rows[j].Date == "") {
+			return rows[j].Date == ""
+		}
+		return rows[i].Date < rows[j].Date
+	})
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if err := pageTemplate.Execute(w, map[string]interface{}{
+		"Filter":    share.Filter,
+		"Rows":      rows,
+		"UpdatedAt": now.Format("02 Jan 15:04"),
+		"ExpiresAt": share.ExpiresAt.In(now.Location()).Format("02 Jan 2006"),
+	}); err != nil {
+		log.Printf("Error rendering share page: %v", err)
+	}
+}
+
+// openTasks returns the open tasks, reusing a recent fetch
+func (s *Server) openTasks(ctx context.Context) ([]notion.Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cachedTasks != nil && s.now().Sub(s.cachedTasksAt) < tasksCacheTTL {
+		return s.cachedTasks, nil
+	}
+
+	tasks, err := s.tasks.GetRecentTasks(ctx, "tasks", 100)
+	if err != nil {
+		return nil, err
+	}
+	s.cachedTasks = tasks
+	s.cachedTasksAt = s.now()
+	return tasks, nil
+}
+
+// taskDate formats the Date property of a task, dates sort as strings
+func taskDate(task notion.Task) string {
+	date, _ := task.Properties["Date"].(string)
+	if t, err := time.Parse(time.RFC3339, date); err == nil {
+		return t.Format("2006-01-02")
+	}
+	return date
+}
+
+// taskStatus returns the status of a task
+func taskStatus(task notion.Task) string {
+	for key, value := range task.Properties {
+		if strings.EqualFold(key, "status") {
+			if status, ok := value.(string); ok {
+				return status
+			}
+		}
+	}
+	return ""
+}
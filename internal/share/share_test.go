@@ -0,0 +1,120 @@
+package share
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// fakeTasks serves a fixed task list and counts fetches
+type fakeTasks struct {
+	tasks   []notion.Task
+	fetches int
+}
+
+func (f *fakeTasks) GetRecentTasks(ctx context.Context, dbType string, limit int) ([]notion.Task, error) {
+	f.fetches++
+	return f.tasks, nil
+}
+
+func newTestServer(t *testing.T, tasks ...notion.Task) (*Server, *database.DB, *fakeTasks) {
+	t.Helper()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	source := &fakeTasks{tasks: tasks}
+	return NewServer(db, source), db, source
+}
+
+func get(server *Server, slug string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, PathPrefix+slug, nil))
+	return recorder
+}
+
+func TestSlugsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		slug, err := NewSlug()
+		if err != nil {
+			t.Fatalf("NewSlug failed: %v", err)
+		}
+		if seen[slug] {
+			t.Fatalf("Duplicate slug %s", slug)
+		}
+		if strings.ContainsAny(slug, "/+=") {
+			t.Fatalf("Slug %s is not URL-safe", slug)
+		}
+		seen[slug] = true
+	}
+}
+
+func TestShareExpiresAndRevokes(t *testing.T) {
+	server, db, _ := newTestServer(t, notion.Task{Title: "Buy milk", Properties: map[string]interface{}{"Tags": []string{"errands"}}})
+	now := time.Date(2025, 10, 12, 12, 0, 0, 0, time.UTC)
+	server.now = func() time.Time { return now }
+
+	created, err := Create(db, "errands", now)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if resp := get(server, created.Slug); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "Buy milk") {
+		t.Fatalf("Expected the task list, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	now = now.Add(DefaultTTL)
+	if resp := get(server, created.Slug); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected an expired link to be gone, got %d", resp.Code)
+	}
+
+	now = time.Date(2025, 10, 12, 12, 0, 0, 0, time.UTC)
+	if deleted, err := db.DeleteShare(created.Slug); err != nil || !deleted {
+		t.Fatalf("DeleteShare failed: %v", err)
+	}
+	if resp := get(server, created.Slug); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected a revoked link to be gone, got %d", resp.Code)
+	}
+	if resp := get(server, "unknown"); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown slug to be gone, got %d", resp.Code)
+	}
+}
+
+func TestSharePageEscapesAndFilters(t *testing.T) {
+	server, db, source := newTestServer(t,
+		notion.Task{ID: "1", URL: "https://notion.so/1", Title: `<script>alert("x")</script>`, Properties: map[string]interface{}{"project": "Errands"}},
+		notion.Task{ID: "2", Title: "Private thing", Properties: map[string]interface{}{"Tags": []string{"work"}}},
+	)
+
+	created, err := Create(db, "errands", time.Now())
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	body := get(server, created.Slug).Body.String()
+	if strings.Contains(body, "<script>") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("Title should be escaped, got: %s", body)
+	}
+	if strings.Contains(body, "Private thing") {
+		t.Error("Tasks outside the filter must not be listed")
+	}
+	if strings.Contains(body, "notion.so") {
+		t.Error("Share pages must not link back into Notion")
+	}
+
+	// A second view within a minute reuses the fetched tasks
+	get(server, created.Slug)
+	if source.fetches != 1 {
+		t.Errorf("Expected a single fetch, got %d", source.fetches)
+	}
+}
@@ -0,0 +1,259 @@
+// Package tenant lets trusted Telegram users connect their own Notion workspace. Their
+// tokens are stored encrypted in the local database and every user gets a Notion client of
+// their own, while everyone else keeps using the workspace configured in the environment.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/auth"
+	"github.com/numero_quadro/notion-mini-app/internal/crypto"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+// Credentials is what a user connects: a Notion integration token and their databases
+type Credentials struct {
+	UserID    int64
+	Token     string
+	TasksDbID string
+	NotesDbID string // Optional
+}
+
+// Registry stores the users' credentials and resolves the Notion client of each of them.
+// A nil Registry is valid and always resolves the fallback, which is what the bot does
+// without TENANT_ENCRYPTION_KEY.
+type Registry struct {
+	db        *database.DB
+	cipher    *crypto.Cipher
+	fallback  *notion.Client
+	allowed   map[int64]bool                                          // Who may connect (TENANT_USER_IDS)
+	newClient func(token, tasksDbID, notesDbID string) *notion.Client // Replaced in tests
+
+	mu      sync.Mutex
+	clients map[int64]*notion.Client // Connected users' clients, loaded lazily
+	known   map[int64]bool           // Whether a user has credentials, cached with clients
+}
+
+// NewRegistryFromEnv creates a registry keyed by TENANT_ENCRYPTION_KEY for the users in
+// TENANT_USER_IDS. It returns nil without a key, leaving the bot single-user.
+func NewRegistryFromEnv(db *database.DB, fallback *notion.Client) (*Registry, error) {
+	key, err := crypto.KeyFromEnv("TENANT_ENCRYPTION_KEY")
+	if err != nil || key == nil {
+		return nil, err
+	}
+	if db == nil {
+		return nil, errors.New("connecting workspaces needs the local database")
+	}
+	allowed, err := auth.ParseUserIDs(os.Getenv("TENANT_USER_IDS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANT_USER_IDS: %w", err)
+	}
+	if len(allowed) == 0 {
+		log.Printf("Warning: TENANT_USER_IDS is empty, nobody can connect a workspace")
+	}
+	return NewRegistry(db, key, fallback, allowed)
+}
+
+// NewRegistry creates a registry encrypting tokens with a 32 byte key
+func NewRegistry(db *database.DB, key []byte, fallback *notion.Client, allowed []int64) (*Registry, error) {
+	c, err := crypto.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Registry{
+		db:       db,
+		cipher:   c,
+		fallback: fallback,
+		allowed:  make(map[int64]bool),
+		newClient: func(token, tasksDbID, notesDbID string) *notion.Client {
+			return notion.NewWorkspaceClient(token, tasksDbID, notesDbID)
+		},
+		clients: make(map[int64]*notion.Client),
+		known:   make(map[int64]bool),
+	}
+	for _, id := range allowed {
+		r.allowed[id] = true
+	}
+	return r, nil
+}
+
+// CanConnect reports whether a user may connect their own workspace
+func (r *Registry) CanConnect(userID int64) bool {
+	return r != nil && r.allowed[userID]
+}
+
+// IsConnected reports whether a user has connected a workspace
+func (r *Registry) IsConnected(userID int64) bool {
+	return r.Client(userID) != nil
+}
+
+// ClientFor returns the Notion client of a connected user, or the fallback for anyone else
+func (r *Registry) ClientFor(userID int64) *notion.Client {
+	if client := r.Client(userID); client != nil {
+		return client
+	}
+	if r == nil {
+		return nil
+	}
+	return r.fallback
+}
+
+// Client returns the Notion client of a connected user, or nil if they haven't connected
+// one. Users removed from TENANT_USER_IDS keep their credentials but can't use them.
+func (r *Registry) Client(userID int64) *notion.Client {
+	if r == nil || !r.allowed[userID] {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if client, ok := r.clients[userID]; ok || r.known[userID] {
+		return client
+	}
+
+	creds, err := r.load(userID)
+	if err != nil {
+		// Not cached, so the next call tries again
+		log.Printf("Warning: Could not load the workspace of user %d: %v", userID, err)
+		return nil
+	}
+	r.known[userID] = true
+	if creds == nil {
+		return nil
+	}
+	client := r.newClient(creds.Token, creds.TasksDbID, creds.NotesDbID)
+	r.clients[userID] = client
+	return client
+}
+
+// Connected returns the IDs of the users with a usable workspace, in ascending order
+func (r *Registry) Connected() ([]int64, error) {
+	if r == nil {
+		return nil, nil
+	}
+	users, err := r.db.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for _, user := range users {
+		if r.allowed[user.TelegramUserID] {
+			ids = append(ids, user.TelegramUserID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// Validate checks credentials against Notion before they're saved: the token must be
+// accepted and the databases shared with its integration
+func (r *Registry) Validate(ctx context.Context, creds Credentials) error {
+	client := r.newClient(creds.Token, creds.TasksDbID, creds.NotesDbID)
+	if _, err := client.CheckToken(ctx); err != nil {
+		return err
+	}
+	if creds.TasksDbID != "" {
+		if _, err := client.CheckDatabase(ctx, "tasks"); err != nil {
+			return err
+		}
+	}
+	if creds.NotesDbID != "" {
+		if _, err := client.CheckDatabase(ctx, "notes"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save stores a user's credentials, replacing their previous ones
+func (r *Registry) Save(creds Credentials, now time.Time) error {
+	if !r.CanConnect(creds.UserID) {
+		return fmt.Errorf("user %d may not connect a workspace", creds.UserID)
+	}
+	if creds.Token == "" || creds.TasksDbID == "" {
+		return errors.New("a token and a tasks database are required")
+	}
+	token, err := r.encrypt(creds.UserID, creds.Token)
+	if err != nil {
+		return err
+	}
+
+	err = r.db.SaveUser(database.User{
+		TelegramUserID: creds.UserID,
+		NotionToken:    token,
+		TasksDbID:      creds.TasksDbID,
+		NotesDbID:      creds.NotesDbID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	})
+	if err != nil {
+		return err
+	}
+	r.forget(creds.UserID)
+	return nil
+}
+
+// Remove deletes a user's credentials, so they fall back to the environment's workspace,
+// and reports whether they had any
+func (r *Registry) Remove(userID int64) (bool, error) {
+	if r == nil {
+		return false, nil
+	}
+	deleted, err := r.db.DeleteUser(userID)
+	if err != nil {
+		return false, err
+	}
+	r.forget(userID)
+	return deleted, nil
+}
+
+// forget drops the cached client of a user whose credentials changed
+func (r *Registry) forget(userID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, userID)
+	delete(r.known, userID)
+}
+
+// load reads and decrypts a user's credentials, nil if they have none
+func (r *Registry) load(userID int64) (*Credentials, error) {
+	user, err := r.db.GetUser(userID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	token, err := r.decrypt(userID, user.NotionToken)
+	if err != nil {
+		return nil, err
+	}
+	return &Credentials{UserID: userID, Token: token, TasksDbID: user.TasksDbID, NotesDbID: user.NotesDbID}, nil
+}
+
+// encrypt seals a token. The user ID is authenticated with it, so a token copied to
+// another user's row doesn't decrypt.
+func (r *Registry) encrypt(userID int64, token string) ([]byte, error) {
+	return r.cipher.Seal([]byte(token), userData(userID))
+}
+
+// decrypt opens a token sealed by encrypt
+func (r *Registry) decrypt(userID int64, sealed []byte) (string, error) {
+	token, err := r.cipher.Open(sealed, userData(userID))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the stored token of user %d: %w", userID, err)
+	}
+	return string(token), nil
+}
+
+// userData is the additional data authenticated with a user's token
+func userData(userID int64) []byte {
+	return []byte(strconv.FormatInt(userID, 10))
+}
@@ -0,0 +1,124 @@
+package tenant
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/crypto"
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+	"github.com/numero_quadro/notion-mini-app/internal/notion"
+)
+
+var testKey = bytes.Repeat([]byte{7}, crypto.KeySize)
+
+func newTestRegistry(t *testing.T, key []byte, allowed ...int64) (*Registry, *database.DB, *notion.Client) {
+	t.Helper()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	t.Setenv("NOTION_TASKS_DATABASE_ID", "owner-tasks")
+	fallback := notion.NewClient()
+	registry, err := NewRegistry(db, key, fallback, allowed)
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	return registry, db, fallback
+}
+
+func TestCredentialsAreEncryptedAtRest(t *testing.T) {
+	registry, db, _ := newTestRegistry(t, testKey, 42)
+
+	creds := Credentials{UserID: 42, Token: "secret_abc123", TasksDbID: "tasks-42", NotesDbID: "notes-42"}
+	if err := registry.Save(creds, time.Now()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	stored, err := db.GetUser(42)
+	if err != nil || stored == nil {
+		t.Fatalf("Expected the stored user, got %v, %v", stored, err)
+	}
+	if bytes.Contains(stored.NotionToken, []byte(creds.Token)) {
+		t.Errorf("The token is stored in clear")
+	}
+
+	loaded, err := registry.load(42)
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if *loaded != creds {
+		t.Errorf("Expected %+v after the round trip, got %+v", creds, *loaded)
+	}
+
+	// Another key, or another user's row, doesn't decrypt
+	other, err := NewRegistry(db, bytes.Repeat([]byte{8}, crypto.KeySize), nil, []int64{42})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	if _, err := other.load(42); err == nil {
+		t.Errorf("Expected the token not to decrypt with another key")
+	}
+	stored.TelegramUserID = 43
+	if err := db.SaveUser(*stored); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+	if _, err := registry.load(43); err == nil {
+		t.Errorf("Expected a token copied to another user not to decrypt")
+	}
+}
+
+func TestClientForResolvesEachUsersWorkspace(t *testing.T) {
+	registry, db, fallback := newTestRegistry(t, testKey, 42, 43)
+
+	if err := registry.Save(Credentials{UserID: 42, Token: "secret_42", TasksDbID: "tasks-42"}, time.Now()); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	// Credentials of a user who is no longer in TENANT_USER_IDS
+	sealed, err := registry.encrypt(44, "secret_44")
+	if err != nil {
+		t.Fatalf("encrypt failed: %v", err)
+	}
+	if err := db.SaveUser(database.User{TelegramUserID: 44, NotionToken: sealed, TasksDbID: "tasks-44", CreatedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveUser failed: %v", err)
+	}
+
+	client := registry.ClientFor(42)
+	if client == fallback || client.GetTasksDatabaseID() != "tasks-42" {
+		t.Errorf("Expected user 42 to get their own workspace, got tasks database %q", client.GetTasksDatabaseID())
+	}
+	if again := registry.ClientFor(42); again != client {
+		t.Errorf("Expected the client to be reused")
+	}
+	if !registry.IsConnected(42) || registry.IsConnected(43) || registry.IsConnected(44) {
+		t.Errorf("Expected only user 42 to be connected")
+	}
+	for _, userID := range []int64{43, 44, 99} {
+		if got := registry.ClientFor(userID); got != fallback {
+			t.Errorf("Expected user %d to get the fallback client", userID)
+		}
+	}
+
+	connected, err := registry.Connected()
+	if err != nil || len(connected) != 1 || connected[0] != 42 {
+		t.Errorf("Expected only user 42 to be listed, got %v, %v", connected, err)
+	}
+
+	// Disconnecting falls back again
+	if removed, err := registry.Remove(42); err != nil || !removed {
+		t.Fatalf("Remove failed: %v, %v", removed, err)
+	}
+	if got := registry.ClientFor(42); got != fallback {
+		t.Errorf("Expected the fallback client after disconnecting")
+	}
+
+	// Without TENANT_ENCRYPTION_KEY there is no registry, callers keep using their own client
+	var single *Registry
+	if single.ClientFor(42) != nil || single.CanConnect(42) || single.IsConnected(42) {
+		t.Errorf("Expected a nil registry to resolve nothing")
+	}
+}
@@ -0,0 +1,128 @@
+// Package tracing provides optional OpenTelemetry tracing across the bot, Notion and Gemini.
+// Setting OTEL_EXPORTER_OTLP_ENDPOINT enables it; otherwise every helper is a no-op that
+// doesn't allocate.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	instrumentationName = "github.com/numero_quadro/notion-mini-app"
+	serviceName         = "notion-mini-app"
+)
+
+// tracer is the active tracer, nil while tracing is disabled
+var tracer atomic.Pointer[trace.Tracer]
+
+// noopSpan is returned while tracing is disabled
+var noopSpan = trace.SpanFromContext(context.Background())
+
+// Setup enables tracing when OTEL_EXPORTER_OTLP_ENDPOINT is set, using the standard OTLP
+// environment variables for the exporter. The returned function flushes pending spans.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attribute.String("service.name", serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	Use(provider)
+	return provider.Shutdown, nil
+}
+
+// Use installs a tracer provider, e.g. one with an in-memory exporter in tests.
+// A nil provider disables tracing.
+func Use(provider trace.TracerProvider) {
+	if provider == nil {
+		tracer.Store(nil)
+		return
+	}
+
+	otel.SetTracerProvider(provider)
+	t := provider.Tracer(instrumentationName)
+	tracer.Store(&t)
+}
+
+// Start starts a span as a child of any span in ctx. While tracing is disabled it returns
+// ctx unchanged and a no-op span.
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	t := tracer.Load()
+	if t == nil {
+		return ctx, noopSpan
+	}
+	return (*t).Start(ctx, name)
+}
+
+// RecordError marks a span as failed
+func RecordError(span trace.Span, err error) {
+	if err == nil || !span.IsRecording() {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// transport creates a client span for every request
+type transport struct {
+	base http.RoundTripper
+	name string
+}
+
+// Transport wraps base (http.DefaultTransport when nil) with a span named "<name> <method>"
+// per request. The URL path is recorded, so it must not contain secrets.
+func Transport(base http.RoundTripper, name string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, name: name}
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	active := tracer.Load()
+	if active == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, span := (*active).Start(req.Context(), t.name+" "+req.Method, trace.WithSpanKind(trace.SpanKindClient))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("http.request.method", req.Method),
+		attribute.String("url.path", req.URL.Path),
+	)
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		RecordError(span, err)
+		return resp, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	return resp, nil
+}
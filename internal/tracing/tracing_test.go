@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// okTransport answers every request without allocating
+type okTransport struct{ resp *http.Response }
+
+func (t okTransport) RoundTrip(*http.Request) (*http.Response, error) { return t.resp, nil }
+
+func TestDisabledTracingDoesNotAllocate(t *testing.T) {
+	Use(nil)
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, span := Start(ctx, "noop")
+		RecordError(span, context.Canceled)
+		span.End()
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations for a disabled span, got %v", allocs)
+	}
+
+	transport := Transport(okTransport{resp: &http.Response{StatusCode: http.StatusOK}}, "test")
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/v1/pages", nil)
+	allocs = testing.AllocsPerRun(100, func() {
+		transport.RoundTrip(req)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations for a disabled transport, got %v", allocs)
+	}
+}
+
+func TestTransportRecordsClientSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	Use(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { Use(nil) })
+
+	ctx, parent := Start(context.Background(), "parent")
+	transport := Transport(okTransport{resp: &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"}}, "notion")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "http://example.com/v1/pages", nil)
+	transport.RoundTrip(req)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	client := spans[0]
+	if client.Name != "notion POST" {
+		t.Errorf("Expected span \"notion POST\", got %q", client.Name)
+	}
+	if client.Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Errorf("Expected the request span to be a child of the caller's span")
+	}
+	if client.Status.Description != "404 Not Found" {
+		t.Errorf("Expected the error status to be recorded, got %+v", client.Status)
+	}
+}
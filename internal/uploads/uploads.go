@@ -0,0 +1,201 @@
+// Package uploads stores the photos attached to tasks and serves them, as the Notion
+// library has no file upload API. Pages link the files by their URL.
+package uploads
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// PathPrefix is where the server serves stored files
+	PathPrefix = "/notion/mini-app/files/"
+	// DefaultMaxBytes is the largest file stored without MAX_UPLOAD_BYTES
+	DefaultMaxBytes = 10 << 20
+
+	// defaultDir is where files are stored without UPLOADS_DIR
+	defaultDir = "./data/uploads"
+	// stagingDir holds files whose task isn't created yet, they aren't served
+	stagingDir = "staging"
+	// orphanAge is how long a staged file waits for its task before it's removed
+	orphanAge = time.Hour
+)
+
+// ErrTooLarge is returned for files over the size limit
+var ErrTooLarge = errors.New("file is too large to store")
+
+// namePattern matches the names Stage gives files, anything else isn't served
+var namePattern = regexp.MustCompile(`^[0-9a-f]{32}\.[a-z0-9]{1,5}$`)
+
+// Store keeps files in a directory. A file is staged while its task is created and
+// committed once the page links it, so files of saves that never finished can be cleaned up.
+type Store struct {
+	dir      string
+	baseURL  string // URL the files are served under, ending in a slash
+	maxBytes int64
+}
+
+// New returns a store of the files in dir, served under baseURL
+func New(dir, baseURL string, maxBytes int64) *Store {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	return &Store{dir: dir, baseURL: baseURL, maxBytes: maxBytes}
+}
+
+// FromEnv returns the store of UPLOADS_DIR limited to MAX_UPLOAD_BYTES, with files served
+// on the host of the mini app's URL
+func FromEnv(miniAppURL string) *Store {
+	dir := os.Getenv("UPLOADS_DIR")
+	if dir == "" {
+		dir = defaultDir
+	}
+
+	var maxBytes int64 = DefaultMaxBytes
+	if value := os.Getenv("MAX_UPLOAD_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			log.Printf("Warning: Invalid MAX_UPLOAD_BYTES %q, using %d", value, DefaultMaxBytes)
+		} else {
+			maxBytes = limit
+		}
+	}
+
+	return New(dir, baseURL(miniAppURL), maxBytes)
+}
+
+// baseURL returns PathPrefix on the host of the mini app's URL, empty if it has none
+func baseURL(miniAppURL string) string {
+	u, err := url.Parse(miniAppURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + PathPrefix
+}
+
+// Limit returns the largest file the store takes
+func (s *Store) Limit() int64 {
+	return s.maxBytes
+}
+
+// Enabled reports whether stored files can be linked, which needs the URL they're served at
+func (s *Store) Enabled() bool {
+	return s != nil && s.baseURL != ""
+}
+
+// URL returns the URL a file is served at once it's committed
+func (s *Store) URL(name string) string {
+	return s.baseURL + name
+}
+
+// Stage stores data under a new random name with the given extension, like "jpg". The file
+// isn't served until it's committed.
+func (s *Store) Stage(data []byte, ext string) (string, error) {
+	if int64(len(data)) > s.maxBytes {
+		return "", ErrTooLarge
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate file name: %w", err)
+	}
+	name := hex.EncodeToString(random) + "." + ext
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid file extension %q", ext)
+	}
+
+	staging := filepath.Join(s.dir, stagingDir)
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(staging, name), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to store file: %w", err)
+	}
+	return name, nil
+}
+
+// Commit serves a staged file from now on
+func (s *Store) Commit(name string) error {
+	if err := os.Rename(filepath.Join(s.dir, stagingDir, name), filepath.Join(s.dir, name)); err != nil {
+		return fmt.Errorf("failed to commit file: %w", err)
+	}
+	return nil
+}
+
+// Discard removes a staged file whose task wasn't created
+func (s *Store) Discard(name string) {
+	if err := os.Remove(filepath.Join(s.dir, stagingDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Warning: Could not remove staged file %s: %v", name, err)
+	}
+}
+
+// CleanupOrphans removes the files staged more than an hour before now, left by saves that
+// stopped with the bot. It returns how many it removed.
+func (s *Store) CleanupOrphans(now time.Time) (int, error) {
+	staging := filepath.Join(s.dir, stagingDir)
+	entries, err := os.ReadDir(staging)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list staged files: %w", err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || now.Sub(info.ModTime()) < orphanAge {
+			continue
+		}
+		if err := os.Remove(filepath.Join(staging, entry.Name())); err != nil {
+			log.Printf("Warning: Could not remove orphaned file %s: %v", entry.Name(), err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// Handler serves committed files under PathPrefix, without auth as Notion fetches them.
+// Names are random, so only those with a page linking a file know its URL.
+func (s *Store) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, PathPrefix)
+		if !namePattern.MatchString(name) {
+			http.NotFound(w, r)
+			return
+		}
+
+		file, err := os.Open(filepath.Join(s.dir, name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Files never change once committed
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, info.ModTime(), file)
+	})
+}
@@ -0,0 +1,75 @@
+package uploads
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func get(store *Store, name string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	store.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PathPrefix+name, nil))
+	return rec
+}
+
+func TestStageCommitAndServe(t *testing.T) {
+	store := New(t.TempDir(), baseURL("https://example.com/notion/mini-app"), 8)
+
+	if _, err := store.Stage([]byte("too large"), "jpg"); err != ErrTooLarge {
+		t.Errorf("Expected ErrTooLarge over the limit, got %v", err)
+	}
+
+	name, err := store.Stage([]byte("photo"), "jpg")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	if got := store.URL(name); got != "https://example.com/notion/mini-app/files/"+name {
+		t.Errorf("Unexpected URL %s", got)
+	}
+	if rec := get(store, name); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected a staged file not to be served, got %d", rec.Code)
+	}
+
+	if err := store.Commit(name); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	rec := get(store, name)
+	if rec.Code != http.StatusOK || rec.Body.String() != "photo" {
+		t.Errorf("Expected the committed file served, got %d %q", rec.Code, rec.Body.String())
+	}
+	for _, path := range []string{"", "staging/" + name, "../uploads/" + name, "notes.txt"} {
+		if rec := get(store, path); rec.Code != http.StatusNotFound {
+			t.Errorf("Expected %q not to be served, got %d", path, rec.Code)
+		}
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	store := New(t.TempDir(), "", 0)
+	old, err := store.Stage([]byte("old"), "jpg")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	recent, err := store.Stage([]byte("recent"), "jpg")
+	if err != nil {
+		t.Fatalf("Stage failed: %v", err)
+	}
+	staged := func(name string) string { return filepath.Join(store.dir, stagingDir, name) }
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(staged(old), past, past); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	if n, err := store.CleanupOrphans(time.Now()); err != nil || n != 1 {
+		t.Fatalf("Expected one orphan removed, got %d, %v", n, err)
+	}
+	if _, err := os.Stat(staged(old)); !os.IsNotExist(err) {
+		t.Errorf("Expected the old staged file removed, got %v", err)
+	}
+	if _, err := os.Stat(staged(recent)); err != nil {
+		t.Errorf("Expected the recent staged file kept, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+const (
+	// rollupInterval is how often the counters are added to the daily totals
+	rollupInterval = 10 * time.Minute
+	// dayFormat keys the daily totals
+	dayFormat = "2006-01-02"
+)
+
+// Flush adds the counts since the previous flush to the totals of the day of now. On
+// failure the counts are kept for the next flush.
+func Flush(db *database.DB, now time.Time) error {
+	values := take()
+	if len(values) == 0 {
+		return nil
+	}
+
+	if err := db.AddDailyUsage(now.Format(dayFormat), values); err != nil {
+		for metric, n := range values {
+			Add(metric, n)
+		}
+		return err
+	}
+	return nil
+}
+
+// RunRollup flushes the counters into db until ctx is done. Flushes are aligned to local
+// midnight so counts land in the day they were made.
+func RunRollup(ctx context.Context, db *database.DB) {
+	for {
+		at := nextFlush(time.Now())
+		timer := time.NewTimer(time.Until(at))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err := Flush(db, time.Now()); err != nil {
+				log.Printf("Warning: Failed to flush usage counters: %v", err)
+			}
+			return
+		case <-timer.C:
+			// Counts up to midnight belong to the day that just ended
+			if err := Flush(db, at.Add(-time.Second)); err != nil {
+				log.Printf("Warning: Failed to flush usage counters: %v", err)
+			}
+		}
+	}
+}
+
+// nextFlush returns when the next flush after now is due
+func nextFlush(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	if at := now.Add(rollupInterval); at.Before(midnight) {
+		return at
+	}
+	return midnight
+}
+
+// startOfWeek returns midnight of the Monday of the week containing t
+func startOfWeek(t time.Time) time.Time {
+	daysSinceMonday := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-daysSinceMonday, 0, 0, 0, 0, t.Location())
+}
+
+// Outbox is the state of the outbox when a summary is rendered
+type Outbox struct {
+	Queued int // Writes waiting for a retry
+	Parked int // Writes that ran out of attempts
+}
+
+// WeekSummary flushes pending counts and renders the usage of the week containing now,
+// from Monday up to the day of now, with the current outbox
+func WeekSummary(db *database.DB, now time.Time) (string, error) {
+	if err := Flush(db, now); err != nil {
+		log.Printf("Warning: Failed to flush usage counters: %v", err)
+	}
+
+	from := startOfWeek(now)
+	totals, err := db.GetUsageTotals(from.Format(dayFormat), now.Format(dayFormat))
+	if err != nil {
+		return "", err
+	}
+	var outbox Outbox
+	if outbox.Queued, outbox.Parked, err = db.CountOutbox(); err != nil {
+		return "", err
+	}
+	return FormatSummary(totals, outbox, from, now), nil
+}
+
+// FormatSummary renders usage totals and the outbox as a monospace block (Telegram HTML)
+func FormatSummary(totals map[string]int64, outbox Outbox, from, to time.Time) string {
+	notionCalls := totals[NotionReads] + totals[NotionQueries] + totals[NotionWrites]
+	errorRate := 0.0
+	if notionCalls > 0 {
+		errorRate = float64(totals[NotionErrors]) * 100 / float64(notionCalls)
+	}
+	avgRun := "-"
+	if runs := totals[SchedulerRuns]; runs > 0 {
+		avgRun = (time.Duration(totals[SchedulerRunTime]/runs) * time.Millisecond).Round(100 * time.Millisecond).String()
+	}
+
+	rows := [][2]string{
+		{"Gemini calls", fmt.Sprint(totals[GeminiCalls])},
+		{"Gemini tokens", fmt.Sprint(totals[GeminiTokens])},
+		{"Notion reads", fmt.Sprint(totals[NotionReads])},
+		{"Notion queries", fmt.Sprint(totals[NotionQueries])},
+		{"Notion writes", fmt.Sprint(totals[NotionWrites])},
+		{"Notion errors", fmt.Sprintf("%d (%.1f%%)", totals[NotionErrors], errorRate)},
+		{"Telegram sent", fmt.Sprint(totals[TelegramSent])},
+		{"Scheduler runs", fmt.Sprint(totals[SchedulerRuns])},
+		{"Avg run time", avgRun},
+		{"Outbox queued", fmt.Sprint(outbox.Queued)},
+		{"Dead letters", fmt.Sprint(outbox.Parked)},
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "📈 <b>Usage %s – %s</b>\n<pre>", from.Format("Mon 02 Jan"), to.Format("Mon 02 Jan"))
+	for _, row := range rows {
+		fmt.Fprintf(&b, "\n%-15s %16s", row[0], row[1])
+	}
+	b.WriteString("</pre>")
+	return b.String()
+}
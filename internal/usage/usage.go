@@ -0,0 +1,109 @@
+// Package usage counts calls to Gemini, Notion and Telegram so the operational load of the
+// bot can be summarized. Counters live in memory and are rolled up into daily totals in the
+// local database, which keeps the weekly view independent of process uptime.
+package usage
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric names, as stored in the daily rollup
+const (
+	GeminiCalls      = "gemini_calls"
+	GeminiTokens     = "gemini_tokens"
+	NotionReads      = "notion_reads"
+	NotionQueries    = "notion_queries"
+	NotionWrites     = "notion_writes"
+	NotionErrors     = "notion_errors"
+	TelegramSent     = "telegram_sent"
+	SchedulerRuns    = "scheduler_runs"
+	SchedulerRunTime = "scheduler_run_ms"
+)
+
+var counters = struct {
+	mu     sync.Mutex
+	values map[string]int64
+}{values: make(map[string]int64)}
+
+// Add increases a metric
+func Add(metric string, n int64) {
+	counters.mu.Lock()
+	counters.values[metric] += n
+	counters.mu.Unlock()
+}
+
+// RecordSchedulerRun counts a scheduler run and its duration
+func RecordSchedulerRun(duration time.Duration) {
+	Add(SchedulerRuns, 1)
+	Add(SchedulerRunTime, duration.Milliseconds())
+}
+
+// take returns the counts since the previous call and resets them
+func take() map[string]int64 {
+	counters.mu.Lock()
+	defer counters.mu.Unlock()
+
+	values := counters.values
+	counters.values = make(map[string]int64)
+	return values
+}
+
+// transport counts the requests made through an http.RoundTripper
+type transport struct {
+	base   http.RoundTripper
+	record func(req *http.Request, resp *http.Response, err error)
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	t.record(req, resp, err)
+	return resp, err
+}
+
+// NotionTransport wraps base (http.DefaultTransport when nil) to count Notion calls by
+// method class: reads, queries and writes. Failed calls are also counted as errors.
+func NotionTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, record: recordNotion}
+}
+
+func recordNotion(req *http.Request, resp *http.Response, err error) {
+	switch {
+	case req.Method == http.MethodGet:
+		Add(NotionReads, 1)
+	case req.Method == http.MethodPost && (strings.HasSuffix(req.URL.Path, "/query") || strings.HasSuffix(req.URL.Path, "/search")):
+		Add(NotionQueries, 1)
+	default:
+		Add(NotionWrites, 1)
+	}
+
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		Add(NotionErrors, 1)
+	}
+}
+
+// TelegramTransport wraps base (http.DefaultTransport when nil) to count the messages the
+// bot sends through the Bot API
+func TelegramTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, record: recordTelegram}
+}
+
+func recordTelegram(req *http.Request, resp *http.Response, err error) {
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	// Bot API paths end with the method name, e.g. /bot<token>/sendMessage
+	method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	if strings.HasPrefix(method, "send") && method != "sendChatAction" {
+		Add(TelegramSent, 1)
+	}
+}
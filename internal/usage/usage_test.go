@@ -0,0 +1,159 @@
+package usage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/numero_quadro/notion-mini-app/internal/database"
+)
+
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestRollupAcrossDayBoundaries(t *testing.T) {
+	db := newTestDB(t)
+	take() // Drop counts left by other tests
+
+	// Sunday 12 Oct 2025 and the Monday after it
+	sunday := time.Date(2025, 10, 12, 23, 55, 0, 0, time.UTC)
+	monday := sunday.Add(10 * time.Minute)
+
+	Add(GeminiCalls, 2)
+	Add(NotionReads, 5)
+	if err := Flush(db, sunday); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	Add(GeminiCalls, 1)
+	if err := Flush(db, sunday.Add(time.Minute)); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	Add(GeminiCalls, 4)
+	if err := Flush(db, monday); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+
+	lastWeek, err := db.GetUsageTotals(startOfWeek(sunday).Format(dayFormat), sunday.Format(dayFormat))
+	if err != nil {
+		t.Fatalf("GetUsageTotals failed: %v", err)
+	}
+	if lastWeek[GeminiCalls] != 3 || lastWeek[NotionReads] != 5 {
+		t.Errorf("Expected Sunday's flushes in the old week, got %v", lastWeek)
+	}
+
+	thisWeek, err := db.GetUsageTotals(startOfWeek(monday).Format(dayFormat), monday.Format(dayFormat))
+	if err != nil {
+		t.Fatalf("GetUsageTotals failed: %v", err)
+	}
+	if thisWeek[GeminiCalls] != 4 || thisWeek[NotionReads] != 0 {
+		t.Errorf("Expected only Monday's flush in the new week, got %v", thisWeek)
+	}
+}
+
+func TestNextFlushStopsAtMidnight(t *testing.T) {
+	beforeMidnight := time.Date(2025, 10, 12, 23, 55, 0, 0, time.UTC)
+	if next := nextFlush(beforeMidnight); !next.Equal(time.Date(2025, 10, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a flush at midnight, got %v", next)
+	}
+
+	noon := time.Date(2025, 10, 12, 12, 0, 0, 0, time.UTC)
+	if next := nextFlush(noon); !next.Equal(noon.Add(rollupInterval)) {
+		t.Errorf("Expected a flush after the rollup interval, got %v", next)
+	}
+}
+
+func TestFormatSummary(t *testing.T) {
+	from := time.Date(2025, 10, 6, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 10, 12, 23, 0, 0, 0, time.UTC)
+
+	text := FormatSummary(map[string]int64{
+		GeminiCalls:      12,
+		NotionReads:      150,
+		NotionQueries:    40,
+		NotionWrites:     10,
+		NotionErrors:     4,
+		SchedulerRuns:    2,
+		SchedulerRunTime: 5000,
+	}, Outbox{Queued: 3, Parked: 1}, from, to)
+
+	for _, expected := range []string{"Mon 06 Oct – Sun 12 Oct", "<pre>", "Gemini calls", "4 (2.0%)", "2.5s", "Outbox queued                  3", "Dead letters                   1"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Summary should contain %q, got:\n%s", expected, text)
+		}
+	}
+
+	// Even absurd totals fit in a single Telegram message
+	huge := make(map[string]int64)
+	for _, metric := range []string{GeminiCalls, GeminiTokens, NotionReads, NotionQueries, NotionWrites, NotionErrors, TelegramSent, SchedulerRuns, SchedulerRunTime} {
+		huge[metric] = 1 << 62
+	}
+	if length := utf8.RuneCountInString(FormatSummary(huge, Outbox{Queued: 1 << 62, Parked: 1 << 62}, from, to)); length > 4096 {
+		t.Errorf("Summary is %d characters, over Telegram's limit", length)
+	}
+}
+
+func TestWeekSummaryCountsOutbox(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	for i, parked := range []bool{false, false, true} {
+		id, err := db.EnqueueOutbox(database.OutboxEntry{Operation: "create", ChatID: 1, MessageID: i + 1, Payload: "{}", NextRetryAt: now})
+		if err != nil {
+			t.Fatalf("EnqueueOutbox failed: %v", err)
+		}
+		if parked {
+			if err := db.ParkOutboxEntry(id, 5, "boom"); err != nil {
+				t.Fatalf("ParkOutboxEntry failed: %v", err)
+			}
+		}
+	}
+
+	text, err := WeekSummary(db, now)
+	if err != nil {
+		t.Fatalf("WeekSummary failed: %v", err)
+	}
+	for _, expected := range []string{"Outbox queued                  2", "Dead letters                   1"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Summary should contain %q, got:\n%s", expected, text)
+		}
+	}
+}
+
+func TestTransportsCountCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	take()
+
+	notionClient := &http.Client{Transport: NotionTransport(nil)}
+	notionClient.Get(server.URL + "/v1/pages/1")
+	notionClient.Post(server.URL+"/v1/databases/1/query", "application/json", nil)
+	notionClient.Post(server.URL+"/v1/pages/fail", "application/json", nil)
+
+	telegramClient := &http.Client{Transport: TelegramTransport(nil)}
+	telegramClient.Post(server.URL+"/botTOKEN/sendMessage", "application/json", nil)
+	telegramClient.Post(server.URL+"/botTOKEN/setMessageReaction", "application/json", nil)
+	telegramClient.Post(server.URL+"/botTOKEN/fail", "application/json", nil)
+
+	counts := take()
+	expected := map[string]int64{NotionReads: 1, NotionQueries: 1, NotionWrites: 1, NotionErrors: 1, TelegramSent: 1}
+	for metric, n := range expected {
+		if counts[metric] != n {
+			t.Errorf("Expected %s=%d, got %d", metric, n, counts[metric])
+		}
+	}
+}
@@ -23,10 +23,16 @@ fi
 echo "Setting up webhook for Telegram bot..."
 echo "Webhook URL: $WEBHOOK_URL"
 
+# Include the secret token the server checks, if configured
+SECRET_FIELD=""
+if [ -n "$TELEGRAM_WEBHOOK_SECRET" ]; then
+    SECRET_FIELD=",\"secret_token\":\"${TELEGRAM_WEBHOOK_SECRET}\""
+fi
+
 # Set the webhook
 RESPONSE=$(curl -s -X POST "https://api.telegram.org/bot${TELEGRAM_BOT_TOKEN}/setWebhook" \
     -H "Content-Type: application/json" \
-    -d "{\"url\":\"${WEBHOOK_URL}\",\"allowed_updates\":[\"message\",\"message_reaction\"]}")
+    -d "{\"url\":\"${WEBHOOK_URL}\",\"allowed_updates\":[\"message\",\"edited_message\",\"message_reaction\",\"callback_query\",\"inline_query\",\"chosen_inline_result\"]${SECRET_FIELD}}")
 
 echo "Response from Telegram API:"
 echo $RESPONSE | jq . 2>/dev/null || echo $RESPONSE
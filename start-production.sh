@@ -45,7 +45,7 @@ echo ""
 echo "2️⃣  Checking bot binary..."
 if [ ! -f "./notion-bot" ]; then
     echo "   ⚠️  Binary not found. Building..."
-    go build -o notion-bot cmd/main.go
+    go build -o notion-bot ./cmd
     echo "   ✅ Build complete"
 else
     echo "   ✅ Binary found"
@@ -14,8 +14,9 @@ echo "🔄 Triggering manual task check..."
 echo "Endpoint: $URL"
 echo ""
 
-# Make the API call
-RESPONSE=$(curl -s -X POST "$URL" -H "Content-Type: application/json")
+# The API only accepts requests signed by Telegram, pass the mini app's initData in
+# INIT_DATA (or run the bot with ALLOW_INSECURE_API=true). The /cron bot command works too.
+RESPONSE=$(curl -s -X POST "$URL" -H "Content-Type: application/json" -H "X-Telegram-Init-Data: ${INIT_DATA:-}")
 
 # Check if successful
 if echo "$RESPONSE" | grep -q '"status":"success"'; then
@@ -1,11 +1,20 @@
 const tg = window.Telegram?.WebApp;
 if(tg) tg.expand();
 
+// API calls carry the signed initData so the server can check they come from Telegram
+function apiFetch(url, options = {}) {
+  const headers = new Headers(options.headers || {});
+  if (tg?.initData) headers.set('X-Telegram-Init-Data', tg.initData);
+  return fetch(url, { ...options, headers });
+}
+
 // Cache and state
 let schemaCache = {};
 let submitting = false;
 let currentDbType = "tasks"; // Track current database type
 let currentSection = "home"; // Track current section (home, form, recent-tasks)
+let appConfig = {}; // Config returned by /api/config
+let focusTaskId = null; // Task to highlight when opened via a deep link
 
 // Renderers for different property types
 const renderers = {
@@ -88,7 +97,7 @@ function multiSelectField(key,cfg){
 async function fetchSchema(dbType = "tasks"){
   try {
     if(!schemaCache[dbType]){
-      const r=await fetch(`/notion/mini-app/api/properties?db_type=${dbType}`);
+      const r=await apiFetch(`/notion/mini-app/api/properties?db_type=${dbType}`);
       const data = await r.json();
       
       if (data && data.properties) {
@@ -299,7 +308,7 @@ async function handleSubmit(e){
     console.log("Submitting data:", payload);
     
     try {
-      const res = await fetch(`/notion/mini-app/api/tasks?db_type=${currentDbType}`, {
+      const res = await apiFetch(`/notion/mini-app/api/tasks?db_type=${currentDbType}`, {
         method: 'POST',
         headers: {'Content-Type': 'application/json'},
         body: JSON.stringify(payload)
@@ -404,12 +413,16 @@ async function loadRecentTasks() {
   tasksList.innerHTML = '<div class="loading-indicator">Loading recent tasks...</div>';
   
   try {
-    const response = await fetch('/notion/mini-app/api/recent-tasks?db_type=tasks');
+    const response = await apiFetch('/notion/mini-app/api/recent-tasks?db_type=tasks');
     if (!response.ok) {
       throw new Error('Failed to fetch recent tasks');
     }
     
-    const tasks = await response.json();
+    const data = await response.json();
+    const tasks = data.tasks || [];
+    if (data.degraded) {
+      showWarning("Notion filtering is degraded, some tasks may be missing");
+    }
     
     if (tasks.length === 0) {
       tasksList.innerHTML = '<div class="no-tasks">No tasks found matching criteria</div>';
@@ -463,11 +476,20 @@ async function loadRecentTasks() {
         </div>
       `;
       
+      // Highlight the task opened from a confirmation card deep link
+      if (focusTaskId && task.id.replace(/-/g, '') === focusTaskId) {
+        taskItem.classList.add('highlighted');
+      }
+      
       taskList.appendChild(taskItem);
     });
     
     tasksList.appendChild(taskList);
     
+    const highlighted = taskList.querySelector('.task-item.highlighted');
+    if (highlighted) highlighted.scrollIntoView({block: 'center'});
+    focusTaskId = null;
+    
     // Add event listeners to checkboxes
     document.querySelectorAll('.task-complete-checkbox').forEach(checkbox => {
       checkbox.addEventListener('change', handleTaskComplete);
@@ -493,7 +515,7 @@ async function handleTaskComplete(event) {
     taskItem.classList.add('updating');
     
     // Update task status to "done"
-    const response = await fetch('/notion/mini-app/api/update-task-status', {
+    const response = await apiFetch('/notion/mini-app/api/update-task-status', {
       method: 'POST',
       headers: {
         'Content-Type': 'application/json'
@@ -542,10 +564,11 @@ async function handleTaskComplete(event) {
 // Check database availability from config
 async function checkDatabaseAvailability() {
   try {
-    const response = await fetch('/notion/mini-app/api/config');
+    const response = await apiFetch('/notion/mini-app/api/config');
     if (!response.ok) return;
     
     const config = await response.json();
+    appConfig = config;
     
     // Hide database tiles if not available
     if (config.HAS_NOTES_DB !== "true") {
@@ -577,6 +600,30 @@ async function checkDatabaseAvailability() {
   }
 }
 
+// Load open-item counts and show them as badges on the database tiles
+async function loadCountBadges() {
+  try {
+    const response = await apiFetch('/notion/mini-app/api/counts');
+    if (!response.ok) return;
+    
+    const counts = await response.json();
+    const tiles = { tasks: '.tasks-tile', notes: '.notes-tile', journal: '.journal-tile' };
+    
+    for (const [dbType, selector] of Object.entries(tiles)) {
+      const count = counts[dbType];
+      const title = document.querySelector(`${selector} .tile-title`);
+      if (!count || !title) continue;
+      
+      const badge = create('span', { className: 'tile-badge' }, `(${count.count}${count.approximate ? '+' : ''})`);
+      title.appendChild(document.createTextNode(' '));
+      title.appendChild(badge);
+    }
+  } catch (error) {
+    // Badges are cosmetic, don't bother the user
+    console.error("Error loading counts:", error);
+  }
+}
+
 // Fetch and display projects
 async function loadProjects() {
   const projectsSection = document.getElementById('projectsSection');
@@ -594,7 +641,7 @@ async function loadProjects() {
   projectsContainer.innerHTML = '<div class="loading-indicator">Loading projects...</div>';
   
   try {
-    const response = await fetch('/notion/mini-app/api/projects');
+    const response = await apiFetch('/notion/mini-app/api/projects');
     if (!response.ok) {
       throw new Error('Failed to fetch projects');
     }
@@ -978,9 +1025,21 @@ document.addEventListener('DOMContentLoaded', async () => {
   // Check which databases are available
   await checkDatabaseAvailability();
   
+  // Badges load in the background, counting can take a few Notion queries
+  loadCountBadges();
+  
   // Setup form submission
     document.getElementById('taskForm').addEventListener('submit', handleSubmit);
   
+  // Deep links from confirmation cards open the recent tasks list focused on the task
+  const startParam = tg?.initDataUnsafe?.start_param;
+  const prefix = appConfig.TASK_START_PARAM_PREFIX;
+  if (startParam && prefix && startParam.startsWith(prefix)) {
+    focusTaskId = startParam.slice(prefix.length);
+    navigateTo('recent-tasks');
+    return;
+  }
+  
   // Start on the home screen
   navigateTo('home');
 }); 
\ No newline at end of file